@@ -0,0 +1,327 @@
+// Package catalog reads and writes product catalog files in CSV and JSON so the catalog can be maintained
+// in a spreadsheet or under version control and loaded with `mangal-admin catalog import`.
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mangal-chai-backend/models"
+)
+
+// Format is a catalog file format.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// Columns is the CSV header written on export and accepted on import, in any order. A product is in stock
+// exactly when its stock is positive, so in_stock is derived rather than read from the file. A file without
+// a stock column, or a blank stock cell, leaves the product's stock as it is.
+var Columns = []string{"id", "name", "description", "price", "category", "image_url", "stock", "weight", "hsn_code", "gst_rate"}
+
+// RowError describes a problem with one record. Line is the 1-based line the record starts on.
+type RowError struct {
+	Line    int
+	Field   string
+	Message string
+}
+
+func (e RowError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Field, e.Message)
+}
+
+// ValidationErrors collects every RowError in a file so they can all be fixed in one pass.
+type ValidationErrors []RowError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, rowErr := range e {
+		lines[i] = rowErr.Error()
+	}
+	return fmt.Sprintf("%d invalid catalog rows:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// FormatFromPath infers the format from a file extension.
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("cannot infer catalog format from %q, use .csv or .json", path)
+	}
+}
+
+// Read parses and validates a catalog. If any record is invalid it returns ValidationErrors listing all of them.
+func Read(r io.Reader, format Format) ([]models.CatalogEntry, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatJSON:
+		return readJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported catalog format %q", format)
+	}
+}
+
+// Write serialises products in the given format.
+func Write(w io.Writer, format Format, products []models.Product) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, products)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(products)
+	default:
+		return fmt.Errorf("unsupported catalog format %q", format)
+	}
+}
+
+func readCSV(r io.Reader) ([]models.CatalogEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	var errs ValidationErrors
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isColumn(name) {
+			errs = append(errs, RowError{Line: 1, Field: name, Message: "unknown column"})
+			continue
+		}
+		index[name] = i
+	}
+	for _, required := range []string{"id", "name", "price", "category"} {
+		if _, ok := index[required]; !ok {
+			errs = append(errs, RowError{Line: 1, Field: required, Message: "missing required column"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var entries []models.CatalogEntry
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				errs = append(errs, RowError{Line: parseErr.StartLine, Message: parseErr.Err.Error()})
+				continue
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		entry := models.CatalogEntry{Product: models.Product{
+			ID:          field("id"),
+			Name:        field("name"),
+			Description: field("description"),
+			Category:    field("category"),
+			ImageURL:    field("image_url"),
+			Weight:      field("weight"),
+			HSNCode:     field("hsn_code"),
+		}}
+		if raw := field("price"); raw != "" {
+			price, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				errs = append(errs, RowError{Line: line, Field: "price", Message: fmt.Sprintf("%q is not a number", raw)})
+				continue
+			}
+			entry.Price = price
+		}
+		if raw := field("stock"); raw != "" {
+			stock, err := strconv.Atoi(raw)
+			if err != nil {
+				errs = append(errs, RowError{Line: line, Field: "stock", Message: fmt.Sprintf("%q is not a whole number", raw)})
+				continue
+			}
+			entry.Stock, entry.HasStock = stock, true
+		}
+		if raw := field("gst_rate"); raw != "" {
+			rate, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				errs = append(errs, RowError{Line: line, Field: "gst_rate", Message: fmt.Sprintf("%q is not a number", raw)})
+				continue
+			}
+			entry.GSTRate = rate
+		}
+		entry.InStock = entry.Stock > 0
+
+		entries = append(entries, entry)
+		lines = append(lines, line)
+	}
+
+	errs = append(errs, validate(entries, lines)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return entries, nil
+}
+
+// jsonProduct is a product in a JSON catalog. Stock is a pointer so a product without one can be told apart
+// from one that has run out.
+type jsonProduct struct {
+	models.Product
+	Stock *int `json:"stock"`
+}
+
+func readJSON(r io.Reader) ([]models.CatalogEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, ValidationErrors{{Line: 1, Message: "catalog must be a JSON array of products"}}
+	}
+
+	var entries []models.CatalogEntry
+	var lines []int
+	var errs ValidationErrors
+	for dec.More() {
+		line := lineAt(data, dec.InputOffset())
+		var product jsonProduct
+		if err := dec.Decode(&product); err != nil {
+			// The decoder cannot resynchronise after a syntax or type error, so stop at the first one.
+			errs = append(errs, RowError{Line: line, Message: err.Error()})
+			return nil, errs
+		}
+		entry := models.CatalogEntry{Product: product.Product}
+		if product.Stock != nil {
+			entry.Stock, entry.HasStock = *product.Stock, true
+		}
+		entry.InStock = entry.Stock > 0
+		entries = append(entries, entry)
+		lines = append(lines, line)
+	}
+
+	errs = append(errs, validate(entries, lines)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return entries, nil
+}
+
+// lineAt returns the line of the first value character at or after offset, skipping separators.
+func lineAt(data []byte, offset int64) int {
+	i := int(offset)
+	for i < len(data) && strings.ContainsRune(" \t\r\n,", rune(data[i])) {
+		i++
+	}
+	return bytes.Count(data[:i], []byte("\n")) + 1
+}
+
+func validate(entries []models.CatalogEntry, lines []int) ValidationErrors {
+	var errs ValidationErrors
+	seen := make(map[string]int, len(entries))
+	for i, p := range entries {
+		line := lines[i]
+		if p.ID == "" {
+			errs = append(errs, RowError{Line: line, Field: "id", Message: "is required"})
+		} else if first, ok := seen[p.ID]; ok {
+			errs = append(errs, RowError{Line: line, Field: "id", Message: fmt.Sprintf("duplicates line %d", first)})
+		} else {
+			seen[p.ID] = line
+		}
+		if p.Name == "" {
+			errs = append(errs, RowError{Line: line, Field: "name", Message: "is required"})
+		}
+		if p.Category == "" {
+			errs = append(errs, RowError{Line: line, Field: "category", Message: "is required"})
+		}
+		if p.Price <= 0 {
+			errs = append(errs, RowError{Line: line, Field: "price", Message: "must be greater than zero"})
+		}
+		if p.Stock < 0 {
+			errs = append(errs, RowError{Line: line, Field: "stock", Message: "cannot be negative"})
+		}
+		if p.GSTRate < 0 || p.GSTRate > 100 {
+			errs = append(errs, RowError{Line: line, Field: "gst_rate", Message: "must be a percentage between 0 and 100"})
+		}
+		if p.ImageURL != "" {
+			if u, err := url.Parse(p.ImageURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, RowError{Line: line, Field: "image_url", Message: "must be an http(s) URL"})
+			}
+		}
+	}
+	return errs
+}
+
+func writeCSV(w io.Writer, products []models.Product) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(Columns); err != nil {
+		return err
+	}
+	for _, p := range products {
+		record := []string{
+			p.ID,
+			p.Name,
+			p.Description,
+			strconv.FormatFloat(p.Price, 'f', -1, 64),
+			p.Category,
+			p.ImageURL,
+			strconv.Itoa(p.Stock),
+			p.Weight,
+			p.HSNCode,
+			gstRate(p.GSTRate),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// gstRate writes a product's GST rate, leaving it blank when the product uses the shop's default.
+func gstRate(rate float64) string {
+	if rate == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+func isColumn(name string) bool {
+	for _, column := range Columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
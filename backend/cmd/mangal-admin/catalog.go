@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"mangal-chai-backend/catalog"
+	"mangal-chai-backend/database"
+	"mangal-chai-backend/jobs"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/services"
+)
+
+func runCatalog(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected `catalog import` or `catalog export`")
+	}
+
+	switch args[0] {
+	case "import":
+		return runCatalogImport(ctx, args[1:])
+	case "export":
+		return runCatalogExport(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown catalog command %q", args[0])
+	}
+}
+
+func runCatalogImport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("catalog import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "validate and report changes without writing them")
+	format := flags.String("format", "", "csv or json (default: inferred from the file extension)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: mangal-admin catalog import [-dry-run] [-format csv|json] FILE")
+	}
+	path := flags.Arg(0)
+
+	catalogFormat, err := resolveFormat(*format, path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Validate the whole file before connecting so a bad file never touches the database.
+	products, err := catalog.Read(file, catalogFormat)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Disconnect()
+	productService := &services.ProductService{
+		Repository: &repositories.ProductRepository{Collection: db.Collection("products")},
+		Restocks:   &jobs.StockEvents{Jobs: &repositories.JobRepository{Collection: db.Collection("jobs")}},
+	}
+
+	result, err := productService.ImportCatalog(ctx, products, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Imported"
+	if result.DryRun {
+		verb = "Dry run, would import"
+	}
+	fmt.Printf("%s %d products: %d created, %d updated, %d unchanged\n",
+		verb, len(products), len(result.Created), len(result.Updated), len(result.Unchanged))
+	printIDs("created", result.Created)
+	printIDs("updated", result.Updated)
+	return nil
+}
+
+func runCatalogExport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("catalog export", flag.ExitOnError)
+	format := flags.String("format", "", "csv or json (default: inferred from -o, or csv)")
+	output := flags.String("o", "", "file to write (default: stdout)")
+	flags.Parse(args)
+
+	catalogFormat := catalog.FormatCSV
+	if *format != "" || *output != "" {
+		var err error
+		if catalogFormat, err = resolveFormat(*format, *output); err != nil {
+			return err
+		}
+	}
+
+	db, err := database.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Disconnect()
+	productService := &services.ProductService{Repository: &repositories.ProductRepository{Collection: db.Collection("products")}}
+
+	products, err := productService.ExportCatalog(ctx)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := catalog.Write(w, catalogFormat, products); err != nil {
+		return err
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d products to %s\n", len(products), *output)
+	}
+	return nil
+}
+
+func resolveFormat(format, path string) (catalog.Format, error) {
+	switch strings.ToLower(format) {
+	case "":
+		return catalog.FormatFromPath(path)
+	case string(catalog.FormatCSV):
+		return catalog.FormatCSV, nil
+	case string(catalog.FormatJSON):
+		return catalog.FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q, use csv or json", format)
+	}
+}
+
+func printIDs(label string, ids []string) {
+	for _, id := range ids {
+		fmt.Printf("  %s %s\n", label, id)
+	}
+}
@@ -0,0 +1,43 @@
+// Command mangal-admin runs operational tasks against the Mangal Chai database.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: mangal-admin <command> [arguments]
+
+commands:
+  migrate up       apply pending schema migrations
+  migrate status   list applied and pending schema migrations
+  catalog import   load products from a CSV or JSON catalog file, upserting by id
+  catalog export   write the live catalog as CSV or JSON
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "migrate":
+		err = runMigrate(context.Background(), os.Args[2:])
+	case "catalog":
+		err = runCatalog(context.Background(), os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
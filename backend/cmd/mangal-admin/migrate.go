@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"mangal-chai-backend/database"
+)
+
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected `migrate up` or `migrate status`")
+	}
+
+	db, err := database.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Disconnect()
+	migrator := database.NewMigrator(db)
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+		return nil
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func printMigrationStatus(ctx context.Context, migrator *database.Migrator) error {
+	applied, err := migrator.Applied(ctx)
+	if err != nil {
+		return err
+	}
+	pending, pendingErr := migrator.Pending(ctx)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATUS\tDESCRIPTION")
+	for _, a := range applied {
+		fmt.Fprintf(w, "%d\tapplied %s\t%s\n", a.Version, a.AppliedAt.Format("2006-01-02 15:04"), a.Description)
+	}
+	for _, p := range pending {
+		fmt.Fprintf(w, "%d\tpending\t%s\n", p.Version, p.Description)
+	}
+	w.Flush()
+	return pendingErr
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CartTokenHeader carries a guest's cart token. New guest carts return their token in this header as well
+// as in the cart body.
+const CartTokenHeader = "X-Cart-Token"
+
+// CartController serves the shopping cart of the logged-in customer or, for guests, the cart named by
+// the cart token.
+type CartController struct {
+	Service  services.CartServiceInterface
+	Recovery services.CartRecoveryServiceInterface
+}
+
+func (c *CartController) GetCart(ctx *gin.Context) {
+	cart, err := c.Service.GetCart(ctx.Request.Context(), cartKey(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching cart"})
+		return
+	}
+	ctx.JSON(http.StatusOK, cart)
+}
+
+func (c *CartController) UpdateCart(ctx *gin.Context) {
+	var update services.CartUpdate
+	if err := ctx.ShouldBindJSON(&update); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := c.Service.UpdateCart(ctx.Request.Context(), cartKey(ctx), update)
+	if errors.Is(err, services.ErrInvalidCart) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating cart"})
+		return
+	}
+	if cart.Token != "" {
+		ctx.Header(CartTokenHeader, cart.Token)
+	}
+	ctx.JSON(http.StatusOK, cart)
+}
+
+func (c *CartController) DeleteCart(ctx *gin.Context) {
+	if err := c.Service.DeleteCart(ctx.Request.Context(), cartKey(ctx)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting cart"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Cart deleted"})
+}
+
+// RestoreCart opens the cart from an abandoned cart reminder link.
+func (c *CartController) RestoreCart(ctx *gin.Context) {
+	var request struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := c.Recovery.RestoreCart(ctx.Request.Context(), request.Token, ctx.GetString(middleware.CustomerIDKey))
+	switch {
+	case errors.Is(err, services.ErrInvalidToken):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "This link is invalid or has expired"})
+	case errors.Is(err, services.ErrCartLoginRequired):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCartNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "This cart is no longer available"})
+	case errors.Is(err, services.ErrCartRecoveryDisabled):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error restoring cart"})
+	default:
+		if cart.Token != "" {
+			ctx.Header(CartTokenHeader, cart.Token)
+		}
+		ctx.JSON(http.StatusOK, cart)
+	}
+}
+
+func (c *CartController) RecoveryReport(ctx *gin.Context) {
+	report, err := c.Recovery.RecoveryReport(ctx.Request.Context(), ctx.Query("from"), ctx.Query("to"))
+	if errors.Is(err, services.ErrInvalidRecoveryPeriod) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error building report"})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+func cartKey(ctx *gin.Context) services.CartKey {
+	return services.CartKey{
+		Token:      ctx.GetHeader(CartTokenHeader),
+		CustomerID: ctx.GetString(middleware.CustomerIDKey),
+	}
+}
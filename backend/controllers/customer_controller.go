@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomerController serves customer login by one-time code.
+type CustomerController struct {
+	Service services.CustomerServiceInterface
+}
+
+func (c *CustomerController) RequestOTP(ctx *gin.Context) {
+	var request struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := c.Service.RequestLoginOTP(ctx.Request.Context(), request.Phone)
+	switch {
+	case errors.Is(err, services.ErrLoginDisabled):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPhone):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOTPTooSoon):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Could not send the code, please try again"})
+	default:
+		ctx.JSON(http.StatusOK, gin.H{"message": "Code sent"})
+	}
+}
+
+func (c *CustomerController) Login(ctx *gin.Context) {
+	var request services.LoginRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.CartToken == "" {
+		request.CartToken = ctx.GetHeader(CartTokenHeader)
+	}
+
+	result, err := c.Service.Login(ctx.Request.Context(), request)
+	switch {
+	case errors.Is(err, services.ErrLoginDisabled):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPhone):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidOTP):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// GetCurrentCustomer returns the logged-in customer.
+func (c *CustomerController) GetCurrentCustomer(ctx *gin.Context) {
+	customerID := ctx.GetString(middleware.CustomerIDKey)
+	if customerID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	customer, err := c.Service.GetCustomer(ctx.Request.Context(), customerID)
+	if errors.Is(err, services.ErrCustomerNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching customer"})
+		return
+	}
+	ctx.JSON(http.StatusOK, customer)
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GiftCardController serves gift card balances, customers' store credit and the admin gift card endpoints.
+type GiftCardController struct {
+	Service services.GiftCardServiceInterface
+}
+
+func (c *GiftCardController) IssueGiftCard(ctx *gin.Context) {
+	var request services.IssueGiftCardRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card, err := c.Service.IssueGiftCard(ctx.Request.Context(), request)
+	if errors.Is(err, services.ErrInvalidGiftCardRequest) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error issuing gift card"})
+		return
+	}
+	ctx.JSON(http.StatusCreated, card)
+}
+
+func (c *GiftCardController) GetBalance(ctx *gin.Context) {
+	balance, err := c.Service.GetBalance(ctx.Request.Context(), ctx.Param("code"))
+	if errors.Is(err, services.ErrGiftCardNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Gift card not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching gift card"})
+		return
+	}
+	ctx.JSON(http.StatusOK, balance)
+}
+
+func (c *GiftCardController) GetWallet(ctx *gin.Context) {
+	customerID, ok := loggedInCustomer(ctx)
+	if !ok {
+		return
+	}
+	statement, err := c.Service.GetWallet(ctx.Request.Context(), customerID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching wallet"})
+		return
+	}
+	ctx.JSON(http.StatusOK, statement)
+}
+
+func (c *GiftCardController) ListLedger(ctx *gin.Context) {
+	entries, err := c.Service.ListLedger(ctx.Request.Context(), ctx.Query("account"), ctx.Query("account_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching ledger"})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"mangal-chai-backend/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthController serves the liveness and readiness probes. The readiness report gives each dependency's
+// status to anyone; their errors and details can name hosts and configuration, so they are only shown to
+// callers with DetailsToken as a bearer token.
+type HealthController struct {
+	Checker      *health.Checker
+	DetailsToken string
+}
+
+// Live reports that the process is up and handling requests. It checks no dependencies, so an outage
+// elsewhere does not get every instance restarted.
+func (c *HealthController) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Ready checks every dependency, with 503 Service Unavailable while a critical one is failing so the load
+// balancer sends traffic elsewhere. Only callers allowed to see the details get each one's error and details.
+func (c *HealthController) Ready(ctx *gin.Context) {
+	report := c.Checker.Check(ctx.Request.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	if !c.showDetails(ctx) {
+		report = report.WithoutDetails()
+	}
+	ctx.JSON(status, report)
+}
+
+func (c *HealthController) showDetails(ctx *gin.Context) bool {
+	if c.DetailsToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(c.DetailsToken)) == 1
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvoiceController serves orders' tax invoices as PDFs.
+type InvoiceController struct {
+	Service services.InvoiceServiceInterface
+}
+
+// GetInvoice serves an order's invoice to the logged-in customer who placed it, or to a guest who gives the
+// order's phone or email as a query parameter.
+func (c *InvoiceController) GetInvoice(ctx *gin.Context) {
+	c.serveInvoice(ctx, services.OrderAccess{
+		CustomerID: ctx.GetString(middleware.CustomerIDKey),
+		Phone:      ctx.Query("phone"),
+		Email:      ctx.Query("email"),
+	})
+}
+
+// GetAdminInvoice serves any order's invoice.
+func (c *InvoiceController) GetAdminInvoice(ctx *gin.Context) {
+	c.serveInvoice(ctx, services.OrderAccess{Admin: true})
+}
+
+func (c *InvoiceController) serveInvoice(ctx *gin.Context, access services.OrderAccess) {
+	invoice, pdf, err := c.Service.GetInvoicePDF(ctx.Request.Context(), ctx.Param("order_id"), access)
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No invoice yet, invoices are issued once an order is paid"})
+		return
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching invoice"})
+		return
+	}
+
+	filename := fmt.Sprintf("invoice-%s.pdf", strings.ReplaceAll(invoice.Number, "/", "-"))
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Data(http.StatusOK, "application/pdf", pdf)
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobController serves the admin background job endpoints.
+type JobController struct {
+	Service services.JobServiceInterface
+}
+
+func (c *JobController) ListJobs(ctx *gin.Context) {
+	jobs, err := c.Service.ListJobs(ctx.Request.Context(), ctx.Query("status"))
+	if errors.Is(err, services.ErrInvalidJobQuery) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching jobs"})
+		return
+	}
+	ctx.JSON(http.StatusOK, jobs)
+}
+
+func (c *JobController) ListDeadJobs(ctx *gin.Context) {
+	jobs, err := c.Service.ListDeadJobs(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching jobs"})
+		return
+	}
+	ctx.JSON(http.StatusOK, jobs)
+}
+
+func (c *JobController) RetryJob(ctx *gin.Context) {
+	job, err := c.Service.RetryJob(ctx.Request.Context(), ctx.Param("job_id"))
+	if errors.Is(err, services.ErrJobNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"net/http"
+
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoyaltyController serves logged-in customers' loyalty points.
+type LoyaltyController struct {
+	Service services.LoyaltyServiceInterface
+}
+
+func (c *LoyaltyController) GetAccount(ctx *gin.Context) {
+	customerID, ok := loggedInCustomer(ctx)
+	if !ok {
+		return
+	}
+	account, err := c.Service.GetAccount(ctx.Request.Context(), customerID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching loyalty points"})
+		return
+	}
+	ctx.JSON(http.StatusOK, account)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MessagingController serves WhatsApp/SMS opt-in management and the provider's callbacks.
+type MessagingController struct {
+	Service services.MessagingServiceInterface
+}
+
+func (c *MessagingController) RequestOTP(ctx *gin.Context) {
+	var request struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := c.Service.RequestOptInOTP(ctx.Request.Context(), request.Phone)
+	switch {
+	case errors.Is(err, services.ErrInvalidPhone):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOTPTooSoon):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Could not send the code, please try again"})
+	default:
+		ctx.JSON(http.StatusOK, gin.H{"message": "Code sent"})
+	}
+}
+
+func (c *MessagingController) OptIn(ctx *gin.Context) {
+	var request services.OptInRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preference, err := c.Service.OptIn(ctx.Request.Context(), request)
+	switch {
+	case errors.Is(err, services.ErrInvalidPhone), errors.Is(err, services.ErrInvalidChannel):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidOTP):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, preference)
+	}
+}
+
+func (c *MessagingController) OptOut(ctx *gin.Context) {
+	var request struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preference, err := c.Service.OptOut(ctx.Request.Context(), request.Phone)
+	if errors.Is(err, services.ErrInvalidPhone) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, preference)
+}
+
+// HandleCallback receives delivery statuses and inbound replies from the messaging provider.
+func (c *MessagingController) HandleCallback(ctx *gin.Context) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.Service.HandleCallback(ctx.Request.Context(), body, ctx.GetHeader("X-Messaging-Signature"))
+	if errors.Is(err, services.ErrInvalidCallbackSignature) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -1,7 +1,8 @@
 package controllers
 
 import (
-	"mangal-chai-backend/models"
+	"errors"
+	"mangal-chai-backend/middleware"
 	"mangal-chai-backend/services"
 	"net/http"
 
@@ -13,32 +14,174 @@ type OrderController struct {
 }
 
 func (c *OrderController) CreateOrder(ctx *gin.Context) {
-	var orderData struct {
-		CustomerInfo models.CustomerInfo `json:"customer_info"`
-		Items        []models.CartItem   `json:"items"`
-		Notes        string              `json:"notes"`
-	}
+	var orderData services.CreateOrderRequest
 
 	if err := ctx.ShouldBindJSON(&orderData); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	order, err := c.Service.CreateOrder(orderData)
+	orderData.CustomerID = ctx.GetString(middleware.CustomerIDKey)
+	if orderData.CartToken == "" {
+		orderData.CartToken = ctx.GetHeader(CartTokenHeader)
+	}
+
+	order, err := c.Service.CreateOrder(ctx.Request.Context(), orderData)
+	if errors.Is(err, services.ErrInvalidCoupon) || errors.Is(err, services.ErrInvalidGiftCard) ||
+		errors.Is(err, services.ErrInvalidPoints) || errors.Is(err, services.ErrInsufficientPoints) ||
+		errors.Is(err, services.ErrInvalidGSTIN) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrStoreCreditChanged) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Order placed successfully", "order_id": order.ID, "total_amount": order.TotalAmount})
+	// amount_due is what is left to pay through the gateway; orders paid in full with a gift card or store
+	// credit are already confirmed.
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        "Order placed successfully",
+		"order_id":       order.ID,
+		"total_amount":   order.TotalAmount,
+		"amount_due":     services.AmountDue(order),
+		"payment_status": order.PaymentStatus,
+	})
 }
 
+// GetOrder serves an order to the logged-in customer who placed it, or to a guest passing the order's phone
+// or email.
 func (c *OrderController) GetOrder(ctx *gin.Context) {
-	orderID := ctx.Param("order_id")
-	order, err := c.Service.GetOrder(orderID)
-	if err != nil {
+	access := services.OrderAccess{
+		CustomerID: ctx.GetString(middleware.CustomerIDKey),
+		Phone:      ctx.Query("phone"),
+		Email:      ctx.Query("email"),
+	}
+	if access.CustomerID == "" && access.Phone == "" && access.Email == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone or email is required"})
+		return
+	}
+
+	order, err := c.Service.GetOrder(ctx.Request.Context(), ctx.Param("order_id"), access)
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching order"})
+	default:
+		ctx.JSON(http.StatusOK, order)
+	}
+}
+
+// TrackOrder serves the public tracking page, for guests who ordered without an account.
+func (c *OrderController) TrackOrder(ctx *gin.Context) {
+	var request services.TrackOrderRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Phone == "" && request.Email == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone or email is required"})
+		return
+	}
+
+	tracking, err := c.Service.TrackOrder(ctx.Request.Context(), request)
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No order matches that order number and phone or email"})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching order"})
+	default:
+		ctx.JSON(http.StatusOK, tracking)
+	}
+}
+
+func (c *OrderController) CancelOrder(ctx *gin.Context) {
+	var request services.CancelOrderRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Phone == "" && request.Email == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone or email is required"})
+		return
+	}
+
+	order, err := c.Service.CancelOrder(ctx.Request.Context(), ctx.Param("order_id"), request)
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	case errors.Is(err, services.ErrOrderNotCancellable):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error cancelling order"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Order cancelled", "order": order})
+}
+
+// ListOrders serves the admin order list.
+func (c *OrderController) ListOrders(ctx *gin.Context) {
+	var query services.OrderListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := c.Service.ListOrders(ctx.Request.Context(), query)
+	if errors.Is(err, services.ErrInvalidOrderQuery) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, order)
-}
\ No newline at end of file
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching orders"})
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (c *OrderController) BulkUpdateStatus(ctx *gin.Context) {
+	var request services.BulkStatusRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := c.Service.BulkUpdateStatus(ctx.Request.Context(), request)
+	if errors.Is(err, services.ErrInvalidStatusChange) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (c *OrderController) ShipOrder(ctx *gin.Context) {
+	var request services.ShipOrderRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := c.Service.ShipOrder(ctx.Request.Context(), ctx.Param("order_id"), request)
+	switch {
+	case errors.Is(err, services.ErrInvalidStatusChange):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrderNotShippable):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, order)
+	}
+}
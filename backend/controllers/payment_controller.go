@@ -2,6 +2,8 @@
 package controllers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"os"
 
@@ -23,7 +25,15 @@ func (pc *PaymentController) CreateRazorpayOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := pc.Service.CreateRazorpayOrder(req)
+	order, err := pc.Service.CreateRazorpayOrder(c.Request.Context(), req)
+	if errors.Is(err, services.ErrOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if errors.Is(err, services.ErrNothingToPay) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -36,3 +46,23 @@ func (pc *PaymentController) CreateRazorpayOrder(c *gin.Context) {
 		"key_id":   os.Getenv("RAZORPAY_KEY_ID"),
 	})
 }
+
+// HandleWebhook receives payment and refund events from Razorpay
+func (pc *PaymentController) HandleWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = pc.Service.HandleWebhook(c.Request.Context(), body, c.GetHeader("X-Razorpay-Signature"))
+	if errors.Is(err, services.ErrInvalidWebhookSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
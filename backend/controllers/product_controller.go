@@ -12,7 +12,7 @@ type ProductController struct {
 }
 
 func (c *ProductController) GetProducts(ctx *gin.Context) {
-	products, err := c.Service.GetProducts()
+	products, err := c.Service.GetProducts(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching products"})
 		return
@@ -22,7 +22,7 @@ func (c *ProductController) GetProducts(ctx *gin.Context) {
 
 func (c *ProductController) GetProduct(ctx *gin.Context) {
 	productID := ctx.Param("product_id")
-	product, err := c.Service.GetProduct(productID)
+	product, err := c.Service.GetProduct(ctx.Request.Context(), productID)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
@@ -32,7 +32,7 @@ func (c *ProductController) GetProduct(ctx *gin.Context) {
 
 func (c *ProductController) GetProductsByCategory(ctx *gin.Context) {
 	category := ctx.Param("category")
-	products, err := c.Service.GetProductsByCategory(category)
+	products, err := c.Service.GetProductsByCategory(ctx.Request.Context(), category)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching products"})
 		return
@@ -41,7 +41,7 @@ func (c *ProductController) GetProductsByCategory(ctx *gin.Context) {
 }
 
 func (c *ProductController) GetCategories(ctx *gin.Context) {
-	categories, err := c.Service.GetCategories()
+	categories, err := c.Service.GetCategories(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching categories"})
 		return
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefundController serves the admin refund endpoints.
+type RefundController struct {
+	Service services.RefundServiceInterface
+}
+
+func (c *RefundController) IssueRefund(ctx *gin.Context) {
+	var request services.RefundRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := c.Service.IssueRefund(ctx.Request.Context(), ctx.Param("order_id"), request)
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, services.ErrOrderNotPaid), errors.Is(err, services.ErrNothingToRefund):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRefund):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGatewayRefundFailed):
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "refund": refund})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusCreated, refund)
+	}
+}
+
+func (c *RefundController) GetOrderRefunds(ctx *gin.Context) {
+	refunds, err := c.Service.GetOrderRefunds(ctx.Request.Context(), ctx.Param("order_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching refunds"})
+		return
+	}
+	ctx.JSON(http.StatusOK, refunds)
+}
+
+func (c *RefundController) ListRefunds(ctx *gin.Context) {
+	refunds, err := c.Service.ListRefunds(ctx.Request.Context(), ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching refunds"})
+		return
+	}
+	ctx.JSON(http.StatusOK, refunds)
+}
+
+func (c *RefundController) SyncRefund(ctx *gin.Context) {
+	refund, err := c.Service.SyncRefund(ctx.Request.Context(), ctx.Param("refund_id"))
+	if errors.Is(err, services.ErrRefundNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Refund not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, refund)
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReturnController serves the customer return request endpoint and the admin return queue.
+type ReturnController struct {
+	Service services.ReturnServiceInterface
+}
+
+func (c *ReturnController) RequestReturn(ctx *gin.Context) {
+	var request services.ReturnRequestInput
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Phone == "" && request.Email == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone or email is required"})
+		return
+	}
+
+	returnRequest, err := c.Service.RequestReturn(ctx.Request.Context(), ctx.Param("order_id"), request)
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, services.ErrReturnNotAllowed):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReturn):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusCreated, returnRequest)
+	}
+}
+
+func (c *ReturnController) ListReturns(ctx *gin.Context) {
+	returns, err := c.Service.ListReturns(ctx.Request.Context(), ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching returns"})
+		return
+	}
+	ctx.JSON(http.StatusOK, returns)
+}
+
+func (c *ReturnController) ApproveReturn(ctx *gin.Context) {
+	c.resolve(ctx, c.Service.ApproveReturn)
+}
+
+func (c *ReturnController) RejectReturn(ctx *gin.Context) {
+	c.resolve(ctx, c.Service.RejectReturn)
+}
+
+func (c *ReturnController) resolve(ctx *gin.Context, resolve func(context.Context, string, services.ReturnDecision) (*models.ReturnRequest, error)) {
+	var decision services.ReturnDecision
+	if err := ctx.ShouldBindJSON(&decision); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	returnRequest, err := resolve(ctx.Request.Context(), ctx.Param("return_id"), decision)
+	switch {
+	case errors.Is(err, services.ErrReturnNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Return request not found"})
+	case errors.Is(err, services.ErrReturnNotPending):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil && returnRequest != nil:
+		// Approved, but the refund still has to be issued.
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "return": returnRequest})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, returnRequest)
+	}
+}
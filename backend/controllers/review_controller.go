@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewController serves product reviews and the admin moderation queue.
+type ReviewController struct {
+	Service services.ReviewServiceInterface
+}
+
+func (c *ReviewController) SubmitReview(ctx *gin.Context) {
+	var request services.ReviewInput
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.CustomerID = ctx.GetString(middleware.CustomerIDKey)
+	if request.CustomerID == "" && request.Phone == "" && request.Email == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone or email is required"})
+		return
+	}
+
+	review, err := c.Service.SubmitReview(ctx.Request.Context(), ctx.Param("product_id"), request)
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, services.ErrReviewNotAllowed), errors.Is(err, services.ErrDuplicateReview):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReview):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving review"})
+	default:
+		ctx.JSON(http.StatusCreated, review)
+	}
+}
+
+func (c *ReviewController) ListProductReviews(ctx *gin.Context) {
+	var query services.ReviewListQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := c.Service.ListProductReviews(ctx.Request.Context(), ctx.Param("product_id"), query)
+	if errors.Is(err, services.ErrInvalidReviewQuery) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reviews"})
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
+}
+
+func (c *ReviewController) MarkHelpful(ctx *gin.Context) {
+	review, err := c.Service.MarkHelpful(ctx.Request.Context(), ctx.Param("product_id"), ctx.Param("review_id"), ctx.GetString(middleware.CustomerIDKey))
+	switch {
+	case errors.Is(err, services.ErrHelpfulVoteRequired):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReviewNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving vote"})
+	default:
+		ctx.JSON(http.StatusOK, review)
+	}
+}
+
+func (c *ReviewController) ListReviews(ctx *gin.Context) {
+	reviews, err := c.Service.ListReviews(ctx.Request.Context(), ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reviews"})
+		return
+	}
+	ctx.JSON(http.StatusOK, reviews)
+}
+
+func (c *ReviewController) ApproveReview(ctx *gin.Context) {
+	c.moderate(ctx, models.ReviewStatusApproved)
+}
+
+func (c *ReviewController) RejectReview(ctx *gin.Context) {
+	c.moderate(ctx, models.ReviewStatusRejected)
+}
+
+func (c *ReviewController) FlagReview(ctx *gin.Context) {
+	c.moderate(ctx, models.ReviewStatusFlagged)
+}
+
+func (c *ReviewController) moderate(ctx *gin.Context, status string) {
+	var request struct {
+		Note string `json:"note"`
+	}
+	// The note is optional, so an empty body is fine.
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	review, err := c.Service.ModerateReview(ctx.Request.Context(), ctx.Param("review_id"), status, request.Note)
+	switch {
+	case errors.Is(err, services.ErrReviewNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error moderating review"})
+	default:
+		ctx.JSON(http.StatusOK, review)
+	}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShippingController books orders with the courier and receives the shipping provider's tracking webhooks.
+type ShippingController struct {
+	Service services.ShippingServiceInterface
+}
+
+func (c *ShippingController) BookShipment(ctx *gin.Context) {
+	var request services.BookShipmentRequest
+	// Every field is optional with the HTTP provider, so an empty body is fine.
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	order, err := c.Service.BookShipment(ctx.Request.Context(), ctx.Param("order_id"), request)
+	if err != nil {
+		shippingError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, order)
+}
+
+func (c *ShippingController) GetLabel(ctx *gin.Context) {
+	label, err := c.Service.GetLabel(ctx.Request.Context(), ctx.Param("order_id"))
+	if err != nil {
+		shippingError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"label_url": label})
+}
+
+func (c *ShippingController) CancelShipment(ctx *gin.Context) {
+	order, err := c.Service.CancelShipment(ctx.Request.Context(), ctx.Param("order_id"))
+	if err != nil {
+		shippingError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, order)
+}
+
+// HandleWebhook receives tracking updates from the shipping provider.
+func (c *ShippingController) HandleWebhook(ctx *gin.Context) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.Service.HandleWebhook(ctx.Request.Context(), body, ctx.GetHeader("X-Shipping-Signature"))
+	if errors.Is(err, services.ErrInvalidWebhookSignature) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func shippingError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, services.ErrInvalidShipment):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrderNotShippable), errors.Is(err, services.ErrShipmentExists),
+		errors.Is(err, services.ErrNoShipment), errors.Is(err, services.ErrShipmentNotCancellable):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrShippingUnsupported):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCourierFailed):
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating shipment"})
+	}
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StockSubscriptionController serves back in stock subscriptions for out-of-stock products.
+type StockSubscriptionController struct {
+	Service services.StockSubscriptionServiceInterface
+}
+
+func (c *StockSubscriptionController) NotifyMe(ctx *gin.Context) {
+	var request services.NotifyMeRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := c.Service.Subscribe(ctx.Request.Context(), ctx.Param("product_id"), request)
+	switch {
+	case errors.Is(err, services.ErrProductNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, services.ErrProductInStock):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSubscription), errors.Is(err, services.ErrPhoneNotSubscribable):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving subscription"})
+	default:
+		ctx.JSON(http.StatusCreated, subscription)
+	}
+}
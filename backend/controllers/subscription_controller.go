@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubscriptionController serves subscription plans and logged-in customers' subscriptions.
+type SubscriptionController struct {
+	Service services.SubscriptionServiceInterface
+}
+
+func (c *SubscriptionController) CreatePlan(ctx *gin.Context) {
+	var request services.PlanRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	plan, err := c.Service.CreatePlan(ctx.Request.Context(), request)
+	if errors.Is(err, services.ErrInvalidPlan) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating plan"})
+		return
+	}
+	ctx.JSON(http.StatusCreated, plan)
+}
+
+func (c *SubscriptionController) ListPlans(ctx *gin.Context) {
+	plans, err := c.Service.ListPlans(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching plans"})
+		return
+	}
+	ctx.JSON(http.StatusOK, plans)
+}
+
+func (c *SubscriptionController) Subscribe(ctx *gin.Context) {
+	customerID, ok := loggedInCustomer(ctx)
+	if !ok {
+		return
+	}
+	var request services.SubscribeRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := c.Service.Subscribe(ctx.Request.Context(), customerID, request)
+	switch {
+	case errors.Is(err, services.ErrPlanNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+	case errors.Is(err, services.ErrInvalidSubscriber):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating subscription"})
+	default:
+		ctx.JSON(http.StatusCreated, subscription)
+	}
+}
+
+func (c *SubscriptionController) ListSubscriptions(ctx *gin.Context) {
+	customerID, ok := loggedInCustomer(ctx)
+	if !ok {
+		return
+	}
+	subscriptions, err := c.Service.ListSubscriptions(ctx.Request.Context(), customerID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching subscriptions"})
+		return
+	}
+	ctx.JSON(http.StatusOK, subscriptions)
+}
+
+func (c *SubscriptionController) PauseSubscription(ctx *gin.Context) {
+	c.change(ctx, c.Service.PauseSubscription)
+}
+
+func (c *SubscriptionController) ResumeSubscription(ctx *gin.Context) {
+	c.change(ctx, c.Service.ResumeSubscription)
+}
+
+func (c *SubscriptionController) SkipCycle(ctx *gin.Context) {
+	c.change(ctx, c.Service.SkipCycle)
+}
+
+func (c *SubscriptionController) CancelSubscription(ctx *gin.Context) {
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	// The reason is optional, so an empty body is fine.
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.change(ctx, func(requestCtx context.Context, customerID string, id string) (*models.Subscription, error) {
+		return c.Service.CancelSubscription(requestCtx, customerID, id, request.Reason)
+	})
+}
+
+// change applies a lifecycle change to one of the logged-in customer's subscriptions.
+func (c *SubscriptionController) change(ctx *gin.Context, apply func(ctx context.Context, customerID string, id string) (*models.Subscription, error)) {
+	customerID, ok := loggedInCustomer(ctx)
+	if !ok {
+		return
+	}
+	subscription, err := apply(ctx.Request.Context(), customerID, ctx.Param("subscription_id"))
+	switch {
+	case errors.Is(err, services.ErrSubscriptionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+	case errors.Is(err, services.ErrSubscriptionNotActive):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating subscription"})
+	default:
+		ctx.JSON(http.StatusOK, subscription)
+	}
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WishlistController serves logged-in customers' wishlists and the admin most-wishlisted report.
+type WishlistController struct {
+	Service services.WishlistServiceInterface
+}
+
+func (c *WishlistController) GetWishlist(ctx *gin.Context) {
+	customerID, ok := loggedInCustomer(ctx)
+	if !ok {
+		return
+	}
+	entries, err := c.Service.GetWishlist(ctx.Request.Context(), customerID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching wishlist"})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func (c *WishlistController) SaveToWishlist(ctx *gin.Context) {
+	customerID, ok := loggedInCustomer(ctx)
+	if !ok {
+		return
+	}
+	var request struct {
+		NotifyRestock bool `json:"notify_restock"`
+	}
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	entry, err := c.Service.SaveToWishlist(ctx.Request.Context(), customerID, ctx.Param("product_id"), request.NotifyRestock)
+	if errors.Is(err, services.ErrProductNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving wishlist"})
+		return
+	}
+	ctx.JSON(http.StatusOK, entry)
+}
+
+func (c *WishlistController) RemoveFromWishlist(ctx *gin.Context) {
+	customerID, ok := loggedInCustomer(ctx)
+	if !ok {
+		return
+	}
+	err := c.Service.RemoveFromWishlist(ctx.Request.Context(), customerID, ctx.Param("product_id"))
+	if errors.Is(err, services.ErrWishlistItemNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving wishlist"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Removed from wishlist"})
+}
+
+func (c *WishlistController) MostWishlisted(ctx *gin.Context) {
+	limit := 0
+	if raw := ctx.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number"})
+			return
+		}
+		limit = parsed
+	}
+	rows, err := c.Service.MostWishlisted(ctx.Request.Context(), limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error building report"})
+		return
+	}
+	ctx.JSON(http.StatusOK, rows)
+}
+
+// loggedInCustomer returns the customer the request is authenticated as, or responds 401 for guests.
+func loggedInCustomer(ctx *gin.Context) (string, bool) {
+	customerID := ctx.GetString(middleware.CustomerIDKey)
+	if customerID == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return "", false
+	}
+	return customerID, true
+}
@@ -3,9 +3,11 @@ package database
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"os"
 
+	"mangal-chai-backend/logging"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -14,7 +16,9 @@ var (
 	client *mongo.Client
 )
 
-func Connect() *mongo.Database {
+// Connect connects to MONGO_URL and checks the server answers. opts are applied on top of the defaults, e.g.
+// to add a command monitor.
+func Connect(ctx context.Context, opts ...*options.ClientOptions) (*mongo.Database, error) {
 	mongoURL := os.Getenv("MONGO_URL")
 	if mongoURL == "" {
 		mongoURL = "mongodb://localhost:27017"
@@ -28,19 +32,19 @@ func Connect() *mongo.Database {
 	clientOptions.SetServerAPIOptions(serverAPI)
 
 	var err error
-	client, err = mongo.Connect(context.TODO(), clientOptions)
+	client, err = mongo.Connect(ctx, append([]*options.ClientOptions{clientOptions}, opts...)...)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("connecting to MongoDB: %w", err)
 	}
 
 	// Ping the database to verify connection
-	err = client.Ping(context.TODO(), nil)
+	err = client.Ping(ctx, nil)
 	if err != nil {
-		log.Fatal("Failed to ping MongoDB: ", err)
+		return nil, fmt.Errorf("pinging MongoDB: %w", err)
 	}
 
-	log.Println("Successfully connected to MongoDB!")
-	return client.Database("mangal_chai_db")
+	logging.FromContext(ctx).Info("Connected to MongoDB")
+	return client.Database("mangal_chai_db"), nil
 }
 
 func Disconnect() {
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"mangal-chai-backend/logging"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationsCollection records every migration that has been applied to the database.
+const MigrationsCollection = "schema_migrations"
+
+// ErrSchemaAhead is returned when the database has migrations applied that this binary does not know about,
+// which usually means an older build is being started against a newer database.
+var ErrSchemaAhead = errors.New("database schema is ahead of this binary")
+
+// Migration is a single, versioned change to the database. Up must be safe to re-run, because two instances
+// starting at the same time may both apply it before either records it.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db *mongo.Database) error
+}
+
+// AppliedMigration is the record stored in MigrationsCollection once a migration has run.
+type AppliedMigration struct {
+	Version     int       `json:"version" bson:"version"`
+	Description string    `json:"description" bson:"description"`
+	AppliedAt   time.Time `json:"applied_at" bson:"applied_at"`
+}
+
+type Migrator struct {
+	DB         *mongo.Database
+	Migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the migrations registered in Migrations.
+func NewMigrator(db *mongo.Database) *Migrator {
+	return &Migrator{DB: db, Migrations: Migrations}
+}
+
+// Applied returns the applied migrations ordered by version.
+func (m *Migrator) Applied(ctx context.Context) ([]AppliedMigration, error) {
+	var applied []AppliedMigration
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}})
+	cursor, err := m.DB.Collection(MigrationsCollection).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &applied); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// Pending returns the known migrations that have not been applied yet, ordered by version. It returns
+// ErrSchemaAhead if the database has a migration applied that is newer than any this binary knows about.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := m.sorted()
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+		if len(known) == 0 || a.Version > known[len(known)-1].Version {
+			return nil, fmt.Errorf("%w: migration %d (%s) is applied but unknown", ErrSchemaAhead, a.Version, a.Description)
+		}
+	}
+
+	var pending []Migration
+	for _, migration := range known {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in version order and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, migration := range pending {
+		logging.FromContext(ctx).Info("Applying migration", "version", migration.Version, "description", migration.Description)
+		if err := migration.Up(m.DB); err != nil {
+			return i, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		record := AppliedMigration{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now()}
+		_, err := m.DB.Collection(MigrationsCollection).InsertOne(ctx, record)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return i, fmt.Errorf("recording migration %d: %w", migration.Version, err)
+		}
+	}
+	return len(pending), nil
+}
+
+func (m *Migrator) sorted() []Migration {
+	known := make([]Migration, len(m.Migrations))
+	copy(known, m.Migrations)
+	sort.Slice(known, func(i, j int) bool { return known[i].Version < known[j].Version })
+	return known
+}
+
+// CreateIndexes returns a migration step that creates the given indexes on a collection. Creating an index
+// that already exists with the same options is a no-op, so the step is safe to re-run.
+func CreateIndexes(collection string, indexes ...mongo.IndexModel) func(db *mongo.Database) error {
+	return func(db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateMany(context.TODO(), indexes)
+		return err
+	}
+}
+
+// RewriteDocuments returns a migration step that passes every document matching filter through rewrite and
+// replaces it with the result. Returning nil from rewrite leaves the document untouched.
+func RewriteDocuments(collection string, filter bson.M, rewrite func(doc bson.M) (bson.M, error)) func(db *mongo.Database) error {
+	return func(db *mongo.Database) error {
+		coll := db.Collection(collection)
+		cursor, err := coll.Find(context.TODO(), filter)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(context.TODO())
+
+		for cursor.Next(context.TODO()) {
+			var doc bson.M
+			if err := cursor.Decode(&doc); err != nil {
+				return err
+			}
+			updated, err := rewrite(doc)
+			if err != nil {
+				return err
+			}
+			if updated == nil {
+				continue
+			}
+			if _, err := coll.ReplaceOne(context.TODO(), bson.M{"_id": doc["_id"]}, updated); err != nil {
+				return err
+			}
+		}
+		return cursor.Err()
+	}
+}
@@ -0,0 +1,359 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrations is the ordered list of schema changes for the application. Append new migrations with the next
+// version number; never edit or renumber one that has been released.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "unique version index on schema_migrations",
+		Up: CreateIndexes(MigrationsCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     2,
+		Description: "product id and category indexes",
+		Up: CreateIndexes("products",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "category", Value: 1}}},
+		),
+	},
+	{
+		Version:     3,
+		Description: "order id index",
+		Up: CreateIndexes("orders", mongo.IndexModel{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     4,
+		Description: "backfill product stock",
+		Up:          RewriteDocuments("products", bson.M{"stock": bson.M{"$exists": false}}, backfillProductStock),
+	},
+	{
+		Version:     5,
+		Description: "refund indexes",
+		Up: CreateIndexes("refunds",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "order_id", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "gateway_refund_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		),
+	},
+	{
+		Version:     6,
+		Description: "return request indexes",
+		Up: CreateIndexes("returns",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "order_id", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		),
+	},
+	{
+		Version:     7,
+		Description: "order payment gateway order index",
+		Up: CreateIndexes("orders", mongo.IndexModel{
+			Keys:    bson.D{{Key: "payment_gateway_order_id", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		}),
+	},
+	{
+		Version:     8,
+		Description: "order list filter indexes",
+		Up: CreateIndexes("orders",
+			mongo.IndexModel{Keys: bson.D{{Key: "order_date", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "order_date", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "items.product_id", Value: 1}}},
+		),
+	},
+	{
+		Version:     9,
+		Description: "notification outbox indexes",
+		Up: CreateIndexes("notifications",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "channel", Value: 1}, {Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "order_id", Value: 1}}},
+		),
+	},
+	{
+		Version:     10,
+		Description: "messaging preference index",
+		Up: CreateIndexes("messaging_preferences", mongo.IndexModel{
+			Keys:    bson.D{{Key: "phone", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     11,
+		Description: "OTP indexes with expiry",
+		Up: CreateIndexes("otps",
+			mongo.IndexModel{Keys: bson.D{{Key: "phone", Value: 1}, {Key: "purpose", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		),
+	},
+	{
+		Version:     12,
+		Description: "order message provider id index",
+		Up: CreateIndexes("orders", mongo.IndexModel{
+			Keys:    bson.D{{Key: "messages.provider_message_id", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		}),
+	},
+	{
+		Version:     13,
+		Description: "job queue indexes with expiry of finished jobs",
+		Up: CreateIndexes("jobs",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "completed_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(finishedJobRetention)},
+		),
+	},
+	{
+		Version:     14,
+		Description: "dead job index",
+		Up: CreateIndexes("dead_jobs", mongo.IndexModel{
+			Keys:    bson.D{{Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     15,
+		Description: "order outbox index",
+		Up: CreateIndexes("orders", mongo.IndexModel{
+			Keys:    bson.D{{Key: "outbox.id", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		}),
+	},
+	{
+		Version:     16,
+		Description: "cart indexes with expiry",
+		Up: CreateIndexes("carts",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "customer_id", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		),
+	},
+	{
+		Version:     17,
+		Description: "customer indexes",
+		Up: CreateIndexes("customers",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "phone", Value: 1}}, Options: options.Index().SetUnique(true)},
+		),
+	},
+	{
+		Version:     18,
+		Description: "coupon indexes",
+		Up: CreateIndexes("coupons",
+			mongo.IndexModel{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}}},
+		),
+	},
+	{
+		Version:     19,
+		Description: "cart reminder indexes",
+		Up: CreateIndexes("cart_reminders",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "sent_at", Value: 1}}},
+		),
+	},
+	{
+		Version:     20,
+		Description: "abandoned cart lookup index",
+		Up: CreateIndexes("carts", mongo.IndexModel{
+			Keys: bson.D{{Key: "updated_at", Value: 1}},
+		}),
+	},
+	{
+		Version:     21,
+		Description: "review indexes",
+		Up: CreateIndexes("reviews",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "order_id", Value: 1}, {Key: "product_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "status", Value: 1}, {Key: "helpful_count", Value: -1}, {Key: "created_at", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		),
+	},
+	{
+		Version:     22,
+		Description: "wishlist indexes",
+		Up: CreateIndexes("wishlists",
+			mongo.IndexModel{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "product_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "notify_restock", Value: 1}, {Key: "added_at", Value: 1}}},
+		),
+	},
+	{
+		Version:     23,
+		Description: "back in stock subscription indexes",
+		Up: CreateIndexes("stock_subscriptions",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "email", Value: 1}, {Key: "phone", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		),
+	},
+	{
+		Version:     24,
+		Description: "subscription plan indexes",
+		Up: CreateIndexes("subscription_plans",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		),
+	},
+	{
+		Version:     25,
+		Description: "subscription indexes",
+		Up: CreateIndexes("subscriptions",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "gateway_subscription_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_order_at", Value: 1}}},
+		),
+	},
+	{
+		Version:     26,
+		Description: "subscription order lookup index",
+		Up: CreateIndexes("orders", mongo.IndexModel{
+			Keys:    bson.D{{Key: "subscription_id", Value: 1}, {Key: "status", Value: 1}, {Key: "order_date", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		}),
+	},
+	{
+		Version:     27,
+		Description: "gift card indexes",
+		Up: CreateIndexes("gift_cards",
+			mongo.IndexModel{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+			// One card per purchased unit, so issuing an order's cards again is a no-op.
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "order_id", Value: 1}, {Key: "order_line", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"order_id": bson.M{"$exists": true}}),
+			},
+		),
+	},
+	{
+		Version:     28,
+		Description: "wallet customer index",
+		Up: CreateIndexes("wallets", mongo.IndexModel{
+			Keys:    bson.D{{Key: "customer_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+	},
+	{
+		Version:     29,
+		Description: "ledger account index",
+		Up: CreateIndexes("ledger",
+			mongo.IndexModel{Keys: bson.D{{Key: "account", Value: 1}, {Key: "account_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		),
+	},
+	{
+		Version:     30,
+		Description: "loyalty points indexes",
+		Up: CreateIndexes("loyalty_points",
+			mongo.IndexModel{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "expires_at", Value: 1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "expires_at", Value: 1}, {Key: "remaining", Value: 1}}},
+			// An order earns points once.
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "order_id", Value: 1}, {Key: "kind", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"kind": "earned"}),
+			},
+		),
+	},
+	{
+		Version:     31,
+		Description: "invoice order and number indexes",
+		Up: CreateIndexes("invoices",
+			mongo.IndexModel{Keys: bson.D{{Key: "order_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "number", Value: 1}}, Options: options.Index().SetUnique(true)},
+		),
+	},
+	{
+		Version:     32,
+		Description: "order shipment tracking indexes",
+		Up: CreateIndexes("orders",
+			mongo.IndexModel{
+				Keys:    bson.D{{Key: "shipment.tracking_number", Value: 1}},
+				Options: options.Index().SetPartialFilterExpression(bson.M{"shipment.tracking_number": bson.M{"$exists": true}}),
+			},
+			mongo.IndexModel{Keys: bson.D{{Key: "shipment.provider", Value: 1}, {Key: "status", Value: 1}, {Key: "shipment.tracked_at", Value: 1}}},
+		),
+	},
+	{
+		Version:     33,
+		Description: "rate limit bucket expiry",
+		Up: CreateIndexes("rate_limits", mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}),
+	},
+	{
+		Version:     34,
+		Description: "order refunded amounts",
+		Up:          backfillRefundedAmounts,
+	},
+	{
+		Version:     35,
+		Description: "order gateway order lists",
+		Up: func(db *mongo.Database) error {
+			if err := backfillGatewayOrderLists(db); err != nil {
+				return err
+			}
+			return CreateIndexes("orders", mongo.IndexModel{
+				Keys:    bson.D{{Key: "payment_gateway_order_ids", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			})(db)
+		},
+	},
+}
+
+// finishedJobRetention is how long, in seconds, succeeded jobs are kept for inspection before Mongo
+// removes them.
+const finishedJobRetention = 7 * 24 * 60 * 60
+
+// backfillStock is the stock given to in-stock products that predate stock tracking. Correct it with a
+// catalog import once real counts are known.
+const backfillStock = 100
+
+func backfillProductStock(doc bson.M) (bson.M, error) {
+	doc["stock"] = 0
+	if inStock, _ := doc["in_stock"].(bool); inStock {
+		doc["stock"] = backfillStock
+	}
+	return doc, nil
+}
+
+// backfillRefundedAmounts records on each order the total of its refunds that have not failed, which refunds
+// now reserve against before they are issued.
+// backfillGatewayOrderLists starts the gateway order list of orders that predate it with their one gateway
+// order.
+func backfillGatewayOrderLists(db *mongo.Database) error {
+	filter := bson.M{"payment_gateway_order_id": bson.M{"$exists": true}, "payment_gateway_order_ids": bson.M{"$exists": false}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"payment_gateway_order_ids": bson.A{"$payment_gateway_order_id"}}}}}
+	_, err := db.Collection("orders").UpdateMany(context.TODO(), filter, update)
+	return err
+}
+
+func backfillRefundedAmounts(db *mongo.Database) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$ne": "failed"}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$order_id", "refunded_amount": bson.M{"$sum": "$amount"}}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "id": "$_id", "refunded_amount": 1}}},
+		{{Key: "$merge", Value: bson.M{"into": "orders", "on": "id", "whenMatched": "merge", "whenNotMatched": "discard"}}},
+	}
+	cursor, err := db.Collection("refunds").Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.Close(context.TODO())
+}
@@ -0,0 +1,37 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"mangal-chai-backend/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// MongoCheck pings the primary of db's deployment.
+func MongoCheck(db *mongo.Database) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		return nil, db.Client().Ping(ctx, readpref.Primary())
+	}
+}
+
+// MigrationsCheck fails while migrations are pending, as handlers may rely on indexes or document shapes
+// they add, or when the database is ahead of this binary.
+func MigrationsCheck(migrator *database.Migrator) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) > 0 {
+			versions := make([]int, len(pending))
+			for i, migration := range pending {
+				versions[i] = migration.Version
+			}
+			return map[string]any{"pending": versions}, fmt.Errorf("%d migrations pending", len(pending))
+		}
+		return nil, nil
+	}
+}
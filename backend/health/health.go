@@ -0,0 +1,215 @@
+// Package health checks the server's dependencies for the readiness probe: MongoDB, the schema, the payment
+// gateway configuration and the background workers.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Component statuses, and the overall status of a Report.
+const (
+	StatusOK          = "ok"
+	StatusFailing     = "failing"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+// DefaultTimeout bounds each check, so a hung dependency fails the probe instead of hanging it.
+const DefaultTimeout = 2 * time.Second
+
+// CheckFunc checks one dependency. It returns details to report either way, and an error when the
+// dependency is unusable.
+type CheckFunc func(ctx context.Context) (any, error)
+
+// Component is a dependency to check. The server is not ready while a critical component is failing; other
+// failing components only mark it degraded.
+type Component struct {
+	Name     string
+	Critical bool
+	Check    CheckFunc
+}
+
+// ComponentReport is the outcome of checking one component.
+type ComponentReport struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	Error     string  `json:"error,omitempty"`
+	Details   any     `json:"details,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Report is the outcome of checking every component.
+type Report struct {
+	Status     string                     `json:"status"`
+	CheckedAt  time.Time                  `json:"checked_at"`
+	Components map[string]ComponentReport `json:"components"`
+}
+
+// Ready reports whether every critical component is healthy.
+func (r Report) Ready() bool {
+	return r.Status != StatusUnavailable
+}
+
+// WithoutDetails is the report with each component's status but not its error or details, which can name
+// hosts and configuration.
+func (r Report) WithoutDetails() Report {
+	components := make(map[string]ComponentReport, len(r.Components))
+	for name, component := range r.Components {
+		component.Error, component.Details = "", nil
+		components[name] = component
+	}
+	r.Components = components
+	return r
+}
+
+// Checker checks its components concurrently, each within Timeout (DefaultTimeout when zero). With CacheFor
+// set, a report is reused for that long, so frequent probes do not each ping every dependency.
+type Checker struct {
+	Components []Component
+	Timeout    time.Duration
+	CacheFor   time.Duration
+
+	mu     sync.Mutex
+	cached Report
+}
+
+// Check returns the latest report, checking the components again if it is older than CacheFor. Callers
+// arriving while a check runs wait for it rather than starting their own. A cached report is shared, so it
+// is checked apart from the caller's context: a probe that gives up early does not cache a failure, and the
+// wait is bounded by the check's own timeout.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.CacheFor <= 0 {
+		return c.run(ctx)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached.CheckedAt.IsZero() || time.Since(c.cached.CheckedAt) >= c.CacheFor {
+		c.cached = c.run(context.WithoutCancel(ctx))
+	}
+	return c.cached
+}
+
+func (c *Checker) run(ctx context.Context) Report {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	report := Report{Status: StatusOK, CheckedAt: time.Now(), Components: make(map[string]ComponentReport, len(c.Components))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, component := range c.Components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := check(ctx, component, timeout)
+			mu.Lock()
+			report.Components[component.Name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Components {
+		if result.Status == StatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusUnavailable
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// check runs one component's check. Checks that do not stop when their context is done are abandoned once
+// the timeout passes rather than waited for.
+func check(ctx context.Context, component Component, timeout time.Duration) ComponentReport {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		details any
+		err     error
+	}
+	start := time.Now()
+	done := make(chan outcome, 1)
+	go func() {
+		details, err := component.Check(ctx)
+		done <- outcome{details, err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result.err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	report := ComponentReport{
+		Status:    StatusOK,
+		Critical:  component.Critical,
+		Details:   result.details,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if result.err != nil {
+		report.Status = StatusFailing
+		report.Error = result.err.Error()
+	}
+	return report
+}
+
+// Heartbeats records when each background worker last polled for work. Workers block while they work, so a
+// worker that has not polled for a while is stuck or has stopped.
+type Heartbeats struct {
+	mu    sync.Mutex
+	beats map[string]time.Time
+}
+
+// Beat records that worker is alive.
+func (h *Heartbeats) Beat(worker string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.beats == nil {
+		h.beats = make(map[string]time.Time)
+	}
+	h.beats[worker] = time.Now()
+}
+
+// WorkerStatus is a worker's last heartbeat.
+type WorkerStatus struct {
+	LastBeat   time.Time `json:"last_beat"`
+	AgeSeconds float64   `json:"age_seconds"`
+	Stale      bool      `json:"stale"`
+}
+
+// Check returns a CheckFunc failing when a worker has not beaten within maxAge, or none has beaten at all.
+func (h *Heartbeats) Check(maxAge time.Duration) CheckFunc {
+	return func(ctx context.Context) (any, error) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if len(h.beats) == 0 {
+			return nil, fmt.Errorf("no background worker has started")
+		}
+		workers := make(map[string]WorkerStatus, len(h.beats))
+		var stale []string
+		for worker, beat := range h.beats {
+			age := time.Since(beat)
+			workers[worker] = WorkerStatus{LastBeat: beat, AgeSeconds: age.Seconds(), Stale: age > maxAge}
+			if age > maxAge {
+				stale = append(stale, worker)
+			}
+		}
+		if len(stale) > 0 {
+			sort.Strings(stale)
+			return workers, fmt.Errorf("no heartbeat for over %s from %v", maxAge, stale)
+		}
+		return workers, nil
+	}
+}
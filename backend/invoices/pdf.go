@@ -0,0 +1,183 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// shopLocation is the time zone invoice dates are printed in.
+var shopLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// Column widths of the line items table, in millimetres; together they fill an A4 page inside its margins.
+var columns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"#", 7, "C"},
+	{"Description", 50, "L"},
+	{"HSN", 15, "C"},
+	{"Qty", 10, "R"},
+	{"Rate", 18, "R"},
+	{"Discount", 17, "R"},
+	{"Taxable", 21, "R"},
+	{"GST %", 12, "R"},
+	{"Tax", 20, "R"},
+	{"Total", 20, "R"},
+}
+
+// Renderer lays out invoices as A4 PDFs. The built-in PDF fonts have no rupee sign, so amounts are printed
+// as "Rs.".
+type Renderer struct{}
+
+// Render lays out an invoice. The PDF is dated when the invoice was issued, however late it is rendered.
+func (r *Renderer) Render(invoice models.Invoice) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetCreationDate(invoice.IssuedAt)
+	pdf.SetTitle("Tax Invoice "+invoice.Number, true)
+	pdf.SetAuthor(invoice.Seller.Name, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "TAX INVOICE", "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	// Seller on the left, invoice details on the right.
+	top := pdf.GetY()
+	party(pdf, tr, "", invoice.Seller)
+	sellerBottom := pdf.GetY()
+	pdf.SetXY(130, top)
+	details := [][2]string{
+		{"Invoice No.", invoice.Number},
+		{"Invoice Date", invoice.IssuedAt.In(shopLocation).Format("02 Jan 2006")},
+		{"Order", invoice.OrderID},
+		{"Place of Supply", fmt.Sprintf("%s (%s)", invoice.Buyer.State, invoice.PlaceOfSupply)},
+		{"Reverse Charge", "No"},
+	}
+	for _, detail := range details {
+		pdf.SetX(130)
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(28, 5, detail[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(0, 5, tr(detail[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.SetY(max(sellerBottom, pdf.GetY()) + 4)
+
+	party(pdf, tr, "Bill To", invoice.Buyer)
+	pdf.Ln(4)
+
+	// Line items.
+	taxTitle := "CGST+SGST"
+	if invoice.InterState {
+		taxTitle = "IGST"
+	}
+	pdf.SetFont("Helvetica", "B", 8)
+	pdf.SetFillColor(235, 235, 235)
+	for _, column := range columns {
+		title := column.title
+		if title == "Tax" {
+			title = taxTitle
+		}
+		pdf.CellFormat(column.width, 7, title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 8)
+	for i, line := range invoice.Lines {
+		values := []string{
+			fmt.Sprintf("%d", i+1),
+			fit(pdf, tr(line.Description), columns[1].width-2),
+			line.HSN,
+			fmt.Sprintf("%d", line.Quantity),
+			money(line.UnitPrice),
+			money(line.Discount),
+			money(line.TaxableValue),
+			fmt.Sprintf("%g", line.GSTRate),
+			money(line.CGST + line.SGST + line.IGST),
+			money(line.Total),
+		}
+		for c, column := range columns {
+			pdf.CellFormat(column.width, 6, values[c], "1", 0, column.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(3)
+
+	// Totals.
+	totals := [][2]string{{"Taxable Value", money(invoice.TaxableValue)}}
+	if invoice.InterState {
+		totals = append(totals, [2]string{"IGST", money(invoice.IGST)})
+	} else {
+		totals = append(totals, [2]string{"CGST", money(invoice.CGST)}, [2]string{"SGST", money(invoice.SGST)})
+	}
+	totals = append(totals, [2]string{"Invoice Total", "Rs. " + money(invoice.Total)})
+	for i, total := range totals {
+		style := ""
+		if i == len(totals)-1 {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.SetX(130)
+		pdf.CellFormat(35, 6, total[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, total[1], "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(3)
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(32, 5, "Amount in words:", "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.MultiCell(0, 5, AmountInWords(invoice.Total), "", "L", false)
+	pdf.Ln(8)
+
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.MultiCell(0, 4, "Prices are inclusive of GST. This is a computer-generated invoice and needs no signature.", "", "L", false)
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// party prints the name, address, GSTIN and state of a seller or buyer, under a heading if one is given.
+func party(pdf *fpdf.Fpdf, tr func(string) string, heading string, p models.InvoiceParty) {
+	if heading != "" {
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(110, 5, heading, "", 1, "L", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(110, 6, tr(p.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	if p.Address != "" {
+		pdf.MultiCell(110, 4.5, tr(p.Address), "", "L", false)
+	}
+	if p.GSTIN != "" {
+		pdf.CellFormat(110, 5, "GSTIN: "+p.GSTIN, "", 1, "L", false, 0, "")
+	}
+	if p.State != "" {
+		pdf.CellFormat(110, 5, fmt.Sprintf("State: %s (%s)", p.State, p.StateCode), "", 1, "L", false, 0, "")
+	}
+}
+
+// fit shortens text with an ellipsis until it is no wider than width. The text is already translated to
+// the font's single-byte code page, so it is cut byte by byte.
+func fit(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	for len(text) > 0 && pdf.GetStringWidth(text+"...") > width {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
+
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
@@ -0,0 +1,76 @@
+// Package invoices lays out GST tax invoices as PDFs and stores them.
+package invoices
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store keeps invoice PDFs by name. Open returns an error wrapping fs.ErrNotExist for a name it does not
+// have.
+type Store interface {
+	Save(name string, data []byte) error
+	Open(name string) ([]byte, error)
+}
+
+// FileStore keeps invoices as files under Dir on the local filesystem.
+type FileStore struct {
+	Dir string
+}
+
+// Save writes the file in full before putting it in place, so a crash never leaves a half-written invoice.
+func (s *FileStore) Save(name string, data []byte) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".invoice-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) Open(name string) ([]byte, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (s *FileStore) path(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid invoice file name %q", name)
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
+
+// NewStoreFromEnv builds the store selected by INVOICE_STORE: "local" (the default), which keeps invoices
+// under INVOICE_DIR, by default ./storage/invoices.
+func NewStoreFromEnv() (Store, error) {
+	switch store := os.Getenv("INVOICE_STORE"); store {
+	case "", "local":
+		dir := os.Getenv("INVOICE_DIR")
+		if dir == "" {
+			dir = "storage/invoices"
+		}
+		return &FileStore{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown INVOICE_STORE %q, use local", store)
+	}
+}
@@ -0,0 +1,50 @@
+package invoices
+
+import (
+	"math"
+	"strings"
+)
+
+var ones = []string{
+	"", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten",
+	"Eleven", "Twelve", "Thirteen", "Fourteen", "Fifteen", "Sixteen", "Seventeen", "Eighteen", "Nineteen",
+}
+
+var tens = []string{"", "", "Twenty", "Thirty", "Forty", "Fifty", "Sixty", "Seventy", "Eighty", "Ninety"}
+
+// AmountInWords spells out a rupee amount the way Indian invoices do, in lakhs and crores, e.g. 125050.5 is
+// "Indian Rupees One Lakh Twenty Five Thousand Fifty and Fifty Paise Only".
+func AmountInWords(amount float64) string {
+	paise := int64(math.Round(math.Abs(amount) * 100))
+	words := "Indian Rupees " + numberInWords(paise/100)
+	if paise%100 > 0 {
+		words += " and " + numberInWords(paise%100) + " Paise"
+	}
+	return words + " Only"
+}
+
+func numberInWords(n int64) string {
+	if n == 0 {
+		return "Zero"
+	}
+	var parts []string
+	for _, unit := range []struct {
+		size int64
+		name string
+	}{{10000000, "Crore"}, {100000, "Lakh"}, {1000, "Thousand"}, {100, "Hundred"}} {
+		if n >= unit.size {
+			parts = append(parts, numberInWords(n/unit.size), unit.name)
+			n %= unit.size
+		}
+	}
+	switch {
+	case n >= 20:
+		parts = append(parts, tens[n/10])
+		if n%10 > 0 {
+			parts = append(parts, ones[n%10])
+		}
+	case n > 0:
+		parts = append(parts, ones[n])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OrderNotifier is told about order events; it is implemented by the email and messaging notifiers.
+type OrderNotifier interface {
+	NotifyOrder(ctx context.Context, event string, order models.Order)
+}
+
+// OrderEventHandler does work an order event calls for, such as issuing the gift cards bought in a paid
+// order. Unlike a notifier it can fail, and must be safe to run again for the same event.
+type OrderEventHandler interface {
+	HandleOrderEvent(ctx context.Context, event string, order models.Order) error
+}
+
+// NotifyOrderJob is the job that notifies the customer of an order event. An order goes through each event
+// at most once, so the job's ID is made from the two: the jobs of one order, written together, cannot clash,
+// and a job relayed twice is queued once.
+func NotifyOrderJob(event string, orderID string) models.JobRequest {
+	return models.JobRequest{
+		ID:      fmt.Sprintf("job_%s_%s", orderID, event),
+		Type:    models.JobTypeNotifyOrder,
+		Payload: map[string]string{"event": event, "order_id": orderID},
+	}
+}
+
+// NotifyOrderHandler runs notify-order jobs, loading the order as it is now and passing it to handlers and
+// then notifier. A handler's error fails the job before the customer is notified, so it is retried with
+// every handler run again.
+func NotifyOrderHandler(orders repositories.OrderRepositoryInterface, notifier OrderNotifier, handlers ...OrderEventHandler) Handler {
+	return func(ctx context.Context, job models.Job) error {
+		order, err := orders.GetOrder(ctx, job.Payload["order_id"])
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return Permanent(fmt.Errorf("order %s not found", job.Payload["order_id"]))
+		}
+		if err != nil {
+			return err
+		}
+		for _, handler := range handlers {
+			if err := handler.HandleOrderEvent(ctx, job.Payload["event"], *order); err != nil {
+				return err
+			}
+		}
+		notifier.NotifyOrder(ctx, job.Payload["event"], *order)
+		return nil
+	}
+}
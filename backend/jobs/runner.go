@@ -0,0 +1,244 @@
+// Package jobs runs background work from a Mongo-backed queue: jobs written to an order's outbox together
+// with the order, jobs enqueued directly, delayed jobs and periodic jobs.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultWorkers      = 4
+	defaultPollInterval = 2 * time.Second
+	defaultMaxAttempts  = 10
+	// jobLease is how long a worker holds a job; a job still running after that is handed to another worker.
+	jobLease     = 5 * time.Minute
+	relayBatch   = 100
+	firstBackoff = 30 * time.Second
+	maxBackoff   = time.Hour
+)
+
+// Handler runs one job. Returning an error retries the job with backoff; wrap the error with Permanent to
+// dead-letter the job straight away instead.
+type Handler func(ctx context.Context, job models.Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a job failure that retrying cannot fix.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+type schedule struct {
+	jobType  string
+	interval time.Duration
+}
+
+// Runner is the worker pool. Register handlers with Handle and periodic jobs with Every before calling Run.
+type Runner struct {
+	Jobs         repositories.JobRepositoryInterface
+	Orders       repositories.OrderRepositoryInterface
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	// Heartbeat, if set, is called with the worker's name ("jobs.relay", or "jobs.worker.1" and on for the
+	// job workers) each time a worker polls for work, so a stuck worker is not hidden by the others.
+	Heartbeat func(worker string)
+
+	handlers  map[string]Handler
+	schedules []schedule
+}
+
+func (r *Runner) Handle(jobType string, handler Handler) {
+	if r.handlers == nil {
+		r.handlers = make(map[string]Handler)
+	}
+	r.handlers[jobType] = handler
+}
+
+// Every runs a job of jobType once per interval. Each run gets an ID derived from its time slot, so any
+// number of server instances enqueue it only once.
+func (r *Runner) Every(jobType string, interval time.Duration) {
+	r.schedules = append(r.schedules, schedule{jobType: jobType, interval: interval})
+}
+
+// Run relays outboxes, enqueues periodic jobs and runs jobs on Workers goroutines until ctx is cancelled.
+func (r *Runner) Run(ctx context.Context) {
+	workers := r.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers + 1)
+	go func() {
+		defer wg.Done()
+		r.loop(ctx, "jobs.relay", func() bool {
+			if _, err := r.RelayOutbox(ctx); err != nil {
+				logging.FromContext(ctx).Error("Outbox relay failed", "error", err)
+			}
+			if err := r.EnqueueScheduled(ctx, time.Now()); err != nil {
+				logging.FromContext(ctx).Error("Scheduling periodic jobs failed", "error", err)
+			}
+			return false
+		})
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			r.loop(ctx, fmt.Sprintf("jobs.worker.%d", i+1), func() bool {
+				ran, err := r.RunNext(ctx)
+				if err != nil {
+					logging.FromContext(ctx).Error("Job runner failed", "error", err)
+				}
+				return ran
+			})
+		}()
+	}
+	wg.Wait()
+}
+
+// loop calls step until ctx is cancelled, waiting PollInterval whenever step reports it had nothing to do.
+func (r *Runner) loop(ctx context.Context, worker string, step func() bool) {
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	for {
+		if r.Heartbeat != nil {
+			r.Heartbeat(worker)
+		}
+		busy := step()
+		if busy {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RelayOutbox moves jobs from order outboxes to the queue and returns how many were relayed.
+func (r *Runner) RelayOutbox(ctx context.Context) (int, error) {
+	if r.Orders == nil {
+		return 0, nil
+	}
+	orders, err := r.Orders.PendingOutbox(ctx, relayBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	relayed := 0
+	for _, order := range orders {
+		ids := make([]string, 0, len(order.Outbox))
+		for _, request := range order.Outbox {
+			if err := r.Jobs.Enqueue(ctx, request); err != nil {
+				return relayed, err
+			}
+			ids = append(ids, request.ID)
+		}
+		if err := r.Orders.ClearOutbox(ctx, order.ID, ids); err != nil {
+			return relayed, err
+		}
+		relayed += len(ids)
+	}
+	return relayed, nil
+}
+
+// EnqueueScheduled enqueues the current run of every periodic job.
+func (r *Runner) EnqueueScheduled(ctx context.Context, now time.Time) error {
+	for _, s := range r.schedules {
+		slot := now.Truncate(s.interval)
+		err := r.Jobs.Enqueue(ctx, models.JobRequest{
+			ID:    fmt.Sprintf("%s@%d", s.jobType, slot.Unix()),
+			Type:  s.jobType,
+			RunAt: slot,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunNext claims and runs the next due job. It reports false when no job was due.
+func (r *Runner) RunNext(ctx context.Context) (bool, error) {
+	job, err := r.Jobs.ClaimNext(ctx, time.Now(), jobLease)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Everything the job logs, down to the repositories, says which job it was.
+	ctx = logging.With(ctx, "job_id", job.ID, "job_type", job.Type)
+	handler, ok := r.handlers[job.Type]
+	if !ok {
+		return true, r.Jobs.Bury(ctx, *job, fmt.Sprintf("no handler for job type %q", job.Type))
+	}
+
+	ctx, span := tracing.Start(ctx, "job "+job.Type, attribute.String("job.id", job.ID), attribute.Int("job.attempt", job.Attempts))
+	runErr := run(ctx, handler, *job)
+	tracing.End(span, runErr)
+	if runErr == nil {
+		return true, r.Jobs.Complete(ctx, job.ID)
+	}
+
+	var permanent permanentError
+	if errors.As(runErr, &permanent) || job.Attempts >= r.maxAttempts() {
+		logging.FromContext(ctx).Error("Job failed, moving it to dead letters", "attempts", job.Attempts, "error", runErr)
+		return true, r.Jobs.Bury(ctx, *job, runErr.Error())
+	}
+	return true, r.Jobs.Retry(ctx, job.ID, time.Now().Add(backoff(job.Attempts)), runErr.Error())
+}
+
+// run calls handler, turning a panic into an error so one bad job cannot take down a worker.
+func run(ctx context.Context, handler Handler, job models.Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (r *Runner) maxAttempts() int {
+	if r.MaxAttempts > 0 {
+		return r.MaxAttempts
+	}
+	return defaultMaxAttempts
+}
+
+// backoff is the delay before retrying after the given number of attempts: 30s, 1m, 2m, ... up to an hour.
+func backoff(attempts int) time.Duration {
+	delay := firstBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
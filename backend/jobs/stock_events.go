@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+)
+
+const (
+	// restockBatch and restockInterval throttle restock alerts: each run of a restock job sends at most
+	// restockBatch alerts per alerter and queues the next run restockInterval later. Batches keep the
+	// messaging providers' rate limits and let the earliest requests get the first chance at short stock.
+	restockBatch    = 50
+	restockInterval = time.Minute
+)
+
+// RestockAlerter sends up to limit alerts for a restocked product, oldest request first, returning how
+// many were sent and whether more are waiting.
+type RestockAlerter interface {
+	AlertRestocked(ctx context.Context, productID string, limit int) (int, bool, error)
+}
+
+// ProductRestockedJob is the job that alerts customers waiting for a product that it is back in stock. The
+// product is part of the job's ID, so the jobs of products restocked by one catalog import cannot clash.
+func ProductRestockedJob(productID string) models.JobRequest {
+	return models.JobRequest{
+		ID:      fmt.Sprintf("job_%s_restocked_%d", productID, time.Now().UnixNano()),
+		Type:    models.JobTypeProductRestocked,
+		Payload: map[string]string{"product_id": productID},
+	}
+}
+
+// StockEvents queues a restock job when a product that had run out gets stock again. It is also used by
+// the admin CLI, so catalog imports reach the server's job runner through the jobs collection.
+type StockEvents struct {
+	Jobs repositories.JobRepositoryInterface
+}
+
+func (e *StockEvents) ProductRestocked(ctx context.Context, productID string) {
+	if err := e.Jobs.Enqueue(ctx, ProductRestockedJob(productID)); err != nil {
+		logging.FromContext(ctx).Error("Failed to queue restock alerts", "product_id", productID, "error", err)
+	}
+}
+
+// RestockHandler runs restock jobs, sending one batch from each alerter and queuing a follow-up job while
+// any has alerts left.
+func RestockHandler(queue repositories.JobRepositoryInterface, alerters ...RestockAlerter) Handler {
+	return func(ctx context.Context, job models.Job) error {
+		productID := job.Payload["product_id"]
+		sent, more := 0, false
+		for _, alerter := range alerters {
+			n, pending, err := alerter.AlertRestocked(ctx, productID, restockBatch)
+			sent += n
+			if err != nil {
+				return err
+			}
+			more = more || pending
+		}
+		if sent > 0 {
+			logging.FromContext(ctx).Info("Sent back in stock alerts", "product_id", productID, "sent", sent)
+		}
+		if !more {
+			return nil
+		}
+		next := ProductRestockedJob(productID)
+		next.RunAt = time.Now().Add(restockInterval)
+		return queue.Enqueue(ctx, next)
+	}
+}
@@ -0,0 +1,73 @@
+// Package logging writes structured JSON logs. A request's logger travels in its context, so services and
+// repositories log with the request ID and the customer and order the request is about.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Redacted replaces the value of attributes that hold a customer's contact details.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never written to the log, alone or as the suffix of a
+// longer key such as "customer_phone".
+var sensitiveKeys = []string{"phone", "email", "address", "recipient"}
+
+type contextKey struct{}
+
+// New returns a logger writing JSON lines to w at level and above, with contact details redacted.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}))
+}
+
+// NewFromEnv returns a logger writing to stdout at LOG_LEVEL (debug, info, warn or error; info by default).
+func NewFromEnv() *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+	return New(os.Stdout, level)
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if Sensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// Sensitive reports whether an attribute with key holds contact details.
+func Sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if key == sensitive || strings.HasSuffix(key, "_"+sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if it has none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger adds args to every record, e.g. With(ctx, "order_id", id).
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
@@ -2,17 +2,38 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"mangal-chai-backend/controllers"
 	"mangal-chai-backend/database"
+	"mangal-chai-backend/health"
+	"mangal-chai-backend/invoices"
+	"mangal-chai-backend/jobs"
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/metrics"
+	"mangal-chai-backend/messaging"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/notifications"
 	"mangal-chai-backend/repositories"
 	"mangal-chai-backend/services"
+	"mangal-chai-backend/shipping"
+	"mangal-chai-backend/tracing"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 func getAllowedOrigins() []string {
@@ -38,62 +59,579 @@ func getAllowedOrigins() []string {
 	}
 }
 
+// expirySweepInterval is how often unpaid orders are checked for expiry, so an order expires at most this
+// long after its payment window ends.
+const expirySweepInterval = 5 * time.Minute
+
+// cartReminderSweepInterval is how often abandoned carts are looked for.
+const cartReminderSweepInterval = 15 * time.Minute
+
+// subscriptionSweepInterval is how often due subscription orders are placed.
+const subscriptionSweepInterval = 15 * time.Minute
+
+// pointsExpirySweepInterval is how often expired loyalty points are cleared from the ledger. Expired points
+// cannot be spent even before the sweep clears them.
+const pointsExpirySweepInterval = time.Hour
+
+// Default rate limits per client IP address. Placing orders and creating gateway orders are held tightest,
+// as each request writes an order or calls Razorpay; order tracking, and every other route that finds an
+// order by its number and the customer's phone or email, shares one limit so order numbers and contact
+// details cannot be guessed by brute force.
+var (
+	defaultAPIRateLimit      = middleware.Limit{Requests: 300, Window: time.Minute}
+	defaultOrderRateLimit    = middleware.Limit{Requests: 10, Window: 10 * time.Minute}
+	defaultPaymentRateLimit  = middleware.Limit{Requests: 20, Window: 10 * time.Minute}
+	defaultTrackingRateLimit = middleware.Limit{Requests: 20, Window: 10 * time.Minute}
+)
+
+// shipmentTrackingSweepInterval is how often shipments are polled for tracking events, in case the shipping
+// provider's webhooks were missed.
+const shipmentTrackingSweepInterval = 30 * time.Minute
+
+// workerHeartbeatMaxAge is how long a background worker can go without polling before the readiness probe
+// reports it stuck. A job worker does not poll while it runs a job, which may take up to the job lease.
+const workerHeartbeatMaxAge = 5 * time.Minute
+
+// readinessCacheFor is how long a readiness report is reused, so frequent probes do not each ping MongoDB.
+const readinessCacheFor = 5 * time.Second
+
+// durationFromEnv reads a Go duration such as "30m" from the environment variable name, falling back to
+// the default when it is unset or invalid.
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		slog.Warn("Invalid duration, using the default", "variable", name, "value", raw, "default", fallback.String())
+		return fallback
+	}
+	return value
+}
+
+// floatFromEnv reads a non-negative number from the environment variable name, or 0 when it is unset or
+// invalid.
+func floatFromEnv(name string) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return 0
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 {
+		slog.Warn("Invalid number, ignoring it", "variable", name, "value", raw)
+		return 0
+	}
+	return value
+}
+
+// limitFromEnv reads a rate limit such as "10/1m" from the environment variable name, or returns fallback
+// when it is unset or invalid.
+func limitFromEnv(name string, fallback middleware.Limit) middleware.Limit {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	limit, err := middleware.ParseLimit(raw)
+	if err != nil {
+		slog.Warn("Invalid rate limit, using the default", "variable", name, "error", err, "default", fmt.Sprintf("%d/%s", fallback.Requests, fallback.Window))
+		return fallback
+	}
+	return limit
+}
+
+// rateLimitStoreFromEnv builds the store selected by RATE_LIMIT_STORE: "mongo" to share counts between
+// server instances, or "memory" (the default).
+func rateLimitStoreFromEnv(db *mongo.Database) (middleware.RateLimitStore, error) {
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		return &middleware.MemoryRateLimitStore{}, nil
+	case "mongo":
+		return &repositories.RateLimitRepository{Collection: db.Collection("rate_limits")}, nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q, use memory or mongo", store)
+	}
+}
+
+// multipliersFromEnv reads per-category multipliers such as "Premium Teas=2,Herbal Teas=1.5" from the
+// environment variable name, skipping invalid entries.
+func multipliersFromEnv(name string) map[string]float64 {
+	multipliers := make(map[string]float64)
+	for _, pair := range strings.Split(os.Getenv(name), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		category, raw, _ := strings.Cut(pair, "=")
+		value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil || value < 0 || strings.TrimSpace(category) == "" {
+			slog.Warn("Invalid multiplier, ignoring it", "variable", name, "entry", pair)
+			continue
+		}
+		multipliers[strings.TrimSpace(category)] = value
+	}
+	return multipliers
+}
+
+// shopURL is the storefront's address, used in links sent to customers.
+func shopURL() string {
+	if url := os.Getenv("SHOP_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:5173"
+}
+
+// sellerName is the shop's legal name printed on tax invoices.
+func sellerName() string {
+	if name := os.Getenv("INVOICE_SELLER_NAME"); name != "" {
+		return name
+	}
+	return "Mangal Chai"
+}
+
+// runMigrations applies pending schema migrations, or only verifies the schema when AUTO_MIGRATE is "false".
+// Either way the server refuses to start against a schema newer than this binary.
+func runMigrations(ctx context.Context, db *mongo.Database) error {
+	migrator := database.NewMigrator(db)
+	if os.Getenv("AUTO_MIGRATE") == "false" {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			slog.Warn("Pending migrations, run `mangal-admin migrate up` to apply them", "pending", len(pending))
+		}
+		return nil
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	if applied > 0 {
+		slog.Info("Applied migrations", "applied", applied)
+	}
+	return nil
+}
+
+// fatal logs why the server cannot start and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func main() {
-	// Database connection
-	db := database.Connect()
+	// Structured JSON logs; the standard logger writes through the same handler.
+	logger := logging.NewFromEnv()
+	slog.SetDefault(logger)
+
+	// Tracing, exported over OTLP or to stdout; see tracing.NewExporterFromEnv.
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal("Invalid tracing configuration", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Database connection, timed for metrics and traced
+	db, err := database.Connect(context.Background(), options.Client().SetMonitor(database.CombineMonitors(metrics.MongoMonitor(), otelmongo.NewMonitor())))
+	if err != nil {
+		fatal("Failed to connect to the database", err)
+	}
 	defer database.Disconnect()
 
+	// Schema migrations
+	if err := runMigrations(context.Background(), db); err != nil {
+		fatal("Schema migrations failed", err)
+	}
+
 	// Repositories
 	productRepository := &repositories.ProductRepository{Collection: db.Collection("products")}
 	orderRepository := &repositories.OrderRepository{Collection: db.Collection("orders")}
+	refundRepository := &repositories.RefundRepository{Collection: db.Collection("refunds")}
+	returnRepository := &repositories.ReturnRepository{Collection: db.Collection("returns")}
+	reviewRepository := &repositories.ReviewRepository{Collection: db.Collection("reviews")}
+	notificationRepository := &repositories.NotificationRepository{Collection: db.Collection("notifications")}
+	preferenceRepository := &repositories.PreferenceRepository{Collection: db.Collection("messaging_preferences")}
+	otpRepository := &repositories.OTPRepository{Collection: db.Collection("otps")}
+	cartRepository := &repositories.CartRepository{Collection: db.Collection("carts")}
+	customerRepository := &repositories.CustomerRepository{Collection: db.Collection("customers")}
+	couponRepository := &repositories.CouponRepository{Collection: db.Collection("coupons")}
+	cartReminderRepository := &repositories.CartReminderRepository{Collection: db.Collection("cart_reminders")}
+	wishlistRepository := &repositories.WishlistRepository{Collection: db.Collection("wishlists")}
+	stockSubscriptionRepository := &repositories.StockSubscriptionRepository{Collection: db.Collection("stock_subscriptions")}
+	subscriptionPlanRepository := &repositories.SubscriptionPlanRepository{Collection: db.Collection("subscription_plans")}
+	subscriptionRepository := &repositories.SubscriptionRepository{Collection: db.Collection("subscriptions")}
+	giftCardRepository := &repositories.GiftCardRepository{Collection: db.Collection("gift_cards")}
+	walletRepository := &repositories.WalletRepository{Collection: db.Collection("wallets")}
+	ledgerRepository := &repositories.LedgerRepository{Collection: db.Collection("ledger")}
+	loyaltyRepository := &repositories.LoyaltyRepository{Collection: db.Collection("loyalty_points")}
+	invoiceRepository := &repositories.InvoiceRepository{Collection: db.Collection("invoices"), Counters: db.Collection("invoice_counters")}
+	jobRepository := &repositories.JobRepository{Collection: db.Collection("jobs"), DeadLetters: db.Collection("dead_jobs")}
+
+	// Notifications
+	mailer, err := notifications.NewMailerFromEnv()
+	if err != nil {
+		fatal("Invalid mailer configuration", err)
+	}
+	messagingProvider, err := messaging.NewProviderFromEnv()
+	if err != nil {
+		fatal("Invalid messaging configuration", err)
+	}
+	shippingProvider, err := shipping.NewProviderFromEnv()
+	if err != nil {
+		fatal("Invalid shipping configuration", err)
+	}
+	emailNotifier := &notifications.Notifier{Outbox: notificationRepository, Products: productRepository}
+	messagingNotifier := &messaging.Notifier{Outbox: notificationRepository, Preferences: preferenceRepository}
+	notifier := services.OrderNotifiers{emailNotifier, messagingNotifier}
+	senders := []notifications.Sender{
+		&notifications.EmailSender{Mailer: mailer},
+		&messaging.Sender{ChannelName: models.NotificationChannelWhatsApp, Provider: messagingProvider, Orders: orderRepository},
+		&messaging.Sender{ChannelName: models.NotificationChannelSMS, Provider: messagingProvider, Orders: orderRepository},
+	}
+	// Background workers report in so the readiness probe can tell when one has stopped.
+	heartbeats := &health.Heartbeats{}
+	for _, sender := range senders {
+		dispatcher := &notifications.Dispatcher{Outbox: notificationRepository, Sender: sender, Heartbeat: heartbeats.Beat}
+		go dispatcher.Run(context.Background())
+	}
+
+	// Order events are written to the order's outbox with the change that caused them, and the job runner
+	// hands them to the notifiers.
+	stockEvents := &jobs.StockEvents{Jobs: jobRepository}
 
 	// Services
-	productService := &services.ProductService{Repository: productRepository}
-	orderService := &services.OrderService{OrderRepository: orderRepository, ProductRepository: productRepository}
-	paymentService := services.NewPaymentService()
+	productService := &services.ProductService{Repository: productRepository, Restocks: stockEvents}
+	paymentGateway := services.NewRazorpayGateway()
+	refundService := &services.RefundService{OrderRepository: orderRepository, RefundRepository: refundRepository, Gateway: paymentGateway}
+	reviewService := &services.ReviewService{Repository: reviewRepository, OrderRepository: orderRepository, ProductRepository: productRepository}
+	returnService := &services.ReturnService{OrderRepository: orderRepository, ReturnRepository: returnRepository, Refunds: refundService}
+	orderService := &services.OrderService{OrderRepository: orderRepository, ProductRepository: productRepository, Refunds: refundService}
+	paymentService := services.NewPaymentService(paymentGateway, orderRepository, refundService)
+	orderService.Payments = paymentService
+	orderService.PaymentWindow = durationFromEnv("ORDER_PAYMENT_WINDOW", services.DefaultPaymentWindow)
+	orderService.Coupons = couponRepository
+	orderService.Restocks = stockEvents
+	orderService.DispatchTime = durationFromEnv("DELIVERY_DISPATCH_TIME", services.DefaultDispatchTime)
+	orderService.TransitTime = durationFromEnv("DELIVERY_TRANSIT_TIME", services.DefaultTransitTime)
+	otpService := &services.OTPService{
+		Repository: otpRepository,
+		Sender:     &messaging.OTPSender{ChannelName: models.NotificationChannelSMS, Provider: messagingProvider},
+	}
+	messagingService := &services.MessagingService{
+		Preferences:     preferenceRepository,
+		OrderRepository: orderRepository,
+		OTP:             otpService,
+		CallbackSecret:  os.Getenv("MESSAGING_CALLBACK_SECRET"),
+	}
+	jobService := &services.JobService{Repository: jobRepository}
+	cartService := &services.CartService{Repository: cartRepository, ProductRepository: productRepository, Reminders: cartReminderRepository}
+	orderService.Carts = cartService
+	tokenSecret := []byte(os.Getenv("CUSTOMER_TOKEN_SECRET"))
+	customerTokens := &services.CustomerTokens{Secret: tokenSecret}
+	customerService := &services.CustomerService{
+		Repository: customerRepository,
+		OTP:        otpService,
+		Tokens:     customerTokens,
+		Carts:      cartService,
+	}
+	cartRecoveryService := &services.CartRecoveryService{
+		Carts:         cartRepository,
+		CartService:   cartService,
+		Reminders:     cartReminderRepository,
+		Coupons:       couponRepository,
+		Customers:     customerRepository,
+		Notifiers:     []services.CartReminderNotifier{emailNotifier, messagingNotifier},
+		LinkSecret:    tokenSecret,
+		ShopURL:       shopURL(),
+		Delay:         durationFromEnv("CART_REMINDER_DELAY", services.DefaultCartReminderDelay),
+		CouponPercent: floatFromEnv("CART_RECOVERY_COUPON_PERCENT"),
+	}
+	wishlistService := &services.WishlistService{
+		Repository: wishlistRepository,
+		Products:   productRepository,
+		Customers:  customerRepository,
+		Notifiers:  []services.BackInStockNotifier{emailNotifier, messagingNotifier},
+		ShopURL:    shopURL(),
+	}
+	stockSubscriptionService := &services.StockSubscriptionService{
+		Repository:  stockSubscriptionRepository,
+		Products:    productRepository,
+		Preferences: preferenceRepository,
+		Notifiers:   []services.BackInStockNotifier{emailNotifier, messagingNotifier},
+		ShopURL:     shopURL(),
+	}
+	subscriptionService := &services.SubscriptionService{
+		Repository:      subscriptionRepository,
+		Plans:           subscriptionPlanRepository,
+		Products:        productRepository,
+		Orders:          orderService,
+		OrderRepository: orderRepository,
+		Payments:        paymentService,
+		Gateway:         paymentGateway,
+		UnpaidOrders:    orderService,
+	}
+	paymentService.Subscriptions = subscriptionService
+	giftCardService := &services.GiftCardService{
+		Repository: giftCardRepository,
+		Wallets:    walletRepository,
+		Ledger:     ledgerRepository,
+		Products:   productRepository,
+		Notifiers:  []services.GiftCardNotifier{emailNotifier},
+		ShopURL:    shopURL(),
+	}
+	orderService.StoredValue = giftCardService
+	refundService.StoredValue = giftCardService
+	refundService.GiftCards = giftCardService
+	loyaltyService := &services.LoyaltyService{
+		Repository:   loyaltyRepository,
+		Products:     productRepository,
+		PointsPer100: floatFromEnv("LOYALTY_POINTS_PER_100"),
+		PointValue:   floatFromEnv("LOYALTY_POINT_VALUE"),
+		Multipliers:  multipliersFromEnv("LOYALTY_CATEGORY_MULTIPLIERS"),
+		PointsTTL:    durationFromEnv("LOYALTY_POINTS_TTL", services.DefaultPointsTTL),
+	}
+	orderService.Loyalty = loyaltyService
+	refundService.Loyalty = loyaltyService
+	invoiceStore, err := invoices.NewStoreFromEnv()
+	if err != nil {
+		fatal("Invalid invoice storage configuration", err)
+	}
+	invoiceService := &services.InvoiceService{
+		Repository: invoiceRepository,
+		Orders:     orderRepository,
+		Products:   productRepository,
+		Renderer:   &invoices.Renderer{},
+		Store:      invoiceStore,
+		Seller: models.InvoiceParty{
+			Name:    sellerName(),
+			Address: os.Getenv("INVOICE_SELLER_ADDRESS"),
+			GSTIN:   strings.ToUpper(strings.TrimSpace(os.Getenv("INVOICE_GSTIN"))),
+		},
+		DefaultHSN:     os.Getenv("INVOICE_DEFAULT_HSN"),
+		DefaultGSTRate: floatFromEnv("INVOICE_DEFAULT_GST_RATE"),
+	}
+	shippingService := &services.ShippingService{Orders: orderRepository, Provider: shippingProvider}
+	// Paid orders are invoiced only once the shop's GSTIN is configured.
+	orderEventHandlers := []jobs.OrderEventHandler{giftCardService, loyaltyService}
+	if invoiceService.Seller.GSTIN != "" {
+		if !services.ValidGSTIN(invoiceService.Seller.GSTIN) {
+			fatal("Invalid INVOICE_GSTIN", fmt.Errorf("%q is not a valid GSTIN", invoiceService.Seller.GSTIN))
+		}
+		orderEventHandlers = append(orderEventHandlers, invoiceService)
+	}
 
-	// Seed database
-	if err := productService.SeedProducts(); err != nil {
-		log.Fatal(err)
+	// Background jobs
+	runner := &jobs.Runner{Jobs: jobRepository, Orders: orderRepository, Heartbeat: heartbeats.Beat}
+	runner.Handle(models.JobTypeNotifyOrder, jobs.NotifyOrderHandler(orderRepository, notifier, orderEventHandlers...))
+	runner.Handle(models.JobTypeExpireUnpaidOrders, func(ctx context.Context, _ models.Job) error {
+		expired, err := orderService.ExpireUnpaidOrders(ctx)
+		if expired > 0 {
+			logging.FromContext(ctx).Info("Expired unpaid orders", "expired", expired)
+		}
+		return err
+	})
+	runner.Every(models.JobTypeExpireUnpaidOrders, expirySweepInterval)
+	runner.Handle(models.JobTypeProductRestocked, jobs.RestockHandler(jobRepository, wishlistService, stockSubscriptionService))
+	runner.Handle(models.JobTypePlaceSubscriptions, func(ctx context.Context, _ models.Job) error {
+		placed, err := subscriptionService.PlaceDueOrders(ctx)
+		if placed > 0 {
+			logging.FromContext(ctx).Info("Placed subscription orders", "placed", placed)
+		}
+		return err
+	})
+	runner.Every(models.JobTypePlaceSubscriptions, subscriptionSweepInterval)
+	runner.Handle(models.JobTypeExpirePoints, func(ctx context.Context, _ models.Job) error {
+		expired, err := loyaltyService.ExpirePoints(ctx)
+		if expired > 0 {
+			logging.FromContext(ctx).Info("Expired loyalty point credits", "expired", expired)
+		}
+		return err
+	})
+	runner.Every(models.JobTypeExpirePoints, pointsExpirySweepInterval)
+	if shippingProvider.Name() != shipping.ProviderManual {
+		runner.Handle(models.JobTypeTrackShipments, func(ctx context.Context, _ models.Job) error {
+			checked, err := shippingService.PollTracking(ctx)
+			if checked > 0 {
+				logging.FromContext(ctx).Info("Tracked shipments", "checked", checked)
+			}
+			return err
+		})
+		runner.Every(models.JobTypeTrackShipments, shipmentTrackingSweepInterval)
 	}
+	if len(tokenSecret) > 0 {
+		runner.Handle(models.JobTypeRemindAbandonedCarts, func(ctx context.Context, _ models.Job) error {
+			sent, err := cartRecoveryService.SendReminders(ctx)
+			if sent > 0 {
+				logging.FromContext(ctx).Info("Sent abandoned cart reminders", "sent", sent)
+			}
+			return err
+		})
+		runner.Every(models.JobTypeRemindAbandonedCarts, cartReminderSweepInterval)
+	}
+	go runner.Run(context.Background())
 
 	// Controllers
 	productController := &controllers.ProductController{Service: productService}
 	orderController := &controllers.OrderController{Service: orderService}
 	paymentController := &controllers.PaymentController{Service: paymentService}
+	refundController := &controllers.RefundController{Service: refundService}
+	returnController := &controllers.ReturnController{Service: returnService}
+	reviewController := &controllers.ReviewController{Service: reviewService}
+	messagingController := &controllers.MessagingController{Service: messagingService}
+	jobController := &controllers.JobController{Service: jobService}
+	cartController := &controllers.CartController{Service: cartService, Recovery: cartRecoveryService}
+	customerController := &controllers.CustomerController{Service: customerService}
+	wishlistController := &controllers.WishlistController{Service: wishlistService}
+	stockSubscriptionController := &controllers.StockSubscriptionController{Service: stockSubscriptionService}
+	subscriptionController := &controllers.SubscriptionController{Service: subscriptionService}
+	giftCardController := &controllers.GiftCardController{Service: giftCardService}
+	loyaltyController := &controllers.LoyaltyController{Service: loyaltyService}
+	invoiceController := &controllers.InvoiceController{Service: invoiceService}
+	shippingController := &controllers.ShippingController{Service: shippingService}
+	healthController := &controllers.HealthController{DetailsToken: os.Getenv("METRICS_TOKEN"), Checker: &health.Checker{CacheFor: readinessCacheFor, Components: []health.Component{
+		{Name: "mongo", Critical: true, Check: health.MongoCheck(db)},
+		{Name: "migrations", Critical: true, Check: health.MigrationsCheck(database.NewMigrator(db))},
+		{Name: "payment_gateway", Check: paymentGateway.CheckConfig},
+		{Name: "workers", Check: heartbeats.Check(workerHeartbeatMaxAge)},
+	}}}
+
+	// Rate limiting
+	rateLimitStore, err := rateLimitStoreFromEnv(db)
+	if err != nil {
+		fatal("Invalid rate limit configuration", err)
+	}
+	allowlist, err := middleware.ParseAllowlist(os.Getenv("RATE_LIMIT_ALLOWLIST"))
+	if err != nil {
+		fatal("Invalid RATE_LIMIT_ALLOWLIST", err)
+	}
+	limiter := &middleware.RateLimiter{Store: rateLimitStore, Allowlist: allowlist}
 
-	// Gin router
-	router := gin.Default()
+	// Gin router. Requests are logged by RequestLogger rather than gin's own text logger; it comes before
+	// Recovery so requests that panic are logged with the 500 Recovery turns them into, and after the tracing
+	// middleware so each log line carries its trace ID. Metrics scrapes and probes are not traced.
+	router := gin.New()
+	router.Use(
+		otelgin.Middleware(tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+			return r.URL.Path != "/metrics" && r.URL.Path != "/healthz" && r.URL.Path != "/readyz"
+		})),
+		middleware.RequestLogger(logger), middleware.Metrics(), gin.Recovery(),
+	)
+	// Client addresses are taken from X-Forwarded-For only when it is set by a trusted proxy; otherwise
+	// anyone could dodge the rate limits by sending their own. In production the server sits behind a load
+	// balancer, and without its range every client would share the proxy's rate limits, so it must be set
+	// ("none" if clients connect directly).
+	trustedProxies := os.Getenv("TRUSTED_PROXIES")
+	if trustedProxies == "" && gin.Mode() == gin.ReleaseMode {
+		fatal("TRUSTED_PROXIES is required in release mode", errors.New(`set it to the load balancer's address range, or "none"`))
+	}
+	if err := middleware.TrustProxies(router, trustedProxies); err != nil {
+		fatal("Invalid TRUSTED_PROXIES", err)
+	}
 
 	// CORS middleware - configure allowed origins
 	allowedOrigins := getAllowedOrigins()
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", controllers.CartTokenHeader, middleware.RequestIDHeader, "traceparent", "tracestate"},
+		ExposeHeaders:    []string{controllers.CartTokenHeader, middleware.RequestIDHeader, "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
 		AllowCredentials: true,
 	}))
 
+	// Probes: /healthz for liveness, /readyz for readiness.
+	router.GET("/healthz", healthController.Live)
+	router.GET("/readyz", healthController.Ready)
+
+	// Prometheus metrics, behind METRICS_TOKEN as they include sales figures.
+	router.GET("/metrics", middleware.AdminAuth(os.Getenv("METRICS_TOKEN")), gin.WrapH(metrics.Handler()))
+
 	// API Routes
-	api := router.Group("/api")
+	api := router.Group("/api", limiter.Limit("api", limitFromEnv("RATE_LIMIT_API", defaultAPIRateLimit)))
+	tracking := limiter.Limit("tracking", limitFromEnv("RATE_LIMIT_TRACKING", defaultTrackingRateLimit))
 	{
 		api.GET("/products", productController.GetProducts)
 		api.GET("/products/:product_id", productController.GetProduct)
 		api.GET("/products/category/:category", productController.GetProductsByCategory)
-		api.POST("/orders", orderController.CreateOrder)
-		api.GET("/orders/:order_id", orderController.GetOrder)
+		api.GET("/products/:product_id/reviews", reviewController.ListProductReviews)
+		api.POST("/products/:product_id/notify-me", stockSubscriptionController.NotifyMe)
+		api.POST("/orders/:order_id/cancel", tracking, orderController.CancelOrder)
+		api.GET("/track", tracking, orderController.TrackOrder)
+		api.POST("/orders/:order_id/returns", tracking, returnController.RequestReturn)
 		api.GET("/categories", productController.GetCategories)
-		api.POST("/payments/create-order", paymentController.CreateRazorpayOrder)
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{"status": "healthy", "message": "Mangal Chai API is running"})
-		})
+		api.GET("/subscription-plans", subscriptionController.ListPlans)
+		api.GET("/gift-cards/:code", giftCardController.GetBalance)
+		api.POST("/payments/create-order", limiter.Limit("payments", limitFromEnv("RATE_LIMIT_PAYMENTS", defaultPaymentRateLimit)), paymentController.CreateRazorpayOrder)
+		api.POST("/payments/webhook", paymentController.HandleWebhook)
+		api.POST("/messaging/otp", messagingController.RequestOTP)
+		api.POST("/messaging/opt-in", messagingController.OptIn)
+		api.POST("/messaging/opt-out", messagingController.OptOut)
+		api.POST("/messaging/callback", messagingController.HandleCallback)
+		api.POST("/shipping/webhook", shippingController.HandleWebhook)
+		api.POST("/auth/otp", customerController.RequestOTP)
+		api.POST("/auth/login", customerController.Login)
+	}
+
+	// Customer Routes, for guests and logged-in customers
+	customer := api.Group("", middleware.CustomerAuth(customerTokens))
+	{
+		customer.GET("/auth/me", customerController.GetCurrentCustomer)
+		customer.GET("/cart", cartController.GetCart)
+		customer.PUT("/cart", cartController.UpdateCart)
+		customer.DELETE("/cart", cartController.DeleteCart)
+		customer.POST("/cart/restore", cartController.RestoreCart)
+		customer.POST("/orders", limiter.Limit("orders", limitFromEnv("RATE_LIMIT_ORDERS", defaultOrderRateLimit)), orderController.CreateOrder)
+		customer.POST("/products/:product_id/reviews", reviewController.SubmitReview)
+		customer.POST("/products/:product_id/reviews/:review_id/helpful", reviewController.MarkHelpful)
+		customer.GET("/wishlist", wishlistController.GetWishlist)
+		customer.PUT("/wishlist/:product_id", wishlistController.SaveToWishlist)
+		customer.DELETE("/wishlist/:product_id", wishlistController.RemoveFromWishlist)
+		customer.GET("/subscriptions", subscriptionController.ListSubscriptions)
+		customer.POST("/subscriptions", subscriptionController.Subscribe)
+		customer.POST("/subscriptions/:subscription_id/pause", subscriptionController.PauseSubscription)
+		customer.POST("/subscriptions/:subscription_id/resume", subscriptionController.ResumeSubscription)
+		customer.POST("/subscriptions/:subscription_id/skip", subscriptionController.SkipCycle)
+		customer.POST("/subscriptions/:subscription_id/cancel", subscriptionController.CancelSubscription)
+		customer.GET("/wallet", giftCardController.GetWallet)
+		customer.GET("/loyalty", loyaltyController.GetAccount)
+		customer.GET("/orders/:order_id", tracking, orderController.GetOrder)
+		customer.GET("/orders/:order_id/invoice", tracking, invoiceController.GetInvoice)
+	}
+
+	// Admin Routes
+	admin := api.Group("/admin", middleware.AdminAuth(os.Getenv("ADMIN_API_KEY")))
+	{
+		admin.GET("/orders", orderController.ListOrders)
+		admin.POST("/orders/status", orderController.BulkUpdateStatus)
+		admin.POST("/orders/:order_id/ship", orderController.ShipOrder)
+		admin.POST("/orders/:order_id/shipment", shippingController.BookShipment)
+		admin.GET("/orders/:order_id/shipment/label", shippingController.GetLabel)
+		admin.POST("/orders/:order_id/shipment/cancel", shippingController.CancelShipment)
+		admin.POST("/orders/:order_id/refunds", refundController.IssueRefund)
+		admin.GET("/orders/:order_id/refunds", refundController.GetOrderRefunds)
+		admin.GET("/orders/:order_id/invoice", invoiceController.GetAdminInvoice)
+		admin.GET("/refunds", refundController.ListRefunds)
+		admin.POST("/refunds/:refund_id/sync", refundController.SyncRefund)
+		admin.GET("/returns", returnController.ListReturns)
+		admin.POST("/returns/:return_id/approve", returnController.ApproveReturn)
+		admin.POST("/returns/:return_id/reject", returnController.RejectReturn)
+		admin.GET("/reviews", reviewController.ListReviews)
+		admin.POST("/reviews/:review_id/approve", reviewController.ApproveReview)
+		admin.POST("/reviews/:review_id/reject", reviewController.RejectReview)
+		admin.POST("/reviews/:review_id/flag", reviewController.FlagReview)
+		admin.GET("/wishlists/top", wishlistController.MostWishlisted)
+		admin.GET("/carts/recovery", cartController.RecoveryReport)
+		admin.POST("/subscription-plans", subscriptionController.CreatePlan)
+		admin.POST("/gift-cards", giftCardController.IssueGiftCard)
+		admin.GET("/ledger", giftCardController.ListLedger)
+		admin.GET("/jobs", jobController.ListJobs)
+		admin.GET("/jobs/dead", jobController.ListDeadJobs)
+		admin.POST("/jobs/dead/:job_id/retry", jobController.RetryJob)
 	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8001"
 	}
-	log.Printf("Starting server on :%s", port)
-	log.Printf("CORS enabled for origins: %v", allowedOrigins)
+	slog.Info("Starting server", "port", port, "cors_origins", allowedOrigins)
 	router.Run(":" + port)
 }
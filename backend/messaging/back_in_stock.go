@@ -0,0 +1,52 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NotifyBackInStock queues a message telling contact that a product is available again. It reports false,
+// queuing nothing, unless contact's phone number has opted in to messages.
+func (n *Notifier) NotifyBackInStock(ctx context.Context, contact models.CustomerInfo, product models.Product, link string) (bool, error) {
+	phone, ok := E164(contact.Phone)
+	if !ok {
+		return false, nil
+	}
+	preference, err := n.Preferences.GetPreference(ctx, phone)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !preference.OptedIn) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	name := contact.Name
+	if name == "" {
+		name = "there"
+	}
+	text, params, err := render(models.ProductEventBackInStock, map[string]string{"name": name, "product": product.Name, "link": link})
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	err = n.Outbox.Enqueue(ctx, models.Notification{
+		ID:            fmt.Sprintf("ntf_%d", now.UnixNano()),
+		Event:         models.ProductEventBackInStock,
+		Channel:       preference.Channel,
+		To:            phone,
+		Text:          text,
+		Template:      models.ProductEventBackInStock,
+		Params:        params,
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	return err == nil, err
+}
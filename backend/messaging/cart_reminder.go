@@ -0,0 +1,83 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CanRemind reports whether the cart's phone number has opted in to messages.
+func (n *Notifier) CanRemind(ctx context.Context, cart models.Cart) (bool, error) {
+	_, preference, err := n.cartPreference(ctx, cart)
+	return preference != nil, err
+}
+
+// RemindCart queues an abandoned cart reminder on the customer's chosen channel. It reports false,
+// queuing nothing, unless the cart's phone number has opted in to messages.
+func (n *Notifier) RemindCart(ctx context.Context, cart models.Cart, reminder models.CartReminder) (bool, error) {
+	phone, preference, err := n.cartPreference(ctx, cart)
+	if preference == nil {
+		return false, err
+	}
+
+	text, params, err := render(models.CartEventReminder, cartData(cart, reminder))
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	err = n.Outbox.Enqueue(ctx, models.Notification{
+		ID:            fmt.Sprintf("ntf_%d", now.UnixNano()),
+		Event:         models.CartEventReminder,
+		Channel:       preference.Channel,
+		To:            phone,
+		Text:          text,
+		Template:      models.CartEventReminder,
+		Params:        params,
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	return err == nil, err
+}
+
+// cartPreference returns the cart's phone number and its messaging preference, or a nil preference when the
+// number has not opted in.
+func (n *Notifier) cartPreference(ctx context.Context, cart models.Cart) (string, *models.MessagingPreference, error) {
+	phone, ok := E164(cart.Phone)
+	if !ok {
+		return "", nil, nil
+	}
+	preference, err := n.Preferences.GetPreference(ctx, phone)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !preference.OptedIn) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return phone, preference, nil
+}
+
+// cartData is the template data for a cart reminder.
+func cartData(cart models.Cart, reminder models.CartReminder) map[string]string {
+	name := cart.Name
+	if name == "" {
+		name = "there"
+	}
+	items := "1 item"
+	if len(cart.Items) != 1 {
+		items = strconv.Itoa(len(cart.Items)) + " items"
+	}
+	data := map[string]string{"name": name, "items": items, "link": reminder.Link}
+	if reminder.CouponCode != "" {
+		data["coupon"] = reminder.CouponCode
+		data["percent_off"] = strconv.FormatFloat(reminder.PercentOff, 'f', -1, 64)
+	}
+	return data
+}
@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Notifier queues WhatsApp or SMS order updates for customers who have opted in, on the channel they chose.
+type Notifier struct {
+	Outbox      repositories.NotificationRepositoryInterface
+	Preferences repositories.PreferenceRepositoryInterface
+}
+
+func (n *Notifier) NotifyOrder(ctx context.Context, event string, order models.Order) {
+	if err := n.enqueue(ctx, event, order); err != nil {
+		logging.FromContext(ctx).Error("Failed to queue order message", "event", event, "order_id", order.ID, "error", err)
+	}
+}
+
+func (n *Notifier) enqueue(ctx context.Context, event string, order models.Order) error {
+	phone, ok := E164(order.CustomerInfo.Phone)
+	if !ok {
+		return nil
+	}
+	preference, err := n.Preferences.GetPreference(ctx, phone)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !preference.OptedIn) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	text, params, err := render(event, orderData(order))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return n.Outbox.Enqueue(ctx, models.Notification{
+		ID:            fmt.Sprintf("ntf_%d", now.UnixNano()),
+		Event:         event,
+		OrderID:       order.ID,
+		Channel:       preference.Channel,
+		To:            phone,
+		Text:          text,
+		Template:      event,
+		Params:        params,
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
@@ -0,0 +1,25 @@
+package messaging
+
+import "strings"
+
+// E164 converts an Indian mobile number in any common format ("98765 43210", "+91-9876543210",
+// "09876543210") to E.164 form. It reports false for anything else.
+func E164(phone string) (string, bool) {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	number := digits.String()
+	switch {
+	case len(number) == 12 && strings.HasPrefix(number, "91"):
+		number = number[2:]
+	case len(number) == 11 && strings.HasPrefix(number, "0"):
+		number = number[1:]
+	}
+	if len(number) != 10 || number[0] < '6' {
+		return "", false
+	}
+	return "+91" + number, true
+}
@@ -0,0 +1,132 @@
+// Package messaging sends order updates and one-time passwords over WhatsApp and SMS through a pluggable
+// provider, respecting each phone number's opt-in.
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is a WhatsApp or SMS message. WhatsApp only allows business-initiated messages from approved
+// templates, so messages carry the template name and parameters as well as the rendered text used for SMS.
+type Message struct {
+	Channel  string
+	To       string
+	Template string
+	Params   []string
+	Text     string
+}
+
+// Provider sends messages and returns the provider's ID for the message, which delivery callbacks refer to.
+type Provider interface {
+	Send(message Message) (string, error)
+}
+
+// HTTPProvider talks to a messaging provider's REST API. It posts each message as JSON to {BaseURL}/messages
+// and expects a JSON response with the message "id".
+type HTTPProvider struct {
+	BaseURL     string
+	APIKey      string
+	Sender      string
+	CallbackURL string
+	Client      *http.Client
+}
+
+type providerRequest struct {
+	Channel     string   `json:"channel"`
+	From        string   `json:"from,omitempty"`
+	To          string   `json:"to"`
+	Template    string   `json:"template,omitempty"`
+	Params      []string `json:"params,omitempty"`
+	Text        string   `json:"text"`
+	CallbackURL string   `json:"callback_url,omitempty"`
+}
+
+type providerResponse struct {
+	ID string `json:"id"`
+}
+
+func (p *HTTPProvider) Send(message Message) (string, error) {
+	body, err := json.Marshal(providerRequest{
+		Channel:     message.Channel,
+		From:        p.Sender,
+		To:          message.To,
+		Template:    message.Template,
+		Params:      message.Params,
+		Text:        message.Text,
+		CallbackURL: p.CallbackURL,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(p.BaseURL, "/")+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("messaging provider returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var result providerResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("invalid messaging provider response: %w", err)
+	}
+	if result.ID == "" {
+		return "", fmt.Errorf("messaging provider response has no message id")
+	}
+	return result.ID, nil
+}
+
+// LogProvider is the development provider: it logs messages instead of sending them.
+type LogProvider struct{}
+
+func (p *LogProvider) Send(message Message) (string, error) {
+	slog.Info("Message", "channel", message.Channel, "phone", message.To, "text", message.Text)
+	return fmt.Sprintf("log_%d", time.Now().UnixNano()), nil
+}
+
+// NewProviderFromEnv builds the provider selected by MESSAGING_PROVIDER: "http", or "log" (the default).
+func NewProviderFromEnv() (Provider, error) {
+	switch provider := os.Getenv("MESSAGING_PROVIDER"); provider {
+	case "", "log":
+		return &LogProvider{}, nil
+	case "http":
+		baseURL := os.Getenv("MESSAGING_API_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("MESSAGING_API_URL must be set when MESSAGING_PROVIDER=http")
+		}
+		return &HTTPProvider{
+			BaseURL:     baseURL,
+			APIKey:      os.Getenv("MESSAGING_API_KEY"),
+			Sender:      os.Getenv("MESSAGING_SENDER"),
+			CallbackURL: os.Getenv("MESSAGING_CALLBACK_URL"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown MESSAGING_PROVIDER %q, use http or log", provider)
+	}
+}
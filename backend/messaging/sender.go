@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+)
+
+// Sender delivers queued WhatsApp or SMS notifications through a Provider and records each message on its
+// order so delivery callbacks can update it.
+type Sender struct {
+	ChannelName string
+	Provider    Provider
+	Orders      repositories.OrderRepositoryInterface
+}
+
+func (s *Sender) Channel() string {
+	return s.ChannelName
+}
+
+func (s *Sender) Send(ctx context.Context, notification models.Notification) error {
+	messageID, err := s.Provider.Send(Message{
+		Channel:  notification.Channel,
+		To:       notification.To,
+		Template: notification.Template,
+		Params:   notification.Params,
+		Text:     notification.Text,
+	})
+	if err != nil {
+		return err
+	}
+	if notification.OrderID == "" {
+		return nil
+	}
+
+	// The message has gone out, so a failure to record it must not cause a resend.
+	now := time.Now()
+	err = s.Orders.AddMessage(ctx, notification.OrderID, models.MessageDelivery{
+		ProviderMessageID: messageID,
+		Channel:           notification.Channel,
+		Event:             notification.Event,
+		To:                notification.To,
+		Status:            models.MessageStatusQueued,
+		SentAt:            now,
+		UpdatedAt:         now,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to record message on order", "message_id", messageID, "order_id", notification.OrderID, "error", err)
+	}
+	return nil
+}
+
+// OTPSender sends one-time passwords directly rather than through the outbox, since they are only useful
+// for a few minutes.
+type OTPSender struct {
+	ChannelName string
+	Provider    Provider
+}
+
+func (s *OTPSender) SendOTP(phone string, code string, ttl time.Duration) error {
+	data := map[string]string{"code": code, "minutes": strconv.Itoa(int(ttl.Minutes()))}
+	text, params, err := render(TemplateOTP, data)
+	if err != nil {
+		return err
+	}
+	_, err = s.Provider.Send(Message{Channel: s.ChannelName, To: phone, Template: TemplateOTP, Params: params, Text: text})
+	return err
+}
@@ -0,0 +1,86 @@
+package messaging
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"mangal-chai-backend/models"
+)
+
+// TemplateOTP is the template for one-time passwords.
+const TemplateOTP = "otp"
+
+// messageTemplate is the text of a message and the data keys whose values, in order, are the parameters of
+// the WhatsApp template registered with the provider under the same name.
+type messageTemplate struct {
+	text   *template.Template
+	params []string
+}
+
+func newTemplate(name string, text string, params ...string) messageTemplate {
+	return messageTemplate{text: template.Must(template.New(name).Option("missingkey=zero").Parse(text)), params: params}
+}
+
+var templates = map[string]messageTemplate{
+	models.OrderEventPlaced: newTemplate(models.OrderEventPlaced,
+		"Hi {{.name}}, we have received your Mangal Chai order {{.order_id}} for {{.amount}}. We will start preparing it once your payment is confirmed.",
+		"name", "order_id", "amount"),
+	models.OrderEventPaymentConfirmed: newTemplate(models.OrderEventPaymentConfirmed,
+		"Hi {{.name}}, we have received your payment of {{.amount}} for Mangal Chai order {{.order_id}}. Your order is confirmed.",
+		"name", "amount", "order_id"),
+	models.OrderEventShipped: newTemplate(models.OrderEventShipped,
+		"Hi {{.name}}, your Mangal Chai order {{.order_id}} is on its way!{{if .tracking}} Track it here: {{.tracking}}{{end}}",
+		"name", "order_id", "tracking"),
+	models.OrderEventDelivered: newTemplate(models.OrderEventDelivered,
+		"Hi {{.name}}, your Mangal Chai order {{.order_id}} has been delivered. Enjoy your chai!",
+		"name", "order_id"),
+	models.OrderEventCancelled: newTemplate(models.OrderEventCancelled,
+		"Hi {{.name}}, your Mangal Chai order {{.order_id}} has been cancelled.{{if .refund}} Your payment of {{.amount}} is being refunded.{{end}}",
+		"name", "order_id", "amount"),
+	models.CartEventReminder: newTemplate(models.CartEventReminder,
+		"Hi {{.name}}, you left {{.items}} in your Mangal Chai cart. Pick up where you left off: {{.link}}{{if .coupon}} Use code {{.coupon}} for {{.percent_off}}% off.{{end}}",
+		"name", "items", "link", "coupon"),
+	models.ProductEventBackInStock: newTemplate(models.ProductEventBackInStock,
+		"Hi {{.name}}, {{.product}} is back in stock at Mangal Chai. Order now before it runs out again: {{.link}}",
+		"name", "product", "link"),
+	TemplateOTP: newTemplate(TemplateOTP,
+		"{{.code}} is your Mangal Chai verification code. It expires in {{.minutes}} minutes. Do not share it with anyone.",
+		"code", "minutes"),
+}
+
+// render renders the named template, returning the text and the WhatsApp template parameters.
+func render(name string, data map[string]string) (string, []string, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return "", nil, fmt.Errorf("no message template %q", name)
+	}
+	var text bytes.Buffer
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return "", nil, err
+	}
+	params := make([]string, len(tmpl.params))
+	for i, key := range tmpl.params {
+		params[i] = data[key]
+	}
+	return text.String(), params, nil
+}
+
+// orderData is the template data for an order event.
+func orderData(order models.Order) map[string]string {
+	data := map[string]string{
+		"name":     order.CustomerInfo.Name,
+		"order_id": order.ID,
+		"amount":   fmt.Sprintf("₹%.2f", order.TotalAmount),
+	}
+	if order.Shipment != nil {
+		data["tracking"] = order.Shipment.TrackingURL
+		if data["tracking"] == "" {
+			data["tracking"] = order.Shipment.TrackingNumber
+		}
+	}
+	if order.PaymentID != "" {
+		data["refund"] = "yes"
+	}
+	return data
+}
@@ -0,0 +1,80 @@
+// Package metrics exposes Prometheus metrics: HTTP traffic, MongoDB operations, and the shop's orders,
+// payments and revenue.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "mangal"
+
+// Registry holds every metric the server exports, along with the Go runtime and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+var (
+	// HTTPRequests counts requests by method, route pattern (such as /api/orders/:order_id, so order IDs do
+	// not each get a series) and status code.
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "http_requests_total", Help: "HTTP requests handled.",
+	}, []string{"method", "route", "status"})
+	// HTTPDuration is how long requests took to handle.
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "http_request_duration_seconds", Help: "Time taken to handle HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// MongoDuration is how long MongoDB commands took, by the repository and method that sent them.
+	MongoDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "mongo_operation_duration_seconds", Help: "Time taken by MongoDB commands.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repository", "method"})
+	// MongoErrors counts MongoDB commands that failed. A query finding no document is not a failure.
+	MongoErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "mongo_operation_errors_total", Help: "MongoDB commands that failed.",
+	}, []string{"repository", "method"})
+
+	// OrdersCreated counts orders placed, by channel: storefront or subscription.
+	OrdersCreated = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "orders_created_total", Help: "Orders placed.",
+	}, []string{"channel"})
+	// OutOfStockRejections counts orders refused because a product was out of stock or had too little left.
+	OutOfStockRejections = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "out_of_stock_rejections_total", Help: "Orders refused for lack of stock.",
+	}, []string{"product_id"})
+	// PaymentsSucceeded counts captured gateway payments by payment method (upi, card, ...).
+	PaymentsSucceeded = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "payments_succeeded_total", Help: "Gateway payments captured.",
+	}, []string{"method"})
+	// PaymentsFailed counts failed gateway payments by the gateway's reason, such as payment_cancelled.
+	PaymentsFailed = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "payments_failed_total", Help: "Gateway payments that failed.",
+	}, []string{"reason"})
+	// Revenue is the total of orders paid for, in rupees, by payment method. It is counted when a paid order
+	// is confirmed, not when it is placed, so unpaid and expired orders and payments that arrive after the
+	// order was cancelled are left out.
+	Revenue = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "revenue_rupees_total", Help: "Value of orders paid for, in rupees.",
+	}, []string{"payment_method"})
+	// Refunds is the total of refunds processed, in rupees, by how they were paid: gateway, store_credit or
+	// gift_card. Revenue less refunds is what the shop kept. Refunds of payments that arrived after their
+	// order was cancelled or expired are counted too, although those payments never counted as revenue.
+	Refunds = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "refunds_rupees_total", Help: "Value of refunds processed, in rupees.",
+	}, []string{"method"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
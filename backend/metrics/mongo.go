@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// repositoryPackage prefixes the functions of the repositories package in stack traces.
+const repositoryPackage = "mangal-chai-backend/repositories."
+
+type operation struct {
+	repository string
+	method     string
+}
+
+// MongoMonitor times every command the client sends. The driver calls the monitor on the goroutine that
+// sent the command, so the repository method behind it is found on the call stack rather than by changing
+// every method. Commands not sent from a repository, such as migrations, are labelled "other" with the
+// command name as the method.
+func MongoMonitor() *event.CommandMonitor {
+	var inFlight sync.Map // request ID -> operation
+	done := func(requestID int64) (operation, bool) {
+		op, ok := inFlight.LoadAndDelete(requestID)
+		if !ok {
+			return operation{}, false
+		}
+		return op.(operation), true
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+			inFlight.Store(evt.RequestID, callingOperation(evt.CommandName))
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			if op, ok := done(evt.RequestID); ok {
+				MongoDuration.WithLabelValues(op.repository, op.method).Observe(evt.Duration.Seconds())
+			}
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			if op, ok := done(evt.RequestID); ok {
+				MongoDuration.WithLabelValues(op.repository, op.method).Observe(evt.Duration.Seconds())
+				MongoErrors.WithLabelValues(op.repository, op.method).Inc()
+			}
+		},
+	}
+}
+
+// callingOperation finds the repository method on the call stack. When one repository method calls
+// another, or a helper, the outermost one is taken, as that is the one the service called.
+func callingOperation(commandName string) operation {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	op := operation{repository: "other", method: commandName}
+	found := false
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, repositoryPackage); ok {
+			op, found = repositoryOperation(name), true
+		} else if found || !more {
+			return op
+		}
+	}
+}
+
+// repositoryOperation splits a function name such as "(*OrderRepository).GetOrder.func1" into its type and
+// method, dropping any closure suffix.
+func repositoryOperation(name string) operation {
+	receiver, method, ok := strings.Cut(name, ".")
+	if !ok {
+		return operation{repository: "repositories", method: receiver}
+	}
+	if strings.HasPrefix(receiver, "(") {
+		// A method; the receiver is (*Type) or (Type).
+		receiver = strings.Trim(receiver, "(*)")
+		method, _, _ = strings.Cut(method, ".")
+		return operation{repository: receiver, method: method}
+	}
+	// A package function or its closure.
+	return operation{repository: "repositories", method: receiver}
+}
@@ -0,0 +1,28 @@
+// Package middleware holds Gin middleware shared by the API routes.
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth only lets through requests carrying apiKey as a bearer token. When apiKey is empty every admin
+// request is refused, so admin routes are closed unless ADMIN_API_KEY is configured.
+func AdminAuth(apiKey string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if apiKey == "" {
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Admin API is not configured"})
+			return
+		}
+
+		token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"mangal-chai-backend/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomerIDKey is the context key under which CustomerAuth stores the logged-in customer's ID.
+const CustomerIDKey = "customer_id"
+
+// TokenVerifier returns the customer ID in a valid login token.
+type TokenVerifier interface {
+	Verify(token string) (string, error)
+}
+
+// CustomerAuth identifies the logged-in customer from their bearer token. Requests without a token carry
+// on as guests; a request with an invalid or expired token is refused so the client knows to log in
+// again rather than silently falling back to a guest session.
+func CustomerAuth(verifier TokenVerifier) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.GetHeader("Authorization")
+		if header == "" {
+			ctx.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		customerID, err := verifier.Verify(token)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Login expired, please log in again"})
+			return
+		}
+		ctx.Set(CustomerIDKey, customerID)
+		ctx.Request = ctx.Request.WithContext(logging.With(ctx.Request.Context(), "customer_id", customerID))
+		ctx.Next()
+	}
+}
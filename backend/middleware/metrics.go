@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"mangal-chai-backend/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that matched no route, so probes for random paths share one series.
+const unmatchedRoute = "unmatched"
+
+// Metrics counts and times requests by method and route pattern.
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route, method := ctx.FullPath(), ctx.Request.Method
+		if route == "" {
+			// Any method can be sent to a missing route, so it is not used as a label.
+			route, method = unmatchedRoute, unmatchedRoute
+		}
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mangal-chai-backend/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit allows Requests per Window from one client, refilled evenly over the window, so a client that has
+// used its allowance gets another request every Window/Requests.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseLimit reads a limit written as requests/window, e.g. "10/1m" for ten requests a minute.
+func ParseLimit(raw string) (Limit, error) {
+	requests, window, ok := strings.Cut(strings.TrimSpace(raw), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q must be requests/window, e.g. 10/1m", raw)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q must allow a positive number of requests", raw)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q must have a positive window, e.g. 1m", raw)
+	}
+	return Limit{Requests: n, Window: d}, nil
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.Requests) / l.Window.Seconds()
+}
+
+// RateLimitStore keeps a token bucket per key. Take refills the key's bucket for the time since it was last
+// used, at perSecond up to capacity, spends a token if there is one and returns the tokens left. ttl is how
+// long an idle bucket must be kept; after that it would be full again anyway.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, capacity float64, perSecond float64, ttl time.Duration, now time.Time) (tokens float64, allowed bool, err error)
+}
+
+// RateLimiter limits requests per client IP address, separately for each named limit so the checkout
+// routes can be held to a tighter limit than browsing. Addresses on the allowlist, such as the shop's own
+// office, are never limited.
+type RateLimiter struct {
+	Store     RateLimitStore
+	Allowlist []*net.IPNet
+}
+
+// Limit returns middleware applying limit, counted under name. Responses carry X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset (seconds until the allowance is full again); refused requests
+// get 429 Too Many Requests with Retry-After. If the store fails the request is let through, so an outage
+// of a shared store does not take checkout down with it.
+func (l *RateLimiter) Limit(name string, limit Limit) gin.HandlerFunc {
+	capacity, perSecond := float64(limit.Requests), limit.perSecond()
+	return func(ctx *gin.Context) {
+		ip := ctx.ClientIP()
+		if l.allowed(ip) {
+			ctx.Next()
+			return
+		}
+
+		tokens, ok, err := l.Store.Take(ctx.Request.Context(), name+":"+ip, capacity, perSecond, limit.Window, time.Now())
+		if err != nil {
+			logging.FromContext(ctx.Request.Context()).Error("Rate limit store failed, letting request through", "error", err)
+			ctx.Next()
+			return
+		}
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(int(math.Floor(tokens))))
+		ctx.Header("X-RateLimit-Reset", strconv.Itoa(seconds((capacity-tokens)/perSecond)))
+		if !ok {
+			ctx.Header("Retry-After", strconv.Itoa(seconds((1-tokens)/perSecond)))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func (l *RateLimiter) allowed(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range l.Allowlist {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAllowlist reads a comma-separated list of IP addresses and CIDR ranges.
+func ParseAllowlist(raw string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q in rate limit allowlist", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// TrustProxies sets the proxies, a comma-separated list of IP addresses and CIDR ranges, whose
+// X-Forwarded-For header gives the client address. With none, or "none", the client address is always the
+// connection's, so clients cannot dodge the rate limits by sending their own X-Forwarded-For.
+func TrustProxies(router *gin.Engine, raw string) error {
+	var proxies []string
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" && entry != NoTrustedProxies {
+			proxies = append(proxies, entry)
+		}
+	}
+	return router.SetTrustedProxies(proxies)
+}
+
+// NoTrustedProxies is the TrustProxies value for a server that clients reach directly.
+const NoTrustedProxies = "none"
+
+func seconds(s float64) int {
+	return int(math.Ceil(s))
+}
+
+// MemoryRateLimitStore keeps buckets in memory, so each server limits on its own. Use a shared store when
+// running several instances.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	expires time.Time
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, capacity float64, perSecond float64, ttl time.Duration, now time.Time) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+	b.expires = now.Add(ttl)
+	if b.tokens < 1 {
+		return b.tokens, false, nil
+	}
+	b.tokens--
+	return b.tokens, true, nil
+}
+
+// prune forgets idle buckets, at most once a minute, so the map does not grow with every address ever seen.
+func (s *MemoryRateLimitStore) prune(now time.Time) {
+	if s.buckets == nil {
+		s.buckets = map[string]*bucket{}
+	}
+	if now.Sub(s.pruned) < time.Minute {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.After(b.expires) {
+			delete(s.buckets, key)
+		}
+	}
+	s.pruned = now
+}
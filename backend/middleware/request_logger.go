@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"mangal-chai-backend/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries a request's ID. An ID sent by the client or a proxy in front of the server is
+// kept, so one request can be followed through every log; otherwise one is generated. Either way it is
+// echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs taken from the client, which are written to every log line of the request.
+const maxRequestIDLength = 128
+
+// RequestLogger gives each request a logger carrying its request ID, trace ID, and the order ID for order
+// routes, in the request context, and logs the request once it has been handled: method, route, status and
+// latency, along with the logged-in customer that CustomerAuth adds to the logger. Server errors are logged
+// at error level and client errors at warning level. The query string is left out as it can hold contact
+// details, such as on order tracking.
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		ctx.Header(RequestIDHeader, requestID)
+
+		requestLogger := logger.With("request_id", requestID)
+		if orderID := ctx.Param("order_id"); orderID != "" {
+			requestLogger = requestLogger.With("order_id", orderID)
+		}
+		// The trace ID leads from a log line to the request's trace, when tracing is on.
+		if span := trace.SpanContextFromContext(ctx.Request.Context()); span.IsValid() {
+			requestLogger = requestLogger.With("trace_id", span.TraceID().String())
+		}
+		ctx.Request = ctx.Request.WithContext(logging.WithLogger(ctx.Request.Context(), requestLogger))
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []any{
+			"method", ctx.Request.Method,
+			"route", ctx.FullPath(),
+			"path", ctx.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", ctx.ClientIP(),
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, "error", ctx.Errors.String())
+		}
+		logging.FromContext(ctx.Request.Context()).Log(ctx.Request.Context(), level, "Request handled", attrs...)
+	}
+}
+
+// validRequestID accepts IDs of letters, digits and the punctuation common in trace and UUID formats, so
+// a client cannot inject arbitrary text into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
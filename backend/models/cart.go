@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Cart is a shopping cart kept on the server. A guest cart is found by its Token; a logged-in customer's
+// cart by CustomerID. Name, Email and Phone are contact details given at checkout, used to remind the
+// customer if they leave without ordering.
+type Cart struct {
+	ID         string     `json:"id" bson:"id"`
+	Token      string     `json:"token,omitempty" bson:"token,omitempty"`
+	CustomerID string     `json:"customer_id,omitempty" bson:"customer_id,omitempty"`
+	Items      []CartLine `json:"items" bson:"items"`
+	Subtotal   float64    `json:"subtotal" bson:"subtotal"`
+	Name       string     `json:"name,omitempty" bson:"name,omitempty"`
+	Email      string     `json:"email,omitempty" bson:"email,omitempty"`
+	Phone      string     `json:"phone,omitempty" bson:"phone,omitempty"`
+	Notices    []string   `json:"notices,omitempty" bson:"-"` // changes made when the cart was last validated
+	ReminderID string     `json:"-" bson:"reminder_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" bson:"updated_at"`
+	ExpiresAt  time.Time  `json:"expires_at" bson:"expires_at"`
+}
+
+// CartLine is a product in a cart. Name, ImageURL and Price are copied from the product each time the cart
+// is validated, so they show what the product costs now.
+type CartLine struct {
+	ProductID string  `json:"product_id" bson:"product_id"`
+	Name      string  `json:"name" bson:"name"`
+	ImageURL  string  `json:"image_url,omitempty" bson:"image_url,omitempty"`
+	Quantity  int     `json:"quantity" bson:"quantity"`
+	Price     float64 `json:"price" bson:"price"`
+}
+
+// CartEventReminder is the notification event for abandoned cart reminders.
+const CartEventReminder = "cart_reminder"
+
+// CartReminder records a reminder sent about an abandoned cart and, if the customer came back and ordered,
+// the order it turned into.
+type CartReminder struct {
+	ID          string     `json:"id" bson:"id"`
+	CartID      string     `json:"cart_id" bson:"cart_id"`
+	CustomerID  string     `json:"customer_id,omitempty" bson:"customer_id,omitempty"`
+	CouponCode  string     `json:"coupon_code,omitempty" bson:"coupon_code,omitempty"`
+	PercentOff  float64    `json:"percent_off,omitempty" bson:"percent_off,omitempty"`
+	Link        string     `json:"link" bson:"link"`
+	Subtotal    float64    `json:"subtotal" bson:"subtotal"`
+	SentAt      time.Time  `json:"sent_at" bson:"sent_at"`
+	OrderID     string     `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	OrderTotal  float64    `json:"order_total,omitempty" bson:"order_total,omitempty"`
+	CouponUsed  bool       `json:"coupon_used,omitempty" bson:"coupon_used,omitempty"`
+	RecoveredAt *time.Time `json:"recovered_at,omitempty" bson:"recovered_at,omitempty"`
+}
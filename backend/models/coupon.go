@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Coupon sources.
+const (
+	CouponSourceCartRecovery = "cart_recovery"
+)
+
+// Coupon is a single-use discount code. It is redeemed by the order it is applied to, and released again
+// if that order is cancelled or expires.
+type Coupon struct {
+	Code       string     `json:"code" bson:"code"`
+	PercentOff float64    `json:"percent_off" bson:"percent_off"`
+	Source     string     `json:"source" bson:"source"`
+	ExpiresAt  time.Time  `json:"expires_at" bson:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	OrderID    string     `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty" bson:"redeemed_at,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Customer is a shopper who has logged in with a one-time code sent to their phone.
+type Customer struct {
+	ID          string    `json:"id" bson:"id"`
+	Phone       string    `json:"phone" bson:"phone"`
+	Name        string    `json:"name,omitempty" bson:"name,omitempty"`
+	Email       string    `json:"email,omitempty" bson:"email,omitempty"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at" bson:"last_login_at"`
+}
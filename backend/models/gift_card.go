@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// GiftCardCategory is the catalog category of gift cards. A gift card product is worth its price, and
+// buying one issues a card of that value once the order is paid. Coupons and points do not apply to them.
+const GiftCardCategory = "Gift Cards"
+
+// GiftCardEventIssued is the notification sent to a gift card's recipient with its code.
+const GiftCardEventIssued = "gift_card_issued"
+
+// Gift card sources.
+const (
+	GiftCardSourcePurchase = "purchase"
+	GiftCardSourceAdmin    = "admin"
+)
+
+// GiftCard is a code with a balance in rupees that can be spent, in part or in full, at checkout. Cards
+// bought in an order are identified by OrderID and OrderLine so each is only issued once, and are voided if
+// the order is refunded before they are used.
+type GiftCard struct {
+	Code           string     `json:"code" bson:"code"`
+	InitialBalance float64    `json:"initial_balance" bson:"initial_balance"`
+	Balance        float64    `json:"balance" bson:"balance"`
+	Source         string     `json:"source" bson:"source"`
+	OrderID        string     `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	OrderLine      int        `json:"-" bson:"order_line,omitempty"`
+	ProductID      string     `json:"-" bson:"product_id,omitempty"` // the gift card product bought
+	RecipientName  string     `json:"recipient_name,omitempty" bson:"recipient_name,omitempty"`
+	RecipientEmail string     `json:"recipient_email,omitempty" bson:"recipient_email,omitempty"`
+	Message        string     `json:"message,omitempty" bson:"message,omitempty"`
+	IssuedAt       time.Time  `json:"issued_at" bson:"issued_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	VoidedAt       *time.Time `json:"voided_at,omitempty" bson:"voided_at,omitempty"`
+}
+
+// Wallet is a customer's store credit in rupees.
+type Wallet struct {
+	CustomerID string    `json:"-" bson:"customer_id"`
+	Balance    float64   `json:"balance" bson:"balance"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Ledger accounts: the kind of balance a ledger entry moved.
+const (
+	LedgerAccountGiftCard = "gift_card"
+	LedgerAccountWallet   = "wallet"
+)
+
+// Ledger entry kinds.
+const (
+	LedgerKindIssued     = "issued"     // a gift card was issued
+	LedgerKindSpent      = "spent"      // paid towards an order
+	LedgerKindReleased   = "released"   // returned from an order that was cancelled, expired or not placed
+	LedgerKindRefund     = "refund"     // an order refund paid as credit
+	LedgerKindVoided     = "voided"     // a gift card bought in a refunded order was taken back
+	LedgerKindReinstated = "reinstated" // a voided gift card's refund failed, so it was given its balance back
+)
+
+// LedgerEntry records one movement of a gift card or wallet balance. Amount is positive for credits and
+// negative for debits, and Balance is the account's balance after the movement.
+type LedgerEntry struct {
+	ID        string    `json:"id" bson:"id"`
+	Account   string    `json:"account" bson:"account"`
+	AccountID string    `json:"account_id" bson:"account_id"` // gift card code or customer ID
+	Kind      string    `json:"kind" bson:"kind"`
+	Amount    float64   `json:"amount" bson:"amount"`
+	Balance   float64   `json:"balance" bson:"balance"`
+	OrderID   string    `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	RefundID  string    `json:"refund_id,omitempty" bson:"refund_id,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Invoice is the GST tax invoice issued for an order once it is paid. It is a snapshot: later changes to the
+// order's products or the shop's details do not change an invoice already issued.
+type Invoice struct {
+	Number string `json:"number" bson:"number"` // e.g. MC/2026-27/00042, sequential within the financial year
+	// FinancialYear is the Indian financial year (April to March) the invoice was issued in, e.g. 2026-27.
+	FinancialYear string        `json:"financial_year" bson:"financial_year"`
+	OrderID       string        `json:"order_id" bson:"order_id"`
+	Seller        InvoiceParty  `json:"seller" bson:"seller"`
+	Buyer         InvoiceParty  `json:"buyer" bson:"buyer"`
+	PlaceOfSupply string        `json:"place_of_supply" bson:"place_of_supply"` // GST state code
+	InterState    bool          `json:"inter_state" bson:"inter_state"`         // IGST rather than CGST and SGST
+	Lines         []InvoiceLine `json:"lines" bson:"lines"`
+	TaxableValue  float64       `json:"taxable_value" bson:"taxable_value"`
+	CGST          float64       `json:"cgst" bson:"cgst"`
+	SGST          float64       `json:"sgst" bson:"sgst"`
+	IGST          float64       `json:"igst" bson:"igst"`
+	Total         float64       `json:"total" bson:"total"`
+	IssuedAt      time.Time     `json:"issued_at" bson:"issued_at"`
+	File          string        `json:"-" bson:"file,omitempty"` // name of the stored PDF, once it is stored
+}
+
+// InvoiceParty is the seller or buyer named on an invoice. StateCode is the two-digit GST state code.
+type InvoiceParty struct {
+	Name      string `json:"name" bson:"name"`
+	Address   string `json:"address" bson:"address"`
+	GSTIN     string `json:"gstin,omitempty" bson:"gstin,omitempty"`
+	State     string `json:"state,omitempty" bson:"state,omitempty"`
+	StateCode string `json:"state_code,omitempty" bson:"state_code,omitempty"`
+}
+
+// InvoiceLine is one product on an invoice. Prices are inclusive of GST, so Total is what the customer paid
+// for the line after discounts and TaxableValue is Total less the tax in it.
+type InvoiceLine struct {
+	Description  string  `json:"description" bson:"description"`
+	HSN          string  `json:"hsn,omitempty" bson:"hsn,omitempty"`
+	Quantity     int     `json:"quantity" bson:"quantity"`
+	UnitPrice    float64 `json:"unit_price" bson:"unit_price"`
+	Discount     float64 `json:"discount" bson:"discount"`
+	TaxableValue float64 `json:"taxable_value" bson:"taxable_value"`
+	GSTRate      float64 `json:"gst_rate" bson:"gst_rate"` // percent
+	CGST         float64 `json:"cgst" bson:"cgst"`
+	SGST         float64 `json:"sgst" bson:"sgst"`
+	IGST         float64 `json:"igst" bson:"igst"`
+	Total        float64 `json:"total" bson:"total"`
+}
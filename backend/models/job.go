@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Job statuses. Jobs that exhaust their attempts are moved to the dead-letter collection with status dead.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusDead      = "dead"
+)
+
+// Job types.
+const (
+	JobTypeNotifyOrder          = "order.notify"
+	JobTypeExpireUnpaidOrders   = "orders.expire_unpaid"
+	JobTypeRemindAbandonedCarts = "carts.remind_abandoned"
+	JobTypeProductRestocked     = "product.restocked"
+	JobTypePlaceSubscriptions   = "subscriptions.place_orders"
+	JobTypeExpirePoints         = "loyalty.expire_points"
+	JobTypeTrackShipments       = "shipping.track_shipments"
+)
+
+// JobRequest asks for a job to be run at RunAt, or as soon as possible when RunAt is zero. Requests with the
+// same ID are only run once, so a request can be safely re-submitted.
+type JobRequest struct {
+	ID      string            `json:"id" bson:"id"`
+	Type    string            `json:"type" bson:"type"`
+	Payload map[string]string `json:"payload,omitempty" bson:"payload,omitempty"`
+	RunAt   time.Time         `json:"run_at" bson:"run_at"`
+}
+
+// Job is a queued unit of background work. While a worker runs it, RunAt holds the time its claim expires.
+type Job struct {
+	ID          string            `json:"id" bson:"id"`
+	Type        string            `json:"type" bson:"type"`
+	Payload     map[string]string `json:"payload,omitempty" bson:"payload,omitempty"`
+	Status      string            `json:"status" bson:"status"`
+	Attempts    int               `json:"attempts" bson:"attempts"`
+	LastError   string            `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	RunAt       time.Time         `json:"run_at" bson:"run_at"`
+	CreatedAt   time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at" bson:"updated_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
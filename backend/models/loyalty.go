@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Loyalty ledger entry kinds.
+const (
+	PointsKindEarned   = "earned"   // for a delivered order
+	PointsKindRedeemed = "redeemed" // spent as a discount at checkout
+	PointsKindReleased = "released" // given back from an order that was cancelled, expired or not placed
+	PointsKindExpired  = "expired"
+	PointsKindReversed = "reversed" // taken back because the order they were earned on was refunded
+)
+
+// PointsEntry is one movement of a customer's loyalty points. Points is positive for credits and negative
+// for debits. Credits are also the lots points are spent from: Remaining is what is left of the credit
+// and it can be spent until ExpiresAt, oldest lot first. A redemption keeps the lots it was spent from in
+// From, so points released from its order go back to those lots.
+type PointsEntry struct {
+	ID         string     `json:"id" bson:"id"`
+	CustomerID string     `json:"-" bson:"customer_id"`
+	Kind       string     `json:"kind" bson:"kind"`
+	Points     int        `json:"points" bson:"points"`
+	Remaining  int        `json:"-" bson:"remaining,omitempty"`
+	OrderID    string     `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	From       []LotDebit `json:"-" bson:"from,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// LotDebit is the part of a debit taken from one lot.
+type LotDebit struct {
+	LotID  string `bson:"lot_id"`
+	Points int    `bson:"points"`
+}
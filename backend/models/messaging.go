@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Message delivery statuses reported by the messaging provider.
+const (
+	MessageStatusQueued    = "queued"
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+	MessageStatusFailed    = "failed"
+)
+
+// MessagingPreference records whether a phone number has opted in to order updates over WhatsApp or SMS.
+// Phone is in E.164 form, e.g. +919876543210.
+type MessagingPreference struct {
+	Phone     string    `json:"phone" bson:"phone"`
+	OptedIn   bool      `json:"opted_in" bson:"opted_in"`
+	Channel   string    `json:"channel" bson:"channel"`
+	Source    string    `json:"source" bson:"source"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// MessageDelivery is a WhatsApp or SMS message sent about an order and its latest delivery status.
+type MessageDelivery struct {
+	ProviderMessageID string    `json:"provider_message_id" bson:"provider_message_id"`
+	Channel           string    `json:"channel" bson:"channel"`
+	Event             string    `json:"event" bson:"event"`
+	To                string    `json:"to" bson:"to"`
+	Status            string    `json:"status" bson:"status"`
+	Error             string    `json:"error,omitempty" bson:"error,omitempty"`
+	SentAt            time.Time `json:"sent_at" bson:"sent_at"`
+	UpdatedAt         time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// OneTimePassword is a pending OTP for a phone number. Only a hash of the code is stored.
+type OneTimePassword struct {
+	Phone     string    `json:"phone" bson:"phone"`
+	Purpose   string    `json:"purpose" bson:"purpose"`
+	CodeHash  string    `json:"-" bson:"code_hash"`
+	Attempts  int       `json:"attempts" bson:"attempts"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
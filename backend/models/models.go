@@ -1,4 +1,3 @@
-
 package models
 
 import "time"
@@ -12,12 +11,29 @@ type Product struct {
 	Category    string  `json:"category" bson:"category"`
 	ImageURL    string  `json:"image_url" bson:"image_url"`
 	InStock     bool    `json:"in_stock" bson:"in_stock"`
+	Stock       int     `json:"stock" bson:"stock"`
 	Weight      string  `json:"weight" bson:"weight"`
+	// HSNCode and GSTRate (percent) are printed on tax invoices; products without them use the shop's
+	// defaults.
+	HSNCode string  `json:"hsn_code,omitempty" bson:"hsn_code,omitempty"`
+	GSTRate float64 `json:"gst_rate,omitempty" bson:"gst_rate,omitempty"`
+	// Rating and ReviewCount summarise the product's approved reviews; they are kept up to date by the
+	// review service, not the catalog.
+	Rating      float64 `json:"rating" bson:"rating"`
+	ReviewCount int     `json:"review_count" bson:"review_count"`
+}
+
+// CatalogEntry is a product read from a catalog file. HasStock is false when the file leaves the stock out,
+// in which case an import keeps the stock the product already has.
+type CatalogEntry struct {
+	Product
+	HasStock bool
 }
 
 type CartItem struct {
-	ProductID string `json:"product_id" bson:"product_id"`
-	Quantity  int    `json:"quantity" bson:"quantity"`
+	ProductID string  `json:"product_id" bson:"product_id"`
+	Quantity  int     `json:"quantity" bson:"quantity"`
+	Price     float64 `json:"price,omitempty" bson:"price,omitempty"` // unit price, fixed when the order is placed
 }
 
 type CustomerInfo struct {
@@ -25,17 +41,85 @@ type CustomerInfo struct {
 	Phone   string `json:"phone" bson:"phone"`
 	Email   string `json:"email" bson:"email"`
 	Address string `json:"address" bson:"address"`
+	GSTIN   string `json:"gstin,omitempty" bson:"gstin,omitempty"` // business customers' GST number, for their tax invoice
+}
+
+// Order statuses. An order can be cancelled by the customer until it is packed; a pending order that is
+// not paid in time expires.
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusConfirmed = "confirmed"
+	OrderStatusPacked    = "packed"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusExpired   = "expired"
+)
+
+// Payment statuses recorded on an order.
+const (
+	PaymentStatusPaid              = "paid"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusRefundFailed      = "refund_failed"
+)
+
+// Payment methods recorded for orders paid in full without the gateway.
+const (
+	PaymentMethodGiftCard      = "gift_card"
+	PaymentMethodStoreCredit   = "store_credit"
+	PaymentMethodLoyaltyPoints = "loyalty_points"
+)
+
+type StatusChange struct {
+	Status    string    `json:"status" bson:"status"`
+	Reason    string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at" bson:"changed_at"`
+}
+
+// Shipment is how an order was sent. It is recorded when the parcel is booked with a courier, or when the
+// order is marked shipped if it was sent some other way, and collects the courier's tracking events.
+type Shipment struct {
+	Provider           string          `json:"provider,omitempty" bson:"provider,omitempty"`
+	ProviderShipmentID string          `json:"-" bson:"provider_shipment_id,omitempty"`
+	Courier            string          `json:"courier,omitempty" bson:"courier,omitempty"`
+	TrackingNumber     string          `json:"tracking_number,omitempty" bson:"tracking_number,omitempty"` // the courier's AWB number
+	TrackingURL        string          `json:"tracking_url,omitempty" bson:"tracking_url,omitempty"`
+	LabelURL           string          `json:"-" bson:"label_url,omitempty"`
+	Status             string          `json:"status,omitempty" bson:"status,omitempty"` // latest tracking status
+	Events             []TrackingEvent `json:"events,omitempty" bson:"events,omitempty"`
+	BookedAt           *time.Time      `json:"booked_at,omitempty" bson:"booked_at,omitempty"`
+	ShippedAt          *time.Time      `json:"shipped_at,omitempty" bson:"shipped_at,omitempty"`
+	TrackedAt          *time.Time      `json:"-" bson:"tracked_at,omitempty"` // last polled for tracking events
 }
 
 type Order struct {
-	ID                    string       `json:"id" bson:"id"`
-	CustomerInfo          CustomerInfo `json:"customer_info" bson:"customer_info"`
-	Items                 []CartItem   `json:"items" bson:"items"`
-	TotalAmount           float64      `json:"total_amount" bson:"total_amount"`
-	Status                string       `json:"status" bson:"status"`
-	OrderDate             time.Time    `json:"order_date" bson:"order_date"`
-	Notes                 string       `json:"notes,omitempty" bson:"notes,omitempty"`
-	PaymentGatewayOrderID string       `json:"payment_gateway_order_id,omitempty" bson:"payment_gateway_order_id,omitempty"`
-	PaymentStatus         string       `json:"payment_status,omitempty" bson:"payment_status,omitempty"`
-	PaymentMethod         string       `json:"payment_method,omitempty" bson:"payment_method,omitempty"`
+	ID                     string            `json:"id" bson:"id"`
+	CustomerInfo           CustomerInfo      `json:"customer_info" bson:"customer_info"`
+	Items                  []CartItem        `json:"items" bson:"items"`
+	TotalAmount            float64           `json:"total_amount" bson:"total_amount"` // after any discount
+	CouponCode             string            `json:"coupon_code,omitempty" bson:"coupon_code,omitempty"`
+	Discount               float64           `json:"discount,omitempty" bson:"discount,omitempty"`
+	PointsRedeemed         int               `json:"points_redeemed,omitempty" bson:"points_redeemed,omitempty"`
+	PointsDiscount         float64           `json:"points_discount,omitempty" bson:"points_discount,omitempty"`
+	GiftCardCode           string            `json:"gift_card_code,omitempty" bson:"gift_card_code,omitempty"`
+	GiftCardAmount         float64           `json:"gift_card_amount,omitempty" bson:"gift_card_amount,omitempty"`       // paid from the gift card
+	StoreCreditAmount      float64           `json:"store_credit_amount,omitempty" bson:"store_credit_amount,omitempty"` // paid from the customer's wallet
+	CustomerID             string            `json:"customer_id,omitempty" bson:"customer_id,omitempty"`
+	SubscriptionID         string            `json:"subscription_id,omitempty" bson:"subscription_id,omitempty"` // placed by a subscription, paid by its gateway charges
+	Status                 string            `json:"status" bson:"status"`
+	OrderDate              time.Time         `json:"order_date" bson:"order_date"`
+	Notes                  string            `json:"notes,omitempty" bson:"notes,omitempty"`
+	PaymentGatewayOrderID  string            `json:"payment_gateway_order_id,omitempty" bson:"payment_gateway_order_id,omitempty"` // the latest gateway order
+	PaymentGatewayAmount   int64             `json:"-" bson:"payment_gateway_amount,omitempty"`                                    // in paise, of the latest gateway order
+	PaymentGatewayOrderIDs []string          `json:"-" bson:"payment_gateway_order_ids,omitempty"`                                 // every gateway order made for it; a capture on any of them pays it
+	PaymentStatus          string            `json:"payment_status,omitempty" bson:"payment_status,omitempty"`
+	PaymentMethod          string            `json:"payment_method,omitempty" bson:"payment_method,omitempty"`
+	PaymentID              string            `json:"payment_id,omitempty" bson:"payment_id,omitempty"`
+	RefundID               string            `json:"refund_id,omitempty" bson:"refund_id,omitempty"`
+	RefundedAmount         float64           `json:"refunded_amount,omitempty" bson:"refunded_amount,omitempty"` // reserved by refunds that have not failed
+	StatusHistory          []StatusChange    `json:"status_history,omitempty" bson:"status_history,omitempty"`
+	Shipment               *Shipment         `json:"shipment,omitempty" bson:"shipment,omitempty"`
+	Messages               []MessageDelivery `json:"messages,omitempty" bson:"messages,omitempty"`
+	Outbox                 []JobRequest      `json:"-" bson:"outbox,omitempty"` // jobs written with the order, relayed to the job queue
 }
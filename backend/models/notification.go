@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Order events customers are notified about.
+const (
+	OrderEventPlaced           = "order_placed"
+	OrderEventPaymentConfirmed = "payment_confirmed"
+	OrderEventShipped          = "order_shipped"
+	OrderEventDelivered        = "order_delivered"
+	OrderEventCancelled        = "order_cancelled"
+)
+
+// Notification channels.
+const (
+	NotificationChannelEmail    = "email"
+	NotificationChannelWhatsApp = "whatsapp"
+	NotificationChannelSMS      = "sms"
+)
+
+// Notification statuses. A notification is sending while a dispatcher holds it; if the dispatcher dies the
+// claim expires at NextAttemptAt and another dispatcher picks it up again.
+const (
+	NotificationStatusPending = "pending"
+	NotificationStatusSending = "sending"
+	NotificationStatusSent    = "sent"
+	NotificationStatusFailed  = "failed"
+)
+
+// Notification is a rendered message in the outbox. It is stored before it is sent so that a crash or a
+// transport outage delays it rather than losing it.
+type Notification struct {
+	ID            string     `json:"id" bson:"id"`
+	Event         string     `json:"event" bson:"event"`
+	OrderID       string     `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	Channel       string     `json:"channel" bson:"channel"`
+	To            string     `json:"to" bson:"to"`
+	Subject       string     `json:"subject,omitempty" bson:"subject,omitempty"`
+	HTML          string     `json:"html,omitempty" bson:"html,omitempty"`
+	Text          string     `json:"text" bson:"text"`
+	Template      string     `json:"template,omitempty" bson:"template,omitempty"` // provider template name for WhatsApp
+	Params        []string   `json:"params,omitempty" bson:"params,omitempty"`     // template parameters, in order
+	Status        string     `json:"status" bson:"status"`
+	Attempts      int        `json:"attempts" bson:"attempts"`
+	LastError     string     `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" bson:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at" bson:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty" bson:"sent_at,omitempty"`
+}
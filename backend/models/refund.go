@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Refund statuses, mirroring the gateway's refund lifecycle.
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusProcessed = "processed"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund methods. Refunds go back through the payment gateway unless they are paid as store credit, or
+// onto the gift card the order was paid with.
+const (
+	RefundMethodGateway     = "gateway"
+	RefundMethodStoreCredit = "store_credit"
+	RefundMethodGiftCard    = "gift_card"
+)
+
+// Refund is a full or partial refund of an order's payment. Items lists the refunded line items for a
+// partial refund and is empty when the remaining balance of the order was refunded.
+type Refund struct {
+	ID              string     `json:"id" bson:"id"`
+	OrderID         string     `json:"order_id" bson:"order_id"`
+	PaymentID       string     `json:"payment_id" bson:"payment_id"`
+	Method          string     `json:"method,omitempty" bson:"method,omitempty"` // empty for gateway refunds made before store credit
+	GatewayRefundID string     `json:"gateway_refund_id,omitempty" bson:"gateway_refund_id,omitempty"`
+	ReturnID        string     `json:"return_id,omitempty" bson:"return_id,omitempty"`
+	Items           []CartItem `json:"items,omitempty" bson:"items,omitempty"`
+	GiftCardCodes   []string   `json:"gift_card_codes,omitempty" bson:"gift_card_codes,omitempty"` // cards bought in the order that the refund voided
+	Amount          float64    `json:"amount" bson:"amount"`
+	Reason          string     `json:"reason" bson:"reason"`
+	Status          string     `json:"status" bson:"status"`
+	FailureReason   string     `json:"failure_reason,omitempty" bson:"failure_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// Return request statuses.
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+)
+
+// ReturnRequest is a customer's request to send back items from a delivered order.
+type ReturnRequest struct {
+	ID        string     `json:"id" bson:"id"`
+	OrderID   string     `json:"order_id" bson:"order_id"`
+	Items     []CartItem `json:"items" bson:"items"`
+	Reason    string     `json:"reason" bson:"reason"`
+	PhotoURLs []string   `json:"photo_urls,omitempty" bson:"photo_urls,omitempty"`
+	Status    string     `json:"status" bson:"status"`
+	AdminNote string     `json:"admin_note,omitempty" bson:"admin_note,omitempty"`
+	RefundID  string     `json:"refund_id,omitempty" bson:"refund_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Review statuses. New reviews wait for moderation and only approved ones are shown and counted in a
+// product's rating. Flagged reviews are hidden until an admin looks at them again.
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+	ReviewStatusFlagged  = "flagged"
+)
+
+// Review is a customer's rating of a product they received. Every review belongs to a delivered order
+// that contains the product, and an order can review each of its products once.
+type Review struct {
+	ID             string    `json:"id" bson:"id"`
+	ProductID      string    `json:"product_id" bson:"product_id"`
+	OrderID        string    `json:"-" bson:"order_id"`
+	CustomerID     string    `json:"-" bson:"customer_id,omitempty"`
+	AuthorName     string    `json:"author_name" bson:"author_name"`
+	Rating         int       `json:"rating" bson:"rating"`
+	Title          string    `json:"title,omitempty" bson:"title,omitempty"`
+	Text           string    `json:"text" bson:"text"`
+	Status         string    `json:"status" bson:"status"`
+	HelpfulCount   int       `json:"helpful_count" bson:"helpful_count"`
+	HelpfulVoters  []string  `json:"-" bson:"helpful_voters,omitempty"`
+	ModerationNote string    `json:"moderation_note,omitempty" bson:"moderation_note,omitempty"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Tracking statuses. Couriers report many more, which shipping providers map onto these; a status a
+// provider cannot map is kept as the courier reported it.
+const (
+	TrackingStatusBooked         = "booked"
+	TrackingStatusPickedUp       = "picked_up"
+	TrackingStatusInTransit      = "in_transit"
+	TrackingStatusOutForDelivery = "out_for_delivery"
+	TrackingStatusDelivered      = "delivered"
+	TrackingStatusFailed         = "delivery_failed"
+	TrackingStatusReturned       = "returned"
+	TrackingStatusCancelled      = "cancelled"
+)
+
+// TrackingEvent is one scan or status update from the courier.
+type TrackingEvent struct {
+	Status      string    `json:"status" bson:"status"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	Location    string    `json:"location,omitempty" bson:"location,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at" bson:"occurred_at"`
+}
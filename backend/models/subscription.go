@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Subscription frequencies: how often a subscription's order is placed.
+const (
+	FrequencyWeekly   = "weekly"
+	FrequencyBiweekly = "biweekly"
+	FrequencyMonthly  = "monthly"
+)
+
+// Subscription statuses. A subscription is pending until the customer authorises its recurring payment with
+// the gateway, and halted when the gateway gave up charging it.
+const (
+	SubscriptionStatusPending   = "pending"
+	SubscriptionStatusActive    = "active"
+	SubscriptionStatusPaused    = "paused"
+	SubscriptionStatusHalted    = "halted"
+	SubscriptionStatusCancelled = "cancelled"
+)
+
+// SubscriptionCycleSkipped is recorded in a subscription's history for a cycle that placed no order, because
+// the customer skipped it or the order could not be placed.
+const SubscriptionCycleSkipped = "skipped"
+
+// SubscriptionPlan is a set of products delivered on a fixed schedule for a fixed price. Item prices are
+// taken from the catalog when the plan is created and do not follow later price changes.
+type SubscriptionPlan struct {
+	ID            string     `json:"id" bson:"id"`
+	Name          string     `json:"name" bson:"name"`
+	Description   string     `json:"description,omitempty" bson:"description,omitempty"`
+	Items         []CartItem `json:"items" bson:"items"`
+	Frequency     string     `json:"frequency" bson:"frequency"`
+	Amount        float64    `json:"amount" bson:"amount"` // charged every cycle
+	GatewayPlanID string     `json:"-" bson:"gateway_plan_id"`
+	Active        bool       `json:"active" bson:"active"`
+	CreatedAt     time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// SubscriptionPayment is a gateway charge for a subscription that no order has been paid with yet.
+type SubscriptionPayment struct {
+	PaymentID string    `json:"payment_id" bson:"payment_id"`
+	Method    string    `json:"method" bson:"method"`
+	Amount    int64     `json:"amount" bson:"amount"` // paise
+	ChargedAt time.Time `json:"charged_at" bson:"charged_at"`
+}
+
+// Subscription is a customer's standing order for a plan. Every cycle, from NextOrderAt, an order for the
+// plan's items is placed and paid with the gateway's charge for that cycle.
+type Subscription struct {
+	ID                    string         `json:"id" bson:"id"`
+	PlanID                string         `json:"plan_id" bson:"plan_id"`
+	PlanName              string         `json:"plan_name" bson:"plan_name"`
+	CustomerID            string         `json:"-" bson:"customer_id"`
+	CustomerInfo          CustomerInfo   `json:"customer_info" bson:"customer_info"`
+	Items                 []CartItem     `json:"items" bson:"items"`
+	Frequency             string         `json:"frequency" bson:"frequency"`
+	Amount                float64        `json:"amount" bson:"amount"`
+	Status                string         `json:"status" bson:"status"`
+	NextOrderAt           *time.Time     `json:"next_order_at,omitempty" bson:"next_order_at,omitempty"`
+	GatewaySubscriptionID string         `json:"gateway_subscription_id" bson:"gateway_subscription_id"`
+	PaymentURL            string         `json:"payment_url,omitempty" bson:"payment_url,omitempty"` // where the customer authorises the payment
+	History               []StatusChange `json:"history,omitempty" bson:"history,omitempty"`
+	// Charges the gateway took before their cycle's order was placed, used to pay the next order.
+	Credits []SubscriptionPayment `json:"-" bson:"credits,omitempty"`
+	// SkippedCharges counts skipped cycles whose gateway charge is still to come; it is refunded on arrival.
+	SkippedCharges int       `json:"-" bson:"skipped_charges"`
+	ChargeIDs      []string  `json:"-" bson:"charge_ids,omitempty"` // gateway payments already handled
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at"`
+}
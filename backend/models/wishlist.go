@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ProductEventBackInStock is the notification event telling a customer that a product they were waiting
+// for can be bought again.
+const ProductEventBackInStock = "back_in_stock"
+
+// WishlistItem is a product a customer has saved. With NotifyRestock set the customer is told the next
+// time the product comes back into stock, after which the alert is switched off again.
+type WishlistItem struct {
+	CustomerID    string     `json:"-" bson:"customer_id"`
+	ProductID     string     `json:"product_id" bson:"product_id"`
+	NotifyRestock bool       `json:"notify_restock" bson:"notify_restock"`
+	AddedAt       time.Time  `json:"added_at" bson:"added_at"`
+	NotifiedAt    *time.Time `json:"notified_at,omitempty" bson:"notified_at,omitempty"`
+}
+
+// StockSubscription asks for a one-off alert when an out-of-stock product can be bought again. Anyone can
+// subscribe with an email address or a phone number opted in to messages; the subscription is removed
+// once the alert is sent.
+type StockSubscription struct {
+	ID        string    `json:"id" bson:"id"`
+	ProductID string    `json:"product_id" bson:"product_id"`
+	Email     string    `json:"email,omitempty" bson:"email"`
+	Phone     string    `json:"phone,omitempty" bson:"phone"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
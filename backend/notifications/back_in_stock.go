@@ -0,0 +1,59 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/models"
+)
+
+// restockView is the data the back in stock templates are rendered with.
+type restockView struct {
+	ShopName string
+	Name     string
+	Product  models.Product
+	Link     string
+}
+
+// NotifyBackInStock queues an email telling contact that a product is available again. It reports false,
+// queuing nothing, when contact has no email address.
+func (n *Notifier) NotifyBackInStock(ctx context.Context, contact models.CustomerInfo, product models.Product, link string) (bool, error) {
+	to := strings.TrimSpace(contact.Email)
+	if to == "" {
+		return false, nil
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return false, fmt.Errorf("invalid email %q", to)
+	}
+
+	view := restockView{ShopName: n.ShopName, Name: contact.Name, Product: product, Link: link}
+	if view.ShopName == "" {
+		view.ShopName = defaultShopName
+	}
+	var html, text bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, models.ProductEventBackInStock+".html", view); err != nil {
+		return false, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, models.ProductEventBackInStock+".txt", view); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	err := n.Outbox.Enqueue(ctx, models.Notification{
+		ID:            fmt.Sprintf("ntf_%d", now.UnixNano()),
+		Event:         models.ProductEventBackInStock,
+		Channel:       models.NotificationChannelEmail,
+		To:            to,
+		Subject:       fmt.Sprintf("%s is back in stock", product.Name),
+		HTML:          html.String(),
+		Text:          text.String(),
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	return err == nil, err
+}
@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/models"
+)
+
+// cartReminderSubject is the subject of abandoned cart reminder emails.
+const cartReminderSubject = "You left something in your cart"
+
+// cartView is the data the cart reminder templates are rendered with.
+type cartView struct {
+	ShopName string
+	Cart     models.Cart
+	Reminder models.CartReminder
+	Items    []lineView
+}
+
+// CanRemind reports whether the cart has an email address to send a reminder to.
+func (n *Notifier) CanRemind(ctx context.Context, cart models.Cart) (bool, error) {
+	to := strings.TrimSpace(cart.Email)
+	if to == "" {
+		return false, nil
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return false, fmt.Errorf("invalid cart email %q", to)
+	}
+	return true, nil
+}
+
+// RemindCart queues a reminder email about an abandoned cart. It reports false, queuing nothing, when the
+// cart has no email address.
+func (n *Notifier) RemindCart(ctx context.Context, cart models.Cart, reminder models.CartReminder) (bool, error) {
+	if ok, err := n.CanRemind(ctx, cart); !ok {
+		return false, err
+	}
+	to := strings.TrimSpace(cart.Email)
+
+	view := cartView{ShopName: n.ShopName, Cart: cart, Reminder: reminder}
+	if view.ShopName == "" {
+		view.ShopName = defaultShopName
+	}
+	for _, line := range cart.Items {
+		view.Items = append(view.Items, lineView{Name: line.Name, Quantity: line.Quantity, Total: line.Price * float64(line.Quantity)})
+	}
+
+	var html, text bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, models.CartEventReminder+".html", view); err != nil {
+		return false, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, models.CartEventReminder+".txt", view); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	err := n.Outbox.Enqueue(ctx, models.Notification{
+		ID:            fmt.Sprintf("ntf_%d", now.UnixNano()),
+		Event:         models.CartEventReminder,
+		Channel:       models.NotificationChannelEmail,
+		To:            to,
+		Subject:       cartReminderSubject,
+		HTML:          html.String(),
+		Text:          text.String(),
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	return err == nil, err
+}
@@ -0,0 +1,129 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultMaxAttempts  = 8
+	// sendLease is how long a claimed notification is held before another dispatcher may retry it.
+	sendLease  = 2 * time.Minute
+	maxBackoff = time.Hour
+)
+
+// Sender delivers queued notifications for one channel.
+type Sender interface {
+	Channel() string
+	Send(ctx context.Context, notification models.Notification) error
+}
+
+// EmailSender delivers email notifications through a Mailer.
+type EmailSender struct {
+	Mailer Mailer
+}
+
+func (s *EmailSender) Channel() string {
+	return models.NotificationChannelEmail
+}
+
+func (s *EmailSender) Send(ctx context.Context, notification models.Notification) error {
+	return s.Mailer.Send(Email{
+		To:      notification.To,
+		Subject: notification.Subject,
+		HTML:    notification.HTML,
+		Text:    notification.Text,
+	})
+}
+
+// Dispatcher sends queued notifications for its Sender's channel from the outbox, retrying failures with
+// exponential backoff until MaxAttempts is reached, after which the notification is left failed.
+type Dispatcher struct {
+	Outbox       repositories.NotificationRepositoryInterface
+	Sender       Sender
+	PollInterval time.Duration
+	MaxAttempts  int
+	// Heartbeat, if set, is called with "notifications." and the channel each time the dispatcher polls.
+	Heartbeat func(worker string)
+}
+
+// Run dispatches due notifications every PollInterval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	interval := d.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if d.Heartbeat != nil {
+			d.Heartbeat("notifications." + d.Sender.Channel())
+		}
+		if _, err := d.DispatchDue(ctx); err != nil {
+			logging.FromContext(ctx).Error("Notification dispatch failed", "channel", d.Sender.Channel(), "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue sends every notification that is currently due and returns how many were attempted.
+func (d *Dispatcher) DispatchDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for {
+		now := time.Now()
+		notification, err := d.Outbox.ClaimDue(ctx, d.Sender.Channel(), now, sendLease)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return attempted, nil
+		}
+		if err != nil {
+			return attempted, err
+		}
+		attempted++
+
+		sendErr := d.Sender.Send(ctx, *notification)
+		if sendErr == nil {
+			err = d.Outbox.MarkSent(ctx, notification.ID, time.Now())
+		} else {
+			giveUp := notification.Attempts >= d.maxAttempts()
+			if giveUp {
+				logging.FromContext(ctx).Error("Giving up on notification", "channel", notification.Channel, "notification_id", notification.ID, "order_id", notification.OrderID, "recipient", notification.To, "attempts", notification.Attempts, "error", sendErr)
+			}
+			err = d.Outbox.MarkFailed(ctx, notification.ID, sendErr.Error(), now.Add(backoff(notification.Attempts)), giveUp)
+		}
+		if err != nil {
+			return attempted, err
+		}
+	}
+}
+
+func (d *Dispatcher) maxAttempts() int {
+	if d.MaxAttempts > 0 {
+		return d.MaxAttempts
+	}
+	return defaultMaxAttempts
+}
+
+// backoff is the delay before retrying after the given number of attempts: 1m, 2m, 4m, ... up to an hour.
+func backoff(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
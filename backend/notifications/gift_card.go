@@ -0,0 +1,58 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/models"
+)
+
+// giftCardView is the data the gift card templates are rendered with.
+type giftCardView struct {
+	ShopName string
+	Card     models.GiftCard
+	Link     string
+}
+
+// NotifyGiftCard queues an email with a gift card's code to its recipient. It reports false, queuing
+// nothing, when the card has no recipient email address.
+func (n *Notifier) NotifyGiftCard(ctx context.Context, card models.GiftCard, link string) (bool, error) {
+	to := strings.TrimSpace(card.RecipientEmail)
+	if to == "" {
+		return false, nil
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return false, fmt.Errorf("invalid email %q", to)
+	}
+
+	view := giftCardView{ShopName: n.ShopName, Card: card, Link: link}
+	if view.ShopName == "" {
+		view.ShopName = defaultShopName
+	}
+	var html, text bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, models.GiftCardEventIssued+".html", view); err != nil {
+		return false, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, models.GiftCardEventIssued+".txt", view); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	err := n.Outbox.Enqueue(ctx, models.Notification{
+		ID:            fmt.Sprintf("ntf_%d", now.UnixNano()),
+		Event:         models.GiftCardEventIssued,
+		Channel:       models.NotificationChannelEmail,
+		To:            to,
+		Subject:       fmt.Sprintf("Your %s gift card worth ₹%.2f", view.ShopName, card.InitialBalance),
+		HTML:          html.String(),
+		Text:          text.String(),
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	return err == nil, err
+}
@@ -0,0 +1,160 @@
+// Package notifications renders customer notifications for order events, queues them in a persisted
+// outbox and delivers them in the background through a pluggable transport.
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Email is a rendered email ready to be sent.
+type Email struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers email.
+type Mailer interface {
+	Send(email Email) error
+}
+
+// SMTPMailer sends email through an SMTP server using STARTTLS when the server offers it.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(email Email) error {
+	message, err := email.mime(m.From, time.Now())
+	if err != nil {
+		return err
+	}
+	sender, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := m.Host + ":" + strconv.Itoa(m.Port)
+	return smtp.SendMail(addr, auth, sender.Address, []string{email.To}, message)
+}
+
+// LogMailer is the development transport. It logs each email and, when Dir is set, also writes it there as
+// an .eml file that can be opened in a mail client.
+type LogMailer struct {
+	Dir  string
+	From string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+func (m *LogMailer) Send(email Email) error {
+	slog.Info("Email", "email", email.To, "subject", email.Subject)
+	if m.Dir == "" {
+		return nil
+	}
+
+	now := time.Now()
+	message, err := email.mime(m.From, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), unsafeFileChars.ReplaceAllString(email.To, "_"))
+	return os.WriteFile(filepath.Join(m.Dir, name), message, 0o644)
+}
+
+// NewMailerFromEnv builds the transport selected by MAIL_TRANSPORT: "smtp", or "log" (the default).
+func NewMailerFromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Mangal Chai <orders@mangalchai.com>"
+	}
+
+	switch transport := os.Getenv("MAIL_TRANSPORT"); transport {
+	case "", "log":
+		return &LogMailer{Dir: os.Getenv("MAIL_LOG_DIR"), From: from}, nil
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST must be set when MAIL_TRANSPORT=smtp")
+		}
+		port := 587
+		if raw := os.Getenv("SMTP_PORT"); raw != "" {
+			var err error
+			if port, err = strconv.Atoi(raw); err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT %q", raw)
+			}
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q, use smtp or log", transport)
+	}
+}
+
+// mime encodes the email as a multipart/alternative message with text and HTML parts.
+func (e Email) mime(from string, date time.Time) ([]byte, error) {
+	if _, err := mail.ParseAddress(e.To); err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", e.To, err)
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", e.Text},
+		{"text/html; charset=UTF-8", e.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", e.To)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", e.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
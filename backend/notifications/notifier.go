@@ -0,0 +1,88 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+)
+
+const defaultShopName = "Mangal Chai"
+
+// Notifier renders order event notifications and adds them to the outbox for the Dispatcher to send.
+type Notifier struct {
+	Outbox   repositories.NotificationRepositoryInterface
+	Products repositories.ProductRepositoryInterface
+	ShopName string
+}
+
+// NotifyOrder queues the customer's email for an order event. Notifications never fail the operation that
+// triggered them, so problems are logged; orders without a usable email address are skipped.
+func (n *Notifier) NotifyOrder(ctx context.Context, event string, order models.Order) {
+	if err := n.enqueueEmail(ctx, event, order); err != nil {
+		logging.FromContext(ctx).Error("Failed to queue order email", "event", event, "order_id", order.ID, "error", err)
+	}
+}
+
+func (n *Notifier) enqueueEmail(ctx context.Context, event string, order models.Order) error {
+	to := strings.TrimSpace(order.CustomerInfo.Email)
+	if to == "" {
+		return nil
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return fmt.Errorf("invalid customer email %q", to)
+	}
+	order.CustomerInfo.Email = to
+
+	email, err := renderOrderEmail(event, n.view(ctx, order))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return n.Outbox.Enqueue(ctx, models.Notification{
+		ID:            fmt.Sprintf("ntf_%d", now.UnixNano()),
+		Event:         event,
+		OrderID:       order.ID,
+		Channel:       models.NotificationChannelEmail,
+		To:            email.To,
+		Subject:       email.Subject,
+		HTML:          email.HTML,
+		Text:          email.Text,
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
+
+func (n *Notifier) view(ctx context.Context, order models.Order) orderView {
+	view := orderView{
+		ShopName:     n.ShopName,
+		Order:        order,
+		Shipment:     order.Shipment,
+		Refunded:     order.PaymentStatus == models.PaymentStatusRefunded,
+		RefundFailed: order.PaymentStatus == models.PaymentStatusRefundFailed,
+	}
+	if view.ShopName == "" {
+		view.ShopName = defaultShopName
+	}
+	if len(order.StatusHistory) > 0 {
+		view.Reason = order.StatusHistory[len(order.StatusHistory)-1].Reason
+	}
+
+	for _, item := range order.Items {
+		name := item.ProductID
+		if n.Products != nil {
+			if product, err := n.Products.GetProduct(ctx, item.ProductID); err == nil {
+				name = product.Name
+			}
+		}
+		view.Items = append(view.Items, lineView{Name: name, Quantity: item.Quantity, Total: item.Price * float64(item.Quantity)})
+	}
+	return view
+}
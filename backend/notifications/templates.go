@@ -0,0 +1,72 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+
+	"mangal-chai-backend/models"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+var templateFuncs = map[string]any{
+	"rupees": func(amount float64) string { return fmt.Sprintf("₹%.2f", amount) },
+}
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(templateFuncs).ParseFS(templateFiles, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.New("").Funcs(templateFuncs).ParseFS(templateFiles, "templates/*.txt"))
+)
+
+// subjects holds the email subject for each order event; %s is the order ID.
+var subjects = map[string]string{
+	models.OrderEventPlaced:           "We have received your order %s",
+	models.OrderEventPaymentConfirmed: "Payment received for order %s",
+	models.OrderEventShipped:          "Your order %s is on its way",
+	models.OrderEventDelivered:        "Your order %s has been delivered",
+	models.OrderEventCancelled:        "Your order %s has been cancelled",
+}
+
+// orderView is the data the order templates are rendered with.
+type orderView struct {
+	ShopName string
+	Order    models.Order
+	Items    []lineView
+	Reason   string
+	Shipment *models.Shipment
+	// Refunded and RefundFailed tell a cancelled order's customer whether their payment is on its way back.
+	Refunded     bool
+	RefundFailed bool
+}
+
+type lineView struct {
+	Name     string
+	Quantity int
+	Total    float64
+}
+
+// renderOrderEmail renders the email for an order event.
+func renderOrderEmail(event string, view orderView) (Email, error) {
+	subject, ok := subjects[event]
+	if !ok {
+		return Email{}, fmt.Errorf("no email template for event %q", event)
+	}
+
+	var html, text bytes.Buffer
+	if err := htmlTemplates.ExecuteTemplate(&html, event+".html", view); err != nil {
+		return Email{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, event+".txt", view); err != nil {
+		return Email{}, err
+	}
+	return Email{
+		To:      view.Order.CustomerInfo.Email,
+		Subject: fmt.Sprintf(subject, view.Order.ID),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CartRecoveryStats summarises the reminders sent in a period and the orders they led to.
+type CartRecoveryStats struct {
+	Sent             int64   `bson:"sent"`
+	Recovered        int64   `bson:"recovered"`
+	CouponsUsed      int64   `bson:"coupons_used"`
+	RecoveredRevenue float64 `bson:"recovered_revenue"`
+}
+
+type CartReminderRepositoryInterface interface {
+	CreateReminder(ctx context.Context, reminder models.CartReminder) error
+	MarkRecovered(ctx context.Context, id string, orderID string, orderTotal float64, couponUsed bool, at time.Time) error
+	RecoveryStats(ctx context.Context, from time.Time, to time.Time) (*CartRecoveryStats, error)
+}
+
+type CartReminderRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *CartReminderRepository) CreateReminder(ctx context.Context, reminder models.CartReminder) error {
+	_, err := r.Collection.InsertOne(ctx, reminder)
+	return err
+}
+
+// MarkRecovered records the order a reminded cart turned into. Only the first order counts.
+func (r *CartReminderRepository) MarkRecovered(ctx context.Context, id string, orderID string, orderTotal float64, couponUsed bool, at time.Time) error {
+	filter := bson.M{"id": id, "order_id": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{
+		"order_id":     orderID,
+		"order_total":  orderTotal,
+		"coupon_used":  couponUsed,
+		"recovered_at": at,
+	}}
+	_, err := r.Collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// RecoveryStats counts the reminders sent in [from, to) and how many of them were recovered, whenever the
+// order was placed.
+func (r *CartReminderRepository) RecoveryStats(ctx context.Context, from time.Time, to time.Time) (*CartRecoveryStats, error) {
+	recovered := bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$order_id", nil}}, 1, 0}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"sent_at": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               nil,
+			"sent":              bson.M{"$sum": 1},
+			"recovered":         bson.M{"$sum": recovered},
+			"coupons_used":      bson.M{"$sum": bson.M{"$cond": bson.A{"$coupon_used", 1, 0}}},
+			"recovered_revenue": bson.M{"$sum": "$order_total"},
+		}}},
+	}
+	cursor, err := r.Collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	results := []CartRecoveryStats{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return &CartRecoveryStats{}, nil
+	}
+	return &results[0], nil
+}
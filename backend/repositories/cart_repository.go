@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CartRepositoryInterface interface {
+	GetCart(ctx context.Context, id string) (*models.Cart, error)
+	GetCartByToken(ctx context.Context, token string) (*models.Cart, error)
+	GetCartByCustomer(ctx context.Context, customerID string) (*models.Cart, error)
+	SaveCart(ctx context.Context, cart models.Cart) error
+	DeleteCart(ctx context.Context, id string) error
+	ListAbandoned(ctx context.Context, updatedAfter time.Time, updatedBefore time.Time, limit int64) ([]models.Cart, error)
+	MarkReminded(ctx context.Context, id string, reminderID string) error
+}
+
+type CartRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *CartRepository) GetCart(ctx context.Context, id string) (*models.Cart, error) {
+	return r.findCart(ctx, bson.M{"id": id})
+}
+
+func (r *CartRepository) GetCartByToken(ctx context.Context, token string) (*models.Cart, error) {
+	return r.findCart(ctx, bson.M{"token": token})
+}
+
+func (r *CartRepository) GetCartByCustomer(ctx context.Context, customerID string) (*models.Cart, error) {
+	return r.findCart(ctx, bson.M{"customer_id": customerID})
+}
+
+func (r *CartRepository) findCart(ctx context.Context, filter bson.M) (*models.Cart, error) {
+	var cart models.Cart
+	err := r.Collection.FindOne(ctx, filter).Decode(&cart)
+	if err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (r *CartRepository) SaveCart(ctx context.Context, cart models.Cart) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := r.Collection.ReplaceOne(ctx, bson.M{"id": cart.ID}, cart, opts)
+	return err
+}
+
+func (r *CartRepository) DeleteCart(ctx context.Context, id string) error {
+	_, err := r.Collection.DeleteOne(ctx, bson.M{"id": id})
+	return err
+}
+
+// ListAbandoned returns carts with items, a way to reach the customer and no reminder yet, that were last
+// changed between updatedAfter and updatedBefore.
+func (r *CartRepository) ListAbandoned(ctx context.Context, updatedAfter time.Time, updatedBefore time.Time, limit int64) ([]models.Cart, error) {
+	filter := bson.M{
+		"items.0":     bson.M{"$exists": true},
+		"reminder_id": bson.M{"$exists": false},
+		"updated_at":  bson.M{"$gt": updatedAfter, "$lt": updatedBefore},
+		"$or": bson.A{
+			bson.M{"customer_id": bson.M{"$exists": true}},
+			bson.M{"email": bson.M{"$exists": true}},
+			bson.M{"phone": bson.M{"$exists": true}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: 1}}).SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	carts := []models.Cart{}
+	if err := cursor.All(ctx, &carts); err != nil {
+		return nil, err
+	}
+	return carts, nil
+}
+
+func (r *CartRepository) MarkReminded(ctx context.Context, id string, reminderID string) error {
+	update := bson.M{"$set": bson.M{"reminder_id": reminderID}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
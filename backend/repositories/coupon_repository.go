@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CouponRepositoryInterface interface {
+	CreateCoupon(ctx context.Context, coupon models.Coupon) error
+	Redeem(ctx context.Context, code string, orderID string, at time.Time) (*models.Coupon, error)
+	Release(ctx context.Context, code string, orderID string) error
+}
+
+type CouponRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *CouponRepository) CreateCoupon(ctx context.Context, coupon models.Coupon) error {
+	_, err := r.Collection.InsertOne(ctx, coupon)
+	return err
+}
+
+// Redeem claims an unexpired, unused coupon for an order. It returns mongo.ErrNoDocuments when the code
+// does not exist, has expired or has already been used.
+func (r *CouponRepository) Redeem(ctx context.Context, code string, orderID string, at time.Time) (*models.Coupon, error) {
+	filter := bson.M{
+		"code":       code,
+		"order_id":   bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": at},
+	}
+	update := bson.M{"$set": bson.M{"order_id": orderID, "redeemed_at": at}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var coupon models.Coupon
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&coupon)
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// Release makes a coupon redeemed by orderID usable again.
+func (r *CouponRepository) Release(ctx context.Context, code string, orderID string) error {
+	update := bson.M{"$unset": bson.M{"order_id": "", "redeemed_at": ""}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"code": code, "order_id": orderID}, update)
+	return err
+}
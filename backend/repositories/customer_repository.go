@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CustomerRepositoryInterface interface {
+	GetCustomer(ctx context.Context, id string) (*models.Customer, error)
+	RecordLogin(ctx context.Context, phone string, at time.Time) (*models.Customer, error)
+}
+
+type CustomerRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *CustomerRepository) GetCustomer(ctx context.Context, id string) (*models.Customer, error) {
+	var customer models.Customer
+	err := r.Collection.FindOne(ctx, bson.M{"id": id}).Decode(&customer)
+	if err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// RecordLogin returns the customer with the given phone number, creating them on their first login, and
+// records the login time.
+func (r *CustomerRepository) RecordLogin(ctx context.Context, phone string, at time.Time) (*models.Customer, error) {
+	update := bson.M{
+		"$set": bson.M{"last_login_at": at},
+		"$setOnInsert": bson.M{
+			"id":         fmt.Sprintf("cus_%d", at.UnixNano()),
+			"phone":      phone,
+			"created_at": at,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var customer models.Customer
+	err := r.Collection.FindOneAndUpdate(ctx, bson.M{"phone": phone}, update, opts).Decode(&customer)
+	if err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
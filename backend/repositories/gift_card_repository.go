@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type GiftCardRepositoryInterface interface {
+	CreateGiftCard(ctx context.Context, card models.GiftCard) error
+	GetGiftCard(ctx context.Context, code string) (*models.GiftCard, error)
+	Debit(ctx context.Context, code string, amount float64, at time.Time) (*models.GiftCard, error)
+	Credit(ctx context.Context, code string, amount float64) (*models.GiftCard, error)
+	ListOrderGiftCards(ctx context.Context, orderID string) ([]models.GiftCard, error)
+	VoidGiftCard(ctx context.Context, code string, at time.Time) (*models.GiftCard, error)
+	ReinstateGiftCard(ctx context.Context, code string) (*models.GiftCard, error)
+}
+
+type GiftCardRepository struct {
+	Collection *mongo.Collection
+}
+
+// CreateGiftCard stores a new card. It returns a duplicate key error if the code is taken or the order line
+// already has its card.
+func (r *GiftCardRepository) CreateGiftCard(ctx context.Context, card models.GiftCard) error {
+	_, err := r.Collection.InsertOne(ctx, card)
+	return err
+}
+
+func (r *GiftCardRepository) GetGiftCard(ctx context.Context, code string) (*models.GiftCard, error) {
+	var card models.GiftCard
+	err := r.Collection.FindOne(ctx, bson.M{"code": code}).Decode(&card)
+	if err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// Debit takes amount off an unexpired card's balance and returns the card after the debit. It returns
+// mongo.ErrNoDocuments when the card does not exist, has expired or its balance is less than amount.
+func (r *GiftCardRepository) Debit(ctx context.Context, code string, amount float64, at time.Time) (*models.GiftCard, error) {
+	filter := bson.M{
+		"code":    code,
+		"balance": bson.M{"$gte": amount},
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": at}},
+		},
+	}
+	return r.updateBalance(ctx, filter, -amount)
+}
+
+// Credit adds amount to a card's balance, whether or not it has expired, and returns the card after.
+func (r *GiftCardRepository) Credit(ctx context.Context, code string, amount float64) (*models.GiftCard, error) {
+	return r.updateBalance(ctx, bson.M{"code": code}, amount)
+}
+
+// ListOrderGiftCards returns the cards bought in an order, in the order they were issued.
+func (r *GiftCardRepository) ListOrderGiftCards(ctx context.Context, orderID string) ([]models.GiftCard, error) {
+	cards := []models.GiftCard{}
+	opts := options.Find().SetSort(bson.D{{Key: "order_line", Value: 1}})
+	cursor, err := r.Collection.Find(ctx, bson.M{"order_id": orderID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &cards); err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+// VoidGiftCard takes a card's initial balance off it and returns the card after. It returns
+// mongo.ErrNoDocuments when the card is already void or some of that balance has been spent.
+func (r *GiftCardRepository) VoidGiftCard(ctx context.Context, code string, at time.Time) (*models.GiftCard, error) {
+	filter := bson.M{
+		"code":      code,
+		"voided_at": bson.M{"$exists": false},
+		"$expr":     bson.M{"$gte": bson.A{"$balance", "$initial_balance"}},
+	}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"balance":   bson.M{"$subtract": bson.A{"$balance", "$initial_balance"}},
+		"voided_at": at,
+	}}}}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+// ReinstateGiftCard gives a voided card its initial balance back and returns the card after.
+func (r *GiftCardRepository) ReinstateGiftCard(ctx context.Context, code string) (*models.GiftCard, error) {
+	filter := bson.M{"code": code, "voided_at": bson.M{"$exists": true}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"balance": bson.M{"$add": bson.A{"$balance", "$initial_balance"}}}}},
+		{{Key: "$unset", Value: "voided_at"}},
+	}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+func (r *GiftCardRepository) updateBalance(ctx context.Context, filter bson.M, delta float64) (*models.GiftCard, error) {
+	return r.findOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"balance": delta}})
+}
+
+func (r *GiftCardRepository) findOneAndUpdate(ctx context.Context, filter bson.M, update interface{}) (*models.GiftCard, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var card models.GiftCard
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&card)
+	if err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type InvoiceRepositoryInterface interface {
+	NextSequence(ctx context.Context, financialYear string) (int, error)
+	CreateInvoice(ctx context.Context, invoice models.Invoice) error
+	GetInvoiceByOrder(ctx context.Context, orderID string) (*models.Invoice, error)
+	SetFile(ctx context.Context, orderID string, file string) error
+}
+
+// InvoiceRepository stores invoices, with the running invoice number of each financial year kept in
+// Counters.
+type InvoiceRepository struct {
+	Collection *mongo.Collection
+	Counters   *mongo.Collection
+}
+
+// NextSequence takes the next invoice number of the financial year, starting from 1.
+func (r *InvoiceRepository) NextSequence(ctx context.Context, financialYear string) (int, error) {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var counter struct {
+		Sequence int `bson:"sequence"`
+	}
+	err := r.Counters.FindOneAndUpdate(ctx,
+		bson.M{"_id": financialYear},
+		bson.M{"$inc": bson.M{"sequence": 1}},
+		opts,
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Sequence, nil
+}
+
+// CreateInvoice stores a new invoice. It returns a duplicate key error if the order already has one.
+func (r *InvoiceRepository) CreateInvoice(ctx context.Context, invoice models.Invoice) error {
+	_, err := r.Collection.InsertOne(ctx, invoice)
+	return err
+}
+
+func (r *InvoiceRepository) GetInvoiceByOrder(ctx context.Context, orderID string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.Collection.FindOne(ctx, bson.M{"order_id": orderID}).Decode(&invoice)
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// SetFile records where an order's invoice PDF is stored.
+func (r *InvoiceRepository) SetFile(ctx context.Context, orderID string, file string) error {
+	result, err := r.Collection.UpdateOne(ctx, bson.M{"order_id": orderID}, bson.M{"$set": bson.M{"file": file}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
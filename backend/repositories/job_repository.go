@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type JobRepositoryInterface interface {
+	Enqueue(ctx context.Context, request models.JobRequest) error
+	ClaimNext(ctx context.Context, now time.Time, lease time.Duration) (*models.Job, error)
+	Complete(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, runAt time.Time, lastError string) error
+	Bury(ctx context.Context, job models.Job, lastError string) error
+	ListJobs(ctx context.Context, status string, limit int64) ([]models.Job, error)
+	ListDeadJobs(ctx context.Context, limit int64) ([]models.Job, error)
+	Requeue(ctx context.Context, id string) (*models.Job, error)
+}
+
+// JobRepository stores the job queue in Collection and jobs that ran out of attempts in DeadLetters.
+type JobRepository struct {
+	Collection  *mongo.Collection
+	DeadLetters *mongo.Collection
+}
+
+// Enqueue adds a job for request. A request whose ID has already been enqueued is ignored, which makes
+// relaying and scheduling idempotent.
+func (r *JobRepository) Enqueue(ctx context.Context, request models.JobRequest) error {
+	now := time.Now()
+	runAt := request.RunAt
+	if runAt.IsZero() {
+		runAt = now
+	}
+	job := models.Job{
+		ID:        request.ID,
+		Type:      request.Type,
+		Payload:   request.Payload,
+		Status:    models.JobStatusQueued,
+		RunAt:     runAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	_, err := r.Collection.InsertOne(ctx, job)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// ClaimNext claims the job that has been due longest, including running jobs whose claim has expired
+// because their worker died, and holds it for lease. It returns mongo.ErrNoDocuments when nothing is due.
+func (r *JobRepository) ClaimNext(ctx context.Context, now time.Time, lease time.Duration) (*models.Job, error) {
+	filter := bson.M{
+		"status": bson.M{"$in": []string{models.JobStatusQueued, models.JobStatusRunning}},
+		"run_at": bson.M{"$lte": now},
+	}
+	update := bson.M{
+		"$set": bson.M{"status": models.JobStatusRunning, "run_at": now.Add(lease), "updated_at": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.Job
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *JobRepository) Complete(ctx context.Context, id string) error {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"status": models.JobStatusSucceeded, "completed_at": now, "updated_at": now}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+func (r *JobRepository) Retry(ctx context.Context, id string, runAt time.Time, lastError string) error {
+	update := bson.M{"$set": bson.M{
+		"status":     models.JobStatusQueued,
+		"run_at":     runAt,
+		"last_error": lastError,
+		"updated_at": time.Now(),
+	}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// Bury moves a job to the dead-letter collection. The copy is written before the original is removed, so a
+// crash in between leaves the job in both places rather than in neither.
+func (r *JobRepository) Bury(ctx context.Context, job models.Job, lastError string) error {
+	job.Status = models.JobStatusDead
+	job.LastError = lastError
+	job.UpdatedAt = time.Now()
+	opts := options.Replace().SetUpsert(true)
+	if _, err := r.DeadLetters.ReplaceOne(ctx, bson.M{"id": job.ID}, job, opts); err != nil {
+		return err
+	}
+	_, err := r.Collection.DeleteOne(ctx, bson.M{"id": job.ID})
+	return err
+}
+
+// ListJobs returns queued and running jobs in the order they are due, or the most recent jobs with the
+// given status.
+func (r *JobRepository) ListJobs(ctx context.Context, status string, limit int64) ([]models.Job, error) {
+	filter := bson.M{}
+	sort := bson.D{{Key: "updated_at", Value: -1}}
+	if status != "" {
+		filter["status"] = status
+	}
+	if status == models.JobStatusQueued || status == models.JobStatusRunning {
+		sort = bson.D{{Key: "run_at", Value: 1}}
+	}
+	return findJobs(ctx, r.Collection, filter, sort, limit)
+}
+
+func (r *JobRepository) ListDeadJobs(ctx context.Context, limit int64) ([]models.Job, error) {
+	return findJobs(ctx, r.DeadLetters, bson.M{}, bson.D{{Key: "updated_at", Value: -1}}, limit)
+}
+
+// Requeue moves a dead job back to the queue with its attempts reset so it runs again straight away.
+func (r *JobRepository) Requeue(ctx context.Context, id string) (*models.Job, error) {
+	var job models.Job
+	if err := r.DeadLetters.FindOne(ctx, bson.M{"id": id}).Decode(&job); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job.Status = models.JobStatusQueued
+	job.Attempts = 0
+	job.RunAt = now
+	job.UpdatedAt = now
+	opts := options.Replace().SetUpsert(true)
+	if _, err := r.Collection.ReplaceOne(ctx, bson.M{"id": id}, job, opts); err != nil {
+		return nil, err
+	}
+	if _, err := r.DeadLetters.DeleteOne(ctx, bson.M{"id": id}); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func findJobs(ctx context.Context, collection *mongo.Collection, filter bson.M, sort bson.D, limit int64) ([]models.Job, error) {
+	opts := options.Find().SetSort(sort).SetLimit(limit)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []models.Job{}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
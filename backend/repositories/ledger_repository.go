@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LedgerRepositoryInterface interface {
+	Record(ctx context.Context, entry models.LedgerEntry) error
+	ListEntries(ctx context.Context, account string, accountID string, limit int64) ([]models.LedgerEntry, error)
+}
+
+// LedgerRepository stores the movements of gift card and wallet balances. Entries are never changed.
+type LedgerRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *LedgerRepository) Record(ctx context.Context, entry models.LedgerEntry) error {
+	_, err := r.Collection.InsertOne(ctx, entry)
+	return err
+}
+
+// ListEntries returns up to limit entries, newest first. An empty account or accountID matches any.
+func (r *LedgerRepository) ListEntries(ctx context.Context, account string, accountID string, limit int64) ([]models.LedgerEntry, error) {
+	filter := bson.M{}
+	if account != "" {
+		filter["account"] = account
+	}
+	if accountID != "" {
+		filter["account_id"] = accountID
+	}
+	entries := []models.LedgerEntry{}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "id", Value: -1}}).SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
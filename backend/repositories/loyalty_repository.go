@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoyaltyRepositoryInterface interface {
+	Record(ctx context.Context, entry models.PointsEntry) error
+	Balance(ctx context.Context, customerID string, at time.Time) (int, error)
+	ListEntries(ctx context.Context, customerID string, limit int64) ([]models.PointsEntry, error)
+	ListOrderEntries(ctx context.Context, orderID string) ([]models.PointsEntry, error)
+	ListLots(ctx context.Context, customerID string, at time.Time) ([]models.PointsEntry, error)
+	TakeFromLot(ctx context.Context, id string, points int, at time.Time) error
+	ReturnToLot(ctx context.Context, id string, points int) error
+	ListExpiredLots(ctx context.Context, before time.Time, limit int64) ([]models.PointsEntry, error)
+	ExpireLot(ctx context.Context, id string) (*models.PointsEntry, error)
+}
+
+// LoyaltyRepository stores the loyalty points ledger. A customer's balance is what is left of their
+// unexpired credits.
+type LoyaltyRepository struct {
+	Collection *mongo.Collection
+}
+
+// Record stores a ledger entry. It returns a duplicate key error if the order has already earned points.
+func (r *LoyaltyRepository) Record(ctx context.Context, entry models.PointsEntry) error {
+	_, err := r.Collection.InsertOne(ctx, entry)
+	return err
+}
+
+// Balance returns the points a customer can spend at the given time.
+func (r *LoyaltyRepository) Balance(ctx context.Context, customerID string, at time.Time) (int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: spendable(customerID, at)}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "balance": bson.M{"$sum": "$remaining"}}}},
+	}
+	cursor, err := r.Collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	var results []struct {
+		Balance int `bson:"balance"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0].Balance, nil
+}
+
+// ListEntries returns up to limit of a customer's entries, newest first.
+func (r *LoyaltyRepository) ListEntries(ctx context.Context, customerID string, limit int64) ([]models.PointsEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "id", Value: -1}}).SetLimit(limit)
+	return r.find(ctx, bson.M{"customer_id": customerID}, opts)
+}
+
+func (r *LoyaltyRepository) ListOrderEntries(ctx context.Context, orderID string) ([]models.PointsEntry, error) {
+	return r.find(ctx, bson.M{"order_id": orderID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+}
+
+// ListLots returns a customer's credits with points left to spend at the given time, soonest to expire
+// first.
+func (r *LoyaltyRepository) ListLots(ctx context.Context, customerID string, at time.Time) ([]models.PointsEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "expires_at", Value: 1}, {Key: "created_at", Value: 1}})
+	return r.find(ctx, spendable(customerID, at), opts)
+}
+
+// TakeFromLot spends points from an unexpired lot. It returns mongo.ErrNoDocuments if the lot has expired
+// or has fewer points left.
+func (r *LoyaltyRepository) TakeFromLot(ctx context.Context, id string, points int, at time.Time) error {
+	filter := bson.M{"id": id, "remaining": bson.M{"$gte": points}, "expires_at": bson.M{"$gt": at}}
+	result, err := r.Collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"remaining": -points}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ReturnToLot puts back points taken from a lot.
+func (r *LoyaltyRepository) ReturnToLot(ctx context.Context, id string, points int) error {
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$inc": bson.M{"remaining": points}})
+	return err
+}
+
+// ListExpiredLots returns up to limit lots that expired before the given time with points left.
+func (r *LoyaltyRepository) ListExpiredLots(ctx context.Context, before time.Time, limit int64) ([]models.PointsEntry, error) {
+	filter := bson.M{"remaining": bson.M{"$gt": 0}, "expires_at": bson.M{"$lte": before}}
+	return r.find(ctx, filter, options.Find().SetSort(bson.D{{Key: "expires_at", Value: 1}}).SetLimit(limit))
+}
+
+// ExpireLot clears the points left in a lot and returns the lot as it was before. It returns
+// mongo.ErrNoDocuments if nothing was left.
+func (r *LoyaltyRepository) ExpireLot(ctx context.Context, id string) (*models.PointsEntry, error) {
+	filter := bson.M{"id": id, "remaining": bson.M{"$gt": 0}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	var lot models.PointsEntry
+	err := r.Collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"remaining": 0}}, opts).Decode(&lot)
+	if err != nil {
+		return nil, err
+	}
+	return &lot, nil
+}
+
+func (r *LoyaltyRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.PointsEntry, error) {
+	entries := []models.PointsEntry{}
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func spendable(customerID string, at time.Time) bson.M {
+	return bson.M{"customer_id": customerID, "remaining": bson.M{"$gt": 0}, "expires_at": bson.M{"$gt": at}}
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NotificationRepositoryInterface is the notification outbox.
+type NotificationRepositoryInterface interface {
+	Enqueue(ctx context.Context, notification models.Notification) error
+	ClaimDue(ctx context.Context, channel string, now time.Time, lease time.Duration) (*models.Notification, error)
+	MarkSent(ctx context.Context, id string, sentAt time.Time) error
+	MarkFailed(ctx context.Context, id string, lastError string, retryAt time.Time, giveUp bool) error
+}
+
+type NotificationRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *NotificationRepository) Enqueue(ctx context.Context, notification models.Notification) error {
+	_, err := r.Collection.InsertOne(ctx, notification)
+	return err
+}
+
+// ClaimDue claims the oldest notification on channel that is due for an attempt, including ones whose
+// previous claim has expired, and holds it for lease. It returns mongo.ErrNoDocuments when nothing is due.
+func (r *NotificationRepository) ClaimDue(ctx context.Context, channel string, now time.Time, lease time.Duration) (*models.Notification, error) {
+	filter := bson.M{
+		"channel":         channel,
+		"status":          bson.M{"$in": []string{models.NotificationStatusPending, models.NotificationStatusSending}},
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	update := bson.M{
+		"$set": bson.M{"status": models.NotificationStatusSending, "next_attempt_at": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var notification models.Notification
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&notification)
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *NotificationRepository) MarkSent(ctx context.Context, id string, sentAt time.Time) error {
+	update := bson.M{
+		"$set":   bson.M{"status": models.NotificationStatusSent, "sent_at": sentAt},
+		"$unset": bson.M{"last_error": ""},
+	}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// MarkFailed records a failed attempt. The notification is retried at retryAt unless giveUp is set, in
+// which case it is left failed.
+func (r *NotificationRepository) MarkFailed(ctx context.Context, id string, lastError string, retryAt time.Time, giveUp bool) error {
+	status := models.NotificationStatusPending
+	if giveUp {
+		status = models.NotificationStatusFailed
+	}
+	update := bson.M{"$set": bson.M{"status": status, "last_error": lastError, "next_attempt_at": retryAt}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
@@ -2,32 +2,427 @@ package repositories
 
 import (
 	"context"
+	"regexp"
+	"strings"
+	"time"
 
 	"mangal-chai-backend/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type OrderRepositoryInterface interface {
-	CreateOrder(order models.Order) error
-	GetOrder(id string) (*models.Order, error)
+	CreateOrder(ctx context.Context, order models.Order) error
+	GetOrder(ctx context.Context, id string) (*models.Order, error)
+	TransitionStatus(ctx context.Context, id string, from []string, change models.StatusChange, outbox []models.JobRequest) (*models.Order, error)
+	SetRefund(ctx context.Context, id string, refundID string, paymentStatus string) error
+	ReserveRefund(ctx context.Context, id string, amount float64) error
+	ReleaseRefund(ctx context.Context, id string, amount float64) error
+	SetPaymentGatewayOrder(ctx context.Context, id string, gatewayOrderID string, amount int64) error
+	RecordPayment(ctx context.Context, gatewayOrderID string, paymentID string, method string) (*models.Order, error)
+	RecordSubscriptionPayment(ctx context.Context, subscriptionID string, paymentID string, method string) (*models.Order, error)
+	ListOrders(ctx context.Context, filter OrderFilter) ([]models.Order, int64, error)
+	SetShipment(ctx context.Context, id string, shipment models.Shipment) error
+	GetOrderByTrackingNumber(ctx context.Context, trackingNumber string) (*models.Order, error)
+	AddTrackingEvents(ctx context.Context, id string, status string, events []models.TrackingEvent, shippedAt *time.Time) error
+	ListTrackedShipments(ctx context.Context, provider string, limit int64) ([]models.Order, error)
+	AddMessage(ctx context.Context, id string, message models.MessageDelivery) error
+	UpdateMessageStatus(ctx context.Context, providerMessageID string, status string, errorMessage string) error
+	PendingOutbox(ctx context.Context, limit int64) ([]models.Order, error)
+	ClearOutbox(ctx context.Context, id string, jobIDs []string) error
+	ListUnpaidBefore(ctx context.Context, cutoff time.Time, limit int64) ([]models.Order, error)
+	ListUnpaidBySubscription(ctx context.Context, subscriptionID string, limit int64) ([]models.Order, error)
+}
+
+// OrderFilter selects orders for the admin order list. Zero values leave a field unfiltered; From is
+// inclusive and To exclusive.
+type OrderFilter struct {
+	Status        []string
+	PaymentStatus string
+	From          time.Time
+	To            time.Time
+	Phone         string
+	Email         string
+	MinAmount     float64
+	MaxAmount     float64
+	ProductID     string
+	SortField     string
+	SortAscending bool
+	Skip          int64
+	Limit         int64
 }
 
 type OrderRepository struct {
 	Collection *mongo.Collection
 }
 
-func (r *OrderRepository) CreateOrder(order models.Order) error {
-	_, err := r.Collection.InsertOne(context.TODO(), order)
+func (r *OrderRepository) CreateOrder(ctx context.Context, order models.Order) error {
+	_, err := r.Collection.InsertOne(ctx, order)
 	return err
 }
 
-func (r *OrderRepository) GetOrder(id string) (*models.Order, error) {
+func (r *OrderRepository) GetOrder(ctx context.Context, id string) (*models.Order, error) {
+	var order models.Order
+	err := r.Collection.FindOne(ctx, bson.M{"id": id}).Decode(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// TransitionStatus moves an order to change.Status and appends change to its history, but only if the order
+// is currently in one of the from statuses. It returns mongo.ErrNoDocuments if the order does not exist or
+// is in any other status, which makes concurrent transitions safe. Jobs in outbox, such as the customer's
+// notification of the change, are added to the order's outbox in the same update.
+func (r *OrderRepository) TransitionStatus(ctx context.Context, id string, from []string, change models.StatusChange, outbox []models.JobRequest) (*models.Order, error) {
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now()
+	}
+	filter := bson.M{"id": id, "status": bson.M{"$in": from}}
+	push := bson.M{"status_history": change}
+	if len(outbox) > 0 {
+		push["outbox"] = bson.M{"$each": outbox}
+	}
+	update := bson.M{
+		"$set":  bson.M{"status": change.Status},
+		"$push": push,
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
 	var order models.Order
-	err := r.Collection.FindOne(context.TODO(), bson.M{"id": id}).Decode(&order)
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&order)
 	if err != nil {
 		return nil, err
 	}
 	return &order, nil
-}
\ No newline at end of file
+}
+
+// SetRefund records the outcome of refunding an order's payment.
+func (r *OrderRepository) SetRefund(ctx context.Context, id string, refundID string, paymentStatus string) error {
+	update := bson.M{"$set": bson.M{"refund_id": refundID, "payment_status": paymentStatus}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// refundTolerance absorbs floating point error when comparing refunded rupee amounts with the order total.
+const refundTolerance = 0.005
+
+// ReserveRefund adds amount to what has been refunded of the order, provided that does not take it past the
+// order's total. It returns mongo.ErrNoDocuments otherwise, so concurrent refunds cannot over-refund.
+func (r *OrderRepository) ReserveRefund(ctx context.Context, id string, amount float64) error {
+	filter := bson.M{
+		"id": id,
+		"$expr": bson.M{"$lte": bson.A{
+			bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$refunded_amount", 0}}, amount}},
+			bson.M{"$add": bson.A{"$total_amount", refundTolerance}},
+		}},
+	}
+	result, err := r.Collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"refunded_amount": amount}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ReleaseRefund gives back an amount reserved by ReserveRefund for a refund that failed.
+func (r *OrderRepository) ReleaseRefund(ctx context.Context, id string, amount float64) error {
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$inc": bson.M{"refunded_amount": -amount}})
+	return err
+}
+
+// SetPaymentGatewayOrder makes gatewayOrderID, for amount paise, the order's latest gateway order. Earlier
+// gateway orders stay on the order, so a payment captured on one of them is still recorded.
+func (r *OrderRepository) SetPaymentGatewayOrder(ctx context.Context, id string, gatewayOrderID string, amount int64) error {
+	update := bson.M{
+		"$set":      bson.M{"payment_gateway_order_id": gatewayOrderID, "payment_gateway_amount": amount},
+		"$addToSet": bson.M{"payment_gateway_order_ids": gatewayOrderID},
+	}
+	result, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// RecordPayment marks the order paid through any of its gateway orders as paid and returns it as it was
+// before the update. It returns mongo.ErrNoDocuments if there is no such order or it is already paid,
+// so replayed gateway events are ignored.
+func (r *OrderRepository) RecordPayment(ctx context.Context, gatewayOrderID string, paymentID string, method string) (*models.Order, error) {
+	filter := bson.M{"payment_gateway_order_ids": gatewayOrderID, "payment_id": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{
+		"payment_status": models.PaymentStatusPaid,
+		"payment_id":     paymentID,
+		"payment_method": method,
+	}}
+
+	var order models.Order
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update).Decode(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// RecordSubscriptionPayment marks the subscription's oldest unpaid pending order as paid and returns it as
+// it was before the update. It returns mongo.ErrNoDocuments if no order of the subscription is waiting for
+// a payment.
+func (r *OrderRepository) RecordSubscriptionPayment(ctx context.Context, subscriptionID string, paymentID string, method string) (*models.Order, error) {
+	filter := bson.M{
+		"subscription_id": subscriptionID,
+		"status":          models.OrderStatusPending,
+		"payment_id":      bson.M{"$exists": false},
+	}
+	update := bson.M{"$set": bson.M{
+		"payment_status": models.PaymentStatusPaid,
+		"payment_id":     paymentID,
+		"payment_method": method,
+	}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "order_date", Value: 1}})
+
+	var order models.Order
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *OrderRepository) SetShipment(ctx context.Context, id string, shipment models.Shipment) error {
+	update := bson.M{"$set": bson.M{"shipment": shipment}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+func (r *OrderRepository) GetOrderByTrackingNumber(ctx context.Context, trackingNumber string) (*models.Order, error) {
+	var order models.Order
+	err := r.Collection.FindOne(ctx, bson.M{"shipment.tracking_number": trackingNumber}).Decode(&order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// AddTrackingEvents records tracking events for an order's shipment, skipping any it already has, sets the
+// shipment's latest status and marks it tracked now. shippedAt, if given, is kept unless the shipment was
+// already shipped earlier.
+func (r *OrderRepository) AddTrackingEvents(ctx context.Context, id string, status string, events []models.TrackingEvent, shippedAt *time.Time) error {
+	update := bson.M{
+		"$set":      bson.M{"shipment.status": status, "shipment.tracked_at": time.Now()},
+		"$addToSet": bson.M{"shipment.events": bson.M{"$each": events}},
+	}
+	if shippedAt != nil {
+		update["$min"] = bson.M{"shipment.shipped_at": *shippedAt}
+	}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// ListTrackedShipments lists packed and shipped orders whose shipment was booked with provider, those
+// tracked longest ago first.
+func (r *OrderRepository) ListTrackedShipments(ctx context.Context, provider string, limit int64) ([]models.Order, error) {
+	filter := bson.M{
+		"status":                   bson.M{"$in": []string{models.OrderStatusPacked, models.OrderStatusShipped}},
+		"shipment.provider":        provider,
+		"shipment.tracking_number": bson.M{"$exists": true},
+		"shipment.status":          bson.M{"$ne": models.TrackingStatusCancelled},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "shipment.tracked_at", Value: 1}}).SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *OrderRepository) AddMessage(ctx context.Context, id string, message models.MessageDelivery) error {
+	update := bson.M{"$push": bson.M{"messages": message}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// UpdateMessageStatus records a delivery status reported for a message sent about an order. It returns
+// mongo.ErrNoDocuments if no order has a message with that provider ID.
+func (r *OrderRepository) UpdateMessageStatus(ctx context.Context, providerMessageID string, status string, errorMessage string) error {
+	filter := bson.M{"messages.provider_message_id": providerMessageID}
+	update := bson.M{"$set": bson.M{
+		"messages.$.status":     status,
+		"messages.$.error":      errorMessage,
+		"messages.$.updated_at": time.Now(),
+	}}
+	result, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// PendingOutbox returns orders with outbox entries still to be relayed to the job queue. Only the order ID
+// and outbox are loaded.
+func (r *OrderRepository) PendingOutbox(ctx context.Context, limit int64) ([]models.Order, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"id": 1, "outbox": 1}).
+		SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, bson.M{"outbox.id": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ClearOutbox removes relayed entries from an order's outbox.
+func (r *OrderRepository) ClearOutbox(ctx context.Context, id string, jobIDs []string) error {
+	update := bson.M{"$pull": bson.M{"outbox": bson.M{"id": bson.M{"$in": jobIDs}}}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// ListOrders returns one page of the orders matching filter along with the total number of matches.
+func (r *OrderRepository) ListOrders(ctx context.Context, filter OrderFilter) ([]models.Order, int64, error) {
+	query := orderQuery(filter)
+	total, err := r.Collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sortField := filter.SortField
+	if sortField == "" {
+		sortField = "order_date"
+	}
+	direction := -1
+	if filter.SortAscending {
+		direction = 1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "id", Value: direction}}).
+		SetSkip(filter.Skip).
+		SetLimit(filter.Limit)
+
+	cursor, err := r.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func orderQuery(filter OrderFilter) bson.M {
+	query := bson.M{}
+	if len(filter.Status) > 0 {
+		query["status"] = bson.M{"$in": filter.Status}
+	}
+	if filter.PaymentStatus != "" {
+		query["payment_status"] = filter.PaymentStatus
+	}
+
+	orderDate := bson.M{}
+	if !filter.From.IsZero() {
+		orderDate["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		orderDate["$lt"] = filter.To
+	}
+	if len(orderDate) > 0 {
+		query["order_date"] = orderDate
+	}
+
+	amount := bson.M{}
+	if filter.MinAmount > 0 {
+		amount["$gte"] = filter.MinAmount
+	}
+	if filter.MaxAmount > 0 {
+		amount["$lte"] = filter.MaxAmount
+	}
+	if len(amount) > 0 {
+		query["total_amount"] = amount
+	}
+
+	if filter.Phone != "" {
+		query["customer_info.phone"] = bson.M{"$regex": phonePattern(filter.Phone)}
+	}
+	if filter.Email != "" {
+		query["customer_info.email"] = bson.M{"$regex": "^" + regexp.QuoteMeta(strings.TrimSpace(filter.Email)) + "$", "$options": "i"}
+	}
+	if filter.ProductID != "" {
+		query["items.product_id"] = filter.ProductID
+	}
+	return query
+}
+
+// phonePattern matches phone numbers ending in the given digits however they were formatted when the
+// order was placed, e.g. "98765 43210" and "+91-9876543210" both match "9876543210".
+func phonePattern(phone string) string {
+	var pattern strings.Builder
+	for _, r := range phone {
+		if r < '0' || r > '9' {
+			continue
+		}
+		if pattern.Len() > 0 {
+			pattern.WriteString(`\D*`)
+		}
+		pattern.WriteRune(r)
+	}
+	return pattern.String() + `\D*$`
+}
+
+// ListUnpaidBefore returns the oldest pending orders placed before cutoff that have no recorded payment.
+// Subscription orders are left out: they are paid when the gateway charges the subscription, which need
+// not be within any payment window.
+func (r *OrderRepository) ListUnpaidBefore(ctx context.Context, cutoff time.Time, limit int64) ([]models.Order, error) {
+	filter := bson.M{
+		"status":          models.OrderStatusPending,
+		"payment_id":      bson.M{"$exists": false},
+		"order_date":      bson.M{"$lt": cutoff},
+		"subscription_id": bson.M{"$exists": false},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "order_date", Value: 1}}).SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ListUnpaidBySubscription returns the oldest pending orders of a subscription that no charge has paid.
+func (r *OrderRepository) ListUnpaidBySubscription(ctx context.Context, subscriptionID string, limit int64) ([]models.Order, error) {
+	filter := bson.M{
+		"subscription_id": subscriptionID,
+		"status":          models.OrderStatusPending,
+		"payment_id":      bson.M{"$exists": false},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "order_date", Value: 1}}).SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OTPRepositoryInterface interface {
+	SaveOTP(ctx context.Context, otp models.OneTimePassword) error
+	GetOTP(ctx context.Context, phone string, purpose string) (*models.OneTimePassword, error)
+	IncrementOTPAttempts(ctx context.Context, phone string, purpose string) error
+	DeleteOTP(ctx context.Context, phone string, purpose string) error
+}
+
+// OTPRepository keeps at most one pending OTP per phone number and purpose.
+type OTPRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *OTPRepository) SaveOTP(ctx context.Context, otp models.OneTimePassword) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := r.Collection.ReplaceOne(ctx, bson.M{"phone": otp.Phone, "purpose": otp.Purpose}, otp, opts)
+	return err
+}
+
+func (r *OTPRepository) GetOTP(ctx context.Context, phone string, purpose string) (*models.OneTimePassword, error) {
+	var otp models.OneTimePassword
+	err := r.Collection.FindOne(ctx, bson.M{"phone": phone, "purpose": purpose}).Decode(&otp)
+	if err != nil {
+		return nil, err
+	}
+	return &otp, nil
+}
+
+func (r *OTPRepository) IncrementOTPAttempts(ctx context.Context, phone string, purpose string) error {
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"phone": phone, "purpose": purpose}, update)
+	return err
+}
+
+func (r *OTPRepository) DeleteOTP(ctx context.Context, phone string, purpose string) error {
+	_, err := r.Collection.DeleteOne(ctx, bson.M{"phone": phone, "purpose": purpose})
+	return err
+}
@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"context"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PreferenceRepositoryInterface interface {
+	GetPreference(ctx context.Context, phone string) (*models.MessagingPreference, error)
+	SavePreference(ctx context.Context, preference models.MessagingPreference) error
+}
+
+type PreferenceRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *PreferenceRepository) GetPreference(ctx context.Context, phone string) (*models.MessagingPreference, error) {
+	var preference models.MessagingPreference
+	err := r.Collection.FindOne(ctx, bson.M{"phone": phone}).Decode(&preference)
+	if err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+func (r *PreferenceRepository) SavePreference(ctx context.Context, preference models.MessagingPreference) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := r.Collection.ReplaceOne(ctx, bson.M{"phone": preference.Phone}, preference, opts)
+	return err
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"errors"
 
 	"mangal-chai-backend/models"
 
@@ -9,53 +10,59 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ErrInsufficientStock is returned when a product does not have enough stock left to reserve.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
 type ProductRepositoryInterface interface {
-	GetProducts() ([]models.Product, error)
-	GetProduct(id string) (*models.Product, error)
-	GetProductsByCategory(category string) ([]models.Product, error)
-	GetCategories() ([]string, error)
-	SeedProducts(products []interface{}) error
+	GetProducts(ctx context.Context) ([]models.Product, error)
+	GetProduct(ctx context.Context, id string) (*models.Product, error)
+	GetProductsByCategory(ctx context.Context, category string) ([]models.Product, error)
+	GetCategories(ctx context.Context) ([]string, error)
+	UpsertProducts(ctx context.Context, entries []models.CatalogEntry) error
+	ReserveStock(ctx context.Context, id string, quantity int) error
+	ReleaseStock(ctx context.Context, id string, quantity int) error
+	SetRating(ctx context.Context, id string, rating float64, count int) error
 }
 
 type ProductRepository struct {
 	Collection *mongo.Collection
 }
 
-func (r *ProductRepository) GetProducts() ([]models.Product, error) {
+func (r *ProductRepository) GetProducts(ctx context.Context) ([]models.Product, error) {
 	var products []models.Product
-	cursor, err := r.Collection.Find(context.TODO(), bson.M{})
+	cursor, err := r.Collection.Find(ctx, bson.M{})
 	if err != nil {
 		return nil, err
 	}
-	if err = cursor.All(context.TODO(), &products); err != nil {
+	if err = cursor.All(ctx, &products); err != nil {
 		return nil, err
 	}
 	return products, nil
 }
 
-func (r *ProductRepository) GetProduct(id string) (*models.Product, error) {
+func (r *ProductRepository) GetProduct(ctx context.Context, id string) (*models.Product, error) {
 	var product models.Product
-	err := r.Collection.FindOne(context.TODO(), bson.M{"id": id}).Decode(&product)
+	err := r.Collection.FindOne(ctx, bson.M{"id": id}).Decode(&product)
 	if err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
-func (r *ProductRepository) GetProductsByCategory(category string) ([]models.Product, error) {
+func (r *ProductRepository) GetProductsByCategory(ctx context.Context, category string) ([]models.Product, error) {
 	var products []models.Product
-	cursor, err := r.Collection.Find(context.TODO(), bson.M{"category": category})
+	cursor, err := r.Collection.Find(ctx, bson.M{"category": category})
 	if err != nil {
 		return nil, err
 	}
-	if err = cursor.All(context.TODO(), &products); err != nil {
+	if err = cursor.All(ctx, &products); err != nil {
 		return nil, err
 	}
 	return products, nil
 }
 
-func (r *ProductRepository) GetCategories() ([]string, error) {
-	categories, err := r.Collection.Distinct(context.TODO(), "category", bson.M{})
+func (r *ProductRepository) GetCategories(ctx context.Context) ([]string, error) {
+	categories, err := r.Collection.Distinct(ctx, "category", bson.M{})
 	if err != nil {
 		return nil, err
 	}
@@ -67,16 +74,88 @@ func (r *ProductRepository) GetCategories() ([]string, error) {
 
 }
 
-func (r *ProductRepository) SeedProducts(products []interface{}) error {
-	count, err := r.Collection.CountDocuments(context.TODO(), bson.M{})
+// UpsertProducts writes the catalog fields of each entry to the product matched by id, inserting the ones
+// that do not exist yet. Stock is only written when the entry has it, so stock reserved by orders since the
+// file was made is kept, and review ratings are left to the review service.
+func (r *ProductRepository) UpsertProducts(ctx context.Context, entries []models.CatalogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, len(entries))
+	for i, entry := range entries {
+		set := bson.M{
+			"name":        entry.Name,
+			"description": entry.Description,
+			"price":       entry.Price,
+			"category":    entry.Category,
+			"image_url":   entry.ImageURL,
+			"weight":      entry.Weight,
+		}
+		if entry.HSNCode != "" {
+			set["hsn_code"] = entry.HSNCode
+		}
+		if entry.GSTRate > 0 {
+			set["gst_rate"] = entry.GSTRate
+		}
+		onInsert := bson.M{"rating": 0.0, "review_count": 0}
+		stock := set
+		if !entry.HasStock {
+			stock = onInsert
+		}
+		stock["stock"], stock["in_stock"] = entry.Stock, entry.Stock > 0
+
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"id": entry.ID}).
+			SetUpdate(bson.M{"$set": set, "$setOnInsert": onInsert}).
+			SetUpsert(true)
+	}
+	_, err := r.Collection.BulkWrite(ctx, writes)
+	return err
+}
+
+// ReserveStock atomically takes quantity units of a product's stock, keeping in_stock in sync. It returns
+// ErrInsufficientStock if the product is out of stock or has fewer than quantity units left.
+func (r *ProductRepository) ReserveStock(ctx context.Context, id string, quantity int) error {
+	filter := bson.M{"id": id, "in_stock": true, "stock": bson.M{"$gte": quantity}}
+	result, err := r.Collection.UpdateOne(ctx, filter, adjustStock(-quantity))
 	if err != nil {
 		return err
 	}
-	if count == 0 {
-		_, err := r.Collection.InsertMany(context.TODO(), products)
-		if err != nil {
-			return err
-		}
+	if result.MatchedCount == 0 {
+		return ErrInsufficientStock
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// ReleaseStock returns quantity units of a product to stock, for example when an order is cancelled.
+func (r *ProductRepository) ReleaseStock(ctx context.Context, id string, quantity int) error {
+	result, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, adjustStock(quantity))
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SetRating stores the summary of a product's approved reviews.
+func (r *ProductRepository) SetRating(ctx context.Context, id string, rating float64, count int) error {
+	update := bson.M{"$set": bson.M{"rating": rating, "review_count": count}}
+	result, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// adjustStock is an update pipeline that changes stock by delta and recomputes in_stock from the result.
+func adjustStock(delta int) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"stock": bson.M{"$add": bson.A{"$stock", delta}}}}},
+		{{Key: "$set", Value: bson.M{"in_stock": bson.M{"$gt": bson.A{"$stock", 0}}}}},
+	}
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitRepository keeps rate limit token buckets in Mongo, so every server instance shares the same
+// counts. Each take is a single atomic update; idle buckets are removed by a TTL index on expires_at.
+type RateLimitRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *RateLimitRepository) Take(ctx context.Context, key string, capacity float64, perSecond float64, ttl time.Duration, now time.Time) (float64, bool, error) {
+	elapsed := bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated", now}}}}, 1000}}
+	refilled := bson.M{"$add": bson.A{
+		bson.M{"$ifNull": bson.A{"$tokens", capacity}},
+		bson.M{"$multiply": bson.A{bson.M{"$max": bson.A{elapsed, 0}}, perSecond}},
+	}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens":     bson.M{"$min": bson.A{capacity, refilled}},
+			"updated":    now,
+			"expires_at": now.Add(ttl),
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var result struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	err := r.Collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&result)
+	// Two first requests from the same client can race to create the bucket; the loser finds it created.
+	if mongo.IsDuplicateKeyError(err) {
+		err = r.Collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&result)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return result.Tokens, result.Allowed, nil
+}
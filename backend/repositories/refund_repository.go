@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RefundRepositoryInterface interface {
+	CreateRefund(ctx context.Context, refund models.Refund) error
+	GetRefund(ctx context.Context, id string) (*models.Refund, error)
+	GetRefundByGatewayID(ctx context.Context, gatewayRefundID string) (*models.Refund, error)
+	GetRefundsByOrder(ctx context.Context, orderID string) ([]models.Refund, error)
+	ListRefunds(ctx context.Context, status string) ([]models.Refund, error)
+	SetGatewayRefund(ctx context.Context, id string, gatewayRefundID string, status string) error
+	UpdateRefundStatus(ctx context.Context, id string, status string, failureReason string) error
+}
+
+type RefundRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *RefundRepository) CreateRefund(ctx context.Context, refund models.Refund) error {
+	_, err := r.Collection.InsertOne(ctx, refund)
+	return err
+}
+
+func (r *RefundRepository) GetRefund(ctx context.Context, id string) (*models.Refund, error) {
+	var refund models.Refund
+	err := r.Collection.FindOne(ctx, bson.M{"id": id}).Decode(&refund)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *RefundRepository) GetRefundByGatewayID(ctx context.Context, gatewayRefundID string) (*models.Refund, error) {
+	var refund models.Refund
+	err := r.Collection.FindOne(ctx, bson.M{"gateway_refund_id": gatewayRefundID}).Decode(&refund)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *RefundRepository) GetRefundsByOrder(ctx context.Context, orderID string) ([]models.Refund, error) {
+	return r.find(ctx, bson.M{"order_id": orderID})
+}
+
+// ListRefunds returns refunds newest first, optionally only those with the given status.
+func (r *RefundRepository) ListRefunds(ctx context.Context, status string) ([]models.Refund, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	return r.find(ctx, filter)
+}
+
+func (r *RefundRepository) SetGatewayRefund(ctx context.Context, id string, gatewayRefundID string, status string) error {
+	update := bson.M{"$set": bson.M{"gateway_refund_id": gatewayRefundID, "status": status, "updated_at": time.Now()}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+func (r *RefundRepository) UpdateRefundStatus(ctx context.Context, id string, status string, failureReason string) error {
+	update := bson.M{"$set": bson.M{"status": status, "failure_reason": failureReason, "updated_at": time.Now()}}
+	result, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *RefundRepository) find(ctx context.Context, filter bson.M) ([]models.Refund, error) {
+	refunds := []models.Refund{}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &refunds); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
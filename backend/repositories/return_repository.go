@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReturnRepositoryInterface interface {
+	CreateReturn(ctx context.Context, request models.ReturnRequest) error
+	GetReturn(ctx context.Context, id string) (*models.ReturnRequest, error)
+	GetReturnsByOrder(ctx context.Context, orderID string) ([]models.ReturnRequest, error)
+	ListReturns(ctx context.Context, status string) ([]models.ReturnRequest, error)
+	ResolveReturn(ctx context.Context, id string, status string, adminNote string) error
+	SetReturnRefund(ctx context.Context, id string, refundID string) error
+}
+
+type ReturnRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *ReturnRepository) CreateReturn(ctx context.Context, request models.ReturnRequest) error {
+	_, err := r.Collection.InsertOne(ctx, request)
+	return err
+}
+
+func (r *ReturnRepository) GetReturn(ctx context.Context, id string) (*models.ReturnRequest, error) {
+	var request models.ReturnRequest
+	err := r.Collection.FindOne(ctx, bson.M{"id": id}).Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *ReturnRepository) GetReturnsByOrder(ctx context.Context, orderID string) ([]models.ReturnRequest, error) {
+	return r.find(ctx, bson.M{"order_id": orderID})
+}
+
+// ListReturns returns return requests oldest first so the queue is worked in order, optionally filtered by status.
+func (r *ReturnRepository) ListReturns(ctx context.Context, status string) ([]models.ReturnRequest, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	return r.find(ctx, filter)
+}
+
+// ResolveReturn approves or rejects a return that is still in the requested state. It returns
+// mongo.ErrNoDocuments if the return does not exist or has already been resolved.
+func (r *ReturnRepository) ResolveReturn(ctx context.Context, id string, status string, adminNote string) error {
+	filter := bson.M{"id": id, "status": models.ReturnStatusRequested}
+	update := bson.M{"$set": bson.M{"status": status, "admin_note": adminNote, "updated_at": time.Now()}}
+	result, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *ReturnRepository) SetReturnRefund(ctx context.Context, id string, refundID string) error {
+	update := bson.M{"$set": bson.M{"refund_id": refundID, "updated_at": time.Now()}}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+func (r *ReturnRepository) find(ctx context.Context, filter bson.M) ([]models.ReturnRequest, error) {
+	requests := []models.ReturnRequest{}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReviewRepositoryInterface interface {
+	CreateReview(ctx context.Context, review models.Review) error
+	GetReview(ctx context.Context, id string) (*models.Review, error)
+	ListReviews(ctx context.Context, filter ReviewFilter) ([]models.Review, int64, error)
+	SetReviewStatus(ctx context.Context, id string, status string, note string) error
+	AddHelpfulVote(ctx context.Context, id string, voterID string) error
+	RatingSummary(ctx context.Context, productID string) (float64, int, error)
+}
+
+// ReviewFilter selects reviews. SortField is "helpful_count" or "created_at", newest first either way.
+type ReviewFilter struct {
+	ProductID     string
+	Status        []string
+	SortField     string
+	SortAscending bool
+	Skip          int64
+	Limit         int64
+}
+
+type ReviewRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *ReviewRepository) CreateReview(ctx context.Context, review models.Review) error {
+	_, err := r.Collection.InsertOne(ctx, review)
+	return err
+}
+
+func (r *ReviewRepository) GetReview(ctx context.Context, id string) (*models.Review, error) {
+	var review models.Review
+	err := r.Collection.FindOne(ctx, bson.M{"id": id}).Decode(&review)
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// ListReviews returns one page of the reviews matching filter and how many match in total.
+func (r *ReviewRepository) ListReviews(ctx context.Context, filter ReviewFilter) ([]models.Review, int64, error) {
+	query := bson.M{}
+	if filter.ProductID != "" {
+		query["product_id"] = filter.ProductID
+	}
+	if len(filter.Status) > 0 {
+		query["status"] = bson.M{"$in": filter.Status}
+	}
+	total, err := r.Collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	direction := -1
+	if filter.SortAscending {
+		direction = 1
+	}
+	sort := bson.D{{Key: "created_at", Value: direction}, {Key: "id", Value: direction}}
+	if filter.SortField != "" && filter.SortField != "created_at" {
+		sort = append(bson.D{{Key: filter.SortField, Value: direction}}, sort...)
+	}
+	opts := options.Find().SetSort(sort).SetSkip(filter.Skip).SetLimit(filter.Limit)
+
+	cursor, err := r.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	reviews := []models.Review{}
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, 0, err
+	}
+	return reviews, total, nil
+}
+
+// SetReviewStatus records a moderation decision. It returns mongo.ErrNoDocuments if the review does not
+// exist.
+func (r *ReviewRepository) SetReviewStatus(ctx context.Context, id string, status string, note string) error {
+	update := bson.M{"$set": bson.M{"status": status, "moderation_note": note, "updated_at": time.Now()}}
+	result, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// AddHelpfulVote counts voterID as finding the review helpful. Voting again has no effect.
+func (r *ReviewRepository) AddHelpfulVote(ctx context.Context, id string, voterID string) error {
+	filter := bson.M{"id": id, "helpful_voters": bson.M{"$ne": voterID}}
+	update := bson.M{
+		"$addToSet": bson.M{"helpful_voters": voterID},
+		"$inc":      bson.M{"helpful_count": 1},
+	}
+	_, err := r.Collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// RatingSummary returns the average rating and number of a product's approved reviews.
+func (r *ReviewRepository) RatingSummary(ctx context.Context, productID string) (float64, int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"product_id": productID, "status": models.ReviewStatusApproved}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"average": bson.M{"$avg": "$rating"},
+			"count":   bson.M{"$sum": 1},
+		}}},
+	}
+	cursor, err := r.Collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+	var results []struct {
+		Average float64 `bson:"average"`
+		Count   int     `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, 0, err
+	}
+	if len(results) == 0 {
+		return 0, 0, nil
+	}
+	return results[0].Average, results[0].Count, nil
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type StockSubscriptionRepositoryInterface interface {
+	Subscribe(ctx context.Context, subscription models.StockSubscription) (*models.StockSubscription, error)
+	ListSubscribers(ctx context.Context, productID string, limit int64) ([]models.StockSubscription, error)
+	ClaimSubscription(ctx context.Context, id string) (bool, error)
+}
+
+type StockSubscriptionRepository struct {
+	Collection *mongo.Collection
+}
+
+// Subscribe stores a subscription unless the same contact is already waiting for the product, and returns
+// the stored one so repeated requests keep their place in the queue.
+func (r *StockSubscriptionRepository) Subscribe(ctx context.Context, subscription models.StockSubscription) (*models.StockSubscription, error) {
+	filter := bson.M{"product_id": subscription.ProductID, "email": subscription.Email, "phone": subscription.Phone}
+	update := bson.M{"$setOnInsert": bson.M{"id": subscription.ID, "created_at": subscription.CreatedAt}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var stored models.StockSubscription
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&stored)
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// ListSubscribers returns up to limit subscriptions for a product, oldest first.
+func (r *StockSubscriptionRepository) ListSubscribers(ctx context.Context, productID string, limit int64) ([]models.StockSubscription, error) {
+	subscriptions := []models.StockSubscription{}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "id", Value: 1}}).SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, bson.M{"product_id": productID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// ClaimSubscription removes a subscription that is about to be alerted, reporting false if it had already
+// been removed, so concurrent restock jobs alert each subscriber once.
+func (r *StockSubscriptionRepository) ClaimSubscription(ctx context.Context, id string) (bool, error) {
+	result, err := r.Collection.DeleteOne(ctx, bson.M{"id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
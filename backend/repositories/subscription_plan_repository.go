@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SubscriptionPlanRepositoryInterface interface {
+	CreatePlan(ctx context.Context, plan models.SubscriptionPlan) error
+	GetPlan(ctx context.Context, id string) (*models.SubscriptionPlan, error)
+	ListPlans(ctx context.Context, activeOnly bool) ([]models.SubscriptionPlan, error)
+}
+
+type SubscriptionPlanRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *SubscriptionPlanRepository) CreatePlan(ctx context.Context, plan models.SubscriptionPlan) error {
+	_, err := r.Collection.InsertOne(ctx, plan)
+	return err
+}
+
+func (r *SubscriptionPlanRepository) GetPlan(ctx context.Context, id string) (*models.SubscriptionPlan, error) {
+	var plan models.SubscriptionPlan
+	err := r.Collection.FindOne(ctx, bson.M{"id": id}).Decode(&plan)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ListPlans returns plans in the order they were created.
+func (r *SubscriptionPlanRepository) ListPlans(ctx context.Context, activeOnly bool) ([]models.SubscriptionPlan, error) {
+	filter := bson.M{}
+	if activeOnly {
+		filter["active"] = true
+	}
+	plans := []models.SubscriptionPlan{}
+	cursor, err := r.Collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &plans); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SubscriptionRepositoryInterface interface {
+	CreateSubscription(ctx context.Context, subscription models.Subscription) error
+	GetSubscription(ctx context.Context, id string) (*models.Subscription, error)
+	GetByGatewayID(ctx context.Context, gatewaySubscriptionID string) (*models.Subscription, error)
+	ListSubscriptions(ctx context.Context, customerID string) ([]models.Subscription, error)
+	TransitionStatus(ctx context.Context, id string, from []string, change models.StatusChange, nextOrderAt *time.Time) (*models.Subscription, error)
+	ListDue(ctx context.Context, before time.Time, limit int64) ([]models.Subscription, error)
+	AdvanceCycle(ctx context.Context, id string, dueAt time.Time, next time.Time) error
+	SkipCycle(ctx context.Context, id string, dueAt time.Time, next time.Time, change models.StatusChange) (*models.Subscription, error)
+	RecordSkip(ctx context.Context, id string, change models.StatusChange) error
+	ClaimCharge(ctx context.Context, id string, paymentID string) error
+	ReleaseCharge(ctx context.Context, id string, paymentID string) error
+	TakeSkippedCharge(ctx context.Context, id string) error
+	ReturnSkippedCharge(ctx context.Context, id string) error
+	AddCredit(ctx context.Context, id string, payment models.SubscriptionPayment) error
+	TakeCredit(ctx context.Context, id string) (*models.SubscriptionPayment, error)
+	ClearCredits(ctx context.Context, id string) ([]models.SubscriptionPayment, error)
+}
+
+type SubscriptionRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *SubscriptionRepository) CreateSubscription(ctx context.Context, subscription models.Subscription) error {
+	_, err := r.Collection.InsertOne(ctx, subscription)
+	return err
+}
+
+func (r *SubscriptionRepository) GetSubscription(ctx context.Context, id string) (*models.Subscription, error) {
+	return r.findOne(ctx, bson.M{"id": id})
+}
+
+func (r *SubscriptionRepository) GetByGatewayID(ctx context.Context, gatewaySubscriptionID string) (*models.Subscription, error) {
+	return r.findOne(ctx, bson.M{"gateway_subscription_id": gatewaySubscriptionID})
+}
+
+// ListSubscriptions returns a customer's subscriptions, newest first.
+func (r *SubscriptionRepository) ListSubscriptions(ctx context.Context, customerID string) ([]models.Subscription, error) {
+	return r.find(ctx, bson.M{"customer_id": customerID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+}
+
+// TransitionStatus moves a subscription to change.Status and appends change to its history, but only if it
+// is currently in one of the from statuses; otherwise it returns mongo.ErrNoDocuments. nextOrderAt, when
+// given, reschedules the next order.
+func (r *SubscriptionRepository) TransitionStatus(ctx context.Context, id string, from []string, change models.StatusChange, nextOrderAt *time.Time) (*models.Subscription, error) {
+	set := bson.M{"status": change.Status, "updated_at": change.ChangedAt}
+	if nextOrderAt != nil {
+		set["next_order_at"] = *nextOrderAt
+	}
+	update := bson.M{"$set": set, "$push": bson.M{"history": change}}
+	return r.findOneAndUpdate(ctx, bson.M{"id": id, "status": bson.M{"$in": from}}, update)
+}
+
+// ListDue returns up to limit active subscriptions whose next order was due before the given time, most
+// overdue first.
+func (r *SubscriptionRepository) ListDue(ctx context.Context, before time.Time, limit int64) ([]models.Subscription, error) {
+	filter := bson.M{"status": models.SubscriptionStatusActive, "next_order_at": bson.M{"$lte": before}}
+	return r.find(ctx, filter, options.Find().SetSort(bson.D{{Key: "next_order_at", Value: 1}}).SetLimit(limit))
+}
+
+// AdvanceCycle claims the cycle due at dueAt by moving the next order to next. It returns
+// mongo.ErrNoDocuments if the cycle was already claimed or skipped, or the subscription is no longer active.
+func (r *SubscriptionRepository) AdvanceCycle(ctx context.Context, id string, dueAt time.Time, next time.Time) error {
+	filter := bson.M{"id": id, "status": models.SubscriptionStatusActive, "next_order_at": dueAt}
+	update := bson.M{"$set": bson.M{"next_order_at": next, "updated_at": time.Now()}}
+	result, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// SkipCycle skips the cycle due at dueAt like AdvanceCycle, and records the skip so the cycle's gateway
+// charge is refunded.
+func (r *SubscriptionRepository) SkipCycle(ctx context.Context, id string, dueAt time.Time, next time.Time, change models.StatusChange) (*models.Subscription, error) {
+	filter := bson.M{"id": id, "status": models.SubscriptionStatusActive, "next_order_at": dueAt}
+	update := bson.M{
+		"$set":  bson.M{"next_order_at": next, "updated_at": change.ChangedAt},
+		"$push": bson.M{"history": change},
+		"$inc":  bson.M{"skipped_charges": 1},
+	}
+	return r.findOneAndUpdate(ctx, filter, update)
+}
+
+// RecordSkip records a cycle that was claimed but placed no order, so its gateway charge is refunded.
+func (r *SubscriptionRepository) RecordSkip(ctx context.Context, id string, change models.StatusChange) error {
+	update := bson.M{
+		"$set":  bson.M{"updated_at": change.ChangedAt},
+		"$push": bson.M{"history": change},
+		"$inc":  bson.M{"skipped_charges": 1},
+	}
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// ClaimCharge records that a gateway payment has been handled. It returns mongo.ErrNoDocuments if it
+// already was, so replayed webhooks are ignored.
+func (r *SubscriptionRepository) ClaimCharge(ctx context.Context, id string, paymentID string) error {
+	filter := bson.M{"id": id, "charge_ids": bson.M{"$ne": paymentID}}
+	result, err := r.Collection.UpdateOne(ctx, filter, bson.M{"$addToSet": bson.M{"charge_ids": paymentID}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ReleaseCharge forgets a claimed gateway payment that could not be applied, so the gateway's retry of the
+// webhook applies it.
+func (r *SubscriptionRepository) ReleaseCharge(ctx context.Context, id string, paymentID string) error {
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$pull": bson.M{"charge_ids": paymentID}})
+	return err
+}
+
+// TakeSkippedCharge uses up one skipped cycle's charge. It returns mongo.ErrNoDocuments if none is owed.
+func (r *SubscriptionRepository) TakeSkippedCharge(ctx context.Context, id string) error {
+	filter := bson.M{"id": id, "skipped_charges": bson.M{"$gt": 0}}
+	result, err := r.Collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"skipped_charges": -1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ReturnSkippedCharge gives back a skipped cycle's charge taken by TakeSkippedCharge.
+func (r *SubscriptionRepository) ReturnSkippedCharge(ctx context.Context, id string) error {
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$inc": bson.M{"skipped_charges": 1}})
+	return err
+}
+
+func (r *SubscriptionRepository) AddCredit(ctx context.Context, id string, payment models.SubscriptionPayment) error {
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$push": bson.M{"credits": payment}})
+	return err
+}
+
+// TakeCredit removes and returns the oldest unused charge. It returns mongo.ErrNoDocuments if there is none.
+func (r *SubscriptionRepository) TakeCredit(ctx context.Context, id string) (*models.SubscriptionPayment, error) {
+	filter := bson.M{"id": id, "credits.0": bson.M{"$exists": true}}
+	subscription, err := r.findOneAndUpdateBefore(ctx, filter, bson.M{"$pop": bson.M{"credits": -1}})
+	if err != nil {
+		return nil, err
+	}
+	return &subscription.Credits[0], nil
+}
+
+// ClearCredits removes and returns all unused charges.
+func (r *SubscriptionRepository) ClearCredits(ctx context.Context, id string) ([]models.SubscriptionPayment, error) {
+	subscription, err := r.findOneAndUpdateBefore(ctx, bson.M{"id": id}, bson.M{"$unset": bson.M{"credits": ""}})
+	if err != nil {
+		return nil, err
+	}
+	return subscription.Credits, nil
+}
+
+func (r *SubscriptionRepository) findOne(ctx context.Context, filter bson.M) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := r.Collection.FindOne(ctx, filter).Decode(&subscription)
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *SubscriptionRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Subscription, error) {
+	subscriptions := []models.Subscription{}
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (r *SubscriptionRepository) findOneAndUpdate(ctx context.Context, filter bson.M, update bson.M) (*models.Subscription, error) {
+	var subscription models.Subscription
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&subscription)
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *SubscriptionRepository) findOneAndUpdateBefore(ctx context.Context, filter bson.M, update bson.M) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update).Decode(&subscription)
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
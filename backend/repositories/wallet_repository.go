@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WalletRepositoryInterface interface {
+	GetWallet(ctx context.Context, customerID string) (*models.Wallet, error)
+	Debit(ctx context.Context, customerID string, amount float64) (*models.Wallet, error)
+	Credit(ctx context.Context, customerID string, amount float64) (*models.Wallet, error)
+}
+
+// WalletRepository stores customers' store credit. A wallet is created by its first credit.
+type WalletRepository struct {
+	Collection *mongo.Collection
+}
+
+func (r *WalletRepository) GetWallet(ctx context.Context, customerID string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.Collection.FindOne(ctx, bson.M{"customer_id": customerID}).Decode(&wallet)
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// Debit takes amount off the customer's balance and returns the wallet after the debit. It returns
+// mongo.ErrNoDocuments when the customer has no wallet or their balance is less than amount.
+func (r *WalletRepository) Debit(ctx context.Context, customerID string, amount float64) (*models.Wallet, error) {
+	filter := bson.M{"customer_id": customerID, "balance": bson.M{"$gte": amount}}
+	update := bson.M{"$inc": bson.M{"balance": -amount}, "$set": bson.M{"updated_at": time.Now()}}
+	return r.findOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+}
+
+// Credit adds amount to the customer's balance, creating their wallet if needed, and returns the wallet after.
+func (r *WalletRepository) Credit(ctx context.Context, customerID string, amount float64) (*models.Wallet, error) {
+	update := bson.M{"$inc": bson.M{"balance": amount}, "$set": bson.M{"updated_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return r.findOneAndUpdate(ctx, bson.M{"customer_id": customerID}, update, opts)
+}
+
+func (r *WalletRepository) findOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, opts *options.FindOneAndUpdateOptions) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&wallet)
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"mangal-chai-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WishlistRepositoryInterface interface {
+	SaveItem(ctx context.Context, item models.WishlistItem) error
+	RemoveItem(ctx context.Context, customerID string, productID string) error
+	ListItems(ctx context.Context, customerID string) ([]models.WishlistItem, error)
+	ListRestockWatchers(ctx context.Context, productID string, limit int64) ([]models.WishlistItem, error)
+	ClaimRestockAlert(ctx context.Context, customerID string, productID string, at time.Time) (bool, error)
+	MostWishlisted(ctx context.Context, limit int64) ([]WishlistCount, error)
+}
+
+// WishlistCount is how many customers have saved a product and how many of them want a restock alert.
+type WishlistCount struct {
+	ProductID string `json:"product_id" bson:"_id"`
+	Customers int64  `json:"customers" bson:"customers"`
+	Watching  int64  `json:"watching_restock" bson:"watching"`
+}
+
+type WishlistRepository struct {
+	Collection *mongo.Collection
+}
+
+// SaveItem adds a product to a customer's wishlist, or updates its restock alert if it is already there.
+// The date it was first added is kept.
+func (r *WishlistRepository) SaveItem(ctx context.Context, item models.WishlistItem) error {
+	filter := bson.M{"customer_id": item.CustomerID, "product_id": item.ProductID}
+	update := bson.M{
+		"$set":         bson.M{"notify_restock": item.NotifyRestock},
+		"$setOnInsert": bson.M{"added_at": item.AddedAt},
+	}
+	_, err := r.Collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// RemoveItem deletes a product from a wishlist. It returns mongo.ErrNoDocuments if it was not there.
+func (r *WishlistRepository) RemoveItem(ctx context.Context, customerID string, productID string) error {
+	result, err := r.Collection.DeleteOne(ctx, bson.M{"customer_id": customerID, "product_id": productID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ListItems returns a customer's wishlist, most recently added first.
+func (r *WishlistRepository) ListItems(ctx context.Context, customerID string) ([]models.WishlistItem, error) {
+	return r.find(ctx, bson.M{"customer_id": customerID}, -1, 0)
+}
+
+// ListRestockWatchers returns up to limit wishlist entries waiting for a product to come back, oldest
+// first.
+func (r *WishlistRepository) ListRestockWatchers(ctx context.Context, productID string, limit int64) ([]models.WishlistItem, error) {
+	return r.find(ctx, bson.M{"product_id": productID, "notify_restock": true}, 1, limit)
+}
+
+// ClaimRestockAlert switches off an entry's restock alert as the customer is about to be told, reporting
+// false if it was already off, so concurrent restock jobs alert each customer once.
+func (r *WishlistRepository) ClaimRestockAlert(ctx context.Context, customerID string, productID string, at time.Time) (bool, error) {
+	filter := bson.M{"customer_id": customerID, "product_id": productID, "notify_restock": true}
+	update := bson.M{"$set": bson.M{"notify_restock": false, "notified_at": at}}
+	result, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// MostWishlisted returns the products on the most wishlists.
+func (r *WishlistRepository) MostWishlisted(ctx context.Context, limit int64) ([]WishlistCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$product_id",
+			"customers": bson.M{"$sum": 1},
+			"watching":  bson.M{"$sum": bson.M{"$cond": bson.A{"$notify_restock", 1, 0}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "customers", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := r.Collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	counts := []WishlistCount{}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (r *WishlistRepository) find(ctx context.Context, filter bson.M, direction int, limit int64) ([]models.WishlistItem, error) {
+	items := []models.WishlistItem{}
+	opts := options.Find().SetSort(bson.D{{Key: "added_at", Value: direction}}).SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrCartNotFound          = errors.New("cart not found")
+	ErrCartLoginRequired     = errors.New("log in to restore this cart")
+	ErrCartRecoveryDisabled  = errors.New("cart recovery is not configured")
+	ErrInvalidRecoveryPeriod = errors.New("invalid recovery report period")
+)
+
+const (
+	// DefaultCartReminderDelay is how long a cart is left untouched before the customer is reminded of it.
+	DefaultCartReminderDelay = time.Hour
+	// maxReminderAge stops reminders for carts that were abandoned long ago, e.g. when recovery is first
+	// switched on.
+	maxReminderAge      = 7 * 24 * time.Hour
+	reminderBatch       = 100
+	cartLinkTTL         = 14 * 24 * time.Hour
+	recoveryCouponTTL   = 7 * 24 * time.Hour
+	recoveryCouponChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// CartReminderNotifier queues a reminder about an abandoned cart on one channel, reporting whether the
+// customer could be reached on it. CanRemind tells beforehand whether they can be.
+type CartReminderNotifier interface {
+	CanRemind(ctx context.Context, cart models.Cart) (bool, error)
+	RemindCart(ctx context.Context, cart models.Cart, reminder models.CartReminder) (bool, error)
+}
+
+type CartRecoveryServiceInterface interface {
+	SendReminders(ctx context.Context) (int, error)
+	RestoreCart(ctx context.Context, token string, customerID string) (*models.Cart, error)
+	RecoveryReport(ctx context.Context, from string, to string) (*CartRecoveryReport, error)
+}
+
+// CartRecoveryReport shows how many abandoned cart reminders sent in a period led to an order.
+type CartRecoveryReport struct {
+	From             time.Time `json:"from"`
+	To               time.Time `json:"to"`
+	RemindersSent    int64     `json:"reminders_sent"`
+	CartsRecovered   int64     `json:"carts_recovered"`
+	ConversionRate   float64   `json:"conversion_rate"`
+	CouponsUsed      int64     `json:"coupons_used"`
+	RecoveredRevenue float64   `json:"recovered_revenue"`
+}
+
+// CartRecoveryService reminds customers of carts they left without ordering. Each reminder carries a
+// signed link that restores the cart and, when CouponPercent is set, a single-use discount code.
+type CartRecoveryService struct {
+	Carts         repositories.CartRepositoryInterface
+	CartService   CartServiceInterface
+	Reminders     repositories.CartReminderRepositoryInterface
+	Coupons       repositories.CouponRepositoryInterface
+	Customers     repositories.CustomerRepositoryInterface
+	Notifiers     []CartReminderNotifier
+	LinkSecret    []byte
+	ShopURL       string
+	Delay         time.Duration
+	CouponPercent float64
+}
+
+// SendReminders reminds the customers of carts untouched for Delay, once per cart, and returns how many
+// reminders were sent.
+func (s *CartRecoveryService) SendReminders(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "CartRecoveryService.SendReminders")
+	defer span.End()
+
+	if len(s.LinkSecret) == 0 {
+		return 0, ErrCartRecoveryDisabled
+	}
+	delay := s.Delay
+	if delay <= 0 {
+		delay = DefaultCartReminderDelay
+	}
+	now := time.Now()
+	carts, err := s.Carts.ListAbandoned(ctx, now.Add(-delay-maxReminderAge), now.Add(-delay), reminderBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, cart := range carts {
+		ok, err := s.remind(ctx, cart, now)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to send a cart reminder", "cart_id", cart.ID, "error", err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// remind sends one cart's reminder on every channel that reaches the customer. A cart nobody can be
+// reached for is marked reminded anyway so it is not retried on every sweep, and gets no coupon.
+func (s *CartRecoveryService) remind(ctx context.Context, cart models.Cart, now time.Time) (bool, error) {
+	if cart.CustomerID != "" {
+		if customer, err := s.Customers.GetCustomer(ctx, cart.CustomerID); err == nil {
+			cart.Phone = customer.Phone
+			if customer.Name != "" {
+				cart.Name = customer.Name
+			}
+			if customer.Email != "" {
+				cart.Email = customer.Email
+			}
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return false, err
+		}
+	}
+
+	var channels []CartReminderNotifier
+	for _, notifier := range s.Notifiers {
+		ok, err := notifier.CanRemind(ctx, cart)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to check if a cart reminder can be sent", "cart_id", cart.ID, "error", err)
+		}
+		if ok {
+			channels = append(channels, notifier)
+		}
+	}
+
+	reminder := models.CartReminder{
+		ID:         fmt.Sprintf("crm_%d", now.UnixNano()),
+		CartID:     cart.ID,
+		CustomerID: cart.CustomerID,
+		Link:       s.restoreLink(cart.ID, now),
+		Subtotal:   cart.Subtotal,
+		SentAt:     now,
+	}
+	if len(channels) > 0 && s.CouponPercent > 0 && s.Coupons != nil {
+		coupon, err := s.createCoupon(ctx, now)
+		if err != nil {
+			return false, err
+		}
+		reminder.CouponCode = coupon.Code
+		reminder.PercentOff = coupon.PercentOff
+	}
+
+	reached := false
+	for _, notifier := range channels {
+		queued, err := notifier.RemindCart(ctx, cart, reminder)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to queue a cart reminder", "cart_id", cart.ID, "error", err)
+		}
+		reached = reached || queued
+	}
+
+	if reached {
+		if err := s.Reminders.CreateReminder(ctx, reminder); err != nil {
+			return false, err
+		}
+	}
+	if err := s.Carts.MarkReminded(ctx, cart.ID, reminder.ID); err != nil {
+		return false, err
+	}
+	return reached, nil
+}
+
+func (s *CartRecoveryService) restoreLink(cartID string, now time.Time) string {
+	token := signToken(s.LinkSecret, tokenPurposeCart, cartID, now.Add(cartLinkTTL))
+	return strings.TrimRight(s.ShopURL, "/") + "/?restore_cart=" + url.QueryEscape(token)
+}
+
+func (s *CartRecoveryService) createCoupon(ctx context.Context, now time.Time) (*models.Coupon, error) {
+	code := make([]byte, 8)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCouponChars))))
+		if err != nil {
+			return nil, err
+		}
+		code[i] = recoveryCouponChars[n.Int64()]
+	}
+	coupon := models.Coupon{
+		Code:       "BACK-" + string(code),
+		PercentOff: s.CouponPercent,
+		Source:     models.CouponSourceCartRecovery,
+		ExpiresAt:  now.Add(recoveryCouponTTL),
+		CreatedAt:  now,
+	}
+	if err := s.Coupons.CreateCoupon(ctx, coupon); err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// RestoreCart returns the cart a reminder link points to, refreshed against the catalogue. A guest cart
+// comes back with its token so the client can carry on with it; a customer's cart is only returned to that
+// customer, logged in as customerID, since the link may have been forwarded or leaked.
+func (s *CartRecoveryService) RestoreCart(ctx context.Context, token string, customerID string) (*models.Cart, error) {
+	ctx, span := tracing.Start(ctx, "CartRecoveryService.RestoreCart")
+	defer span.End()
+
+	if len(s.LinkSecret) == 0 {
+		return nil, ErrCartRecoveryDisabled
+	}
+	cartID, err := verifyToken(s.LinkSecret, tokenPurposeCart, token)
+	if err != nil {
+		return nil, err
+	}
+	cart, err := s.Carts.GetCart(ctx, cartID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cart.CustomerID != "" && customerID == "" {
+		return nil, ErrCartLoginRequired
+	}
+	if cart.CustomerID != "" && cart.CustomerID != customerID {
+		return nil, ErrCartNotFound
+	}
+	return s.CartService.GetCart(ctx, CartKey{Token: cart.Token, CustomerID: cart.CustomerID})
+}
+
+// RecoveryReport reports on reminders sent between from and to, inclusive dates in the shop's timezone.
+// The period defaults to the last 30 days.
+func (s *CartRecoveryService) RecoveryReport(ctx context.Context, from string, to string) (*CartRecoveryReport, error) {
+	ctx, span := tracing.Start(ctx, "CartRecoveryService.RecoveryReport")
+	defer span.End()
+
+	start, _, err := parseDateBound(from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: from: %v", ErrInvalidRecoveryPeriod, err)
+	}
+	end, isDate, err := parseDateBound(to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: to: %v", ErrInvalidRecoveryPeriod, err)
+	}
+	if isDate {
+		end = end.AddDate(0, 0, 1)
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		start = end.AddDate(0, 0, -30)
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRecoveryPeriod)
+	}
+
+	stats, err := s.Reminders.RecoveryStats(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	report := &CartRecoveryReport{
+		From:             start,
+		To:               end,
+		RemindersSent:    stats.Sent,
+		CartsRecovered:   stats.Recovered,
+		CouponsUsed:      stats.CouponsUsed,
+		RecoveredRevenue: roundRupees(stats.RecoveredRevenue),
+	}
+	if stats.Sent > 0 {
+		report.ConversionRate = math.Round(float64(stats.Recovered)/float64(stats.Sent)*10000) / 10000
+	}
+	return report, nil
+}
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/messaging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrInvalidCart = errors.New("invalid cart")
+
+const (
+	maxCartLines    = 50
+	guestCartTTL    = 30 * 24 * time.Hour
+	customerCartTTL = 90 * 24 * time.Hour
+)
+
+// CartKey identifies whose cart a request is for: the logged-in customer's when CustomerID is set, otherwise
+// the guest cart with Token.
+type CartKey struct {
+	Token      string
+	CustomerID string
+}
+
+// CartUpdate replaces the contents of a cart. An empty Items list empties it. Contact details, when given,
+// are kept with the cart so the customer can be reminded of it.
+type CartUpdate struct {
+	Items []models.CartItem `json:"items"`
+	Name  string            `json:"name"`
+	Email string            `json:"email"`
+	Phone string            `json:"phone"`
+}
+
+type CartServiceInterface interface {
+	GetCart(ctx context.Context, key CartKey) (*models.Cart, error)
+	UpdateCart(ctx context.Context, key CartKey, update CartUpdate) (*models.Cart, error)
+	DeleteCart(ctx context.Context, key CartKey) error
+	MergeGuestCart(ctx context.Context, token string, customerID string) (*models.Cart, error)
+	CompleteCart(ctx context.Context, key CartKey, order models.Order) error
+}
+
+// CartService keeps carts on the server. Lines are checked against the catalogue on every change, and
+// each time a cart is read its prices are refreshed and lines that can no longer be bought are adjusted,
+// with a notice telling the customer what changed.
+type CartService struct {
+	Repository        repositories.CartRepositoryInterface
+	ProductRepository repositories.ProductRepositoryInterface
+	Reminders         repositories.CartReminderRepositoryInterface
+}
+
+// GetCart returns the cart for key, or an empty unsaved cart if there is none.
+func (s *CartService) GetCart(ctx context.Context, key CartKey) (*models.Cart, error) {
+	ctx, span := tracing.Start(ctx, "CartService.GetCart")
+	defer span.End()
+
+	cart, err := s.findCart(ctx, key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &models.Cart{CustomerID: key.CustomerID, Items: []models.CartLine{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := s.refresh(ctx, cart)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		if err := s.save(ctx, cart); err != nil {
+			return nil, err
+		}
+	}
+	return cart, nil
+}
+
+// UpdateCart replaces the cart's lines. Unlike a refresh, a change that asks for something that cannot be
+// bought is rejected with ErrInvalidCart rather than adjusted. A guest without a cart gets a new one with
+// a fresh token.
+func (s *CartService) UpdateCart(ctx context.Context, key CartKey, update CartUpdate) (*models.Cart, error) {
+	ctx, span := tracing.Start(ctx, "CartService.UpdateCart")
+	defer span.End()
+
+	lines, err := s.validateLines(ctx, update.Items)
+	if err != nil {
+		return nil, err
+	}
+	email := strings.TrimSpace(update.Email)
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return nil, fmt.Errorf("%w: invalid email", ErrInvalidCart)
+		}
+	}
+	phone := ""
+	if update.Phone != "" {
+		var ok bool
+		if phone, ok = messaging.E164(update.Phone); !ok {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCart, ErrInvalidPhone)
+		}
+	}
+
+	cart, err := s.findCart(ctx, key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		cart, err = newCart(key.CustomerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cart.Items = lines
+	cart.Subtotal = subtotal(lines)
+	if name := strings.TrimSpace(update.Name); name != "" {
+		cart.Name = name
+	}
+	if email != "" {
+		cart.Email = email
+	}
+	if phone != "" {
+		cart.Phone = phone
+	}
+	if err := s.save(ctx, cart); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+func (s *CartService) DeleteCart(ctx context.Context, key CartKey) error {
+	ctx, span := tracing.Start(ctx, "CartService.DeleteCart")
+	defer span.End()
+
+	cart, err := s.findCart(ctx, key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.Repository.DeleteCart(ctx, cart.ID)
+}
+
+// MergeGuestCart moves the guest cart with token into the customer's cart when they log in. Quantities of
+// products in both are added together, then capped at what is in stock.
+func (s *CartService) MergeGuestCart(ctx context.Context, token string, customerID string) (*models.Cart, error) {
+	ctx, span := tracing.Start(ctx, "CartService.MergeGuestCart")
+	defer span.End()
+
+	guest, err := s.Repository.GetCartByToken(ctx, token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return s.GetCart(ctx, CartKey{CustomerID: customerID})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := s.Repository.GetCartByCustomer(ctx, customerID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		cart, err = newCart(customerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cart.ReminderID == "" {
+		cart.ReminderID = guest.ReminderID
+	}
+	for _, line := range guest.Items {
+		merged := false
+		for i := range cart.Items {
+			if cart.Items[i].ProductID == line.ProductID {
+				cart.Items[i].Quantity += line.Quantity
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			cart.Items = append(cart.Items, line)
+		}
+	}
+
+	if _, err := s.refresh(ctx, cart); err != nil {
+		return nil, err
+	}
+	if err := s.save(ctx, cart); err != nil {
+		return nil, err
+	}
+	if err := s.Repository.DeleteCart(ctx, guest.ID); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// CompleteCart deletes the cart an order was placed from. If the customer had been reminded about the
+// cart, the reminder is credited with the order.
+func (s *CartService) CompleteCart(ctx context.Context, key CartKey, order models.Order) error {
+	ctx, span := tracing.Start(ctx, "CartService.CompleteCart")
+	defer span.End()
+
+	cart, err := s.findCart(ctx, key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if cart.ReminderID != "" && s.Reminders != nil {
+		err := s.Reminders.MarkRecovered(ctx, cart.ReminderID, order.ID, order.TotalAmount, order.CouponCode != "", time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return s.Repository.DeleteCart(ctx, cart.ID)
+}
+
+func (s *CartService) findCart(ctx context.Context, key CartKey) (*models.Cart, error) {
+	if key.CustomerID != "" {
+		return s.Repository.GetCartByCustomer(ctx, key.CustomerID)
+	}
+	if key.Token != "" {
+		return s.Repository.GetCartByToken(ctx, key.Token)
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+// save stores the cart and pushes back its expiry; carts that are not touched for the TTL are deleted by
+// the carts collection's TTL index.
+func (s *CartService) save(ctx context.Context, cart *models.Cart) error {
+	now := time.Now()
+	ttl := guestCartTTL
+	if cart.CustomerID != "" {
+		ttl = customerCartTTL
+	}
+	cart.UpdatedAt = now
+	cart.ExpiresAt = now.Add(ttl)
+	return s.Repository.SaveCart(ctx, *cart)
+}
+
+// validateLines checks requested items against the catalogue and builds cart lines at current prices.
+// Repeated products are combined.
+func (s *CartService) validateLines(ctx context.Context, items []models.CartItem) ([]models.CartLine, error) {
+	lines := []models.CartLine{}
+	index := map[string]int{}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity for product %s must be at least 1", ErrInvalidCart, item.ProductID)
+		}
+		if i, ok := index[item.ProductID]; ok {
+			lines[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(lines)
+		lines = append(lines, models.CartLine{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	if len(lines) > maxCartLines {
+		return nil, fmt.Errorf("%w: a cart can hold at most %d products", ErrInvalidCart, maxCartLines)
+	}
+
+	for i, line := range lines {
+		product, err := s.ProductRepository.GetProduct(ctx, line.ProductID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%w: product %s not found", ErrInvalidCart, line.ProductID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !product.InStock || product.Stock <= 0 {
+			return nil, fmt.Errorf("%w: %s is out of stock", ErrInvalidCart, product.Name)
+		}
+		if line.Quantity > product.Stock {
+			return nil, fmt.Errorf("%w: only %d of %s left", ErrInvalidCart, product.Stock, product.Name)
+		}
+		lines[i] = cartLine(product, line.Quantity)
+	}
+	return lines, nil
+}
+
+// refresh brings the cart up to date with the catalogue: prices are updated, quantities are capped at the
+// stock left and products that are gone or out of stock are removed. Each change is added to the cart's
+// notices, and refresh reports whether anything changed.
+func (s *CartService) refresh(ctx context.Context, cart *models.Cart) (bool, error) {
+	changed := false
+	lines := make([]models.CartLine, 0, len(cart.Items))
+	for _, line := range cart.Items {
+		product, err := s.ProductRepository.GetProduct(ctx, line.ProductID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			cart.Notices = append(cart.Notices, fmt.Sprintf("%s is no longer available and was removed", line.Name))
+			changed = true
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if !product.InStock || product.Stock <= 0 {
+			cart.Notices = append(cart.Notices, fmt.Sprintf("%s is out of stock and was removed", product.Name))
+			changed = true
+			continue
+		}
+
+		quantity := line.Quantity
+		if quantity > product.Stock {
+			quantity = product.Stock
+			cart.Notices = append(cart.Notices, fmt.Sprintf("Only %d of %s left, quantity reduced", product.Stock, product.Name))
+		}
+		if line.Price != 0 && line.Price != product.Price {
+			cart.Notices = append(cart.Notices, fmt.Sprintf("The price of %s changed from ₹%.2f to ₹%.2f", product.Name, line.Price, product.Price))
+		}
+		fresh := cartLine(product, quantity)
+		if fresh != line {
+			changed = true
+		}
+		lines = append(lines, fresh)
+	}
+	cart.Items = lines
+	cart.Subtotal = subtotal(lines)
+	return changed, nil
+}
+
+func cartLine(product *models.Product, quantity int) models.CartLine {
+	return models.CartLine{
+		ProductID: product.ID,
+		Name:      product.Name,
+		ImageURL:  product.ImageURL,
+		Quantity:  quantity,
+		Price:     product.Price,
+	}
+}
+
+func subtotal(lines []models.CartLine) float64 {
+	total := 0.0
+	for _, line := range lines {
+		total += line.Price * float64(line.Quantity)
+	}
+	return math.Round(total*100) / 100
+}
+
+func newCart(customerID string) (*models.Cart, error) {
+	now := time.Now()
+	cart := &models.Cart{
+		ID:         fmt.Sprintf("cart_%d", now.UnixNano()),
+		CustomerID: customerID,
+		Items:      []models.CartLine{},
+		CreatedAt:  now,
+	}
+	if customerID == "" {
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		cart.Token = token
+	}
+	return cart, nil
+}
+
+// newToken returns a random token that is hard to guess, for identifying guests.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
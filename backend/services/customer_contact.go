@@ -0,0 +1,52 @@
+package services
+
+import (
+	"strings"
+
+	"mangal-chai-backend/models"
+)
+
+// OrderAccess is who is asking for an order or its invoice: an admin, the logged-in customer who placed the
+// order, or a guest giving the order's phone or email.
+type OrderAccess struct {
+	Admin      bool
+	CustomerID string
+	Phone      string
+	Email      string
+}
+
+func (a OrderAccess) allows(order *models.Order) bool {
+	if a.Admin {
+		return true
+	}
+	if a.CustomerID != "" && a.CustomerID == order.CustomerID {
+		return true
+	}
+	return matchesContact(order.CustomerInfo, a.Phone, a.Email)
+}
+
+// matchesContact reports whether phone or email identifies the customer on an order. Phone numbers are
+// compared on their last ten digits so "+91 98765 43210" matches "9876543210".
+func matchesContact(info models.CustomerInfo, phone string, email string) bool {
+	if phone != "" && normalizePhone(phone) != "" && normalizePhone(phone) == normalizePhone(info.Phone) {
+		return true
+	}
+	if email != "" && strings.EqualFold(strings.TrimSpace(email), strings.TrimSpace(info.Email)) {
+		return true
+	}
+	return false
+}
+
+func normalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	normalized := digits.String()
+	if len(normalized) > 10 {
+		normalized = normalized[len(normalized)-10:]
+	}
+	return normalized
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OTPPurposeLogin is the OTP purpose for customer login.
+const OTPPurposeLogin = "login"
+
+var ErrCustomerNotFound = errors.New("customer not found")
+
+type CustomerServiceInterface interface {
+	RequestLoginOTP(ctx context.Context, phone string) error
+	Login(ctx context.Context, request LoginRequest) (*LoginResult, error)
+	GetCustomer(ctx context.Context, id string) (*models.Customer, error)
+}
+
+// LoginRequest logs a customer in with the code sent to their phone. CartToken is the guest cart to bring
+// along, if any.
+type LoginRequest struct {
+	Phone     string `json:"phone" binding:"required"`
+	OTP       string `json:"otp" binding:"required"`
+	CartToken string `json:"cart_token"`
+}
+
+type LoginResult struct {
+	Token     string           `json:"token"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	Customer  *models.Customer `json:"customer"`
+	Cart      *models.Cart     `json:"cart,omitempty"`
+}
+
+// CustomerService logs customers in with a one-time code sent to their phone; there are no passwords.
+type CustomerService struct {
+	Repository repositories.CustomerRepositoryInterface
+	OTP        OTPServiceInterface
+	Tokens     *CustomerTokens
+	Carts      CartServiceInterface
+}
+
+func (s *CustomerService) RequestLoginOTP(ctx context.Context, phone string) error {
+	ctx, span := tracing.Start(ctx, "CustomerService.RequestLoginOTP")
+	defer span.End()
+
+	if !s.loginEnabled() {
+		return ErrLoginDisabled
+	}
+	return s.OTP.RequestOTP(ctx, phone, OTPPurposeLogin)
+}
+
+// Login verifies the code, creates the customer on their first login and issues a login token. A guest
+// cart is merged into the customer's cart; failing to merge it does not fail the login.
+func (s *CustomerService) Login(ctx context.Context, request LoginRequest) (*LoginResult, error) {
+	ctx, span := tracing.Start(ctx, "CustomerService.Login")
+	defer span.End()
+
+	if !s.loginEnabled() {
+		return nil, ErrLoginDisabled
+	}
+	phone, err := s.OTP.VerifyOTP(ctx, request.Phone, OTPPurposeLogin, request.OTP)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	customer, err := s.Repository.RecordLogin(ctx, phone, now)
+	if err != nil {
+		return nil, err
+	}
+	token, expiresAt, err := s.Tokens.Issue(customer.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LoginResult{Token: token, ExpiresAt: expiresAt, Customer: customer}
+	if request.CartToken != "" && s.Carts != nil {
+		cart, err := s.Carts.MergeGuestCart(ctx, request.CartToken, customer.ID)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to merge guest cart into customer cart", "customer_id", customer.ID, "error", err)
+		}
+		result.Cart = cart
+	}
+	return result, nil
+}
+
+func (s *CustomerService) GetCustomer(ctx context.Context, id string) (*models.Customer, error) {
+	ctx, span := tracing.Start(ctx, "CustomerService.GetCustomer")
+	defer span.End()
+
+	customer, err := s.Repository.GetCustomer(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrCustomerNotFound
+	}
+	return customer, err
+}
+
+func (s *CustomerService) loginEnabled() bool {
+	return s.Tokens != nil && len(s.Tokens.Secret) > 0
+}
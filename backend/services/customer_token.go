@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrLoginDisabled = errors.New("customer login is not configured")
+	ErrInvalidToken  = errors.New("invalid or expired token")
+)
+
+// DefaultCustomerTokenTTL is how long a customer stays logged in.
+const DefaultCustomerTokenTTL = 30 * 24 * time.Hour
+
+// CustomerTokens issues and verifies the bearer tokens customers get when they log in. A token carries
+// the customer ID and its expiry, signed with HMAC-SHA256, so checking one needs no database lookup.
+type CustomerTokens struct {
+	Secret []byte
+	TTL    time.Duration
+}
+
+func (t *CustomerTokens) Issue(customerID string, now time.Time) (string, time.Time, error) {
+	if t == nil || len(t.Secret) == 0 {
+		return "", time.Time{}, ErrLoginDisabled
+	}
+	ttl := t.TTL
+	if ttl <= 0 {
+		ttl = DefaultCustomerTokenTTL
+	}
+	expiresAt := now.Add(ttl)
+	return signToken(t.Secret, tokenPurposeCustomer, customerID, expiresAt), expiresAt, nil
+}
+
+// Verify returns the customer ID in a valid, unexpired token.
+func (t *CustomerTokens) Verify(token string) (string, error) {
+	if t == nil || len(t.Secret) == 0 {
+		return "", ErrLoginDisabled
+	}
+	return verifyToken(t.Secret, tokenPurposeCustomer, token)
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/mail"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrGiftCardNotFound       = errors.New("gift card not found")
+	ErrInvalidGiftCard        = errors.New("gift card is invalid, expired or has no balance left")
+	ErrInvalidGiftCardRequest = errors.New("invalid gift card")
+	ErrStoreCreditChanged     = errors.New("store credit balance changed, please try again")
+	ErrGiftCardUsed           = errors.New("a gift card bought in the order has already been used")
+)
+
+const (
+	// MaxGiftCardAmount is the largest gift card the shop issues, in rupees.
+	MaxGiftCardAmount = 10000.0
+	giftCardValidity  = 365 * 24 * time.Hour
+	// ledgerPageSize is how many ledger entries are listed at most, newest first.
+	ledgerPageSize = 200
+)
+
+// StoredValue spends and credits gift card and wallet balances for orders and refunds, recording every
+// movement in the ledger.
+type StoredValue interface {
+	// RedeemGiftCard spends up to upTo from a gift card towards an order and returns the amount spent.
+	RedeemGiftCard(ctx context.Context, code string, upTo float64, orderID string) (float64, error)
+	// SpendWallet spends up to upTo of a customer's store credit towards an order and returns the amount
+	// spent, which is zero when they have none.
+	SpendWallet(ctx context.Context, customerID string, upTo float64, orderID string) (float64, error)
+	// Credit adds entry.Amount to the account named by entry and records entry in the ledger.
+	Credit(ctx context.Context, entry models.LedgerEntry) error
+}
+
+// GiftCardNotifier sends a gift card's code to its recipient on one channel, reporting whether they could
+// be reached on it.
+type GiftCardNotifier interface {
+	NotifyGiftCard(ctx context.Context, card models.GiftCard, link string) (bool, error)
+}
+
+type GiftCardServiceInterface interface {
+	IssueGiftCard(ctx context.Context, request IssueGiftCardRequest) (*models.GiftCard, error)
+	GetBalance(ctx context.Context, code string) (*GiftCardBalance, error)
+	GetWallet(ctx context.Context, customerID string) (*WalletStatement, error)
+	ListLedger(ctx context.Context, account string, accountID string) ([]models.LedgerEntry, error)
+}
+
+// IssueGiftCardRequest is a gift card issued by an admin, e.g. for corporate gifting. The code is emailed
+// to RecipientEmail when it is given.
+type IssueGiftCardRequest struct {
+	Amount         float64 `json:"amount" binding:"required"`
+	RecipientName  string  `json:"recipient_name"`
+	RecipientEmail string  `json:"recipient_email"`
+	Message        string  `json:"message"`
+}
+
+// GiftCardBalance is what anyone holding a gift card's code can see of it.
+type GiftCardBalance struct {
+	Code      string     `json:"code"`
+	Balance   float64    `json:"balance"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+}
+
+// WalletStatement is a customer's store credit and its latest movements.
+type WalletStatement struct {
+	Balance float64              `json:"balance"`
+	Entries []models.LedgerEntry `json:"entries"`
+}
+
+// GiftCardService issues gift cards, both bought in the shop and issued by admins, and manages the gift
+// card and wallet balances they are paid with.
+type GiftCardService struct {
+	Repository repositories.GiftCardRepositoryInterface
+	Wallets    repositories.WalletRepositoryInterface
+	Ledger     repositories.LedgerRepositoryInterface
+	Products   repositories.ProductRepositoryInterface
+	Notifiers  []GiftCardNotifier
+	ShopURL    string
+}
+
+func (s *GiftCardService) IssueGiftCard(ctx context.Context, request IssueGiftCardRequest) (*models.GiftCard, error) {
+	ctx, span := tracing.Start(ctx, "GiftCardService.IssueGiftCard")
+	defer span.End()
+
+	amount := roundRupees(request.Amount)
+	if amount <= 0 || amount > MaxGiftCardAmount {
+		return nil, fmt.Errorf("%w: amount must be between ₹1 and ₹%.0f", ErrInvalidGiftCardRequest, MaxGiftCardAmount)
+	}
+	email := strings.ToLower(strings.TrimSpace(request.RecipientEmail))
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return nil, fmt.Errorf("%w: invalid recipient email %q", ErrInvalidGiftCardRequest, request.RecipientEmail)
+		}
+	}
+
+	return s.issue(ctx, models.GiftCard{
+		InitialBalance: amount,
+		Source:         models.GiftCardSourceAdmin,
+		RecipientName:  strings.TrimSpace(request.RecipientName),
+		RecipientEmail: email,
+		Message:        strings.TrimSpace(request.Message),
+	})
+}
+
+// HandleOrderEvent issues the gift cards bought in an order once it is paid, one card per unit worth the
+// price it was bought at, and sends them to the buyer. Cards already issued for the order are skipped, so
+// a retried event issues only the missing ones, and none are issued if the order was cancelled meanwhile.
+func (s *GiftCardService) HandleOrderEvent(ctx context.Context, event string, order models.Order) error {
+	ctx, span := tracing.Start(ctx, "GiftCardService.HandleOrderEvent")
+	defer span.End()
+
+	if event != models.OrderEventPaymentConfirmed || order.Status == models.OrderStatusCancelled {
+		return nil
+	}
+	line := 0
+	for _, item := range order.Items {
+		product, err := s.Products.GetProduct(ctx, item.ProductID)
+		if err != nil {
+			return fmt.Errorf("loading product %s: %w", item.ProductID, err)
+		}
+		if product.Category != models.GiftCardCategory {
+			continue
+		}
+		for i := 0; i < item.Quantity; i++ {
+			line++
+			_, err := s.issue(ctx, models.GiftCard{
+				InitialBalance: item.Price,
+				Source:         models.GiftCardSourcePurchase,
+				OrderID:        order.ID,
+				OrderLine:      line,
+				ProductID:      item.ProductID,
+				RecipientName:  order.CustomerInfo.Name,
+				RecipientEmail: order.CustomerInfo.Email,
+			})
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("issuing gift card %d of order %s: %w", line, order.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// OrderGiftCards returns the gift cards bought in an order.
+func (s *GiftCardService) OrderGiftCards(ctx context.Context, orderID string) ([]models.GiftCard, error) {
+	ctx, span := tracing.Start(ctx, "GiftCardService.OrderGiftCards")
+	defer span.End()
+
+	return s.Repository.ListOrderGiftCards(ctx, orderID)
+}
+
+// VoidGiftCards takes back gift cards bought in an order that is being refunded, all of them or none: if
+// one has been spent from, those already voided are reinstated and ErrGiftCardUsed is returned.
+func (s *GiftCardService) VoidGiftCards(ctx context.Context, orderID string, refundID string, codes []string) error {
+	ctx, span := tracing.Start(ctx, "GiftCardService.VoidGiftCards")
+	defer span.End()
+
+	now := time.Now()
+	for i, code := range codes {
+		card, err := s.Repository.VoidGiftCard(ctx, code, now)
+		if err != nil {
+			s.reinstate(ctx, orderID, refundID, codes[:i])
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return fmt.Errorf("%w: %s", ErrGiftCardUsed, code)
+			}
+			return err
+		}
+		s.record(ctx, models.LedgerEntry{
+			Account:   models.LedgerAccountGiftCard,
+			AccountID: code,
+			Kind:      models.LedgerKindVoided,
+			Amount:    -card.InitialBalance,
+			Balance:   card.Balance,
+			OrderID:   orderID,
+			RefundID:  refundID,
+		})
+	}
+	return nil
+}
+
+// ReinstateGiftCards gives the cards voided by a refund that failed their balance back.
+func (s *GiftCardService) ReinstateGiftCards(ctx context.Context, orderID string, refundID string, codes []string) {
+	ctx, span := tracing.Start(ctx, "GiftCardService.ReinstateGiftCards")
+	defer span.End()
+
+	s.reinstate(ctx, orderID, refundID, codes)
+}
+
+func (s *GiftCardService) reinstate(ctx context.Context, orderID string, refundID string, codes []string) {
+	for _, code := range codes {
+		card, err := s.Repository.ReinstateGiftCard(ctx, code)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Reinstated by an earlier report of the same failure.
+			continue
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to reinstate gift card", "code", code, "order_id", orderID, "refund_id", refundID, "error", err)
+			continue
+		}
+		s.record(ctx, models.LedgerEntry{
+			Account:   models.LedgerAccountGiftCard,
+			AccountID: code,
+			Kind:      models.LedgerKindReinstated,
+			Amount:    card.InitialBalance,
+			Balance:   card.Balance,
+			OrderID:   orderID,
+			RefundID:  refundID,
+		})
+	}
+}
+
+// issue gives card a fresh code and its full balance, stores it and sends it to the recipient.
+func (s *GiftCardService) issue(ctx context.Context, card models.GiftCard) (*models.GiftCard, error) {
+	code, err := newGiftCardCode()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(giftCardValidity)
+	card.Code = code
+	card.Balance = card.InitialBalance
+	card.IssuedAt = now
+	card.ExpiresAt = &expiresAt
+	if err := s.Repository.CreateGiftCard(ctx, card); err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, models.LedgerEntry{
+		Account:   models.LedgerAccountGiftCard,
+		AccountID: card.Code,
+		Kind:      models.LedgerKindIssued,
+		Amount:    card.InitialBalance,
+		Balance:   card.Balance,
+		OrderID:   card.OrderID,
+	})
+	for _, notifier := range s.Notifiers {
+		if _, err := notifier.NotifyGiftCard(ctx, card, s.ShopURL); err != nil {
+			logging.FromContext(ctx).Error("Failed to send gift card", "code", card.Code, "order_id", card.OrderID, "error", err)
+		}
+	}
+	return &card, nil
+}
+
+func (s *GiftCardService) GetBalance(ctx context.Context, code string) (*GiftCardBalance, error) {
+	ctx, span := tracing.Start(ctx, "GiftCardService.GetBalance")
+	defer span.End()
+
+	card, err := s.Repository.GetGiftCard(ctx, cleanGiftCardCode(code))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrGiftCardNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &GiftCardBalance{
+		Code:      card.Code,
+		Balance:   roundRupees(card.Balance),
+		ExpiresAt: card.ExpiresAt,
+		Expired:   card.ExpiresAt != nil && !card.ExpiresAt.After(time.Now()),
+	}, nil
+}
+
+func (s *GiftCardService) GetWallet(ctx context.Context, customerID string) (*WalletStatement, error) {
+	ctx, span := tracing.Start(ctx, "GiftCardService.GetWallet")
+	defer span.End()
+
+	statement := &WalletStatement{}
+	wallet, err := s.Wallets.GetWallet(ctx, customerID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	if wallet != nil {
+		statement.Balance = roundRupees(wallet.Balance)
+	}
+	statement.Entries, err = s.Ledger.ListEntries(ctx, models.LedgerAccountWallet, customerID, ledgerPageSize)
+	if err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// ListLedger returns the latest balance movements, optionally only those of one account.
+func (s *GiftCardService) ListLedger(ctx context.Context, account string, accountID string) ([]models.LedgerEntry, error) {
+	ctx, span := tracing.Start(ctx, "GiftCardService.ListLedger")
+	defer span.End()
+
+	if account == models.LedgerAccountGiftCard {
+		accountID = cleanGiftCardCode(accountID)
+	}
+	return s.Ledger.ListEntries(ctx, account, accountID, ledgerPageSize)
+}
+
+func (s *GiftCardService) RedeemGiftCard(ctx context.Context, code string, upTo float64, orderID string) (float64, error) {
+	ctx, span := tracing.Start(ctx, "GiftCardService.RedeemGiftCard")
+	defer span.End()
+
+	code = cleanGiftCardCode(code)
+	card, err := s.Repository.GetGiftCard(ctx, code)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, ErrInvalidGiftCard
+	}
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	amount := roundRupees(math.Min(card.Balance, upTo))
+	if amount <= 0 || (card.ExpiresAt != nil && !card.ExpiresAt.After(now)) {
+		return 0, ErrInvalidGiftCard
+	}
+
+	card, err = s.Repository.Debit(ctx, code, amount, now)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Spent elsewhere since it was read.
+		return 0, ErrInvalidGiftCard
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.record(ctx, models.LedgerEntry{
+		Account:   models.LedgerAccountGiftCard,
+		AccountID: code,
+		Kind:      models.LedgerKindSpent,
+		Amount:    -amount,
+		Balance:   card.Balance,
+		OrderID:   orderID,
+	})
+	return amount, nil
+}
+
+func (s *GiftCardService) SpendWallet(ctx context.Context, customerID string, upTo float64, orderID string) (float64, error) {
+	ctx, span := tracing.Start(ctx, "GiftCardService.SpendWallet")
+	defer span.End()
+
+	wallet, err := s.Wallets.GetWallet(ctx, customerID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	amount := roundRupees(math.Min(wallet.Balance, upTo))
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	wallet, err = s.Wallets.Debit(ctx, customerID, amount)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, ErrStoreCreditChanged
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.record(ctx, models.LedgerEntry{
+		Account:   models.LedgerAccountWallet,
+		AccountID: customerID,
+		Kind:      models.LedgerKindSpent,
+		Amount:    -amount,
+		Balance:   wallet.Balance,
+		OrderID:   orderID,
+	})
+	return amount, nil
+}
+
+func (s *GiftCardService) Credit(ctx context.Context, entry models.LedgerEntry) error {
+	ctx, span := tracing.Start(ctx, "GiftCardService.Credit")
+	defer span.End()
+
+	switch entry.Account {
+	case models.LedgerAccountGiftCard:
+		card, err := s.Repository.Credit(ctx, entry.AccountID, entry.Amount)
+		if err != nil {
+			return err
+		}
+		entry.Balance = card.Balance
+	case models.LedgerAccountWallet:
+		wallet, err := s.Wallets.Credit(ctx, entry.AccountID, entry.Amount)
+		if err != nil {
+			return err
+		}
+		entry.Balance = wallet.Balance
+	default:
+		return fmt.Errorf("unknown ledger account %q", entry.Account)
+	}
+	s.record(ctx, entry)
+	return nil
+}
+
+// record adds a movement to the ledger. The balance has already moved by then, so a failure is logged
+// with the entry rather than undoing it.
+func (s *GiftCardService) record(ctx context.Context, entry models.LedgerEntry) {
+	now := time.Now()
+	entry.ID = fmt.Sprintf("led_%d", now.UnixNano())
+	entry.Balance = roundRupees(entry.Balance)
+	entry.CreatedAt = now
+	if err := s.Ledger.Record(ctx, entry); err != nil {
+		logging.FromContext(ctx).Error("Failed to record ledger entry", "entry", entry, "error", err)
+	}
+}
+
+// newGiftCardCode generates a code like MC-7KQ2-X9TD-4HNP, from characters that cannot be mistaken for one
+// another.
+func newGiftCardCode() (string, error) {
+	groups := make([]string, 3)
+	for g := range groups {
+		group := make([]byte, 4)
+		for i := range group {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCouponChars))))
+			if err != nil {
+				return "", err
+			}
+			group[i] = recoveryCouponChars[n.Int64()]
+		}
+		groups[g] = string(group)
+	}
+	return "MC-" + strings.Join(groups, "-"), nil
+}
+
+func cleanGiftCardCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
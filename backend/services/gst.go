@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mangal-chai-backend/models"
+)
+
+// gstStates are the GST state codes, which are also the first two digits of a GSTIN.
+var gstStates = map[string]string{
+	"01": "Jammu and Kashmir",
+	"02": "Himachal Pradesh",
+	"03": "Punjab",
+	"04": "Chandigarh",
+	"05": "Uttarakhand",
+	"06": "Haryana",
+	"07": "Delhi",
+	"08": "Rajasthan",
+	"09": "Uttar Pradesh",
+	"10": "Bihar",
+	"11": "Sikkim",
+	"12": "Arunachal Pradesh",
+	"13": "Nagaland",
+	"14": "Manipur",
+	"15": "Mizoram",
+	"16": "Tripura",
+	"17": "Meghalaya",
+	"18": "Assam",
+	"19": "West Bengal",
+	"20": "Jharkhand",
+	"21": "Odisha",
+	"22": "Chhattisgarh",
+	"23": "Madhya Pradesh",
+	"24": "Gujarat",
+	"26": "Dadra and Nagar Haveli and Daman and Diu",
+	"27": "Maharashtra",
+	"29": "Karnataka",
+	"30": "Goa",
+	"31": "Lakshadweep",
+	"32": "Kerala",
+	"33": "Tamil Nadu",
+	"34": "Puducherry",
+	"35": "Andaman and Nicobar Islands",
+	"36": "Telangana",
+	"37": "Andhra Pradesh",
+	"38": "Ladakh",
+}
+
+// gstStateAliases are older or common names for states found in addresses.
+var gstStateAliases = map[string]string{
+	"Orissa":      "21",
+	"Pondicherry": "34",
+	"New Delhi":   "07",
+	"Daman":       "26",
+	"Silvassa":    "26",
+}
+
+var gstinPattern = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+
+const gstinChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// ValidGSTIN reports whether gstin is a well-formed GSTIN for a known state, with a correct check digit.
+func ValidGSTIN(gstin string) bool {
+	if !gstinPattern.MatchString(gstin) {
+		return false
+	}
+	if _, ok := gstStates[gstin[:2]]; !ok {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 14; i++ {
+		product := strings.IndexByte(gstinChars, gstin[i]) * (i%2 + 1)
+		sum += product/len(gstinChars) + product%len(gstinChars)
+	}
+	check := (len(gstinChars) - sum%len(gstinChars)) % len(gstinChars)
+	return gstin[14] == gstinChars[check]
+}
+
+func cleanGSTIN(gstin string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(gstin), " ", ""))
+}
+
+// checkCustomerGSTIN tidies the GSTIN a business customer gave for their invoices, failing with
+// ErrInvalidGSTIN if it is not valid. Most customers give none.
+func checkCustomerGSTIN(info *models.CustomerInfo) error {
+	if strings.TrimSpace(info.GSTIN) == "" {
+		info.GSTIN = ""
+		return nil
+	}
+	gstin := cleanGSTIN(info.GSTIN)
+	if !ValidGSTIN(gstin) {
+		return fmt.Errorf("%w %q", ErrInvalidGSTIN, info.GSTIN)
+	}
+	info.GSTIN = gstin
+	return nil
+}
+
+// addressStates match the state names and aliases in addresses, longest name first so that "Andhra
+// Pradesh" is not mistaken for a shorter name.
+var addressStates = func() []addressState {
+	names := make(map[string]string)
+	for code, name := range gstStates {
+		names[name] = code
+	}
+	for alias, code := range gstStateAliases {
+		names[alias] = code
+	}
+	var states []addressState
+	for name, code := range names {
+		states = append(states, addressState{
+			name:    name,
+			code:    code,
+			pattern: regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`),
+		})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if len(states[i].name) != len(states[j].name) {
+			return len(states[i].name) > len(states[j].name)
+		}
+		return states[i].name < states[j].name
+	})
+	return states
+}()
+
+type addressState struct {
+	name    string
+	code    string
+	pattern *regexp.Regexp
+}
+
+// stateFromAddress finds the GST state code of the state named in a free-text address, or "" when it names
+// none.
+func stateFromAddress(address string) string {
+	for _, state := range addressStates {
+		if state.pattern.MatchString(address) {
+			return state.code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	ErrInvalidGSTIN    = errors.New("invalid GSTIN")
+)
+
+const (
+	// DefaultHSNCode is the HSN code printed for products without their own: 0902, tea.
+	DefaultHSNCode = "0902"
+	// DefaultGSTRate is the GST rate, in percent, of products without their own.
+	DefaultGSTRate = 5.0
+	// invoicePrefix starts every invoice number. GST invoice numbers are at most 16 characters, which leaves
+	// five digits for the sequence.
+	invoicePrefix = "MC"
+)
+
+// InvoiceRenderer lays out an invoice as a PDF.
+type InvoiceRenderer interface {
+	Render(invoice models.Invoice) ([]byte, error)
+}
+
+// InvoiceStore keeps invoice PDFs by name. Open returns an error wrapping fs.ErrNotExist for a name it does
+// not have.
+type InvoiceStore interface {
+	Save(name string, data []byte) error
+	Open(name string) ([]byte, error)
+}
+
+type InvoiceServiceInterface interface {
+	GetInvoicePDF(ctx context.Context, orderID string, access OrderAccess) (*models.Invoice, []byte, error)
+}
+
+// InvoiceService issues a GST tax invoice for every paid order and keeps its PDF in Store. Seller must carry
+// the shop's GSTIN.
+type InvoiceService struct {
+	Repository repositories.InvoiceRepositoryInterface
+	Orders     repositories.OrderRepositoryInterface
+	Products   repositories.ProductRepositoryInterface
+	Renderer   InvoiceRenderer
+	Store      InvoiceStore
+	Seller     models.InvoiceParty
+	DefaultHSN string
+	// DefaultGSTRate applies to products without a rate of their own, in percent.
+	DefaultGSTRate float64
+}
+
+// HandleOrderEvent issues the invoice for an order once it is paid. An order has one invoice, so a retried
+// event only finishes storing the PDF if that is what failed.
+func (s *InvoiceService) HandleOrderEvent(ctx context.Context, event string, order models.Order) error {
+	ctx, span := tracing.Start(ctx, "InvoiceService.HandleOrderEvent")
+	defer span.End()
+
+	if event != models.OrderEventPaymentConfirmed {
+		return nil
+	}
+	invoice, err := s.Repository.GetInvoiceByOrder(ctx, order.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		invoice, err = s.issue(ctx, order)
+	}
+	if err != nil {
+		return err
+	}
+	if invoice.File != "" {
+		return nil
+	}
+	_, err = s.store(ctx, invoice)
+	return err
+}
+
+// GetInvoicePDF returns an order's invoice and its PDF to someone allowed to see the order. The PDF is
+// rendered again from the invoice if it was never stored or has gone missing.
+func (s *InvoiceService) GetInvoicePDF(ctx context.Context, orderID string, access OrderAccess) (*models.Invoice, []byte, error) {
+	ctx, span := tracing.Start(ctx, "InvoiceService.GetInvoicePDF")
+	defer span.End()
+
+	order, err := s.Orders.GetOrder(ctx, orderID)
+	if err != nil || !access.allows(order) {
+		return nil, nil, ErrOrderNotFound
+	}
+	invoice, err := s.Repository.GetInvoiceByOrder(ctx, orderID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if invoice.File != "" {
+		data, err := s.Store.Open(invoice.File)
+		if err == nil {
+			return invoice, data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+	}
+	data, err := s.store(ctx, invoice)
+	if err != nil {
+		return nil, nil, err
+	}
+	return invoice, data, nil
+}
+
+// issue numbers and saves the invoice for an order. A number taken by an attempt that then fails to save
+// is left unused, so the sequence can have gaps but never repeats.
+func (s *InvoiceService) issue(ctx context.Context, order models.Order) (*models.Invoice, error) {
+	now := time.Now()
+	invoice, err := s.build(ctx, order, now)
+	if err != nil {
+		return nil, err
+	}
+	sequence, err := s.Repository.NextSequence(ctx, invoice.FinancialYear)
+	if err != nil {
+		return nil, err
+	}
+	invoice.Number = fmt.Sprintf("%s/%s/%05d", invoicePrefix, invoice.FinancialYear, sequence)
+
+	err = s.Repository.CreateInvoice(ctx, invoice)
+	if mongo.IsDuplicateKeyError(err) {
+		// Issued by another attempt in the meantime.
+		return s.Repository.GetInvoiceByOrder(ctx, order.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// store renders an invoice, saves the PDF and records where it is.
+func (s *InvoiceService) store(ctx context.Context, invoice *models.Invoice) ([]byte, error) {
+	data, err := s.Renderer.Render(*invoice)
+	if err != nil {
+		return nil, fmt.Errorf("rendering invoice %s: %w", invoice.Number, err)
+	}
+	file := fmt.Sprintf("%s/%s.pdf", invoice.FinancialYear, strings.ReplaceAll(invoice.Number, "/", "-"))
+	if err := s.Store.Save(file, data); err != nil {
+		return nil, fmt.Errorf("storing invoice %s: %w", invoice.Number, err)
+	}
+	if err := s.Repository.SetFile(ctx, invoice.OrderID, file); err != nil {
+		return nil, err
+	}
+	invoice.File = file
+	return data, nil
+}
+
+// build works out the invoice for an order. Prices include GST, so each line's tax is taken out of what was
+// paid for it. Order discounts are shared between the lines in proportion to their value.
+func (s *InvoiceService) build(ctx context.Context, order models.Order, issuedAt time.Time) (models.Invoice, error) {
+	if !ValidGSTIN(s.Seller.GSTIN) {
+		return models.Invoice{}, fmt.Errorf("%w: shop GSTIN %q", ErrInvalidGSTIN, s.Seller.GSTIN)
+	}
+	seller := s.Seller
+	seller.StateCode = seller.GSTIN[:2]
+	seller.State = gstStates[seller.StateCode]
+	buyer := models.InvoiceParty{
+		Name:    order.CustomerInfo.Name,
+		Address: order.CustomerInfo.Address,
+		GSTIN:   cleanGSTIN(order.CustomerInfo.GSTIN),
+	}
+	buyer.StateCode = buyerState(buyer, seller.StateCode)
+	buyer.State = gstStates[buyer.StateCode]
+
+	invoice := models.Invoice{
+		FinancialYear: financialYear(issuedAt),
+		OrderID:       order.ID,
+		Seller:        seller,
+		Buyer:         buyer,
+		PlaceOfSupply: buyer.StateCode,
+		InterState:    buyer.StateCode != seller.StateCode,
+		IssuedAt:      issuedAt,
+	}
+
+	subtotal := 0.0
+	for _, item := range order.Items {
+		subtotal += item.Price * float64(item.Quantity)
+	}
+	discount := roundRupees(subtotal - order.TotalAmount)
+	discountLeft := discount
+	for i, item := range order.Items {
+		product, err := s.Products.GetProduct(ctx, item.ProductID)
+		if err != nil {
+			return models.Invoice{}, fmt.Errorf("loading product %s: %w", item.ProductID, err)
+		}
+		line := models.InvoiceLine{
+			Description: product.Name,
+			HSN:         s.hsnCode(product),
+			Quantity:    item.Quantity,
+			UnitPrice:   item.Price,
+			GSTRate:     s.gstRate(product),
+		}
+		if product.Weight != "" {
+			line.Description += " (" + product.Weight + ")"
+		}
+		amount := roundRupees(item.Price * float64(item.Quantity))
+		if i == len(order.Items)-1 {
+			line.Discount = discountLeft
+		} else if subtotal > 0 {
+			line.Discount = roundRupees(discount * amount / subtotal)
+			discountLeft = roundRupees(discountLeft - line.Discount)
+		}
+		line.Total = roundRupees(amount - line.Discount)
+		line.TaxableValue = roundRupees(line.Total / (1 + line.GSTRate/100))
+		tax := roundRupees(line.Total - line.TaxableValue)
+		if invoice.InterState {
+			line.IGST = tax
+		} else {
+			line.CGST = roundRupees(tax / 2)
+			line.SGST = roundRupees(tax - line.CGST)
+		}
+
+		invoice.Lines = append(invoice.Lines, line)
+		invoice.TaxableValue = roundRupees(invoice.TaxableValue + line.TaxableValue)
+		invoice.CGST = roundRupees(invoice.CGST + line.CGST)
+		invoice.SGST = roundRupees(invoice.SGST + line.SGST)
+		invoice.IGST = roundRupees(invoice.IGST + line.IGST)
+		invoice.Total = roundRupees(invoice.Total + line.Total)
+	}
+	return invoice, nil
+}
+
+// hsnCode is the HSN code printed for a product. Gift cards are not a supply of goods and have none.
+func (s *InvoiceService) hsnCode(product *models.Product) string {
+	switch {
+	case product.Category == models.GiftCardCategory:
+		return ""
+	case product.HSNCode != "":
+		return product.HSNCode
+	case s.DefaultHSN != "":
+		return s.DefaultHSN
+	}
+	return DefaultHSNCode
+}
+
+// gstRate is the GST rate of a product, in percent. Gift cards carry no GST when they are sold.
+func (s *InvoiceService) gstRate(product *models.Product) float64 {
+	switch {
+	case product.Category == models.GiftCardCategory:
+		return 0
+	case product.GSTRate > 0:
+		return product.GSTRate
+	case s.DefaultGSTRate > 0:
+		return s.DefaultGSTRate
+	}
+	return DefaultGSTRate
+}
+
+// buyerState is the place of supply: the state of a business buyer's GSTIN, otherwise the state named in
+// the delivery address, otherwise the seller's own state.
+func buyerState(buyer models.InvoiceParty, sellerState string) string {
+	if ValidGSTIN(buyer.GSTIN) {
+		return buyer.GSTIN[:2]
+	}
+	if code := stateFromAddress(buyer.Address); code != "" {
+		return code
+	}
+	return sellerState
+}
+
+// financialYear is the Indian financial year, April to March, that t falls in, e.g. "2026-27".
+func financialYear(t time.Time) string {
+	t = t.In(shopLocation)
+	year := t.Year()
+	if t.Month() < time.April {
+		year--
+	}
+	return fmt.Sprintf("%d-%02d", year, (year+1)%100)
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrInvalidJobQuery = errors.New("invalid job query")
+)
+
+// jobListLimit caps the admin job lists; failing jobs are few, and a backlog larger than this needs
+// investigating rather than paging through.
+const jobListLimit = 200
+
+type JobServiceInterface interface {
+	ListJobs(ctx context.Context, status string) ([]models.Job, error)
+	ListDeadJobs(ctx context.Context) ([]models.Job, error)
+	RetryJob(ctx context.Context, id string) (*models.Job, error)
+}
+
+// JobService lets staff inspect the background job queue and retry jobs that were dead-lettered.
+type JobService struct {
+	Repository repositories.JobRepositoryInterface
+}
+
+func (s *JobService) ListJobs(ctx context.Context, status string) ([]models.Job, error) {
+	ctx, span := tracing.Start(ctx, "JobService.ListJobs")
+	defer span.End()
+
+	switch status {
+	case "", models.JobStatusQueued, models.JobStatusRunning, models.JobStatusSucceeded:
+	default:
+		return nil, fmt.Errorf("%w: status must be one of %s, %s or %s", ErrInvalidJobQuery,
+			models.JobStatusQueued, models.JobStatusRunning, models.JobStatusSucceeded)
+	}
+	return s.Repository.ListJobs(ctx, status, jobListLimit)
+}
+
+func (s *JobService) ListDeadJobs(ctx context.Context) ([]models.Job, error) {
+	ctx, span := tracing.Start(ctx, "JobService.ListDeadJobs")
+	defer span.End()
+
+	return s.Repository.ListDeadJobs(ctx, jobListLimit)
+}
+
+// RetryJob puts a dead-lettered job back on the queue to run straight away with a fresh set of attempts.
+func (s *JobService) RetryJob(ctx context.Context, id string) (*models.Job, error) {
+	ctx, span := tracing.Start(ctx, "JobService.RetryJob")
+	defer span.End()
+
+	job, err := s.Repository.Requeue(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrJobNotFound
+	}
+	return job, err
+}
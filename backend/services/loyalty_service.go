@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidPoints      = errors.New("loyalty points can only be redeemed by logged-in customers")
+	ErrInsufficientPoints = errors.New("not enough loyalty points")
+)
+
+const (
+	// DefaultPointsPer100 is how many points a customer earns for every ₹100 paid.
+	DefaultPointsPer100 = 5.0
+	// DefaultPointValue is what a point is worth at checkout, in rupees.
+	DefaultPointValue = 0.5
+	// DefaultPointsTTL is how long points can be spent after they are earned.
+	DefaultPointsTTL = 365 * 24 * time.Hour
+
+	pointsExpiryBatch = 200
+	pointsPageSize    = 100
+)
+
+// PointsRedeemer spends and gives back customers' loyalty points for orders.
+type PointsRedeemer interface {
+	// RedeemPoints spends up to points, as many as are worth no more than upTo, and returns the points
+	// spent and the discount they give.
+	RedeemPoints(ctx context.Context, customerID string, points int, upTo float64, orderID string) (int, float64, error)
+	ReturnPoints(ctx context.Context, customerID string, points int, orderID string) error
+}
+
+// PointsReverser takes back the points an order earned once it has been refunded, in proportion to the
+// amount refunded so far.
+type PointsReverser interface {
+	ReversePoints(ctx context.Context, order models.Order, refunded float64) error
+}
+
+type LoyaltyServiceInterface interface {
+	GetAccount(ctx context.Context, customerID string) (*LoyaltyAccount, error)
+}
+
+// LoyaltyAccount is a customer's points balance, what it is worth at checkout and its latest movements.
+type LoyaltyAccount struct {
+	Balance    int                  `json:"balance"`
+	Value      float64              `json:"value"`
+	PointValue float64              `json:"point_value"`
+	Entries    []models.PointsEntry `json:"entries"`
+}
+
+// LoyaltyService runs the loyalty programme: logged-in customers earn points on delivered orders and spend
+// them as a discount at checkout. Points are spent oldest first and expire PointsTTL after they are earned.
+type LoyaltyService struct {
+	Repository   repositories.LoyaltyRepositoryInterface
+	Products     repositories.ProductRepositoryInterface
+	PointsPer100 float64
+	PointValue   float64
+	// Multipliers scale the points earned on products in a category; other categories earn at 1x. Gift
+	// cards never earn points.
+	Multipliers map[string]float64
+	PointsTTL   time.Duration
+}
+
+func (s *LoyaltyService) GetAccount(ctx context.Context, customerID string) (*LoyaltyAccount, error) {
+	ctx, span := tracing.Start(ctx, "LoyaltyService.GetAccount")
+	defer span.End()
+
+	balance, err := s.Repository.Balance(ctx, customerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.Repository.ListEntries(ctx, customerID, pointsPageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &LoyaltyAccount{
+		Balance:    balance,
+		Value:      roundRupees(float64(balance) * s.pointValue()),
+		PointValue: s.pointValue(),
+		Entries:    entries,
+	}, nil
+}
+
+// HandleOrderEvent credits the points a logged-in customer's order earned once it is delivered. An order
+// earns points once, so a retried event does nothing.
+func (s *LoyaltyService) HandleOrderEvent(ctx context.Context, event string, order models.Order) error {
+	ctx, span := tracing.Start(ctx, "LoyaltyService.HandleOrderEvent")
+	defer span.End()
+
+	if event != models.OrderEventDelivered || order.CustomerID == "" {
+		return nil
+	}
+	points, err := s.earnedPoints(ctx, order)
+	if err != nil || points <= 0 {
+		return err
+	}
+	err = s.credit(ctx, order.CustomerID, models.PointsKindEarned, points, order.ID)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// earnedPoints is what an order earns: its items at their category's multiplier, scaled to what was paid
+// after discounts.
+func (s *LoyaltyService) earnedPoints(ctx context.Context, order models.Order) (int, error) {
+	subtotal, eligible := 0.0, 0.0
+	for _, item := range order.Items {
+		product, err := s.Products.GetProduct(ctx, item.ProductID)
+		if err != nil {
+			return 0, fmt.Errorf("loading product %s: %w", item.ProductID, err)
+		}
+		amount := item.Price * float64(item.Quantity)
+		subtotal += amount
+		eligible += amount * s.multiplier(product.Category)
+	}
+	if subtotal <= 0 {
+		return 0, nil
+	}
+	paid := eligible * order.TotalAmount / subtotal
+	return int(math.Floor(paid * s.pointsPer100() / 100)), nil
+}
+
+func (s *LoyaltyService) RedeemPoints(ctx context.Context, customerID string, points int, upTo float64, orderID string) (int, float64, error) {
+	ctx, span := tracing.Start(ctx, "LoyaltyService.RedeemPoints")
+	defer span.End()
+
+	if customerID == "" || points <= 0 {
+		return 0, 0, ErrInvalidPoints
+	}
+	value := s.pointValue()
+	if affordable := int(math.Floor(upTo / value)); points > affordable {
+		points = affordable
+	}
+	if points <= 0 {
+		return 0, 0, nil
+	}
+
+	from, _, err := s.take(ctx, customerID, points, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	s.record(ctx, models.PointsEntry{CustomerID: customerID, Kind: models.PointsKindRedeemed, Points: -points, OrderID: orderID, From: from})
+	return points, roundRupees(float64(points) * value), nil
+}
+
+// ReturnPoints gives back points redeemed for an order that was never completed to the lots they were
+// spent from, so they expire when they would have. Points whose lots were not recorded come back as a
+// fresh credit.
+func (s *LoyaltyService) ReturnPoints(ctx context.Context, customerID string, points int, orderID string) error {
+	ctx, span := tracing.Start(ctx, "LoyaltyService.ReturnPoints")
+	defer span.End()
+
+	entries, err := s.Repository.ListOrderEntries(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	var from []models.LotDebit
+	recorded := 0
+	for _, entry := range entries {
+		if entry.Kind != models.PointsKindRedeemed || entry.CustomerID != customerID {
+			continue
+		}
+		for _, debit := range entry.From {
+			from = append(from, debit)
+			recorded += debit.Points
+		}
+	}
+	if recorded != points {
+		return s.credit(ctx, customerID, models.PointsKindReleased, points, orderID)
+	}
+
+	returned := 0
+	for _, debit := range from {
+		if returnErr := s.Repository.ReturnToLot(ctx, debit.LotID, debit.Points); returnErr != nil {
+			logging.FromContext(ctx).Error("Failed to return points to lot", "lot_id", debit.LotID, "points", debit.Points, "order_id", orderID, "error", returnErr)
+			err = returnErr
+			continue
+		}
+		returned += debit.Points
+	}
+	if returned > 0 {
+		s.record(ctx, models.PointsEntry{CustomerID: customerID, Kind: models.PointsKindReleased, Points: returned, OrderID: orderID})
+	}
+	return err
+}
+
+// ReversePoints takes back the share of the points the order earned that matches the share of it that
+// has been refunded. Points the customer has already spent cannot be taken back; the shortfall is taken
+// from their next points by a later refund of the same order.
+func (s *LoyaltyService) ReversePoints(ctx context.Context, order models.Order, refunded float64) error {
+	ctx, span := tracing.Start(ctx, "LoyaltyService.ReversePoints")
+	defer span.End()
+
+	if order.CustomerID == "" || order.TotalAmount <= 0 {
+		return nil
+	}
+	entries, err := s.Repository.ListOrderEntries(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	earned, reversed := 0, 0
+	for _, entry := range entries {
+		switch entry.Kind {
+		case models.PointsKindEarned:
+			earned += entry.Points
+		case models.PointsKindReversed:
+			reversed -= entry.Points
+		}
+	}
+	share := math.Min(refunded/order.TotalAmount, 1)
+	owed := int(math.Round(float64(earned)*share)) - reversed
+	if owed <= 0 {
+		return nil
+	}
+
+	_, taken, err := s.take(ctx, order.CustomerID, owed, false)
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		s.record(ctx, models.PointsEntry{CustomerID: order.CustomerID, Kind: models.PointsKindReversed, Points: -taken, OrderID: order.ID})
+	}
+	return nil
+}
+
+// ExpirePoints clears the points left in lots that have expired and returns how many lots it expired.
+func (s *LoyaltyService) ExpirePoints(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "LoyaltyService.ExpirePoints")
+	defer span.End()
+
+	lots, err := s.Repository.ListExpiredLots(ctx, time.Now(), pointsExpiryBatch)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, lot := range lots {
+		before, err := s.Repository.ExpireLot(ctx, lot.ID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Spent since it was listed.
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		s.record(ctx, models.PointsEntry{CustomerID: lot.CustomerID, Kind: models.PointsKindExpired, Points: -before.Remaining})
+		expired++
+	}
+	return expired, nil
+}
+
+// take spends points from a customer's lots, soonest to expire first, and returns what it took from each
+// lot and how many it took in all. With all set it takes all of them or none, failing with
+// ErrInsufficientPoints; otherwise it takes as many as the customer has.
+func (s *LoyaltyService) take(ctx context.Context, customerID string, points int, all bool) ([]models.LotDebit, int, error) {
+	now := time.Now()
+	lots, err := s.Repository.ListLots(ctx, customerID, now)
+	if err != nil {
+		return nil, 0, err
+	}
+	available := 0
+	for _, lot := range lots {
+		available += lot.Remaining
+	}
+	if all && available < points {
+		return nil, 0, ErrInsufficientPoints
+	}
+
+	taken := 0
+	var from []models.LotDebit
+	for _, lot := range lots {
+		if taken == points {
+			break
+		}
+		n := min(lot.Remaining, points-taken)
+		err := s.Repository.TakeFromLot(ctx, lot.ID, n, now)
+		if err != nil {
+			// Spent or expired since it was listed; put back what was taken so the caller can try again.
+			for _, prev := range from {
+				if returnErr := s.Repository.ReturnToLot(ctx, prev.LotID, prev.Points); returnErr != nil {
+					logging.FromContext(ctx).Error("Failed to return points to lot", "lot_id", prev.LotID, "points", prev.Points, "error", returnErr)
+				}
+			}
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, 0, ErrInsufficientPoints
+			}
+			return nil, 0, err
+		}
+		from = append(from, models.LotDebit{LotID: lot.ID, Points: n})
+		taken += n
+	}
+	return from, taken, nil
+}
+
+// credit adds a lot of points that can be spent until PointsTTL from now.
+func (s *LoyaltyService) credit(ctx context.Context, customerID string, kind string, points int, orderID string) error {
+	now := time.Now()
+	expiresAt := now.Add(s.pointsTTL())
+	return s.Repository.Record(ctx, models.PointsEntry{
+		ID:         fmt.Sprintf("pts_%d", now.UnixNano()),
+		CustomerID: customerID,
+		Kind:       kind,
+		Points:     points,
+		Remaining:  points,
+		OrderID:    orderID,
+		ExpiresAt:  &expiresAt,
+		CreatedAt:  now,
+	})
+}
+
+// record adds a debit to the ledger. The points have already been taken by then, so a failure is logged
+// with the entry rather than undoing it.
+func (s *LoyaltyService) record(ctx context.Context, entry models.PointsEntry) {
+	now := time.Now()
+	entry.ID = fmt.Sprintf("pts_%d", now.UnixNano())
+	entry.CreatedAt = now
+	if err := s.Repository.Record(ctx, entry); err != nil {
+		logging.FromContext(ctx).Error("Failed to record loyalty entry", "entry", entry, "error", err)
+	}
+}
+
+func (s *LoyaltyService) multiplier(category string) float64 {
+	if category == models.GiftCardCategory {
+		return 0
+	}
+	if multiplier, ok := s.Multipliers[category]; ok {
+		return multiplier
+	}
+	return 1
+}
+
+func (s *LoyaltyService) pointsPer100() float64 {
+	if s.PointsPer100 <= 0 {
+		return DefaultPointsPer100
+	}
+	return s.PointsPer100
+}
+
+func (s *LoyaltyService) pointValue() float64 {
+	if s.PointValue <= 0 {
+		return DefaultPointValue
+	}
+	return s.PointValue
+}
+
+func (s *LoyaltyService) pointsTTL() time.Duration {
+	if s.PointsTTL <= 0 {
+		return DefaultPointsTTL
+	}
+	return s.PointsTTL
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/messaging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OTPPurposeMessagingOptIn is the OTP purpose for confirming a WhatsApp/SMS opt-in.
+const OTPPurposeMessagingOptIn = "messaging_opt_in"
+
+var (
+	ErrInvalidChannel           = errors.New("channel must be whatsapp or sms")
+	ErrInvalidCallbackSignature = errors.New("invalid callback signature")
+)
+
+// Keywords customers can reply with to manage their subscription.
+var (
+	optOutKeywords = []string{"STOP", "UNSUBSCRIBE", "STOP ALL"}
+	optInKeywords  = []string{"START", "SUBSCRIBE"}
+)
+
+type MessagingServiceInterface interface {
+	RequestOptInOTP(ctx context.Context, phone string) error
+	OptIn(ctx context.Context, request OptInRequest) (*models.MessagingPreference, error)
+	OptOut(ctx context.Context, phone string) (*models.MessagingPreference, error)
+	HandleCallback(ctx context.Context, body []byte, signature string) error
+}
+
+// OptInRequest subscribes a phone number to order updates. OTP is the code sent by RequestOptInOTP, so
+// nobody can subscribe a number they do not own. Channel defaults to whatsapp.
+type OptInRequest struct {
+	Phone   string `json:"phone" binding:"required"`
+	OTP     string `json:"otp" binding:"required"`
+	Channel string `json:"channel"`
+}
+
+type MessagingService struct {
+	Preferences     repositories.PreferenceRepositoryInterface
+	OrderRepository repositories.OrderRepositoryInterface
+	OTP             OTPServiceInterface
+	CallbackSecret  string
+}
+
+func (s *MessagingService) RequestOptInOTP(ctx context.Context, phone string) error {
+	ctx, span := tracing.Start(ctx, "MessagingService.RequestOptInOTP")
+	defer span.End()
+
+	return s.OTP.RequestOTP(ctx, phone, OTPPurposeMessagingOptIn)
+}
+
+func (s *MessagingService) OptIn(ctx context.Context, request OptInRequest) (*models.MessagingPreference, error) {
+	ctx, span := tracing.Start(ctx, "MessagingService.OptIn")
+	defer span.End()
+
+	channel := request.Channel
+	if channel == "" {
+		channel = models.NotificationChannelWhatsApp
+	}
+	if channel != models.NotificationChannelWhatsApp && channel != models.NotificationChannelSMS {
+		return nil, ErrInvalidChannel
+	}
+	phone, err := s.OTP.VerifyOTP(ctx, request.Phone, OTPPurposeMessagingOptIn, request.OTP)
+	if err != nil {
+		return nil, err
+	}
+	return s.savePreference(ctx, phone, true, channel, "otp")
+}
+
+// OptOut unsubscribes a phone number. It needs no verification: the worst a stranger can do is stop
+// messages the customer can turn back on.
+func (s *MessagingService) OptOut(ctx context.Context, phone string) (*models.MessagingPreference, error) {
+	ctx, span := tracing.Start(ctx, "MessagingService.OptOut")
+	defer span.End()
+
+	phone, ok := messaging.E164(phone)
+	if !ok {
+		return nil, ErrInvalidPhone
+	}
+	return s.savePreference(ctx, phone, false, "", "api")
+}
+
+// messagingCallback is a provider callback: a delivery status update for a message we sent, or a message a
+// customer sent us.
+type messagingCallback struct {
+	Type      string `json:"type"`
+	MessageID string `json:"message_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+	From      string `json:"from"`
+	Channel   string `json:"channel"`
+	Text      string `json:"text"`
+}
+
+// HandleCallback verifies and applies a provider callback. Delivery statuses are recorded on the order the
+// message was about; inbound STOP and START replies change the sender's opt-in.
+func (s *MessagingService) HandleCallback(ctx context.Context, body []byte, signature string) error {
+	ctx, span := tracing.Start(ctx, "MessagingService.HandleCallback")
+	defer span.End()
+
+	if !messaging.VerifySignature(s.CallbackSecret, body, signature) {
+		return ErrInvalidCallbackSignature
+	}
+	var callback messagingCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		return err
+	}
+
+	switch callback.Type {
+	case "status":
+		err := s.OrderRepository.UpdateMessageStatus(ctx, callback.MessageID, callback.Status, callback.Error)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			logging.FromContext(ctx).Warn("Ignoring status for unknown message", "status", callback.Status, "message_id", callback.MessageID)
+			return nil
+		}
+		return err
+	case "inbound":
+		return s.handleInbound(ctx, callback)
+	}
+	return nil
+}
+
+func (s *MessagingService) handleInbound(ctx context.Context, callback messagingCallback) error {
+	phone, ok := messaging.E164(callback.From)
+	if !ok {
+		return nil
+	}
+	keyword := strings.ToUpper(strings.TrimSpace(callback.Text))
+	switch {
+	case slices.Contains(optOutKeywords, keyword):
+		_, err := s.savePreference(ctx, phone, false, "", "reply")
+		return err
+	case slices.Contains(optInKeywords, keyword):
+		channel := callback.Channel
+		if channel != models.NotificationChannelSMS {
+			channel = models.NotificationChannelWhatsApp
+		}
+		_, err := s.savePreference(ctx, phone, true, channel, "reply")
+		return err
+	}
+	return nil
+}
+
+// savePreference records the opt-in state. Opting out keeps the previously chosen channel.
+func (s *MessagingService) savePreference(ctx context.Context, phone string, optedIn bool, channel string, source string) (*models.MessagingPreference, error) {
+	if channel == "" {
+		channel = models.NotificationChannelWhatsApp
+		if existing, err := s.Preferences.GetPreference(ctx, phone); err == nil && existing.Channel != "" {
+			channel = existing.Channel
+		}
+	}
+	preference := models.MessagingPreference{
+		Phone:     phone,
+		OptedIn:   optedIn,
+		Channel:   channel,
+		Source:    source,
+		UpdatedAt: time.Now(),
+	}
+	if err := s.Preferences.SavePreference(ctx, preference); err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
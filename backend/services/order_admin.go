@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidOrderQuery   = errors.New("invalid order query")
+	ErrInvalidStatusChange = errors.New("invalid status change")
+	ErrOrderNotShippable   = errors.New("only packed orders can be shipped")
+)
+
+const (
+	defaultOrderPageSize = 20
+	maxOrderPageSize     = 100
+	maxBulkOrders        = 500
+)
+
+// shopLocation is the shop's timezone; date filters such as "today's orders" are Indian calendar days.
+var shopLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// orderSorts maps the sort parameter of the order list to a field and direction. A leading "-" sorts
+// descending.
+var orderSorts = map[string]struct {
+	field     string
+	ascending bool
+}{
+	"order_date":    {"order_date", true},
+	"-order_date":   {"order_date", false},
+	"total_amount":  {"total_amount", true},
+	"-total_amount": {"total_amount", false},
+}
+
+// fulfilmentEvents are the customer notifications sent when staff move an order to a fulfilment status.
+var fulfilmentEvents = map[string]string{
+	models.OrderStatusShipped:   models.OrderEventShipped,
+	models.OrderStatusDelivered: models.OrderEventDelivered,
+}
+
+// fulfilmentTransitions lists, for each status staff can set in bulk, the statuses an order may move from.
+var fulfilmentTransitions = map[string][]string{
+	models.OrderStatusPacked:    {models.OrderStatusConfirmed},
+	models.OrderStatusShipped:   {models.OrderStatusPacked},
+	models.OrderStatusDelivered: {models.OrderStatusShipped},
+}
+
+// OrderListQuery holds the admin order list's query parameters. Status takes a comma-separated list; From
+// and To are inclusive dates (YYYY-MM-DD, in IST) or RFC 3339 timestamps.
+type OrderListQuery struct {
+	Status        string  `form:"status"`
+	PaymentStatus string  `form:"payment_status"`
+	From          string  `form:"from"`
+	To            string  `form:"to"`
+	Phone         string  `form:"phone"`
+	Email         string  `form:"email"`
+	MinAmount     float64 `form:"min_amount"`
+	MaxAmount     float64 `form:"max_amount"`
+	ProductID     string  `form:"product_id"`
+	Sort          string  `form:"sort"`
+	Page          int     `form:"page"`
+	PageSize      int     `form:"page_size"`
+}
+
+type OrderPage struct {
+	Orders   []models.Order `json:"orders"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+}
+
+// BulkStatusRequest moves several orders to Status, e.g. to mark a day's orders packed or shipped.
+type BulkStatusRequest struct {
+	OrderIDs []string `json:"order_ids" binding:"required"`
+	Status   string   `json:"status" binding:"required"`
+	Reason   string   `json:"reason"`
+}
+
+// ShipOrderRequest records how a packed order was shipped. All fields are optional, but the customer's
+// shipping notification includes whichever are given.
+type ShipOrderRequest struct {
+	Courier        string `json:"courier"`
+	TrackingNumber string `json:"tracking_number"`
+	TrackingURL    string `json:"tracking_url"`
+	Reason         string `json:"reason"`
+}
+
+// BulkStatusResult reports which orders were updated and why the others were skipped.
+type BulkStatusResult struct {
+	Updated []string          `json:"updated"`
+	Skipped map[string]string `json:"skipped"`
+}
+
+func (s *OrderService) ListOrders(ctx context.Context, query OrderListQuery) (*OrderPage, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.ListOrders")
+	defer span.End()
+
+	filter, err := orderFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page, pageSize := query.Page, query.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultOrderPageSize
+	}
+	if pageSize > maxOrderPageSize {
+		pageSize = maxOrderPageSize
+	}
+	filter.Skip = int64((page - 1) * pageSize)
+	filter.Limit = int64(pageSize)
+
+	orders, total, err := s.OrderRepository.ListOrders(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderPage{Orders: orders, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// BulkUpdateStatus applies a fulfilment status to each order that is in the preceding status. Orders that
+// are missing or in any other status are skipped and reported rather than failing the whole batch.
+func (s *OrderService) BulkUpdateStatus(ctx context.Context, request BulkStatusRequest) (*BulkStatusResult, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.BulkUpdateStatus")
+	defer span.End()
+
+	from, ok := fulfilmentTransitions[request.Status]
+	if !ok {
+		return nil, fmt.Errorf("%w: status must be one of %s, %s or %s", ErrInvalidStatusChange,
+			models.OrderStatusPacked, models.OrderStatusShipped, models.OrderStatusDelivered)
+	}
+	if len(request.OrderIDs) == 0 || len(request.OrderIDs) > maxBulkOrders {
+		return nil, fmt.Errorf("%w: between 1 and %d order IDs are required", ErrInvalidStatusChange, maxBulkOrders)
+	}
+
+	result := &BulkStatusResult{Updated: []string{}, Skipped: map[string]string{}}
+	seen := make(map[string]bool, len(request.OrderIDs))
+	for _, id := range request.OrderIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		change := models.StatusChange{Status: request.Status, Reason: request.Reason, ChangedAt: time.Now()}
+		var outbox []models.JobRequest
+		if event, ok := fulfilmentEvents[request.Status]; ok {
+			outbox = notifyOn(event, id, 0)
+		}
+		_, err := s.OrderRepository.TransitionStatus(ctx, id, from, change, outbox)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			result.Skipped[id] = fmt.Sprintf("order not found or not %s", strings.Join(from, "/"))
+		case err != nil:
+			result.Skipped[id] = err.Error()
+		default:
+			result.Updated = append(result.Updated, id)
+		}
+	}
+	return result, nil
+}
+
+// ShipOrder marks a packed order shipped and records its shipment details.
+func (s *OrderService) ShipOrder(ctx context.Context, id string, request ShipOrderRequest) (*models.Order, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.ShipOrder")
+	defer span.End()
+
+	if request.TrackingURL != "" {
+		u, err := url.Parse(request.TrackingURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: tracking_url must be an http(s) URL", ErrInvalidStatusChange)
+		}
+	}
+
+	now := time.Now()
+	change := models.StatusChange{Status: models.OrderStatusShipped, Reason: request.Reason, ChangedAt: now}
+	// The notification waits for the shipment details below, which it includes.
+	outbox := notifyOn(models.OrderEventShipped, id, followUpDelay)
+	order, err := s.OrderRepository.TransitionStatus(ctx, id, fulfilmentTransitions[models.OrderStatusShipped], change, outbox)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrOrderNotShippable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A parcel booked through the shipping service keeps its booking; details given here override it.
+	shipment := models.Shipment{}
+	if order.Shipment != nil {
+		shipment = *order.Shipment
+	}
+	if request.Courier != "" {
+		shipment.Courier = request.Courier
+	}
+	if request.TrackingNumber != "" {
+		shipment.TrackingNumber = request.TrackingNumber
+	}
+	if request.TrackingURL != "" {
+		shipment.TrackingURL = request.TrackingURL
+	}
+	shipment.ShippedAt = &now
+	if err := s.OrderRepository.SetShipment(ctx, id, shipment); err != nil {
+		return nil, err
+	}
+	order.Shipment = &shipment
+	return order, nil
+}
+
+func orderFilter(query OrderListQuery) (repositories.OrderFilter, error) {
+	filter := repositories.OrderFilter{
+		PaymentStatus: query.PaymentStatus,
+		Email:         query.Email,
+		MinAmount:     query.MinAmount,
+		MaxAmount:     query.MaxAmount,
+		ProductID:     query.ProductID,
+	}
+
+	for _, status := range strings.Split(query.Status, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			filter.Status = append(filter.Status, status)
+		}
+	}
+
+	if query.Phone != "" {
+		filter.Phone = normalizePhone(query.Phone)
+		if filter.Phone == "" {
+			return filter, fmt.Errorf("%w: phone must contain digits", ErrInvalidOrderQuery)
+		}
+	}
+
+	var err error
+	if filter.From, _, err = parseDateBound(query.From); err != nil {
+		return filter, fmt.Errorf("%w: from: %v", ErrInvalidOrderQuery, err)
+	}
+	var isDate bool
+	if filter.To, isDate, err = parseDateBound(query.To); err != nil {
+		return filter, fmt.Errorf("%w: to: %v", ErrInvalidOrderQuery, err)
+	}
+	if isDate {
+		// A date includes the whole day, so the exclusive bound is the following midnight.
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("%w: from must be before to", ErrInvalidOrderQuery)
+	}
+
+	if query.MinAmount < 0 || query.MaxAmount < 0 || (query.MaxAmount > 0 && query.MinAmount > query.MaxAmount) {
+		return filter, fmt.Errorf("%w: invalid amount range", ErrInvalidOrderQuery)
+	}
+
+	if query.Sort != "" {
+		sort, ok := orderSorts[query.Sort]
+		if !ok {
+			return filter, fmt.Errorf("%w: sort must be one of order_date, -order_date, total_amount, -total_amount", ErrInvalidOrderQuery)
+		}
+		filter.SortField = sort.field
+		filter.SortAscending = sort.ascending
+	}
+	return filter, nil
+}
+
+// parseDateBound parses a YYYY-MM-DD date as midnight IST, or an RFC 3339 timestamp. It reports whether
+// the value was a date.
+func parseDateBound(value string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, shopLocation); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%q is not a YYYY-MM-DD date or RFC 3339 time", value)
+	}
+	return t, false, nil
+}
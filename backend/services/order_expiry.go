@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultPaymentWindow is how long a customer has to pay for an order before it expires.
+const DefaultPaymentWindow = 30 * time.Minute
+
+// expiryBatch is how many orders one sweep expires at most; a larger backlog is worked off by later sweeps.
+const expiryBatch = 200
+
+// PaymentReconciler checks the gateway for a payment whose webhook has not arrived yet.
+type PaymentReconciler interface {
+	ReconcilePayment(ctx context.Context, order models.Order) (bool, error)
+}
+
+// ExpireUnpaidOrders expires pending orders that have not been paid within PaymentWindow and returns their
+// stock. Each order is first checked with the gateway, so an order whose payment was captured late is
+// confirmed instead, and one with a payment still being processed is left for the next sweep.
+func (s *OrderService) ExpireUnpaidOrders(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.ExpireUnpaidOrders")
+	defer span.End()
+
+	window := s.PaymentWindow
+	if window <= 0 {
+		window = DefaultPaymentWindow
+	}
+	orders, err := s.OrderRepository.ListUnpaidBefore(ctx, time.Now().Add(-window), expiryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	var failed []string
+	for _, order := range orders {
+		ok, err := s.expireUnpaid(ctx, order, window)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to expire order", "order_id", order.ID, "error", err)
+			failed = append(failed, order.ID)
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+	if len(failed) > 0 {
+		return expired, fmt.Errorf("%d orders could not be expired: %v", len(failed), failed)
+	}
+	return expired, nil
+}
+
+// ExpireSubscriptionOrders expires the unpaid orders of a subscription that will not be charged again, because
+// it halted or was cancelled, and returns their stock.
+func (s *OrderService) ExpireSubscriptionOrders(ctx context.Context, subscriptionID string, reason string) (int, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.ExpireSubscriptionOrders")
+	defer span.End()
+
+	orders, err := s.OrderRepository.ListUnpaidBySubscription(ctx, subscriptionID, expiryBatch)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, order := range orders {
+		ok, err := s.expire(ctx, order, reason)
+		if err != nil {
+			return expired, fmt.Errorf("expiring order %s: %w", order.ID, err)
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// expireUnpaid expires one order, reporting false when it turned out to be paid or was no longer pending.
+func (s *OrderService) expireUnpaid(ctx context.Context, order models.Order, window time.Duration) (bool, error) {
+	if s.Payments != nil {
+		paid, err := s.Payments.ReconcilePayment(ctx, order)
+		if err != nil {
+			return false, fmt.Errorf("checking payment: %w", err)
+		}
+		if paid {
+			return false, nil
+		}
+	}
+	return s.expire(ctx, order, fmt.Sprintf("not paid within %s", window))
+}
+
+// expire moves a pending order to expired and releases what it reserved, reporting false if it was no
+// longer pending.
+func (s *OrderService) expire(ctx context.Context, order models.Order, reason string) (bool, error) {
+	change := models.StatusChange{
+		Status:    models.OrderStatusExpired,
+		Reason:    reason,
+		ChangedAt: time.Now(),
+	}
+	expired, err := s.OrderRepository.TransitionStatus(ctx, order.ID, []string{models.OrderStatusPending}, change, nil)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Paid or cancelled since it was listed.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	s.releaseStock(ctx, expired.ID, expired.Items)
+	s.releaseCoupon(ctx, expired)
+	s.releasePoints(ctx, expired)
+	s.releaseStoredValue(ctx, expired)
+	return true, nil
+}
@@ -1,62 +1,484 @@
-
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/jobs"
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/metrics"
 	"mangal-chai-backend/models"
 	"mangal-chai-backend/repositories"
-	"time"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+var (
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+	ErrInvalidCoupon       = errors.New("coupon is invalid, expired or already used")
+)
+
+// cancellableStatuses are the statuses an order can be cancelled from; once packed it is too late.
+var cancellableStatuses = []string{models.OrderStatusPending, models.OrderStatusConfirmed}
+
 type OrderServiceInterface interface {
-	CreateOrder(orderData struct {
-		CustomerInfo models.CustomerInfo `json:"customer_info"`
-		Items        []models.CartItem   `json:"items"`
-		Notes        string              `json:"notes"`
-	}) (*models.Order, error)
-	GetOrder(id string) (*models.Order, error)
+	CreateOrder(ctx context.Context, orderData CreateOrderRequest) (*models.Order, error)
+	GetOrder(ctx context.Context, id string, access OrderAccess) (*models.Order, error)
+	CancelOrder(ctx context.Context, id string, request CancelOrderRequest) (*models.Order, error)
+	ListOrders(ctx context.Context, query OrderListQuery) (*OrderPage, error)
+	BulkUpdateStatus(ctx context.Context, request BulkStatusRequest) (*BulkStatusResult, error)
+	ShipOrder(ctx context.Context, id string, request ShipOrderRequest) (*models.Order, error)
+	TrackOrder(ctx context.Context, request TrackOrderRequest) (*OrderTracking, error)
+}
+
+// CreateOrderRequest is an order placed at checkout. CouponCode applies a discount, and RedeemPoints spends a
+// logged-in customer's loyalty points for a further discount. GiftCardCode pays for
+// the order from a gift card, and UseWallet from a logged-in customer's store credit, as far as their
+// balances go; the rest is paid through the gateway. The cart the order was placed from, named by
+// CartToken or the logged-in CustomerID, is cleared once the order is saved.
+// SubscriptionID is set for orders placed by a subscription instead; they are charged at the plan's prices
+// given in Items and leave the cart alone.
+type CreateOrderRequest struct {
+	CustomerInfo   models.CustomerInfo `json:"customer_info"`
+	Items          []models.CartItem   `json:"items"`
+	Notes          string              `json:"notes"`
+	CouponCode     string              `json:"coupon_code"`
+	RedeemPoints   int                 `json:"redeem_points"`
+	GiftCardCode   string              `json:"gift_card_code"`
+	UseWallet      bool                `json:"use_wallet"`
+	CartToken      string              `json:"cart_token"`
+	CustomerID     string              `json:"-"`
+	SubscriptionID string              `json:"-"`
+}
+
+// CartCompleter is told when an order has been placed from a cart.
+type CartCompleter interface {
+	CompleteCart(ctx context.Context, key CartKey, order models.Order) error
+}
+
+// OrderNotifier is told about order events so the customer can be notified. Implementations must not block
+// on delivery.
+type OrderNotifier interface {
+	NotifyOrder(ctx context.Context, event string, order models.Order)
+}
+
+// OrderNotifiers notifies each notifier in turn, e.g. by email and by WhatsApp.
+type OrderNotifiers []OrderNotifier
+
+func (n OrderNotifiers) NotifyOrder(ctx context.Context, event string, order models.Order) {
+	for _, notifier := range n {
+		notifier.NotifyOrder(ctx, event, order)
+	}
+}
+
+// CancelOrderRequest is a customer's request to cancel an order. Phone or Email must match the order.
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"required"`
+	Phone  string `json:"phone"`
+	Email  string `json:"email"`
 }
 
 type OrderService struct {
 	OrderRepository   repositories.OrderRepositoryInterface
 	ProductRepository repositories.ProductRepositoryInterface
+	Refunds           RefundServiceInterface
+	Payments          PaymentReconciler
+	PaymentWindow     time.Duration
+	Coupons           repositories.CouponRepositoryInterface
+	Carts             CartCompleter
+	Restocks          RestockNotifier
+	StoredValue       StoredValue
+	Loyalty           PointsRedeemer
+	DispatchTime      time.Duration // for delivery estimates, DefaultDispatchTime if zero
+	TransitTime       time.Duration // for delivery estimates, DefaultTransitTime if zero
 }
 
-func (s *OrderService) CreateOrder(orderData struct {
-	CustomerInfo models.CustomerInfo `json:"customer_info"`
-	Items        []models.CartItem   `json:"items"`
-	Notes        string              `json:"notes"`
-}) (*models.Order, error) {
+func (s *OrderService) CreateOrder(ctx context.Context, orderData CreateOrderRequest) (*models.Order, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.CreateOrder")
+	defer span.End()
+
+	if err := checkCustomerGSTIN(&orderData.CustomerInfo); err != nil {
+		return nil, err
+	}
 	totalAmount := 0.0
-	for _, item := range orderData.Items {
-		product, err := s.ProductRepository.GetProduct(item.ProductID)
+	discountable := 0.0 // what coupons and points apply to: everything but gift cards
+	items := make([]models.CartItem, len(orderData.Items))
+	for i, item := range orderData.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid quantity for product %s", item.ProductID)
+		}
+		product, err := s.ProductRepository.GetProduct(ctx, item.ProductID)
 		if err != nil {
 			return nil, fmt.Errorf("product %s not found", item.ProductID)
 		}
 		if !product.InStock {
+			metrics.OutOfStockRejections.WithLabelValues(item.ProductID).Inc()
 			return nil, fmt.Errorf("product %s is out of stock", product.Name)
 		}
-		totalAmount += product.Price * float64(item.Quantity)
+		price := product.Price
+		if orderData.SubscriptionID != "" && item.Price > 0 {
+			price = item.Price
+		}
+		totalAmount += price * float64(item.Quantity)
+		if product.Category != models.GiftCardCategory {
+			discountable += price * float64(item.Quantity)
+		}
+		items[i] = models.CartItem{ProductID: item.ProductID, Quantity: item.Quantity, Price: price}
 	}
 
+	if err := s.reserveStock(ctx, items); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	newOrder := models.Order{
-		ID:           fmt.Sprintf("ord_%d", time.Now().UnixNano()),
-		CustomerInfo: orderData.CustomerInfo,
-		Items:        orderData.Items,
-		TotalAmount:  totalAmount,
-		Status:       "pending",
-		OrderDate:    time.Now(),
-		Notes:        orderData.Notes,
+		ID:             fmt.Sprintf("ord_%d", now.UnixNano()),
+		CustomerInfo:   orderData.CustomerInfo,
+		Items:          items,
+		TotalAmount:    totalAmount,
+		Status:         models.OrderStatusPending,
+		OrderDate:      now,
+		Notes:          orderData.Notes,
+		StatusHistory:  []models.StatusChange{{Status: models.OrderStatusPending, ChangedAt: now}},
+		CustomerID:     orderData.CustomerID,
+		SubscriptionID: orderData.SubscriptionID,
+	}
+	if orderData.CouponCode != "" {
+		if err := s.applyCoupon(ctx, &newOrder, orderData.CouponCode, discountable); err != nil {
+			s.releaseStock(ctx, newOrder.ID, items)
+			return nil, err
+		}
+	}
+	if orderData.RedeemPoints > 0 {
+		if err := s.applyPoints(ctx, &newOrder, orderData.RedeemPoints, discountable-newOrder.Discount); err != nil {
+			s.releaseStock(ctx, newOrder.ID, items)
+			s.releaseCoupon(ctx, &newOrder)
+			return nil, err
+		}
+	}
+	if err := s.applyStoredValue(ctx, &newOrder, orderData); err != nil {
+		s.releaseStock(ctx, newOrder.ID, items)
+		s.releaseCoupon(ctx, &newOrder)
+		s.releasePoints(ctx, &newOrder)
+		return nil, err
+	}
+	paidWithoutGateway := newOrder.PointsDiscount + newOrder.GiftCardAmount + newOrder.StoreCreditAmount
+	if AmountDue(&newOrder) <= 0 && paidWithoutGateway > 0 {
+		markPaid(&newOrder)
+	}
+	// The confirmation is written with the order so it is sent even if the server stops right after saving.
+	newOrder.Outbox = []models.JobRequest{jobs.NotifyOrderJob(models.OrderEventPlaced, newOrder.ID)}
+	if newOrder.PaymentStatus == models.PaymentStatusPaid {
+		newOrder.Outbox = append(newOrder.Outbox, jobs.NotifyOrderJob(models.OrderEventPaymentConfirmed, newOrder.ID))
 	}
 
-	err := s.OrderRepository.CreateOrder(newOrder)
+	err := s.OrderRepository.CreateOrder(ctx, newOrder)
 	if err != nil {
+		s.releaseStock(ctx, newOrder.ID, items)
+		s.releaseCoupon(ctx, &newOrder)
+		s.releasePoints(ctx, &newOrder)
+		s.releaseStoredValue(ctx, &newOrder)
 		return nil, err
 	}
+	channel := "storefront"
+	if newOrder.SubscriptionID != "" {
+		channel = "subscription"
+	}
+	metrics.OrdersCreated.WithLabelValues(channel).Inc()
+	if newOrder.PaymentStatus == models.PaymentStatusPaid {
+		countRevenue(&newOrder)
+	}
 
+	if s.Carts != nil && orderData.SubscriptionID == "" {
+		key := CartKey{Token: orderData.CartToken, CustomerID: orderData.CustomerID}
+		if err := s.Carts.CompleteCart(ctx, key, newOrder); err != nil {
+			logging.FromContext(ctx).Error("Failed to clear the cart for order", "order_id", newOrder.ID, "error", err)
+		}
+	}
 	return &newOrder, nil
 }
 
-func (s *OrderService) GetOrder(id string) (*models.Order, error) {
-	return s.OrderRepository.GetOrder(id)
+// GetOrder returns an order to someone allowed to see it. An unknown order and one the caller may not see
+// both give ErrOrderNotFound, so order numbers alone do not reveal anything.
+func (s *OrderService) GetOrder(ctx context.Context, id string, access OrderAccess) (*models.Order, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.GetOrder")
+	defer span.End()
+
+	order, err := s.OrderRepository.GetOrder(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !access.allows(order) {
+		return nil, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// CancelOrder cancels an order that has not been packed yet, returns its stock and refunds the payment if
+// it was paid. A failed refund does not undo the cancellation; the order is marked refund_failed instead
+// and the failed attempt is kept with the order's refunds.
+func (s *OrderService) CancelOrder(ctx context.Context, id string, request CancelOrderRequest) (*models.Order, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.CancelOrder")
+	defer span.End()
+
+	order, err := s.OrderRepository.GetOrder(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !matchesContact(order.CustomerInfo, request.Phone, request.Email) {
+		return nil, ErrOrderNotFound
+	}
+
+	change := models.StatusChange{Status: models.OrderStatusCancelled, Reason: request.Reason, ChangedAt: time.Now()}
+	// The notification waits for the refund below, so it can say whether the payment is on its way back.
+	outbox := notifyOn(models.OrderEventCancelled, id, followUpDelay)
+	cancelled, err := s.OrderRepository.TransitionStatus(ctx, id, cancellableStatuses, change, outbox)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// The order has moved past the statuses it can be cancelled from.
+		return nil, ErrOrderNotCancellable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.releaseStock(ctx, id, cancelled.Items)
+	s.releaseCoupon(ctx, cancelled)
+	s.releasePoints(ctx, cancelled)
+
+	switch {
+	case cancelled.PaymentStatus != models.PaymentStatusPaid:
+		s.releaseStoredValue(ctx, cancelled)
+	case cancelled.TotalAmount > 0:
+		s.refundCancelled(ctx, cancelled, request.Reason)
+	}
+	return cancelled, nil
+}
+
+// refundCancelled refunds the full payment of a cancelled order and records the outcome on it. What was
+// paid through the gateway is refunded there, and what was paid with a gift card or store credit goes back
+// as store credit.
+func (s *OrderService) refundCancelled(ctx context.Context, order *models.Order, reason string) {
+	if s.Refunds != nil {
+		request := RefundRequest{Reason: "Order cancelled: " + reason}
+		refund, err := s.Refunds.IssueRefund(ctx, order.ID, request)
+		if err == nil && order.PaymentID != "" && AmountDue(order) < order.TotalAmount {
+			request.StoreCredit = true
+			_, err = s.Refunds.IssueRefund(ctx, order.ID, request)
+		}
+		if err == nil {
+			order.RefundID = refund.GatewayRefundID
+			order.PaymentStatus = models.PaymentStatusRefunded
+			return
+		}
+		logging.FromContext(ctx).Error("Refund for cancelled order failed", "order_id", order.ID, "error", err)
+	} else {
+		logging.FromContext(ctx).Warn("No refund service configured, order needs a manual refund", "order_id", order.ID)
+	}
+
+	order.PaymentStatus = models.PaymentStatusRefundFailed
+	if err := s.OrderRepository.SetRefund(ctx, order.ID, "", order.PaymentStatus); err != nil {
+		logging.FromContext(ctx).Error("Failed to record refund failure for order", "order_id", order.ID, "error", err)
+	}
+}
+
+// reserveStock reserves every item or none: if one item cannot be reserved, the earlier ones are released.
+func (s *OrderService) reserveStock(ctx context.Context, items []models.CartItem) error {
+	for i, item := range items {
+		err := s.ProductRepository.ReserveStock(ctx, item.ProductID, item.Quantity)
+		if err == nil {
+			continue
+		}
+		s.releaseStock(ctx, "", items[:i])
+		if errors.Is(err, repositories.ErrInsufficientStock) {
+			metrics.OutOfStockRejections.WithLabelValues(item.ProductID).Inc()
+			return fmt.Errorf("product %s is out of stock", item.ProductID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *OrderService) releaseStock(ctx context.Context, orderID string, items []models.CartItem) {
+	for _, item := range items {
+		if err := s.ProductRepository.ReleaseStock(ctx, item.ProductID, item.Quantity); err != nil {
+			logging.FromContext(ctx).Error("Failed to release stock", "product_id", item.ProductID, "quantity", item.Quantity, "order_id", orderID, "error", err)
+			continue
+		}
+		s.checkRestocked(ctx, item)
+	}
+}
+
+// checkRestocked reports a product whose released stock is all it has, meaning it had run out. A
+// concurrent order can make this miss or repeat a restock; both are harmless for the alerts it triggers.
+func (s *OrderService) checkRestocked(ctx context.Context, item models.CartItem) {
+	if s.Restocks == nil {
+		return
+	}
+	product, err := s.ProductRepository.GetProduct(ctx, item.ProductID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to check stock of product", "product_id", item.ProductID, "error", err)
+		return
+	}
+	if product.Stock == item.Quantity {
+		s.Restocks.ProductRestocked(ctx, item.ProductID)
+	}
+}
+
+// applyCoupon redeems a coupon for the order and takes its discount off the total. The discount is on the
+// discountable amount only.
+func (s *OrderService) applyCoupon(ctx context.Context, order *models.Order, code string, discountable float64) error {
+	if s.Coupons == nil {
+		return ErrInvalidCoupon
+	}
+	if discountable <= 0 {
+		return fmt.Errorf("%w: coupons cannot be used on gift cards", ErrInvalidCoupon)
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+	coupon, err := s.Coupons.Redeem(ctx, code, order.ID, order.OrderDate)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrInvalidCoupon
+	}
+	if err != nil {
+		return err
+	}
+
+	order.CouponCode = coupon.Code
+	order.Discount = roundRupees(discountable * coupon.PercentOff / 100)
+	order.TotalAmount = roundRupees(order.TotalAmount - order.Discount)
+	return nil
+}
+
+// releaseCoupon makes the order's coupon usable again, for orders that were never completed.
+func (s *OrderService) releaseCoupon(ctx context.Context, order *models.Order) {
+	if order.CouponCode == "" || s.Coupons == nil {
+		return
+	}
+	if err := s.Coupons.Release(ctx, order.CouponCode, order.ID); err != nil {
+		logging.FromContext(ctx).Error("Failed to release coupon of order", "coupon", order.CouponCode, "order_id", order.ID, "error", err)
+	}
+}
+
+// applyStoredValue pays what it can of the order from the requested gift card, then from the customer's
+// wallet.
+func (s *OrderService) applyStoredValue(ctx context.Context, order *models.Order, orderData CreateOrderRequest) error {
+	useWallet := orderData.UseWallet && order.CustomerID != ""
+	if orderData.GiftCardCode == "" && !useWallet {
+		return nil
+	}
+	if s.StoredValue == nil {
+		return ErrInvalidGiftCard
+	}
+
+	if orderData.GiftCardCode != "" {
+		code := strings.ToUpper(strings.TrimSpace(orderData.GiftCardCode))
+		spent, err := s.StoredValue.RedeemGiftCard(ctx, code, order.TotalAmount, order.ID)
+		if err != nil {
+			return err
+		}
+		order.GiftCardCode = code
+		order.GiftCardAmount = spent
+	}
+	if due := AmountDue(order); useWallet && due > 0 {
+		spent, err := s.StoredValue.SpendWallet(ctx, order.CustomerID, due, order.ID)
+		if err != nil {
+			s.releaseStoredValue(ctx, order)
+			return err
+		}
+		order.StoreCreditAmount = spent
+	}
+	return nil
+}
+
+// markPaid confirms an order that gift cards, store credit or points paid for in full.
+func markPaid(order *models.Order) {
+	order.PaymentStatus = models.PaymentStatusPaid
+	switch {
+	case order.StoreCreditAmount > 0:
+		order.PaymentMethod = models.PaymentMethodStoreCredit
+	case order.GiftCardAmount > 0:
+		order.PaymentMethod = models.PaymentMethodGiftCard
+	default:
+		order.PaymentMethod = models.PaymentMethodLoyaltyPoints
+	}
+	order.Status = models.OrderStatusConfirmed
+	order.StatusHistory = append(order.StatusHistory, models.StatusChange{
+		Status:    models.OrderStatusConfirmed,
+		Reason:    "paid with " + strings.ReplaceAll(order.PaymentMethod, "_", " "),
+		ChangedAt: order.OrderDate,
+	})
+}
+
+// applyPoints spends the customer's loyalty points, worth upTo at most, and takes their value off the total.
+func (s *OrderService) applyPoints(ctx context.Context, order *models.Order, points int, upTo float64) error {
+	if s.Loyalty == nil || order.CustomerID == "" {
+		return ErrInvalidPoints
+	}
+	redeemed, discount, err := s.Loyalty.RedeemPoints(ctx, order.CustomerID, points, upTo, order.ID)
+	if err != nil {
+		return err
+	}
+	order.PointsRedeemed = redeemed
+	order.PointsDiscount = discount
+	order.TotalAmount = roundRupees(order.TotalAmount - discount)
+	return nil
+}
+
+// releasePoints gives back the points spent on an order that was never completed.
+func (s *OrderService) releasePoints(ctx context.Context, order *models.Order) {
+	if order.PointsRedeemed <= 0 || s.Loyalty == nil {
+		return
+	}
+	if err := s.Loyalty.ReturnPoints(ctx, order.CustomerID, order.PointsRedeemed, order.ID); err != nil {
+		logging.FromContext(ctx).Error("Failed to return points from order", "points", order.PointsRedeemed, "customer_id", order.CustomerID, "order_id", order.ID, "error", err)
+	}
+}
+
+// releaseStoredValue returns what an order that was never paid took from a gift card or wallet.
+func (s *OrderService) releaseStoredValue(ctx context.Context, order *models.Order) {
+	if s.StoredValue == nil {
+		return
+	}
+	if order.GiftCardAmount > 0 {
+		s.creditBack(ctx, order, models.LedgerAccountGiftCard, order.GiftCardCode, order.GiftCardAmount)
+	}
+	if order.StoreCreditAmount > 0 {
+		s.creditBack(ctx, order, models.LedgerAccountWallet, order.CustomerID, order.StoreCreditAmount)
+	}
+}
+
+func (s *OrderService) creditBack(ctx context.Context, order *models.Order, account string, accountID string, amount float64) {
+	entry := models.LedgerEntry{Account: account, AccountID: accountID, Kind: models.LedgerKindReleased, Amount: amount, OrderID: order.ID}
+	if err := s.StoredValue.Credit(ctx, entry); err != nil {
+		logging.FromContext(ctx).Error("Failed to return balance from order", "amount", amount, "account", account, "account_id", accountID, "order_id", order.ID, "error", err)
+	}
+}
+
+// AmountDue is what is left of the order's total to pay through the gateway.
+func AmountDue(order *models.Order) float64 {
+	return roundRupees(order.TotalAmount - order.GiftCardAmount - order.StoreCreditAmount)
+}
+
+// followUpDelay holds back the notification of a status change that is followed by more work on the order,
+// such as refunding a cancelled order, since the notification shows the order as it is when it is sent.
+const followUpDelay = time.Minute
+
+// notifyOn returns the outbox for a status change the customer is notified of. It is written with the change,
+// so the notification goes out even if the server stops right after it.
+func notifyOn(event string, orderID string, delay time.Duration) []models.JobRequest {
+	request := jobs.NotifyOrderJob(event, orderID)
+	if delay > 0 {
+		request.RunAt = time.Now().Add(delay)
+	}
+	return []models.JobRequest{request}
 }
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/tracing"
+)
+
+// Defaults for estimating delivery dates: how long a paid order takes to leave the shop, and how long the
+// courier takes after that.
+const (
+	DefaultDispatchTime = 48 * time.Hour
+	DefaultTransitTime  = 5 * 24 * time.Hour
+)
+
+// TrackOrderRequest looks up an order for the tracking page. The order's phone or email must be given, and
+// must match, so order numbers alone do not reveal anything.
+type TrackOrderRequest struct {
+	OrderID string `form:"order" binding:"required"`
+	Phone   string `form:"phone"`
+	Email   string `form:"email"`
+}
+
+// OrderTracking is what the public tracking page shows about an order. Contact details are masked and the
+// delivery address is cut down to its last line, so someone who learns a customer's phone number and order
+// number cannot read their address.
+type OrderTracking struct {
+	OrderID       string           `json:"order_id"`
+	Status        string           `json:"status"`
+	PaymentStatus string           `json:"payment_status,omitempty"`
+	OrderDate     time.Time        `json:"order_date"`
+	Customer      TrackingCustomer `json:"customer"`
+	Items         []TrackedItem    `json:"items"`
+	TotalAmount   float64          `json:"total_amount"`
+	Timeline      []TimelineEntry  `json:"timeline"`
+	Shipment      *models.Shipment `json:"shipment,omitempty"`
+	// ExpectedDelivery is the estimated delivery date (YYYY-MM-DD, IST) of an order on its way.
+	ExpectedDelivery string `json:"expected_delivery,omitempty"`
+}
+
+type TrackingCustomer struct {
+	Name         string `json:"name"`
+	Phone        string `json:"phone,omitempty"`
+	Email        string `json:"email,omitempty"`
+	DeliveryArea string `json:"delivery_area,omitempty"`
+}
+
+type TrackedItem struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// TimelineEntry is a status the order reached and when. Staff's reasons for status changes are left out.
+type TimelineEntry struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// TrackOrder returns the tracking view of an order for a customer without an account. A wrong order
+// number and a wrong phone or email both give ErrOrderNotFound, so neither can be guessed one at a time.
+func (s *OrderService) TrackOrder(ctx context.Context, request TrackOrderRequest) (*OrderTracking, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.TrackOrder")
+	defer span.End()
+
+	order, err := s.OrderRepository.GetOrder(ctx, strings.TrimSpace(request.OrderID))
+	if err != nil || !matchesContact(order.CustomerInfo, request.Phone, request.Email) {
+		return nil, ErrOrderNotFound
+	}
+
+	tracking := &OrderTracking{
+		OrderID:       order.ID,
+		Status:        order.Status,
+		PaymentStatus: order.PaymentStatus,
+		OrderDate:     order.OrderDate,
+		Customer: TrackingCustomer{
+			Name:         firstName(order.CustomerInfo.Name),
+			Phone:        maskPhone(order.CustomerInfo.Phone),
+			Email:        maskEmail(order.CustomerInfo.Email),
+			DeliveryArea: deliveryArea(order.CustomerInfo.Address),
+		},
+		Items:       make([]TrackedItem, 0, len(order.Items)),
+		TotalAmount: order.TotalAmount,
+		Timeline:    make([]TimelineEntry, 0, len(order.StatusHistory)),
+		Shipment:    order.Shipment,
+	}
+	for _, item := range order.Items {
+		tracked := TrackedItem{ProductID: item.ProductID, Quantity: item.Quantity}
+		if product, err := s.ProductRepository.GetProduct(ctx, item.ProductID); err == nil {
+			tracked.Name = product.Name
+		}
+		tracking.Items = append(tracking.Items, tracked)
+	}
+	for _, change := range order.StatusHistory {
+		tracking.Timeline = append(tracking.Timeline, TimelineEntry{Status: change.Status, At: change.ChangedAt})
+	}
+	if expected, ok := s.expectedDelivery(order, time.Now()); ok {
+		tracking.ExpectedDelivery = expected.In(shopLocation).Format("2006-01-02")
+	}
+	return tracking, nil
+}
+
+// expectedDelivery estimates when an order on its way will arrive: the transit time after it shipped, or
+// the dispatch and transit times after it was confirmed. An order running late is expected no earlier
+// than now.
+func (s *OrderService) expectedDelivery(order *models.Order, now time.Time) (time.Time, bool) {
+	dispatch, transit := s.DispatchTime, s.TransitTime
+	if dispatch <= 0 {
+		dispatch = DefaultDispatchTime
+	}
+	if transit <= 0 {
+		transit = DefaultTransitTime
+	}
+
+	switch order.Status {
+	case models.OrderStatusShipped:
+		shippedAt, ok := statusReachedAt(order, models.OrderStatusShipped)
+		if order.Shipment != nil && order.Shipment.ShippedAt != nil {
+			shippedAt, ok = *order.Shipment.ShippedAt, true
+		}
+		if !ok {
+			return time.Time{}, false
+		}
+		return latest(shippedAt.Add(transit), now), true
+	case models.OrderStatusConfirmed, models.OrderStatusPacked:
+		confirmedAt, ok := statusReachedAt(order, models.OrderStatusConfirmed)
+		if !ok {
+			confirmedAt = order.OrderDate
+		}
+		return latest(confirmedAt.Add(dispatch), now).Add(transit), true
+	}
+	return time.Time{}, false
+}
+
+// statusReachedAt returns when the order last moved to status.
+func statusReachedAt(order *models.Order, status string) (time.Time, bool) {
+	for i := len(order.StatusHistory) - 1; i >= 0; i-- {
+		if order.StatusHistory[i].Status == status {
+			return order.StatusHistory[i].ChangedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func latest(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// maskPhone keeps the last four digits of a phone number, e.g. ******3210.
+func maskPhone(phone string) string {
+	digits := normalizePhone(phone)
+	if len(digits) <= 4 {
+		return ""
+	}
+	return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+}
+
+// maskEmail keeps the first letter of an email address's local part and its domain, e.g. a***@example.com.
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(strings.TrimSpace(email), "@")
+	if !ok || local == "" {
+		return ""
+	}
+	return local[:1] + "***@" + domain
+}
+
+// deliveryArea is the last line of an address, which is usually the city and PIN code.
+func deliveryArea(address string) string {
+	parts := strings.FieldsFunc(address, func(r rune) bool { return r == ',' || r == '\n' })
+	for i := len(parts) - 1; i >= 0; i-- {
+		if part := strings.TrimSpace(parts[i]); part != "" {
+			// A single-line address has nothing to cut away.
+			if i == 0 {
+				return ""
+			}
+			return part
+		}
+	}
+	return ""
+}
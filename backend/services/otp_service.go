@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"mangal-chai-backend/messaging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+)
+
+var (
+	ErrInvalidPhone = errors.New("phone must be an Indian mobile number")
+	ErrOTPTooSoon   = errors.New("please wait before requesting another code")
+	ErrInvalidOTP   = errors.New("invalid or expired code")
+)
+
+const (
+	otpDigits         = 6
+	otpTTL            = 10 * time.Minute
+	otpResendInterval = 30 * time.Second
+	maxOTPAttempts    = 5
+)
+
+// OTPSender delivers a one-time password to a phone number.
+type OTPSender interface {
+	SendOTP(phone string, code string, ttl time.Duration) error
+}
+
+type OTPServiceInterface interface {
+	RequestOTP(ctx context.Context, phone string, purpose string) error
+	VerifyOTP(ctx context.Context, phone string, purpose string, code string) (string, error)
+}
+
+// OTPService issues and checks one-time passwords sent to a phone number. Purpose separates codes issued
+// for different actions so a code for one cannot be used for another.
+type OTPService struct {
+	Repository repositories.OTPRepositoryInterface
+	Sender     OTPSender
+}
+
+func (s *OTPService) RequestOTP(ctx context.Context, phone string, purpose string) error {
+	ctx, span := tracing.Start(ctx, "OTPService.RequestOTP")
+	defer span.End()
+
+	phone, ok := messaging.E164(phone)
+	if !ok {
+		return ErrInvalidPhone
+	}
+	now := time.Now()
+	if existing, err := s.Repository.GetOTP(ctx, phone, purpose); err == nil && now.Sub(existing.CreatedAt) < otpResendInterval {
+		return ErrOTPTooSoon
+	}
+
+	code, err := generateOTP()
+	if err != nil {
+		return err
+	}
+	otp := models.OneTimePassword{
+		Phone:     phone,
+		Purpose:   purpose,
+		CodeHash:  hashOTP(phone, purpose, code),
+		ExpiresAt: now.Add(otpTTL),
+		CreatedAt: now,
+	}
+	if err := s.Repository.SaveOTP(ctx, otp); err != nil {
+		return err
+	}
+	return s.Sender.SendOTP(phone, code, otpTTL)
+}
+
+// VerifyOTP checks a code and consumes it, returning the phone number in E.164 form. After maxOTPAttempts
+// wrong guesses the code is discarded and a new one has to be requested.
+func (s *OTPService) VerifyOTP(ctx context.Context, phone string, purpose string, code string) (string, error) {
+	ctx, span := tracing.Start(ctx, "OTPService.VerifyOTP")
+	defer span.End()
+
+	phone, ok := messaging.E164(phone)
+	if !ok {
+		return "", ErrInvalidPhone
+	}
+	otp, err := s.Repository.GetOTP(ctx, phone, purpose)
+	if err != nil || time.Now().After(otp.ExpiresAt) || otp.Attempts >= maxOTPAttempts {
+		return "", ErrInvalidOTP
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashOTP(phone, purpose, code)), []byte(otp.CodeHash)) != 1 {
+		if err := s.Repository.IncrementOTPAttempts(ctx, phone, purpose); err != nil {
+			return "", err
+		}
+		return "", ErrInvalidOTP
+	}
+	if err := s.Repository.DeleteOTP(ctx, phone, purpose); err != nil {
+		return "", err
+	}
+	return phone, nil
+}
+
+func generateOTP() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < otpDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpDigits, n), nil
+}
+
+func hashOTP(phone string, purpose string, code string) string {
+	sum := sha256.Sum256([]byte(phone + ":" + purpose + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
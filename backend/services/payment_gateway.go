@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/tracing"
+
+	"github.com/razorpay/razorpay-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PaymentGateway is the subset of the payment provider API the shop relies on. Amounts are in paise. Calls
+// are traced as children of the span in ctx.
+type PaymentGateway interface {
+	CreateOrder(ctx context.Context, amount int64, currency string, receipt string) (map[string]interface{}, error)
+	Refund(ctx context.Context, paymentID string, amount int64, notes map[string]string) (*GatewayRefund, error)
+	FetchRefund(ctx context.Context, refundID string) (*GatewayRefund, error)
+	FetchOrderPayments(ctx context.Context, gatewayOrderID string) ([]GatewayPayment, error)
+	VerifyWebhookSignature(body []byte, signature string) bool
+}
+
+// SubscriptionGateway is the part of the payment provider API used for subscriptions, which the gateway
+// charges on its own schedule once the customer has authorised them. Amounts are in paise.
+type SubscriptionGateway interface {
+	CreatePlan(ctx context.Context, name string, frequency string, amount int64) (string, error)
+	CreateSubscription(ctx context.Context, gatewayPlanID string, frequency string, notes map[string]string) (*GatewaySubscription, error)
+	PauseSubscription(ctx context.Context, gatewaySubscriptionID string) error
+	ResumeSubscription(ctx context.Context, gatewaySubscriptionID string) error
+	CancelSubscription(ctx context.Context, gatewaySubscriptionID string) error
+	Refund(ctx context.Context, paymentID string, amount int64, notes map[string]string) (*GatewayRefund, error)
+}
+
+// GatewaySubscription is the gateway's view of a subscription. ShortURL is where the customer authorises
+// its payments.
+type GatewaySubscription struct {
+	ID       string
+	Status   string
+	ShortURL string
+}
+
+// GatewayRefund is the gateway's view of a refund.
+type GatewayRefund struct {
+	ID     string
+	Status string
+	Amount int64
+}
+
+// GatewayPayment is the gateway's view of a payment attempt against a gateway order.
+type GatewayPayment struct {
+	ID     string
+	Status string
+	Method string
+	Amount int64
+}
+
+// Gateway payment statuses the shop acts on. An authorized payment is captured automatically shortly after.
+const (
+	GatewayPaymentAuthorized = "authorized"
+	GatewayPaymentCaptured   = "captured"
+)
+
+// RazorpayGateway implements PaymentGateway with the Razorpay API.
+type RazorpayGateway struct {
+	client        *razorpay.Client
+	mode          string
+	webhookSecret string
+}
+
+// NewRazorpayGateway creates a RazorpayGateway from RAZORPAY_KEY_ID and RAZORPAY_KEY_SECRET. Webhooks are
+// verified with RAZORPAY_WEBHOOK_SECRET and rejected if it is not set.
+func NewRazorpayGateway() *RazorpayGateway {
+	keyId := os.Getenv("RAZORPAY_KEY_ID")
+	keySecret := os.Getenv("RAZORPAY_KEY_SECRET")
+
+	if keyId == "" || keySecret == "" {
+		panic("RAZORPAY_KEY_ID or RAZORPAY_KEY_SECRET environment variable not set")
+	}
+
+	mode := "test"
+	if strings.HasPrefix(keyId, "rzp_live_") {
+		mode = "live"
+	}
+	return &RazorpayGateway{
+		client:        razorpay.NewClient(keyId, keySecret),
+		mode:          mode,
+		webhookSecret: os.Getenv("RAZORPAY_WEBHOOK_SECRET"),
+	}
+}
+
+// CheckConfig reports the gateway's configuration for the readiness probe: whether it uses test or live
+// keys, and whether webhooks can be verified. Without the webhook secret no payment is ever confirmed.
+func (g *RazorpayGateway) CheckConfig(ctx context.Context) (any, error) {
+	details := map[string]any{"mode": g.mode, "webhook_secret_configured": g.webhookSecret != ""}
+	if g.webhookSecret == "" {
+		return details, fmt.Errorf("RAZORPAY_WEBHOOK_SECRET is not set")
+	}
+	return details, nil
+}
+
+// startSpan starts a client span for a call to the Razorpay API. The client does not take a context, so the
+// span covers the whole call rather than the HTTP request alone.
+func startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := tracing.Start(ctx, "razorpay."+operation, attrs...)
+	span.SetAttributes(attribute.String("peer.service", "razorpay"))
+	return span
+}
+
+func (g *RazorpayGateway) CreateOrder(ctx context.Context, amount int64, currency string, receipt string) (order map[string]interface{}, err error) {
+	span := startSpan(ctx, "CreateOrder", attribute.String("razorpay.receipt", receipt), attribute.Int64("razorpay.amount", amount))
+	defer func() { tracing.End(span, err) }()
+
+	orderParams := map[string]interface{}{
+		"amount":   amount,
+		"currency": currency,
+		"receipt":  receipt,
+	}
+	return g.client.Order.Create(orderParams, nil)
+}
+
+func (g *RazorpayGateway) Refund(ctx context.Context, paymentID string, amount int64, notes map[string]string) (_ *GatewayRefund, err error) {
+	span := startSpan(ctx, "Refund", attribute.String("razorpay.payment_id", paymentID), attribute.Int64("razorpay.amount", amount))
+	defer func() { tracing.End(span, err) }()
+
+	data := map[string]interface{}{"speed": "normal"}
+	if len(notes) > 0 {
+		data["notes"] = notes
+	}
+	refund, err := g.client.Payment.Refund(paymentID, int(amount), data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseGatewayRefund(refund)
+}
+
+func (g *RazorpayGateway) FetchRefund(ctx context.Context, refundID string) (_ *GatewayRefund, err error) {
+	span := startSpan(ctx, "FetchRefund", attribute.String("razorpay.refund_id", refundID))
+	defer func() { tracing.End(span, err) }()
+
+	refund, err := g.client.Refund.Fetch(refundID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseGatewayRefund(refund)
+}
+
+func (g *RazorpayGateway) FetchOrderPayments(ctx context.Context, gatewayOrderID string) (_ []GatewayPayment, err error) {
+	span := startSpan(ctx, "FetchOrderPayments", attribute.String("razorpay.order_id", gatewayOrderID))
+	defer func() { tracing.End(span, err) }()
+
+	response, err := g.client.Order.Payments(gatewayOrderID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := response["items"].([]interface{})
+	payments := make([]GatewayPayment, 0, len(items))
+	for _, item := range items {
+		payment, _ := item.(map[string]interface{})
+		id, _ := payment["id"].(string)
+		status, _ := payment["status"].(string)
+		method, _ := payment["method"].(string)
+		amount, _ := payment["amount"].(float64)
+		payments = append(payments, GatewayPayment{ID: id, Status: status, Method: method, Amount: int64(amount)})
+	}
+	return payments, nil
+}
+
+// subscriptionYears is how long a gateway subscription runs before it has to be renewed; the gateway needs
+// a fixed number of cycles.
+const subscriptionYears = 10
+
+// razorpayPeriods maps subscription frequencies to a Razorpay plan period and interval, and how many cycles
+// make up subscriptionYears.
+var razorpayPeriods = map[string]struct {
+	period   string
+	interval int
+	perYear  int
+}{
+	models.FrequencyWeekly:   {"weekly", 1, 52},
+	models.FrequencyBiweekly: {"weekly", 2, 26},
+	models.FrequencyMonthly:  {"monthly", 1, 12},
+}
+
+func (g *RazorpayGateway) CreatePlan(ctx context.Context, name string, frequency string, amount int64) (_ string, err error) {
+	span := startSpan(ctx, "CreatePlan", attribute.String("razorpay.frequency", frequency))
+	defer func() { tracing.End(span, err) }()
+
+	period, ok := razorpayPeriods[frequency]
+	if !ok {
+		return "", fmt.Errorf("unsupported subscription frequency %q", frequency)
+	}
+	plan, err := g.client.Plan.Create(map[string]interface{}{
+		"period":   period.period,
+		"interval": period.interval,
+		"item":     map[string]interface{}{"name": name, "amount": amount, "currency": "INR"},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	id, _ := plan["id"].(string)
+	if id == "" {
+		return "", fmt.Errorf("gateway plan response has no id")
+	}
+	return id, nil
+}
+
+func (g *RazorpayGateway) CreateSubscription(ctx context.Context, gatewayPlanID string, frequency string, notes map[string]string) (_ *GatewaySubscription, err error) {
+	span := startSpan(ctx, "CreateSubscription", attribute.String("razorpay.plan_id", gatewayPlanID))
+	defer func() { tracing.End(span, err) }()
+
+	period, ok := razorpayPeriods[frequency]
+	if !ok {
+		return nil, fmt.Errorf("unsupported subscription frequency %q", frequency)
+	}
+	data := map[string]interface{}{
+		"plan_id":         gatewayPlanID,
+		"total_count":     period.perYear * subscriptionYears,
+		"customer_notify": 1,
+	}
+	if len(notes) > 0 {
+		data["notes"] = notes
+	}
+	subscription, err := g.client.Subscription.Create(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseGatewaySubscription(subscription)
+}
+
+func (g *RazorpayGateway) PauseSubscription(ctx context.Context, gatewaySubscriptionID string) error {
+	span := startSpan(ctx, "PauseSubscription", attribute.String("razorpay.subscription_id", gatewaySubscriptionID))
+	_, err := g.client.Subscription.Pause(gatewaySubscriptionID, map[string]interface{}{"pause_at": "now"}, nil)
+	tracing.End(span, err)
+	return err
+}
+
+func (g *RazorpayGateway) ResumeSubscription(ctx context.Context, gatewaySubscriptionID string) error {
+	span := startSpan(ctx, "ResumeSubscription", attribute.String("razorpay.subscription_id", gatewaySubscriptionID))
+	_, err := g.client.Subscription.Resume(gatewaySubscriptionID, map[string]interface{}{"resume_at": "now"}, nil)
+	tracing.End(span, err)
+	return err
+}
+
+func (g *RazorpayGateway) CancelSubscription(ctx context.Context, gatewaySubscriptionID string) error {
+	span := startSpan(ctx, "CancelSubscription", attribute.String("razorpay.subscription_id", gatewaySubscriptionID))
+	_, err := g.client.Subscription.Cancel(gatewaySubscriptionID, map[string]interface{}{"cancel_at_cycle_end": 0}, nil)
+	tracing.End(span, err)
+	return err
+}
+
+func (g *RazorpayGateway) VerifyWebhookSignature(body []byte, signature string) bool {
+	if g.webhookSecret == "" || signature == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(g.webhookSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func parseGatewayRefund(refund map[string]interface{}) (*GatewayRefund, error) {
+	id, _ := refund["id"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("gateway refund response has no id")
+	}
+	status, _ := refund["status"].(string)
+	// The Razorpay client decodes JSON numbers as float64.
+	amount, _ := refund["amount"].(float64)
+	return &GatewayRefund{ID: id, Status: status, Amount: int64(amount)}, nil
+}
+
+func parseGatewaySubscription(subscription map[string]interface{}) (*GatewaySubscription, error) {
+	id, _ := subscription["id"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("gateway subscription response has no id")
+	}
+	status, _ := subscription["status"].(string)
+	shortURL, _ := subscription["short_url"].(string)
+	return &GatewaySubscription{ID: id, Status: status, ShortURL: shortURL}, nil
+}
+
+// toPaise converts a rupee amount to the integer paise the gateway expects.
+func toPaise(rupees float64) int64 {
+	return int64(math.Round(rupees * 100))
+}
@@ -1,53 +1,266 @@
-
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/metrics"
 	"mangal-chai-backend/models"
-	"os"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
 
-	"github.com/razorpay/razorpay-go"
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrNothingToPay            = errors.New("order has nothing left to pay")
 )
 
 // PaymentService handles payment related logic
 type PaymentService struct {
-	razorpayClient *razorpay.Client
+	Gateway         PaymentGateway
+	OrderRepository repositories.OrderRepositoryInterface
+	Refunds         RefundServiceInterface
+	Subscriptions   SubscriptionEventHandler
 }
 
+// SubscriptionEventHandler applies the gateway's subscription events: the subscription's status changing,
+// or a charge for one of its cycles.
+type SubscriptionEventHandler interface {
+	HandleSubscriptionEvent(ctx context.Context, event string, gatewaySubscriptionID string, payment GatewayPayment) error
+}
+
+// CreateRazorpayOrderRequest identifies what to charge for. When OrderID is set the gateway order is created
+// for what is left of that order's total after any gift card or store credit, and linked to it so the
+// payment can be matched when it is captured.
 type CreateRazorpayOrderRequest struct {
-	Items []models.CartItem `json:"items"`
+	OrderID string            `json:"order_id"`
+	Items   []models.CartItem `json:"items"`
 }
 
 // NewPaymentService creates a new PaymentService
-func NewPaymentService() *PaymentService {
-	keyId := os.Getenv("RAZORPAY_KEY_ID")
-	keySecret := os.Getenv("RAZORPAY_KEY_SECRET")
-
-	if keyId == "" || keySecret == "" {
-		panic("RAZORPAY_KEY_ID or RAZORPAY_KEY_SECRET environment variable not set")
-	}
-
-	client := razorpay.NewClient(keyId, keySecret)
-
-	return &PaymentService{razorpayClient: client}
+func NewPaymentService(gateway PaymentGateway, orderRepository repositories.OrderRepositoryInterface, refunds RefundServiceInterface) *PaymentService {
+	return &PaymentService{Gateway: gateway, OrderRepository: orderRepository, Refunds: refunds}
 }
 
 // CreateRazorpayOrder creates a new Razorpay order
-func (ps *PaymentService) CreateRazorpayOrder(request CreateRazorpayOrderRequest) (map[string]interface{}, error) {
+func (ps *PaymentService) CreateRazorpayOrder(ctx context.Context, request CreateRazorpayOrderRequest) (map[string]interface{}, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.CreateRazorpayOrder")
+	defer span.End()
+
+	if request.OrderID != "" {
+		return ps.createGatewayOrderFor(ctx, request.OrderID)
+	}
+
 	// This is a placeholder for calculating the order amount based on the items
 	// In a real application, you would fetch the product prices from your database
 	// and calculate the total amount
 	var totalAmount int64 = 100000 // Placeholder amount (e.g., 1000.00 INR)
 
-	orderParams := map[string]interface{}{
-		"amount":   totalAmount,
-		"currency": "INR",
-		"receipt":  "some_receipt_id", // Replace with a unique receipt ID
+	order, err := ps.Gateway.CreateOrder(ctx, totalAmount, "INR", "some_receipt_id") // Replace with a unique receipt ID
+	if err != nil {
+		return nil, err
 	}
 
-	order, err := ps.razorpayClient.Order.Create(orderParams, nil)
+	return order, nil
+}
+
+func (ps *PaymentService) createGatewayOrderFor(ctx context.Context, orderID string) (map[string]interface{}, error) {
+	order, err := ps.OrderRepository.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	due := AmountDue(order)
+	if due <= 0 || order.PaymentStatus == models.PaymentStatusPaid {
+		return nil, ErrNothingToPay
+	}
+
+	// A retried checkout pays the gateway order it already has, unless the amount due has changed since.
+	amount := toPaise(due)
+	if order.PaymentGatewayOrderID != "" && order.PaymentGatewayAmount == amount {
+		return map[string]interface{}{"id": order.PaymentGatewayOrderID, "amount": amount, "currency": "INR", "receipt": order.ID}, nil
+	}
+
+	gatewayOrder, err := ps.Gateway.CreateOrder(ctx, amount, "INR", order.ID)
 	if err != nil {
 		return nil, err
 	}
+	gatewayOrderID, _ := gatewayOrder["id"].(string)
+	if err := ps.OrderRepository.SetPaymentGatewayOrder(ctx, order.ID, gatewayOrderID, amount); err != nil {
+		return nil, err
+	}
+	return gatewayOrder, nil
+}
 
-	return order, nil
+// webhookEvent is the part of a Razorpay webhook payload the shop uses.
+type webhookEvent struct {
+	Event   string `json:"event"`
+	Payload struct {
+		Payment struct {
+			Entity struct {
+				ID          string `json:"id"`
+				OrderID     string `json:"order_id"`
+				Method      string `json:"method"`
+				Amount      int64  `json:"amount"`
+				ErrorReason string `json:"error_reason"`
+			} `json:"entity"`
+		} `json:"payment"`
+		Subscription struct {
+			Entity struct {
+				ID string `json:"id"`
+			} `json:"entity"`
+		} `json:"subscription"`
+		Refund struct {
+			Entity struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+			} `json:"entity"`
+		} `json:"refund"`
+	} `json:"payload"`
+}
+
+// HandleWebhook verifies and applies a gateway webhook. Events for unknown orders or refunds are ignored so
+// the gateway does not keep retrying them.
+func (ps *PaymentService) HandleWebhook(ctx context.Context, body []byte, signature string) error {
+	ctx, span := tracing.Start(ctx, "PaymentService.HandleWebhook")
+	defer span.End()
+
+	if !ps.Gateway.VerifyWebhookSignature(body, signature) {
+		return ErrInvalidWebhookSignature
+	}
+
+	var event webhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return err
+	}
+
+	switch event.Event {
+	case "payment.captured":
+		payment := event.Payload.Payment.Entity
+		return ps.recordPayment(ctx, payment.OrderID, payment.ID, payment.Method)
+	case "payment.failed":
+		// The customer can try again with the same gateway order, so a failure only needs counting.
+		payment := event.Payload.Payment.Entity
+		reason := payment.ErrorReason
+		if reason == "" {
+			reason = "unknown"
+		}
+		metrics.PaymentsFailed.WithLabelValues(reason).Inc()
+		logging.FromContext(ctx).Info("Payment failed", "payment_id", payment.ID, "gateway_order_id", payment.OrderID, "reason", reason)
+		return nil
+	case "refund.processed", "refund.failed":
+		refund := event.Payload.Refund.Entity
+		status := models.RefundStatusProcessed
+		if event.Event == "refund.failed" {
+			status = models.RefundStatusFailed
+		}
+		err := ps.Refunds.UpdateFromGateway(ctx, refund.ID, status, "")
+		if errors.Is(err, ErrRefundNotFound) {
+			logging.FromContext(ctx).Warn("Ignoring event for unknown refund", "event", event.Event, "refund_id", refund.ID)
+			return nil
+		}
+		return err
+	}
+	if strings.HasPrefix(event.Event, "subscription.") {
+		if ps.Subscriptions == nil {
+			logging.FromContext(ctx).Warn("Ignoring event, subscriptions are not configured", "event", event.Event)
+			return nil
+		}
+		payment := event.Payload.Payment.Entity
+		return ps.Subscriptions.HandleSubscriptionEvent(ctx, event.Event, event.Payload.Subscription.Entity.ID,
+			GatewayPayment{ID: payment.ID, Status: GatewayPaymentCaptured, Method: payment.Method, Amount: payment.Amount})
+	}
+	return nil
+}
+
+// ReconcilePayment asks the gateway whether an unpaid order was in fact paid, for orders whose webhook is
+// late or was missed. A captured payment is recorded as if its webhook had arrived. It reports whether the
+// order has a captured or authorized payment, in which case it must not be expired.
+func (ps *PaymentService) ReconcilePayment(ctx context.Context, order models.Order) (bool, error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.ReconcilePayment")
+	defer span.End()
+
+	inFlight := false
+	for _, gatewayOrderID := range order.PaymentGatewayOrderIDs {
+		payments, err := ps.Gateway.FetchOrderPayments(ctx, gatewayOrderID)
+		if err != nil {
+			return false, err
+		}
+		for _, payment := range payments {
+			switch payment.Status {
+			case GatewayPaymentCaptured:
+				return true, ps.recordPayment(ctx, gatewayOrderID, payment.ID, payment.Method)
+			case GatewayPaymentAuthorized:
+				inFlight = true
+			}
+		}
+	}
+	return inFlight, nil
+}
+
+// recordPayment marks the order paid and confirms it.
+func (ps *PaymentService) recordPayment(ctx context.Context, gatewayOrderID string, paymentID string, method string) error {
+	order, err := ps.OrderRepository.RecordPayment(ctx, gatewayOrderID, paymentID, method)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Ignoring payment for unknown or already paid gateway order", "payment_id", paymentID, "gateway_order_id", gatewayOrderID)
+		return nil
+	}
+	countPayment(method)
+	order.PaymentMethod = method
+	return ps.ConfirmPayment(ctx, order)
+}
+
+// countPayment adds a newly recorded gateway payment to the payment metrics.
+func countPayment(method string) {
+	metrics.PaymentsSucceeded.WithLabelValues(paymentMethodLabel(method)).Inc()
+}
+
+// countRevenue adds a confirmed order to the revenue metric, under its payment method.
+func countRevenue(order *models.Order) {
+	metrics.Revenue.WithLabelValues(paymentMethodLabel(order.PaymentMethod)).Add(order.TotalAmount)
+}
+
+func paymentMethodLabel(method string) string {
+	if method == "" {
+		return "unknown"
+	}
+	return method
+}
+
+// ConfirmPayment confirms an order whose payment has just been recorded; order is as it was before, with
+// the payment's method. The order counts as revenue once confirmed. A payment that arrives after the order
+// was cancelled or expired is refunded straight away instead.
+func (ps *PaymentService) ConfirmPayment(ctx context.Context, order *models.Order) error {
+	ctx, span := tracing.Start(ctx, "PaymentService.ConfirmPayment")
+	defer span.End()
+
+	if order.Status == models.OrderStatusPending {
+		change := models.StatusChange{Status: models.OrderStatusConfirmed, Reason: "payment captured", ChangedAt: time.Now()}
+		outbox := notifyOn(models.OrderEventPaymentConfirmed, order.ID, 0)
+		_, err := ps.OrderRepository.TransitionStatus(ctx, order.ID, []string{models.OrderStatusPending}, change, outbox)
+		if err == nil {
+			countRevenue(order)
+			return nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		// The order was cancelled or expired after the payment was recorded.
+		if order, err = ps.OrderRepository.GetOrder(ctx, order.ID); err != nil {
+			return err
+		}
+	}
+
+	switch order.Status {
+	case models.OrderStatusCancelled, models.OrderStatusExpired:
+		_, err := ps.Refunds.IssueRefund(ctx, order.ID, RefundRequest{Reason: "Payment captured after the order was " + order.Status})
+		return err
+	}
+	countRevenue(order)
+	return nil
 }
@@ -2,46 +2,154 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"sort"
+
 	"mangal-chai-backend/models"
 	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
 )
 
+var ErrProductNotFound = errors.New("product not found")
+
+// RestockNotifier is told when a product that had run out has stock again.
+type RestockNotifier interface {
+	ProductRestocked(ctx context.Context, productID string)
+}
+
 type ProductServiceInterface interface {
-	GetProducts() ([]models.Product, error)
-	GetProduct(id string) (*models.Product, error)
-	GetProductsByCategory(category string) ([]models.Product, error)
-	GetCategories() ([]string, error)
-	SeedProducts() error
+	GetProducts(ctx context.Context) ([]models.Product, error)
+	GetProduct(ctx context.Context, id string) (*models.Product, error)
+	GetProductsByCategory(ctx context.Context, category string) ([]models.Product, error)
+	GetCategories(ctx context.Context) ([]string, error)
+	ImportCatalog(ctx context.Context, entries []models.CatalogEntry, dryRun bool) (*CatalogImportResult, error)
+	ExportCatalog(ctx context.Context) ([]models.Product, error)
+}
+
+// CatalogImportResult lists product ids by what an import did, or would do on a dry run, to them.
+type CatalogImportResult struct {
+	DryRun    bool     `json:"dry_run"`
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
 }
 
 type ProductService struct {
 	Repository repositories.ProductRepositoryInterface
+	Restocks   RestockNotifier
+}
+
+func (s *ProductService) GetProducts(ctx context.Context) ([]models.Product, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.GetProducts")
+	defer span.End()
+
+	return s.Repository.GetProducts(ctx)
+}
+
+func (s *ProductService) GetProduct(ctx context.Context, id string) (*models.Product, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.GetProduct")
+	defer span.End()
+
+	return s.Repository.GetProduct(ctx, id)
 }
 
-func (s *ProductService) GetProducts() ([]models.Product, error) {
-	return s.Repository.GetProducts()
+func (s *ProductService) GetProductsByCategory(ctx context.Context, category string) ([]models.Product, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.GetProductsByCategory")
+	defer span.End()
+
+	return s.Repository.GetProductsByCategory(ctx, category)
 }
 
-func (s *ProductService) GetProduct(id string) (*models.Product, error) {
-	return s.Repository.GetProduct(id)
+func (s *ProductService) GetCategories(ctx context.Context) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.GetCategories")
+	defer span.End()
+
+	return s.Repository.GetCategories(ctx)
 }
 
-func (s *ProductService) GetProductsByCategory(category string) ([]models.Product, error) {
-	return s.Repository.GetProductsByCategory(category)
+// ImportCatalog upserts the given products by id. Products missing from the import are left untouched, and
+// products identical to the stored copy are not rewritten. A product imported without a stock, HSN code or
+// GST rate keeps the stored one, so files that leave out those columns do not clear them. With dryRun
+// nothing is written.
+func (s *ProductService) ImportCatalog(ctx context.Context, entries []models.CatalogEntry, dryRun bool) (*CatalogImportResult, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.ImportCatalog")
+	defer span.End()
+
+	existing, err := s.Repository.GetProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]models.Product, len(existing))
+	for _, product := range existing {
+		current[product.ID] = product
+	}
+
+	result := &CatalogImportResult{DryRun: dryRun}
+	var changed []models.CatalogEntry
+	for _, entry := range entries {
+		product := entry.Product
+		stored, ok := current[product.ID]
+		if ok {
+			product.Rating, product.ReviewCount = stored.Rating, stored.ReviewCount
+			if !entry.HasStock {
+				product.Stock, product.InStock = stored.Stock, stored.InStock
+			}
+			if product.HSNCode == "" {
+				product.HSNCode = stored.HSNCode
+			}
+			if product.GSTRate == 0 {
+				product.GSTRate = stored.GSTRate
+			}
+		}
+		entry.Product = product
+		switch {
+		case !ok:
+			result.Created = append(result.Created, product.ID)
+			changed = append(changed, entry)
+		case stored != product:
+			result.Updated = append(result.Updated, product.ID)
+			changed = append(changed, entry)
+		default:
+			result.Unchanged = append(result.Unchanged, product.ID)
+		}
+	}
+
+	if !dryRun {
+		if err := s.Repository.UpsertProducts(ctx, changed); err != nil {
+			return nil, err
+		}
+		s.notifyRestocked(ctx, current, changed)
+	}
+	return result, nil
 }
 
-func (s *ProductService) GetCategories() ([]string, error) {
-	return s.Repository.GetCategories()
+// notifyRestocked reports the imported products that had run out and now have stock.
+func (s *ProductService) notifyRestocked(ctx context.Context, before map[string]models.Product, changed []models.CatalogEntry) {
+	if s.Restocks == nil {
+		return
+	}
+	for _, product := range changed {
+		if stored, ok := before[product.ID]; ok && stored.Stock <= 0 && product.Stock > 0 {
+			s.Restocks.ProductRestocked(ctx, product.ID)
+		}
+	}
 }
 
-func (s *ProductService) SeedProducts() error {
-	sampleProducts := []interface{}{
-		models.Product{ID: "a1b2c3d4-e5f6-7890-1234-567890abcdef", Name: "Premium Assam Black Tea", Description: "Rich, malty Assam tea with robust flavor. Perfect for morning tea with milk and sugar. Sourced from the finest tea gardens of Assam.", Price: 299.0, Category: "Black Tea", ImageURL: "https://images.unsplash.com/photo-1563822249366-3efb23b8e0c9", InStock: true, Weight: "100g"},
-		models.Product{ID: "b2c3d4e5-f6a7-8901-2345-67890abcdef0", Name: "Darjeeling Muscatel", Description: "Delicate and aromatic Darjeeling tea with a distinctive muscatel flavor. Known as the 'Champagne of Teas'.", Price: 450.0, Category: "Black Tea", ImageURL: "https://images.pexels.com/photos/1793034/pexels-photo-1793034.jpeg", InStock: true, Weight: "100g"},
-		models.Product{ID: "c3d4e5f6-a7b8-9012-3456-7890abcdef01", Name: "Traditional Masala Chai", Description: "Our signature blend of black tea with cardamom, cinnamon, cloves, and ginger. A 60-year-old family recipe.", Price: 199.0, Category: "Masala Chai", ImageURL: "https://images.pexels.com/photos/5947062/pexels-photo-5947062.jpeg", InStock: true, Weight: "200g"},
-		models.Product{ID: "d4e5f6a7-b8c9-0123-4567-890abcdef012", Name: "Royal Jaipur Blend", Description: "A premium blend inspired by royal traditions of Jaipur. Mix of fine Assam tea with aromatic spices.", Price: 399.0, Category: "Special Blends", ImageURL: "https://images.unsplash.com/photo-1625033405953-f20401c7d848", InStock: true, Weight: "150g"},
-		models.Product{ID: "e5f6a7b8-c9d0-1234-5678-90abcdef0123", Name: "Green Tea Classic", Description: "Pure green tea leaves with natural antioxidants. Light, refreshing taste perfect for health-conscious tea lovers.", Price: 349.0, Category: "Green Tea", ImageURL: "https://images.unsplash.com/photo-1521136492500-e18f107709f7", InStock: true, Weight: "100g"},
-		models.Product{ID: "f6a7b8c9-d0e1-2345-6789-0abcdef01234", Name: "Cardamom Tea", Description: "Aromatic tea infused with premium green cardamom. A classic favorite for its warming and soothing properties.", Price: 259.0, Category: "Flavored Tea", ImageURL: "https://images.pexels.com/photos/3904035/pexels-photo-3904035.jpeg", InStock: true, Weight: "100g"},
+// ExportCatalog returns every product ordered by category and name, ready to be written to a catalog file.
+func (s *ProductService) ExportCatalog(ctx context.Context) ([]models.Product, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.ExportCatalog")
+	defer span.End()
+
+	products, err := s.Repository.GetProducts(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return s.Repository.SeedProducts(sampleProducts)
+	sort.Slice(products, func(i, j int) bool {
+		if products[i].Category != products[j].Category {
+			return products[i].Category < products[j].Category
+		}
+		return products[i].Name < products[j].Name
+	})
+	return products, nil
 }
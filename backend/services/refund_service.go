@@ -0,0 +1,538 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/metrics"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrRefundNotFound      = errors.New("refund not found")
+	ErrOrderNotPaid        = errors.New("order has no captured payment to refund")
+	ErrNothingToRefund     = errors.New("order has already been fully refunded")
+	ErrInvalidRefund       = errors.New("invalid refund")
+	ErrGatewayRefundFailed = errors.New("payment gateway rejected the refund")
+)
+
+type RefundServiceInterface interface {
+	IssueRefund(ctx context.Context, orderID string, request RefundRequest) (*models.Refund, error)
+	GetOrderRefunds(ctx context.Context, orderID string) ([]models.Refund, error)
+	ListRefunds(ctx context.Context, status string) ([]models.Refund, error)
+	SyncRefund(ctx context.Context, id string) (*models.Refund, error)
+	UpdateFromGateway(ctx context.Context, gatewayRefundID string, status string, failureReason string) error
+}
+
+// RefundRequest asks for a refund of the given line items, or of the whole remaining balance when Items is
+// empty. ReturnID links the refund to the return request it settles, if any. StoreCredit pays the refund as
+// store credit instead of through the gateway.
+type RefundRequest struct {
+	Items       []models.CartItem `json:"items"`
+	Reason      string            `json:"reason" binding:"required"`
+	ReturnID    string            `json:"return_id,omitempty"`
+	StoreCredit bool              `json:"store_credit,omitempty"`
+}
+
+// GiftCardVoider takes back the gift cards bought in an order when the order is refunded.
+type GiftCardVoider interface {
+	OrderGiftCards(ctx context.Context, orderID string) ([]models.GiftCard, error)
+	VoidGiftCards(ctx context.Context, orderID string, refundID string, codes []string) error
+	ReinstateGiftCards(ctx context.Context, orderID string, refundID string, codes []string)
+}
+
+type RefundService struct {
+	OrderRepository  repositories.OrderRepositoryInterface
+	RefundRepository repositories.RefundRepositoryInterface
+	Gateway          PaymentGateway
+	StoredValue      StoredValue
+	Loyalty          PointsReverser
+	GiftCards        GiftCardVoider
+}
+
+// IssueRefund records a refund against the order and submits it to the payment gateway. The refund is
+// recorded before the gateway is called so every attempt is accounted for; if the gateway rejects it the
+// record is marked failed and ErrGatewayRefundFailed is returned along with it. Its amount is first
+// reserved on the order, so refunds issued at the same time cannot together exceed the order's total.
+// Orders whose last refund failed can be refunded again.
+//
+// The gateway refunds at most what was paid through it; a full-balance refund of an order paid partly with
+// a gift card or store credit only covers that part, and the rest can then be refunded as store credit.
+// Store credit goes to the customer's wallet, or back onto the gift card for a guest's order. Orders paid
+// entirely without the gateway are always refunded as store credit.
+//
+// Gift cards bought in the order are voided by the refund of their line, or of the whole balance, and the
+// refund is rejected if one of them has been used.
+func (s *RefundService) IssueRefund(ctx context.Context, orderID string, request RefundRequest) (*models.Refund, error) {
+	ctx, span := tracing.Start(ctx, "RefundService.IssueRefund")
+	defer span.End()
+
+	order, err := s.OrderRepository.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	switch order.PaymentStatus {
+	case models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefundFailed:
+	default:
+		return nil, ErrOrderNotPaid
+	}
+
+	previous, err := s.RefundRepository.GetRefundsByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	refunded, refundedQuantities := refundedSoFar(previous)
+	remaining := roundRupees(order.TotalAmount - refunded)
+	if remaining <= 0 {
+		return nil, ErrNothingToRefund
+	}
+	var cards []models.GiftCard
+	if s.GiftCards != nil {
+		if cards, err = s.GiftCards.OrderGiftCards(ctx, order.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	online := onlineRefundable(order, previous)
+	method := models.RefundMethodGateway
+	if request.StoreCredit || online <= 0 {
+		method = models.RefundMethodStoreCredit
+	}
+
+	amount := remaining
+	if method == models.RefundMethodGateway {
+		amount = math.Min(remaining, online)
+	}
+	var items []models.CartItem
+	if len(request.Items) > 0 {
+		amount, items, err = lineItemAmount(order, request.Items, refundedQuantities, giftCardProducts(cards))
+		if err != nil {
+			return nil, err
+		}
+		if amount > remaining {
+			return nil, fmt.Errorf("%w: %.2f exceeds the refundable balance of %.2f", ErrInvalidRefund, amount, remaining)
+		}
+		if method == models.RefundMethodGateway && amount > online {
+			return nil, fmt.Errorf("%w: %.2f exceeds the %.2f left of the online payment, refund it as store credit", ErrInvalidRefund, amount, online)
+		}
+	}
+	voided, err := giftCardsToVoid(cards, items)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	refund := models.Refund{
+		ID:            fmt.Sprintf("rfd_%d", now.UnixNano()),
+		OrderID:       order.ID,
+		Method:        method,
+		ReturnID:      request.ReturnID,
+		Items:         items,
+		Amount:        amount,
+		GiftCardCodes: voided,
+		Reason:        request.Reason,
+		Status:        models.RefundStatusPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	var credit models.LedgerEntry
+	if method == models.RefundMethodGateway {
+		refund.PaymentID = order.PaymentID
+	} else {
+		if credit, err = s.storeCredit(order); err != nil {
+			return nil, err
+		}
+		if credit.Account == models.LedgerAccountGiftCard {
+			refund.Method = models.RefundMethodGiftCard
+		}
+	}
+	if len(voided) > 0 {
+		if err := s.GiftCards.VoidGiftCards(ctx, order.ID, refund.ID, voided); err != nil {
+			if errors.Is(err, ErrGiftCardUsed) {
+				return nil, fmt.Errorf("%w: %v, refund the order's other items instead", ErrInvalidRefund, err)
+			}
+			return nil, err
+		}
+	}
+	if err := s.OrderRepository.ReserveRefund(ctx, order.ID, amount); err != nil {
+		s.reinstateGiftCards(ctx, &refund)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Another refund of the order was issued since the balance was read.
+			return nil, ErrNothingToRefund
+		}
+		return nil, err
+	}
+	if err := s.RefundRepository.CreateRefund(ctx, refund); err != nil {
+		s.releaseRefund(ctx, &refund)
+		return nil, err
+	}
+
+	paymentStatus := models.PaymentStatusPartiallyRefunded
+	if amount >= remaining {
+		paymentStatus = models.PaymentStatusRefunded
+	}
+	if method != models.RefundMethodGateway {
+		result, err := s.creditRefund(ctx, order, refund, credit, paymentStatus)
+		if err == nil {
+			s.reversePoints(ctx, order, refunded+amount)
+		}
+		return result, err
+	}
+
+	notes := map[string]string{"order_id": order.ID, "refund_id": refund.ID, "reason": request.Reason}
+	gatewayRefund, err := s.Gateway.Refund(ctx, order.PaymentID, toPaise(amount), notes)
+	if err != nil {
+		refund.Status = models.RefundStatusFailed
+		refund.FailureReason = err.Error()
+		if updateErr := s.RefundRepository.UpdateRefundStatus(ctx, refund.ID, refund.Status, refund.FailureReason); updateErr != nil {
+			logging.FromContext(ctx).Error("Failed to mark refund failed", "refund_id", refund.ID, "order_id", refund.OrderID, "error", updateErr)
+		}
+		s.releaseRefund(ctx, &refund)
+		return &refund, fmt.Errorf("%w: %v", ErrGatewayRefundFailed, err)
+	}
+
+	refund.GatewayRefundID = gatewayRefund.ID
+	refund.Status = refundStatusFromGateway(gatewayRefund.Status)
+	if err := s.RefundRepository.SetGatewayRefund(ctx, refund.ID, refund.GatewayRefundID, refund.Status); err != nil {
+		logging.FromContext(ctx).Error("Failed to record gateway refund", "gateway_refund_id", gatewayRefund.ID, "refund_id", refund.ID, "order_id", refund.OrderID, "error", err)
+	}
+	if refund.Status == models.RefundStatusProcessed {
+		countRefund(&refund)
+	}
+
+	if err := s.OrderRepository.SetRefund(ctx, order.ID, gatewayRefund.ID, paymentStatus); err != nil {
+		logging.FromContext(ctx).Error("Failed to update payment status of order", "order_id", order.ID, "error", err)
+	}
+	s.reversePoints(ctx, order, refunded+amount)
+
+	return &refund, nil
+}
+
+// reversePoints takes back the loyalty points the refunded share of the order earned. The refund stands
+// if that fails.
+func (s *RefundService) reversePoints(ctx context.Context, order *models.Order, refunded float64) {
+	if s.Loyalty == nil {
+		return
+	}
+	if err := s.Loyalty.ReversePoints(ctx, *order, refunded); err != nil {
+		logging.FromContext(ctx).Error("Failed to reverse loyalty points of order", "order_id", order.ID, "error", err)
+	}
+}
+
+// releaseRefund gives back the amount a failed refund reserved on its order, so it can be refunded again,
+// and the gift cards it voided.
+func (s *RefundService) releaseRefund(ctx context.Context, refund *models.Refund) {
+	if err := s.OrderRepository.ReleaseRefund(ctx, refund.OrderID, refund.Amount); err != nil {
+		logging.FromContext(ctx).Error("Failed to release refunded amount of order", "order_id", refund.OrderID, "amount", refund.Amount, "error", err)
+	}
+	s.reinstateGiftCards(ctx, refund)
+}
+
+func (s *RefundService) reinstateGiftCards(ctx context.Context, refund *models.Refund) {
+	if len(refund.GiftCardCodes) > 0 && s.GiftCards != nil {
+		s.GiftCards.ReinstateGiftCards(ctx, refund.OrderID, refund.ID, refund.GiftCardCodes)
+	}
+}
+
+// storeCredit returns the ledger entry, without an amount, that pays an order's refund as store credit:
+// to the customer's wallet, or for a guest back onto the gift card they paid with.
+func (s *RefundService) storeCredit(order *models.Order) (models.LedgerEntry, error) {
+	entry := models.LedgerEntry{Kind: models.LedgerKindRefund, OrderID: order.ID}
+	switch {
+	case s.StoredValue == nil:
+		return entry, fmt.Errorf("%w: store credit is not available", ErrInvalidRefund)
+	case order.CustomerID != "":
+		entry.Account, entry.AccountID = models.LedgerAccountWallet, order.CustomerID
+	case order.GiftCardCode != "":
+		entry.Account, entry.AccountID = models.LedgerAccountGiftCard, order.GiftCardCode
+	default:
+		return entry, fmt.Errorf("%w: guest order %s can only be refunded to its original payment", ErrInvalidRefund, order.ID)
+	}
+	return entry, nil
+}
+
+// creditRefund pays a recorded refund as store credit; it is processed as soon as the credit is made.
+func (s *RefundService) creditRefund(ctx context.Context, order *models.Order, refund models.Refund, credit models.LedgerEntry, paymentStatus string) (*models.Refund, error) {
+	credit.Amount = refund.Amount
+	credit.RefundID = refund.ID
+	if err := s.StoredValue.Credit(ctx, credit); err != nil {
+		refund.Status = models.RefundStatusFailed
+		refund.FailureReason = err.Error()
+		if updateErr := s.RefundRepository.UpdateRefundStatus(ctx, refund.ID, refund.Status, refund.FailureReason); updateErr != nil {
+			logging.FromContext(ctx).Error("Failed to mark refund failed", "refund_id", refund.ID, "order_id", refund.OrderID, "error", updateErr)
+		}
+		s.releaseRefund(ctx, &refund)
+		return &refund, err
+	}
+
+	refund.Status = models.RefundStatusProcessed
+	if err := s.RefundRepository.UpdateRefundStatus(ctx, refund.ID, refund.Status, ""); err != nil {
+		logging.FromContext(ctx).Error("Failed to mark refund processed", "refund_id", refund.ID, "order_id", refund.OrderID, "error", err)
+	}
+	countRefund(&refund)
+	if err := s.OrderRepository.SetRefund(ctx, order.ID, order.RefundID, paymentStatus); err != nil {
+		logging.FromContext(ctx).Error("Failed to update payment status of order", "order_id", order.ID, "error", err)
+	}
+	return &refund, nil
+}
+
+func (s *RefundService) GetOrderRefunds(ctx context.Context, orderID string) ([]models.Refund, error) {
+	ctx, span := tracing.Start(ctx, "RefundService.GetOrderRefunds")
+	defer span.End()
+
+	return s.RefundRepository.GetRefundsByOrder(ctx, orderID)
+}
+
+func (s *RefundService) ListRefunds(ctx context.Context, status string) ([]models.Refund, error) {
+	ctx, span := tracing.Start(ctx, "RefundService.ListRefunds")
+	defer span.End()
+
+	return s.RefundRepository.ListRefunds(ctx, status)
+}
+
+// SyncRefund refreshes a refund's status from the gateway, for refunds whose webhook was missed.
+func (s *RefundService) SyncRefund(ctx context.Context, id string) (*models.Refund, error) {
+	ctx, span := tracing.Start(ctx, "RefundService.SyncRefund")
+	defer span.End()
+
+	refund, err := s.RefundRepository.GetRefund(ctx, id)
+	if err != nil {
+		return nil, ErrRefundNotFound
+	}
+	if refund.GatewayRefundID == "" {
+		return refund, nil
+	}
+
+	gatewayRefund, err := s.Gateway.FetchRefund(ctx, refund.GatewayRefundID)
+	if err != nil {
+		return nil, err
+	}
+	status := refundStatusFromGateway(gatewayRefund.Status)
+	if status != refund.Status {
+		if err := s.RefundRepository.UpdateRefundStatus(ctx, refund.ID, status, ""); err != nil {
+			return nil, err
+		}
+		switch status {
+		case models.RefundStatusFailed:
+			s.releaseRefund(ctx, refund)
+		case models.RefundStatusProcessed:
+			countRefund(refund)
+		}
+		refund.Status = status
+		if status == models.RefundStatusFailed {
+			if err := s.updatePaymentStatus(ctx, refund); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return refund, nil
+}
+
+// UpdateFromGateway applies a refund status reported by a gateway webhook.
+func (s *RefundService) UpdateFromGateway(ctx context.Context, gatewayRefundID string, status string, failureReason string) error {
+	ctx, span := tracing.Start(ctx, "RefundService.UpdateFromGateway")
+	defer span.End()
+
+	refund, err := s.RefundRepository.GetRefundByGatewayID(ctx, gatewayRefundID)
+	if err != nil {
+		return ErrRefundNotFound
+	}
+	status = refundStatusFromGateway(status)
+	if err := s.RefundRepository.UpdateRefundStatus(ctx, refund.ID, status, failureReason); err != nil {
+		return err
+	}
+	if status == models.RefundStatusProcessed && refund.Status != models.RefundStatusProcessed {
+		// Counted on the first report only, as with failures below.
+		countRefund(refund)
+	}
+	if status == models.RefundStatusFailed {
+		if refund.Status != models.RefundStatusFailed {
+			// Webhooks are redelivered; only the first report of the failure releases the amount.
+			s.releaseRefund(ctx, refund)
+		}
+		return s.updatePaymentStatus(ctx, refund)
+	}
+	return nil
+}
+
+// updatePaymentStatus sets the payment status of the refund's order from all of the order's refunds, after
+// one of them has failed. The order is only refund_failed when none of its refunds went through.
+func (s *RefundService) updatePaymentStatus(ctx context.Context, refund *models.Refund) error {
+	order, err := s.OrderRepository.GetOrder(ctx, refund.OrderID)
+	if err != nil {
+		return err
+	}
+	refunds, err := s.RefundRepository.GetRefundsByOrder(ctx, refund.OrderID)
+	if err != nil {
+		return err
+	}
+	return s.OrderRepository.SetRefund(ctx, order.ID, refund.GatewayRefundID, paymentStatusAfterRefunds(order, refunds))
+}
+
+// paymentStatusAfterRefunds is the payment status of a paid order with the given refunds.
+func paymentStatusAfterRefunds(order *models.Order, refunds []models.Refund) string {
+	refunded, _ := refundedSoFar(refunds)
+	switch {
+	case refunded > 0 && roundRupees(order.TotalAmount-refunded) <= 0:
+		return models.PaymentStatusRefunded
+	case refunded > 0:
+		return models.PaymentStatusPartiallyRefunded
+	}
+	for _, refund := range refunds {
+		if refund.Status == models.RefundStatusFailed {
+			return models.PaymentStatusRefundFailed
+		}
+	}
+	return models.PaymentStatusPaid
+}
+
+// countRefund adds a refund that has just been processed to the refunds metric. Refunds recorded before
+// refunds had a method were paid through the gateway.
+func countRefund(refund *models.Refund) {
+	method := refund.Method
+	if method == "" {
+		method = models.RefundMethodGateway
+	}
+	metrics.Refunds.WithLabelValues(method).Add(refund.Amount)
+}
+
+// refundedSoFar totals the refunds that have not failed, and the quantity refunded per product.
+func refundedSoFar(refunds []models.Refund) (float64, map[string]int) {
+	total := 0.0
+	quantities := make(map[string]int)
+	for _, refund := range refunds {
+		if refund.Status == models.RefundStatusFailed {
+			continue
+		}
+		total += refund.Amount
+		for _, item := range refund.Items {
+			quantities[item.ProductID] += item.Quantity
+		}
+	}
+	return total, quantities
+}
+
+// onlineRefundable is how much of the order's gateway payment has not been refunded yet.
+func onlineRefundable(order *models.Order, refunds []models.Refund) float64 {
+	if order.PaymentID == "" {
+		return 0
+	}
+	refunded := 0.0
+	for _, refund := range refunds {
+		if refund.Status == models.RefundStatusFailed || (refund.Method != "" && refund.Method != models.RefundMethodGateway) {
+			continue
+		}
+		refunded += refund.Amount
+	}
+	return roundRupees(AmountDue(order) - refunded)
+}
+
+// lineItemAmount prices the requested items at the unit price they were ordered at, checking that each
+// item was ordered and has not already been refunded. It returns the total and the priced items.
+// giftCards holds the order's gift card products, which no discount applied to.
+func lineItemAmount(order *models.Order, items []models.CartItem, refundedQuantities map[string]int, giftCards map[string]bool) (float64, []models.CartItem, error) {
+	ordered := make(map[string]models.CartItem, len(order.Items))
+	for _, item := range order.Items {
+		ordered[item.ProductID] = item
+	}
+
+	amount := 0.0
+	undiscounted := 0.0
+	priced := make([]models.CartItem, len(items))
+	requested := make(map[string]int)
+	for i, item := range items {
+		line, ok := ordered[item.ProductID]
+		if !ok {
+			return 0, nil, fmt.Errorf("%w: product %s is not part of order %s", ErrInvalidRefund, item.ProductID, order.ID)
+		}
+		if line.Price <= 0 {
+			return 0, nil, fmt.Errorf("%w: order %s has no unit price for product %s, refund the full balance instead", ErrInvalidRefund, order.ID, item.ProductID)
+		}
+		requested[item.ProductID] += item.Quantity
+		if item.Quantity <= 0 || requested[item.ProductID]+refundedQuantities[item.ProductID] > line.Quantity {
+			return 0, nil, fmt.Errorf("%w: quantity for product %s exceeds the %d left to refund", ErrInvalidRefund, item.ProductID, line.Quantity-refundedQuantities[item.ProductID])
+		}
+		if giftCards[item.ProductID] {
+			undiscounted += line.Price * float64(item.Quantity)
+		} else {
+			amount += line.Price * float64(item.Quantity)
+		}
+		priced[i] = models.CartItem{ProductID: item.ProductID, Quantity: item.Quantity, Price: line.Price}
+	}
+	if discount := order.Discount + order.PointsDiscount; discount > 0 {
+		// A discount is spread over the items it applied to in proportion to their price.
+		discounted := order.TotalAmount
+		for _, line := range order.Items {
+			if giftCards[line.ProductID] {
+				discounted -= line.Price * float64(line.Quantity)
+			}
+		}
+		amount *= discounted / (discounted + discount)
+	}
+	return roundRupees(amount + undiscounted), priced, nil
+}
+
+// giftCardProducts returns the products the gift cards bought in an order were issued for.
+func giftCardProducts(cards []models.GiftCard) map[string]bool {
+	products := make(map[string]bool)
+	for _, card := range cards {
+		products[card.ProductID] = true
+	}
+	return products
+}
+
+// giftCardsToVoid picks the gift cards bought in an order that a refund takes back: every card not voided
+// yet for a refund of the whole balance, or one per refunded unit of a gift card line, unspent cards first.
+func giftCardsToVoid(cards []models.GiftCard, items []models.CartItem) ([]string, error) {
+	var codes []string
+	available := make(map[string][]models.GiftCard)
+	for _, card := range cards {
+		if card.VoidedAt != nil {
+			continue
+		}
+		if len(items) == 0 {
+			codes = append(codes, card.Code)
+		}
+		available[card.ProductID] = append(available[card.ProductID], card)
+	}
+	products := giftCardProducts(cards)
+	for _, item := range items {
+		if !products[item.ProductID] {
+			continue
+		}
+		candidates := available[item.ProductID]
+		if len(candidates) < item.Quantity {
+			return nil, fmt.Errorf("%w: only %d gift cards of product %s are left to take back", ErrInvalidRefund, len(candidates), item.ProductID)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Balance >= candidates[i].InitialBalance && candidates[j].Balance < candidates[j].InitialBalance
+		})
+		for _, card := range candidates[:item.Quantity] {
+			codes = append(codes, card.Code)
+		}
+		available[item.ProductID] = candidates[item.Quantity:]
+	}
+	return codes, nil
+}
+
+func refundStatusFromGateway(status string) string {
+	switch status {
+	case models.RefundStatusProcessed, models.RefundStatusFailed:
+		return status
+	default:
+		return models.RefundStatusPending
+	}
+}
+
+func roundRupees(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
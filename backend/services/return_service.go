@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+)
+
+var (
+	ErrReturnNotFound   = errors.New("return request not found")
+	ErrReturnNotAllowed = errors.New("only delivered orders can be returned")
+	ErrInvalidReturn    = errors.New("invalid return request")
+	ErrReturnNotPending = errors.New("return request has already been resolved")
+)
+
+const maxReturnPhotos = 5
+
+type ReturnServiceInterface interface {
+	RequestReturn(ctx context.Context, orderID string, request ReturnRequestInput) (*models.ReturnRequest, error)
+	ListReturns(ctx context.Context, status string) ([]models.ReturnRequest, error)
+	ApproveReturn(ctx context.Context, id string, decision ReturnDecision) (*models.ReturnRequest, error)
+	RejectReturn(ctx context.Context, id string, decision ReturnDecision) (*models.ReturnRequest, error)
+}
+
+// ReturnRequestInput is a customer's return request. Phone or Email must match the order.
+type ReturnRequestInput struct {
+	Phone     string            `json:"phone"`
+	Email     string            `json:"email"`
+	Items     []models.CartItem `json:"items" binding:"required"`
+	Reason    string            `json:"reason" binding:"required"`
+	PhotoURLs []string          `json:"photo_urls"`
+}
+
+// ReturnDecision is an admin's resolution of a return request. Refund controls whether approving the
+// return also refunds the returned items.
+type ReturnDecision struct {
+	Note   string `json:"note"`
+	Refund bool   `json:"refund"`
+}
+
+type ReturnService struct {
+	OrderRepository  repositories.OrderRepositoryInterface
+	ReturnRepository repositories.ReturnRepositoryInterface
+	Refunds          RefundServiceInterface
+}
+
+func (s *ReturnService) RequestReturn(ctx context.Context, orderID string, request ReturnRequestInput) (*models.ReturnRequest, error) {
+	ctx, span := tracing.Start(ctx, "ReturnService.RequestReturn")
+	defer span.End()
+
+	order, err := s.OrderRepository.GetOrder(ctx, orderID)
+	if err != nil || !matchesContact(order.CustomerInfo, request.Phone, request.Email) {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != models.OrderStatusDelivered {
+		return nil, ErrReturnNotAllowed
+	}
+	if err := validateReturn(order, request); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	returnRequest := models.ReturnRequest{
+		ID:        fmt.Sprintf("ret_%d", now.UnixNano()),
+		OrderID:   order.ID,
+		Items:     request.Items,
+		Reason:    request.Reason,
+		PhotoURLs: request.PhotoURLs,
+		Status:    models.ReturnStatusRequested,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.ReturnRepository.CreateReturn(ctx, returnRequest); err != nil {
+		return nil, err
+	}
+	return &returnRequest, nil
+}
+
+func (s *ReturnService) ListReturns(ctx context.Context, status string) ([]models.ReturnRequest, error) {
+	ctx, span := tracing.Start(ctx, "ReturnService.ListReturns")
+	defer span.End()
+
+	return s.ReturnRepository.ListReturns(ctx, status)
+}
+
+// ApproveReturn approves a pending return and, if asked to, refunds the returned items. The approval stands
+// even if the refund fails, so the error reports that the refund still has to be issued.
+func (s *ReturnService) ApproveReturn(ctx context.Context, id string, decision ReturnDecision) (*models.ReturnRequest, error) {
+	ctx, span := tracing.Start(ctx, "ReturnService.ApproveReturn")
+	defer span.End()
+
+	returnRequest, err := s.resolve(ctx, id, models.ReturnStatusApproved, decision.Note)
+	if err != nil || !decision.Refund {
+		return returnRequest, err
+	}
+
+	refund, err := s.Refunds.IssueRefund(ctx, returnRequest.OrderID, RefundRequest{
+		Items:    returnRequest.Items,
+		Reason:   "Return: " + returnRequest.Reason,
+		ReturnID: returnRequest.ID,
+	})
+	if refund != nil {
+		returnRequest.RefundID = refund.ID
+		if setErr := s.ReturnRepository.SetReturnRefund(ctx, id, refund.ID); setErr != nil && err == nil {
+			err = setErr
+		}
+	}
+	if err != nil {
+		return returnRequest, fmt.Errorf("return approved but refund failed, issue it from the order's refunds: %w", err)
+	}
+	return returnRequest, nil
+}
+
+func (s *ReturnService) RejectReturn(ctx context.Context, id string, decision ReturnDecision) (*models.ReturnRequest, error) {
+	ctx, span := tracing.Start(ctx, "ReturnService.RejectReturn")
+	defer span.End()
+
+	return s.resolve(ctx, id, models.ReturnStatusRejected, decision.Note)
+}
+
+func (s *ReturnService) resolve(ctx context.Context, id string, status string, note string) (*models.ReturnRequest, error) {
+	returnRequest, err := s.ReturnRepository.GetReturn(ctx, id)
+	if err != nil {
+		return nil, ErrReturnNotFound
+	}
+	if err := s.ReturnRepository.ResolveReturn(ctx, id, status, note); err != nil {
+		return nil, ErrReturnNotPending
+	}
+	returnRequest.Status = status
+	returnRequest.AdminNote = note
+	return returnRequest, nil
+}
+
+func validateReturn(order *models.Order, request ReturnRequestInput) error {
+	if len(request.Items) == 0 {
+		return fmt.Errorf("%w: at least one item is required", ErrInvalidReturn)
+	}
+	ordered := make(map[string]int, len(order.Items))
+	for _, item := range order.Items {
+		ordered[item.ProductID] += item.Quantity
+	}
+	for _, item := range request.Items {
+		if item.Quantity <= 0 || item.Quantity > ordered[item.ProductID] {
+			return fmt.Errorf("%w: product %s was not ordered in that quantity", ErrInvalidReturn, item.ProductID)
+		}
+	}
+
+	if len(request.PhotoURLs) > maxReturnPhotos {
+		return fmt.Errorf("%w: at most %d photos can be attached", ErrInvalidReturn, maxReturnPhotos)
+	}
+	for _, photoURL := range request.PhotoURLs {
+		u, err := url.Parse(photoURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %q is not an http(s) URL", ErrInvalidReturn, photoURL)
+		}
+	}
+	return nil
+}
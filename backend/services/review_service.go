@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrReviewNotFound      = errors.New("review not found")
+	ErrReviewNotAllowed    = errors.New("only products from delivered orders can be reviewed")
+	ErrDuplicateReview     = errors.New("this product has already been reviewed for the order")
+	ErrInvalidReview       = errors.New("invalid review")
+	ErrInvalidReviewQuery  = errors.New("invalid review query")
+	ErrInvalidModeration   = errors.New("reviews can only be approved, rejected or flagged")
+	ErrHelpfulVoteRequired = errors.New("log in to vote on reviews")
+)
+
+const (
+	defaultReviewPageSize = 10
+	maxReviewPageSize     = 50
+	reviewQueueLimit      = 200
+	maxReviewTitle        = 120
+	maxReviewText         = 2000
+)
+
+// Review sort orders for product pages.
+const (
+	ReviewSortHelpful = "helpful"
+	ReviewSortRecent  = "recent"
+)
+
+type ReviewServiceInterface interface {
+	SubmitReview(ctx context.Context, productID string, request ReviewInput) (*models.Review, error)
+	ListProductReviews(ctx context.Context, productID string, query ReviewListQuery) (*ReviewPage, error)
+	MarkHelpful(ctx context.Context, productID string, reviewID string, customerID string) (*models.Review, error)
+	ListReviews(ctx context.Context, status string) ([]models.Review, error)
+	ModerateReview(ctx context.Context, id string, status string, note string) (*models.Review, error)
+}
+
+// ReviewInput is a review of a product from one of the customer's orders. A logged-in customer's own
+// orders are recognised from CustomerID; otherwise Phone or Email must match the order.
+type ReviewInput struct {
+	OrderID    string `json:"order_id" binding:"required"`
+	Rating     int    `json:"rating" binding:"required"`
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	Phone      string `json:"phone"`
+	Email      string `json:"email"`
+	CustomerID string `json:"-"`
+}
+
+// ReviewListQuery is a page of a product's reviews. Sort is "helpful" (default) or "recent".
+type ReviewListQuery struct {
+	Sort     string `form:"sort"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+type ReviewPage struct {
+	Reviews  []models.Review `json:"reviews"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+}
+
+// ReviewService takes verified-purchase reviews, runs them through moderation and keeps each product's
+// rating summary in step with its approved reviews.
+type ReviewService struct {
+	Repository        repositories.ReviewRepositoryInterface
+	OrderRepository   repositories.OrderRepositoryInterface
+	ProductRepository repositories.ProductRepositoryInterface
+}
+
+// SubmitReview records a review for moderation. The order must be delivered and contain the product.
+func (s *ReviewService) SubmitReview(ctx context.Context, productID string, request ReviewInput) (*models.Review, error) {
+	ctx, span := tracing.Start(ctx, "ReviewService.SubmitReview")
+	defer span.End()
+
+	order, err := s.OrderRepository.GetOrder(ctx, request.OrderID)
+	if err != nil || !ownsOrder(order, request) {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != models.OrderStatusDelivered || !orderContains(order, productID) {
+		return nil, ErrReviewNotAllowed
+	}
+
+	request.Title = strings.TrimSpace(request.Title)
+	request.Text = strings.TrimSpace(request.Text)
+	if err := validateReview(request); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	review := models.Review{
+		ID:         fmt.Sprintf("rev_%d", now.UnixNano()),
+		ProductID:  productID,
+		OrderID:    order.ID,
+		CustomerID: order.CustomerID,
+		AuthorName: firstName(order.CustomerInfo.Name),
+		Rating:     request.Rating,
+		Title:      request.Title,
+		Text:       request.Text,
+		Status:     models.ReviewStatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.Repository.CreateReview(ctx, review); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateReview
+		}
+		return nil, err
+	}
+	return &review, nil
+}
+
+// ListProductReviews returns a page of a product's approved reviews.
+func (s *ReviewService) ListProductReviews(ctx context.Context, productID string, query ReviewListQuery) (*ReviewPage, error) {
+	ctx, span := tracing.Start(ctx, "ReviewService.ListProductReviews")
+	defer span.End()
+
+	filter := repositories.ReviewFilter{ProductID: productID, Status: []string{models.ReviewStatusApproved}}
+	switch query.Sort {
+	case "", ReviewSortHelpful:
+		filter.SortField = "helpful_count"
+	case ReviewSortRecent:
+		filter.SortField = "created_at"
+	default:
+		return nil, fmt.Errorf("%w: sort must be %q or %q", ErrInvalidReviewQuery, ReviewSortHelpful, ReviewSortRecent)
+	}
+
+	page, pageSize := query.Page, query.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultReviewPageSize
+	}
+	if pageSize > maxReviewPageSize {
+		pageSize = maxReviewPageSize
+	}
+	filter.Skip = int64((page - 1) * pageSize)
+	filter.Limit = int64(pageSize)
+
+	reviews, total, err := s.Repository.ListReviews(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &ReviewPage{Reviews: reviews, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// MarkHelpful records that a logged-in customer found an approved review helpful. Each customer counts
+// once per review.
+func (s *ReviewService) MarkHelpful(ctx context.Context, productID string, reviewID string, customerID string) (*models.Review, error) {
+	ctx, span := tracing.Start(ctx, "ReviewService.MarkHelpful")
+	defer span.End()
+
+	if customerID == "" {
+		return nil, ErrHelpfulVoteRequired
+	}
+	review, err := s.getReview(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.ProductID != productID || review.Status != models.ReviewStatusApproved {
+		return nil, ErrReviewNotFound
+	}
+	if err := s.Repository.AddHelpfulVote(ctx, reviewID, customerID); err != nil {
+		return nil, err
+	}
+	return s.getReview(ctx, reviewID)
+}
+
+// ListReviews returns reviews oldest first for moderation. Without a status it returns the queue of
+// pending and flagged reviews.
+func (s *ReviewService) ListReviews(ctx context.Context, status string) ([]models.Review, error) {
+	ctx, span := tracing.Start(ctx, "ReviewService.ListReviews")
+	defer span.End()
+
+	filter := repositories.ReviewFilter{
+		Status:        []string{models.ReviewStatusPending, models.ReviewStatusFlagged},
+		SortAscending: true,
+		Limit:         reviewQueueLimit,
+	}
+	if status != "" {
+		filter.Status = []string{status}
+	}
+	reviews, _, err := s.Repository.ListReviews(ctx, filter)
+	return reviews, err
+}
+
+// ModerateReview approves, rejects or flags a review and refreshes the product's rating. A decision can
+// be revisited, e.g. to flag a review that was approved earlier.
+func (s *ReviewService) ModerateReview(ctx context.Context, id string, status string, note string) (*models.Review, error) {
+	ctx, span := tracing.Start(ctx, "ReviewService.ModerateReview")
+	defer span.End()
+
+	switch status {
+	case models.ReviewStatusApproved, models.ReviewStatusRejected, models.ReviewStatusFlagged:
+	default:
+		return nil, ErrInvalidModeration
+	}
+
+	review, err := s.getReview(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repository.SetReviewStatus(ctx, id, status, note); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrReviewNotFound
+		}
+		return nil, err
+	}
+	wasApproved := review.Status == models.ReviewStatusApproved
+	review.Status = status
+	review.ModerationNote = note
+	review.UpdatedAt = time.Now()
+
+	if wasApproved || status == models.ReviewStatusApproved {
+		if err := s.refreshRating(ctx, review.ProductID); err != nil {
+			logging.FromContext(ctx).Error("Failed to update the rating of product", "product_id", review.ProductID, "error", err)
+		}
+	}
+	return review, nil
+}
+
+// refreshRating recomputes a product's rating from its approved reviews, rounded to one decimal place.
+func (s *ReviewService) refreshRating(ctx context.Context, productID string) error {
+	average, count, err := s.Repository.RatingSummary(ctx, productID)
+	if err != nil {
+		return err
+	}
+	return s.ProductRepository.SetRating(ctx, productID, math.Round(average*10)/10, count)
+}
+
+func (s *ReviewService) getReview(ctx context.Context, id string) (*models.Review, error) {
+	review, err := s.Repository.GetReview(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrReviewNotFound
+	}
+	return review, err
+}
+
+func ownsOrder(order *models.Order, request ReviewInput) bool {
+	if request.CustomerID != "" && order.CustomerID == request.CustomerID {
+		return true
+	}
+	return matchesContact(order.CustomerInfo, request.Phone, request.Email)
+}
+
+func orderContains(order *models.Order, productID string) bool {
+	for _, item := range order.Items {
+		if item.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+func validateReview(request ReviewInput) error {
+	if request.Rating < 1 || request.Rating > 5 {
+		return fmt.Errorf("%w: rating must be between 1 and 5", ErrInvalidReview)
+	}
+	if request.Text == "" {
+		return fmt.Errorf("%w: text is required", ErrInvalidReview)
+	}
+	if utf8.RuneCountInString(request.Title) > maxReviewTitle {
+		return fmt.Errorf("%w: title is longer than %d characters", ErrInvalidReview, maxReviewTitle)
+	}
+	if utf8.RuneCountInString(request.Text) > maxReviewText {
+		return fmt.Errorf("%w: text is longer than %d characters", ErrInvalidReview, maxReviewText)
+	}
+	return nil
+}
+
+// firstName is how a reviewer is shown publicly, so full names stay private.
+func firstName(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "Customer"
+	}
+	return fields[0]
+}
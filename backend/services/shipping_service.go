@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/shipping"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidShipment        = errors.New("invalid shipment")
+	ErrShipmentExists         = errors.New("order already has a shipment booked")
+	ErrNoShipment             = errors.New("order has no shipment booked")
+	ErrShipmentNotCancellable = errors.New("only shipments not yet collected by the courier can be cancelled")
+	ErrShippingUnsupported    = errors.New("not supported by the shipping provider")
+	ErrCourierFailed          = errors.New("shipping provider rejected the request")
+)
+
+// trackingBatch is how many shipments one tracking sweep polls. The least recently tracked go first, so
+// every shipment is polled in turn however many are out.
+const trackingBatch = 100
+
+// collectedStatuses are the tracking statuses that show the courier has the parcel, so the order has
+// shipped.
+var collectedStatuses = map[string]bool{
+	models.TrackingStatusPickedUp:       true,
+	models.TrackingStatusInTransit:      true,
+	models.TrackingStatusOutForDelivery: true,
+	models.TrackingStatusDelivered:      true,
+}
+
+type ShippingServiceInterface interface {
+	BookShipment(ctx context.Context, orderID string, request BookShipmentRequest) (*models.Order, error)
+	GetLabel(ctx context.Context, orderID string) (string, error)
+	CancelShipment(ctx context.Context, orderID string) (*models.Order, error)
+	HandleWebhook(ctx context.Context, body []byte, signature string) error
+	PollTracking(ctx context.Context) (int, error)
+}
+
+// BookShipmentRequest books a packed order with the courier. WeightGrams is the packed parcel's weight.
+// With the manual provider, staff book the parcel themselves and give the courier and tracking number.
+type BookShipmentRequest struct {
+	WeightGrams    int    `json:"weight_grams"`
+	Courier        string `json:"courier"`
+	TrackingNumber string `json:"tracking_number"`
+	TrackingURL    string `json:"tracking_url"`
+}
+
+// ShippingService books orders with the shipping provider and follows their tracking, moving orders to
+// shipped when the courier collects them and to delivered when they arrive.
+type ShippingService struct {
+	Orders   repositories.OrderRepositoryInterface
+	Provider shipping.Provider
+}
+
+func (s *ShippingService) BookShipment(ctx context.Context, orderID string, request BookShipmentRequest) (*models.Order, error) {
+	ctx, span := tracing.Start(ctx, "ShippingService.BookShipment")
+	defer span.End()
+
+	if request.WeightGrams < 0 {
+		return nil, fmt.Errorf("%w: weight_grams cannot be negative", ErrInvalidShipment)
+	}
+	if request.TrackingURL != "" {
+		u, err := url.Parse(request.TrackingURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: tracking_url must be an http(s) URL", ErrInvalidShipment)
+		}
+	}
+
+	order, err := s.getOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusPacked {
+		return nil, ErrOrderNotShippable
+	}
+	if booked(order) {
+		return nil, ErrShipmentExists
+	}
+
+	booking, err := s.Provider.CreateShipment(shipping.Parcel{
+		Order:          *order,
+		WeightGrams:    request.WeightGrams,
+		Courier:        request.Courier,
+		TrackingNumber: request.TrackingNumber,
+		TrackingURL:    request.TrackingURL,
+	})
+	if errors.Is(err, shipping.ErrInvalidParcel) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidShipment, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCourierFailed, err)
+	}
+
+	now := time.Now()
+	shipment := models.Shipment{
+		Provider:           s.Provider.Name(),
+		ProviderShipmentID: booking.ShipmentID,
+		Courier:            booking.Courier,
+		TrackingNumber:     booking.AWB,
+		TrackingURL:        booking.TrackingURL,
+		Status:             models.TrackingStatusBooked,
+		Events: []models.TrackingEvent{{
+			Status:      models.TrackingStatusBooked,
+			Description: fmt.Sprintf("Booked with %s, AWB %s", booking.Courier, booking.AWB),
+			OccurredAt:  now,
+		}},
+		BookedAt: &now,
+	}
+	// The label can be fetched again later, so failing to get it now does not undo the booking.
+	if booking.ShipmentID != "" {
+		label, err := s.Provider.Label(booking.ShipmentID)
+		if err != nil && !errors.Is(err, shipping.ErrNotSupported) {
+			logging.FromContext(ctx).Error("Failed to fetch shipping label", "order_id", order.ID, "error", err)
+		}
+		shipment.LabelURL = label
+	}
+	if err := s.Orders.SetShipment(ctx, order.ID, shipment); err != nil {
+		return nil, err
+	}
+	order.Shipment = &shipment
+	return order, nil
+}
+
+// GetLabel returns the URL of the shipping label for an order's booked shipment, generating it if it was
+// not fetched when the shipment was booked.
+func (s *ShippingService) GetLabel(ctx context.Context, orderID string) (string, error) {
+	ctx, span := tracing.Start(ctx, "ShippingService.GetLabel")
+	defer span.End()
+
+	order, err := s.getOrder(ctx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if !booked(order) {
+		return "", ErrNoShipment
+	}
+	shipment := *order.Shipment
+	if shipment.LabelURL != "" {
+		return shipment.LabelURL, nil
+	}
+	if shipment.Provider != s.Provider.Name() || shipment.ProviderShipmentID == "" {
+		return "", fmt.Errorf("%w: the shipment was booked outside the shop and has no label", ErrShippingUnsupported)
+	}
+
+	label, err := s.Provider.Label(shipment.ProviderShipmentID)
+	if errors.Is(err, shipping.ErrNotSupported) {
+		return "", ErrShippingUnsupported
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrCourierFailed, err)
+	}
+	shipment.LabelURL = label
+	if err := s.Orders.SetShipment(ctx, order.ID, shipment); err != nil {
+		return "", err
+	}
+	return label, nil
+}
+
+// CancelShipment cancels a booking the courier has not collected yet, so the order can be booked again.
+func (s *ShippingService) CancelShipment(ctx context.Context, orderID string) (*models.Order, error) {
+	ctx, span := tracing.Start(ctx, "ShippingService.CancelShipment")
+	defer span.End()
+
+	order, err := s.getOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !booked(order) {
+		return nil, ErrNoShipment
+	}
+	shipment := *order.Shipment
+	if order.Status != models.OrderStatusPacked || collectedStatuses[shipment.Status] {
+		return nil, ErrShipmentNotCancellable
+	}
+
+	if shipment.Provider == s.Provider.Name() && shipment.ProviderShipmentID != "" {
+		if err := s.Provider.Cancel(shipment.ProviderShipmentID); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCourierFailed, err)
+		}
+	}
+	shipment.Status = models.TrackingStatusCancelled
+	shipment.Events = append(shipment.Events, models.TrackingEvent{
+		Status:      models.TrackingStatusCancelled,
+		Description: "Shipment cancelled",
+		OccurredAt:  time.Now(),
+	})
+	if err := s.Orders.SetShipment(ctx, order.ID, shipment); err != nil {
+		return nil, err
+	}
+	order.Shipment = &shipment
+	return order, nil
+}
+
+// HandleWebhook applies tracking events pushed by the shipping provider. Events for parcels the shop does
+// not know are ignored so the provider does not keep retrying them.
+func (s *ShippingService) HandleWebhook(ctx context.Context, body []byte, signature string) error {
+	ctx, span := tracing.Start(ctx, "ShippingService.HandleWebhook")
+	defer span.End()
+
+	if !s.Provider.VerifyWebhookSignature(body, signature) {
+		return ErrInvalidWebhookSignature
+	}
+	update, err := s.Provider.ParseTrackingWebhook(body)
+	if err != nil {
+		return err
+	}
+
+	order, err := s.Orders.GetOrderByTrackingNumber(ctx, update.AWB)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		logging.FromContext(ctx).Warn("Ignoring tracking update for unknown AWB", "awb", update.AWB)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.track(ctx, order, update.Events)
+}
+
+// PollTracking fetches tracking for shipments booked with the provider that are not yet delivered, for
+// providers whose webhooks can be missed. It returns how many shipments were checked.
+func (s *ShippingService) PollTracking(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "ShippingService.PollTracking")
+	defer span.End()
+
+	orders, err := s.Orders.ListTrackedShipments(ctx, s.Provider.Name(), trackingBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	checked := 0
+	var failed []string
+	for i := range orders {
+		order := &orders[i]
+		events, err := s.Provider.Track(order.Shipment.TrackingNumber)
+		if errors.Is(err, shipping.ErrNotSupported) {
+			return checked, nil
+		}
+		if err == nil {
+			err = s.track(ctx, order, events)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to track shipment", "order_id", order.ID, "error", err)
+			failed = append(failed, order.ID)
+			continue
+		}
+		checked++
+	}
+	if len(failed) > 0 {
+		return checked, fmt.Errorf("%d shipments could not be tracked: %v", len(failed), failed)
+	}
+	return checked, nil
+}
+
+// track records the events the order's shipment does not have yet and advances the order: to shipped once
+// the courier has collected the parcel, and to delivered once it arrives. Orders cancelled or changed by
+// staff in the meantime are left alone.
+func (s *ShippingService) track(ctx context.Context, order *models.Order, events []models.TrackingEvent) error {
+	if order.Shipment == nil {
+		return ErrNoShipment
+	}
+	known := make(map[string]bool, len(order.Shipment.Events))
+	for _, event := range order.Shipment.Events {
+		known[eventKey(event)] = true
+	}
+	fresh := []models.TrackingEvent{}
+	for _, event := range events {
+		if event.OccurredAt.IsZero() {
+			event.OccurredAt = time.Now()
+		}
+		// Mongo stores times to the millisecond, so compare and store them that way.
+		event.OccurredAt = event.OccurredAt.UTC().Truncate(time.Millisecond)
+		if key := eventKey(event); !known[key] {
+			known[key] = true
+			fresh = append(fresh, event)
+		}
+	}
+
+	all := append(append([]models.TrackingEvent{}, order.Shipment.Events...), fresh...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].OccurredAt.Before(all[j].OccurredAt) })
+	status := order.Shipment.Status
+	var shippedAt *time.Time
+	for _, event := range all {
+		status = event.Status
+		if collectedStatuses[event.Status] && shippedAt == nil {
+			at := event.OccurredAt
+			shippedAt = &at
+		}
+	}
+	if err := s.Orders.AddTrackingEvents(ctx, order.ID, status, fresh, shippedAt); err != nil {
+		return err
+	}
+	order.Shipment.Events = all
+	order.Shipment.Status = status
+	if shippedAt != nil && order.Shipment.ShippedAt == nil {
+		order.Shipment.ShippedAt = shippedAt
+	}
+
+	if collectedStatuses[status] && order.Status == models.OrderStatusPacked {
+		if err := s.advance(ctx, order, models.OrderStatusShipped, "Collected by the courier"); err != nil {
+			return err
+		}
+	}
+	if status == models.TrackingStatusDelivered && order.Status == models.OrderStatusShipped {
+		if err := s.advance(ctx, order, models.OrderStatusDelivered, "Delivered by the courier"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advance moves the order to a fulfilment status and notifies the customer, unless the order has moved on
+// since it was read.
+func (s *ShippingService) advance(ctx context.Context, order *models.Order, status string, reason string) error {
+	change := models.StatusChange{Status: status, Reason: reason, ChangedAt: time.Now()}
+	updated, err := s.Orders.TransitionStatus(ctx, order.ID, fulfilmentTransitions[status], change, notifyOn(fulfilmentEvents[status], order.ID, 0))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	updated.Shipment = order.Shipment
+	*order = *updated
+	return nil
+}
+
+func (s *ShippingService) getOrder(ctx context.Context, id string) (*models.Order, error) {
+	order, err := s.Orders.GetOrder(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// booked reports whether the order has a shipment that has not been cancelled.
+func booked(order *models.Order) bool {
+	return order.Shipment != nil && order.Shipment.TrackingNumber != "" &&
+		order.Shipment.Status != models.TrackingStatusCancelled
+}
+
+// eventKey identifies a tracking event, as providers resend events they have already sent.
+func eventKey(event models.TrackingEvent) string {
+	return event.Status + "|" + event.Location + "|" + event.OccurredAt.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/messaging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrProductInStock       = errors.New("product is in stock")
+	ErrInvalidSubscription  = errors.New("invalid back in stock subscription")
+	ErrPhoneNotSubscribable = errors.New("phone number has not opted in to messages; verify it with /api/messaging/opt-in or give an email address")
+)
+
+type StockSubscriptionServiceInterface interface {
+	Subscribe(ctx context.Context, productID string, request NotifyMeRequest) (*models.StockSubscription, error)
+}
+
+// NotifyMeRequest asks to be told when a product is back in stock. At least one of Email and Phone is
+// required.
+type NotifyMeRequest struct {
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// StockSubscriptionService records back in stock requests for out-of-stock products, from guests as well
+// as customers, and sends the alerts when stock returns.
+type StockSubscriptionService struct {
+	Repository  repositories.StockSubscriptionRepositoryInterface
+	Products    repositories.ProductRepositoryInterface
+	Preferences repositories.PreferenceRepositoryInterface
+	Notifiers   []BackInStockNotifier
+	ShopURL     string
+}
+
+func (s *StockSubscriptionService) Subscribe(ctx context.Context, productID string, request NotifyMeRequest) (*models.StockSubscription, error) {
+	ctx, span := tracing.Start(ctx, "StockSubscriptionService.Subscribe")
+	defer span.End()
+
+	product, err := s.Products.GetProduct(ctx, productID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if product.InStock && product.Stock > 0 {
+		return nil, ErrProductInStock
+	}
+
+	subscription, err := s.contact(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	subscription.ID = fmt.Sprintf("bis_%d", now.UnixNano())
+	subscription.ProductID = productID
+	subscription.CreatedAt = now
+	return s.Repository.Subscribe(ctx, *subscription)
+}
+
+// contact validates and normalises the request's contact details. A phone number alone is only accepted
+// if it has opted in to messages, since it could not be reached otherwise.
+func (s *StockSubscriptionService) contact(ctx context.Context, request NotifyMeRequest) (*models.StockSubscription, error) {
+	subscription := &models.StockSubscription{}
+	if email := strings.TrimSpace(request.Email); email != "" {
+		address, err := mail.ParseAddress(email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid email address", ErrInvalidSubscription)
+		}
+		subscription.Email = strings.ToLower(address.Address)
+	}
+	if strings.TrimSpace(request.Phone) != "" {
+		phone, ok := messaging.E164(request.Phone)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid phone number", ErrInvalidSubscription)
+		}
+		subscription.Phone = phone
+	}
+
+	switch {
+	case subscription.Email == "" && subscription.Phone == "":
+		return nil, fmt.Errorf("%w: email or phone is required", ErrInvalidSubscription)
+	case subscription.Email == "":
+		preference, err := s.Preferences.GetPreference(ctx, subscription.Phone)
+		if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !preference.OptedIn) {
+			return nil, ErrPhoneNotSubscribable
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return subscription, nil
+}
+
+// AlertRestocked alerts up to limit subscribers of a product, oldest first, and removes their
+// subscriptions. Each subscription is removed before its alert is sent, and skipped if another restock job
+// got to it first. It returns how many were reached and whether more are waiting. Nothing is sent if the
+// product has run out again, so the remaining subscribers wait for the next restock.
+func (s *StockSubscriptionService) AlertRestocked(ctx context.Context, productID string, limit int) (int, bool, error) {
+	ctx, span := tracing.Start(ctx, "StockSubscriptionService.AlertRestocked")
+	defer span.End()
+
+	product, err := restockedProduct(ctx, s.Products, productID)
+	if product == nil || err != nil {
+		return 0, false, err
+	}
+
+	subscriptions, err := s.Repository.ListSubscribers(ctx, productID, int64(limit+1))
+	if err != nil {
+		return 0, false, err
+	}
+	more := len(subscriptions) > limit
+	if more {
+		subscriptions = subscriptions[:limit]
+	}
+
+	link := productLink(s.ShopURL, productID)
+	sent := 0
+	for _, subscription := range subscriptions {
+		claimed, err := s.Repository.ClaimSubscription(ctx, subscription.ID)
+		if err != nil {
+			return sent, more, err
+		}
+		if !claimed {
+			continue
+		}
+		contact := models.CustomerInfo{Email: subscription.Email, Phone: subscription.Phone}
+		if notifyBackInStock(ctx, s.Notifiers, contact, *product, link) {
+			sent++
+		}
+	}
+	return sent, more, nil
+}
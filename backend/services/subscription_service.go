@@ -0,0 +1,570 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrPlanNotFound          = errors.New("subscription plan not found")
+	ErrInvalidPlan           = errors.New("invalid subscription plan")
+	ErrSubscriptionNotFound  = errors.New("subscription not found")
+	ErrInvalidSubscriber     = errors.New("invalid subscription details")
+	ErrSubscriptionNotActive = errors.New("subscription cannot be changed in its current status")
+)
+
+// subscriptionBatch is how many subscriptions one scheduler run places orders for at most; the rest are
+// placed by the next run.
+const subscriptionBatch = 100
+
+// cancellableSubscriptionStatuses are the statuses a customer can cancel a subscription from.
+var cancellableSubscriptionStatuses = []string{
+	models.SubscriptionStatusPending,
+	models.SubscriptionStatusActive,
+	models.SubscriptionStatusPaused,
+	models.SubscriptionStatusHalted,
+}
+
+// SubscriptionOrderExpirer expires the orders of a subscription that are still waiting for a charge.
+type SubscriptionOrderExpirer interface {
+	ExpireSubscriptionOrders(ctx context.Context, subscriptionID string, reason string) (int, error)
+}
+
+// PaymentConfirmer confirms an order once its payment has been recorded.
+type PaymentConfirmer interface {
+	ConfirmPayment(ctx context.Context, order *models.Order) error
+}
+
+type SubscriptionServiceInterface interface {
+	CreatePlan(ctx context.Context, request PlanRequest) (*models.SubscriptionPlan, error)
+	ListPlans(ctx context.Context) ([]models.SubscriptionPlan, error)
+	Subscribe(ctx context.Context, customerID string, request SubscribeRequest) (*models.Subscription, error)
+	ListSubscriptions(ctx context.Context, customerID string) ([]models.Subscription, error)
+	PauseSubscription(ctx context.Context, customerID string, id string) (*models.Subscription, error)
+	ResumeSubscription(ctx context.Context, customerID string, id string) (*models.Subscription, error)
+	SkipCycle(ctx context.Context, customerID string, id string) (*models.Subscription, error)
+	CancelSubscription(ctx context.Context, customerID string, id string, reason string) (*models.Subscription, error)
+}
+
+// PlanRequest creates a subscription plan. Items are priced from the catalog.
+type PlanRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Description string            `json:"description"`
+	Items       []models.CartItem `json:"items" binding:"required"`
+	Frequency   string            `json:"frequency" binding:"required"`
+}
+
+// SubscribeRequest subscribes a customer to a plan, delivered to CustomerInfo.
+type SubscribeRequest struct {
+	PlanID       string              `json:"plan_id" binding:"required"`
+	CustomerInfo models.CustomerInfo `json:"customer_info"`
+}
+
+// SubscriptionService runs recurring orders. The gateway charges each subscription on the plan's schedule
+// and the scheduler places an order for every cycle through Orders; each charge pays the subscription's
+// oldest unpaid order, or is kept for the next order if it arrives first.
+type SubscriptionService struct {
+	Repository      repositories.SubscriptionRepositoryInterface
+	Plans           repositories.SubscriptionPlanRepositoryInterface
+	Products        repositories.ProductRepositoryInterface
+	Orders          OrderServiceInterface
+	OrderRepository repositories.OrderRepositoryInterface
+	Payments        PaymentConfirmer
+	Gateway         SubscriptionGateway
+	UnpaidOrders    SubscriptionOrderExpirer // expires the orders no charge will pay once a subscription halts or ends
+}
+
+// CreatePlan prices the plan's items from the catalog and registers it with the gateway.
+func (s *SubscriptionService) CreatePlan(ctx context.Context, request PlanRequest) (*models.SubscriptionPlan, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.CreatePlan")
+	defer span.End()
+
+	if _, err := nextCycle(time.Now(), request.Frequency); err != nil {
+		return nil, err
+	}
+	if len(request.Items) == 0 {
+		return nil, fmt.Errorf("%w: a plan needs at least one item", ErrInvalidPlan)
+	}
+
+	items := make([]models.CartItem, len(request.Items))
+	amount := 0.0
+	for i, item := range request.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: invalid quantity for product %s", ErrInvalidPlan, item.ProductID)
+		}
+		product, err := s.Products.GetProduct(ctx, item.ProductID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%w: product %s not found", ErrInvalidPlan, item.ProductID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items[i] = models.CartItem{ProductID: item.ProductID, Quantity: item.Quantity, Price: product.Price}
+		amount += product.Price * float64(item.Quantity)
+	}
+	amount = roundRupees(amount)
+
+	gatewayPlanID, err := s.Gateway.CreatePlan(ctx, request.Name, request.Frequency, toPaise(amount))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	plan := models.SubscriptionPlan{
+		ID:            fmt.Sprintf("plan_%d", now.UnixNano()),
+		Name:          strings.TrimSpace(request.Name),
+		Description:   strings.TrimSpace(request.Description),
+		Items:         items,
+		Frequency:     request.Frequency,
+		Amount:        amount,
+		GatewayPlanID: gatewayPlanID,
+		Active:        true,
+		CreatedAt:     now,
+	}
+	if err := s.Plans.CreatePlan(ctx, plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ListPlans returns the plans customers can subscribe to.
+func (s *SubscriptionService) ListPlans(ctx context.Context) ([]models.SubscriptionPlan, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.ListPlans")
+	defer span.End()
+
+	return s.Plans.ListPlans(ctx, true)
+}
+
+// Subscribe creates the subscription with the gateway. It stays pending until the customer authorises the
+// payment at its PaymentURL and the gateway activates it.
+func (s *SubscriptionService) Subscribe(ctx context.Context, customerID string, request SubscribeRequest) (*models.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.Subscribe")
+	defer span.End()
+
+	plan, err := s.Plans.GetPlan(ctx, request.PlanID)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !plan.Active) {
+		return nil, ErrPlanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := request.CustomerInfo
+	if strings.TrimSpace(info.Name) == "" || strings.TrimSpace(info.Phone) == "" || strings.TrimSpace(info.Address) == "" {
+		return nil, fmt.Errorf("%w: name, phone and address are required", ErrInvalidSubscriber)
+	}
+	if err := checkCustomerGSTIN(&info); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSubscriber, err)
+	}
+
+	now := time.Now()
+	id := fmt.Sprintf("sub_%d", now.UnixNano())
+	gatewaySubscription, err := s.Gateway.CreateSubscription(ctx, plan.GatewayPlanID, plan.Frequency, map[string]string{"subscription_id": id})
+	if err != nil {
+		return nil, err
+	}
+	subscription := models.Subscription{
+		ID:                    id,
+		PlanID:                plan.ID,
+		PlanName:              plan.Name,
+		CustomerID:            customerID,
+		CustomerInfo:          info,
+		Items:                 plan.Items,
+		Frequency:             plan.Frequency,
+		Amount:                plan.Amount,
+		Status:                models.SubscriptionStatusPending,
+		GatewaySubscriptionID: gatewaySubscription.ID,
+		PaymentURL:            gatewaySubscription.ShortURL,
+		History:               []models.StatusChange{{Status: models.SubscriptionStatusPending, ChangedAt: now}},
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+	if err := s.Repository.CreateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (s *SubscriptionService) ListSubscriptions(ctx context.Context, customerID string) ([]models.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.ListSubscriptions")
+	defer span.End()
+
+	return s.Repository.ListSubscriptions(ctx, customerID)
+}
+
+// PauseSubscription stops orders and charges until the subscription is resumed.
+func (s *SubscriptionService) PauseSubscription(ctx context.Context, customerID string, id string) (*models.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.PauseSubscription")
+	defer span.End()
+
+	subscription, err := s.customerSubscription(ctx, customerID, id, models.SubscriptionStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Gateway.PauseSubscription(ctx, subscription.GatewaySubscriptionID); err != nil {
+		return nil, err
+	}
+	change := models.StatusChange{Status: models.SubscriptionStatusPaused, Reason: "paused by customer", ChangedAt: time.Now()}
+	return s.transition(ctx, id, []string{models.SubscriptionStatusActive}, change, nil)
+}
+
+// ResumeSubscription restarts a paused subscription on its original schedule, from the first cycle that is
+// still to come.
+func (s *SubscriptionService) ResumeSubscription(ctx context.Context, customerID string, id string) (*models.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.ResumeSubscription")
+	defer span.End()
+
+	subscription, err := s.customerSubscription(ctx, customerID, id, models.SubscriptionStatusPaused)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Gateway.ResumeSubscription(ctx, subscription.GatewaySubscriptionID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	next, err := upcomingCycle(*subscription.NextOrderAt, subscription.Frequency, now)
+	if err != nil {
+		return nil, err
+	}
+	change := models.StatusChange{Status: models.SubscriptionStatusActive, Reason: "resumed by customer", ChangedAt: now}
+	return s.transition(ctx, id, []string{models.SubscriptionStatusPaused}, change, &next)
+}
+
+// SkipCycle skips the next order. The gateway still charges for the cycle, so that charge is refunded when
+// it arrives.
+func (s *SubscriptionService) SkipCycle(ctx context.Context, customerID string, id string) (*models.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.SkipCycle")
+	defer span.End()
+
+	subscription, err := s.customerSubscription(ctx, customerID, id, models.SubscriptionStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	dueAt := *subscription.NextOrderAt
+	next, err := nextCycle(dueAt, subscription.Frequency)
+	if err != nil {
+		return nil, err
+	}
+	change := models.StatusChange{
+		Status:    models.SubscriptionCycleSkipped,
+		Reason:    "order of " + dueAt.In(shopLocation).Format("2006-01-02") + " skipped by customer",
+		ChangedAt: time.Now(),
+	}
+	skipped, err := s.Repository.SkipCycle(ctx, id, dueAt, next, change)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// The order was placed, or the subscription changed, in the meantime.
+		return nil, ErrSubscriptionNotActive
+	}
+	return skipped, err
+}
+
+// CancelSubscription ends the subscription with the gateway. Orders already paid are not affected and
+// unpaid ones are expired; charges that were not used for an order are refunded.
+func (s *SubscriptionService) CancelSubscription(ctx context.Context, customerID string, id string, reason string) (*models.Subscription, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.CancelSubscription")
+	defer span.End()
+
+	subscription, err := s.customerSubscription(ctx, customerID, id, cancellableSubscriptionStatuses...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Gateway.CancelSubscription(ctx, subscription.GatewaySubscriptionID); err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		reason = "cancelled by customer"
+	}
+	change := models.StatusChange{Status: models.SubscriptionStatusCancelled, Reason: reason, ChangedAt: time.Now()}
+	cancelled, err := s.transition(ctx, id, cancellableSubscriptionStatuses, change, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.expireOrders(ctx, cancelled.ID, "subscription cancelled"); err != nil {
+		logging.FromContext(ctx).Error("Failed to expire unpaid orders of subscription", "subscription_id", cancelled.ID, "error", err)
+	}
+	s.refundCredits(ctx, cancelled)
+	return cancelled, nil
+}
+
+// PlaceDueOrders places the order for every active subscription whose cycle is due. A subscription that
+// fell behind, e.g. because the scheduler was down, gets one order and moves on to its next future cycle.
+func (s *SubscriptionService) PlaceDueOrders(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.PlaceDueOrders")
+	defer span.End()
+
+	now := time.Now()
+	subscriptions, err := s.Repository.ListDue(ctx, now, subscriptionBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	placed := 0
+	var failed []string
+	for _, subscription := range subscriptions {
+		ok, err := s.placeCycle(ctx, subscription, now)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to place the order for subscription", "subscription_id", subscription.ID, "error", err)
+			failed = append(failed, subscription.ID)
+			continue
+		}
+		if ok {
+			placed++
+		}
+	}
+	if len(failed) > 0 {
+		return placed, fmt.Errorf("%d subscriptions could not be processed: %v", len(failed), failed)
+	}
+	return placed, nil
+}
+
+// placeCycle claims the subscription's due cycle and places its order, reporting whether an order was
+// placed. The cycle is claimed first so a retry never orders it twice; an order that cannot be placed, e.g.
+// because a product is out of stock, is recorded as a skipped cycle and its charge refunded.
+func (s *SubscriptionService) placeCycle(ctx context.Context, subscription models.Subscription, now time.Time) (bool, error) {
+	dueAt := *subscription.NextOrderAt
+	next, err := upcomingCycle(dueAt, subscription.Frequency, now)
+	if err != nil {
+		return false, err
+	}
+	err = s.Repository.AdvanceCycle(ctx, subscription.ID, dueAt, next)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	order, err := s.Orders.CreateOrder(ctx, CreateOrderRequest{
+		CustomerInfo:   subscription.CustomerInfo,
+		Items:          subscription.Items,
+		Notes:          "Subscription: " + subscription.PlanName,
+		CustomerID:     subscription.CustomerID,
+		SubscriptionID: subscription.ID,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("Skipping the order of subscription", "subscription_id", subscription.ID, "error", err)
+		change := models.StatusChange{Status: models.SubscriptionCycleSkipped, Reason: "order could not be placed: " + err.Error(), ChangedAt: now}
+		return false, s.Repository.RecordSkip(ctx, subscription.ID, change)
+	}
+
+	// Pay the order with a charge that arrived before it, if there is one.
+	credit, err := s.Repository.TakeCredit(ctx, subscription.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return true, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	_, err = s.payOrder(ctx, subscription.ID, credit.PaymentID, credit.Method, order.ID)
+	return true, err
+}
+
+// HandleSubscriptionEvent applies a gateway webhook for a subscription. Events for unknown subscriptions
+// are ignored so the gateway does not keep retrying them.
+func (s *SubscriptionService) HandleSubscriptionEvent(ctx context.Context, event string, gatewaySubscriptionID string, payment GatewayPayment) error {
+	ctx, span := tracing.Start(ctx, "SubscriptionService.HandleSubscriptionEvent")
+	defer span.End()
+
+	subscription, err := s.Repository.GetByGatewayID(ctx, gatewaySubscriptionID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		logging.FromContext(ctx).Warn("Ignoring event for unknown subscription", "event", event, "gateway_subscription_id", gatewaySubscriptionID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch event {
+	case "subscription.activated":
+		from := []string{models.SubscriptionStatusPending, models.SubscriptionStatusHalted}
+		change := models.StatusChange{Status: models.SubscriptionStatusActive, Reason: "payment authorised", ChangedAt: now}
+		next := now
+		if subscription.NextOrderAt != nil {
+			if next, err = upcomingCycle(*subscription.NextOrderAt, subscription.Frequency, now); err != nil {
+				return err
+			}
+		}
+		_, err = s.Repository.TransitionStatus(ctx, subscription.ID, from, change, &next)
+	case "subscription.halted":
+		from := []string{models.SubscriptionStatusActive, models.SubscriptionStatusPaused}
+		change := models.StatusChange{Status: models.SubscriptionStatusHalted, Reason: "payment failed", ChangedAt: now}
+		_, err = s.Repository.TransitionStatus(ctx, subscription.ID, from, change, nil)
+		if err == nil || (errors.Is(err, mongo.ErrNoDocuments) && subscription.Status == models.SubscriptionStatusHalted) {
+			// Also on a redelivered event, in case the orders could not be expired the first time.
+			return s.expireOrders(ctx, subscription.ID, "subscription payment failed")
+		}
+	case "subscription.cancelled", "subscription.completed":
+		change := models.StatusChange{Status: models.SubscriptionStatusCancelled, Reason: strings.TrimPrefix(event, "subscription.") + " by the payment gateway", ChangedAt: now}
+		var cancelled *models.Subscription
+		if cancelled, err = s.Repository.TransitionStatus(ctx, subscription.ID, cancellableSubscriptionStatuses, change, nil); err == nil {
+			s.refundCredits(ctx, cancelled)
+		}
+		if err == nil || (errors.Is(err, mongo.ErrNoDocuments) && subscription.Status == models.SubscriptionStatusCancelled) {
+			return s.expireOrders(ctx, subscription.ID, "subscription "+strings.TrimPrefix(event, "subscription."))
+		}
+	case "subscription.charged":
+		return s.recordCharge(ctx, subscription, payment, now)
+	default:
+		return nil
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Already in that status, or an event that arrived out of order.
+		return nil
+	}
+	return err
+}
+
+// recordCharge pays the subscription's oldest unpaid order with a gateway charge. A charge for a skipped
+// cycle is refunded, and any other charge is kept to pay the next order.
+func (s *SubscriptionService) recordCharge(ctx context.Context, subscription *models.Subscription, payment GatewayPayment, now time.Time) error {
+	err := s.Repository.ClaimCharge(ctx, subscription.ID, payment.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		logging.FromContext(ctx).Info("Ignoring already recorded charge of subscription", "payment_id", payment.ID, "subscription_id", subscription.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	recorded, err := s.applyCharge(ctx, subscription, payment, now)
+	if err == nil {
+		return nil
+	}
+	if recorded {
+		// The charge is on an order already, so a retried webhook must not apply it again.
+		logging.FromContext(ctx).Error("Charge of subscription could not be applied and needs a manual check", "payment_id", payment.ID, "subscription_id", subscription.ID, "error", err)
+		return err
+	}
+	// Nothing was recorded, so let the gateway's retry apply the charge.
+	if releaseErr := s.Repository.ReleaseCharge(ctx, subscription.ID, payment.ID); releaseErr != nil {
+		logging.FromContext(ctx).Error("Charge of subscription could not be applied and needs a manual check", "payment_id", payment.ID, "subscription_id", subscription.ID, "error", releaseErr)
+	}
+	return err
+}
+
+// applyCharge applies a claimed charge, reporting whether it was recorded against an order, a skipped cycle
+// or the subscription's credits before any error.
+func (s *SubscriptionService) applyCharge(ctx context.Context, subscription *models.Subscription, payment GatewayPayment, now time.Time) (bool, error) {
+	recorded, err := s.payOrder(ctx, subscription.ID, payment.ID, payment.Method, "")
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return recorded, err
+	}
+	err = s.Repository.TakeSkippedCharge(ctx, subscription.ID)
+	if err == nil {
+		_, err := s.Gateway.Refund(ctx, payment.ID, payment.Amount, map[string]string{"reason": "subscription cycle skipped"})
+		if err != nil {
+			if returnErr := s.Repository.ReturnSkippedCharge(ctx, subscription.ID); returnErr != nil {
+				logging.FromContext(ctx).Error("Failed to return skipped charge of subscription", "subscription_id", subscription.ID, "error", returnErr)
+				return true, err
+			}
+		}
+		return false, err
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return false, err
+	}
+	err = s.Repository.AddCredit(ctx, subscription.ID, models.SubscriptionPayment{
+		PaymentID: payment.ID,
+		Method:    payment.Method,
+		Amount:    payment.Amount,
+		ChargedAt: now,
+	})
+	return false, err
+}
+
+// payOrder records a charge against the subscription's oldest unpaid order and confirms it, reporting
+// whether the charge was recorded. It returns mongo.ErrNoDocuments if no order is waiting for payment;
+// orderID is only used for logging.
+func (s *SubscriptionService) payOrder(ctx context.Context, subscriptionID string, paymentID string, method string, orderID string) (bool, error) {
+	order, err := s.OrderRepository.RecordSubscriptionPayment(ctx, subscriptionID, paymentID, method)
+	if errors.Is(err, mongo.ErrNoDocuments) && orderID != "" {
+		logging.FromContext(ctx).Warn("Order of subscription was not waiting for payment", "order_id", orderID, "subscription_id", subscriptionID, "payment_id", paymentID)
+	}
+	if err != nil {
+		return false, err
+	}
+	countPayment(method)
+	order.PaymentMethod = method
+	return true, s.Payments.ConfirmPayment(ctx, order)
+}
+
+// expireOrders expires the subscription's orders that are waiting for a charge that will not come.
+func (s *SubscriptionService) expireOrders(ctx context.Context, subscriptionID string, reason string) error {
+	if s.UnpaidOrders == nil {
+		return nil
+	}
+	_, err := s.UnpaidOrders.ExpireSubscriptionOrders(ctx, subscriptionID, reason)
+	return err
+}
+
+// refundCredits refunds the charges of a cancelled subscription that no order was paid with.
+func (s *SubscriptionService) refundCredits(ctx context.Context, subscription *models.Subscription) {
+	credits, err := s.Repository.ClearCredits(ctx, subscription.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to clear unused charges of subscription", "subscription_id", subscription.ID, "error", err)
+		return
+	}
+	for _, credit := range credits {
+		notes := map[string]string{"reason": "subscription cancelled"}
+		if _, err := s.Gateway.Refund(ctx, credit.PaymentID, credit.Amount, notes); err != nil {
+			logging.FromContext(ctx).Error("Refund of subscription charge failed, it needs a manual refund", "payment_id", credit.PaymentID, "subscription_id", subscription.ID, "error", err)
+		}
+	}
+}
+
+// customerSubscription loads one of the customer's subscriptions, checking it is in one of statuses.
+func (s *SubscriptionService) customerSubscription(ctx context.Context, customerID string, id string, statuses ...string) (*models.Subscription, error) {
+	subscription, err := s.Repository.GetSubscription(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && subscription.CustomerID != customerID) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if subscription.Status == status {
+			return subscription, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: it is %s", ErrSubscriptionNotActive, subscription.Status)
+}
+
+func (s *SubscriptionService) transition(ctx context.Context, id string, from []string, change models.StatusChange, nextOrderAt *time.Time) (*models.Subscription, error) {
+	subscription, err := s.Repository.TransitionStatus(ctx, id, from, change, nextOrderAt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSubscriptionNotActive
+	}
+	return subscription, err
+}
+
+// nextCycle returns when the cycle after the one at t starts.
+func nextCycle(t time.Time, frequency string) (time.Time, error) {
+	switch frequency {
+	case models.FrequencyWeekly:
+		return t.AddDate(0, 0, 7), nil
+	case models.FrequencyBiweekly:
+		return t.AddDate(0, 0, 14), nil
+	case models.FrequencyMonthly:
+		return t.AddDate(0, 1, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("%w: frequency must be weekly, biweekly or monthly", ErrInvalidPlan)
+}
+
+// upcomingCycle returns the first cycle of the schedule through t, t included, that starts after now.
+func upcomingCycle(t time.Time, frequency string, now time.Time) (time.Time, error) {
+	next := t
+	var err error
+	for err == nil && !next.After(now) {
+		next, err = nextCycle(next, frequency)
+	}
+	return next, err
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/tracing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrWishlistItemNotFound = errors.New("product is not on the wishlist")
+
+const (
+	defaultWishlistReportSize = 20
+	maxWishlistReportSize     = 100
+)
+
+// BackInStockNotifier tells someone on one channel that a product is available again, reporting whether
+// they could be reached on it.
+type BackInStockNotifier interface {
+	NotifyBackInStock(ctx context.Context, contact models.CustomerInfo, product models.Product, link string) (bool, error)
+}
+
+type WishlistServiceInterface interface {
+	GetWishlist(ctx context.Context, customerID string) ([]WishlistEntry, error)
+	SaveToWishlist(ctx context.Context, customerID string, productID string, notifyRestock bool) (*WishlistEntry, error)
+	RemoveFromWishlist(ctx context.Context, customerID string, productID string) error
+	MostWishlisted(ctx context.Context, limit int) ([]WishlistReportRow, error)
+}
+
+// WishlistEntry is a saved product as it is in the catalogue now.
+type WishlistEntry struct {
+	Product       models.Product `json:"product"`
+	NotifyRestock bool           `json:"notify_restock"`
+	AddedAt       time.Time      `json:"added_at"`
+}
+
+// WishlistReportRow is a line of the most-wishlisted products report.
+type WishlistReportRow struct {
+	ProductID       string `json:"product_id"`
+	Name            string `json:"name"`
+	InStock         bool   `json:"in_stock"`
+	Customers       int64  `json:"customers"`
+	WatchingRestock int64  `json:"watching_restock"`
+}
+
+// WishlistService keeps logged-in customers' saved products and sends the restock alerts they ask for.
+type WishlistService struct {
+	Repository repositories.WishlistRepositoryInterface
+	Products   repositories.ProductRepositoryInterface
+	Customers  repositories.CustomerRepositoryInterface
+	Notifiers  []BackInStockNotifier
+	ShopURL    string
+}
+
+// GetWishlist returns a customer's saved products, most recent first. Products that have since left the
+// catalogue are skipped.
+func (s *WishlistService) GetWishlist(ctx context.Context, customerID string) ([]WishlistEntry, error) {
+	ctx, span := tracing.Start(ctx, "WishlistService.GetWishlist")
+	defer span.End()
+
+	items, err := s.Repository.ListItems(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]WishlistEntry, 0, len(items))
+	for _, item := range items {
+		product, err := s.Products.GetProduct(ctx, item.ProductID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, WishlistEntry{Product: *product, NotifyRestock: item.NotifyRestock, AddedAt: item.AddedAt})
+	}
+	return entries, nil
+}
+
+// SaveToWishlist adds a product to the wishlist, or changes whether the customer wants to hear when it is
+// back in stock.
+func (s *WishlistService) SaveToWishlist(ctx context.Context, customerID string, productID string, notifyRestock bool) (*WishlistEntry, error) {
+	ctx, span := tracing.Start(ctx, "WishlistService.SaveToWishlist")
+	defer span.End()
+
+	product, err := s.Products.GetProduct(ctx, productID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item := models.WishlistItem{CustomerID: customerID, ProductID: productID, NotifyRestock: notifyRestock, AddedAt: time.Now()}
+	if err := s.Repository.SaveItem(ctx, item); err != nil {
+		return nil, err
+	}
+	return &WishlistEntry{Product: *product, NotifyRestock: notifyRestock, AddedAt: item.AddedAt}, nil
+}
+
+func (s *WishlistService) RemoveFromWishlist(ctx context.Context, customerID string, productID string) error {
+	ctx, span := tracing.Start(ctx, "WishlistService.RemoveFromWishlist")
+	defer span.End()
+
+	err := s.Repository.RemoveItem(ctx, customerID, productID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrWishlistItemNotFound
+	}
+	return err
+}
+
+// MostWishlisted reports the products saved by the most customers.
+func (s *WishlistService) MostWishlisted(ctx context.Context, limit int) ([]WishlistReportRow, error) {
+	ctx, span := tracing.Start(ctx, "WishlistService.MostWishlisted")
+	defer span.End()
+
+	if limit < 1 {
+		limit = defaultWishlistReportSize
+	}
+	if limit > maxWishlistReportSize {
+		limit = maxWishlistReportSize
+	}
+	counts, err := s.Repository.MostWishlisted(ctx, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]WishlistReportRow, len(counts))
+	for i, count := range counts {
+		rows[i] = WishlistReportRow{ProductID: count.ProductID, Customers: count.Customers, WatchingRestock: count.Watching}
+		product, err := s.Products.GetProduct(ctx, count.ProductID)
+		if err == nil {
+			rows[i].Name = product.Name
+			rows[i].InStock = product.InStock
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// AlertRestocked alerts up to limit customers waiting for a product, oldest request first, and switches
+// their alerts off. Each alert is switched off before it is sent, and skipped if another restock job got to
+// it first. It returns how many were reached and whether more are waiting. Nothing is sent if the product
+// has run out again.
+func (s *WishlistService) AlertRestocked(ctx context.Context, productID string, limit int) (int, bool, error) {
+	ctx, span := tracing.Start(ctx, "WishlistService.AlertRestocked")
+	defer span.End()
+
+	product, err := restockedProduct(ctx, s.Products, productID)
+	if product == nil || err != nil {
+		return 0, false, err
+	}
+
+	watchers, err := s.Repository.ListRestockWatchers(ctx, productID, int64(limit+1))
+	if err != nil {
+		return 0, false, err
+	}
+	more := len(watchers) > limit
+	if more {
+		watchers = watchers[:limit]
+	}
+
+	link := productLink(s.ShopURL, productID)
+	sent := 0
+	for _, item := range watchers {
+		customer, err := s.Customers.GetCustomer(ctx, item.CustomerID)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return sent, more, err
+		}
+		claimed, claimErr := s.Repository.ClaimRestockAlert(ctx, item.CustomerID, productID, time.Now())
+		if claimErr != nil {
+			return sent, more, claimErr
+		}
+		if err == nil && claimed {
+			contact := models.CustomerInfo{Name: customer.Name, Phone: customer.Phone, Email: customer.Email}
+			if notifyBackInStock(ctx, s.Notifiers, contact, *product, link) {
+				sent++
+			}
+		}
+	}
+	return sent, more, nil
+}
+
+// restockedProduct loads a product that restock alerts are due for. It returns nil if the product is
+// gone or has run out again.
+func restockedProduct(ctx context.Context, products repositories.ProductRepositoryInterface, productID string) (*models.Product, error) {
+	product, err := products.GetProduct(ctx, productID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !product.InStock || product.Stock <= 0 {
+		return nil, nil
+	}
+	return product, nil
+}
+
+// notifyBackInStock sends a restock alert on every channel that reaches contact, reporting whether any
+// did. Failures are logged so one bad address does not hold up everyone else's alerts.
+func notifyBackInStock(ctx context.Context, notifiers []BackInStockNotifier, contact models.CustomerInfo, product models.Product, link string) bool {
+	reached := false
+	for _, notifier := range notifiers {
+		queued, err := notifier.NotifyBackInStock(ctx, contact, product, link)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to queue a back in stock alert", "product_id", product.ID, "error", err)
+		}
+		reached = reached || queued
+	}
+	return reached
+}
+
+// productLink opens a product on the storefront.
+func productLink(shopURL string, productID string) string {
+	return strings.TrimRight(shopURL, "/") + "/?product=" + url.QueryEscape(productID)
+}
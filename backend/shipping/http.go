@@ -0,0 +1,254 @@
+package shipping
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"mangal-chai-backend/models"
+)
+
+// HTTPProvider talks to a shipping aggregator's REST API in the style of Shiprocket: the aggregator picks a
+// courier, assigns the AWB number and generates the label. Tracking updates are pushed to the shop's
+// webhook, signed with the hex HMAC-SHA256 of the body under WebhookSecret, and can also be polled.
+type HTTPProvider struct {
+	BaseURL        string
+	APIKey         string
+	PickupLocation string // the pickup address registered with the aggregator
+	WebhookSecret  string
+	Client         *http.Client
+}
+
+type shipmentRequest struct {
+	OrderID        string         `json:"order_id"`
+	OrderDate      time.Time      `json:"order_date"`
+	PickupLocation string         `json:"pickup_location,omitempty"`
+	Consignee      consignee      `json:"consignee"`
+	Items          []shipmentItem `json:"items"`
+	Amount         float64        `json:"amount"`
+	PaymentMode    string         `json:"payment_mode"`
+	WeightGrams    int            `json:"weight_grams,omitempty"`
+}
+
+type consignee struct {
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email,omitempty"`
+	Address string `json:"address"`
+}
+
+type shipmentItem struct {
+	SKU       string  `json:"sku"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+}
+
+type shipmentResponse struct {
+	ShipmentID  string `json:"shipment_id"`
+	AWB         string `json:"awb"`
+	Courier     string `json:"courier"`
+	TrackingURL string `json:"tracking_url"`
+}
+
+type labelResponse struct {
+	LabelURL string `json:"label_url"`
+}
+
+// trackingResponse is both the tracking API's response and the body of tracking webhooks.
+type trackingResponse struct {
+	AWB    string          `json:"awb"`
+	Events []trackingEvent `json:"events"`
+}
+
+type trackingEvent struct {
+	Status      string    `json:"status"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// trackingStatuses maps the aggregator's status codes onto the shop's tracking statuses.
+var trackingStatuses = map[string]string{
+	"BOOKED":           models.TrackingStatusBooked,
+	"MANIFESTED":       models.TrackingStatusBooked,
+	"PICKUP_SCHEDULED": models.TrackingStatusBooked,
+	"PICKED_UP":        models.TrackingStatusPickedUp,
+	"SHIPPED":          models.TrackingStatusInTransit,
+	"IN_TRANSIT":       models.TrackingStatusInTransit,
+	"REACHED_HUB":      models.TrackingStatusInTransit,
+	"OUT_FOR_DELIVERY": models.TrackingStatusOutForDelivery,
+	"DELIVERED":        models.TrackingStatusDelivered,
+	"UNDELIVERED":      models.TrackingStatusFailed,
+	"DELIVERY_FAILED":  models.TrackingStatusFailed,
+	"RTO_INITIATED":    models.TrackingStatusReturned,
+	"RTO_DELIVERED":    models.TrackingStatusReturned,
+	"CANCELLED":        models.TrackingStatusCancelled,
+	"CANCELED":         models.TrackingStatusCancelled,
+}
+
+func (p *HTTPProvider) Name() string {
+	return ProviderHTTP
+}
+
+func (p *HTTPProvider) CreateShipment(parcel Parcel) (*Booking, error) {
+	order := parcel.Order
+	if order.CustomerInfo.Address == "" || order.CustomerInfo.Phone == "" {
+		return nil, fmt.Errorf("%w: the order has no delivery address or phone", ErrInvalidParcel)
+	}
+	request := shipmentRequest{
+		OrderID:        order.ID,
+		OrderDate:      order.OrderDate,
+		PickupLocation: p.PickupLocation,
+		Consignee: consignee{
+			Name:    order.CustomerInfo.Name,
+			Phone:   order.CustomerInfo.Phone,
+			Email:   order.CustomerInfo.Email,
+			Address: order.CustomerInfo.Address,
+		},
+		Items:       make([]shipmentItem, 0, len(order.Items)),
+		Amount:      order.TotalAmount,
+		PaymentMode: "prepaid",
+		WeightGrams: parcel.WeightGrams,
+	}
+	for _, item := range order.Items {
+		request.Items = append(request.Items, shipmentItem{SKU: item.ProductID, Quantity: item.Quantity, UnitPrice: item.Price})
+	}
+
+	var response shipmentResponse
+	if err := p.do(http.MethodPost, "/shipments", request, &response); err != nil {
+		return nil, err
+	}
+	if response.ShipmentID == "" || response.AWB == "" {
+		return nil, fmt.Errorf("shipping provider response has no shipment id or awb")
+	}
+	return &Booking{
+		ShipmentID:  response.ShipmentID,
+		AWB:         response.AWB,
+		Courier:     response.Courier,
+		TrackingURL: response.TrackingURL,
+	}, nil
+}
+
+func (p *HTTPProvider) Label(shipmentID string) (string, error) {
+	var response labelResponse
+	if err := p.do(http.MethodPost, "/shipments/"+url.PathEscape(shipmentID)+"/label", nil, &response); err != nil {
+		return "", err
+	}
+	if response.LabelURL == "" {
+		return "", fmt.Errorf("shipping provider response has no label url")
+	}
+	return response.LabelURL, nil
+}
+
+func (p *HTTPProvider) Track(awb string) ([]models.TrackingEvent, error) {
+	var response trackingResponse
+	if err := p.do(http.MethodGet, "/tracking/"+url.PathEscape(awb), nil, &response); err != nil {
+		return nil, err
+	}
+	return trackingEvents(response.Events), nil
+}
+
+func (p *HTTPProvider) Cancel(shipmentID string) error {
+	return p.do(http.MethodPost, "/shipments/"+url.PathEscape(shipmentID)+"/cancel", nil, nil)
+}
+
+// VerifyWebhookSignature checks a webhook's hex HMAC-SHA256 signature. It always fails when no webhook
+// secret is configured so tracking updates cannot be forged.
+func (p *HTTPProvider) VerifyWebhookSignature(body []byte, signature string) bool {
+	if p.WebhookSecret == "" || signature == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(p.WebhookSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func (p *HTTPProvider) ParseTrackingWebhook(body []byte) (*TrackingUpdate, error) {
+	var webhook trackingResponse
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, fmt.Errorf("invalid tracking webhook: %w", err)
+	}
+	if webhook.AWB == "" {
+		return nil, fmt.Errorf("tracking webhook has no awb")
+	}
+	return &TrackingUpdate{AWB: webhook.AWB, Events: trackingEvents(webhook.Events)}, nil
+}
+
+// do sends a request to the provider's API and decodes its JSON response into result, if result is not
+// nil.
+func (p *HTTPProvider) do(method string, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(p.BaseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 256<<10))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("shipping provider returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("invalid shipping provider response: %w", err)
+	}
+	return nil
+}
+
+func trackingEvents(events []trackingEvent) []models.TrackingEvent {
+	converted := make([]models.TrackingEvent, 0, len(events))
+	for _, event := range events {
+		converted = append(converted, models.TrackingEvent{
+			Status:      trackingStatus(event.Status),
+			Description: event.Description,
+			Location:    event.Location,
+			OccurredAt:  event.OccurredAt,
+		})
+	}
+	return converted
+}
+
+// trackingStatus maps a status code onto a tracking status, keeping codes it does not know in lower case.
+func trackingStatus(code string) string {
+	code = strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_").Replace(strings.TrimSpace(code)))
+	if status, ok := trackingStatuses[code]; ok {
+		return status
+	}
+	return strings.ToLower(code)
+}
@@ -0,0 +1,122 @@
+// Package shipping books parcels with couriers, fetches their labels and tracks them through a pluggable
+// provider.
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"mangal-chai-backend/models"
+)
+
+// Provider names, recorded on each shipment so tracking goes back to the provider that booked it.
+const (
+	ProviderManual = "manual"
+	ProviderHTTP   = "http"
+)
+
+var (
+	// ErrNotSupported is returned by providers for operations they cannot perform, e.g. labels for
+	// parcels staff booked with the courier themselves.
+	ErrNotSupported = errors.New("not supported by the shipping provider")
+	// ErrInvalidParcel is returned when a parcel is missing details the provider needs to book it.
+	ErrInvalidParcel = errors.New("invalid parcel")
+)
+
+// Provider is what the shop needs from a shipping aggregator or courier.
+type Provider interface {
+	Name() string
+	CreateShipment(parcel Parcel) (*Booking, error)
+	Label(shipmentID string) (string, error)
+	Track(awb string) ([]models.TrackingEvent, error)
+	Cancel(shipmentID string) error
+	VerifyWebhookSignature(body []byte, signature string) bool
+	ParseTrackingWebhook(body []byte) (*TrackingUpdate, error)
+}
+
+// Parcel is an order to be booked with a courier. Courier, TrackingNumber and TrackingURL are only used by
+// the manual provider, where staff book the parcel themselves and enter the courier's details.
+type Parcel struct {
+	Order          models.Order
+	WeightGrams    int
+	Courier        string
+	TrackingNumber string
+	TrackingURL    string
+}
+
+// Booking is the provider's record of a booked parcel. AWB is the courier's air waybill number, which
+// customers track the parcel with.
+type Booking struct {
+	ShipmentID  string
+	AWB         string
+	Courier     string
+	TrackingURL string
+}
+
+// TrackingUpdate is a batch of tracking events for one parcel, as pushed by a provider's webhook.
+type TrackingUpdate struct {
+	AWB    string
+	Events []models.TrackingEvent
+}
+
+// ManualProvider is for parcels staff book with a courier outside the shop. It records the courier and
+// AWB number staff enter, and cannot fetch labels or tracking.
+type ManualProvider struct{}
+
+func (p *ManualProvider) Name() string {
+	return ProviderManual
+}
+
+func (p *ManualProvider) CreateShipment(parcel Parcel) (*Booking, error) {
+	if parcel.Courier == "" || parcel.TrackingNumber == "" {
+		return nil, fmt.Errorf("%w: courier and tracking_number are required", ErrInvalidParcel)
+	}
+	return &Booking{
+		AWB:         parcel.TrackingNumber,
+		Courier:     parcel.Courier,
+		TrackingURL: parcel.TrackingURL,
+	}, nil
+}
+
+func (p *ManualProvider) Label(shipmentID string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (p *ManualProvider) Track(awb string) ([]models.TrackingEvent, error) {
+	return nil, ErrNotSupported
+}
+
+// Cancel does nothing: there is no booking to cancel with the provider, staff cancel with the courier.
+func (p *ManualProvider) Cancel(shipmentID string) error {
+	return nil
+}
+
+func (p *ManualProvider) VerifyWebhookSignature(body []byte, signature string) bool {
+	return false
+}
+
+func (p *ManualProvider) ParseTrackingWebhook(body []byte) (*TrackingUpdate, error) {
+	return nil, ErrNotSupported
+}
+
+// NewProviderFromEnv builds the provider selected by SHIPPING_PROVIDER: "http", or "manual" (the default).
+func NewProviderFromEnv() (Provider, error) {
+	switch provider := os.Getenv("SHIPPING_PROVIDER"); provider {
+	case "", ProviderManual:
+		return &ManualProvider{}, nil
+	case ProviderHTTP:
+		baseURL := os.Getenv("SHIPPING_API_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("SHIPPING_API_URL must be set when SHIPPING_PROVIDER=http")
+		}
+		return &HTTPProvider{
+			BaseURL:        baseURL,
+			APIKey:         os.Getenv("SHIPPING_API_KEY"),
+			PickupLocation: os.Getenv("SHIPPING_PICKUP_LOCATION"),
+			WebhookSecret:  os.Getenv("SHIPPING_WEBHOOK_SECRET"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown SHIPPING_PROVIDER %q, use http or manual", provider)
+	}
+}
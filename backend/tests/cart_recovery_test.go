@@ -0,0 +1,363 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/notifications"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockCouponRepository struct {
+	mock.Mock
+}
+
+func (m *MockCouponRepository) CreateCoupon(ctx context.Context, coupon models.Coupon) error {
+	args := m.Called(coupon)
+	return args.Error(0)
+}
+
+func (m *MockCouponRepository) Redeem(ctx context.Context, code string, orderID string, at time.Time) (*models.Coupon, error) {
+	args := m.Called(code, orderID, at)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Coupon), args.Error(1)
+}
+
+func (m *MockCouponRepository) Release(ctx context.Context, code string, orderID string) error {
+	args := m.Called(code, orderID)
+	return args.Error(0)
+}
+
+type MockCartReminderRepository struct {
+	mock.Mock
+}
+
+func (m *MockCartReminderRepository) CreateReminder(ctx context.Context, reminder models.CartReminder) error {
+	args := m.Called(reminder)
+	return args.Error(0)
+}
+
+func (m *MockCartReminderRepository) MarkRecovered(ctx context.Context, id string, orderID string, orderTotal float64, couponUsed bool, at time.Time) error {
+	args := m.Called(id, orderID, orderTotal, couponUsed, at)
+	return args.Error(0)
+}
+
+func (m *MockCartReminderRepository) RecoveryStats(ctx context.Context, from time.Time, to time.Time) (*repositories.CartRecoveryStats, error) {
+	args := m.Called(from, to)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*repositories.CartRecoveryStats), args.Error(1)
+}
+
+type MockCartReminderNotifier struct {
+	mock.Mock
+}
+
+func (m *MockCartReminderNotifier) CanRemind(ctx context.Context, cart models.Cart) (bool, error) {
+	args := m.Called(cart)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCartReminderNotifier) RemindCart(ctx context.Context, cart models.Cart, reminder models.CartReminder) (bool, error) {
+	args := m.Called(cart, reminder)
+	return args.Bool(0), args.Error(1)
+}
+
+// restoreToken pulls the signed token out of a reminder's restore link.
+func restoreToken(t *testing.T, link string) string {
+	parsed, err := url.Parse(link)
+	assert.Nil(t, err)
+	return parsed.Query().Get("restore_cart")
+}
+
+func TestCartRecoveryService(t *testing.T) {
+	secret := []byte("test-secret")
+	abandoned := models.Cart{
+		ID:       "cart_1",
+		Token:    "guest-token",
+		Email:    "asha@example.com",
+		Items:    []models.CartLine{{ProductID: "prod1", Name: "Masala Chai", Price: 199.0, Quantity: 2}},
+		Subtotal: 398.0,
+	}
+
+	t.Run("SendReminders - Sends Reminder With Coupon And Restore Link", func(t *testing.T) {
+		mockCarts := new(MockCartRepository)
+		mockReminders := new(MockCartReminderRepository)
+		mockCoupons := new(MockCouponRepository)
+		mockNotifier := new(MockCartReminderNotifier)
+
+		mockCarts.On("ListAbandoned", mock.Anything, mock.Anything, int64(100)).Return([]models.Cart{abandoned}, nil)
+		mockCoupons.On("CreateCoupon", mock.MatchedBy(func(coupon models.Coupon) bool {
+			return strings.HasPrefix(coupon.Code, "BACK-") && coupon.PercentOff == 10 &&
+				coupon.Source == models.CouponSourceCartRecovery && coupon.ExpiresAt.After(time.Now())
+		})).Return(nil)
+		var sent models.CartReminder
+		mockNotifier.On("CanRemind", abandoned).Return(true, nil)
+		mockNotifier.On("RemindCart", abandoned, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(models.CartReminder)
+		}).Return(true, nil)
+		mockReminders.On("CreateReminder", mock.Anything).Return(nil)
+		mockCarts.On("MarkReminded", "cart_1", mock.Anything).Return(nil)
+
+		service := &services.CartRecoveryService{
+			Carts:         mockCarts,
+			Reminders:     mockReminders,
+			Coupons:       mockCoupons,
+			Notifiers:     []services.CartReminderNotifier{mockNotifier},
+			LinkSecret:    secret,
+			ShopURL:       "https://shop.example.com/",
+			CouponPercent: 10,
+		}
+		count, err := service.SendReminders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, "cart_1", sent.CartID)
+		assert.True(t, strings.HasPrefix(sent.CouponCode, "BACK-"))
+		assert.True(t, strings.HasPrefix(sent.Link, "https://shop.example.com/?restore_cart="))
+		mockReminders.AssertCalled(t, "CreateReminder", sent)
+		mockCarts.AssertCalled(t, "MarkReminded", "cart_1", sent.ID)
+
+		mockCarts.On("GetCart", "cart_1").Return(&abandoned, nil)
+		mockCartService := new(MockCartService)
+		mockCartService.On("GetCart", services.CartKey{Token: "guest-token"}).Return(&abandoned, nil)
+		service.CartService = mockCartService
+
+		restored, err := service.RestoreCart(context.Background(), restoreToken(t, sent.Link), "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "guest-token", restored.Token)
+	})
+
+	t.Run("SendReminders - Marks Unreachable Cart Without Reminder Or Coupon", func(t *testing.T) {
+		mockCarts := new(MockCartRepository)
+		mockReminders := new(MockCartReminderRepository)
+		mockCustomers := new(MockCustomerRepository)
+		mockCoupons := new(MockCouponRepository)
+		mockNotifier := new(MockCartReminderNotifier)
+
+		cart := models.Cart{ID: "cart_2", CustomerID: "cus_1", Items: abandoned.Items}
+		mockCarts.On("ListAbandoned", mock.Anything, mock.Anything, int64(100)).Return([]models.Cart{cart}, nil)
+		mockCustomers.On("GetCustomer", "cus_1").Return(&models.Customer{ID: "cus_1", Phone: "+919876543210"}, nil)
+		mockNotifier.On("CanRemind", mock.MatchedBy(func(c models.Cart) bool {
+			return c.Phone == "+919876543210"
+		})).Return(false, nil)
+		mockCarts.On("MarkReminded", "cart_2", mock.Anything).Return(nil)
+
+		service := &services.CartRecoveryService{
+			Carts:         mockCarts,
+			Reminders:     mockReminders,
+			Customers:     mockCustomers,
+			Coupons:       mockCoupons,
+			Notifiers:     []services.CartReminderNotifier{mockNotifier},
+			LinkSecret:    secret,
+			CouponPercent: 10,
+		}
+		count, err := service.SendReminders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count)
+		mockCarts.AssertExpectations(t)
+		mockReminders.AssertNotCalled(t, "CreateReminder", mock.Anything)
+		mockCoupons.AssertNotCalled(t, "CreateCoupon", mock.Anything)
+		mockNotifier.AssertNotCalled(t, "RemindCart", mock.Anything, mock.Anything)
+	})
+
+	t.Run("SendReminders - Disabled Without Secret", func(t *testing.T) {
+		service := &services.CartRecoveryService{}
+		_, err := service.SendReminders(context.Background())
+
+		assert.True(t, errors.Is(err, services.ErrCartRecoveryDisabled))
+	})
+
+	t.Run("RestoreCart - Rejects Tampered Token", func(t *testing.T) {
+		mockCarts := new(MockCartRepository)
+		service := &services.CartRecoveryService{Carts: mockCarts, LinkSecret: secret}
+
+		_, err := service.RestoreCart(context.Background(), "Y2FydHxjYXJ0XzF8OTk5OTk5OTk5OQ.c2lnbmF0dXJl", "")
+
+		assert.True(t, errors.Is(err, services.ErrInvalidToken))
+		mockCarts.AssertNotCalled(t, "GetCart", mock.Anything)
+	})
+
+	t.Run("RestoreCart - Customer Login Token Is Not A Cart Link", func(t *testing.T) {
+		tokens := &services.CustomerTokens{Secret: secret}
+		token, _, err := tokens.Issue("cart_1", time.Now())
+		assert.Nil(t, err)
+
+		service := &services.CartRecoveryService{Carts: new(MockCartRepository), LinkSecret: secret}
+		_, err = service.RestoreCart(context.Background(), token, "")
+
+		assert.True(t, errors.Is(err, services.ErrInvalidToken))
+	})
+
+	t.Run("RestoreCart - Customer Cart Needs Its Owner", func(t *testing.T) {
+		owned := models.Cart{ID: "cart_3", CustomerID: "cus_1", Items: abandoned.Items}
+		mockCarts := new(MockCartRepository)
+		mockCarts.On("GetCart", "cart_3").Return(&owned, nil)
+		mockCartService := new(MockCartService)
+		mockCartService.On("GetCart", services.CartKey{CustomerID: "cus_1"}).Return(&owned, nil)
+		service := &services.CartRecoveryService{Carts: mockCarts, CartService: mockCartService, LinkSecret: secret, ShopURL: "https://shop.example.com"}
+
+		mockCustomers := new(MockCustomerRepository)
+		mockCustomers.On("GetCustomer", "cus_1").Return(&models.Customer{ID: "cus_1"}, nil)
+		mockNotifier := new(MockCartReminderNotifier)
+		var sent models.CartReminder
+		mockNotifier.On("CanRemind", mock.Anything).Return(true, nil)
+		mockNotifier.On("RemindCart", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(models.CartReminder)
+		}).Return(true, nil)
+		mockCarts.On("ListAbandoned", mock.Anything, mock.Anything, int64(100)).Return([]models.Cart{owned}, nil)
+		mockCarts.On("MarkReminded", "cart_3", mock.Anything).Return(nil)
+		mockReminders := new(MockCartReminderRepository)
+		mockReminders.On("CreateReminder", mock.Anything).Return(nil)
+		service.Customers, service.Notifiers, service.Reminders = mockCustomers, []services.CartReminderNotifier{mockNotifier}, mockReminders
+		_, err := service.SendReminders(context.Background())
+		assert.Nil(t, err)
+		token := restoreToken(t, sent.Link)
+
+		_, err = service.RestoreCart(context.Background(), token, "")
+		assert.True(t, errors.Is(err, services.ErrCartLoginRequired))
+
+		_, err = service.RestoreCart(context.Background(), token, "cus_2")
+		assert.True(t, errors.Is(err, services.ErrCartNotFound))
+
+		restored, err := service.RestoreCart(context.Background(), token, "cus_1")
+		assert.Nil(t, err)
+		assert.Equal(t, "cart_3", restored.ID)
+	})
+
+	t.Run("RecoveryReport - Computes Conversion Rate", func(t *testing.T) {
+		mockReminders := new(MockCartReminderRepository)
+		ist := time.FixedZone("IST", 5*60*60+30*60)
+		from := time.Date(2024, 3, 1, 0, 0, 0, 0, ist)
+		to := time.Date(2024, 4, 1, 0, 0, 0, 0, ist)
+		mockReminders.On("RecoveryStats", from, to).Return(&repositories.CartRecoveryStats{
+			Sent: 8, Recovered: 3, CouponsUsed: 2, RecoveredRevenue: 1234.567,
+		}, nil)
+
+		service := &services.CartRecoveryService{Reminders: mockReminders}
+		report, err := service.RecoveryReport(context.Background(), "2024-03-01", "2024-03-31")
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(8), report.RemindersSent)
+		assert.Equal(t, int64(3), report.CartsRecovered)
+		assert.Equal(t, 0.375, report.ConversionRate)
+		assert.Equal(t, 1234.57, report.RecoveredRevenue)
+	})
+
+	t.Run("RecoveryReport - Invalid Period", func(t *testing.T) {
+		service := &services.CartRecoveryService{Reminders: new(MockCartReminderRepository)}
+		_, err := service.RecoveryReport(context.Background(), "2024-04-01", "2024-03-01")
+
+		assert.True(t, errors.Is(err, services.ErrInvalidRecoveryPeriod))
+	})
+}
+
+func TestCartConversion(t *testing.T) {
+	product := &models.Product{ID: "prod1", Name: "Masala Chai", Price: 200.0, InStock: true, Stock: 5}
+
+	t.Run("CreateOrder - Applies Coupon And Completes Cart", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockCoupons := new(MockCouponRepository)
+		mockCarts := new(MockCartService)
+
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 2).Return(nil)
+		mockCoupons.On("Redeem", "BACK-ABCD2345", mock.Anything, mock.Anything).Return(&models.Coupon{Code: "BACK-ABCD2345", PercentOff: 10}, nil)
+		mockOrderRepo.On("CreateOrder", mock.Anything).Return(nil)
+		mockCarts.On("CompleteCart", services.CartKey{Token: "guest-token"}, mock.Anything).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Coupons: mockCoupons, Carts: mockCarts}
+		order, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			CustomerInfo: models.CustomerInfo{Name: "Asha"},
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 2}},
+			CouponCode:   " back-abcd2345 ",
+			CartToken:    "guest-token",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "BACK-ABCD2345", order.CouponCode)
+		assert.Equal(t, 40.0, order.Discount)
+		assert.Equal(t, 360.0, order.TotalAmount)
+		mockCarts.AssertExpectations(t)
+	})
+
+	t.Run("CreateOrder - Invalid Coupon Releases Stock", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockCoupons := new(MockCouponRepository)
+
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 2).Return(nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 2).Return(nil)
+		mockCoupons.On("Redeem", "USED", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Coupons: mockCoupons}
+		_, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			CustomerInfo: models.CustomerInfo{Name: "Asha"},
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 2}},
+			CouponCode:   "used",
+		})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidCoupon))
+		mockProductRepo.AssertExpectations(t)
+		mockOrderRepo.AssertNotCalled(t, "CreateOrder", mock.Anything)
+	})
+
+	t.Run("CompleteCart - Credits Reminder With Order", func(t *testing.T) {
+		mockCarts := new(MockCartRepository)
+		mockReminders := new(MockCartReminderRepository)
+
+		mockCarts.On("GetCartByCustomer", "cus_1").Return(&models.Cart{ID: "cart_1", CustomerID: "cus_1", ReminderID: "crm_1"}, nil)
+		mockReminders.On("MarkRecovered", "crm_1", "ord_1", 360.0, true, mock.Anything).Return(nil)
+		mockCarts.On("DeleteCart", "cart_1").Return(nil)
+
+		service := &services.CartService{Repository: mockCarts, Reminders: mockReminders}
+		err := service.CompleteCart(context.Background(), services.CartKey{CustomerID: "cus_1"}, models.Order{ID: "ord_1", TotalAmount: 360.0, CouponCode: "BACK-ABCD2345"})
+
+		assert.Nil(t, err)
+		mockCarts.AssertExpectations(t)
+		mockReminders.AssertExpectations(t)
+	})
+
+	t.Run("RemindCart - Queues Email With Link And Coupon", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+		var queued models.Notification
+		mockOutbox.On("Enqueue", mock.Anything).Run(func(args mock.Arguments) {
+			queued = args.Get(0).(models.Notification)
+		}).Return(nil)
+
+		notifier := &notifications.Notifier{Outbox: mockOutbox}
+		cart := models.Cart{
+			Name:  "Asha",
+			Email: "asha@example.com",
+			Items: []models.CartLine{{ProductID: "prod1", Name: "Masala Chai", Price: 199.0, Quantity: 2}},
+		}
+		reached, err := notifier.RemindCart(context.Background(), cart, models.CartReminder{Link: "https://shop.example.com/?restore_cart=abc", CouponCode: "BACK-ABCD2345", PercentOff: 10})
+
+		assert.Nil(t, err)
+		assert.True(t, reached)
+		assert.Equal(t, "asha@example.com", queued.To)
+		assert.Equal(t, models.CartEventReminder, queued.Event)
+		assert.Contains(t, queued.HTML, `href="https://shop.example.com/?restore_cart=abc"`)
+		assert.Contains(t, queued.Text, "BACK-ABCD2345")
+		assert.Contains(t, queued.Text, "Masala Chai")
+	})
+}
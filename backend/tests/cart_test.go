@@ -0,0 +1,367 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockCartRepository struct {
+	mock.Mock
+}
+
+func (m *MockCartRepository) GetCartByToken(ctx context.Context, token string) (*models.Cart, error) {
+	args := m.Called(token)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Cart), args.Error(1)
+}
+
+func (m *MockCartRepository) GetCartByCustomer(ctx context.Context, customerID string) (*models.Cart, error) {
+	args := m.Called(customerID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Cart), args.Error(1)
+}
+
+func (m *MockCartRepository) SaveCart(ctx context.Context, cart models.Cart) error {
+	args := m.Called(cart)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) DeleteCart(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockCartRepository) GetCart(ctx context.Context, id string) (*models.Cart, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Cart), args.Error(1)
+}
+
+func (m *MockCartRepository) ListAbandoned(ctx context.Context, updatedAfter, updatedBefore time.Time, limit int64) ([]models.Cart, error) {
+	args := m.Called(updatedAfter, updatedBefore, limit)
+	return args.Get(0).([]models.Cart), args.Error(1)
+}
+
+func (m *MockCartRepository) MarkReminded(ctx context.Context, id string, reminderID string) error {
+	args := m.Called(id, reminderID)
+	return args.Error(0)
+}
+
+type MockCustomerRepository struct {
+	mock.Mock
+}
+
+func (m *MockCustomerRepository) GetCustomer(ctx context.Context, id string) (*models.Customer, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Customer), args.Error(1)
+}
+
+func (m *MockCustomerRepository) RecordLogin(ctx context.Context, phone string, at time.Time) (*models.Customer, error) {
+	args := m.Called(phone, at)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Customer), args.Error(1)
+}
+
+type MockCartService struct {
+	mock.Mock
+}
+
+func (m *MockCartService) GetCart(ctx context.Context, key services.CartKey) (*models.Cart, error) {
+	args := m.Called(key)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Cart), args.Error(1)
+}
+
+func (m *MockCartService) UpdateCart(ctx context.Context, key services.CartKey, update services.CartUpdate) (*models.Cart, error) {
+	args := m.Called(key, update)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Cart), args.Error(1)
+}
+
+func (m *MockCartService) DeleteCart(ctx context.Context, key services.CartKey) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockCartService) MergeGuestCart(ctx context.Context, token string, customerID string) (*models.Cart, error) {
+	args := m.Called(token, customerID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Cart), args.Error(1)
+}
+
+func (m *MockCartService) CompleteCart(ctx context.Context, key services.CartKey, order models.Order) error {
+	args := m.Called(key, order)
+	return args.Error(0)
+}
+
+func TestCartService(t *testing.T) {
+	chai := &models.Product{ID: "prod1", Name: "Masala Chai", Price: 199.0, InStock: true, Stock: 5}
+	cups := &models.Product{ID: "prod2", Name: "Kulhad Cups", Price: 99.5, InStock: true, Stock: 2}
+
+	t.Run("UpdateCart - Creates Guest Cart With Token", func(t *testing.T) {
+		mockCarts := new(MockCartRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "prod1").Return(chai, nil)
+		mockCarts.On("SaveCart", mock.Anything).Return(nil)
+
+		service := &services.CartService{Repository: mockCarts, ProductRepository: mockProductRepo}
+		cart, err := service.UpdateCart(context.Background(), services.CartKey{}, services.CartUpdate{Items: []models.CartItem{
+			{ProductID: "prod1", Quantity: 1},
+			{ProductID: "prod1", Quantity: 2, Price: 1.0},
+		}})
+
+		assert.Nil(t, err)
+		assert.Len(t, cart.Token, 32)
+		assert.Equal(t, []models.CartLine{{ProductID: "prod1", Name: "Masala Chai", Quantity: 3, Price: 199.0}}, cart.Items)
+		assert.Equal(t, 597.0, cart.Subtotal)
+		assert.True(t, cart.ExpiresAt.After(time.Now().Add(29*24*time.Hour)))
+		mockCarts.AssertNotCalled(t, "GetCartByToken", mock.Anything)
+	})
+
+	t.Run("UpdateCart - Rejects Unknown Product", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "nope").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.CartService{Repository: new(MockCartRepository), ProductRepository: mockProductRepo}
+		_, err := service.UpdateCart(context.Background(), services.CartKey{Token: "tok"}, services.CartUpdate{Items: []models.CartItem{{ProductID: "nope", Quantity: 1}}})
+
+		assert.ErrorIs(t, err, services.ErrInvalidCart)
+	})
+
+	t.Run("UpdateCart - Rejects More Than In Stock", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "prod2").Return(cups, nil)
+
+		service := &services.CartService{Repository: new(MockCartRepository), ProductRepository: mockProductRepo}
+		_, err := service.UpdateCart(context.Background(), services.CartKey{Token: "tok"}, services.CartUpdate{Items: []models.CartItem{{ProductID: "prod2", Quantity: 3}}})
+
+		assert.ErrorIs(t, err, services.ErrInvalidCart)
+		assert.Contains(t, err.Error(), "only 2 of Kulhad Cups left")
+	})
+
+	t.Run("UpdateCart - Rejects Zero Quantity", func(t *testing.T) {
+		service := &services.CartService{Repository: new(MockCartRepository), ProductRepository: new(MockProductRepository)}
+		_, err := service.UpdateCart(context.Background(), services.CartKey{Token: "tok"}, services.CartUpdate{Items: []models.CartItem{{ProductID: "prod1", Quantity: 0}}})
+
+		assert.ErrorIs(t, err, services.ErrInvalidCart)
+	})
+
+	t.Run("GetCart - Empty When Missing", func(t *testing.T) {
+		mockCarts := new(MockCartRepository)
+		mockCarts.On("GetCartByToken", "tok").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.CartService{Repository: mockCarts, ProductRepository: new(MockProductRepository)}
+		cart, err := service.GetCart(context.Background(), services.CartKey{Token: "tok"})
+
+		assert.Nil(t, err)
+		assert.Empty(t, cart.Items)
+		mockCarts.AssertNotCalled(t, "SaveCart", mock.Anything)
+	})
+
+	t.Run("GetCart - Refreshes Prices And Stock", func(t *testing.T) {
+		mockCarts := new(MockCartRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockCarts.On("GetCartByCustomer", "cus_1").Return(&models.Cart{ID: "cart_1", CustomerID: "cus_1", Items: []models.CartLine{
+			{ProductID: "prod1", Name: "Masala Chai", Quantity: 1, Price: 149.0},
+			{ProductID: "prod2", Name: "Kulhad Cups", Quantity: 4, Price: 99.5},
+			{ProductID: "prod3", Name: "Old Blend", Quantity: 1, Price: 50.0},
+		}}, nil)
+		mockProductRepo.On("GetProduct", "prod1").Return(chai, nil)
+		mockProductRepo.On("GetProduct", "prod2").Return(cups, nil)
+		mockProductRepo.On("GetProduct", "prod3").Return(nil, mongo.ErrNoDocuments)
+		var saved models.Cart
+		mockCarts.On("SaveCart", mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(models.Cart)
+		}).Return(nil)
+
+		service := &services.CartService{Repository: mockCarts, ProductRepository: mockProductRepo}
+		cart, err := service.GetCart(context.Background(), services.CartKey{CustomerID: "cus_1", Token: "ignored"})
+
+		assert.Nil(t, err)
+		assert.Len(t, cart.Items, 2)
+		assert.Equal(t, 199.0, cart.Items[0].Price)
+		assert.Equal(t, 2, cart.Items[1].Quantity)
+		assert.Equal(t, 398.0, cart.Subtotal)
+		assert.Len(t, cart.Notices, 3)
+		assert.Equal(t, "cart_1", saved.ID)
+		assert.True(t, saved.ExpiresAt.After(time.Now().Add(89*24*time.Hour)))
+	})
+
+	t.Run("MergeGuestCart - Adds Quantities And Deletes Guest Cart", func(t *testing.T) {
+		mockCarts := new(MockCartRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockCarts.On("GetCartByToken", "tok").Return(&models.Cart{ID: "cart_guest", Token: "tok", Items: []models.CartLine{
+			{ProductID: "prod1", Quantity: 2, Price: 199.0},
+			{ProductID: "prod2", Quantity: 1, Price: 99.5},
+		}}, nil)
+		mockCarts.On("GetCartByCustomer", "cus_1").Return(&models.Cart{ID: "cart_1", CustomerID: "cus_1", Items: []models.CartLine{
+			{ProductID: "prod2", Quantity: 2, Price: 99.5},
+		}}, nil)
+		mockProductRepo.On("GetProduct", "prod1").Return(chai, nil)
+		mockProductRepo.On("GetProduct", "prod2").Return(cups, nil)
+		mockCarts.On("SaveCart", mock.MatchedBy(func(cart models.Cart) bool { return cart.ID == "cart_1" })).Return(nil)
+		mockCarts.On("DeleteCart", "cart_guest").Return(nil)
+
+		service := &services.CartService{Repository: mockCarts, ProductRepository: mockProductRepo}
+		cart, err := service.MergeGuestCart(context.Background(), "tok", "cus_1")
+
+		assert.Nil(t, err)
+		assert.Len(t, cart.Items, 2)
+		assert.Equal(t, 2, cart.Items[0].Quantity) // 2 + 1 capped at the 2 cups in stock
+		assert.Equal(t, "prod1", cart.Items[1].ProductID)
+		mockCarts.AssertExpectations(t)
+	})
+}
+
+func TestCustomerLogin(t *testing.T) {
+	tokens := &services.CustomerTokens{Secret: []byte("test-secret")}
+
+	t.Run("CustomerTokens - Round Trip", func(t *testing.T) {
+		token, expiresAt, err := tokens.Issue("cus_1", time.Now())
+		assert.Nil(t, err)
+		assert.True(t, expiresAt.After(time.Now()))
+
+		customerID, err := tokens.Verify(token)
+		assert.Nil(t, err)
+		assert.Equal(t, "cus_1", customerID)
+	})
+
+	t.Run("CustomerTokens - Rejects Tampered And Expired Tokens", func(t *testing.T) {
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+		other := &services.CustomerTokens{Secret: []byte("other-secret")}
+		_, err := other.Verify(token)
+		assert.ErrorIs(t, err, services.ErrInvalidToken)
+
+		expired, _, _ := tokens.Issue("cus_1", time.Now().Add(-31*24*time.Hour))
+		_, err = tokens.Verify(expired)
+		assert.ErrorIs(t, err, services.ErrInvalidToken)
+
+		_, err = tokens.Verify("not-a-token")
+		assert.ErrorIs(t, err, services.ErrInvalidToken)
+	})
+
+	t.Run("Login - Issues Token And Merges Guest Cart", func(t *testing.T) {
+		mockOTP := new(MockOTPService)
+		mockCustomers := new(MockCustomerRepository)
+		mockCarts := new(MockCartService)
+		mockOTP.On("VerifyOTP", "9876543210", services.OTPPurposeLogin, "123456").Return("+919876543210", nil)
+		mockCustomers.On("RecordLogin", "+919876543210", mock.Anything).Return(&models.Customer{ID: "cus_1", Phone: "+919876543210"}, nil)
+		mockCarts.On("MergeGuestCart", "tok", "cus_1").Return(&models.Cart{ID: "cart_1", CustomerID: "cus_1"}, nil)
+
+		service := &services.CustomerService{Repository: mockCustomers, OTP: mockOTP, Tokens: tokens, Carts: mockCarts}
+		result, err := service.Login(context.Background(), services.LoginRequest{Phone: "9876543210", OTP: "123456", CartToken: "tok"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "cart_1", result.Cart.ID)
+		customerID, err := tokens.Verify(result.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, "cus_1", customerID)
+	})
+
+	t.Run("Login - Disabled Without Secret", func(t *testing.T) {
+		mockOTP := new(MockOTPService)
+
+		service := &services.CustomerService{Repository: new(MockCustomerRepository), OTP: mockOTP, Tokens: &services.CustomerTokens{}}
+		_, err := service.Login(context.Background(), services.LoginRequest{Phone: "9876543210", OTP: "123456"})
+
+		assert.ErrorIs(t, err, services.ErrLoginDisabled)
+		mockOTP.AssertNotCalled(t, "VerifyOTP", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCartController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := &services.CustomerTokens{Secret: []byte("test-secret")}
+
+	t.Run("UpdateCart - Returns Guest Token Header", func(t *testing.T) {
+		mockService := new(MockCartService)
+		mockService.On("UpdateCart", services.CartKey{}, services.CartUpdate{Items: []models.CartItem{{ProductID: "prod1", Quantity: 2}}}).
+			Return(&models.Cart{ID: "cart_1", Token: "tok"}, nil)
+
+		router := gin.New()
+		controller := &controllers.CartController{Service: mockService}
+		router.PUT("/api/cart", middleware.CustomerAuth(tokens), controller.UpdateCart)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/api/cart", bytes.NewBufferString(`{"items": [{"product_id": "prod1", "quantity": 2}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "tok", rr.Header().Get(controllers.CartTokenHeader))
+	})
+
+	t.Run("GetCart - Uses Logged In Customer", func(t *testing.T) {
+		mockService := new(MockCartService)
+		mockService.On("GetCart", services.CartKey{CustomerID: "cus_1"}).Return(&models.Cart{ID: "cart_1", CustomerID: "cus_1"}, nil)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		router := gin.New()
+		controller := &controllers.CartController{Service: mockService}
+		router.GET("/api/cart", middleware.CustomerAuth(tokens), controller.GetCart)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/cart", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("GetCart - Expired Login Is Rejected", func(t *testing.T) {
+		mockService := new(MockCartService)
+
+		router := gin.New()
+		controller := &controllers.CartController{Service: mockService}
+		router.GET("/api/cart", middleware.CustomerAuth(tokens), controller.GetCart)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/cart", strings.NewReader(""))
+		req.Header.Set("Authorization", "Bearer expired")
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockService.AssertNotCalled(t, "GetCart", mock.Anything)
+	})
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"mangal-chai-backend/catalog"
+	"mangal-chai-backend/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	t.Run("Read CSV - Success", func(t *testing.T) {
+		input := "id,name,price,category,stock\n" +
+			"1,Assam,299,Black Tea,0\n" +
+			"2,\"Masala, Chai\",199.5,Masala Chai,25\n"
+
+		products, err := catalog.Read(strings.NewReader(input), catalog.FormatCSV)
+
+		assert.Nil(t, err)
+		assert.Len(t, products, 2)
+		assert.Equal(t, "Masala, Chai", products[1].Name)
+		assert.Equal(t, 199.5, products[1].Price)
+		assert.False(t, products[0].InStock)
+		assert.True(t, products[1].InStock)
+		assert.Equal(t, 25, products[1].Stock)
+	})
+
+	t.Run("Read CSV - Reports Every Invalid Row With Line Numbers", func(t *testing.T) {
+		input := "id,name,price,category\n" +
+			"1,Assam,299,Black Tea\n" +
+			"2,,abc,Black Tea\n" +
+			"1,Duplicate,100,\n"
+
+		products, err := catalog.Read(strings.NewReader(input), catalog.FormatCSV)
+
+		assert.Nil(t, products)
+		var validationErrs catalog.ValidationErrors
+		assert.True(t, errors.As(err, &validationErrs))
+		assert.Contains(t, err.Error(), "line 3: price")
+		assert.Contains(t, err.Error(), "line 4: id: duplicates line 2")
+		assert.Contains(t, err.Error(), "line 4: category: is required")
+	})
+
+	t.Run("Read CSV - Tax Columns", func(t *testing.T) {
+		input := "id,name,price,category,hsn_code,gst_rate\n" +
+			"1,Assam,299,Black Tea,09023020,5\n" +
+			"2,Gift Card,500,Gift Cards,,\n"
+
+		products, err := catalog.Read(strings.NewReader(input), catalog.FormatCSV)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "09023020", products[0].HSNCode)
+		assert.Equal(t, 5.0, products[0].GSTRate)
+		assert.Equal(t, "", products[1].HSNCode)
+		assert.Equal(t, 0.0, products[1].GSTRate)
+	})
+
+	t.Run("Read CSV - Invalid GST Rate", func(t *testing.T) {
+		input := "id,name,price,category,gst_rate\n" +
+			"1,Assam,299,Black Tea,abc\n" +
+			"2,Nilgiri,350,Black Tea,120\n"
+
+		_, err := catalog.Read(strings.NewReader(input), catalog.FormatCSV)
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), `line 2: gst_rate: "abc" is not a number`)
+		assert.Contains(t, err.Error(), "line 3: gst_rate: must be a percentage between 0 and 100")
+	})
+
+	t.Run("Read CSV - Unknown Column", func(t *testing.T) {
+		input := "id,name,price,category,colour\n1,Assam,299,Black Tea,red\n"
+
+		_, err := catalog.Read(strings.NewReader(input), catalog.FormatCSV)
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "line 1: colour: unknown column")
+	})
+
+	t.Run("Read JSON - Line Numbers", func(t *testing.T) {
+		input := `[
+  {"id": "1", "name": "Assam", "price": 299, "category": "Black Tea"},
+  {"id": "2", "name": "Darjeeling", "price": 0, "category": "Black Tea"}
+]`
+
+		_, err := catalog.Read(strings.NewReader(input), catalog.FormatJSON)
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "line 3: price: must be greater than zero")
+	})
+
+	t.Run("Write And Read Round Trip", func(t *testing.T) {
+		products := []models.Product{
+			{ID: "1", Name: "Assam", Description: "Rich, malty", Price: 299, Category: "Black Tea", ImageURL: "https://example.com/a.jpg", InStock: true, Stock: 40, Weight: "100g", HSNCode: "09023020", GSTRate: 5},
+			{ID: "2", Name: "Green", Price: 349, Category: "Green Tea", InStock: false},
+		}
+
+		for _, format := range []catalog.Format{catalog.FormatCSV, catalog.FormatJSON} {
+			var buf bytes.Buffer
+			assert.Nil(t, catalog.Write(&buf, format, products))
+
+			read, err := catalog.Read(&buf, format)
+			assert.Nil(t, err)
+			assert.Equal(t, withStock(products...), read)
+		}
+	})
+
+	t.Run("Read - Stock Left Out", func(t *testing.T) {
+		csvInput := "id,name,price,category,stock\n" +
+			"1,Assam,299,Black Tea,\n" +
+			"2,Green,349,Green Tea,0\n"
+		jsonInput := `[
+  {"id": "1", "name": "Assam", "price": 299, "category": "Black Tea"},
+  {"id": "2", "name": "Green", "price": 349, "category": "Green Tea", "stock": 0}
+]`
+
+		for format, input := range map[catalog.Format]string{catalog.FormatCSV: csvInput, catalog.FormatJSON: jsonInput} {
+			entries, err := catalog.Read(strings.NewReader(input), format)
+
+			assert.Nil(t, err)
+			assert.False(t, entries[0].HasStock)
+			assert.True(t, entries[1].HasStock)
+		}
+
+		entries, err := catalog.Read(strings.NewReader("id,name,price,category\n1,Assam,299,Black Tea\n"), catalog.FormatCSV)
+		assert.Nil(t, err)
+		assert.False(t, entries[0].HasStock)
+	})
+}
@@ -0,0 +1,839 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockGiftCardRepository struct {
+	mock.Mock
+}
+
+func (m *MockGiftCardRepository) CreateGiftCard(ctx context.Context, card models.GiftCard) error {
+	args := m.Called(card)
+	return args.Error(0)
+}
+
+func (m *MockGiftCardRepository) GetGiftCard(ctx context.Context, code string) (*models.GiftCard, error) {
+	args := m.Called(code)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.GiftCard), args.Error(1)
+}
+
+func (m *MockGiftCardRepository) Debit(ctx context.Context, code string, amount float64, at time.Time) (*models.GiftCard, error) {
+	args := m.Called(code, amount, at)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.GiftCard), args.Error(1)
+}
+
+func (m *MockGiftCardRepository) Credit(ctx context.Context, code string, amount float64) (*models.GiftCard, error) {
+	args := m.Called(code, amount)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.GiftCard), args.Error(1)
+}
+
+func (m *MockGiftCardRepository) ListOrderGiftCards(ctx context.Context, orderID string) ([]models.GiftCard, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.GiftCard), args.Error(1)
+}
+
+func (m *MockGiftCardRepository) VoidGiftCard(ctx context.Context, code string, at time.Time) (*models.GiftCard, error) {
+	args := m.Called(code, at)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.GiftCard), args.Error(1)
+}
+
+func (m *MockGiftCardRepository) ReinstateGiftCard(ctx context.Context, code string) (*models.GiftCard, error) {
+	args := m.Called(code)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.GiftCard), args.Error(1)
+}
+
+type MockWalletRepository struct {
+	mock.Mock
+}
+
+func (m *MockWalletRepository) GetWallet(ctx context.Context, customerID string) (*models.Wallet, error) {
+	args := m.Called(customerID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Wallet), args.Error(1)
+}
+
+func (m *MockWalletRepository) Debit(ctx context.Context, customerID string, amount float64) (*models.Wallet, error) {
+	args := m.Called(customerID, amount)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Wallet), args.Error(1)
+}
+
+func (m *MockWalletRepository) Credit(ctx context.Context, customerID string, amount float64) (*models.Wallet, error) {
+	args := m.Called(customerID, amount)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Wallet), args.Error(1)
+}
+
+type MockLedgerRepository struct {
+	mock.Mock
+}
+
+func (m *MockLedgerRepository) Record(ctx context.Context, entry models.LedgerEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockLedgerRepository) ListEntries(ctx context.Context, account string, accountID string, limit int64) ([]models.LedgerEntry, error) {
+	args := m.Called(account, accountID, limit)
+	return args.Get(0).([]models.LedgerEntry), args.Error(1)
+}
+
+type MockGiftCardNotifier struct {
+	mock.Mock
+}
+
+func (m *MockGiftCardNotifier) NotifyGiftCard(ctx context.Context, card models.GiftCard, link string) (bool, error) {
+	args := m.Called(card, link)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockStoredValue struct {
+	mock.Mock
+}
+
+func (m *MockStoredValue) RedeemGiftCard(ctx context.Context, code string, upTo float64, orderID string) (float64, error) {
+	args := m.Called(code, upTo, orderID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockStoredValue) SpendWallet(ctx context.Context, customerID string, upTo float64, orderID string) (float64, error) {
+	args := m.Called(customerID, upTo, orderID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockStoredValue) Credit(ctx context.Context, entry models.LedgerEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+type MockGiftCardVoider struct {
+	mock.Mock
+}
+
+func (m *MockGiftCardVoider) OrderGiftCards(ctx context.Context, orderID string) ([]models.GiftCard, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.GiftCard), args.Error(1)
+}
+
+func (m *MockGiftCardVoider) VoidGiftCards(ctx context.Context, orderID string, refundID string, codes []string) error {
+	args := m.Called(orderID, refundID, codes)
+	return args.Error(0)
+}
+
+func (m *MockGiftCardVoider) ReinstateGiftCards(ctx context.Context, orderID string, refundID string, codes []string) {
+	m.Called(orderID, refundID, codes)
+}
+
+type MockGiftCardService struct {
+	mock.Mock
+}
+
+func (m *MockGiftCardService) IssueGiftCard(ctx context.Context, request services.IssueGiftCardRequest) (*models.GiftCard, error) {
+	args := m.Called(request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.GiftCard), args.Error(1)
+}
+
+func (m *MockGiftCardService) GetBalance(ctx context.Context, code string) (*services.GiftCardBalance, error) {
+	args := m.Called(code)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.GiftCardBalance), args.Error(1)
+}
+
+func (m *MockGiftCardService) GetWallet(ctx context.Context, customerID string) (*services.WalletStatement, error) {
+	args := m.Called(customerID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.WalletStatement), args.Error(1)
+}
+
+func (m *MockGiftCardService) ListLedger(ctx context.Context, account string, accountID string) ([]models.LedgerEntry, error) {
+	args := m.Called(account, accountID)
+	return args.Get(0).([]models.LedgerEntry), args.Error(1)
+}
+
+func TestGiftCardService(t *testing.T) {
+	nextYear := time.Now().Add(365 * 24 * time.Hour)
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+
+	t.Run("IssueGiftCard - Issues, Records And Sends", func(t *testing.T) {
+		mockCards := new(MockGiftCardRepository)
+		mockLedger := new(MockLedgerRepository)
+		mockNotifier := new(MockGiftCardNotifier)
+		mockCards.On("CreateGiftCard", mock.MatchedBy(func(card models.GiftCard) bool {
+			return len(card.Code) == len("MC-XXXX-XXXX-XXXX") && card.Balance == 2000.0 && card.InitialBalance == 2000.0 &&
+				card.Source == models.GiftCardSourceAdmin && card.RecipientEmail == "priya@example.com" && card.ExpiresAt != nil
+		})).Return(nil)
+		mockLedger.On("Record", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Account == models.LedgerAccountGiftCard && entry.Kind == models.LedgerKindIssued &&
+				entry.Amount == 2000.0 && entry.Balance == 2000.0
+		})).Return(nil)
+		mockNotifier.On("NotifyGiftCard", mock.Anything, "https://shop.example.com").Return(true, nil)
+
+		service := &services.GiftCardService{
+			Repository: mockCards,
+			Ledger:     mockLedger,
+			Notifiers:  []services.GiftCardNotifier{mockNotifier},
+			ShopURL:    "https://shop.example.com",
+		}
+		card, err := service.IssueGiftCard(context.Background(), services.IssueGiftCardRequest{Amount: 2000, RecipientName: "Priya", RecipientEmail: " Priya@Example.com "})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Priya", card.RecipientName)
+		mockCards.AssertExpectations(t)
+		mockLedger.AssertExpectations(t)
+		mockNotifier.AssertExpectations(t)
+	})
+
+	t.Run("IssueGiftCard - Invalid Amount", func(t *testing.T) {
+		service := &services.GiftCardService{Repository: new(MockGiftCardRepository)}
+
+		_, err := service.IssueGiftCard(context.Background(), services.IssueGiftCardRequest{Amount: -100})
+		assert.True(t, errors.Is(err, services.ErrInvalidGiftCardRequest))
+
+		_, err = service.IssueGiftCard(context.Background(), services.IssueGiftCardRequest{Amount: services.MaxGiftCardAmount + 1})
+		assert.True(t, errors.Is(err, services.ErrInvalidGiftCardRequest))
+	})
+
+	t.Run("RedeemGiftCard - Spends Up To The Balance", func(t *testing.T) {
+		mockCards := new(MockGiftCardRepository)
+		mockLedger := new(MockLedgerRepository)
+		mockCards.On("GetGiftCard", "MC-AAAA-BBBB-CCCC").Return(&models.GiftCard{Code: "MC-AAAA-BBBB-CCCC", Balance: 500.0, ExpiresAt: &nextYear}, nil)
+		mockCards.On("Debit", "MC-AAAA-BBBB-CCCC", 500.0, mock.Anything).Return(&models.GiftCard{Code: "MC-AAAA-BBBB-CCCC", Balance: 0}, nil)
+		mockLedger.On("Record", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Kind == models.LedgerKindSpent && entry.Amount == -500.0 && entry.Balance == 0 && entry.OrderID == "ord_1"
+		})).Return(nil)
+
+		service := &services.GiftCardService{Repository: mockCards, Ledger: mockLedger}
+		spent, err := service.RedeemGiftCard(context.Background(), "mc-aaaa-bbbb-cccc", 800.0, "ord_1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 500.0, spent)
+		mockLedger.AssertExpectations(t)
+	})
+
+	t.Run("RedeemGiftCard - Expired Or Empty", func(t *testing.T) {
+		mockCards := new(MockGiftCardRepository)
+		mockCards.On("GetGiftCard", "MC-OLD").Return(&models.GiftCard{Code: "MC-OLD", Balance: 500.0, ExpiresAt: &lastWeek}, nil)
+		mockCards.On("GetGiftCard", "MC-EMPTY").Return(&models.GiftCard{Code: "MC-EMPTY", Balance: 0, ExpiresAt: &nextYear}, nil)
+		mockCards.On("GetGiftCard", "MC-NONE").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.GiftCardService{Repository: mockCards}
+		for _, code := range []string{"MC-OLD", "MC-EMPTY", "MC-NONE"} {
+			_, err := service.RedeemGiftCard(context.Background(), code, 800.0, "ord_1")
+			assert.True(t, errors.Is(err, services.ErrInvalidGiftCard), code)
+		}
+		mockCards.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("SpendWallet - No Wallet Spends Nothing", func(t *testing.T) {
+		mockWallets := new(MockWalletRepository)
+		mockWallets.On("GetWallet", "cus_1").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.GiftCardService{Wallets: mockWallets}
+		spent, err := service.SpendWallet(context.Background(), "cus_1", 300.0, "ord_1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0.0, spent)
+		mockWallets.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Credit - Records Balance After", func(t *testing.T) {
+		mockWallets := new(MockWalletRepository)
+		mockLedger := new(MockLedgerRepository)
+		mockWallets.On("Credit", "cus_1", 250.0).Return(&models.Wallet{CustomerID: "cus_1", Balance: 400.0}, nil)
+		mockLedger.On("Record", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Kind == models.LedgerKindRefund && entry.Amount == 250.0 && entry.Balance == 400.0 && entry.RefundID == "rfd_1"
+		})).Return(nil)
+
+		service := &services.GiftCardService{Wallets: mockWallets, Ledger: mockLedger}
+		err := service.Credit(context.Background(), models.LedgerEntry{Account: models.LedgerAccountWallet, AccountID: "cus_1", Kind: models.LedgerKindRefund, Amount: 250.0, RefundID: "rfd_1"})
+
+		assert.Nil(t, err)
+		mockLedger.AssertExpectations(t)
+	})
+
+	t.Run("HandleOrderEvent - Issues A Card Per Purchased Unit", func(t *testing.T) {
+		mockCards := new(MockGiftCardRepository)
+		mockLedger := new(MockLedgerRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "gift1000").Return(&models.Product{ID: "gift1000", Category: models.GiftCardCategory, Price: 1000.0}, nil)
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", Category: "Black Tea", Price: 350.0}, nil)
+		var lines []int
+		mockCards.On("CreateGiftCard", mock.MatchedBy(func(card models.GiftCard) bool {
+			return card.OrderID == "ord_1" && card.InitialBalance == 900.0 && card.Source == models.GiftCardSourcePurchase &&
+				card.RecipientEmail == "hr@example.com" && card.ProductID == "gift1000"
+		})).Run(func(args mock.Arguments) {
+			lines = append(lines, args.Get(0).(models.GiftCard).OrderLine)
+		}).Return(nil).Once()
+		// The second card was issued by an earlier attempt.
+		mockCards.On("CreateGiftCard", mock.Anything).Return(mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}).Once()
+		mockLedger.On("Record", mock.Anything).Return(nil)
+
+		service := &services.GiftCardService{Repository: mockCards, Ledger: mockLedger, Products: mockProductRepo}
+		order := models.Order{
+			ID:           "ord_1",
+			CustomerInfo: models.CustomerInfo{Name: "Acme HR", Email: "hr@example.com"},
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1, Price: 350.0}, {ProductID: "gift1000", Quantity: 2, Price: 900.0}},
+		}
+
+		assert.Nil(t, service.HandleOrderEvent(context.Background(), models.OrderEventPlaced, order))
+		mockCards.AssertNotCalled(t, "CreateGiftCard", mock.Anything)
+
+		err := service.HandleOrderEvent(context.Background(), models.OrderEventPaymentConfirmed, order)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1}, lines)
+		mockCards.AssertNumberOfCalls(t, "CreateGiftCard", 2)
+		mockLedger.AssertNumberOfCalls(t, "Record", 1)
+	})
+
+	t.Run("HandleOrderEvent - Cancelled Order Issues No Cards", func(t *testing.T) {
+		mockCards := new(MockGiftCardRepository)
+		mockProductRepo := new(MockProductRepository)
+
+		service := &services.GiftCardService{Repository: mockCards, Products: mockProductRepo}
+		order := models.Order{ID: "ord_1", Status: models.OrderStatusCancelled, Items: []models.CartItem{{ProductID: "gift1000", Quantity: 1, Price: 1000.0}}}
+		err := service.HandleOrderEvent(context.Background(), models.OrderEventPaymentConfirmed, order)
+
+		assert.Nil(t, err)
+		mockCards.AssertNotCalled(t, "CreateGiftCard", mock.Anything)
+	})
+
+	t.Run("VoidGiftCards - Used Card Reinstates The Others", func(t *testing.T) {
+		mockCards := new(MockGiftCardRepository)
+		mockLedger := new(MockLedgerRepository)
+		mockCards.On("VoidGiftCard", "MC-AAAA-AAAA-AAAA", mock.Anything).Return(&models.GiftCard{Code: "MC-AAAA-AAAA-AAAA", InitialBalance: 1000.0}, nil)
+		mockCards.On("VoidGiftCard", "MC-BBBB-BBBB-BBBB", mock.Anything).Return(nil, mongo.ErrNoDocuments)
+		mockCards.On("ReinstateGiftCard", "MC-AAAA-AAAA-AAAA").Return(&models.GiftCard{Code: "MC-AAAA-AAAA-AAAA", InitialBalance: 1000.0, Balance: 1000.0}, nil)
+		mockLedger.On("Record", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Kind == models.LedgerKindVoided && entry.Amount == -1000.0 && entry.RefundID == "rfd_1"
+		})).Return(nil).Once()
+		mockLedger.On("Record", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Kind == models.LedgerKindReinstated && entry.Amount == 1000.0 && entry.Balance == 1000.0
+		})).Return(nil).Once()
+
+		service := &services.GiftCardService{Repository: mockCards, Ledger: mockLedger}
+		err := service.VoidGiftCards(context.Background(), "ord_1", "rfd_1", []string{"MC-AAAA-AAAA-AAAA", "MC-BBBB-BBBB-BBBB"})
+
+		assert.True(t, errors.Is(err, services.ErrGiftCardUsed))
+		mockCards.AssertExpectations(t)
+		mockLedger.AssertExpectations(t)
+	})
+}
+
+func TestGiftCardCheckout(t *testing.T) {
+	product := &models.Product{ID: "prod1", Name: "Diwali Hamper", Price: 1200.0, InStock: true, Stock: 5}
+	request := services.CreateOrderRequest{
+		CustomerInfo: models.CustomerInfo{Name: "Asha"},
+		Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+		GiftCardCode: " mc-aaaa-bbbb-cccc ",
+		UseWallet:    true,
+		CustomerID:   "cus_1",
+	}
+
+	t.Run("CreateOrder - Gift Card And Wallet Pay Part", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockStoredValue := new(MockStoredValue)
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockStoredValue.On("RedeemGiftCard", "MC-AAAA-BBBB-CCCC", 1200.0, mock.Anything).Return(500.0, nil)
+		mockStoredValue.On("SpendWallet", "cus_1", 700.0, mock.Anything).Return(200.0, nil)
+		mockOrderRepo.On("CreateOrder", mock.MatchedBy(func(order models.Order) bool {
+			return order.Status == models.OrderStatusPending && order.PaymentStatus == "" && len(order.Outbox) == 1 &&
+				order.GiftCardCode == "MC-AAAA-BBBB-CCCC" && order.GiftCardAmount == 500.0 && order.StoreCreditAmount == 200.0
+		})).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, StoredValue: mockStoredValue}
+		order, err := service.CreateOrder(context.Background(), request)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1200.0, order.TotalAmount)
+		assert.Equal(t, 500.0, services.AmountDue(order))
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("CreateOrder - Paid In Full Is Confirmed", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockStoredValue := new(MockStoredValue)
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockStoredValue.On("RedeemGiftCard", "MC-AAAA-BBBB-CCCC", 1200.0, mock.Anything).Return(1200.0, nil)
+		mockOrderRepo.On("CreateOrder", mock.MatchedBy(func(order models.Order) bool {
+			return order.Status == models.OrderStatusConfirmed && order.PaymentStatus == models.PaymentStatusPaid &&
+				order.PaymentMethod == models.PaymentMethodGiftCard && len(order.Outbox) == 2 &&
+				order.Outbox[1].Payload["event"] == models.OrderEventPaymentConfirmed
+		})).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, StoredValue: mockStoredValue}
+		_, err := service.CreateOrder(context.Background(), request)
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockStoredValue.AssertNotCalled(t, "SpendWallet", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("CreateOrder - Invalid Gift Card Releases Stock", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockStoredValue := new(MockStoredValue)
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 1).Return(nil)
+		mockStoredValue.On("RedeemGiftCard", "MC-AAAA-BBBB-CCCC", 1200.0, mock.Anything).Return(0.0, services.ErrInvalidGiftCard)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, StoredValue: mockStoredValue}
+		_, err := service.CreateOrder(context.Background(), request)
+
+		assert.True(t, errors.Is(err, services.ErrInvalidGiftCard))
+		mockProductRepo.AssertExpectations(t)
+		mockOrderRepo.AssertNotCalled(t, "CreateOrder", mock.Anything)
+	})
+
+	t.Run("CreateOrder - Failed Save Returns Stored Value", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockStoredValue := new(MockStoredValue)
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 1).Return(nil)
+		mockStoredValue.On("RedeemGiftCard", "MC-AAAA-BBBB-CCCC", 1200.0, mock.Anything).Return(500.0, nil)
+		mockStoredValue.On("SpendWallet", "cus_1", 700.0, mock.Anything).Return(200.0, nil)
+		mockStoredValue.On("Credit", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Account == models.LedgerAccountGiftCard && entry.AccountID == "MC-AAAA-BBBB-CCCC" && entry.Amount == 500.0 &&
+				entry.Kind == models.LedgerKindReleased
+		})).Return(nil)
+		mockStoredValue.On("Credit", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Account == models.LedgerAccountWallet && entry.AccountID == "cus_1" && entry.Amount == 200.0
+		})).Return(nil)
+		mockOrderRepo.On("CreateOrder", mock.Anything).Return(errors.New("db down"))
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, StoredValue: mockStoredValue}
+		_, err := service.CreateOrder(context.Background(), request)
+
+		assert.NotNil(t, err)
+		mockStoredValue.AssertExpectations(t)
+	})
+
+	t.Run("CreateOrder - Coupon Does Not Discount Gift Cards", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockCoupons := new(MockCouponRepository)
+		giftCard := &models.Product{ID: "gift1000", Name: "Gift Card ₹1000", Category: models.GiftCardCategory, Price: 1000.0, InStock: true, Stock: 100}
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("GetProduct", "gift1000").Return(giftCard, nil)
+		mockProductRepo.On("ReserveStock", mock.Anything, 1).Return(nil)
+		mockCoupons.On("Redeem", "DIWALI10", mock.Anything, mock.Anything).Return(&models.Coupon{Code: "DIWALI10", PercentOff: 10}, nil)
+		mockOrderRepo.On("CreateOrder", mock.Anything).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Coupons: mockCoupons}
+		order, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			CustomerInfo: models.CustomerInfo{Name: "Asha"},
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}, {ProductID: "gift1000", Quantity: 1}},
+			CouponCode:   "DIWALI10",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 120.0, order.Discount)
+		assert.Equal(t, 2080.0, order.TotalAmount)
+	})
+
+	t.Run("CreateOrder - Coupon On Gift Cards Alone Is Rejected", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockCoupons := new(MockCouponRepository)
+		giftCard := &models.Product{ID: "gift1000", Name: "Gift Card ₹1000", Category: models.GiftCardCategory, Price: 1000.0, InStock: true, Stock: 100}
+		mockProductRepo.On("GetProduct", "gift1000").Return(giftCard, nil)
+		mockProductRepo.On("ReserveStock", "gift1000", 1).Return(nil)
+		mockProductRepo.On("ReleaseStock", "gift1000", 1).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Coupons: mockCoupons}
+		_, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			CustomerInfo: models.CustomerInfo{Name: "Asha"},
+			Items:        []models.CartItem{{ProductID: "gift1000", Quantity: 1}},
+			CouponCode:   "DIWALI10",
+		})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidCoupon))
+		mockCoupons.AssertNotCalled(t, "Redeem", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("CreateOrder - Points Do Not Pay For Gift Cards", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockLoyalty := new(MockPointsRedeemer)
+		giftCard := &models.Product{ID: "gift1000", Name: "Gift Card ₹1000", Category: models.GiftCardCategory, Price: 1000.0, InStock: true, Stock: 100}
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("GetProduct", "gift1000").Return(giftCard, nil)
+		mockProductRepo.On("ReserveStock", mock.Anything, 1).Return(nil)
+		mockLoyalty.On("RedeemPoints", "cus_1", 5000, 1200.0, mock.Anything).Return(2400, 1200.0, nil)
+		mockOrderRepo.On("CreateOrder", mock.Anything).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Loyalty: mockLoyalty}
+		order, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}, {ProductID: "gift1000", Quantity: 1}},
+			RedeemPoints: 5000,
+			CustomerID:   "cus_1",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1000.0, order.TotalAmount)
+		mockLoyalty.AssertExpectations(t)
+	})
+
+	t.Run("CreateRazorpayOrder - Charges What Is Left", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockGateway := new(MockPaymentGateway)
+		order := &models.Order{ID: "ord_1", TotalAmount: 1200.0, GiftCardAmount: 500.0, StoreCreditAmount: 200.0}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockGateway.On("CreateOrder", int64(50000), "INR", "ord_1").Return(map[string]interface{}{"id": "order_gw1"}, nil)
+		mockOrderRepo.On("SetPaymentGatewayOrder", "ord_1", "order_gw1", int64(50000)).Return(nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		_, err := service.CreateRazorpayOrder(context.Background(), services.CreateRazorpayOrderRequest{OrderID: "ord_1"})
+
+		assert.Nil(t, err)
+		mockGateway.AssertExpectations(t)
+	})
+
+	t.Run("CreateRazorpayOrder - Nothing To Pay", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockGateway := new(MockPaymentGateway)
+		order := &models.Order{ID: "ord_1", TotalAmount: 1200.0, GiftCardAmount: 1200.0, PaymentStatus: models.PaymentStatusPaid}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		_, err := service.CreateRazorpayOrder(context.Background(), services.CreateRazorpayOrderRequest{OrderID: "ord_1"})
+
+		assert.True(t, errors.Is(err, services.ErrNothingToPay))
+		mockGateway.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestStoreCreditRefunds(t *testing.T) {
+	t.Run("IssueRefund - Order Paid With Store Value Goes To Wallet", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+		mockStoredValue := new(MockStoredValue)
+		order := &models.Order{ID: "ord_1", CustomerID: "cus_1", TotalAmount: 600.0, GiftCardCode: "MC-AAAA-BBBB-CCCC", GiftCardAmount: 600.0,
+			PaymentStatus: models.PaymentStatusPaid, PaymentMethod: models.PaymentMethodGiftCard}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{}, nil)
+		mockOrderRepo.On("ReserveRefund", "ord_1", 600.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.MatchedBy(func(refund models.Refund) bool {
+			return refund.Method == models.RefundMethodStoreCredit && refund.Amount == 600.0 && refund.PaymentID == ""
+		})).Return(nil)
+		mockStoredValue.On("Credit", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Account == models.LedgerAccountWallet && entry.AccountID == "cus_1" && entry.Amount == 600.0 &&
+				entry.Kind == models.LedgerKindRefund && entry.RefundID != ""
+		})).Return(nil)
+		mockRefundRepo.On("UpdateRefundStatus", mock.Anything, models.RefundStatusProcessed, "").Return(nil)
+		mockOrderRepo.On("SetRefund", "ord_1", "", models.PaymentStatusRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway, StoredValue: mockStoredValue}
+		refund, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{Reason: "Damaged"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.RefundStatusProcessed, refund.Status)
+		mockStoredValue.AssertExpectations(t)
+		mockGateway.AssertNotCalled(t, "Refund", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("IssueRefund - Gateway Refunds Only The Online Payment", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+		order := &models.Order{ID: "ord_1", TotalAmount: 1200.0, GiftCardCode: "MC-AAAA-BBBB-CCCC", GiftCardAmount: 500.0,
+			PaymentID: "pay_1", PaymentStatus: models.PaymentStatusPaid}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{}, nil)
+		mockOrderRepo.On("ReserveRefund", "ord_1", 700.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.MatchedBy(func(refund models.Refund) bool {
+			return refund.Method == models.RefundMethodGateway && refund.Amount == 700.0 && refund.PaymentID == "pay_1"
+		})).Return(nil)
+		mockGateway.On("Refund", "pay_1", int64(70000), mock.Anything).Return(&services.GatewayRefund{ID: "rfnd_1", Status: "processed"}, nil)
+		mockRefundRepo.On("SetGatewayRefund", mock.Anything, "rfnd_1", models.RefundStatusProcessed).Return(nil)
+		mockOrderRepo.On("SetRefund", "ord_1", "rfnd_1", models.PaymentStatusPartiallyRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway}
+		_, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{Reason: "Cancelled"})
+
+		assert.Nil(t, err)
+		mockGateway.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Guest Store Credit Goes Back On The Gift Card", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockStoredValue := new(MockStoredValue)
+		order := &models.Order{ID: "ord_1", TotalAmount: 1200.0, GiftCardCode: "MC-AAAA-BBBB-CCCC", GiftCardAmount: 500.0,
+			PaymentID: "pay_1", PaymentStatus: models.PaymentStatusPartiallyRefunded}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{
+			{ID: "rfd_0", Method: models.RefundMethodGateway, Amount: 700.0, Status: models.RefundStatusProcessed},
+		}, nil)
+		mockOrderRepo.On("ReserveRefund", "ord_1", 500.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.MatchedBy(func(refund models.Refund) bool {
+			return refund.Method == models.RefundMethodGiftCard && refund.Amount == 500.0
+		})).Return(nil)
+		mockStoredValue.On("Credit", mock.MatchedBy(func(entry models.LedgerEntry) bool {
+			return entry.Account == models.LedgerAccountGiftCard && entry.AccountID == "MC-AAAA-BBBB-CCCC" && entry.Amount == 500.0
+		})).Return(nil)
+		mockRefundRepo.On("UpdateRefundStatus", mock.Anything, models.RefundStatusProcessed, "").Return(nil)
+		mockOrderRepo.On("SetRefund", "ord_1", "", models.PaymentStatusRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: new(MockPaymentGateway), StoredValue: mockStoredValue}
+		_, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{Reason: "Cancelled", StoreCredit: true})
+
+		assert.Nil(t, err)
+		mockStoredValue.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Guest Without Gift Card Cannot Get Store Credit", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		order := &models.Order{ID: "ord_1", TotalAmount: 1200.0, PaymentID: "pay_1", PaymentStatus: models.PaymentStatusPaid}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{}, nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, StoredValue: new(MockStoredValue)}
+		_, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{Reason: "Goodwill", StoreCredit: true})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidRefund))
+		mockRefundRepo.AssertNotCalled(t, "CreateRefund", mock.Anything)
+	})
+}
+
+func TestGiftCardRefunds(t *testing.T) {
+	order := &models.Order{
+		ID:            "ord_1",
+		CustomerID:    "cus_1",
+		Items:         []models.CartItem{{ProductID: "prod1", Quantity: 1, Price: 1200.0}, {ProductID: "gift1000", Quantity: 2, Price: 1000.0}},
+		Discount:      120.0,
+		TotalAmount:   3080.0,
+		PaymentID:     "pay_1",
+		PaymentStatus: models.PaymentStatusPaid,
+	}
+	cards := []models.GiftCard{
+		{Code: "MC-AAAA-AAAA-AAAA", ProductID: "gift1000", OrderLine: 1, InitialBalance: 1000.0, Balance: 400.0},
+		{Code: "MC-BBBB-BBBB-BBBB", ProductID: "gift1000", OrderLine: 2, InitialBalance: 1000.0, Balance: 1000.0},
+	}
+
+	t.Run("IssueRefund - Gift Card Line Voids An Unspent Card At Its Full Price", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+		mockGiftCards := new(MockGiftCardVoider)
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{}, nil)
+		mockGiftCards.On("OrderGiftCards", "ord_1").Return(cards, nil)
+		mockGiftCards.On("VoidGiftCards", "ord_1", mock.Anything, []string{"MC-BBBB-BBBB-BBBB"}).Return(nil)
+		mockOrderRepo.On("ReserveRefund", "ord_1", 1000.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.MatchedBy(func(refund models.Refund) bool {
+			return refund.Amount == 1000.0 && assert.ObjectsAreEqual([]string{"MC-BBBB-BBBB-BBBB"}, refund.GiftCardCodes)
+		})).Return(nil)
+		mockGateway.On("Refund", "pay_1", int64(100000), mock.Anything).Return(&services.GatewayRefund{ID: "rfnd_1", Status: "processed"}, nil)
+		mockRefundRepo.On("SetGatewayRefund", mock.Anything, "rfnd_1", models.RefundStatusProcessed).Return(nil)
+		mockOrderRepo.On("SetRefund", "ord_1", "rfnd_1", models.PaymentStatusPartiallyRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway, GiftCards: mockGiftCards}
+		_, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{
+			Items:  []models.CartItem{{ProductID: "gift1000", Quantity: 1}},
+			Reason: "Bought by mistake",
+		})
+
+		assert.Nil(t, err)
+		mockGiftCards.AssertExpectations(t)
+		mockGateway.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Used Gift Card Is Rejected", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGiftCards := new(MockGiftCardVoider)
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{}, nil)
+		mockGiftCards.On("OrderGiftCards", "ord_1").Return(cards, nil)
+		mockGiftCards.On("VoidGiftCards", "ord_1", mock.Anything, []string{"MC-BBBB-BBBB-BBBB", "MC-AAAA-AAAA-AAAA"}).
+			Return(fmt.Errorf("%w: MC-AAAA-AAAA-AAAA", services.ErrGiftCardUsed))
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: new(MockPaymentGateway), GiftCards: mockGiftCards}
+		_, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{
+			Items:  []models.CartItem{{ProductID: "gift1000", Quantity: 2}},
+			Reason: "Bought by mistake",
+		})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidRefund))
+		mockOrderRepo.AssertNotCalled(t, "ReserveRefund", mock.Anything, mock.Anything)
+		mockRefundRepo.AssertNotCalled(t, "CreateRefund", mock.Anything)
+	})
+
+	t.Run("IssueRefund - Full Refund Voids Every Card And A Failure Reinstates Them", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+		mockGiftCards := new(MockGiftCardVoider)
+		unspent := []models.GiftCard{cards[1], {Code: "MC-CCCC-CCCC-CCCC", ProductID: "gift1000", OrderLine: 3, InitialBalance: 1000.0, Balance: 1000.0}}
+		codes := []string{"MC-BBBB-BBBB-BBBB", "MC-CCCC-CCCC-CCCC"}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{}, nil)
+		mockGiftCards.On("OrderGiftCards", "ord_1").Return(unspent, nil)
+		mockGiftCards.On("VoidGiftCards", "ord_1", mock.Anything, codes).Return(nil)
+		mockOrderRepo.On("ReserveRefund", "ord_1", 3080.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.Anything).Return(nil)
+		mockGateway.On("Refund", "pay_1", int64(308000), mock.Anything).Return(nil, errors.New("gateway unavailable"))
+		mockRefundRepo.On("UpdateRefundStatus", mock.Anything, models.RefundStatusFailed, mock.Anything).Return(nil)
+		mockOrderRepo.On("ReleaseRefund", "ord_1", 3080.0).Return(nil)
+		mockGiftCards.On("ReinstateGiftCards", "ord_1", mock.Anything, codes).Return()
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway, GiftCards: mockGiftCards}
+		_, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{Reason: "Cancelled"})
+
+		assert.True(t, errors.Is(err, services.ErrGatewayRefundFailed))
+		mockGiftCards.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Discount Is Spread Over Items Other Than Gift Cards", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+		mockGiftCards := new(MockGiftCardVoider)
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{}, nil)
+		mockGiftCards.On("OrderGiftCards", "ord_1").Return(cards, nil)
+		mockOrderRepo.On("ReserveRefund", "ord_1", 1080.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.Anything).Return(nil)
+		mockGateway.On("Refund", "pay_1", int64(108000), mock.Anything).Return(&services.GatewayRefund{ID: "rfnd_1", Status: "processed"}, nil)
+		mockRefundRepo.On("SetGatewayRefund", mock.Anything, "rfnd_1", models.RefundStatusProcessed).Return(nil)
+		mockOrderRepo.On("SetRefund", "ord_1", "rfnd_1", models.PaymentStatusPartiallyRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway, GiftCards: mockGiftCards}
+		refund, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{
+			Items:  []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			Reason: "Damaged",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1080.0, refund.Amount)
+		mockGiftCards.AssertNotCalled(t, "VoidGiftCards", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestGiftCardController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := &services.CustomerTokens{Secret: []byte("test-secret")}
+
+	newRouter := func(service services.GiftCardServiceInterface) *gin.Engine {
+		router := gin.New()
+		controller := &controllers.GiftCardController{Service: service}
+		router.GET("/api/gift-cards/:code", controller.GetBalance)
+		router.GET("/api/wallet", middleware.CustomerAuth(tokens), controller.GetWallet)
+		router.POST("/api/admin/gift-cards", controller.IssueGiftCard)
+		return router
+	}
+
+	t.Run("GetBalance - Not Found", func(t *testing.T) {
+		mockService := new(MockGiftCardService)
+		mockService.On("GetBalance", "MC-NONE").Return(nil, services.ErrGiftCardNotFound)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/gift-cards/MC-NONE", nil)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("GetWallet - Requires Login", func(t *testing.T) {
+		mockService := new(MockGiftCardService)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/wallet", nil)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockService.AssertNotCalled(t, "GetWallet", mock.Anything)
+	})
+
+	t.Run("GetWallet - Success", func(t *testing.T) {
+		mockService := new(MockGiftCardService)
+		mockService.On("GetWallet", "cus_1").Return(&services.WalletStatement{Balance: 250.0, Entries: []models.LedgerEntry{}}, nil)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/wallet", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"balance":250`)
+	})
+
+	t.Run("IssueGiftCard - Invalid", func(t *testing.T) {
+		mockService := new(MockGiftCardService)
+		mockService.On("IssueGiftCard", services.IssueGiftCardRequest{Amount: 50000}).Return(nil, services.ErrInvalidGiftCardRequest)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/gift-cards", bytes.NewBufferString(`{"amount": 50000}`))
+		req.Header.Set("Content-Type", "application/json")
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
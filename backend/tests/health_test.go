@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/health"
+	"mangal-chai-backend/jobs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func passing(details any) health.CheckFunc {
+	return func(ctx context.Context) (any, error) { return details, nil }
+}
+
+func failing(message string) health.CheckFunc {
+	return func(ctx context.Context) (any, error) { return nil, errors.New(message) }
+}
+
+func TestHealthChecker(t *testing.T) {
+	t.Run("Check - All Healthy", func(t *testing.T) {
+		checker := &health.Checker{Components: []health.Component{
+			{Name: "mongo", Critical: true, Check: passing(nil)},
+			{Name: "payment_gateway", Check: passing(map[string]any{"mode": "test"})},
+		}}
+
+		report := checker.Check(context.Background())
+
+		assert.True(t, report.Ready())
+		assert.Equal(t, health.StatusOK, report.Status)
+		assert.Equal(t, health.StatusOK, report.Components["mongo"].Status)
+		assert.Equal(t, map[string]any{"mode": "test"}, report.Components["payment_gateway"].Details)
+	})
+
+	t.Run("Check - Non-Critical Failure Degrades", func(t *testing.T) {
+		checker := &health.Checker{Components: []health.Component{
+			{Name: "mongo", Critical: true, Check: passing(nil)},
+			{Name: "workers", Check: failing("no heartbeat")},
+		}}
+
+		report := checker.Check(context.Background())
+
+		assert.True(t, report.Ready())
+		assert.Equal(t, health.StatusDegraded, report.Status)
+		assert.Equal(t, health.StatusFailing, report.Components["workers"].Status)
+		assert.Equal(t, "no heartbeat", report.Components["workers"].Error)
+	})
+
+	t.Run("Check - Critical Failure Is Unavailable", func(t *testing.T) {
+		checker := &health.Checker{Components: []health.Component{
+			{Name: "mongo", Critical: true, Check: failing("connection refused")},
+			{Name: "workers", Check: failing("no heartbeat")},
+		}}
+
+		report := checker.Check(context.Background())
+
+		assert.False(t, report.Ready())
+		assert.Equal(t, health.StatusUnavailable, report.Status)
+	})
+
+	t.Run("Check - Hung Check Times Out", func(t *testing.T) {
+		hung := make(chan struct{})
+		defer close(hung)
+		checker := &health.Checker{Timeout: 10 * time.Millisecond, Components: []health.Component{
+			{Name: "migrations", Critical: true, Check: func(ctx context.Context) (any, error) {
+				<-hung
+				return nil, nil
+			}},
+		}}
+
+		report := checker.Check(context.Background())
+
+		assert.False(t, report.Ready())
+		assert.Contains(t, report.Components["migrations"].Error, "timed out")
+	})
+
+	t.Run("Check - Reuses The Report Within CacheFor", func(t *testing.T) {
+		calls := 0
+		checker := &health.Checker{CacheFor: time.Hour, Components: []health.Component{
+			{Name: "mongo", Critical: true, Check: func(ctx context.Context) (any, error) {
+				calls++
+				return nil, nil
+			}},
+		}}
+
+		first := checker.Check(context.Background())
+		second := checker.Check(context.Background())
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, first.CheckedAt, second.CheckedAt)
+
+		checker = &health.Checker{CacheFor: time.Millisecond, Components: checker.Components}
+		checker.Check(context.Background())
+		time.Sleep(2 * time.Millisecond)
+		checker.Check(context.Background())
+
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Check - A Cancelled Probe Does Not Cache A Failure", func(t *testing.T) {
+		checker := &health.Checker{CacheFor: time.Hour, Components: []health.Component{
+			{Name: "mongo", Critical: true, Check: func(ctx context.Context) (any, error) {
+				return nil, ctx.Err()
+			}},
+		}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		checker.Check(ctx)
+		report := checker.Check(context.Background())
+
+		assert.True(t, report.Ready())
+		assert.Equal(t, health.StatusOK, report.Components["mongo"].Status)
+	})
+
+	t.Run("Heartbeats - Reports Missing And Stale Workers", func(t *testing.T) {
+		heartbeats := &health.Heartbeats{}
+		_, err := heartbeats.Check(time.Minute)(context.Background())
+		assert.NotNil(t, err)
+
+		heartbeats.Beat("jobs.worker")
+		details, err := heartbeats.Check(time.Minute)(context.Background())
+		assert.Nil(t, err)
+		assert.False(t, details.(map[string]health.WorkerStatus)["jobs.worker"].Stale)
+
+		time.Sleep(2 * time.Millisecond)
+		details, err = heartbeats.Check(time.Millisecond)(context.Background())
+		assert.NotNil(t, err)
+		assert.True(t, details.(map[string]health.WorkerStatus)["jobs.worker"].Stale)
+	})
+
+	t.Run("Runner - Workers Beat When Polling", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+		heartbeats := &health.Heartbeats{}
+
+		// Already cancelled, so each loop polls once and returns.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		runner := &jobs.Runner{Jobs: mockJobs, Workers: 1, Heartbeat: heartbeats.Beat}
+		runner.Run(ctx)
+
+		details, err := heartbeats.Check(time.Minute)(context.Background())
+		assert.Nil(t, err)
+		assert.Contains(t, details, "jobs.relay")
+		assert.Contains(t, details, "jobs.worker.1")
+	})
+}
+
+func TestHealthController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(components ...health.Component) *gin.Engine {
+		controller := &controllers.HealthController{Checker: &health.Checker{Components: components}, DetailsToken: "metrics-token"}
+		router := gin.New()
+		router.GET("/healthz", controller.Live)
+		router.GET("/readyz", controller.Ready)
+		return router
+	}
+	get := func(router *gin.Engine, path string, token string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Live - Ignores Dependencies", func(t *testing.T) {
+		router := newRouter(health.Component{Name: "mongo", Critical: true, Check: failing("connection refused")})
+
+		rr := get(router, "/healthz", "")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"status": "ok"}`, rr.Body.String())
+	})
+
+	t.Run("Ready - Critical Failure Returns 503 With Breakdown", func(t *testing.T) {
+		router := newRouter(
+			health.Component{Name: "mongo", Critical: true, Check: failing("connection refused")},
+			health.Component{Name: "payment_gateway", Check: passing(map[string]any{"mode": "live"})},
+		)
+
+		rr := get(router, "/readyz", "metrics-token")
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		var report map[string]any
+		assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &report))
+		assert.Equal(t, "unavailable", report["status"])
+		components := report["components"].(map[string]any)
+		assert.Equal(t, "failing", components["mongo"].(map[string]any)["status"])
+		assert.Equal(t, "connection refused", components["mongo"].(map[string]any)["error"])
+		assert.Equal(t, "ok", components["payment_gateway"].(map[string]any)["status"])
+	})
+
+	t.Run("Ready - Degraded Still Returns 200", func(t *testing.T) {
+		router := newRouter(
+			health.Component{Name: "mongo", Critical: true, Check: passing(nil)},
+			health.Component{Name: "workers", Check: failing("no heartbeat")},
+		)
+
+		rr := get(router, "/readyz", "metrics-token")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"status":"degraded"`)
+	})
+
+	t.Run("Ready - Only Statuses Without The Token", func(t *testing.T) {
+		router := newRouter(
+			health.Component{Name: "mongo", Critical: true, Check: failing("connection refused")},
+			health.Component{Name: "payment_gateway", Check: passing(map[string]any{"mode": "live"})},
+		)
+
+		for _, token := range []string{"", "wrong-token"} {
+			rr := get(router, "/readyz", token)
+
+			assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+			var body health.Report
+			assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, health.StatusUnavailable, body.Status)
+			assert.Equal(t, health.StatusFailing, body.Components["mongo"].Status)
+			assert.Equal(t, health.StatusOK, body.Components["payment_gateway"].Status)
+			assert.NotContains(t, rr.Body.String(), "connection refused")
+			assert.NotContains(t, rr.Body.String(), "live")
+		}
+	})
+}
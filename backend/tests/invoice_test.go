@@ -0,0 +1,428 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/invoices"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockInvoiceRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceRepository) NextSequence(ctx context.Context, financialYear string) (int, error) {
+	args := m.Called(financialYear)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) CreateInvoice(ctx context.Context, invoice models.Invoice) error {
+	args := m.Called(invoice)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceRepository) GetInvoiceByOrder(ctx context.Context, orderID string) (*models.Invoice, error) {
+	args := m.Called(orderID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) SetFile(ctx context.Context, orderID string, file string) error {
+	args := m.Called(orderID, file)
+	return args.Error(0)
+}
+
+type MockInvoiceRenderer struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceRenderer) Render(invoice models.Invoice) ([]byte, error) {
+	args := m.Called(invoice)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.([]byte), args.Error(1)
+}
+
+type MockInvoiceStore struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceStore) Save(name string, data []byte) error {
+	args := m.Called(name, data)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceStore) Open(name string) ([]byte, error) {
+	args := m.Called(name)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.([]byte), args.Error(1)
+}
+
+type MockInvoiceService struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceService) GetInvoicePDF(ctx context.Context, orderID string, access services.OrderAccess) (*models.Invoice, []byte, error) {
+	args := m.Called(orderID, access)
+	val := args.Get(0)
+	if val == nil {
+		return nil, nil, args.Error(2)
+	}
+	return val.(*models.Invoice), args.Get(1).([]byte), args.Error(2)
+}
+
+// Two real GSTINs with valid check digits, registered in Maharashtra and Karnataka.
+const (
+	shopGSTIN  = "27AAPFU0939F1ZV"
+	buyerGSTIN = "29AAGCB7383J1Z4"
+)
+
+func TestInvoiceService(t *testing.T) {
+	seller := models.InvoiceParty{Name: "Mangal Chai", Address: "Pune, Maharashtra", GSTIN: shopGSTIN}
+	products := func() *MockProductRepository {
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "masala").Return(&models.Product{ID: "masala", Name: "Masala Chai", Weight: "250g"}, nil)
+		mockProductRepo.On("GetProduct", "green").Return(&models.Product{ID: "green", Name: "Green Tea", HSNCode: "09021010", GSTRate: 12}, nil)
+		mockProductRepo.On("GetProduct", "gift").Return(&models.Product{ID: "gift", Name: "Gift Card", Category: models.GiftCardCategory}, nil)
+		return mockProductRepo
+	}
+	// issue runs a payment_confirmed event for order and returns the invoice it created.
+	issue := func(t *testing.T, order models.Order) models.Invoice {
+		mockRepo := new(MockInvoiceRepository)
+		mockRenderer := new(MockInvoiceRenderer)
+		mockStore := new(MockInvoiceStore)
+		var created models.Invoice
+		mockRepo.On("GetInvoiceByOrder", order.ID).Return(nil, mongo.ErrNoDocuments)
+		mockRepo.On("NextSequence", mock.Anything).Return(7, nil)
+		mockRepo.On("CreateInvoice", mock.Anything).Run(func(args mock.Arguments) {
+			created = args.Get(0).(models.Invoice)
+		}).Return(nil)
+		mockRenderer.On("Render", mock.Anything).Return([]byte("%PDF-1.3"), nil)
+		mockStore.On("Save", mock.MatchedBy(func(name string) bool {
+			return strings.HasSuffix(name, "-00007.pdf")
+		}), []byte("%PDF-1.3")).Return(nil)
+		mockRepo.On("SetFile", order.ID, mock.Anything).Return(nil)
+
+		service := &services.InvoiceService{Repository: mockRepo, Products: products(), Renderer: mockRenderer, Store: mockStore, Seller: seller}
+		err := service.HandleOrderEvent(context.Background(), models.OrderEventPaymentConfirmed, order)
+
+		assert.Nil(t, err)
+		mockRepo.AssertExpectations(t)
+		mockStore.AssertExpectations(t)
+		return created
+	}
+
+	t.Run("HandleOrderEvent - Within The State", func(t *testing.T) {
+		invoice := issue(t, models.Order{
+			ID:           "ord_1",
+			CustomerInfo: models.CustomerInfo{Name: "Asha", Address: "12 FC Road, Pune, Maharashtra 411004"},
+			Items: []models.CartItem{
+				{ProductID: "masala", Quantity: 2, Price: 300.0},
+				{ProductID: "green", Quantity: 1, Price: 400.0},
+			},
+			Discount:    100.0,
+			TotalAmount: 900.0,
+		})
+
+		assert.Regexp(t, regexp.MustCompile(`^MC/\d{4}-\d{2}/00007$`), invoice.Number)
+		assert.LessOrEqual(t, len(invoice.Number), 16)
+		assert.False(t, invoice.InterState)
+		assert.Equal(t, "27", invoice.PlaceOfSupply)
+		assert.Equal(t, "Maharashtra", invoice.Seller.State)
+		assert.Len(t, invoice.Lines, 2)
+
+		masala := invoice.Lines[0]
+		assert.Equal(t, "Masala Chai (250g)", masala.Description)
+		assert.Equal(t, services.DefaultHSNCode, masala.HSN)
+		assert.Equal(t, 60.0, masala.Discount)
+		assert.Equal(t, 540.0, masala.Total)
+		assert.Equal(t, 514.29, masala.TaxableValue)
+		assert.InDelta(t, 25.71, masala.CGST+masala.SGST, 0.001)
+
+		green := invoice.Lines[1]
+		assert.Equal(t, "09021010", green.HSN)
+		assert.Equal(t, 12.0, green.GSTRate)
+		assert.Equal(t, 360.0, green.Total)
+		assert.Equal(t, 321.43, green.TaxableValue)
+
+		assert.Equal(t, 900.0, invoice.Total)
+		assert.Equal(t, 835.72, invoice.TaxableValue)
+		assert.InDelta(t, 64.28, invoice.CGST+invoice.SGST, 0.001)
+		assert.Equal(t, 0.0, invoice.IGST)
+	})
+
+	t.Run("HandleOrderEvent - Business Buyer In Another State", func(t *testing.T) {
+		invoice := issue(t, models.Order{
+			ID:           "ord_2",
+			CustomerInfo: models.CustomerInfo{Name: "Chai Point", Address: "Pune, Maharashtra", GSTIN: buyerGSTIN},
+			Items: []models.CartItem{
+				{ProductID: "masala", Quantity: 1, Price: 210.0},
+				{ProductID: "gift", Quantity: 1, Price: 500.0},
+			},
+			TotalAmount: 710.0,
+		})
+
+		// The GSTIN decides the place of supply over the delivery address.
+		assert.True(t, invoice.InterState)
+		assert.Equal(t, "29", invoice.PlaceOfSupply)
+		assert.Equal(t, buyerGSTIN, invoice.Buyer.GSTIN)
+		assert.Equal(t, 10.0, invoice.Lines[0].IGST)
+		assert.Equal(t, 0.0, invoice.Lines[0].CGST)
+		assert.Equal(t, "", invoice.Lines[1].HSN)
+		assert.Equal(t, 0.0, invoice.Lines[1].GSTRate)
+		assert.Equal(t, 500.0, invoice.Lines[1].TaxableValue)
+		assert.Equal(t, 10.0, invoice.IGST)
+		assert.Equal(t, 710.0, invoice.Total)
+	})
+
+	t.Run("HandleOrderEvent - Place Of Supply From The Address", func(t *testing.T) {
+		order := models.Order{
+			ID:           "ord_3",
+			CustomerInfo: models.CustomerInfo{Name: "Ravi", Address: "Flat 4, 100 Feet Road, Indiranagar, Bengaluru, KARNATAKA"},
+			Items:        []models.CartItem{{ProductID: "masala", Quantity: 1, Price: 210.0}},
+			TotalAmount:  210.0,
+		}
+		invoice := issue(t, order)
+		assert.True(t, invoice.InterState)
+		assert.Equal(t, "Karnataka", invoice.Buyer.State)
+
+		// An address naming no state is taken to be in the shop's own state.
+		order.ID = "ord_4"
+		order.CustomerInfo.Address = "Flat 4, Andheri West"
+		invoice = issue(t, order)
+		assert.False(t, invoice.InterState)
+		assert.Equal(t, "27", invoice.PlaceOfSupply)
+	})
+
+	t.Run("HandleOrderEvent - Ignores Other Events And Issued Invoices", func(t *testing.T) {
+		mockRepo := new(MockInvoiceRepository)
+		mockRepo.On("GetInvoiceByOrder", "ord_1").Return(&models.Invoice{Number: "MC/2026-27/00001", OrderID: "ord_1", File: "2026-27/MC-2026-27-00001.pdf"}, nil)
+
+		service := &services.InvoiceService{Repository: mockRepo, Seller: seller}
+		assert.Nil(t, service.HandleOrderEvent(context.Background(), models.OrderEventPlaced, models.Order{ID: "ord_1"}))
+		assert.Nil(t, service.HandleOrderEvent(context.Background(), models.OrderEventPaymentConfirmed, models.Order{ID: "ord_1"}))
+
+		mockRepo.AssertNumberOfCalls(t, "GetInvoiceByOrder", 1)
+		mockRepo.AssertNotCalled(t, "NextSequence", mock.Anything)
+	})
+
+	t.Run("HandleOrderEvent - Retry Stores The Issued Invoice", func(t *testing.T) {
+		mockRepo := new(MockInvoiceRepository)
+		mockRenderer := new(MockInvoiceRenderer)
+		mockStore := new(MockInvoiceStore)
+		invoice := &models.Invoice{Number: "MC/2026-27/00001", FinancialYear: "2026-27", OrderID: "ord_1"}
+		mockRepo.On("GetInvoiceByOrder", "ord_1").Return(invoice, nil)
+		mockRenderer.On("Render", *invoice).Return([]byte("%PDF-1.3"), nil)
+		mockStore.On("Save", "2026-27/MC-2026-27-00001.pdf", []byte("%PDF-1.3")).Return(nil)
+		mockRepo.On("SetFile", "ord_1", "2026-27/MC-2026-27-00001.pdf").Return(nil)
+
+		service := &services.InvoiceService{Repository: mockRepo, Renderer: mockRenderer, Store: mockStore, Seller: seller}
+		err := service.HandleOrderEvent(context.Background(), models.OrderEventPaymentConfirmed, models.Order{ID: "ord_1"})
+
+		assert.Nil(t, err)
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "NextSequence", mock.Anything)
+	})
+
+	t.Run("GetInvoicePDF - Owner Only", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRepo := new(MockInvoiceRepository)
+		mockStore := new(MockInvoiceStore)
+		order := &models.Order{ID: "ord_1", CustomerID: "cus_1", CustomerInfo: models.CustomerInfo{Phone: "9876543210"}}
+		invoice := &models.Invoice{Number: "MC/2026-27/00001", OrderID: "ord_1", File: "2026-27/MC-2026-27-00001.pdf"}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRepo.On("GetInvoiceByOrder", "ord_1").Return(invoice, nil)
+		mockStore.On("Open", invoice.File).Return([]byte("%PDF-1.3"), nil)
+
+		service := &services.InvoiceService{Repository: mockRepo, Orders: mockOrderRepo, Store: mockStore}
+
+		_, _, err := service.GetInvoicePDF(context.Background(), "ord_1", services.OrderAccess{CustomerID: "cus_2"})
+		assert.True(t, errors.Is(err, services.ErrOrderNotFound))
+
+		for _, access := range []services.OrderAccess{{CustomerID: "cus_1"}, {Phone: "+91 98765 43210"}, {Admin: true}} {
+			got, pdf, err := service.GetInvoicePDF(context.Background(), "ord_1", access)
+			assert.Nil(t, err)
+			assert.Equal(t, invoice.Number, got.Number)
+			assert.Equal(t, []byte("%PDF-1.3"), pdf)
+		}
+	})
+
+	t.Run("GetInvoicePDF - Renders A Missing File Again", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRepo := new(MockInvoiceRepository)
+		mockRenderer := new(MockInvoiceRenderer)
+		mockStore := new(MockInvoiceStore)
+		invoice := &models.Invoice{Number: "MC/2026-27/00001", FinancialYear: "2026-27", OrderID: "ord_1", File: "2026-27/MC-2026-27-00001.pdf"}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(&models.Order{ID: "ord_1"}, nil)
+		mockRepo.On("GetInvoiceByOrder", "ord_1").Return(invoice, nil)
+		mockStore.On("Open", invoice.File).Return(nil, fs.ErrNotExist)
+		mockRenderer.On("Render", *invoice).Return([]byte("%PDF-1.3"), nil)
+		mockStore.On("Save", invoice.File, []byte("%PDF-1.3")).Return(nil)
+		mockRepo.On("SetFile", "ord_1", invoice.File).Return(nil)
+
+		service := &services.InvoiceService{Repository: mockRepo, Orders: mockOrderRepo, Renderer: mockRenderer, Store: mockStore}
+		_, pdf, err := service.GetInvoicePDF(context.Background(), "ord_1", services.OrderAccess{Admin: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("%PDF-1.3"), pdf)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("GetInvoicePDF - Not Invoiced Yet", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRepo := new(MockInvoiceRepository)
+		mockOrderRepo.On("GetOrder", "ord_1").Return(&models.Order{ID: "ord_1"}, nil)
+		mockRepo.On("GetInvoiceByOrder", "ord_1").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.InvoiceService{Repository: mockRepo, Orders: mockOrderRepo}
+		_, _, err := service.GetInvoicePDF(context.Background(), "ord_1", services.OrderAccess{Admin: true})
+
+		assert.True(t, errors.Is(err, services.ErrInvoiceNotFound))
+	})
+
+	t.Run("CreateOrder - Rejects An Invalid GSTIN", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		service := &services.OrderService{OrderRepository: new(MockOrderRepository), ProductRepository: mockProductRepo}
+
+		_, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			CustomerInfo: models.CustomerInfo{Name: "Chai Point", GSTIN: "27AAPFU0939F1ZX"},
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+		})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidGSTIN))
+		mockProductRepo.AssertNotCalled(t, "GetProduct", mock.Anything)
+	})
+}
+
+func TestInvoicePDF(t *testing.T) {
+	t.Run("AmountInWords - Indian Numbering", func(t *testing.T) {
+		assert.Equal(t, "Indian Rupees Zero Only", invoices.AmountInWords(0))
+		assert.Equal(t, "Indian Rupees Nine Hundred Only", invoices.AmountInWords(900))
+		assert.Equal(t, "Indian Rupees One Lakh Twenty Five Thousand Fifty and Fifty Paise Only", invoices.AmountInWords(125050.5))
+		assert.Equal(t, "Indian Rupees Twelve Crore Thirty Four Lakh Fifty Six Thousand Seven Hundred Eighty Nine and Five Paise Only", invoices.AmountInWords(123456789.05))
+	})
+
+	t.Run("Render - Produces A PDF", func(t *testing.T) {
+		invoice := models.Invoice{
+			Number:        "MC/2026-27/00001",
+			FinancialYear: "2026-27",
+			OrderID:       "ord_1",
+			Seller:        models.InvoiceParty{Name: "Mangal Chai", Address: "Pune", GSTIN: shopGSTIN, State: "Maharashtra", StateCode: "27"},
+			Buyer:         models.InvoiceParty{Name: "Asha Pātil", Address: "Pune", State: "Maharashtra", StateCode: "27"},
+			PlaceOfSupply: "27",
+			Lines: []models.InvoiceLine{{
+				Description: "A very long product name that does not fit in the description column (250g)",
+				HSN:         "0902", Quantity: 1, UnitPrice: 210, TaxableValue: 200, GSTRate: 5, CGST: 5, SGST: 5, Total: 210,
+			}},
+			TaxableValue: 200, CGST: 5, SGST: 5, Total: 210,
+			IssuedAt: time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+		}
+
+		renderer := &invoices.Renderer{}
+		pdf, err := renderer.Render(invoice)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(pdf), "%PDF-"))
+	})
+
+	t.Run("FileStore - Saves And Opens", func(t *testing.T) {
+		store := &invoices.FileStore{Dir: t.TempDir()}
+
+		assert.Nil(t, store.Save("2026-27/MC-2026-27-00001.pdf", []byte("%PDF-1.3")))
+		data, err := store.Open("2026-27/MC-2026-27-00001.pdf")
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("%PDF-1.3"), data)
+
+		_, err = store.Open("2026-27/MC-2026-27-00002.pdf")
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+		assert.NotNil(t, store.Save("../outside.pdf", []byte("%PDF-1.3")))
+	})
+}
+
+func TestInvoiceController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := &services.CustomerTokens{Secret: []byte("test-secret")}
+
+	newRouter := func(service services.InvoiceServiceInterface) *gin.Engine {
+		router := gin.New()
+		controller := &controllers.InvoiceController{Service: service}
+		router.GET("/api/orders/:order_id/invoice", middleware.CustomerAuth(tokens), controller.GetInvoice)
+		router.GET("/api/admin/orders/:order_id/invoice", controller.GetAdminInvoice)
+		return router
+	}
+	invoice := &models.Invoice{Number: "MC/2026-27/00001", OrderID: "ord_1"}
+
+	t.Run("GetInvoice - Serves The PDF To A Guest With The Order's Phone", func(t *testing.T) {
+		mockService := new(MockInvoiceService)
+		mockService.On("GetInvoicePDF", "ord_1", services.OrderAccess{Phone: "9876543210"}).Return(invoice, []byte("%PDF-1.3"), nil)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/orders/ord_1/invoice?phone=9876543210", nil)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Header().Get("Content-Disposition"), `filename="invoice-MC-2026-27-00001.pdf"`)
+		assert.Equal(t, "%PDF-1.3", rr.Body.String())
+	})
+
+	t.Run("GetInvoice - Logged-In Customer", func(t *testing.T) {
+		mockService := new(MockInvoiceService)
+		mockService.On("GetInvoicePDF", "ord_1", services.OrderAccess{CustomerID: "cus_1"}).Return(invoice, []byte("%PDF-1.3"), nil)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/orders/ord_1/invoice", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("GetInvoice - Not Found", func(t *testing.T) {
+		mockService := new(MockInvoiceService)
+		mockService.On("GetInvoicePDF", "ord_1", mock.Anything).Return(nil, nil, services.ErrOrderNotFound)
+		mockService.On("GetInvoicePDF", "ord_2", mock.Anything).Return(nil, nil, services.ErrInvoiceNotFound)
+
+		for _, orderID := range []string{"ord_1", "ord_2"} {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/orders/"+orderID+"/invoice", nil)
+			newRouter(mockService).ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("GetAdminInvoice - Success", func(t *testing.T) {
+		mockService := new(MockInvoiceService)
+		mockService.On("GetInvoicePDF", "ord_1", services.OrderAccess{Admin: true}).Return(invoice, []byte("%PDF-1.3"), nil)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/orders/ord_1/invoice", nil)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
@@ -0,0 +1,409 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/jobs"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type MockJobRepository struct {
+	mock.Mock
+}
+
+func (m *MockJobRepository) Enqueue(ctx context.Context, request models.JobRequest) error {
+	args := m.Called(request)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) ClaimNext(ctx context.Context, now time.Time, lease time.Duration) (*models.Job, error) {
+	args := m.Called(now, lease)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) Complete(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) Retry(ctx context.Context, id string, runAt time.Time, lastError string) error {
+	args := m.Called(id, runAt, lastError)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) Bury(ctx context.Context, job models.Job, lastError string) error {
+	args := m.Called(job, lastError)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) ListJobs(ctx context.Context, status string, limit int64) ([]models.Job, error) {
+	args := m.Called(status, limit)
+	return args.Get(0).([]models.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) ListDeadJobs(ctx context.Context, limit int64) ([]models.Job, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]models.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) Requeue(ctx context.Context, id string) (*models.Job, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Job), args.Error(1)
+}
+
+type MockJobService struct {
+	mock.Mock
+}
+
+func (m *MockJobService) ListJobs(ctx context.Context, status string) ([]models.Job, error) {
+	args := m.Called(status)
+	return args.Get(0).([]models.Job), args.Error(1)
+}
+
+func (m *MockJobService) ListDeadJobs(ctx context.Context) ([]models.Job, error) {
+	args := m.Called()
+	return args.Get(0).([]models.Job), args.Error(1)
+}
+
+func (m *MockJobService) RetryJob(ctx context.Context, id string) (*models.Job, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Job), args.Error(1)
+}
+
+func TestJobRunner(t *testing.T) {
+	t.Run("RelayOutbox - Enqueues And Clears Order Outbox", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		request := jobs.NotifyOrderJob(models.OrderEventPlaced, "ord_1")
+		mockOrderRepo.On("PendingOutbox", int64(100)).Return([]models.Order{{ID: "ord_1", Outbox: []models.JobRequest{request}}}, nil)
+		mockJobs.On("Enqueue", request).Return(nil)
+		mockOrderRepo.On("ClearOutbox", "ord_1", []string{request.ID}).Return(nil)
+
+		runner := &jobs.Runner{Jobs: mockJobs, Orders: mockOrderRepo}
+		relayed, err := runner.RelayOutbox(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, relayed)
+		mockJobs.AssertExpectations(t)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("NotifyOrderJob - One Job Per Order Event", func(t *testing.T) {
+		placed := jobs.NotifyOrderJob(models.OrderEventPlaced, "ord_1")
+		paid := jobs.NotifyOrderJob(models.OrderEventPaymentConfirmed, "ord_1")
+
+		assert.NotEqual(t, placed.ID, paid.ID)
+		assert.Equal(t, placed.ID, jobs.NotifyOrderJob(models.OrderEventPlaced, "ord_1").ID)
+		assert.NotEqual(t, placed.ID, jobs.NotifyOrderJob(models.OrderEventPlaced, "ord_2").ID)
+	})
+
+	t.Run("RelayOutbox - Keeps Outbox When Enqueue Fails", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		request := jobs.NotifyOrderJob(models.OrderEventPlaced, "ord_1")
+		mockOrderRepo.On("PendingOutbox", int64(100)).Return([]models.Order{{ID: "ord_1", Outbox: []models.JobRequest{request}}}, nil)
+		mockJobs.On("Enqueue", request).Return(errors.New("connection reset"))
+
+		runner := &jobs.Runner{Jobs: mockJobs, Orders: mockOrderRepo}
+		_, err := runner.RelayOutbox(context.Background())
+
+		assert.NotNil(t, err)
+		mockOrderRepo.AssertNotCalled(t, "ClearOutbox", mock.Anything, mock.Anything)
+	})
+
+	t.Run("RunNext - Nothing Due", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+
+		runner := &jobs.Runner{Jobs: mockJobs}
+		ran, err := runner.RunNext(context.Background())
+
+		assert.Nil(t, err)
+		assert.False(t, ran)
+	})
+
+	t.Run("RunNext - Completes Successful Job", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		job := &models.Job{ID: "job_1", Type: "test", Attempts: 1, Payload: map[string]string{"key": "value"}}
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(job, nil)
+		mockJobs.On("Complete", "job_1").Return(nil)
+
+		var handled models.Job
+		runner := &jobs.Runner{Jobs: mockJobs}
+		runner.Handle("test", func(ctx context.Context, job models.Job) error {
+			handled = job
+			return nil
+		})
+		ran, err := runner.RunNext(context.Background())
+
+		assert.Nil(t, err)
+		assert.True(t, ran)
+		assert.Equal(t, "value", handled.Payload["key"])
+		mockJobs.AssertExpectations(t)
+	})
+
+	t.Run("RunNext - Retries With Backoff", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(&models.Job{ID: "job_1", Type: "test", Attempts: 3}, nil)
+		var retryAt time.Time
+		mockJobs.On("Retry", "job_1", mock.Anything, "smtp timeout").Run(func(args mock.Arguments) {
+			retryAt = args.Get(1).(time.Time)
+		}).Return(nil)
+
+		runner := &jobs.Runner{Jobs: mockJobs}
+		runner.Handle("test", func(ctx context.Context, job models.Job) error { return errors.New("smtp timeout") })
+		_, err := runner.RunNext(context.Background())
+
+		assert.Nil(t, err)
+		// The third failed attempt waits 30s * 2 * 2.
+		assert.WithinDuration(t, time.Now().Add(2*time.Minute), retryAt, 5*time.Second)
+		mockJobs.AssertNotCalled(t, "Bury", mock.Anything, mock.Anything)
+	})
+
+	t.Run("RunNext - Dead Letters After Max Attempts", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		job := &models.Job{ID: "job_1", Type: "test", Attempts: 3}
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(job, nil)
+		mockJobs.On("Bury", *job, "smtp timeout").Return(nil)
+
+		runner := &jobs.Runner{Jobs: mockJobs, MaxAttempts: 3}
+		runner.Handle("test", func(ctx context.Context, job models.Job) error { return errors.New("smtp timeout") })
+		_, err := runner.RunNext(context.Background())
+
+		assert.Nil(t, err)
+		mockJobs.AssertExpectations(t)
+		mockJobs.AssertNotCalled(t, "Retry", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("RunNext - Dead Letters Permanent Failures Immediately", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		job := &models.Job{ID: "job_1", Type: "test", Attempts: 1}
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(job, nil)
+		mockJobs.On("Bury", *job, "bad payload").Return(nil)
+
+		runner := &jobs.Runner{Jobs: mockJobs}
+		runner.Handle("test", func(ctx context.Context, job models.Job) error { return jobs.Permanent(errors.New("bad payload")) })
+		_, err := runner.RunNext(context.Background())
+
+		assert.Nil(t, err)
+		mockJobs.AssertExpectations(t)
+	})
+
+	t.Run("RunNext - Recovers From Panics", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(&models.Job{ID: "job_1", Type: "test", Attempts: 1}, nil)
+		mockJobs.On("Retry", "job_1", mock.Anything, "panic: boom").Return(nil)
+
+		runner := &jobs.Runner{Jobs: mockJobs}
+		runner.Handle("test", func(ctx context.Context, job models.Job) error { panic("boom") })
+		_, err := runner.RunNext(context.Background())
+
+		assert.Nil(t, err)
+		mockJobs.AssertExpectations(t)
+	})
+
+	t.Run("RunNext - Dead Letters Unknown Job Types", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		job := &models.Job{ID: "job_1", Type: "unknown", Attempts: 1}
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(job, nil)
+		mockJobs.On("Bury", *job, `no handler for job type "unknown"`).Return(nil)
+
+		runner := &jobs.Runner{Jobs: mockJobs}
+		_, err := runner.RunNext(context.Background())
+
+		assert.Nil(t, err)
+		mockJobs.AssertExpectations(t)
+	})
+
+	t.Run("EnqueueScheduled - One Job Per Interval", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		var requests []models.JobRequest
+		mockJobs.On("Enqueue", mock.Anything).Run(func(args mock.Arguments) {
+			requests = append(requests, args.Get(0).(models.JobRequest))
+		}).Return(nil)
+
+		runner := &jobs.Runner{Jobs: mockJobs}
+		runner.Every("cleanup", 5*time.Minute)
+		base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		assert.Nil(t, runner.EnqueueScheduled(context.Background(), base.Add(1*time.Minute)))
+		assert.Nil(t, runner.EnqueueScheduled(context.Background(), base.Add(4*time.Minute)))
+		assert.Nil(t, runner.EnqueueScheduled(context.Background(), base.Add(6*time.Minute)))
+
+		assert.Len(t, requests, 3)
+		assert.Equal(t, requests[0].ID, requests[1].ID)
+		assert.NotEqual(t, requests[1].ID, requests[2].ID)
+		assert.Equal(t, "cleanup", requests[2].Type)
+		assert.Equal(t, base.Add(5*time.Minute), requests[2].RunAt)
+	})
+
+	t.Run("NotifyOrderHandler - Notifies With Current Order", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockNotifier := new(MockOrderNotifier)
+		order := &models.Order{ID: "ord_1", Status: models.OrderStatusConfirmed}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockNotifier.On("NotifyOrder", models.OrderEventPlaced, *order).Return()
+
+		handler := jobs.NotifyOrderHandler(mockOrderRepo, mockNotifier)
+		err := handler(context.Background(), models.Job{Payload: map[string]string{"event": models.OrderEventPlaced, "order_id": "ord_1"}})
+
+		assert.Nil(t, err)
+		mockNotifier.AssertExpectations(t)
+	})
+
+	t.Run("NotifyOrderHandler - Handler Error Retries Before Notifying", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockNotifier := new(MockOrderNotifier)
+		mockHandler := new(MockOrderEventHandler)
+		order := &models.Order{ID: "ord_1", Status: models.OrderStatusConfirmed}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockHandler.On("HandleOrderEvent", models.OrderEventPaymentConfirmed, *order).Return(errors.New("db down"))
+
+		handler := jobs.NotifyOrderHandler(mockOrderRepo, mockNotifier, mockHandler)
+		err := handler(context.Background(), models.Job{Payload: map[string]string{"event": models.OrderEventPaymentConfirmed, "order_id": "ord_1"}})
+
+		assert.NotNil(t, err)
+		mockNotifier.AssertNotCalled(t, "NotifyOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("NotifyOrderHandler - Missing Order Is Permanent", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "ord_1").Return(nil, mongo.ErrNoDocuments)
+		job := &models.Job{ID: "job_1", Type: models.JobTypeNotifyOrder, Attempts: 1, Payload: map[string]string{"event": models.OrderEventPlaced, "order_id": "ord_1"}}
+		mockJobs.On("ClaimNext", mock.Anything, mock.Anything).Return(job, nil)
+		mockJobs.On("Bury", *job, "order ord_1 not found").Return(nil)
+
+		runner := &jobs.Runner{Jobs: mockJobs}
+		runner.Handle(models.JobTypeNotifyOrder, jobs.NotifyOrderHandler(mockOrderRepo, new(MockOrderNotifier)))
+		_, err := runner.RunNext(context.Background())
+
+		assert.Nil(t, err)
+		mockJobs.AssertExpectations(t)
+	})
+}
+
+type MockOrderEventHandler struct {
+	mock.Mock
+}
+
+func (m *MockOrderEventHandler) HandleOrderEvent(ctx context.Context, event string, order models.Order) error {
+	args := m.Called(event, order)
+	return args.Error(0)
+}
+
+func TestJobService(t *testing.T) {
+	t.Run("ListJobs - Rejects Unknown Status", func(t *testing.T) {
+		service := &services.JobService{Repository: new(MockJobRepository)}
+
+		_, err := service.ListJobs(context.Background(), "dead")
+
+		assert.ErrorIs(t, err, services.ErrInvalidJobQuery)
+	})
+
+	t.Run("RetryJob - Not Found", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockJobs.On("Requeue", "job_1").Return(nil, mongo.ErrNoDocuments)
+		service := &services.JobService{Repository: mockJobs}
+
+		_, err := service.RetryJob(context.Background(), "job_1")
+
+		assert.ErrorIs(t, err, services.ErrJobNotFound)
+	})
+}
+
+func TestJobController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("ListDeadJobs - Success", func(t *testing.T) {
+		mockService := new(MockJobService)
+		mockService.On("ListDeadJobs").Return([]models.Job{{ID: "job_1", Status: models.JobStatusDead, LastError: "smtp timeout"}}, nil)
+
+		controller := &controllers.JobController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/jobs/dead", nil)
+
+		controller.ListDeadJobs(c)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "smtp timeout")
+	})
+
+	t.Run("RetryJob - Not Found", func(t *testing.T) {
+		mockService := new(MockJobService)
+		mockService.On("RetryJob", "job_1").Return(nil, services.ErrJobNotFound)
+
+		controller := &controllers.JobController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/jobs/dead/job_1/retry", nil)
+		c.Params = gin.Params{{Key: "job_id", Value: "job_1"}}
+
+		controller.RetryJob(c)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestJobRepository(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Enqueue - Ignores Duplicate IDs", func(mt *mtest.T) {
+		jobRepository := &repositories.JobRepository{Collection: mt.Coll}
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}))
+
+		err := jobRepository.Enqueue(context.Background(), models.JobRequest{ID: "cleanup@1700000000", Type: "cleanup"})
+
+		assert.Nil(t, err)
+	})
+
+	mt.Run("ClaimNext", func(mt *mtest.T) {
+		jobRepository := &repositories.JobRepository{Collection: mt.Coll}
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: bson.D{
+				{Key: "id", Value: "job_1"},
+				{Key: "type", Value: models.JobTypeNotifyOrder},
+				{Key: "status", Value: models.JobStatusRunning},
+				{Key: "attempts", Value: 1},
+			}},
+		})
+
+		job, err := jobRepository.ClaimNext(context.Background(), time.Now(), time.Minute)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "job_1", job.ID)
+		assert.Equal(t, 1, job.Attempts)
+	})
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubTokenVerifier struct{}
+
+func (v stubTokenVerifier) Verify(token string) (string, error) {
+	if token == "valid" {
+		return "cust_1", nil
+	}
+	return "", errors.New("invalid token")
+}
+
+// logLines decodes the JSON lines written to buf.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestLogging(t *testing.T) {
+	t.Run("New - Redacts Contact Details", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.New(&buf, slog.LevelInfo)
+
+		logger.Info("Order placed", "order_id", "ord_1", "phone", "+919876543210", "customer_email", "asha@example.com",
+			slog.Group("shipping", "address", "12 MG Road, Pune"))
+
+		lines := logLines(t, &buf)
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "ord_1", lines[0]["order_id"])
+		assert.Equal(t, logging.Redacted, lines[0]["phone"])
+		assert.Equal(t, logging.Redacted, lines[0]["customer_email"])
+		assert.Equal(t, logging.Redacted, lines[0]["shipping"].(map[string]any)["address"])
+		assert.NotContains(t, buf.String(), "9876543210")
+	})
+
+	t.Run("FromContext - Carries Attributes", func(t *testing.T) {
+		var buf bytes.Buffer
+		ctx := logging.WithLogger(context.Background(), logging.New(&buf, slog.LevelInfo))
+		ctx = logging.With(ctx, "order_id", "ord_1")
+
+		logging.FromContext(ctx).Warn("Refund failed")
+
+		lines := logLines(t, &buf)
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "WARN", lines[0]["level"])
+		assert.Equal(t, "ord_1", lines[0]["order_id"])
+		assert.Equal(t, slog.Default(), logging.FromContext(context.Background()))
+	})
+}
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(buf *bytes.Buffer) *gin.Engine {
+		router := gin.New()
+		router.Use(middleware.RequestLogger(logging.New(buf, slog.LevelInfo)))
+		api := router.Group("/api", middleware.CustomerAuth(stubTokenVerifier{}))
+		api.GET("/orders/:order_id", func(ctx *gin.Context) {
+			logging.FromContext(ctx.Request.Context()).Info("Loading order")
+			ctx.Status(http.StatusOK)
+		})
+		api.GET("/track", func(ctx *gin.Context) { ctx.Status(http.StatusNotFound) })
+		api.GET("/broken", func(ctx *gin.Context) { ctx.Status(http.StatusInternalServerError) })
+		return router
+	}
+	send := func(router *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("RequestLogger - Propagates Request ID To Handlers", func(t *testing.T) {
+		var buf bytes.Buffer
+		router := newRouter(&buf)
+
+		rr := send(router, "/api/orders/ord_1", map[string]string{"X-Request-ID": "req-123", "Authorization": "Bearer valid"})
+
+		assert.Equal(t, "req-123", rr.Header().Get("X-Request-ID"))
+		lines := logLines(t, &buf)
+		assert.Len(t, lines, 2)
+		assert.Equal(t, "Loading order", lines[0]["msg"])
+		assert.Equal(t, "req-123", lines[0]["request_id"])
+		assert.Equal(t, "ord_1", lines[0]["order_id"])
+		assert.Equal(t, "cust_1", lines[0]["customer_id"])
+
+		assert.Equal(t, "INFO", lines[1]["level"])
+		assert.Equal(t, "req-123", lines[1]["request_id"])
+		assert.Equal(t, "GET", lines[1]["method"])
+		assert.Equal(t, "/api/orders/:order_id", lines[1]["route"])
+		assert.Equal(t, float64(http.StatusOK), lines[1]["status"])
+		assert.Equal(t, "cust_1", lines[1]["customer_id"])
+		assert.Contains(t, lines[1], "latency_ms")
+	})
+
+	t.Run("RequestLogger - Generates Missing Or Invalid Request ID", func(t *testing.T) {
+		var buf bytes.Buffer
+		router := newRouter(&buf)
+
+		generated := send(router, "/api/orders/ord_1", nil).Header().Get("X-Request-ID")
+		replaced := send(router, "/api/orders/ord_1", map[string]string{"X-Request-ID": "bad id\n{\"level\":\"ERROR\"}"}).Header().Get("X-Request-ID")
+
+		assert.Len(t, generated, 32)
+		assert.Len(t, replaced, 32)
+		assert.NotEqual(t, generated, replaced)
+	})
+
+	t.Run("RequestLogger - Level By Status Without Query String", func(t *testing.T) {
+		var buf bytes.Buffer
+		router := newRouter(&buf)
+
+		send(router, "/api/track?order=ord_1&phone=9876543210", nil)
+		send(router, "/api/broken", nil)
+
+		lines := logLines(t, &buf)
+		assert.Len(t, lines, 2)
+		assert.Equal(t, "WARN", lines[0]["level"])
+		assert.Equal(t, "/api/track", lines[0]["path"])
+		assert.Equal(t, "ERROR", lines[1]["level"])
+		assert.NotContains(t, buf.String(), "9876543210")
+	})
+}
@@ -0,0 +1,443 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockLoyaltyRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoyaltyRepository) Record(ctx context.Context, entry models.PointsEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockLoyaltyRepository) Balance(ctx context.Context, customerID string, at time.Time) (int, error) {
+	args := m.Called(customerID, at)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockLoyaltyRepository) ListEntries(ctx context.Context, customerID string, limit int64) ([]models.PointsEntry, error) {
+	args := m.Called(customerID, limit)
+	return args.Get(0).([]models.PointsEntry), args.Error(1)
+}
+
+func (m *MockLoyaltyRepository) ListOrderEntries(ctx context.Context, orderID string) ([]models.PointsEntry, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.PointsEntry), args.Error(1)
+}
+
+func (m *MockLoyaltyRepository) ListLots(ctx context.Context, customerID string, at time.Time) ([]models.PointsEntry, error) {
+	args := m.Called(customerID, at)
+	return args.Get(0).([]models.PointsEntry), args.Error(1)
+}
+
+func (m *MockLoyaltyRepository) TakeFromLot(ctx context.Context, id string, points int, at time.Time) error {
+	args := m.Called(id, points, at)
+	return args.Error(0)
+}
+
+func (m *MockLoyaltyRepository) ReturnToLot(ctx context.Context, id string, points int) error {
+	args := m.Called(id, points)
+	return args.Error(0)
+}
+
+func (m *MockLoyaltyRepository) ListExpiredLots(ctx context.Context, before time.Time, limit int64) ([]models.PointsEntry, error) {
+	args := m.Called(before, limit)
+	return args.Get(0).([]models.PointsEntry), args.Error(1)
+}
+
+func (m *MockLoyaltyRepository) ExpireLot(ctx context.Context, id string) (*models.PointsEntry, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.PointsEntry), args.Error(1)
+}
+
+type MockPointsRedeemer struct {
+	mock.Mock
+}
+
+func (m *MockPointsRedeemer) RedeemPoints(ctx context.Context, customerID string, points int, upTo float64, orderID string) (int, float64, error) {
+	args := m.Called(customerID, points, upTo, orderID)
+	return args.Int(0), args.Get(1).(float64), args.Error(2)
+}
+
+func (m *MockPointsRedeemer) ReturnPoints(ctx context.Context, customerID string, points int, orderID string) error {
+	args := m.Called(customerID, points, orderID)
+	return args.Error(0)
+}
+
+type MockPointsReverser struct {
+	mock.Mock
+}
+
+func (m *MockPointsReverser) ReversePoints(ctx context.Context, order models.Order, refunded float64) error {
+	args := m.Called(order, refunded)
+	return args.Error(0)
+}
+
+type MockLoyaltyService struct {
+	mock.Mock
+}
+
+func (m *MockLoyaltyService) GetAccount(ctx context.Context, customerID string) (*services.LoyaltyAccount, error) {
+	args := m.Called(customerID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.LoyaltyAccount), args.Error(1)
+}
+
+func TestLoyaltyService(t *testing.T) {
+	t.Run("HandleOrderEvent - Earns On Delivery With Multipliers", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "premium").Return(&models.Product{ID: "premium", Category: "Premium Teas"}, nil)
+		mockProductRepo.On("GetProduct", "masala").Return(&models.Product{ID: "masala", Category: "Masala Chai"}, nil)
+		mockProductRepo.On("GetProduct", "gift").Return(&models.Product{ID: "gift", Category: models.GiftCardCategory}, nil)
+		// ₹1000 of premium tea at 2x, ₹500 of masala chai and a ₹1000 gift card earn on ₹2500 of a ₹2500
+		// subtotal; paying ₹1800 of it earns on ₹1800, at 5 points per ₹100.
+		mockRepo.On("Record", mock.MatchedBy(func(entry models.PointsEntry) bool {
+			return entry.Kind == models.PointsKindEarned && entry.Points == 90 && entry.Remaining == 90 &&
+				entry.CustomerID == "cus_1" && entry.OrderID == "ord_1" && entry.ExpiresAt != nil
+		})).Return(nil)
+
+		service := &services.LoyaltyService{Repository: mockRepo, Products: mockProductRepo, PointsPer100: 5, Multipliers: map[string]float64{"Premium Teas": 2}}
+		order := models.Order{
+			ID:          "ord_1",
+			CustomerID:  "cus_1",
+			TotalAmount: 1800.0,
+			Discount:    200.0,
+			Items: []models.CartItem{
+				{ProductID: "premium", Quantity: 2, Price: 500.0},
+				{ProductID: "masala", Quantity: 1, Price: 500.0},
+				{ProductID: "gift", Quantity: 1, Price: 1000.0},
+			},
+		}
+
+		assert.Nil(t, service.HandleOrderEvent(context.Background(), models.OrderEventShipped, order))
+		mockRepo.AssertNotCalled(t, "Record", mock.Anything)
+
+		err := service.HandleOrderEvent(context.Background(), models.OrderEventDelivered, order)
+		assert.Nil(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("HandleOrderEvent - Guests And Repeats Earn Nothing", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "masala").Return(&models.Product{ID: "masala", Category: "Masala Chai"}, nil)
+		mockRepo.On("Record", mock.Anything).Return(mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}})
+
+		service := &services.LoyaltyService{Repository: mockRepo, Products: mockProductRepo}
+		order := models.Order{ID: "ord_1", TotalAmount: 500.0, Items: []models.CartItem{{ProductID: "masala", Quantity: 1, Price: 500.0}}}
+
+		assert.Nil(t, service.HandleOrderEvent(context.Background(), models.OrderEventDelivered, order))
+		mockRepo.AssertNotCalled(t, "Record", mock.Anything)
+
+		order.CustomerID = "cus_1"
+		assert.Nil(t, service.HandleOrderEvent(context.Background(), models.OrderEventDelivered, order))
+		mockRepo.AssertNumberOfCalls(t, "Record", 1)
+	})
+
+	t.Run("RedeemPoints - Spends Oldest Lots Up To The Order Value", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockRepo.On("ListLots", "cus_1", mock.Anything).Return([]models.PointsEntry{
+			{ID: "pts_1", Remaining: 30},
+			{ID: "pts_2", Remaining: 500},
+		}, nil)
+		mockRepo.On("TakeFromLot", "pts_1", 30, mock.Anything).Return(nil)
+		mockRepo.On("TakeFromLot", "pts_2", 170, mock.Anything).Return(nil)
+		mockRepo.On("Record", mock.MatchedBy(func(entry models.PointsEntry) bool {
+			return entry.Kind == models.PointsKindRedeemed && entry.Points == -200 && entry.OrderID == "ord_1" &&
+				assert.ObjectsAreEqual([]models.LotDebit{{LotID: "pts_1", Points: 30}, {LotID: "pts_2", Points: 170}}, entry.From)
+		})).Return(nil)
+
+		service := &services.LoyaltyService{Repository: mockRepo, PointValue: 0.5}
+		points, discount, err := service.RedeemPoints(context.Background(), "cus_1", 400, 100.0, "ord_1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 200, points)
+		assert.Equal(t, 100.0, discount)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RedeemPoints - Not Enough Points", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockRepo.On("ListLots", "cus_1", mock.Anything).Return([]models.PointsEntry{{ID: "pts_1", Remaining: 30}}, nil)
+
+		service := &services.LoyaltyService{Repository: mockRepo}
+		_, _, err := service.RedeemPoints(context.Background(), "cus_1", 100, 1000.0, "ord_1")
+
+		assert.True(t, errors.Is(err, services.ErrInsufficientPoints))
+		mockRepo.AssertNotCalled(t, "TakeFromLot", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("RedeemPoints - Puts Back Points When A Lot Was Spent Meanwhile", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockRepo.On("ListLots", "cus_1", mock.Anything).Return([]models.PointsEntry{
+			{ID: "pts_1", Remaining: 30},
+			{ID: "pts_2", Remaining: 100},
+		}, nil)
+		mockRepo.On("TakeFromLot", "pts_1", 30, mock.Anything).Return(nil)
+		mockRepo.On("TakeFromLot", "pts_2", 70, mock.Anything).Return(mongo.ErrNoDocuments)
+		mockRepo.On("ReturnToLot", "pts_1", 30).Return(nil)
+
+		service := &services.LoyaltyService{Repository: mockRepo}
+		_, _, err := service.RedeemPoints(context.Background(), "cus_1", 100, 1000.0, "ord_1")
+
+		assert.True(t, errors.Is(err, services.ErrInsufficientPoints))
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "Record", mock.Anything)
+	})
+
+	t.Run("ReturnPoints - Puts Points Back In The Lots They Came From", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockRepo.On("ListOrderEntries", "ord_1").Return([]models.PointsEntry{
+			{CustomerID: "cus_1", Kind: models.PointsKindRedeemed, Points: -200, From: []models.LotDebit{
+				{LotID: "pts_1", Points: 30},
+				{LotID: "pts_2", Points: 170},
+			}},
+		}, nil)
+		mockRepo.On("ReturnToLot", "pts_1", 30).Return(nil)
+		mockRepo.On("ReturnToLot", "pts_2", 170).Return(nil)
+		mockRepo.On("Record", mock.MatchedBy(func(entry models.PointsEntry) bool {
+			return entry.Kind == models.PointsKindReleased && entry.Points == 200 && entry.Remaining == 0 &&
+				entry.ExpiresAt == nil && entry.OrderID == "ord_1"
+		})).Return(nil)
+
+		service := &services.LoyaltyService{Repository: mockRepo}
+		err := service.ReturnPoints(context.Background(), "cus_1", 200, "ord_1")
+
+		assert.Nil(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ReturnPoints - Credits Points Whose Lots Were Not Recorded", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockRepo.On("ListOrderEntries", "ord_1").Return([]models.PointsEntry{
+			{CustomerID: "cus_1", Kind: models.PointsKindRedeemed, Points: -200},
+		}, nil)
+		mockRepo.On("Record", mock.MatchedBy(func(entry models.PointsEntry) bool {
+			return entry.Kind == models.PointsKindReleased && entry.Points == 200 && entry.Remaining == 200 &&
+				entry.ExpiresAt != nil && entry.OrderID == "ord_1"
+		})).Return(nil)
+
+		service := &services.LoyaltyService{Repository: mockRepo}
+		err := service.ReturnPoints(context.Background(), "cus_1", 200, "ord_1")
+
+		assert.Nil(t, err)
+		mockRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "ReturnToLot", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ReversePoints - Takes Back The Refunded Share", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockRepo.On("ListOrderEntries", "ord_1").Return([]models.PointsEntry{
+			{Kind: models.PointsKindEarned, Points: 100},
+			{Kind: models.PointsKindReversed, Points: -25},
+		}, nil)
+		mockRepo.On("ListLots", "cus_1", mock.Anything).Return([]models.PointsEntry{{ID: "pts_2", Remaining: 10}}, nil)
+		mockRepo.On("TakeFromLot", "pts_2", 10, mock.Anything).Return(nil)
+		mockRepo.On("Record", mock.MatchedBy(func(entry models.PointsEntry) bool {
+			return entry.Kind == models.PointsKindReversed && entry.Points == -10 && entry.OrderID == "ord_1"
+		})).Return(nil)
+
+		service := &services.LoyaltyService{Repository: mockRepo}
+		// Three quarters refunded so far: 75 points owed, 25 taken already, only 10 left to take.
+		err := service.ReversePoints(context.Background(), models.Order{ID: "ord_1", CustomerID: "cus_1", TotalAmount: 2000.0}, 1500.0)
+
+		assert.Nil(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ExpirePoints - Clears What Is Left", func(t *testing.T) {
+		mockRepo := new(MockLoyaltyRepository)
+		mockRepo.On("ListExpiredLots", mock.Anything, int64(200)).Return([]models.PointsEntry{
+			{ID: "pts_1", CustomerID: "cus_1"},
+			{ID: "pts_2", CustomerID: "cus_2"},
+		}, nil)
+		mockRepo.On("ExpireLot", "pts_1").Return(&models.PointsEntry{ID: "pts_1", CustomerID: "cus_1", Remaining: 40}, nil)
+		mockRepo.On("ExpireLot", "pts_2").Return(nil, mongo.ErrNoDocuments)
+		mockRepo.On("Record", mock.MatchedBy(func(entry models.PointsEntry) bool {
+			return entry.Kind == models.PointsKindExpired && entry.Points == -40 && entry.CustomerID == "cus_1"
+		})).Return(nil)
+
+		service := &services.LoyaltyService{Repository: mockRepo}
+		expired, err := service.ExpirePoints(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, expired)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestLoyaltyCheckout(t *testing.T) {
+	product := &models.Product{ID: "prod1", Name: "Assam Gold", Price: 400.0, InStock: true, Stock: 5}
+
+	t.Run("CreateOrder - Points Discount The Total", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockLoyalty := new(MockPointsRedeemer)
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockLoyalty.On("RedeemPoints", "cus_1", 200, 400.0, mock.Anything).Return(200, 100.0, nil)
+		mockOrderRepo.On("CreateOrder", mock.MatchedBy(func(order models.Order) bool {
+			return order.TotalAmount == 300.0 && order.PointsRedeemed == 200 && order.PointsDiscount == 100.0 &&
+				order.Status == models.OrderStatusPending
+		})).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Loyalty: mockLoyalty}
+		_, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			RedeemPoints: 200,
+			CustomerID:   "cus_1",
+		})
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("CreateOrder - Paid In Full With Points", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockLoyalty := new(MockPointsRedeemer)
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockLoyalty.On("RedeemPoints", "cus_1", 1000, 400.0, mock.Anything).Return(800, 400.0, nil)
+		mockOrderRepo.On("CreateOrder", mock.MatchedBy(func(order models.Order) bool {
+			return order.TotalAmount == 0 && order.PaymentStatus == models.PaymentStatusPaid &&
+				order.PaymentMethod == models.PaymentMethodLoyaltyPoints && order.Status == models.OrderStatusConfirmed
+		})).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Loyalty: mockLoyalty}
+		_, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			RedeemPoints: 1000,
+			CustomerID:   "cus_1",
+		})
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("CreateOrder - Guests Cannot Redeem", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 1).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Loyalty: new(MockPointsRedeemer)}
+		_, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			RedeemPoints: 100,
+		})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidPoints))
+		mockProductRepo.AssertExpectations(t)
+	})
+
+	t.Run("CancelOrder - Returns Redeemed Points", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockLoyalty := new(MockPointsRedeemer)
+		order := &models.Order{
+			ID: "ord_1", CustomerID: "cus_1", CustomerInfo: models.CustomerInfo{Phone: "9876543210"},
+			Items: []models.CartItem{{ProductID: "prod1", Quantity: 1}}, TotalAmount: 300.0,
+			PointsRedeemed: 200, PointsDiscount: 100.0, Status: models.OrderStatusPending,
+		}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockOrderRepo.On("TransitionStatus", "ord_1", mock.Anything, mock.Anything, mock.Anything).Return(order, nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 1).Return(nil)
+		mockLoyalty.On("ReturnPoints", "cus_1", 200, "ord_1").Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Loyalty: mockLoyalty}
+		_, err := service.CancelOrder(context.Background(), "ord_1", services.CancelOrderRequest{Reason: "Changed my mind", Phone: "9876543210"})
+
+		assert.Nil(t, err)
+		mockLoyalty.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Reverses Points For The Amount Refunded So Far", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+		mockLoyalty := new(MockPointsReverser)
+		order := &models.Order{ID: "ord_1", CustomerID: "cus_1", TotalAmount: 1000.0, PaymentID: "pay_1", PaymentStatus: models.PaymentStatusPartiallyRefunded,
+			Items: []models.CartItem{{ProductID: "prod1", Quantity: 4, Price: 250.0}}}
+		mockOrderRepo.On("GetOrder", "ord_1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "ord_1").Return([]models.Refund{
+			{ID: "rfd_0", Amount: 250.0, Status: models.RefundStatusProcessed, Items: []models.CartItem{{ProductID: "prod1", Quantity: 1}}},
+		}, nil)
+		mockOrderRepo.On("ReserveRefund", "ord_1", 250.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.Anything).Return(nil)
+		mockGateway.On("Refund", "pay_1", int64(25000), mock.Anything).Return(&services.GatewayRefund{ID: "rfnd_2", Status: "pending"}, nil)
+		mockRefundRepo.On("SetGatewayRefund", mock.Anything, "rfnd_2", models.RefundStatusPending).Return(nil)
+		mockOrderRepo.On("SetRefund", "ord_1", "rfnd_2", models.PaymentStatusPartiallyRefunded).Return(nil)
+		mockLoyalty.On("ReversePoints", *order, 500.0).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway, Loyalty: mockLoyalty}
+		_, err := service.IssueRefund(context.Background(), "ord_1", services.RefundRequest{Reason: "Damaged", Items: []models.CartItem{{ProductID: "prod1", Quantity: 1}}})
+
+		assert.Nil(t, err)
+		mockLoyalty.AssertExpectations(t)
+	})
+}
+
+func TestLoyaltyController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := &services.CustomerTokens{Secret: []byte("test-secret")}
+
+	newRouter := func(service services.LoyaltyServiceInterface) *gin.Engine {
+		router := gin.New()
+		controller := &controllers.LoyaltyController{Service: service}
+		router.GET("/api/loyalty", middleware.CustomerAuth(tokens), controller.GetAccount)
+		return router
+	}
+
+	t.Run("GetAccount - Requires Login", func(t *testing.T) {
+		mockService := new(MockLoyaltyService)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/loyalty", nil)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockService.AssertNotCalled(t, "GetAccount", mock.Anything)
+	})
+
+	t.Run("GetAccount - Success", func(t *testing.T) {
+		mockService := new(MockLoyaltyService)
+		mockService.On("GetAccount", "cus_1").Return(&services.LoyaltyAccount{Balance: 120, Value: 60.0, PointValue: 0.5, Entries: []models.PointsEntry{}}, nil)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/loyalty", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"balance":120`)
+	})
+}
@@ -0,0 +1,342 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/messaging"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockPreferenceRepository struct {
+	mock.Mock
+}
+
+func (m *MockPreferenceRepository) GetPreference(ctx context.Context, phone string) (*models.MessagingPreference, error) {
+	args := m.Called(phone)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.MessagingPreference), args.Error(1)
+}
+
+func (m *MockPreferenceRepository) SavePreference(ctx context.Context, preference models.MessagingPreference) error {
+	args := m.Called(preference)
+	return args.Error(0)
+}
+
+type MockOTPService struct {
+	mock.Mock
+}
+
+func (m *MockOTPService) RequestOTP(ctx context.Context, phone string, purpose string) error {
+	args := m.Called(phone, purpose)
+	return args.Error(0)
+}
+
+func (m *MockOTPService) VerifyOTP(ctx context.Context, phone string, purpose string, code string) (string, error) {
+	args := m.Called(phone, purpose, code)
+	return args.String(0), args.Error(1)
+}
+
+type MockMessagingProvider struct {
+	mock.Mock
+}
+
+func (m *MockMessagingProvider) Send(message messaging.Message) (string, error) {
+	args := m.Called(message)
+	return args.String(0), args.Error(1)
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestMessaging(t *testing.T) {
+	order := models.Order{
+		ID:           "order1",
+		CustomerInfo: models.CustomerInfo{Name: "Asha", Phone: "98765 43210"},
+		TotalAmount:  398.0,
+		Shipment:     &models.Shipment{TrackingURL: "https://track.example.com/AWB123"},
+	}
+
+	t.Run("E164 - Normalises Indian Mobile Numbers", func(t *testing.T) {
+		for _, input := range []string{"9876543210", "+91 98765 43210", "09876543210", "919876543210"} {
+			phone, ok := messaging.E164(input)
+			assert.True(t, ok, input)
+			assert.Equal(t, "+919876543210", phone, input)
+		}
+		_, ok := messaging.E164("12345")
+		assert.False(t, ok)
+	})
+
+	t.Run("HTTPProvider - Posts Message To Provider", func(t *testing.T) {
+		var received map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/messages", r.URL.Path)
+			assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+			json.NewDecoder(r.Body).Decode(&received)
+			w.Write([]byte(`{"id": "msg_1", "status": "queued"}`))
+		}))
+		defer server.Close()
+
+		provider := &messaging.HTTPProvider{BaseURL: server.URL, APIKey: "key", Sender: "MANGAL"}
+		id, err := provider.Send(messaging.Message{Channel: "whatsapp", To: "+919876543210", Template: "order_shipped", Params: []string{"Asha"}, Text: "Hi"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "msg_1", id)
+		assert.Equal(t, "+919876543210", received["to"])
+		assert.Equal(t, "order_shipped", received["template"])
+		assert.Equal(t, "MANGAL", received["from"])
+	})
+
+	t.Run("HTTPProvider - Provider Error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "invalid template", http.StatusUnprocessableEntity)
+		}))
+		defer server.Close()
+
+		provider := &messaging.HTTPProvider{BaseURL: server.URL}
+		_, err := provider.Send(messaging.Message{Channel: "sms", To: "+919876543210", Text: "Hi"})
+
+		assert.ErrorContains(t, err, "invalid template")
+	})
+
+	t.Run("Notifier - Queues Message For Opted In Phone", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+		mockPreferences := new(MockPreferenceRepository)
+		mockPreferences.On("GetPreference", "+919876543210").Return(&models.MessagingPreference{OptedIn: true, Channel: "whatsapp"}, nil)
+
+		var queued models.Notification
+		mockOutbox.On("Enqueue", mock.Anything).Run(func(args mock.Arguments) {
+			queued = args.Get(0).(models.Notification)
+		}).Return(nil)
+
+		notifier := &messaging.Notifier{Outbox: mockOutbox, Preferences: mockPreferences}
+		notifier.NotifyOrder(context.Background(), models.OrderEventShipped, order)
+
+		assert.Equal(t, models.NotificationChannelWhatsApp, queued.Channel)
+		assert.Equal(t, "+919876543210", queued.To)
+		assert.Equal(t, models.OrderEventShipped, queued.Template)
+		assert.Equal(t, []string{"Asha", "order1", "https://track.example.com/AWB123"}, queued.Params)
+		assert.Contains(t, queued.Text, "https://track.example.com/AWB123")
+	})
+
+	t.Run("Notifier - Skips Phones Without Opt In", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+		mockPreferences := new(MockPreferenceRepository)
+		mockPreferences.On("GetPreference", "+919876543210").Return(nil, mongo.ErrNoDocuments)
+
+		notifier := &messaging.Notifier{Outbox: mockOutbox, Preferences: mockPreferences}
+		notifier.NotifyOrder(context.Background(), models.OrderEventPlaced, order)
+
+		mockOutbox.AssertNotCalled(t, "Enqueue", mock.Anything)
+	})
+
+	t.Run("Sender - Records Message On Order", func(t *testing.T) {
+		mockProvider := new(MockMessagingProvider)
+		mockOrderRepo := new(MockOrderRepository)
+		mockProvider.On("Send", mock.MatchedBy(func(message messaging.Message) bool {
+			return message.Channel == "sms" && message.To == "+919876543210"
+		})).Return("msg_1", nil)
+		mockOrderRepo.On("AddMessage", "order1", mock.MatchedBy(func(delivery models.MessageDelivery) bool {
+			return delivery.ProviderMessageID == "msg_1" && delivery.Status == models.MessageStatusQueued
+		})).Return(nil)
+
+		sender := &messaging.Sender{ChannelName: "sms", Provider: mockProvider, Orders: mockOrderRepo}
+		err := sender.Send(context.Background(), models.Notification{OrderID: "order1", Event: "order_placed", Channel: "sms", To: "+919876543210", Text: "Hi"})
+
+		assert.Nil(t, err)
+		mockProvider.AssertExpectations(t)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("OptIn - Requires Valid OTP", func(t *testing.T) {
+		mockPreferences := new(MockPreferenceRepository)
+		mockOTP := new(MockOTPService)
+		mockOTP.On("VerifyOTP", "9876543210", services.OTPPurposeMessagingOptIn, "123456").Return("+919876543210", nil)
+		mockPreferences.On("SavePreference", mock.MatchedBy(func(preference models.MessagingPreference) bool {
+			return preference.Phone == "+919876543210" && preference.OptedIn && preference.Channel == "sms"
+		})).Return(nil)
+
+		service := &services.MessagingService{Preferences: mockPreferences, OTP: mockOTP}
+		preference, err := service.OptIn(context.Background(), services.OptInRequest{Phone: "9876543210", OTP: "123456", Channel: "sms"})
+
+		assert.Nil(t, err)
+		assert.True(t, preference.OptedIn)
+		mockPreferences.AssertExpectations(t)
+	})
+
+	t.Run("OptIn - Wrong OTP", func(t *testing.T) {
+		mockPreferences := new(MockPreferenceRepository)
+		mockOTP := new(MockOTPService)
+		mockOTP.On("VerifyOTP", "9876543210", services.OTPPurposeMessagingOptIn, "000000").Return("", services.ErrInvalidOTP)
+
+		service := &services.MessagingService{Preferences: mockPreferences, OTP: mockOTP}
+		_, err := service.OptIn(context.Background(), services.OptInRequest{Phone: "9876543210", OTP: "000000"})
+
+		assert.Equal(t, services.ErrInvalidOTP, err)
+		mockPreferences.AssertNotCalled(t, "SavePreference", mock.Anything)
+	})
+
+	t.Run("HandleCallback - Records Delivery Status", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("UpdateMessageStatus", "msg_1", models.MessageStatusDelivered, "").Return(nil)
+
+		body := []byte(`{"type": "status", "message_id": "msg_1", "status": "delivered"}`)
+		service := &services.MessagingService{OrderRepository: mockOrderRepo, CallbackSecret: "secret"}
+		err := service.HandleCallback(context.Background(), body, sign("secret", body))
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("HandleCallback - STOP Reply Opts Out", func(t *testing.T) {
+		mockPreferences := new(MockPreferenceRepository)
+		mockPreferences.On("GetPreference", "+919876543210").Return(&models.MessagingPreference{OptedIn: true, Channel: "whatsapp"}, nil)
+		mockPreferences.On("SavePreference", mock.MatchedBy(func(preference models.MessagingPreference) bool {
+			return preference.Phone == "+919876543210" && !preference.OptedIn && preference.Channel == "whatsapp"
+		})).Return(nil)
+
+		body := []byte(`{"type": "inbound", "from": "919876543210", "text": " stop "}`)
+		service := &services.MessagingService{Preferences: mockPreferences, CallbackSecret: "secret"}
+		err := service.HandleCallback(context.Background(), body, sign("secret", body))
+
+		assert.Nil(t, err)
+		mockPreferences.AssertExpectations(t)
+	})
+
+	t.Run("HandleCallback - Invalid Signature", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		body := []byte(`{"type": "status", "message_id": "msg_1", "status": "delivered"}`)
+
+		service := &services.MessagingService{OrderRepository: mockOrderRepo, CallbackSecret: "secret"}
+		err := service.HandleCallback(context.Background(), body, sign("other", body))
+
+		assert.Equal(t, services.ErrInvalidCallbackSignature, err)
+		mockOrderRepo.AssertNotCalled(t, "UpdateMessageStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+type MockOTPRepository struct {
+	mock.Mock
+}
+
+func (m *MockOTPRepository) SaveOTP(ctx context.Context, otp models.OneTimePassword) error {
+	args := m.Called(otp)
+	return args.Error(0)
+}
+
+func (m *MockOTPRepository) GetOTP(ctx context.Context, phone string, purpose string) (*models.OneTimePassword, error) {
+	args := m.Called(phone, purpose)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.OneTimePassword), args.Error(1)
+}
+
+func (m *MockOTPRepository) IncrementOTPAttempts(ctx context.Context, phone string, purpose string) error {
+	args := m.Called(phone, purpose)
+	return args.Error(0)
+}
+
+func (m *MockOTPRepository) DeleteOTP(ctx context.Context, phone string, purpose string) error {
+	args := m.Called(phone, purpose)
+	return args.Error(0)
+}
+
+type MockOTPSender struct {
+	mock.Mock
+}
+
+func (m *MockOTPSender) SendOTP(phone string, code string, ttl time.Duration) error {
+	args := m.Called(phone, code, ttl)
+	return args.Error(0)
+}
+
+func TestOTPService(t *testing.T) {
+	t.Run("RequestOTP Then VerifyOTP", func(t *testing.T) {
+		mockRepo := new(MockOTPRepository)
+		mockSender := new(MockOTPSender)
+
+		var saved models.OneTimePassword
+		var code string
+		mockRepo.On("GetOTP", "+919876543210", "login").Return(nil, mongo.ErrNoDocuments).Once()
+		mockRepo.On("SaveOTP", mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(models.OneTimePassword)
+		}).Return(nil)
+		mockSender.On("SendOTP", "+919876543210", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			code = args.String(1)
+		}).Return(nil)
+
+		service := &services.OTPService{Repository: mockRepo, Sender: mockSender}
+		assert.Nil(t, service.RequestOTP(context.Background(), "98765 43210", "login"))
+		assert.Len(t, code, 6)
+		assert.NotContains(t, saved.CodeHash, code)
+
+		mockRepo.On("GetOTP", "+919876543210", "login").Return(&saved, nil)
+		mockRepo.On("DeleteOTP", "+919876543210", "login").Return(nil)
+
+		phone, err := service.VerifyOTP(context.Background(), "9876543210", "login", code)
+		assert.Nil(t, err)
+		assert.Equal(t, "+919876543210", phone)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("RequestOTP - Too Soon", func(t *testing.T) {
+		mockRepo := new(MockOTPRepository)
+		mockSender := new(MockOTPSender)
+		mockRepo.On("GetOTP", "+919876543210", "login").Return(&models.OneTimePassword{CreatedAt: time.Now()}, nil)
+
+		service := &services.OTPService{Repository: mockRepo, Sender: mockSender}
+		err := service.RequestOTP(context.Background(), "9876543210", "login")
+
+		assert.Equal(t, services.ErrOTPTooSoon, err)
+		mockSender.AssertNotCalled(t, "SendOTP", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("VerifyOTP - Wrong Code Counts Attempt", func(t *testing.T) {
+		mockRepo := new(MockOTPRepository)
+		mockRepo.On("GetOTP", "+919876543210", "login").Return(&models.OneTimePassword{
+			CodeHash:  "not-the-hash",
+			ExpiresAt: time.Now().Add(time.Minute),
+		}, nil)
+		mockRepo.On("IncrementOTPAttempts", "+919876543210", "login").Return(nil)
+
+		service := &services.OTPService{Repository: mockRepo}
+		_, err := service.VerifyOTP(context.Background(), "9876543210", "login", "123456")
+
+		assert.Equal(t, services.ErrInvalidOTP, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("VerifyOTP - Too Many Attempts", func(t *testing.T) {
+		mockRepo := new(MockOTPRepository)
+		mockRepo.On("GetOTP", "+919876543210", "login").Return(&models.OneTimePassword{
+			Attempts:  5,
+			ExpiresAt: time.Now().Add(time.Minute),
+		}, nil)
+
+		service := &services.OTPService{Repository: mockRepo}
+		_, err := service.VerifyOTP(context.Background(), "9876543210", "login", "123456")
+
+		assert.Equal(t, services.ErrInvalidOTP, err)
+		mockRepo.AssertNotCalled(t, "IncrementOTPAttempts", mock.Anything, mock.Anything)
+	})
+}
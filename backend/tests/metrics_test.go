@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/metrics"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The metrics are process-wide, so these tests compare counts before and after rather than absolute values.
+
+func TestMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.Metrics())
+	router.GET("/api/orders/:order_id", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	send := func(method, path string) {
+		req, _ := http.NewRequest(method, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	t.Run("Metrics - Labels By Route Pattern", func(t *testing.T) {
+		counter := metrics.HTTPRequests.WithLabelValues("GET", "/api/orders/:order_id", "200")
+		before := testutil.ToFloat64(counter)
+
+		send(http.MethodGet, "/api/orders/ord_1")
+		send(http.MethodGet, "/api/orders/ord_2")
+
+		assert.Equal(t, before+2, testutil.ToFloat64(counter))
+	})
+
+	t.Run("Metrics - Unmatched Requests Share One Series", func(t *testing.T) {
+		counter := metrics.HTTPRequests.WithLabelValues("unmatched", "unmatched", "404")
+		before := testutil.ToFloat64(counter)
+
+		send(http.MethodGet, "/wp-login.php")
+		send("PROPFIND", "/.env")
+
+		assert.Equal(t, before+2, testutil.ToFloat64(counter))
+	})
+
+	t.Run("Handler - Serves Text Format", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+		metrics.Handler().ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "mangal_http_requests_total")
+		assert.Contains(t, rr.Body.String(), "go_goroutines")
+	})
+}
+
+func TestMongoMonitor(t *testing.T) {
+	t.Run("MongoMonitor - Counts Failures Outside Repositories As Other", func(t *testing.T) {
+		monitor := metrics.MongoMonitor()
+		errorsBefore := testutil.ToFloat64(metrics.MongoErrors.WithLabelValues("other", "ping"))
+
+		monitor.Started(context.Background(), &event.CommandStartedEvent{CommandName: "ping", RequestID: 1})
+		monitor.Started(context.Background(), &event.CommandStartedEvent{CommandName: "ping", RequestID: 2})
+		monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{
+			CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "ping", RequestID: 1, Duration: time.Millisecond},
+		})
+		monitor.Failed(context.Background(), &event.CommandFailedEvent{
+			CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "ping", RequestID: 2, Duration: time.Millisecond},
+		})
+		// A finish without a matching start is ignored.
+		monitor.Failed(context.Background(), &event.CommandFailedEvent{
+			CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "ping", RequestID: 3},
+		})
+
+		assert.Equal(t, errorsBefore+1, testutil.ToFloat64(metrics.MongoErrors.WithLabelValues("other", "ping")))
+		assert.Positive(t, testutil.CollectAndCount(metrics.MongoDuration))
+	})
+}
+
+func TestBusinessMetrics(t *testing.T) {
+	t.Run("CreateOrder - Counts Orders And Out Of Stock Rejections", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", Price: 10.0, InStock: true, Stock: 5}, nil)
+		mockProductRepo.On("GetProduct", "prod_sold_out").Return(&models.Product{ID: "prod_sold_out", Price: 10.0, InStock: false}, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockOrderRepo.On("CreateOrder", mock.Anything).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
+		created := metrics.OrdersCreated.WithLabelValues("storefront")
+		rejected := metrics.OutOfStockRejections.WithLabelValues("prod_sold_out")
+		createdBefore, rejectedBefore := testutil.ToFloat64(created), testutil.ToFloat64(rejected)
+
+		_, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			CustomerInfo: models.CustomerInfo{Name: "John Doe"},
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+		})
+		assert.Nil(t, err)
+		_, err = service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			CustomerInfo: models.CustomerInfo{Name: "John Doe"},
+			Items:        []models.CartItem{{ProductID: "prod_sold_out", Quantity: 1}},
+		})
+		assert.NotNil(t, err)
+
+		assert.Equal(t, createdBefore+1, testutil.ToFloat64(created))
+		assert.Equal(t, rejectedBefore+1, testutil.ToFloat64(rejected))
+	})
+
+	t.Run("HandleWebhook - Counts Payments And Revenue", func(t *testing.T) {
+		captured := []byte(`{"event": "payment.captured", "payload": {"payment": {"entity": {"id": "pay_m1", "order_id": "gw_order_m1", "method": "card"}}}}`)
+		failed := []byte(`{"event": "payment.failed", "payload": {"payment": {"entity": {"id": "pay_m2", "order_id": "gw_order_m2", "method": "card", "error_reason": "payment_cancelled"}}}}`)
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		mockGateway.On("VerifyWebhookSignature", mock.Anything, "sig").Return(true)
+		mockOrderRepo.On("RecordPayment", "gw_order_m1", "pay_m1", "card").Return(&models.Order{ID: "order1", Status: models.OrderStatusPending, TotalAmount: 250}, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", mock.Anything, mock.Anything, mock.Anything).Return(&models.Order{ID: "order1", Status: models.OrderStatusConfirmed}, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		succeeded := metrics.PaymentsSucceeded.WithLabelValues("card")
+		revenue := metrics.Revenue.WithLabelValues("card")
+		cancelled := metrics.PaymentsFailed.WithLabelValues("payment_cancelled")
+		succeededBefore, revenueBefore, cancelledBefore := testutil.ToFloat64(succeeded), testutil.ToFloat64(revenue), testutil.ToFloat64(cancelled)
+
+		assert.Nil(t, service.HandleWebhook(context.Background(), captured, "sig"))
+		assert.Nil(t, service.HandleWebhook(context.Background(), failed, "sig"))
+
+		assert.Equal(t, succeededBefore+1, testutil.ToFloat64(succeeded))
+		assert.Equal(t, revenueBefore+250, testutil.ToFloat64(revenue))
+		assert.Equal(t, cancelledBefore+1, testutil.ToFloat64(cancelled))
+		mockOrderRepo.AssertNotCalled(t, "RecordPayment", "gw_order_m2", mock.Anything, mock.Anything)
+	})
+
+	t.Run("HandleWebhook - Payment For A Cancelled Order Is Not Revenue", func(t *testing.T) {
+		captured := []byte(`{"event": "payment.captured", "payload": {"payment": {"entity": {"id": "pay_m3", "order_id": "gw_order_m3", "method": "netbanking"}}}}`)
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefunds := new(MockRefundService)
+		mockGateway.On("VerifyWebhookSignature", mock.Anything, "sig").Return(true)
+		mockOrderRepo.On("RecordPayment", "gw_order_m3", "pay_m3", "netbanking").Return(&models.Order{ID: "order3", Status: models.OrderStatusPending, TotalAmount: 400}, nil)
+		mockOrderRepo.On("TransitionStatus", "order3", mock.Anything, mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+		mockOrderRepo.On("GetOrder", "order3").Return(&models.Order{ID: "order3", Status: models.OrderStatusCancelled, TotalAmount: 400, PaymentMethod: "netbanking"}, nil)
+		mockRefunds.On("IssueRefund", "order3", mock.Anything).Return(&models.Refund{ID: "rfd_3"}, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, mockRefunds)
+		succeeded := metrics.PaymentsSucceeded.WithLabelValues("netbanking")
+		revenue := metrics.Revenue.WithLabelValues("netbanking")
+		succeededBefore, revenueBefore := testutil.ToFloat64(succeeded), testutil.ToFloat64(revenue)
+
+		assert.Nil(t, service.HandleWebhook(context.Background(), captured, "sig"))
+
+		assert.Equal(t, succeededBefore+1, testutil.ToFloat64(succeeded))
+		assert.Equal(t, revenueBefore, testutil.ToFloat64(revenue))
+		mockRefunds.AssertExpectations(t)
+	})
+
+	t.Run("UpdateFromGateway - Counts A Processed Refund Once", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockRefundRepo.On("GetRefundByGatewayID", "rfnd_m1").Return(&models.Refund{ID: "rfd_m1", OrderID: "order1", Amount: 150, Status: models.RefundStatusPending}, nil).Once()
+		mockRefundRepo.On("GetRefundByGatewayID", "rfnd_m1").Return(&models.Refund{ID: "rfd_m1", OrderID: "order1", Amount: 150, Status: models.RefundStatusProcessed}, nil).Once()
+		mockRefundRepo.On("UpdateRefundStatus", "rfd_m1", models.RefundStatusProcessed, "").Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo}
+		refunds := metrics.Refunds.WithLabelValues(models.RefundMethodGateway)
+		refundsBefore := testutil.ToFloat64(refunds)
+
+		assert.Nil(t, service.UpdateFromGateway(context.Background(), "rfnd_m1", "processed", ""))
+		assert.Nil(t, service.UpdateFromGateway(context.Background(), "rfnd_m1", "processed", ""))
+
+		assert.Equal(t, refundsBefore+150, testutil.ToFloat64(refunds))
+		mockRefundRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/database"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMigrator(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("Up - Applies Pending In Order", func(mt *mtest.T) {
+		var ran []int
+		step := func(version int) func(db *mongo.Database) error {
+			return func(db *mongo.Database) error {
+				ran = append(ran, version)
+				return nil
+			}
+		}
+		migrator := &database.Migrator{DB: mt.DB, Migrations: []database.Migration{
+			{Version: 2, Description: "second", Up: step(2)},
+			{Version: 1, Description: "first", Up: step(1)},
+			{Version: 3, Description: "third", Up: step(3)},
+		}}
+
+		applied := bson.D{{Key: "version", Value: 1}, {Key: "description", Value: "first"}, {Key: "applied_at", Value: time.Now()}}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "foo.schema_migrations", mtest.FirstBatch, applied),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		count, err := migrator.Up(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []int{2, 3}, ran)
+	})
+
+	mt.Run("Up - Schema Ahead", func(mt *mtest.T) {
+		migrator := &database.Migrator{DB: mt.DB, Migrations: []database.Migration{
+			{Version: 1, Description: "first", Up: func(db *mongo.Database) error { return nil }},
+		}}
+
+		applied := bson.D{{Key: "version", Value: 7}, {Key: "description", Value: "from the future"}}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.schema_migrations", mtest.FirstBatch, applied))
+
+		count, err := migrator.Up(context.Background())
+		assert.True(t, errors.Is(err, database.ErrSchemaAhead))
+		assert.Equal(t, 0, count)
+	})
+
+	mt.Run("Up - Stops On Failure", func(mt *mtest.T) {
+		migrator := &database.Migrator{DB: mt.DB, Migrations: []database.Migration{
+			{Version: 1, Description: "first", Up: func(db *mongo.Database) error { return errors.New("boom") }},
+			{Version: 2, Description: "second", Up: func(db *mongo.Database) error {
+				t.Fatal("migration after a failure must not run")
+				return nil
+			}},
+		}}
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.schema_migrations", mtest.FirstBatch))
+
+		count, err := migrator.Up(context.Background())
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "boom")
+		assert.Equal(t, 0, count)
+	})
+}
@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/notifications"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockNotificationRepository struct {
+	mock.Mock
+}
+
+func (m *MockNotificationRepository) Enqueue(ctx context.Context, notification models.Notification) error {
+	args := m.Called(notification)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) ClaimDue(ctx context.Context, channel string, now time.Time, lease time.Duration) (*models.Notification, error) {
+	args := m.Called(channel, now, lease)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) MarkSent(ctx context.Context, id string, sentAt time.Time) error {
+	args := m.Called(id, sentAt)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) MarkFailed(ctx context.Context, id string, lastError string, retryAt time.Time, giveUp bool) error {
+	args := m.Called(id, lastError, retryAt, giveUp)
+	return args.Error(0)
+}
+
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) Send(email notifications.Email) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func TestNotifications(t *testing.T) {
+	order := models.Order{
+		ID:           "order1",
+		CustomerInfo: models.CustomerInfo{Name: "Asha", Email: "asha@example.com", Address: "12 MG Road, Pune"},
+		Items:        []models.CartItem{{ProductID: "prod1", Quantity: 2, Price: 199.0}},
+		TotalAmount:  398.0,
+		Shipment:     &models.Shipment{Courier: "Delhivery", TrackingNumber: "AWB123", TrackingURL: "https://track.example.com/AWB123"},
+	}
+
+	t.Run("NotifyOrder - Queues Rendered Email", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", Name: "Masala Chai"}, nil)
+
+		var queued models.Notification
+		mockOutbox.On("Enqueue", mock.Anything).Run(func(args mock.Arguments) {
+			queued = args.Get(0).(models.Notification)
+		}).Return(nil)
+
+		notifier := &notifications.Notifier{Outbox: mockOutbox, Products: mockProductRepo}
+		notifier.NotifyOrder(context.Background(), models.OrderEventShipped, order)
+
+		assert.Equal(t, "asha@example.com", queued.To)
+		assert.Equal(t, models.NotificationChannelEmail, queued.Channel)
+		assert.Equal(t, models.NotificationStatusPending, queued.Status)
+		assert.Equal(t, "Your order order1 is on its way", queued.Subject)
+		assert.Contains(t, queued.HTML, "Masala Chai")
+		assert.Contains(t, queued.HTML, `href="https://track.example.com/AWB123"`)
+		assert.Contains(t, queued.Text, "Tracking number: AWB123")
+		assert.Contains(t, queued.Text, "₹398.00")
+		mockOutbox.AssertExpectations(t)
+	})
+
+	t.Run("NotifyOrder - Escapes Customer Input In HTML", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+		var queued models.Notification
+		mockOutbox.On("Enqueue", mock.Anything).Run(func(args mock.Arguments) {
+			queued = args.Get(0).(models.Notification)
+		}).Return(nil)
+
+		hostile := order
+		hostile.CustomerInfo.Name = "<script>alert(1)</script>"
+		notifier := &notifications.Notifier{Outbox: mockOutbox}
+		notifier.NotifyOrder(context.Background(), models.OrderEventPlaced, hostile)
+
+		assert.NotContains(t, queued.HTML, "<script>")
+		assert.Contains(t, queued.Text, "prod1")
+	})
+
+	t.Run("NotifyOrder - Cancellation Wording Follows The Refund", func(t *testing.T) {
+		render := func(paymentStatus string) models.Notification {
+			mockOutbox := new(MockNotificationRepository)
+			var queued models.Notification
+			mockOutbox.On("Enqueue", mock.Anything).Run(func(args mock.Arguments) {
+				queued = args.Get(0).(models.Notification)
+			}).Return(nil)
+
+			cancelled := order
+			cancelled.PaymentID = "pay_1"
+			cancelled.PaymentStatus = paymentStatus
+			notifier := &notifications.Notifier{Outbox: mockOutbox}
+			notifier.NotifyOrder(context.Background(), models.OrderEventCancelled, cancelled)
+			return queued
+		}
+
+		refunded := render(models.PaymentStatusRefunded)
+		assert.Contains(t, refunded.Text, "is being refunded")
+		assert.Contains(t, refunded.HTML, "original payment method")
+
+		failed := render(models.PaymentStatusRefundFailed)
+		assert.NotContains(t, failed.Text, "is being refunded")
+		assert.Contains(t, failed.Text, "could not refund your payment")
+
+		unpaid := render("")
+		assert.NotContains(t, unpaid.Text, "refund")
+		assert.NotContains(t, unpaid.HTML, "refund")
+	})
+
+	t.Run("NotifyOrder - Skips Orders Without Email", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+
+		noEmail := order
+		noEmail.CustomerInfo.Email = ""
+		notifier := &notifications.Notifier{Outbox: mockOutbox}
+		notifier.NotifyOrder(context.Background(), models.OrderEventPlaced, noEmail)
+
+		mockOutbox.AssertNotCalled(t, "Enqueue", mock.Anything)
+	})
+
+	t.Run("DispatchDue - Sends And Marks Sent", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+		mockMailer := new(MockMailer)
+
+		queued := &models.Notification{ID: "ntf_1", To: "asha@example.com", Subject: "Hi", Text: "Hello", Attempts: 1}
+		mockOutbox.On("ClaimDue", models.NotificationChannelEmail, mock.Anything, mock.Anything).Return(queued, nil).Once()
+		mockOutbox.On("ClaimDue", models.NotificationChannelEmail, mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments).Once()
+		mockMailer.On("Send", notifications.Email{To: "asha@example.com", Subject: "Hi", Text: "Hello"}).Return(nil)
+		mockOutbox.On("MarkSent", "ntf_1", mock.Anything).Return(nil)
+
+		dispatcher := &notifications.Dispatcher{Outbox: mockOutbox, Sender: &notifications.EmailSender{Mailer: mockMailer}}
+		attempted, err := dispatcher.DispatchDue(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, attempted)
+		mockOutbox.AssertExpectations(t)
+		mockMailer.AssertExpectations(t)
+	})
+
+	t.Run("DispatchDue - Retries With Backoff Then Gives Up", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+		mockMailer := new(MockMailer)
+
+		first := &models.Notification{ID: "ntf_1", To: "a@example.com", Attempts: 1}
+		last := &models.Notification{ID: "ntf_2", To: "b@example.com", Attempts: 3}
+		mockOutbox.On("ClaimDue", mock.Anything, mock.Anything, mock.Anything).Return(first, nil).Once()
+		mockOutbox.On("ClaimDue", mock.Anything, mock.Anything, mock.Anything).Return(last, nil).Once()
+		mockOutbox.On("ClaimDue", mock.Anything, mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments).Once()
+		mockMailer.On("Send", mock.Anything).Return(errors.New("connection refused"))
+
+		retryAt := func(min time.Duration) interface{} {
+			return mock.MatchedBy(func(at time.Time) bool {
+				return at.After(time.Now().Add(min - time.Second))
+			})
+		}
+		mockOutbox.On("MarkFailed", "ntf_1", "connection refused", retryAt(time.Minute), false).Return(nil)
+		mockOutbox.On("MarkFailed", "ntf_2", "connection refused", retryAt(4*time.Minute), true).Return(nil)
+
+		dispatcher := &notifications.Dispatcher{Outbox: mockOutbox, Sender: &notifications.EmailSender{Mailer: mockMailer}, MaxAttempts: 3}
+		attempted, err := dispatcher.DispatchDue(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, attempted)
+		mockOutbox.AssertExpectations(t)
+	})
+
+	t.Run("LogMailer - Writes EML File", func(t *testing.T) {
+		dir := t.TempDir()
+		mailer := &notifications.LogMailer{Dir: dir, From: "Mangal Chai <orders@example.com>"}
+
+		err := mailer.Send(notifications.Email{To: "asha@example.com", Subject: "Your order", HTML: "<p>Hi</p>", Text: "Hi"})
+		assert.Nil(t, err)
+
+		files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+		assert.Len(t, files, 1)
+		content, _ := os.ReadFile(files[0])
+		assert.True(t, strings.Contains(string(content), "To: asha@example.com"))
+		assert.Contains(t, string(content), "multipart/alternative")
+		assert.Contains(t, string(content), "<p>Hi</p>")
+	})
+}
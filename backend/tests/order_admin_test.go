@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestOrderAdmin(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+
+	t.Run("ListOrders - Builds Filter And Page", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		expected := repositories.OrderFilter{
+			Status:        []string{"confirmed", "packed"},
+			From:          time.Date(2024, 5, 1, 0, 0, 0, 0, ist),
+			To:            time.Date(2024, 5, 2, 0, 0, 0, 0, ist),
+			Phone:         "9876543210",
+			MinAmount:     500,
+			SortField:     "total_amount",
+			SortAscending: false,
+			Skip:          50,
+			Limit:         50,
+		}
+		mockOrderRepo.On("ListOrders", mock.MatchedBy(func(filter repositories.OrderFilter) bool {
+			return assert.ObjectsAreEqual(expected.Status, filter.Status) &&
+				filter.From.Equal(expected.From) && filter.To.Equal(expected.To) &&
+				filter.Phone == expected.Phone && filter.MinAmount == expected.MinAmount &&
+				filter.SortField == expected.SortField && !filter.SortAscending &&
+				filter.Skip == expected.Skip && filter.Limit == expected.Limit
+		})).Return([]models.Order{{ID: "order1"}}, int64(51), nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		page, err := service.ListOrders(context.Background(), services.OrderListQuery{
+			Status:    "confirmed, packed",
+			From:      "2024-05-01",
+			To:        "2024-05-01",
+			Phone:     "+91 98765 43210",
+			MinAmount: 500,
+			Sort:      "-total_amount",
+			Page:      2,
+			PageSize:  50,
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(51), page.Total)
+		assert.Equal(t, 2, page.Page)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("ListOrders - Caps Page Size", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("ListOrders", mock.MatchedBy(func(filter repositories.OrderFilter) bool {
+			return filter.Skip == 0 && filter.Limit == 100
+		})).Return([]models.Order{}, int64(0), nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		page, err := service.ListOrders(context.Background(), services.OrderListQuery{PageSize: 1000})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 100, page.PageSize)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("ListOrders - Invalid Date", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		_, err := service.ListOrders(context.Background(), services.OrderListQuery{From: "01/05/2024"})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidOrderQuery))
+		mockOrderRepo.AssertNotCalled(t, "ListOrders", mock.Anything)
+	})
+
+	t.Run("BulkUpdateStatus - Skips Orders In Other Statuses", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		from := []string{models.OrderStatusConfirmed}
+		isPacked := mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.OrderStatusPacked && change.Reason == "morning batch"
+		})
+		mockOrderRepo.On("TransitionStatus", "order1", from, isPacked, mock.Anything).Return(&models.Order{ID: "order1"}, nil).Once()
+		mockOrderRepo.On("TransitionStatus", "order2", from, isPacked, mock.Anything).Return(nil, mongo.ErrNoDocuments).Once()
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		result, err := service.BulkUpdateStatus(context.Background(), services.BulkStatusRequest{
+			OrderIDs: []string{"order1", "order2", "order1"},
+			Status:   models.OrderStatusPacked,
+			Reason:   "morning batch",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"order1"}, result.Updated)
+		assert.Contains(t, result.Skipped, "order2")
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("BulkUpdateStatus - Rejects Non Fulfilment Status", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		_, err := service.BulkUpdateStatus(context.Background(), services.BulkStatusRequest{OrderIDs: []string{"order1"}, Status: models.OrderStatusCancelled})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidStatusChange))
+		mockOrderRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ShipOrder - Records Tracking And Notifies", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPacked}, mock.Anything, notifies(models.OrderEventShipped)).
+			Return(&models.Order{ID: "order1", Status: models.OrderStatusShipped}, nil)
+		mockOrderRepo.On("SetShipment", "order1", mock.MatchedBy(func(shipment models.Shipment) bool {
+			return shipment.Courier == "Delhivery" && shipment.TrackingNumber == "AWB123"
+		})).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		order, err := service.ShipOrder(context.Background(), "order1", services.ShipOrderRequest{
+			Courier:        "Delhivery",
+			TrackingNumber: "AWB123",
+			TrackingURL:    "https://track.example.com/AWB123",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "AWB123", order.Shipment.TrackingNumber)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("ShipOrder - Not Packed", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("TransitionStatus", "order1", mock.Anything, mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		order, err := service.ShipOrder(context.Background(), "order1", services.ShipOrderRequest{})
+
+		assert.Equal(t, services.ErrOrderNotShippable, err)
+		assert.Nil(t, order)
+		mockOrderRepo.AssertNotCalled(t, "SetShipment", mock.Anything, mock.Anything)
+	})
+}
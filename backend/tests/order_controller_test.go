@@ -3,14 +3,18 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/middleware"
 	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -21,11 +25,7 @@ type MockOrderService struct {
 	mock.Mock
 }
 
-func (m *MockOrderService) CreateOrder(orderData struct {
-	CustomerInfo models.CustomerInfo `json:"customer_info"`
-	Items        []models.CartItem   `json:"items"`
-	Notes        string              `json:"notes"`
-}) (*models.Order, error) {
+func (m *MockOrderService) CreateOrder(ctx context.Context, orderData services.CreateOrderRequest) (*models.Order, error) {
 	args := m.Called(orderData)
 	val := args.Get(0)
 	if val == nil {
@@ -34,8 +34,8 @@ func (m *MockOrderService) CreateOrder(orderData struct {
 	return val.(*models.Order), args.Error(1)
 }
 
-func (m *MockOrderService) GetOrder(id string) (*models.Order, error) {
-	args := m.Called(id)
+func (m *MockOrderService) GetOrder(ctx context.Context, id string, access services.OrderAccess) (*models.Order, error) {
+	args := m.Called(id, access)
 	val := args.Get(0)
 	if val == nil {
 		return nil, args.Error(1)
@@ -43,6 +43,51 @@ func (m *MockOrderService) GetOrder(id string) (*models.Order, error) {
 	return val.(*models.Order), args.Error(1)
 }
 
+func (m *MockOrderService) CancelOrder(ctx context.Context, id string, request services.CancelOrderRequest) (*models.Order, error) {
+	args := m.Called(id, request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) ListOrders(ctx context.Context, query services.OrderListQuery) (*services.OrderPage, error) {
+	args := m.Called(query)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.OrderPage), args.Error(1)
+}
+
+func (m *MockOrderService) BulkUpdateStatus(ctx context.Context, request services.BulkStatusRequest) (*services.BulkStatusResult, error) {
+	args := m.Called(request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.BulkStatusResult), args.Error(1)
+}
+
+func (m *MockOrderService) ShipOrder(ctx context.Context, id string, request services.ShipOrderRequest) (*models.Order, error) {
+	args := m.Called(id, request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderService) TrackOrder(ctx context.Context, request services.TrackOrderRequest) (*services.OrderTracking, error) {
+	args := m.Called(request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.OrderTracking), args.Error(1)
+}
+
 func TestOrderController(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -56,6 +101,7 @@ func TestOrderController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 
 		orderData := map[string]interface{}{
 			"customer_info": map[string]string{"name": "John Doe"},
@@ -81,6 +127,7 @@ func TestOrderController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 
 		orderData := map[string]interface{}{
 			"customer_info": map[string]string{"name": "John Doe"},
@@ -102,12 +149,13 @@ func TestOrderController(t *testing.T) {
 	t.Run("GetOrder - Success", func(t *testing.T) {
 		mockService := new(MockOrderService)
 		expectedOrder := &models.Order{ID: "order1", CustomerInfo: models.CustomerInfo{Name: "John Doe"}}
-		mockService.On("GetOrder", "order1").Return(expectedOrder, nil)
+		mockService.On("GetOrder", "order1", services.OrderAccess{Phone: "9876543210"}).Return(expectedOrder, nil)
 
 		controller := &controllers.OrderController{Service: mockService}
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/?phone=9876543210", nil)
 		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
 
 		controller.GetOrder(c)
@@ -117,14 +165,49 @@ func TestOrderController(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 
+	t.Run("GetOrder - Logged In Customer", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		mockService.On("GetOrder", "order1", services.OrderAccess{CustomerID: "cus_1"}).Return(&models.Order{ID: "order1", CustomerID: "cus_1"}, nil)
+
+		controller := &controllers.OrderController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
+		c.Set(middleware.CustomerIDKey, "cus_1")
+
+		controller.GetOrder(c)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("GetOrder - Requires Phone Or Email", func(t *testing.T) {
+		mockService := new(MockOrderService)
+
+		controller := &controllers.OrderController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
+
+		controller.GetOrder(c)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertNotCalled(t, "GetOrder", mock.Anything, mock.Anything)
+	})
+
 	t.Run("GetOrder - Not Found", func(t *testing.T) {
 		mockService := new(MockOrderService)
-		mockService.On("GetOrder", "order1").Return(nil, errors.New("not found"))
+		mockService.On("GetOrder", "order1", services.OrderAccess{Email: "a@example.com"}).Return(nil, services.ErrOrderNotFound)
 
 		controller := &controllers.OrderController{Service: mockService}
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/?email=a@example.com", nil)
 		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
 
 		controller.GetOrder(c)
@@ -133,4 +216,120 @@ func TestOrderController(t *testing.T) {
 		assert.Contains(t, rr.Body.String(), "Order not found")
 		mockService.AssertExpectations(t)
 	})
+
+	// Test CancelOrder
+	t.Run("CancelOrder - Success", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		request := services.CancelOrderRequest{Reason: "ordered twice", Phone: "9876543210"}
+		cancelled := &models.Order{ID: "order1", Status: models.OrderStatusCancelled}
+		mockService.On("CancelOrder", "order1", request).Return(cancelled, nil)
+
+		controller := &controllers.OrderController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
+		body, _ := json.Marshal(request)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/orders/order1/cancel", bytes.NewBuffer(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		controller.CancelOrder(c)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "Order cancelled")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("CancelOrder - Missing Contact", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		controller := &controllers.OrderController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/orders/order1/cancel", bytes.NewBufferString(`{"reason": "changed my mind"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		controller.CancelOrder(c)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertNotCalled(t, "CancelOrder", mock.Anything, mock.Anything)
+	})
+
+	t.Run("CancelOrder - Already Packed", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		mockService.On("CancelOrder", "order1", mock.Anything).Return(nil, services.ErrOrderNotCancellable)
+
+		controller := &controllers.OrderController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/orders/order1/cancel", bytes.NewBufferString(`{"reason": "late", "email": "a@b.com"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		controller.CancelOrder(c)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("ListOrders - Binds Filters", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		query := services.OrderListQuery{Status: "confirmed,packed", From: "2024-05-01", MinAmount: 500, Page: 2}
+		mockService.On("ListOrders", query).Return(&services.OrderPage{Orders: []models.Order{{ID: "order1"}}, Total: 21, Page: 2, PageSize: 20}, nil)
+
+		controller := &controllers.OrderController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/orders?status=confirmed,packed&from=2024-05-01&min_amount=500&page=2", nil)
+
+		controller.ListOrders(c)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"total":21`)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("ListOrders - Invalid Query", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		mockService.On("ListOrders", mock.Anything).Return(nil, fmt.Errorf("%w: bad sort", services.ErrInvalidOrderQuery))
+
+		controller := &controllers.OrderController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/orders?sort=name", nil)
+
+		controller.ListOrders(c)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("BulkUpdateStatus - Success", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		request := services.BulkStatusRequest{OrderIDs: []string{"order1", "order2"}, Status: "packed"}
+		result := &services.BulkStatusResult{Updated: []string{"order1"}, Skipped: map[string]string{"order2": "order not found or not confirmed"}}
+		mockService.On("BulkUpdateStatus", request).Return(result, nil)
+
+		controller := &controllers.OrderController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/orders/status", bytes.NewBufferString(`{"order_ids": ["order1", "order2"], "status": "packed"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		controller.BulkUpdateStatus(c)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "order2")
+		mockService.AssertExpectations(t)
+	})
 }
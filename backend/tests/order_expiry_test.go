@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockPaymentReconciler struct {
+	mock.Mock
+}
+
+func (m *MockPaymentReconciler) ReconcilePayment(ctx context.Context, order models.Order) (bool, error) {
+	args := m.Called(order)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestOrderExpiry(t *testing.T) {
+	unpaid := models.Order{
+		ID:                     "order1",
+		Status:                 models.OrderStatusPending,
+		PaymentGatewayOrderID:  "gw_order_1",
+		PaymentGatewayOrderIDs: []string{"gw_order_1"},
+		Items:                  []models.CartItem{{ProductID: "prod1", Quantity: 2, Price: 10.0}},
+	}
+
+	t.Run("ExpireUnpaidOrders - Expires And Releases Stock", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockPayments := new(MockPaymentReconciler)
+		var cutoff time.Time
+		mockOrderRepo.On("ListUnpaidBefore", mock.Anything, int64(200)).Run(func(args mock.Arguments) {
+			cutoff = args.Get(0).(time.Time)
+		}).Return([]models.Order{unpaid}, nil)
+		mockPayments.On("ReconcilePayment", unpaid).Return(false, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPending}, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.OrderStatusExpired && change.Reason == "not paid within 45m0s"
+		}), mock.Anything).Return(&models.Order{ID: "order1", Status: models.OrderStatusExpired, Items: unpaid.Items}, nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 2).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Payments: mockPayments, PaymentWindow: 45 * time.Minute}
+		expired, err := service.ExpireUnpaidOrders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, expired)
+		assert.WithinDuration(t, time.Now().Add(-45*time.Minute), cutoff, 5*time.Second)
+		mockOrderRepo.AssertExpectations(t)
+		mockProductRepo.AssertExpectations(t)
+	})
+
+	t.Run("ExpireUnpaidOrders - Late Capture Is Not Expired", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockPayments := new(MockPaymentReconciler)
+		mockOrderRepo.On("ListUnpaidBefore", mock.Anything, int64(200)).Return([]models.Order{unpaid}, nil)
+		mockPayments.On("ReconcilePayment", unpaid).Return(true, nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Payments: mockPayments}
+		expired, err := service.ExpireUnpaidOrders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, expired)
+		mockOrderRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockProductRepo.AssertNotCalled(t, "ReleaseStock", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ExpireUnpaidOrders - Already Paid Or Cancelled Keeps Stock", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockOrderRepo.On("ListUnpaidBefore", mock.Anything, int64(200)).Return([]models.Order{unpaid}, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPending}, mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
+		expired, err := service.ExpireUnpaidOrders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, expired)
+		mockProductRepo.AssertNotCalled(t, "ReleaseStock", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ExpireUnpaidOrders - Gateway Error Leaves Order For Next Sweep", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockPayments := new(MockPaymentReconciler)
+		other := models.Order{ID: "order2", Status: models.OrderStatusPending}
+		mockOrderRepo.On("ListUnpaidBefore", mock.Anything, int64(200)).Return([]models.Order{unpaid, other}, nil)
+		mockPayments.On("ReconcilePayment", unpaid).Return(false, errors.New("gateway timeout"))
+		mockPayments.On("ReconcilePayment", other).Return(false, nil)
+		mockOrderRepo.On("TransitionStatus", "order2", []string{models.OrderStatusPending}, mock.Anything, mock.Anything).Return(&models.Order{ID: "order2", Status: models.OrderStatusExpired}, nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: new(MockProductRepositoryForOrderService), Payments: mockPayments}
+		expired, err := service.ExpireUnpaidOrders(context.Background())
+
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, expired)
+		mockOrderRepo.AssertNotCalled(t, "TransitionStatus", "order1", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ExpireSubscriptionOrders - Expires Without Checking The Gateway", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockPayments := new(MockPaymentReconciler)
+		order := models.Order{ID: "order3", Status: models.OrderStatusPending, SubscriptionID: "sub_1", Items: unpaid.Items}
+		mockOrderRepo.On("ListUnpaidBySubscription", "sub_1", int64(200)).Return([]models.Order{order}, nil)
+		mockOrderRepo.On("TransitionStatus", "order3", []string{models.OrderStatusPending}, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.OrderStatusExpired && change.Reason == "subscription payment failed"
+		}), mock.Anything).Return(&models.Order{ID: "order3", Status: models.OrderStatusExpired, Items: unpaid.Items}, nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 2).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Payments: mockPayments}
+		expired, err := service.ExpireSubscriptionOrders(context.Background(), "sub_1", "subscription payment failed")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, expired)
+		mockProductRepo.AssertExpectations(t)
+		mockPayments.AssertNotCalled(t, "ReconcilePayment", mock.Anything)
+	})
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
 )
 
@@ -28,7 +30,7 @@ func TestOrderRepository(t *testing.T) {
 			Status:       "pending",
 			OrderDate:    time.Now(),
 		}
-		err := orderRepository.CreateOrder(order)
+		err := orderRepository.CreateOrder(context.Background(), order)
 		assert.Nil(t, err)
 	})
 
@@ -50,9 +52,59 @@ func TestOrderRepository(t *testing.T) {
 		}
 		mt.AddMockResponses(mtest.CreateCursorResponse(1, "foo.bar", mtest.FirstBatch, expectedOrder))
 
-		order, err := orderRepository.GetOrder("test_order_id")
+		order, err := orderRepository.GetOrder(context.Background(), "test_order_id")
 		assert.Nil(t, err)
 		assert.NotNil(t, order)
 		assert.Equal(t, "test_order_id", order.ID)
 	})
-}
\ No newline at end of file
+
+	mt.Run("TransitionStatus", func(mt *mtest.T) {
+		orderRepository := &repositories.OrderRepository{Collection: mt.Coll}
+
+		updated := bson.D{
+			{Key: "id", Value: "test_order_id"},
+			{Key: "status", Value: "cancelled"},
+			{Key: "status_history", Value: bson.A{bson.D{{Key: "status", Value: "cancelled"}, {Key: "reason", Value: "duplicate"}}}},
+		}
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: updated}})
+
+		outbox := []models.JobRequest{{ID: "job_1", Type: models.JobTypeNotifyOrder}}
+		order, err := orderRepository.TransitionStatus(context.Background(), "test_order_id", []string{"pending"}, models.StatusChange{Status: "cancelled", Reason: "duplicate"}, outbox)
+		assert.Nil(t, err)
+		assert.Equal(t, "cancelled", order.Status)
+		assert.Len(t, order.StatusHistory, 1)
+		// The outbox is written in the same update as the status.
+		update := mt.GetStartedEvent().Command.Lookup("update").Document()
+		assert.Equal(t, "job_1", update.Lookup("$push", "outbox", "$each").Array().Index(0).Value().Document().Lookup("id").StringValue())
+	})
+
+	mt.Run("TransitionStatus - Wrong Status", func(mt *mtest.T) {
+		orderRepository := &repositories.OrderRepository{Collection: mt.Coll}
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		order, err := orderRepository.TransitionStatus(context.Background(), "test_order_id", []string{"pending"}, models.StatusChange{Status: "cancelled"}, nil)
+		assert.Equal(t, mongo.ErrNoDocuments, err)
+		assert.Nil(t, order)
+	})
+
+	mt.Run("ListOrders", func(mt *mtest.T) {
+		orderRepository := &repositories.OrderRepository{Collection: mt.Coll}
+
+		count := mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{{Key: "n", Value: int32(21)}})
+		first := mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+			bson.D{{Key: "id", Value: "order1"}, {Key: "status", Value: "confirmed"}},
+			bson.D{{Key: "id", Value: "order2"}, {Key: "status", Value: "confirmed"}},
+		)
+		mt.AddMockResponses(count, first)
+
+		orders, total, err := orderRepository.ListOrders(context.Background(), repositories.OrderFilter{
+			Status: []string{"confirmed"},
+			Phone:  "9876543210",
+			Skip:   20,
+			Limit:  20,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(21), total)
+		assert.Len(t, orders, 2)
+	})
+}
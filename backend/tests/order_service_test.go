@@ -1,26 +1,30 @@
 package tests
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
 	"mangal-chai-backend/services"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type MockOrderRepository struct {
 	mock.Mock
 }
 
-func (m *MockOrderRepository) CreateOrder(order models.Order) error {
+func (m *MockOrderRepository) CreateOrder(ctx context.Context, order models.Order) error {
 	args := m.Called(order)
 	return args.Error(0)
 }
 
-func (m *MockOrderRepository) GetOrder(id string) (*models.Order, error) {
+func (m *MockOrderRepository) GetOrder(ctx context.Context, id string) (*models.Order, error) {
 	args := m.Called(id)
 	val := args.Get(0)
 	if val == nil {
@@ -29,16 +33,178 @@ func (m *MockOrderRepository) GetOrder(id string) (*models.Order, error) {
 	return val.(*models.Order), args.Error(1)
 }
 
+func (m *MockOrderRepository) TransitionStatus(ctx context.Context, id string, from []string, change models.StatusChange, outbox []models.JobRequest) (*models.Order, error) {
+	args := m.Called(id, from, change, outbox)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) SetRefund(ctx context.Context, id string, refundID string, paymentStatus string) error {
+	args := m.Called(id, refundID, paymentStatus)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) ReserveRefund(ctx context.Context, id string, amount float64) error {
+	args := m.Called(id, amount)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) ReleaseRefund(ctx context.Context, id string, amount float64) error {
+	args := m.Called(id, amount)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) SetPaymentGatewayOrder(ctx context.Context, id string, gatewayOrderID string, amount int64) error {
+	args := m.Called(id, gatewayOrderID, amount)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) RecordPayment(ctx context.Context, gatewayOrderID string, paymentID string, method string) (*models.Order, error) {
+	args := m.Called(gatewayOrderID, paymentID, method)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) RecordSubscriptionPayment(ctx context.Context, subscriptionID string, paymentID string, method string) (*models.Order, error) {
+	args := m.Called(subscriptionID, paymentID, method)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) ListOrders(ctx context.Context, filter repositories.OrderFilter) ([]models.Order, int64, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.Order), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockOrderRepository) SetShipment(ctx context.Context, id string, shipment models.Shipment) error {
+	args := m.Called(id, shipment)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) GetOrderByTrackingNumber(ctx context.Context, trackingNumber string) (*models.Order, error) {
+	args := m.Called(trackingNumber)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) AddTrackingEvents(ctx context.Context, id string, status string, events []models.TrackingEvent, shippedAt *time.Time) error {
+	args := m.Called(id, status, events, shippedAt)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) ListTrackedShipments(ctx context.Context, provider string, limit int64) ([]models.Order, error) {
+	args := m.Called(provider, limit)
+	return args.Get(0).([]models.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) AddMessage(ctx context.Context, id string, message models.MessageDelivery) error {
+	args := m.Called(id, message)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) UpdateMessageStatus(ctx context.Context, providerMessageID string, status string, errorMessage string) error {
+	args := m.Called(providerMessageID, status, errorMessage)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) PendingOutbox(ctx context.Context, limit int64) ([]models.Order, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]models.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) ClearOutbox(ctx context.Context, id string, jobIDs []string) error {
+	args := m.Called(id, jobIDs)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) ListUnpaidBefore(ctx context.Context, cutoff time.Time, limit int64) ([]models.Order, error) {
+	args := m.Called(cutoff, limit)
+	return args.Get(0).([]models.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) ListUnpaidBySubscription(ctx context.Context, subscriptionID string, limit int64) ([]models.Order, error) {
+	args := m.Called(subscriptionID, limit)
+	return args.Get(0).([]models.Order), args.Error(1)
+}
+
+// notifies matches an outbox holding only the job that notifies the customer of event.
+func notifies(event string) any {
+	return mock.MatchedBy(func(outbox []models.JobRequest) bool {
+		return len(outbox) == 1 && outbox[0].Type == models.JobTypeNotifyOrder && outbox[0].Payload["event"] == event
+	})
+}
+
+type MockOrderNotifier struct {
+	mock.Mock
+}
+
+func (m *MockOrderNotifier) NotifyOrder(ctx context.Context, event string, order models.Order) {
+	m.Called(event, order)
+}
+
+type MockPaymentGateway struct {
+	mock.Mock
+}
+
+func (m *MockPaymentGateway) CreateOrder(ctx context.Context, amount int64, currency string, receipt string) (map[string]interface{}, error) {
+	args := m.Called(amount, currency, receipt)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(map[string]interface{}), args.Error(1)
+}
+
+func (m *MockPaymentGateway) Refund(ctx context.Context, paymentID string, amount int64, notes map[string]string) (*services.GatewayRefund, error) {
+	args := m.Called(paymentID, amount, notes)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.GatewayRefund), args.Error(1)
+}
+
+func (m *MockPaymentGateway) FetchRefund(ctx context.Context, refundID string) (*services.GatewayRefund, error) {
+	args := m.Called(refundID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.GatewayRefund), args.Error(1)
+}
+
+func (m *MockPaymentGateway) FetchOrderPayments(ctx context.Context, gatewayOrderID string) ([]services.GatewayPayment, error) {
+	args := m.Called(gatewayOrderID)
+	return args.Get(0).([]services.GatewayPayment), args.Error(1)
+}
+
+func (m *MockPaymentGateway) VerifyWebhookSignature(body []byte, signature string) bool {
+	args := m.Called(body, signature)
+	return args.Bool(0)
+}
+
 type MockProductRepositoryForOrderService struct {
 	mock.Mock
 }
 
-func (m *MockProductRepositoryForOrderService) GetProducts() ([]models.Product, error) {
+func (m *MockProductRepositoryForOrderService) GetProducts(ctx context.Context) ([]models.Product, error) {
 	args := m.Called()
 	return args.Get(0).([]models.Product), args.Error(1)
 }
 
-func (m *MockProductRepositoryForOrderService) GetProduct(id string) (*models.Product, error) {
+func (m *MockProductRepositoryForOrderService) GetProduct(ctx context.Context, id string) (*models.Product, error) {
 	args := m.Called(id)
 	val := args.Get(0)
 	if val == nil {
@@ -47,18 +213,33 @@ func (m *MockProductRepositoryForOrderService) GetProduct(id string) (*models.Pr
 	return val.(*models.Product), args.Error(1)
 }
 
-func (m *MockProductRepositoryForOrderService) GetProductsByCategory(category string) ([]models.Product, error) {
+func (m *MockProductRepositoryForOrderService) GetProductsByCategory(ctx context.Context, category string) ([]models.Product, error) {
 	args := m.Called(category)
 	return args.Get(0).([]models.Product), args.Error(1)
 }
 
-func (m *MockProductRepositoryForOrderService) GetCategories() ([]string, error) {
+func (m *MockProductRepositoryForOrderService) GetCategories(ctx context.Context) ([]string, error) {
 	args := m.Called()
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *MockProductRepositoryForOrderService) SeedProducts(products []interface{}) error {
-	args := m.Called(products)
+func (m *MockProductRepositoryForOrderService) UpsertProducts(ctx context.Context, entries []models.CatalogEntry) error {
+	args := m.Called(entries)
+	return args.Error(0)
+}
+
+func (m *MockProductRepositoryForOrderService) ReserveStock(ctx context.Context, id string, quantity int) error {
+	args := m.Called(id, quantity)
+	return args.Error(0)
+}
+
+func (m *MockProductRepositoryForOrderService) ReleaseStock(ctx context.Context, id string, quantity int) error {
+	args := m.Called(id, quantity)
+	return args.Error(0)
+}
+
+func (m *MockProductRepositoryForOrderService) SetRating(ctx context.Context, id string, rating float64, count int) error {
+	args := m.Called(id, rating, count)
 	return args.Error(0)
 }
 
@@ -68,26 +249,29 @@ func TestOrderService(t *testing.T) {
 		mockOrderRepo := new(MockOrderRepository)
 		mockProductRepo := new(MockProductRepositoryForOrderService)
 
-		product := &models.Product{ID: "prod1", Name: "Test Product", Price: 10.0, InStock: true}
+		product := &models.Product{ID: "prod1", Name: "Test Product", Price: 10.0, InStock: true, Stock: 5}
 		mockProductRepo.On("GetProduct", "prod1").Return(product, nil)
-		mockOrderRepo.On("CreateOrder", mock.AnythingOfType("models.Order")).Return(nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockOrderRepo.On("CreateOrder", mock.MatchedBy(func(order models.Order) bool {
+			return order.Status == models.OrderStatusPending && len(order.Outbox) == 1 &&
+				order.Outbox[0].Type == models.JobTypeNotifyOrder &&
+				order.Outbox[0].Payload["event"] == models.OrderEventPlaced &&
+				order.Outbox[0].Payload["order_id"] == order.ID
+		})).Return(nil)
 
 		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
 
-		orderData := struct {
-			CustomerInfo models.CustomerInfo `json:"customer_info"`
-			Items        []models.CartItem   `json:"items"`
-			Notes        string              `json:"notes"`
-		}{
+		orderData := services.CreateOrderRequest{
 			CustomerInfo: models.CustomerInfo{Name: "John Doe"},
 			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
 			Notes:        "",
 		}
 
-		order, err := service.CreateOrder(orderData)
+		order, err := service.CreateOrder(context.Background(), orderData)
 
 		assert.Nil(t, err)
 		assert.NotNil(t, order)
+		assert.Equal(t, 10.0, order.Items[0].Price)
 		mockOrderRepo.AssertExpectations(t)
 		mockProductRepo.AssertExpectations(t)
 	})
@@ -100,17 +284,13 @@ func TestOrderService(t *testing.T) {
 
 		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
 
-		orderData := struct {
-			CustomerInfo models.CustomerInfo `json:"customer_info"`
-			Items        []models.CartItem   `json:"items"`
-			Notes        string              `json:"notes"`
-		}{
+		orderData := services.CreateOrderRequest{
 			CustomerInfo: models.CustomerInfo{Name: "John Doe"},
 			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
 			Notes:        "",
 		}
 
-		order, err := service.CreateOrder(orderData)
+		order, err := service.CreateOrder(context.Background(), orderData)
 
 		assert.NotNil(t, err)
 		assert.Nil(t, order)
@@ -127,17 +307,13 @@ func TestOrderService(t *testing.T) {
 
 		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
 
-		orderData := struct {
-			CustomerInfo models.CustomerInfo `json:"customer_info"`
-			Items        []models.CartItem   `json:"items"`
-			Notes        string              `json:"notes"`
-		}{
+		orderData := services.CreateOrderRequest{
 			CustomerInfo: models.CustomerInfo{Name: "John Doe"},
 			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1}},
 			Notes:        "",
 		}
 
-		order, err := service.CreateOrder(orderData)
+		order, err := service.CreateOrder(context.Background(), orderData)
 
 		assert.NotNil(t, err)
 		assert.Nil(t, order)
@@ -145,16 +321,42 @@ func TestOrderService(t *testing.T) {
 		mockProductRepo.AssertExpectations(t)
 	})
 
+	t.Run("CreateOrder - Releases Reserved Stock When Another Item Runs Out", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", Price: 10.0, InStock: true, Stock: 5}, nil)
+		mockProductRepo.On("GetProduct", "prod2").Return(&models.Product{ID: "prod2", Price: 20.0, InStock: true, Stock: 1}, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 2).Return(nil)
+		mockProductRepo.On("ReserveStock", "prod2", 3).Return(repositories.ErrInsufficientStock)
+		mockProductRepo.On("ReleaseStock", "prod1", 2).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
+
+		orderData := services.CreateOrderRequest{
+			CustomerInfo: models.CustomerInfo{Name: "John Doe"},
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 2}, {ProductID: "prod2", Quantity: 3}},
+		}
+
+		order, err := service.CreateOrder(context.Background(), orderData)
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "out of stock")
+		assert.Nil(t, order)
+		mockOrderRepo.AssertNotCalled(t, "CreateOrder", mock.Anything)
+		mockProductRepo.AssertExpectations(t)
+	})
+
 	// Test GetOrder
 	t.Run("GetOrder - Success", func(t *testing.T) {
 		mockOrderRepo := new(MockOrderRepository)
 		mockProductRepo := new(MockProductRepositoryForOrderService)
 
-		expectedOrder := &models.Order{ID: "order1", CustomerInfo: models.CustomerInfo{Name: "John Doe"}}
+		expectedOrder := &models.Order{ID: "order1", CustomerInfo: models.CustomerInfo{Name: "John Doe", Phone: "9876543210"}}
 		mockOrderRepo.On("GetOrder", "order1").Return(expectedOrder, nil)
 
 		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
-		order, err := service.GetOrder("order1")
+		order, err := service.GetOrder(context.Background(), "order1", services.OrderAccess{Phone: "+91 98765 43210"})
 
 		assert.Nil(t, err)
 		assert.Equal(t, expectedOrder, order)
@@ -162,6 +364,31 @@ func TestOrderService(t *testing.T) {
 		mockProductRepo.AssertExpectations(t)
 	})
 
+	t.Run("GetOrder - Hidden From Others", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(&models.Order{
+			ID:           "order1",
+			CustomerID:   "cus_1",
+			CustomerInfo: models.CustomerInfo{Name: "John Doe", Phone: "9876543210", Email: "john@example.com"},
+		}, nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		for _, access := range []services.OrderAccess{
+			{Phone: "9999999999"},
+			{Email: "someone@example.com"},
+			{CustomerID: "cus_2"},
+		} {
+			order, err := service.GetOrder(context.Background(), "order1", access)
+
+			assert.True(t, errors.Is(err, services.ErrOrderNotFound))
+			assert.Nil(t, order)
+		}
+
+		order, err := service.GetOrder(context.Background(), "order1", services.OrderAccess{CustomerID: "cus_1"})
+		assert.Nil(t, err)
+		assert.Equal(t, "order1", order.ID)
+	})
+
 	t.Run("GetOrder - Error", func(t *testing.T) {
 		mockOrderRepo := new(MockOrderRepository)
 		mockProductRepo := new(MockProductRepositoryForOrderService)
@@ -169,11 +396,122 @@ func TestOrderService(t *testing.T) {
 		mockOrderRepo.On("GetOrder", "order1").Return(nil, errors.New("not found"))
 
 		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
-		order, err := service.GetOrder("order1")
+		order, err := service.GetOrder(context.Background(), "order1", services.OrderAccess{Phone: "9876543210"})
 
 		assert.NotNil(t, err)
 		assert.Nil(t, order)
 		mockOrderRepo.AssertExpectations(t)
 		mockProductRepo.AssertExpectations(t)
 	})
-}
\ No newline at end of file
+
+	// Test CancelOrder
+	paidOrder := func() *models.Order {
+		return &models.Order{
+			ID:            "order1",
+			CustomerInfo:  models.CustomerInfo{Name: "John Doe", Phone: "+91 98765 43210"},
+			Items:         []models.CartItem{{ProductID: "prod1", Quantity: 2}},
+			TotalAmount:   398.0,
+			Status:        models.OrderStatusConfirmed,
+			PaymentStatus: models.PaymentStatusPaid,
+			PaymentID:     "pay_123",
+		}
+	}
+
+	t.Run("CancelOrder - Restores Stock And Refunds", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockRefunds := new(MockRefundService)
+
+		cancelled := paidOrder()
+		cancelled.Status = models.OrderStatusCancelled
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPending, models.OrderStatusConfirmed},
+			mock.MatchedBy(func(change models.StatusChange) bool {
+				return change.Status == models.OrderStatusCancelled && change.Reason == "ordered twice"
+			}), notifies(models.OrderEventCancelled)).Return(cancelled, nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 2).Return(nil)
+		mockRefunds.On("IssueRefund", "order1", services.RefundRequest{Reason: "Order cancelled: ordered twice"}).
+			Return(&models.Refund{ID: "rfd_1", GatewayRefundID: "rfnd_1", Amount: 398.0, Status: models.RefundStatusPending}, nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Refunds: mockRefunds}
+		order, err := service.CancelOrder(context.Background(), "order1", services.CancelOrderRequest{Reason: "ordered twice", Phone: "9876543210"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.OrderStatusCancelled, order.Status)
+		assert.Equal(t, "rfnd_1", order.RefundID)
+		assert.Equal(t, models.PaymentStatusRefunded, order.PaymentStatus)
+		mockOrderRepo.AssertExpectations(t)
+		mockProductRepo.AssertExpectations(t)
+		mockRefunds.AssertExpectations(t)
+	})
+
+	t.Run("CancelOrder - Refund Failure Keeps Cancellation", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockRefunds := new(MockRefundService)
+
+		cancelled := paidOrder()
+		cancelled.Status = models.OrderStatusCancelled
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockOrderRepo.On("TransitionStatus", "order1", mock.Anything, mock.Anything, mock.Anything).Return(cancelled, nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 2).Return(nil)
+		mockRefunds.On("IssueRefund", "order1", mock.Anything).
+			Return(&models.Refund{ID: "rfd_1", Status: models.RefundStatusFailed}, services.ErrGatewayRefundFailed)
+		mockOrderRepo.On("SetRefund", "order1", "", models.PaymentStatusRefundFailed).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Refunds: mockRefunds}
+		order, err := service.CancelOrder(context.Background(), "order1", services.CancelOrderRequest{Reason: "ordered twice", Phone: "9876543210"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.PaymentStatusRefundFailed, order.PaymentStatus)
+		mockOrderRepo.AssertExpectations(t)
+		mockRefunds.AssertExpectations(t)
+	})
+
+	t.Run("CancelOrder - Contact Mismatch", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
+		order, err := service.CancelOrder(context.Background(), "order1", services.CancelOrderRequest{Reason: "x", Phone: "9999999999"})
+
+		assert.Equal(t, services.ErrOrderNotFound, err)
+		assert.Nil(t, order)
+		mockOrderRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("CancelOrder - Already Packed", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+
+		packed := paidOrder()
+		packed.Status = models.OrderStatusPacked
+		mockOrderRepo.On("GetOrder", "order1").Return(packed, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", mock.Anything, mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
+		order, err := service.CancelOrder(context.Background(), "order1", services.CancelOrderRequest{Reason: "x", Phone: "9876543210"})
+
+		assert.Equal(t, services.ErrOrderNotCancellable, err)
+		assert.Nil(t, order)
+		mockProductRepo.AssertNotCalled(t, "ReleaseStock", mock.Anything, mock.Anything)
+	})
+
+	t.Run("CancelOrder - Database Error Is Not A Conflict", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+
+		outage := errors.New("server selection timeout")
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockOrderRepo.On("TransitionStatus", "order1", mock.Anything, mock.Anything, mock.Anything).Return(nil, outage)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
+		order, err := service.CancelOrder(context.Background(), "order1", services.CancelOrderRequest{Reason: "x", Phone: "9876543210"})
+
+		assert.Equal(t, outage, err)
+		assert.Nil(t, order)
+		mockProductRepo.AssertNotCalled(t, "ReleaseStock", mock.Anything, mock.Anything)
+	})
+}
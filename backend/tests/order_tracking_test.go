@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTrackOrder(t *testing.T) {
+	shippedAt := time.Now().Add(-24 * time.Hour)
+	order := &models.Order{
+		ID:     "order1",
+		Status: models.OrderStatusShipped,
+		CustomerInfo: models.CustomerInfo{
+			Name:    "Asha Rao",
+			Phone:   "+91 98765 43210",
+			Email:   "asha@example.com",
+			Address: "Flat 4, 12 MG Road, Bandra, Mumbai 400050",
+		},
+		Items:         []models.CartItem{{ProductID: "masala-chai", Quantity: 2, Price: 250}},
+		TotalAmount:   500,
+		PaymentStatus: models.PaymentStatusPaid,
+		OrderDate:     shippedAt.Add(-48 * time.Hour),
+		StatusHistory: []models.StatusChange{
+			{Status: models.OrderStatusPending, ChangedAt: shippedAt.Add(-48 * time.Hour)},
+			{Status: models.OrderStatusConfirmed, ChangedAt: shippedAt.Add(-47 * time.Hour)},
+			{Status: models.OrderStatusShipped, Reason: "Collected by the courier", ChangedAt: shippedAt},
+		},
+		Shipment: &models.Shipment{
+			Courier:            "Delhivery",
+			TrackingNumber:     "AWB1",
+			ProviderShipmentID: "shp_1",
+			LabelURL:           "https://labels.example.com/shp_1.pdf",
+			ShippedAt:          &shippedAt,
+			Events:             []models.TrackingEvent{{Status: models.TrackingStatusPickedUp, OccurredAt: shippedAt}},
+		},
+	}
+	newService := func() (*services.OrderService, *MockOrderRepository) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(order, nil)
+		mockOrderRepo.On("GetOrder", mock.Anything).Return(nil, mongo.ErrNoDocuments)
+		mockProductRepo.On("GetProduct", "masala-chai").Return(&models.Product{ID: "masala-chai", Name: "Masala Chai"}, nil)
+		return &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}, mockOrderRepo
+	}
+
+	t.Run("TrackOrder - Redacted Timeline For A Matching Phone", func(t *testing.T) {
+		service, _ := newService()
+
+		tracking, err := service.TrackOrder(context.Background(), services.TrackOrderRequest{OrderID: "order1", Phone: "9876543210"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.OrderStatusShipped, tracking.Status)
+		assert.Equal(t, services.TrackingCustomer{
+			Name:         "Asha",
+			Phone:        "******3210",
+			Email:        "a***@example.com",
+			DeliveryArea: "Mumbai 400050",
+		}, tracking.Customer)
+		assert.Equal(t, []services.TrackedItem{{ProductID: "masala-chai", Name: "Masala Chai", Quantity: 2}}, tracking.Items)
+		assert.Len(t, tracking.Timeline, 3)
+		assert.Equal(t, "AWB1", tracking.Shipment.TrackingNumber)
+		expected := shippedAt.Add(services.DefaultTransitTime).In(time.FixedZone("IST", 5*60*60+30*60)).Format("2006-01-02")
+		assert.Equal(t, expected, tracking.ExpectedDelivery)
+
+		body, _ := json.Marshal(tracking)
+		for _, private := range []string{"12 MG Road", "98765", "asha@", "Rao", "shp_1", "labels.example.com", "Collected by the courier"} {
+			assert.NotContains(t, string(body), private)
+		}
+	})
+
+	t.Run("TrackOrder - Matching Email", func(t *testing.T) {
+		service, _ := newService()
+
+		tracking, err := service.TrackOrder(context.Background(), services.TrackOrderRequest{OrderID: " order1 ", Email: "ASHA@example.com"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "order1", tracking.OrderID)
+	})
+
+	t.Run("TrackOrder - Wrong Contact Or Order Look The Same", func(t *testing.T) {
+		service, _ := newService()
+
+		_, wrongPhone := service.TrackOrder(context.Background(), services.TrackOrderRequest{OrderID: "order1", Phone: "9000000000"})
+		_, wrongOrder := service.TrackOrder(context.Background(), services.TrackOrderRequest{OrderID: "order2", Phone: "9876543210"})
+
+		assert.Equal(t, services.ErrOrderNotFound, wrongPhone)
+		assert.Equal(t, services.ErrOrderNotFound, wrongOrder)
+	})
+
+	t.Run("TrackOrder - No Estimate Once Delivered", func(t *testing.T) {
+		delivered := *order
+		delivered.ID = "order3"
+		delivered.Status = models.OrderStatusDelivered
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockOrderRepo.On("GetOrder", "order3").Return(&delivered, nil)
+		mockProductRepo.On("GetProduct", mock.Anything).Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
+		tracking, err := service.TrackOrder(context.Background(), services.TrackOrderRequest{OrderID: "order3", Phone: "9876543210"})
+
+		assert.Nil(t, err)
+		assert.Empty(t, tracking.ExpectedDelivery)
+		assert.Equal(t, "masala-chai", tracking.Items[0].ProductID)
+	})
+}
+
+func TestTrackOrderController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(service services.OrderServiceInterface, limit int) *gin.Engine {
+		router := gin.New()
+		controller := &controllers.OrderController{Service: service}
+		limiter := &middleware.RateLimiter{Store: &middleware.MemoryRateLimitStore{}}
+		router.GET("/api/track", limiter.Limit("tracking", middleware.Limit{Requests: limit, Window: time.Minute}), controller.TrackOrder)
+		return router
+	}
+
+	t.Run("TrackOrder - Success", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		mockService.On("TrackOrder", services.TrackOrderRequest{OrderID: "order1", Phone: "9876543210"}).
+			Return(&services.OrderTracking{OrderID: "order1", Status: models.OrderStatusShipped}, nil)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/track?order=order1&phone=9876543210", nil)
+		newRouter(mockService, 10).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"status":"shipped"`)
+	})
+
+	t.Run("TrackOrder - Phone Or Email Required", func(t *testing.T) {
+		mockService := new(MockOrderService)
+
+		for _, query := range []string{"", "?order=order1"} {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/track"+query, nil)
+			newRouter(mockService, 10).ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		}
+		mockService.AssertNotCalled(t, "TrackOrder", mock.Anything)
+	})
+
+	t.Run("TrackOrder - Rate Limited", func(t *testing.T) {
+		mockService := new(MockOrderService)
+		mockService.On("TrackOrder", mock.Anything).Return(nil, services.ErrOrderNotFound)
+		router := newRouter(mockService, 3)
+
+		codes := []int{}
+		var last *httptest.ResponseRecorder
+		for i := 0; i < 4; i++ {
+			last = httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/track?order=order"+strings.Repeat("1", i+1)+"&phone=9876543210", nil)
+			req.RemoteAddr = "203.0.113.7:4000"
+			router.ServeHTTP(last, req)
+			codes = append(codes, last.Code)
+		}
+
+		assert.Equal(t, []int{http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, http.StatusTooManyRequests}, codes)
+		assert.Equal(t, "20", last.Header().Get("Retry-After"))
+		mockService.AssertNumberOfCalls(t, "TrackOrder", 3)
+
+		// Other addresses have their own allowance.
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/track?order=order1&phone=9876543210", nil)
+		req.RemoteAddr = "198.51.100.2:4000"
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestPaymentService(t *testing.T) {
+	captured := []byte(`{"event": "payment.captured", "payload": {"payment": {"entity": {"id": "pay_1", "order_id": "gw_order_1", "method": "upi"}}}}`)
+
+	t.Run("HandleWebhook - Invalid Signature", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		mockGateway.On("VerifyWebhookSignature", captured, "bad").Return(false)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		err := service.HandleWebhook(context.Background(), captured, "bad")
+
+		assert.Equal(t, services.ErrInvalidWebhookSignature, err)
+		mockOrderRepo.AssertNotCalled(t, "RecordPayment", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("HandleWebhook - Payment Captured Confirms Order", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		mockGateway.On("VerifyWebhookSignature", captured, "sig").Return(true)
+		mockOrderRepo.On("RecordPayment", "gw_order_1", "pay_1", "upi").Return(&models.Order{ID: "order1", Status: models.OrderStatusPending}, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPending}, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.OrderStatusConfirmed
+		}), notifies(models.OrderEventPaymentConfirmed)).Return(&models.Order{ID: "order1", Status: models.OrderStatusConfirmed}, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		err := service.HandleWebhook(context.Background(), captured, "sig")
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("HandleWebhook - Payment After Cancellation Is Refunded", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefunds := new(MockRefundService)
+		mockGateway.On("VerifyWebhookSignature", captured, "sig").Return(true)
+		mockOrderRepo.On("RecordPayment", "gw_order_1", "pay_1", "upi").Return(&models.Order{ID: "order1", Status: models.OrderStatusCancelled}, nil)
+		mockRefunds.On("IssueRefund", "order1", mock.Anything).Return(&models.Refund{ID: "rfd_1"}, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, mockRefunds)
+		err := service.HandleWebhook(context.Background(), captured, "sig")
+
+		assert.Nil(t, err)
+		mockRefunds.AssertExpectations(t)
+		mockOrderRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("HandleWebhook - Payment Racing Expiry Is Refunded", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefunds := new(MockRefundService)
+		mockGateway.On("VerifyWebhookSignature", captured, "sig").Return(true)
+		mockOrderRepo.On("RecordPayment", "gw_order_1", "pay_1", "upi").Return(&models.Order{ID: "order1", Status: models.OrderStatusPending}, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPending}, mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+		mockOrderRepo.On("GetOrder", "order1").Return(&models.Order{ID: "order1", Status: models.OrderStatusExpired}, nil)
+		mockRefunds.On("IssueRefund", "order1", mock.MatchedBy(func(request services.RefundRequest) bool {
+			return request.Reason == "Payment captured after the order was expired"
+		})).Return(&models.Refund{ID: "rfd_1"}, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, mockRefunds)
+		err := service.HandleWebhook(context.Background(), captured, "sig")
+
+		assert.Nil(t, err)
+		mockRefunds.AssertExpectations(t)
+	})
+
+	t.Run("ReconcilePayment - Records Late Capture", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		mockGateway.On("FetchOrderPayments", "gw_order_1").Return([]services.GatewayPayment{
+			{ID: "pay_0", Status: "failed", Method: "card"},
+			{ID: "pay_1", Status: services.GatewayPaymentCaptured, Method: "upi"},
+		}, nil)
+		mockOrderRepo.On("RecordPayment", "gw_order_1", "pay_1", "upi").Return(&models.Order{ID: "order1", Status: models.OrderStatusPending}, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPending}, mock.Anything, mock.Anything).Return(&models.Order{ID: "order1", Status: models.OrderStatusConfirmed}, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		paid, err := service.ReconcilePayment(context.Background(), models.Order{ID: "order1", PaymentGatewayOrderID: "gw_order_1", PaymentGatewayOrderIDs: []string{"gw_order_1"}})
+
+		assert.Nil(t, err)
+		assert.True(t, paid)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("ReconcilePayment - Checks Earlier Gateway Orders", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		mockGateway.On("FetchOrderPayments", "gw_order_1").Return([]services.GatewayPayment{
+			{ID: "pay_1", Status: services.GatewayPaymentCaptured, Method: "card"},
+		}, nil)
+		mockOrderRepo.On("RecordPayment", "gw_order_1", "pay_1", "card").Return(&models.Order{ID: "order1", Status: models.OrderStatusPending}, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPending}, mock.Anything, mock.Anything).Return(&models.Order{ID: "order1", Status: models.OrderStatusConfirmed}, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		paid, err := service.ReconcilePayment(context.Background(), models.Order{
+			ID:                     "order1",
+			PaymentGatewayOrderID:  "gw_order_2",
+			PaymentGatewayOrderIDs: []string{"gw_order_1", "gw_order_2"},
+		})
+
+		assert.Nil(t, err)
+		assert.True(t, paid)
+		mockOrderRepo.AssertExpectations(t)
+		mockGateway.AssertNotCalled(t, "FetchOrderPayments", "gw_order_2")
+	})
+
+	t.Run("CreateRazorpayOrder - Reuses Gateway Order For The Same Amount", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		order := &models.Order{ID: "order1", TotalAmount: 500.0, PaymentGatewayOrderID: "gw_order_1", PaymentGatewayAmount: 50000}
+		mockOrderRepo.On("GetOrder", "order1").Return(order, nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		gatewayOrder, err := service.CreateRazorpayOrder(context.Background(), services.CreateRazorpayOrderRequest{OrderID: "order1"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "gw_order_1", gatewayOrder["id"])
+		mockGateway.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything, mock.Anything)
+		mockOrderRepo.AssertNotCalled(t, "SetPaymentGatewayOrder", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("CreateRazorpayOrder - New Gateway Order When The Amount Changed", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+		mockOrderRepo := new(MockOrderRepository)
+		order := &models.Order{ID: "order1", TotalAmount: 450.0, PaymentGatewayOrderID: "gw_order_1", PaymentGatewayAmount: 50000}
+		mockOrderRepo.On("GetOrder", "order1").Return(order, nil)
+		mockGateway.On("CreateOrder", int64(45000), "INR", "order1").Return(map[string]interface{}{"id": "gw_order_2"}, nil)
+		mockOrderRepo.On("SetPaymentGatewayOrder", "order1", "gw_order_2", int64(45000)).Return(nil)
+
+		service := services.NewPaymentService(mockGateway, mockOrderRepo, nil)
+		gatewayOrder, err := service.CreateRazorpayOrder(context.Background(), services.CreateRazorpayOrderRequest{OrderID: "order1"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "gw_order_2", gatewayOrder["id"])
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("ReconcilePayment - No Gateway Order", func(t *testing.T) {
+		mockGateway := new(MockPaymentGateway)
+
+		service := services.NewPaymentService(mockGateway, new(MockOrderRepository), nil)
+		paid, err := service.ReconcilePayment(context.Background(), models.Order{ID: "order1"})
+
+		assert.Nil(t, err)
+		assert.False(t, paid)
+		mockGateway.AssertNotCalled(t, "FetchOrderPayments", mock.Anything)
+	})
+}
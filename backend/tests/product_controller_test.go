@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -8,6 +9,7 @@ import (
 
 	"mangal-chai-backend/controllers"
 	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -18,12 +20,12 @@ type MockProductService struct {
 	mock.Mock
 }
 
-func (m *MockProductService) GetProducts() ([]models.Product, error) {
+func (m *MockProductService) GetProducts(ctx context.Context) ([]models.Product, error) {
 	args := m.Called()
 	return args.Get(0).([]models.Product), args.Error(1)
 }
 
-func (m *MockProductService) GetProduct(id string) (*models.Product, error) {
+func (m *MockProductService) GetProduct(ctx context.Context, id string) (*models.Product, error) {
 	args := m.Called(id)
 	val := args.Get(0)
 	if val == nil {
@@ -32,19 +34,28 @@ func (m *MockProductService) GetProduct(id string) (*models.Product, error) {
 	return val.(*models.Product), args.Error(1)
 }
 
-func (m *MockProductService) GetProductsByCategory(category string) ([]models.Product, error) {
+func (m *MockProductService) GetProductsByCategory(ctx context.Context, category string) ([]models.Product, error) {
 	args := m.Called(category)
 	return args.Get(0).([]models.Product), args.Error(1)
 }
 
-func (m *MockProductService) GetCategories() ([]string, error) {
+func (m *MockProductService) GetCategories(ctx context.Context) ([]string, error) {
 	args := m.Called()
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *MockProductService) SeedProducts() error {
+func (m *MockProductService) ImportCatalog(ctx context.Context, entries []models.CatalogEntry, dryRun bool) (*services.CatalogImportResult, error) {
+	args := m.Called(entries, dryRun)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.CatalogImportResult), args.Error(1)
+}
+
+func (m *MockProductService) ExportCatalog(ctx context.Context) ([]models.Product, error) {
 	args := m.Called()
-	return args.Error(0)
+	return args.Get(0).([]models.Product), args.Error(1)
 }
 
 func TestProductController(t *testing.T) {
@@ -60,6 +71,7 @@ func TestProductController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 
 		controller.GetProducts(c)
 
@@ -76,6 +88,7 @@ func TestProductController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 
 		controller.GetProducts(c)
 
@@ -94,6 +107,7 @@ func TestProductController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 		c.Params = gin.Params{{Key: "product_id", Value: "1"}}
 
 		controller.GetProduct(c)
@@ -111,6 +125,7 @@ func TestProductController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 		c.Params = gin.Params{{Key: "product_id", Value: "1"}}
 
 		controller.GetProduct(c)
@@ -130,6 +145,7 @@ func TestProductController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 		c.Params = gin.Params{{Key: "category", Value: "Tea"}}
 
 		controller.GetProductsByCategory(c)
@@ -147,6 +163,7 @@ func TestProductController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 		c.Params = gin.Params{{Key: "category", Value: "Tea"}}
 
 		controller.GetProductsByCategory(c)
@@ -166,6 +183,7 @@ func TestProductController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 
 		controller.GetCategories(c)
 
@@ -182,6 +200,7 @@ func TestProductController(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 
 		controller.GetCategories(c)
 
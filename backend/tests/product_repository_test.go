@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"testing"
 
 	"mangal-chai-backend/models"
@@ -23,7 +24,7 @@ func TestProductRepository(t *testing.T) {
 		killCursors := mtest.CreateCursorResponse(0, "foo.bar", mtest.NextBatch)
 		mt.AddMockResponses(first, second, killCursors)
 
-		products, err := productRepository.GetProducts()
+		products, err := productRepository.GetProducts(context.Background())
 		assert.Nil(t, err)
 		assert.Len(t, products, 2)
 	})
@@ -35,7 +36,7 @@ func TestProductRepository(t *testing.T) {
 		killCursors := mtest.CreateCursorResponse(0, "foo.bar", mtest.NextBatch)
 		mt.AddMockResponses(first, killCursors)
 
-		product, err := productRepository.GetProduct("1")
+		product, err := productRepository.GetProduct(context.Background(), "1")
 		assert.Nil(t, err)
 		assert.NotNil(t, product)
 		assert.Equal(t, "p1", product.Name)
@@ -45,18 +46,56 @@ func TestProductRepository(t *testing.T) {
 		productRepository := &repositories.ProductRepository{Collection: mt.Coll}
 		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{"cat1", "cat2"}}))
 
-		categories, err := productRepository.GetCategories()
+		categories, err := productRepository.GetCategories(context.Background())
 		assert.Nil(t, err)
 		assert.Len(t, categories, 2)
 	})
 
-	mt.Run("SeedProducts", func(mt *mtest.T) {
+	mt.Run("UpsertProducts", func(mt *mtest.T) {
 		productRepository := &repositories.ProductRepository{Collection: mt.Coll}
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "foo.bar", mtest.FirstBatch, bson.D{{Key: "n", Value: 0}}),
-			mtest.CreateSuccessResponse(),
-		)
-		err := productRepository.SeedProducts([]interface{}{models.Product{ID: "1"}})
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := productRepository.UpsertProducts(context.Background(), []models.CatalogEntry{{Product: models.Product{ID: "1", Name: "p1"}}})
+		assert.Nil(t, err)
+	})
+
+	mt.Run("UpsertProducts - Keeps Stock Left Out", func(mt *mtest.T) {
+		productRepository := &repositories.ProductRepository{Collection: mt.Coll}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}))
+
+		err := productRepository.UpsertProducts(context.Background(), []models.CatalogEntry{
+			{Product: models.Product{ID: "1", Name: "p1", Price: 299}},
+			{Product: models.Product{ID: "2", Name: "p2", Price: 349, Stock: 5, InStock: true}, HasStock: true},
+		})
+		assert.Nil(t, err)
+
+		// Only catalog fields are set; the stock of a product without one is only set when it is inserted.
+		updates := mt.GetStartedEvent().Command.Lookup("updates").Array()
+		withoutStock := updates.Index(0).Value().Document().Lookup("u").Document()
+		assert.Equal(t, "p1", withoutStock.Lookup("$set", "name").StringValue())
+		_, err = withoutStock.LookupErr("$set", "stock")
+		assert.NotNil(t, err)
+		_, err = withoutStock.LookupErr("$set", "rating")
+		assert.NotNil(t, err)
+		assert.Equal(t, int32(0), withoutStock.Lookup("$setOnInsert", "stock").Int32())
+		stocked := updates.Index(1).Value().Document().Lookup("u").Document()
+		assert.Equal(t, int32(5), stocked.Lookup("$set", "stock").Int32())
+		assert.True(t, stocked.Lookup("$set", "in_stock").Boolean())
+	})
+
+	mt.Run("ReserveStock", func(mt *mtest.T) {
+		productRepository := &repositories.ProductRepository{Collection: mt.Coll}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := productRepository.ReserveStock(context.Background(), "1", 2)
 		assert.Nil(t, err)
 	})
-}
\ No newline at end of file
+
+	mt.Run("ReserveStock - Insufficient", func(mt *mtest.T) {
+		productRepository := &repositories.ProductRepository{Collection: mt.Coll}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		err := productRepository.ReserveStock(context.Background(), "1", 2)
+		assert.Equal(t, repositories.ErrInsufficientStock, err)
+	})
+}
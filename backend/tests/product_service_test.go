@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -15,12 +16,12 @@ type MockProductRepository struct {
 	mock.Mock
 }
 
-func (m *MockProductRepository) GetProducts() ([]models.Product, error) {
+func (m *MockProductRepository) GetProducts(ctx context.Context) ([]models.Product, error) {
 	args := m.Called()
 	return args.Get(0).([]models.Product), args.Error(1)
 }
 
-func (m *MockProductRepository) GetProduct(id string) (*models.Product, error) {
+func (m *MockProductRepository) GetProduct(ctx context.Context, id string) (*models.Product, error) {
 	args := m.Called(id)
 	val := args.Get(0)
 	if val == nil {
@@ -29,18 +30,33 @@ func (m *MockProductRepository) GetProduct(id string) (*models.Product, error) {
 	return val.(*models.Product), args.Error(1)
 }
 
-func (m *MockProductRepository) GetProductsByCategory(category string) ([]models.Product, error) {
+func (m *MockProductRepository) GetProductsByCategory(ctx context.Context, category string) ([]models.Product, error) {
 	args := m.Called(category)
 	return args.Get(0).([]models.Product), args.Error(1)
 }
 
-func (m *MockProductRepository) GetCategories() ([]string, error) {
+func (m *MockProductRepository) GetCategories(ctx context.Context) ([]string, error) {
 	args := m.Called()
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *MockProductRepository) SeedProducts(products []interface{}) error {
-	args := m.Called(products)
+func (m *MockProductRepository) UpsertProducts(ctx context.Context, entries []models.CatalogEntry) error {
+	args := m.Called(entries)
+	return args.Error(0)
+}
+
+func (m *MockProductRepository) ReserveStock(ctx context.Context, id string, quantity int) error {
+	args := m.Called(id, quantity)
+	return args.Error(0)
+}
+
+func (m *MockProductRepository) ReleaseStock(ctx context.Context, id string, quantity int) error {
+	args := m.Called(id, quantity)
+	return args.Error(0)
+}
+
+func (m *MockProductRepository) SetRating(ctx context.Context, id string, rating float64, count int) error {
+	args := m.Called(id, rating, count)
 	return args.Error(0)
 }
 
@@ -52,7 +68,7 @@ func TestProductService(t *testing.T) {
 		mockRepo.On("GetProducts").Return(expectedProducts, nil)
 
 		service := &services.ProductService{Repository: mockRepo}
-		products, err := service.GetProducts()
+		products, err := service.GetProducts(context.Background())
 
 		assert.Nil(t, err)
 		assert.Equal(t, expectedProducts, products)
@@ -64,7 +80,7 @@ func TestProductService(t *testing.T) {
 		mockRepo.On("GetProducts").Return([]models.Product{}, errors.New("db error"))
 
 		service := &services.ProductService{Repository: mockRepo}
-		products, err := service.GetProducts()
+		products, err := service.GetProducts(context.Background())
 
 		assert.NotNil(t, err)
 		assert.Empty(t, products)
@@ -78,7 +94,7 @@ func TestProductService(t *testing.T) {
 		mockRepo.On("GetProduct", "1").Return(expectedProduct, nil)
 
 		service := &services.ProductService{Repository: mockRepo}
-		product, err := service.GetProduct("1")
+		product, err := service.GetProduct(context.Background(), "1")
 
 		assert.Nil(t, err)
 		assert.Equal(t, expectedProduct, product)
@@ -90,7 +106,7 @@ func TestProductService(t *testing.T) {
 		mockRepo.On("GetProduct", "1").Return(nil, errors.New("not found"))
 
 		service := &services.ProductService{Repository: mockRepo}
-		product, err := service.GetProduct("1")
+		product, err := service.GetProduct(context.Background(), "1")
 
 		assert.NotNil(t, err)
 		assert.Nil(t, product)
@@ -104,7 +120,7 @@ func TestProductService(t *testing.T) {
 		mockRepo.On("GetProductsByCategory", "Tea").Return(expectedProducts, nil)
 
 		service := &services.ProductService{Repository: mockRepo}
-		products, err := service.GetProductsByCategory("Tea")
+		products, err := service.GetProductsByCategory(context.Background(), "Tea")
 
 		assert.Nil(t, err)
 		assert.Equal(t, expectedProducts, products)
@@ -116,7 +132,7 @@ func TestProductService(t *testing.T) {
 		mockRepo.On("GetProductsByCategory", "Tea").Return([]models.Product{}, errors.New("db error"))
 
 		service := &services.ProductService{Repository: mockRepo}
-		products, err := service.GetProductsByCategory("Tea")
+		products, err := service.GetProductsByCategory(context.Background(), "Tea")
 
 		assert.NotNil(t, err)
 		assert.Empty(t, products)
@@ -130,7 +146,7 @@ func TestProductService(t *testing.T) {
 		mockRepo.On("GetCategories").Return(expectedCategories, nil)
 
 		service := &services.ProductService{Repository: mockRepo}
-		categories, err := service.GetCategories()
+		categories, err := service.GetCategories(context.Background())
 
 		assert.Nil(t, err)
 		assert.Equal(t, expectedCategories, categories)
@@ -142,33 +158,147 @@ func TestProductService(t *testing.T) {
 		mockRepo.On("GetCategories").Return([]string{}, errors.New("db error"))
 
 		service := &services.ProductService{Repository: mockRepo}
-		categories, err := service.GetCategories()
+		categories, err := service.GetCategories(context.Background())
 
 		assert.NotNil(t, err)
 		assert.Empty(t, categories)
 		mockRepo.AssertExpectations(t)
 	})
 
-	// Test SeedProducts
-	t.Run("SeedProducts - Success", func(t *testing.T) {
+	// Test ImportCatalog
+	t.Run("ImportCatalog - Classifies And Upserts Changes", func(t *testing.T) {
+		mockRepo := new(MockProductRepository)
+		stored := []models.Product{
+			{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: true},
+			{ID: "2", Name: "Darjeeling", Price: 450, Category: "Black Tea", InStock: true},
+		}
+		mockRepo.On("GetProducts").Return(stored, nil)
+
+		imported := []models.Product{
+			{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: true},
+			{ID: "2", Name: "Darjeeling", Price: 499, Category: "Black Tea", InStock: true},
+			{ID: "3", Name: "Masala Chai", Price: 199, Category: "Masala Chai", InStock: true},
+		}
+		mockRepo.On("UpsertProducts", withStock(imported[1], imported[2])).Return(nil)
+
+		service := &services.ProductService{Repository: mockRepo}
+		result, err := service.ImportCatalog(context.Background(), withStock(imported...), false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"3"}, result.Created)
+		assert.Equal(t, []string{"2"}, result.Updated)
+		assert.Equal(t, []string{"1"}, result.Unchanged)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ImportCatalog - Keeps Review Ratings", func(t *testing.T) {
+		mockRepo := new(MockProductRepository)
+		mockRepo.On("GetProducts").Return([]models.Product{
+			{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: true, Rating: 4.5, ReviewCount: 12},
+		}, nil)
+		mockRepo.On("UpsertProducts", []models.CatalogEntry(nil)).Return(nil)
+
+		service := &services.ProductService{Repository: mockRepo}
+		result, err := service.ImportCatalog(context.Background(), withStock(models.Product{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: true}), false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1"}, result.Unchanged)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ImportCatalog - Keeps Tax Fields Left Blank", func(t *testing.T) {
+		mockRepo := new(MockProductRepository)
+		mockRepo.On("GetProducts").Return([]models.Product{
+			{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: true, HSNCode: "09023020", GSTRate: 5},
+			{ID: "2", Name: "Darjeeling", Price: 450, Category: "Black Tea", InStock: true, HSNCode: "09023020", GSTRate: 5},
+		}, nil)
+		mockRepo.On("UpsertProducts", withStock(
+			models.Product{ID: "2", Name: "Darjeeling", Price: 499, Category: "Black Tea", InStock: true, HSNCode: "0902", GSTRate: 5},
+		)).Return(nil)
+
+		service := &services.ProductService{Repository: mockRepo}
+		result, err := service.ImportCatalog(context.Background(), withStock(
+			models.Product{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: true},
+			models.Product{ID: "2", Name: "Darjeeling", Price: 499, Category: "Black Tea", InStock: true, HSNCode: "0902"},
+		), false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1"}, result.Unchanged)
+		assert.Equal(t, []string{"2"}, result.Updated)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("ImportCatalog - Keeps Stock Left Out", func(t *testing.T) {
 		mockRepo := new(MockProductRepository)
-		mockRepo.On("SeedProducts", mock.Anything).Return(nil)
+		mockRepo.On("GetProducts").Return([]models.Product{
+			{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: true, Stock: 7},
+			{ID: "2", Name: "Darjeeling", Price: 450, Category: "Black Tea", InStock: false},
+		}, nil)
+		mockRepo.On("UpsertProducts", []models.CatalogEntry{
+			{Product: models.Product{ID: "2", Name: "Darjeeling", Price: 499, Category: "Black Tea", InStock: false}},
+		}).Return(nil)
 
 		service := &services.ProductService{Repository: mockRepo}
-		err := service.SeedProducts()
+		result, err := service.ImportCatalog(context.Background(), []models.CatalogEntry{
+			{Product: models.Product{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea"}},
+			{Product: models.Product{ID: "2", Name: "Darjeeling", Price: 499, Category: "Black Tea"}},
+		}, false)
 
 		assert.Nil(t, err)
+		assert.Equal(t, []string{"1"}, result.Unchanged)
+		assert.Equal(t, []string{"2"}, result.Updated)
 		mockRepo.AssertExpectations(t)
 	})
 
-	t.Run("SeedProducts - Error", func(t *testing.T) {
+	t.Run("ImportCatalog - Dry Run Does Not Write", func(t *testing.T) {
+		mockRepo := new(MockProductRepository)
+		mockRepo.On("GetProducts").Return([]models.Product{}, nil)
+
+		service := &services.ProductService{Repository: mockRepo}
+		result, err := service.ImportCatalog(context.Background(), withStock(models.Product{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea"}), true)
+
+		assert.Nil(t, err)
+		assert.True(t, result.DryRun)
+		assert.Equal(t, []string{"1"}, result.Created)
+		mockRepo.AssertNotCalled(t, "UpsertProducts", mock.Anything)
+	})
+
+	t.Run("ImportCatalog - Error", func(t *testing.T) {
 		mockRepo := new(MockProductRepository)
-		mockRepo.On("SeedProducts", mock.Anything).Return(errors.New("db error"))
+		mockRepo.On("GetProducts").Return([]models.Product{}, nil)
+		mockRepo.On("UpsertProducts", mock.Anything).Return(errors.New("db error"))
 
 		service := &services.ProductService{Repository: mockRepo}
-		err := service.SeedProducts()
+		result, err := service.ImportCatalog(context.Background(), withStock(models.Product{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea"}), false)
 
 		assert.NotNil(t, err)
+		assert.Nil(t, result)
 		mockRepo.AssertExpectations(t)
 	})
-}
\ No newline at end of file
+
+	// Test ExportCatalog
+	t.Run("ExportCatalog - Sorted By Category And Name", func(t *testing.T) {
+		mockRepo := new(MockProductRepository)
+		mockRepo.On("GetProducts").Return([]models.Product{
+			{ID: "3", Name: "Masala Chai", Category: "Masala Chai"},
+			{ID: "2", Name: "Darjeeling", Category: "Black Tea"},
+			{ID: "1", Name: "Assam", Category: "Black Tea"},
+		}, nil)
+
+		service := &services.ProductService{Repository: mockRepo}
+		products, err := service.ExportCatalog(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2", "3"}, []string{products[0].ID, products[1].ID, products[2].ID})
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+// withStock makes catalog entries of products whose stock is given in the file.
+func withStock(products ...models.Product) []models.CatalogEntry {
+	entries := make([]models.CatalogEntry, len(products))
+	for i, product := range products {
+		entries[i] = models.CatalogEntry{Product: product, HasStock: true}
+	}
+	return entries
+}
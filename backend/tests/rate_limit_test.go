@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingRateLimitStore struct{}
+
+func (s *failingRateLimitStore) Take(ctx context.Context, key string, capacity float64, perSecond float64, ttl time.Duration, now time.Time) (float64, bool, error) {
+	return 0, false, errors.New("connection refused")
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(limiter *middleware.RateLimiter) *gin.Engine {
+		router := gin.New()
+		ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+		router.POST("/api/orders", limiter.Limit("orders", middleware.Limit{Requests: 2, Window: time.Minute}), ok)
+		router.POST("/api/payments/create-order", limiter.Limit("payments", middleware.Limit{Requests: 2, Window: time.Minute}), ok)
+		return router
+	}
+	send := func(router *gin.Engine, path string, ip string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = ip + ":4000"
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Limit - Headers And Retry-After", func(t *testing.T) {
+		router := newRouter(&middleware.RateLimiter{Store: &middleware.MemoryRateLimitStore{}})
+
+		first := send(router, "/api/orders", "203.0.113.7")
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, "2", first.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "1", first.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "30", first.Header().Get("X-RateLimit-Reset"))
+
+		send(router, "/api/orders", "203.0.113.7")
+		refused := send(router, "/api/orders", "203.0.113.7")
+		assert.Equal(t, http.StatusTooManyRequests, refused.Code)
+		assert.Equal(t, "0", refused.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "30", refused.Header().Get("Retry-After"))
+	})
+
+	t.Run("Limit - Each Route Group And Address Counts Separately", func(t *testing.T) {
+		router := newRouter(&middleware.RateLimiter{Store: &middleware.MemoryRateLimitStore{}})
+		send(router, "/api/orders", "203.0.113.7")
+		send(router, "/api/orders", "203.0.113.7")
+
+		assert.Equal(t, http.StatusOK, send(router, "/api/payments/create-order", "203.0.113.7").Code)
+		assert.Equal(t, http.StatusOK, send(router, "/api/orders", "198.51.100.2").Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(router, "/api/orders", "203.0.113.7").Code)
+	})
+
+	t.Run("Limit - Allowlisted Addresses Are Not Limited", func(t *testing.T) {
+		allowlist, err := middleware.ParseAllowlist("10.0.0.0/8, 203.0.113.7")
+		assert.Nil(t, err)
+		router := newRouter(&middleware.RateLimiter{Store: &middleware.MemoryRateLimitStore{}, Allowlist: allowlist})
+
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusOK, send(router, "/api/orders", "203.0.113.7").Code)
+			assert.Equal(t, http.StatusOK, send(router, "/api/orders", "10.1.2.3").Code)
+		}
+		send(router, "/api/orders", "203.0.113.8")
+		send(router, "/api/orders", "203.0.113.8")
+		assert.Equal(t, http.StatusTooManyRequests, send(router, "/api/orders", "203.0.113.8").Code)
+	})
+
+	t.Run("Limit - Spoofed X-Forwarded-For Is Ignored", func(t *testing.T) {
+		sendForwarded := func(router *gin.Engine, forwardedFor string) int {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/api/orders", nil)
+			req.RemoteAddr = "203.0.113.7:4000"
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			router.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		router := newRouter(&middleware.RateLimiter{Store: &middleware.MemoryRateLimitStore{}})
+		assert.Nil(t, middleware.TrustProxies(router, ""))
+		assert.Equal(t, http.StatusOK, sendForwarded(router, "198.51.100.1"))
+		assert.Equal(t, http.StatusOK, sendForwarded(router, "198.51.100.2"))
+		assert.Equal(t, http.StatusTooManyRequests, sendForwarded(router, "198.51.100.3"))
+
+		router = newRouter(&middleware.RateLimiter{Store: &middleware.MemoryRateLimitStore{}})
+		assert.Nil(t, middleware.TrustProxies(router, "203.0.113.0/24, 10.0.0.1"))
+		assert.Equal(t, http.StatusOK, sendForwarded(router, "198.51.100.1"))
+		assert.Equal(t, http.StatusOK, sendForwarded(router, "198.51.100.2"))
+		assert.Equal(t, http.StatusOK, sendForwarded(router, "198.51.100.3"))
+
+		router = newRouter(&middleware.RateLimiter{Store: &middleware.MemoryRateLimitStore{}})
+		assert.Nil(t, middleware.TrustProxies(router, middleware.NoTrustedProxies))
+		assert.Equal(t, http.StatusOK, sendForwarded(router, "198.51.100.1"))
+		assert.Equal(t, http.StatusOK, sendForwarded(router, "198.51.100.2"))
+		assert.Equal(t, http.StatusTooManyRequests, sendForwarded(router, "198.51.100.3"))
+
+		assert.NotNil(t, middleware.TrustProxies(router, "not-an-address"))
+	})
+
+	t.Run("Limit - Store Failure Lets Requests Through", func(t *testing.T) {
+		router := newRouter(&middleware.RateLimiter{Store: &failingRateLimitStore{}})
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, send(router, "/api/orders", "203.0.113.7").Code)
+		}
+	})
+
+	t.Run("MemoryRateLimitStore - Refills Over The Window", func(t *testing.T) {
+		store := &middleware.MemoryRateLimitStore{}
+		now := time.Now()
+
+		for i := 0; i < 2; i++ {
+			_, allowed, _ := store.Take(context.Background(), "orders:1.2.3.4", 2, 2.0/60, time.Minute, now)
+			assert.True(t, allowed)
+		}
+		_, allowed, _ := store.Take(context.Background(), "orders:1.2.3.4", 2, 2.0/60, time.Minute, now.Add(10*time.Second))
+		assert.False(t, allowed)
+		tokens, allowed, _ := store.Take(context.Background(), "orders:1.2.3.4", 2, 2.0/60, time.Minute, now.Add(40*time.Second))
+		assert.True(t, allowed)
+		assert.InDelta(t, 0.333, tokens, 0.01)
+	})
+
+	t.Run("ParseLimit", func(t *testing.T) {
+		limit, err := middleware.ParseLimit("10/1m")
+		assert.Nil(t, err)
+		assert.Equal(t, middleware.Limit{Requests: 10, Window: time.Minute}, limit)
+
+		for _, raw := range []string{"10", "0/1m", "ten/1m", "10/soon", "10/-1m"} {
+			_, err := middleware.ParseLimit(raw)
+			assert.Error(t, err, raw)
+		}
+		_, err = middleware.ParseAllowlist("10.0.0.0/33")
+		assert.Error(t, err)
+	})
+}
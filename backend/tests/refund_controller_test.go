@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRefundService struct {
+	mock.Mock
+}
+
+func (m *MockRefundService) IssueRefund(ctx context.Context, orderID string, request services.RefundRequest) (*models.Refund, error) {
+	args := m.Called(orderID, request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Refund), args.Error(1)
+}
+
+func (m *MockRefundService) GetOrderRefunds(ctx context.Context, orderID string) ([]models.Refund, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.Refund), args.Error(1)
+}
+
+func (m *MockRefundService) ListRefunds(ctx context.Context, status string) ([]models.Refund, error) {
+	args := m.Called(status)
+	return args.Get(0).([]models.Refund), args.Error(1)
+}
+
+func (m *MockRefundService) SyncRefund(ctx context.Context, id string) (*models.Refund, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Refund), args.Error(1)
+}
+
+func (m *MockRefundService) UpdateFromGateway(ctx context.Context, gatewayRefundID string, status string, failureReason string) error {
+	args := m.Called(gatewayRefundID, status, failureReason)
+	return args.Error(0)
+}
+
+func TestRefundController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("IssueRefund - Partial Refund Created", func(t *testing.T) {
+		mockService := new(MockRefundService)
+		request := services.RefundRequest{Items: []models.CartItem{{ProductID: "prod1", Quantity: 1}}, Reason: "damaged"}
+		mockService.On("IssueRefund", "order1", request).Return(&models.Refund{ID: "rfd_1", Amount: 199.0}, nil)
+
+		controller := &controllers.RefundController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/orders/order1/refunds",
+			bytes.NewBufferString(`{"items": [{"product_id": "prod1", "quantity": 1}], "reason": "damaged"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		controller.IssueRefund(c)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Contains(t, rr.Body.String(), "rfd_1")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Order Not Paid", func(t *testing.T) {
+		mockService := new(MockRefundService)
+		mockService.On("IssueRefund", "order1", mock.Anything).Return(nil, services.ErrOrderNotPaid)
+
+		controller := &controllers.RefundController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/orders/order1/refunds", bytes.NewBufferString(`{"reason": "goodwill"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		controller.IssueRefund(c)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Reason Required", func(t *testing.T) {
+		mockService := new(MockRefundService)
+		controller := &controllers.RefundController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Params = gin.Params{{Key: "order_id", Value: "order1"}}
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/orders/order1/refunds", bytes.NewBufferString(`{}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		controller.IssueRefund(c)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertNotCalled(t, "IssueRefund", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ListRefunds - Filters By Status", func(t *testing.T) {
+		mockService := new(MockRefundService)
+		mockService.On("ListRefunds", "failed").Return([]models.Refund{{ID: "rfd_1", Status: "failed"}}, nil)
+
+		controller := &controllers.RefundController{Service: mockService}
+
+		rr := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rr)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/refunds?status=failed", nil)
+
+		controller.ListRefunds(c)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "rfd_1")
+		mockService.AssertExpectations(t)
+	})
+}
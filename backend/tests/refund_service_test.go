@@ -0,0 +1,311 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockRefundRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefundRepository) CreateRefund(ctx context.Context, refund models.Refund) error {
+	args := m.Called(refund)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) GetRefund(ctx context.Context, id string) (*models.Refund, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Refund), args.Error(1)
+}
+
+func (m *MockRefundRepository) GetRefundByGatewayID(ctx context.Context, gatewayRefundID string) (*models.Refund, error) {
+	args := m.Called(gatewayRefundID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Refund), args.Error(1)
+}
+
+func (m *MockRefundRepository) GetRefundsByOrder(ctx context.Context, orderID string) ([]models.Refund, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.Refund), args.Error(1)
+}
+
+func (m *MockRefundRepository) ListRefunds(ctx context.Context, status string) ([]models.Refund, error) {
+	args := m.Called(status)
+	return args.Get(0).([]models.Refund), args.Error(1)
+}
+
+func (m *MockRefundRepository) SetGatewayRefund(ctx context.Context, id string, gatewayRefundID string, status string) error {
+	args := m.Called(id, gatewayRefundID, status)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) UpdateRefundStatus(ctx context.Context, id string, status string, failureReason string) error {
+	args := m.Called(id, status, failureReason)
+	return args.Error(0)
+}
+
+func TestRefundService(t *testing.T) {
+	paidOrder := func() *models.Order {
+		return &models.Order{
+			ID: "order1",
+			Items: []models.CartItem{
+				{ProductID: "prod1", Quantity: 2, Price: 199.0},
+				{ProductID: "prod2", Quantity: 1, Price: 450.0},
+			},
+			TotalAmount:   848.0,
+			Status:        models.OrderStatusDelivered,
+			PaymentStatus: models.PaymentStatusPaid,
+			PaymentID:     "pay_123",
+		}
+	}
+
+	t.Run("IssueRefund - Full Balance", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return([]models.Refund{}, nil)
+		mockOrderRepo.On("ReserveRefund", "order1", 848.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.MatchedBy(func(refund models.Refund) bool {
+			return refund.Amount == 848.0 && refund.Status == models.RefundStatusPending && len(refund.Items) == 0
+		})).Return(nil)
+		mockGateway.On("Refund", "pay_123", int64(84800), mock.Anything).Return(&services.GatewayRefund{ID: "rfnd_1", Status: "processed"}, nil)
+		mockRefundRepo.On("SetGatewayRefund", mock.Anything, "rfnd_1", models.RefundStatusProcessed).Return(nil)
+		mockOrderRepo.On("SetRefund", "order1", "rfnd_1", models.PaymentStatusRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway}
+		refund, err := service.IssueRefund(context.Background(), "order1", services.RefundRequest{Reason: "goodwill"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "rfnd_1", refund.GatewayRefundID)
+		assert.Equal(t, models.RefundStatusProcessed, refund.Status)
+		mockOrderRepo.AssertExpectations(t)
+		mockRefundRepo.AssertExpectations(t)
+		mockGateway.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Partial Line Item", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		previous := []models.Refund{{ID: "rfd_0", Amount: 199.0, Status: models.RefundStatusProcessed, Items: []models.CartItem{{ProductID: "prod1", Quantity: 1}}}}
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return(previous, nil)
+		mockOrderRepo.On("ReserveRefund", "order1", 199.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.MatchedBy(func(refund models.Refund) bool {
+			return refund.Amount == 199.0 && refund.Items[0].Price == 199.0
+		})).Return(nil)
+		mockGateway.On("Refund", "pay_123", int64(19900), mock.Anything).Return(&services.GatewayRefund{ID: "rfnd_2", Status: "pending"}, nil)
+		mockRefundRepo.On("SetGatewayRefund", mock.Anything, "rfnd_2", models.RefundStatusPending).Return(nil)
+		mockOrderRepo.On("SetRefund", "order1", "rfnd_2", models.PaymentStatusPartiallyRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway}
+		refund, err := service.IssueRefund(context.Background(), "order1", services.RefundRequest{
+			Items:  []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			Reason: "one pack damaged",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 199.0, refund.Amount)
+		mockOrderRepo.AssertExpectations(t)
+		mockRefundRepo.AssertExpectations(t)
+		mockGateway.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Quantity Already Refunded", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		previous := []models.Refund{{ID: "rfd_0", Amount: 398.0, Status: models.RefundStatusProcessed, Items: []models.CartItem{{ProductID: "prod1", Quantity: 2}}}}
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return(previous, nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway}
+		refund, err := service.IssueRefund(context.Background(), "order1", services.RefundRequest{
+			Items:  []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			Reason: "again",
+		})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidRefund))
+		assert.Nil(t, refund)
+		mockRefundRepo.AssertNotCalled(t, "CreateRefund", mock.Anything)
+		mockGateway.AssertNotCalled(t, "Refund", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("IssueRefund - Unpaid Order", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+
+		unpaid := paidOrder()
+		unpaid.PaymentStatus = ""
+		unpaid.PaymentID = ""
+		mockOrderRepo.On("GetOrder", "order1").Return(unpaid, nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo}
+		refund, err := service.IssueRefund(context.Background(), "order1", services.RefundRequest{Reason: "x"})
+
+		assert.Equal(t, services.ErrOrderNotPaid, err)
+		assert.Nil(t, refund)
+	})
+
+	t.Run("IssueRefund - Gateway Failure Is Recorded", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return([]models.Refund{}, nil)
+		mockOrderRepo.On("ReserveRefund", "order1", 848.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.Anything).Return(nil)
+		mockGateway.On("Refund", "pay_123", int64(84800), mock.Anything).Return(nil, errors.New("insufficient balance"))
+		mockRefundRepo.On("UpdateRefundStatus", mock.Anything, models.RefundStatusFailed, "insufficient balance").Return(nil)
+		mockOrderRepo.On("ReleaseRefund", "order1", 848.0).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway}
+		refund, err := service.IssueRefund(context.Background(), "order1", services.RefundRequest{Reason: "goodwill"})
+
+		assert.True(t, errors.Is(err, services.ErrGatewayRefundFailed))
+		assert.Equal(t, models.RefundStatusFailed, refund.Status)
+		mockRefundRepo.AssertExpectations(t)
+		mockOrderRepo.AssertCalled(t, "ReleaseRefund", "order1", 848.0)
+		mockOrderRepo.AssertNotCalled(t, "SetRefund", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpdateFromGateway - Failed Refund Flags Order", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+
+		mockRefundRepo.On("GetRefundByGatewayID", "rfnd_1").Return(&models.Refund{ID: "rfd_1", OrderID: "order1", GatewayRefundID: "rfnd_1", Amount: 848.0, Status: models.RefundStatusPending}, nil)
+		mockRefundRepo.On("UpdateRefundStatus", "rfd_1", models.RefundStatusFailed, "").Return(nil)
+		mockOrderRepo.On("ReleaseRefund", "order1", 848.0).Return(nil)
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return([]models.Refund{{ID: "rfd_1", Amount: 848.0, Status: models.RefundStatusFailed}}, nil)
+		mockOrderRepo.On("SetRefund", "order1", "rfnd_1", models.PaymentStatusRefundFailed).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo}
+		err := service.UpdateFromGateway(context.Background(), "rfnd_1", "failed", "")
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+		mockRefundRepo.AssertExpectations(t)
+	})
+
+	t.Run("UpdateFromGateway - Redelivered Failure Releases Once", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+
+		mockRefundRepo.On("GetRefundByGatewayID", "rfnd_1").Return(&models.Refund{ID: "rfd_1", OrderID: "order1", GatewayRefundID: "rfnd_1", Amount: 848.0, Status: models.RefundStatusFailed}, nil)
+		mockRefundRepo.On("UpdateRefundStatus", "rfd_1", models.RefundStatusFailed, "").Return(nil)
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return([]models.Refund{{ID: "rfd_1", Amount: 848.0, Status: models.RefundStatusFailed}}, nil)
+		mockOrderRepo.On("SetRefund", "order1", "rfnd_1", models.PaymentStatusRefundFailed).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo}
+		err := service.UpdateFromGateway(context.Background(), "rfnd_1", "failed", "")
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertNotCalled(t, "ReleaseRefund", mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpdateFromGateway - Failure Keeps Earlier Refunds", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+
+		mockRefundRepo.On("GetRefundByGatewayID", "rfnd_2").Return(&models.Refund{ID: "rfd_2", OrderID: "order1", GatewayRefundID: "rfnd_2", Amount: 450.0, Status: models.RefundStatusPending}, nil)
+		mockRefundRepo.On("UpdateRefundStatus", "rfd_2", models.RefundStatusFailed, "").Return(nil)
+		mockOrderRepo.On("ReleaseRefund", "order1", 450.0).Return(nil)
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return([]models.Refund{
+			{ID: "rfd_1", Amount: 398.0, Status: models.RefundStatusProcessed},
+			{ID: "rfd_2", Amount: 450.0, Status: models.RefundStatusFailed},
+		}, nil)
+		mockOrderRepo.On("SetRefund", "order1", "rfnd_2", models.PaymentStatusPartiallyRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo}
+		err := service.UpdateFromGateway(context.Background(), "rfnd_2", "failed", "")
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("SyncRefund - Failed Refund Flags Order", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+
+		mockRefundRepo.On("GetRefund", "rfd_1").Return(&models.Refund{ID: "rfd_1", OrderID: "order1", GatewayRefundID: "rfnd_1", Amount: 848.0, Status: models.RefundStatusPending}, nil)
+		mockGateway.On("FetchRefund", "rfnd_1").Return(&services.GatewayRefund{ID: "rfnd_1", Status: "failed"}, nil)
+		mockRefundRepo.On("UpdateRefundStatus", "rfd_1", models.RefundStatusFailed, "").Return(nil)
+		mockOrderRepo.On("ReleaseRefund", "order1", 848.0).Return(nil)
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return([]models.Refund{{ID: "rfd_1", Amount: 848.0, Status: models.RefundStatusFailed}}, nil)
+		mockOrderRepo.On("SetRefund", "order1", "rfnd_1", models.PaymentStatusRefundFailed).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway}
+		refund, err := service.SyncRefund(context.Background(), "rfd_1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.RefundStatusFailed, refund.Status)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Retries After A Failed Refund", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+
+		order := paidOrder()
+		order.PaymentStatus = models.PaymentStatusRefundFailed
+		mockOrderRepo.On("GetOrder", "order1").Return(order, nil)
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return([]models.Refund{{ID: "rfd_0", Amount: 848.0, Status: models.RefundStatusFailed}}, nil)
+		mockOrderRepo.On("ReserveRefund", "order1", 848.0).Return(nil)
+		mockRefundRepo.On("CreateRefund", mock.Anything).Return(nil)
+		mockGateway.On("Refund", "pay_123", int64(84800), mock.Anything).Return(&services.GatewayRefund{ID: "rfnd_2", Status: "processed"}, nil)
+		mockRefundRepo.On("SetGatewayRefund", mock.Anything, "rfnd_2", models.RefundStatusProcessed).Return(nil)
+		mockOrderRepo.On("SetRefund", "order1", "rfnd_2", models.PaymentStatusRefunded).Return(nil)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway}
+		refund, err := service.IssueRefund(context.Background(), "order1", services.RefundRequest{Reason: "goodwill"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "rfnd_2", refund.GatewayRefundID)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("IssueRefund - Concurrent Refund Wins The Reservation", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockRefundRepo := new(MockRefundRepository)
+		mockGateway := new(MockPaymentGateway)
+
+		mockOrderRepo.On("GetOrder", "order1").Return(paidOrder(), nil)
+		mockRefundRepo.On("GetRefundsByOrder", "order1").Return([]models.Refund{}, nil)
+		mockOrderRepo.On("ReserveRefund", "order1", 848.0).Return(mongo.ErrNoDocuments)
+
+		service := &services.RefundService{OrderRepository: mockOrderRepo, RefundRepository: mockRefundRepo, Gateway: mockGateway}
+		refund, err := service.IssueRefund(context.Background(), "order1", services.RefundRequest{Reason: "goodwill"})
+
+		assert.Equal(t, services.ErrNothingToRefund, err)
+		assert.Nil(t, refund)
+		mockRefundRepo.AssertNotCalled(t, "CreateRefund", mock.Anything)
+		mockGateway.AssertNotCalled(t, "Refund", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
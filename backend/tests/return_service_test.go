@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockReturnRepository struct {
+	mock.Mock
+}
+
+func (m *MockReturnRepository) CreateReturn(ctx context.Context, returnRequest models.ReturnRequest) error {
+	args := m.Called(returnRequest)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepository) GetReturn(ctx context.Context, id string) (*models.ReturnRequest, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.ReturnRequest), args.Error(1)
+}
+
+func (m *MockReturnRepository) GetReturnsByOrder(ctx context.Context, orderID string) ([]models.ReturnRequest, error) {
+	args := m.Called(orderID)
+	return args.Get(0).([]models.ReturnRequest), args.Error(1)
+}
+
+func (m *MockReturnRepository) ListReturns(ctx context.Context, status string) ([]models.ReturnRequest, error) {
+	args := m.Called(status)
+	return args.Get(0).([]models.ReturnRequest), args.Error(1)
+}
+
+func (m *MockReturnRepository) ResolveReturn(ctx context.Context, id string, status string, adminNote string) error {
+	args := m.Called(id, status, adminNote)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepository) SetReturnRefund(ctx context.Context, id string, refundID string) error {
+	args := m.Called(id, refundID)
+	return args.Error(0)
+}
+
+func TestReturnService(t *testing.T) {
+	deliveredOrder := func() *models.Order {
+		return &models.Order{
+			ID:           "order1",
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 2, Price: 199.0}},
+			CustomerInfo: models.CustomerInfo{Phone: "9876543210"},
+			Status:       models.OrderStatusDelivered,
+		}
+	}
+
+	t.Run("RequestReturn - Success", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockReturnRepo := new(MockReturnRepository)
+
+		mockOrderRepo.On("GetOrder", "order1").Return(deliveredOrder(), nil)
+		mockReturnRepo.On("CreateReturn", mock.MatchedBy(func(r models.ReturnRequest) bool {
+			return r.OrderID == "order1" && r.Status == models.ReturnStatusRequested && len(r.PhotoURLs) == 1
+		})).Return(nil)
+
+		service := &services.ReturnService{OrderRepository: mockOrderRepo, ReturnRepository: mockReturnRepo}
+		returnRequest, err := service.RequestReturn(context.Background(), "order1", services.ReturnRequestInput{
+			Phone:     "+91 98765 43210",
+			Items:     []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			Reason:    "Seal was broken",
+			PhotoURLs: []string{"https://example.com/photo.jpg"},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.ReturnStatusRequested, returnRequest.Status)
+		mockReturnRepo.AssertExpectations(t)
+	})
+
+	t.Run("RequestReturn - Order Not Delivered", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockReturnRepo := new(MockReturnRepository)
+
+		order := deliveredOrder()
+		order.Status = models.OrderStatusShipped
+		mockOrderRepo.On("GetOrder", "order1").Return(order, nil)
+
+		service := &services.ReturnService{OrderRepository: mockOrderRepo, ReturnRepository: mockReturnRepo}
+		returnRequest, err := service.RequestReturn(context.Background(), "order1", services.ReturnRequestInput{
+			Phone:  "9876543210",
+			Items:  []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			Reason: "Changed my mind",
+		})
+
+		assert.Equal(t, services.ErrReturnNotAllowed, err)
+		assert.Nil(t, returnRequest)
+		mockReturnRepo.AssertNotCalled(t, "CreateReturn", mock.Anything)
+	})
+
+	t.Run("RequestReturn - Invalid Photo URL", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockReturnRepo := new(MockReturnRepository)
+
+		mockOrderRepo.On("GetOrder", "order1").Return(deliveredOrder(), nil)
+
+		service := &services.ReturnService{OrderRepository: mockOrderRepo, ReturnRepository: mockReturnRepo}
+		_, err := service.RequestReturn(context.Background(), "order1", services.ReturnRequestInput{
+			Phone:     "9876543210",
+			Items:     []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			Reason:    "Seal was broken",
+			PhotoURLs: []string{"file:///etc/passwd"},
+		})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidReturn))
+	})
+
+	t.Run("ApproveReturn - Refunds Returned Items", func(t *testing.T) {
+		mockReturnRepo := new(MockReturnRepository)
+		mockRefunds := new(MockRefundService)
+
+		pending := &models.ReturnRequest{
+			ID:      "ret_1",
+			OrderID: "order1",
+			Items:   []models.CartItem{{ProductID: "prod1", Quantity: 1}},
+			Reason:  "Seal was broken",
+			Status:  models.ReturnStatusRequested,
+		}
+		mockReturnRepo.On("GetReturn", "ret_1").Return(pending, nil)
+		mockReturnRepo.On("ResolveReturn", "ret_1", models.ReturnStatusApproved, "ok").Return(nil)
+		mockRefunds.On("IssueRefund", "order1", services.RefundRequest{
+			Items:    pending.Items,
+			Reason:   "Return: Seal was broken",
+			ReturnID: "ret_1",
+		}).Return(&models.Refund{ID: "rfd_1"}, nil)
+		mockReturnRepo.On("SetReturnRefund", "ret_1", "rfd_1").Return(nil)
+
+		service := &services.ReturnService{ReturnRepository: mockReturnRepo, Refunds: mockRefunds}
+		returnRequest, err := service.ApproveReturn(context.Background(), "ret_1", services.ReturnDecision{Note: "ok", Refund: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.ReturnStatusApproved, returnRequest.Status)
+		assert.Equal(t, "rfd_1", returnRequest.RefundID)
+		mockReturnRepo.AssertExpectations(t)
+		mockRefunds.AssertExpectations(t)
+	})
+
+	t.Run("RejectReturn - Already Resolved", func(t *testing.T) {
+		mockReturnRepo := new(MockReturnRepository)
+
+		mockReturnRepo.On("GetReturn", "ret_1").Return(&models.ReturnRequest{ID: "ret_1", Status: models.ReturnStatusApproved}, nil)
+		mockReturnRepo.On("ResolveReturn", "ret_1", models.ReturnStatusRejected, "").Return(mongo.ErrNoDocuments)
+
+		service := &services.ReturnService{ReturnRepository: mockReturnRepo}
+		_, err := service.RejectReturn(context.Background(), "ret_1", services.ReturnDecision{})
+
+		assert.Equal(t, services.ErrReturnNotPending, err)
+	})
+}
@@ -0,0 +1,392 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockReviewRepository struct {
+	mock.Mock
+}
+
+func (m *MockReviewRepository) CreateReview(ctx context.Context, review models.Review) error {
+	args := m.Called(review)
+	return args.Error(0)
+}
+
+func (m *MockReviewRepository) GetReview(ctx context.Context, id string) (*models.Review, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Review), args.Error(1)
+}
+
+func (m *MockReviewRepository) ListReviews(ctx context.Context, filter repositories.ReviewFilter) ([]models.Review, int64, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]models.Review), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockReviewRepository) SetReviewStatus(ctx context.Context, id string, status string, note string) error {
+	args := m.Called(id, status, note)
+	return args.Error(0)
+}
+
+func (m *MockReviewRepository) AddHelpfulVote(ctx context.Context, id string, voterID string) error {
+	args := m.Called(id, voterID)
+	return args.Error(0)
+}
+
+func (m *MockReviewRepository) RatingSummary(ctx context.Context, productID string) (float64, int, error) {
+	args := m.Called(productID)
+	return args.Get(0).(float64), args.Int(1), args.Error(2)
+}
+
+type MockReviewService struct {
+	mock.Mock
+}
+
+func (m *MockReviewService) SubmitReview(ctx context.Context, productID string, request services.ReviewInput) (*models.Review, error) {
+	args := m.Called(productID, request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Review), args.Error(1)
+}
+
+func (m *MockReviewService) ListProductReviews(ctx context.Context, productID string, query services.ReviewListQuery) (*services.ReviewPage, error) {
+	args := m.Called(productID, query)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.ReviewPage), args.Error(1)
+}
+
+func (m *MockReviewService) MarkHelpful(ctx context.Context, productID string, reviewID string, customerID string) (*models.Review, error) {
+	args := m.Called(productID, reviewID, customerID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Review), args.Error(1)
+}
+
+func (m *MockReviewService) ListReviews(ctx context.Context, status string) ([]models.Review, error) {
+	args := m.Called(status)
+	return args.Get(0).([]models.Review), args.Error(1)
+}
+
+func (m *MockReviewService) ModerateReview(ctx context.Context, id string, status string, note string) (*models.Review, error) {
+	args := m.Called(id, status, note)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Review), args.Error(1)
+}
+
+func TestReviewService(t *testing.T) {
+	delivered := &models.Order{
+		ID:           "order1",
+		CustomerID:   "cus_1",
+		CustomerInfo: models.CustomerInfo{Name: "Asha Rao", Phone: "+919876543210"},
+		Items:        []models.CartItem{{ProductID: "prod1", Quantity: 1, Price: 199.0}},
+		Status:       models.OrderStatusDelivered,
+	}
+
+	t.Run("SubmitReview - Queues Verified Review For Moderation", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(delivered, nil)
+		mockReviews.On("CreateReview", mock.MatchedBy(func(review models.Review) bool {
+			return review.Status == models.ReviewStatusPending && review.AuthorName == "Asha" &&
+				review.ProductID == "prod1" && review.OrderID == "order1" && review.Rating == 5
+		})).Return(nil)
+
+		service := &services.ReviewService{Repository: mockReviews, OrderRepository: mockOrderRepo}
+		review, err := service.SubmitReview(context.Background(), "prod1", services.ReviewInput{OrderID: "order1", Rating: 5, Text: " Lovely chai ", Phone: "9876543210"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Lovely chai", review.Text)
+		mockReviews.AssertExpectations(t)
+	})
+
+	t.Run("SubmitReview - Logged In Customer Owns Order", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(delivered, nil)
+		mockReviews.On("CreateReview", mock.Anything).Return(nil)
+
+		service := &services.ReviewService{Repository: mockReviews, OrderRepository: mockOrderRepo}
+		_, err := service.SubmitReview(context.Background(), "prod1", services.ReviewInput{OrderID: "order1", Rating: 4, Text: "Good", CustomerID: "cus_1"})
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("SubmitReview - Wrong Contact Hides Order", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(delivered, nil)
+
+		service := &services.ReviewService{Repository: new(MockReviewRepository), OrderRepository: mockOrderRepo}
+		_, err := service.SubmitReview(context.Background(), "prod1", services.ReviewInput{OrderID: "order1", Rating: 4, Text: "Good", Phone: "9999999999"})
+
+		assert.True(t, errors.Is(err, services.ErrOrderNotFound))
+	})
+
+	t.Run("SubmitReview - Requires Delivered Order With Product", func(t *testing.T) {
+		shipped := *delivered
+		shipped.Status = models.OrderStatusShipped
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(&shipped, nil)
+		mockOrderRepo.On("GetOrder", "order2").Return(delivered, nil)
+
+		service := &services.ReviewService{Repository: new(MockReviewRepository), OrderRepository: mockOrderRepo}
+		_, err := service.SubmitReview(context.Background(), "prod1", services.ReviewInput{OrderID: "order1", Rating: 4, Text: "Good", CustomerID: "cus_1"})
+		assert.True(t, errors.Is(err, services.ErrReviewNotAllowed))
+
+		_, err = service.SubmitReview(context.Background(), "prod2", services.ReviewInput{OrderID: "order2", Rating: 4, Text: "Good", CustomerID: "cus_1"})
+		assert.True(t, errors.Is(err, services.ErrReviewNotAllowed))
+	})
+
+	t.Run("SubmitReview - Invalid Rating", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(delivered, nil)
+
+		service := &services.ReviewService{Repository: new(MockReviewRepository), OrderRepository: mockOrderRepo}
+		_, err := service.SubmitReview(context.Background(), "prod1", services.ReviewInput{OrderID: "order1", Rating: 6, Text: "Good", CustomerID: "cus_1"})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidReview))
+	})
+
+	t.Run("SubmitReview - One Review Per Order And Product", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(delivered, nil)
+		mockReviews.On("CreateReview", mock.Anything).Return(mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}})
+
+		service := &services.ReviewService{Repository: mockReviews, OrderRepository: mockOrderRepo}
+		_, err := service.SubmitReview(context.Background(), "prod1", services.ReviewInput{OrderID: "order1", Rating: 4, Text: "Good", CustomerID: "cus_1"})
+
+		assert.True(t, errors.Is(err, services.ErrDuplicateReview))
+	})
+
+	t.Run("ListProductReviews - Approved Reviews By Helpfulness", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockReviews.On("ListReviews", repositories.ReviewFilter{
+			ProductID: "prod1",
+			Status:    []string{models.ReviewStatusApproved},
+			SortField: "helpful_count",
+			Skip:      10,
+			Limit:     10,
+		}).Return([]models.Review{{ID: "rev_1"}}, int64(11), nil)
+
+		service := &services.ReviewService{Repository: mockReviews}
+		page, err := service.ListProductReviews(context.Background(), "prod1", services.ReviewListQuery{Page: 2})
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(11), page.Total)
+		assert.Equal(t, 2, page.Page)
+		assert.Len(t, page.Reviews, 1)
+	})
+
+	t.Run("ListProductReviews - Invalid Sort", func(t *testing.T) {
+		service := &services.ReviewService{Repository: new(MockReviewRepository)}
+		_, err := service.ListProductReviews(context.Background(), "prod1", services.ReviewListQuery{Sort: "rating"})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidReviewQuery))
+	})
+
+	t.Run("ModerateReview - Approval Updates Product Rating", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockReviews.On("GetReview", "rev_1").Return(&models.Review{ID: "rev_1", ProductID: "prod1", Status: models.ReviewStatusPending}, nil)
+		mockReviews.On("SetReviewStatus", "rev_1", models.ReviewStatusApproved, "").Return(nil)
+		mockReviews.On("RatingSummary", "prod1").Return(4.333333, 3, nil)
+		mockProductRepo.On("SetRating", "prod1", 4.3, 3).Return(nil)
+
+		service := &services.ReviewService{Repository: mockReviews, ProductRepository: mockProductRepo}
+		review, err := service.ModerateReview(context.Background(), "rev_1", models.ReviewStatusApproved, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.ReviewStatusApproved, review.Status)
+		mockProductRepo.AssertExpectations(t)
+	})
+
+	t.Run("ModerateReview - Rejecting Pending Review Leaves Rating", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockReviews.On("GetReview", "rev_1").Return(&models.Review{ID: "rev_1", ProductID: "prod1", Status: models.ReviewStatusPending}, nil)
+		mockReviews.On("SetReviewStatus", "rev_1", models.ReviewStatusRejected, "spam").Return(nil)
+
+		service := &services.ReviewService{Repository: mockReviews, ProductRepository: mockProductRepo}
+		_, err := service.ModerateReview(context.Background(), "rev_1", models.ReviewStatusRejected, "spam")
+
+		assert.Nil(t, err)
+		mockProductRepo.AssertNotCalled(t, "SetRating", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ModerateReview - Flagging Approved Review Removes It From Rating", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockReviews.On("GetReview", "rev_1").Return(&models.Review{ID: "rev_1", ProductID: "prod1", Status: models.ReviewStatusApproved}, nil)
+		mockReviews.On("SetReviewStatus", "rev_1", models.ReviewStatusFlagged, "").Return(nil)
+		mockReviews.On("RatingSummary", "prod1").Return(0.0, 0, nil)
+		mockProductRepo.On("SetRating", "prod1", 0.0, 0).Return(nil)
+
+		service := &services.ReviewService{Repository: mockReviews, ProductRepository: mockProductRepo}
+		_, err := service.ModerateReview(context.Background(), "rev_1", models.ReviewStatusFlagged, "")
+
+		assert.Nil(t, err)
+		mockProductRepo.AssertExpectations(t)
+	})
+
+	t.Run("MarkHelpful - Requires Approved Review Of Product", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockReviews.On("GetReview", "rev_1").Return(&models.Review{ID: "rev_1", ProductID: "prod1", Status: models.ReviewStatusPending}, nil)
+
+		service := &services.ReviewService{Repository: mockReviews}
+		_, err := service.MarkHelpful(context.Background(), "prod1", "rev_1", "cus_2")
+
+		assert.True(t, errors.Is(err, services.ErrReviewNotFound))
+		mockReviews.AssertNotCalled(t, "AddHelpfulVote", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ListReviews - Defaults To Moderation Queue", func(t *testing.T) {
+		mockReviews := new(MockReviewRepository)
+		mockReviews.On("ListReviews", repositories.ReviewFilter{
+			Status:        []string{models.ReviewStatusPending, models.ReviewStatusFlagged},
+			SortAscending: true,
+			Limit:         200,
+		}).Return([]models.Review{}, int64(0), nil)
+
+		service := &services.ReviewService{Repository: mockReviews}
+		_, err := service.ListReviews(context.Background(), "")
+
+		assert.Nil(t, err)
+		mockReviews.AssertExpectations(t)
+	})
+}
+
+func TestReviewController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := &services.CustomerTokens{Secret: []byte("test-secret")}
+
+	t.Run("SubmitReview - Uses Logged In Customer", func(t *testing.T) {
+		mockService := new(MockReviewService)
+		mockService.On("SubmitReview", "prod1", services.ReviewInput{OrderID: "order1", Rating: 5, Text: "Great", CustomerID: "cus_1"}).
+			Return(&models.Review{ID: "rev_1"}, nil)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		router := gin.New()
+		controller := &controllers.ReviewController{Service: mockService}
+		router.POST("/api/products/:product_id/reviews", middleware.CustomerAuth(tokens), controller.SubmitReview)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/products/prod1/reviews", bytes.NewBufferString(`{"order_id": "order1", "rating": 5, "text": "Great"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("SubmitReview - Guest Needs Contact", func(t *testing.T) {
+		mockService := new(MockReviewService)
+
+		router := gin.New()
+		controller := &controllers.ReviewController{Service: mockService}
+		router.POST("/api/products/:product_id/reviews", middleware.CustomerAuth(tokens), controller.SubmitReview)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/products/prod1/reviews", bytes.NewBufferString(`{"order_id": "order1", "rating": 5, "text": "Great"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertNotCalled(t, "SubmitReview", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ListProductReviews - Passes Query", func(t *testing.T) {
+		mockService := new(MockReviewService)
+		mockService.On("ListProductReviews", "prod1", services.ReviewListQuery{Sort: "recent", Page: 2, PageSize: 5}).
+			Return(&services.ReviewPage{Reviews: []models.Review{}, Page: 2, PageSize: 5}, nil)
+
+		router := gin.New()
+		controller := &controllers.ReviewController{Service: mockService}
+		router.GET("/api/products/:product_id/reviews", controller.ListProductReviews)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/products/prod1/reviews?sort=recent&page=2&page_size=5", nil)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("MarkHelpful - Guest Is Unauthorized", func(t *testing.T) {
+		mockService := new(MockReviewService)
+		mockService.On("MarkHelpful", "prod1", "rev_1", "").Return(nil, services.ErrHelpfulVoteRequired)
+
+		router := gin.New()
+		controller := &controllers.ReviewController{Service: mockService}
+		router.POST("/api/products/:product_id/reviews/:review_id/helpful", middleware.CustomerAuth(tokens), controller.MarkHelpful)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/products/prod1/reviews/rev_1/helpful", nil)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("FlagReview - Without Body", func(t *testing.T) {
+		mockService := new(MockReviewService)
+		mockService.On("ModerateReview", "rev_1", models.ReviewStatusFlagged, "").Return(&models.Review{ID: "rev_1", Status: models.ReviewStatusFlagged}, nil)
+
+		router := gin.New()
+		controller := &controllers.ReviewController{Service: mockService}
+		router.POST("/api/admin/reviews/:review_id/flag", controller.FlagReview)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/reviews/rev_1/flag", nil)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("ApproveReview - Not Found", func(t *testing.T) {
+		mockService := new(MockReviewService)
+		mockService.On("ModerateReview", "rev_9", models.ReviewStatusApproved, "ok").Return(nil, services.ErrReviewNotFound)
+
+		router := gin.New()
+		controller := &controllers.ReviewController{Service: mockService}
+		router.POST("/api/admin/reviews/:review_id/approve", controller.ApproveReview)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/reviews/rev_9/approve", bytes.NewBufferString(`{"note": "ok"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,434 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+	"mangal-chai-backend/shipping"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockShippingService struct {
+	mock.Mock
+}
+
+func (m *MockShippingService) BookShipment(ctx context.Context, orderID string, request services.BookShipmentRequest) (*models.Order, error) {
+	args := m.Called(orderID, request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Order), args.Error(1)
+}
+
+func (m *MockShippingService) GetLabel(ctx context.Context, orderID string) (string, error) {
+	args := m.Called(orderID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockShippingService) CancelShipment(ctx context.Context, orderID string) (*models.Order, error) {
+	args := m.Called(orderID)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Order), args.Error(1)
+}
+
+func (m *MockShippingService) HandleWebhook(ctx context.Context, body []byte, signature string) error {
+	args := m.Called(body, signature)
+	return args.Error(0)
+}
+
+func (m *MockShippingService) PollTracking(ctx context.Context) (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+// courierStub serves a Shiprocket-style shipping API for one shipment, shp_1 with AWB AWB1, whose tracking
+// returns events.
+func courierStub(t *testing.T, events string) (*httptest.Server, *map[string]interface{}) {
+	booked := map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		switch r.Method + " " + r.URL.Path {
+		case "POST /shipments":
+			json.NewDecoder(r.Body).Decode(&booked)
+			w.Write([]byte(`{"shipment_id": "shp_1", "awb": "AWB1", "courier": "Delhivery", "tracking_url": "https://track.example.com/AWB1"}`))
+		case "POST /shipments/shp_1/label":
+			w.Write([]byte(`{"label_url": "https://labels.example.com/shp_1.pdf"}`))
+		case "GET /tracking/AWB1":
+			w.Write([]byte(`{"awb": "AWB1", "events": ` + events + `}`))
+		case "POST /shipments/shp_1/cancel":
+			w.Write([]byte(`{"status": "cancelled"}`))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	return server, &booked
+}
+
+func TestShippingProviders(t *testing.T) {
+	t.Run("HTTPProvider - Books, Labels And Tracks A Parcel", func(t *testing.T) {
+		server, booked := courierStub(t, `[
+			{"status": "PICKED_UP", "location": "Pune", "occurred_at": "2026-10-01T10:00:00Z"},
+			{"status": "Out For Delivery", "location": "Mumbai", "occurred_at": "2026-10-02T08:00:00Z"},
+			{"status": "WEIGHED", "occurred_at": "2026-10-01T11:00:00Z"}
+		]`)
+		defer server.Close()
+		provider := &shipping.HTTPProvider{BaseURL: server.URL, APIKey: "key", PickupLocation: "Warehouse"}
+
+		booking, err := provider.CreateShipment(shipping.Parcel{
+			Order: models.Order{
+				ID:           "order1",
+				CustomerInfo: models.CustomerInfo{Name: "Asha", Phone: "9876543210", Address: "12 MG Road, Mumbai"},
+				Items:        []models.CartItem{{ProductID: "masala-chai", Quantity: 2, Price: 250}},
+				TotalAmount:  500,
+			},
+			WeightGrams: 600,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, &shipping.Booking{ShipmentID: "shp_1", AWB: "AWB1", Courier: "Delhivery", TrackingURL: "https://track.example.com/AWB1"}, booking)
+		assert.Equal(t, "order1", (*booked)["order_id"])
+		assert.Equal(t, "Warehouse", (*booked)["pickup_location"])
+		assert.Equal(t, "prepaid", (*booked)["payment_mode"])
+		assert.Equal(t, float64(600), (*booked)["weight_grams"])
+
+		label, err := provider.Label("shp_1")
+		assert.Nil(t, err)
+		assert.Equal(t, "https://labels.example.com/shp_1.pdf", label)
+
+		events, err := provider.Track("AWB1")
+		assert.Nil(t, err)
+		assert.Len(t, events, 3)
+		assert.Equal(t, models.TrackingStatusPickedUp, events[0].Status)
+		assert.Equal(t, models.TrackingStatusOutForDelivery, events[1].Status)
+		assert.Equal(t, "weighed", events[2].Status)
+		assert.Equal(t, "Mumbai", events[1].Location)
+	})
+
+	t.Run("HTTPProvider - Provider Error", func(t *testing.T) {
+		server, _ := courierStub(t, `[]`)
+		defer server.Close()
+		provider := &shipping.HTTPProvider{BaseURL: server.URL, APIKey: "key"}
+
+		_, err := provider.Label("shp_missing")
+
+		assert.ErrorContains(t, err, "404")
+	})
+
+	t.Run("HTTPProvider - Webhook", func(t *testing.T) {
+		provider := &shipping.HTTPProvider{WebhookSecret: "hook-secret"}
+		body := []byte(`{"awb": "AWB1", "events": [{"status": "DELIVERED", "occurred_at": "2026-10-02T12:00:00Z"}]}`)
+
+		assert.True(t, provider.VerifyWebhookSignature(body, sign("hook-secret", body)))
+		assert.False(t, provider.VerifyWebhookSignature(body, sign("other-secret", body)))
+		assert.False(t, (&shipping.HTTPProvider{}).VerifyWebhookSignature(body, sign("", body)))
+
+		update, err := provider.ParseTrackingWebhook(body)
+		assert.Nil(t, err)
+		assert.Equal(t, "AWB1", update.AWB)
+		assert.Equal(t, models.TrackingStatusDelivered, update.Events[0].Status)
+	})
+
+	t.Run("ManualProvider - Requires Courier And Tracking Number", func(t *testing.T) {
+		provider := &shipping.ManualProvider{}
+
+		_, err := provider.CreateShipment(shipping.Parcel{Courier: "India Post"})
+		assert.True(t, errors.Is(err, shipping.ErrInvalidParcel))
+
+		booking, err := provider.CreateShipment(shipping.Parcel{Courier: "India Post", TrackingNumber: "EM123IN"})
+		assert.Nil(t, err)
+		assert.Equal(t, "EM123IN", booking.AWB)
+		_, err = provider.Label("")
+		assert.Equal(t, shipping.ErrNotSupported, err)
+	})
+}
+
+func TestShippingService(t *testing.T) {
+	packed := func() *models.Order {
+		return &models.Order{
+			ID:           "order1",
+			Status:       models.OrderStatusPacked,
+			CustomerInfo: models.CustomerInfo{Name: "Asha", Phone: "9876543210", Address: "12 MG Road, Mumbai"},
+		}
+	}
+	bookedShipment := func() *models.Shipment {
+		return &models.Shipment{
+			Provider:           shipping.ProviderHTTP,
+			ProviderShipmentID: "shp_1",
+			Courier:            "Delhivery",
+			TrackingNumber:     "AWB1",
+			Status:             models.TrackingStatusBooked,
+			Events: []models.TrackingEvent{
+				{Status: models.TrackingStatusBooked, OccurredAt: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)},
+			},
+		}
+	}
+
+	t.Run("BookShipment - Books A Packed Order With The Courier", func(t *testing.T) {
+		server, _ := courierStub(t, `[]`)
+		defer server.Close()
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(packed(), nil)
+		var saved models.Shipment
+		mockOrderRepo.On("SetShipment", "order1", mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(1).(models.Shipment)
+		}).Return(nil)
+
+		service := &services.ShippingService{Orders: mockOrderRepo, Provider: &shipping.HTTPProvider{BaseURL: server.URL, APIKey: "key"}}
+		order, err := service.BookShipment(context.Background(), "order1", services.BookShipmentRequest{WeightGrams: 600})
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.OrderStatusPacked, order.Status)
+		assert.Equal(t, shipping.ProviderHTTP, saved.Provider)
+		assert.Equal(t, "shp_1", saved.ProviderShipmentID)
+		assert.Equal(t, "AWB1", saved.TrackingNumber)
+		assert.Equal(t, "https://labels.example.com/shp_1.pdf", saved.LabelURL)
+		assert.Equal(t, models.TrackingStatusBooked, saved.Status)
+		assert.Len(t, saved.Events, 1)
+		assert.NotNil(t, saved.BookedAt)
+		assert.Nil(t, saved.ShippedAt)
+	})
+
+	t.Run("BookShipment - Only Packed Orders Without A Booking", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		confirmed := packed()
+		confirmed.Status = models.OrderStatusConfirmed
+		alreadyBooked := packed()
+		alreadyBooked.ID = "order2"
+		alreadyBooked.Shipment = bookedShipment()
+		mockOrderRepo.On("GetOrder", "order1").Return(confirmed, nil)
+		mockOrderRepo.On("GetOrder", "order2").Return(alreadyBooked, nil)
+		mockOrderRepo.On("GetOrder", "missing").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.ShippingService{Orders: mockOrderRepo, Provider: &shipping.ManualProvider{}}
+		_, err := service.BookShipment(context.Background(), "order1", services.BookShipmentRequest{})
+		assert.Equal(t, services.ErrOrderNotShippable, err)
+		_, err = service.BookShipment(context.Background(), "order2", services.BookShipmentRequest{Courier: "DTDC", TrackingNumber: "D1"})
+		assert.Equal(t, services.ErrShipmentExists, err)
+		_, err = service.BookShipment(context.Background(), "missing", services.BookShipmentRequest{})
+		assert.Equal(t, services.ErrOrderNotFound, err)
+		mockOrderRepo.AssertNotCalled(t, "SetShipment", mock.Anything, mock.Anything)
+	})
+
+	t.Run("BookShipment - Manual Provider Needs The Tracking Number", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "order1").Return(packed(), nil)
+
+		service := &services.ShippingService{Orders: mockOrderRepo, Provider: &shipping.ManualProvider{}}
+		_, err := service.BookShipment(context.Background(), "order1", services.BookShipmentRequest{Courier: "India Post"})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidShipment))
+	})
+
+	t.Run("HandleWebhook - Delivery Ships And Delivers The Order", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		order := packed()
+		order.Shipment = bookedShipment()
+		mockOrderRepo.On("GetOrderByTrackingNumber", "AWB1").Return(order, nil)
+		pickedUp := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+		mockOrderRepo.On("AddTrackingEvents", "order1", models.TrackingStatusDelivered, mock.MatchedBy(func(events []models.TrackingEvent) bool {
+			// The booking event the order already has is not added again.
+			return len(events) == 2
+		}), &pickedUp).Return(nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPacked}, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.OrderStatusShipped
+		}), notifies(models.OrderEventShipped)).Return(&models.Order{ID: "order1", Status: models.OrderStatusShipped}, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusShipped}, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.OrderStatusDelivered
+		}), notifies(models.OrderEventDelivered)).Return(&models.Order{ID: "order1", Status: models.OrderStatusDelivered}, nil)
+
+		service := &services.ShippingService{
+			Orders:   mockOrderRepo,
+			Provider: &shipping.HTTPProvider{WebhookSecret: "hook-secret"},
+		}
+		body := []byte(`{"awb": "AWB1", "events": [
+			{"status": "BOOKED", "occurred_at": "2026-10-01T09:00:00Z"},
+			{"status": "PICKED_UP", "occurred_at": "2026-10-01T10:00:00Z"},
+			{"status": "DELIVERED", "occurred_at": "2026-10-02T12:00:00Z"}
+		]}`)
+		err := service.HandleWebhook(context.Background(), body, sign("hook-secret", body))
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+
+	t.Run("HandleWebhook - Invalid Signature", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		service := &services.ShippingService{Orders: mockOrderRepo, Provider: &shipping.HTTPProvider{WebhookSecret: "hook-secret"}}
+
+		err := service.HandleWebhook(context.Background(), []byte(`{"awb": "AWB1"}`), "forged")
+
+		assert.Equal(t, services.ErrInvalidWebhookSignature, err)
+		mockOrderRepo.AssertNotCalled(t, "GetOrderByTrackingNumber", mock.Anything)
+	})
+
+	t.Run("HandleWebhook - Unknown AWB Is Ignored", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrderByTrackingNumber", "AWB9").Return(nil, mongo.ErrNoDocuments)
+		service := &services.ShippingService{Orders: mockOrderRepo, Provider: &shipping.HTTPProvider{WebhookSecret: "hook-secret"}}
+
+		body := []byte(`{"awb": "AWB9", "events": [{"status": "DELIVERED"}]}`)
+		err := service.HandleWebhook(context.Background(), body, sign("hook-secret", body))
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertNotCalled(t, "AddTrackingEvents", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("PollTracking - Pickup Ships The Order", func(t *testing.T) {
+		server, _ := courierStub(t, `[{"status": "PICKED_UP", "occurred_at": "2026-10-01T10:00:00Z"}]`)
+		defer server.Close()
+		mockOrderRepo := new(MockOrderRepository)
+		order := packed()
+		order.Shipment = bookedShipment()
+		mockOrderRepo.On("ListTrackedShipments", shipping.ProviderHTTP, int64(100)).Return([]models.Order{*order}, nil)
+		mockOrderRepo.On("AddTrackingEvents", "order1", models.TrackingStatusPickedUp, mock.Anything, mock.Anything).Return(nil)
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPacked}, mock.Anything, notifies(models.OrderEventShipped)).
+			Return(&models.Order{ID: "order1", Status: models.OrderStatusShipped}, nil)
+
+		service := &services.ShippingService{
+			Orders:   mockOrderRepo,
+			Provider: &shipping.HTTPProvider{BaseURL: server.URL, APIKey: "key"},
+		}
+		checked, err := service.PollTracking(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, checked)
+		mockOrderRepo.AssertExpectations(t)
+		mockOrderRepo.AssertNotCalled(t, "TransitionStatus", "order1", []string{models.OrderStatusShipped}, mock.Anything, mock.Anything)
+	})
+
+	t.Run("PollTracking - Nothing New Leaves The Order Alone", func(t *testing.T) {
+		server, _ := courierStub(t, `[{"status": "BOOKED", "occurred_at": "2026-10-01T09:00:00Z"}]`)
+		defer server.Close()
+		mockOrderRepo := new(MockOrderRepository)
+		order := packed()
+		order.Shipment = bookedShipment()
+		mockOrderRepo.On("ListTrackedShipments", shipping.ProviderHTTP, int64(100)).Return([]models.Order{*order}, nil)
+		mockOrderRepo.On("AddTrackingEvents", "order1", models.TrackingStatusBooked, []models.TrackingEvent{}, (*time.Time)(nil)).Return(nil)
+
+		service := &services.ShippingService{Orders: mockOrderRepo, Provider: &shipping.HTTPProvider{BaseURL: server.URL, APIKey: "key"}}
+		_, err := service.PollTracking(context.Background())
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertNotCalled(t, "TransitionStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("CancelShipment - Cancels A Booking Not Yet Collected", func(t *testing.T) {
+		server, _ := courierStub(t, `[]`)
+		defer server.Close()
+		mockOrderRepo := new(MockOrderRepository)
+		order := packed()
+		order.Shipment = bookedShipment()
+		collected := packed()
+		collected.ID = "order2"
+		collected.Shipment = bookedShipment()
+		collected.Shipment.Status = models.TrackingStatusPickedUp
+		mockOrderRepo.On("GetOrder", "order1").Return(order, nil)
+		mockOrderRepo.On("GetOrder", "order2").Return(collected, nil)
+		mockOrderRepo.On("SetShipment", "order1", mock.MatchedBy(func(shipment models.Shipment) bool {
+			return shipment.Status == models.TrackingStatusCancelled && len(shipment.Events) == 2
+		})).Return(nil)
+
+		service := &services.ShippingService{Orders: mockOrderRepo, Provider: &shipping.HTTPProvider{BaseURL: server.URL, APIKey: "key"}}
+		cancelled, err := service.CancelShipment(context.Background(), "order1")
+		assert.Nil(t, err)
+		assert.Equal(t, models.TrackingStatusCancelled, cancelled.Shipment.Status)
+
+		_, err = service.CancelShipment(context.Background(), "order2")
+		assert.Equal(t, services.ErrShipmentNotCancellable, err)
+	})
+
+	t.Run("ShipOrder - Keeps The Courier Booking", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		order := packed()
+		order.Status = models.OrderStatusShipped
+		order.Shipment = bookedShipment()
+		mockOrderRepo.On("TransitionStatus", "order1", []string{models.OrderStatusPacked}, mock.Anything, mock.Anything).Return(order, nil)
+		mockOrderRepo.On("SetShipment", "order1", mock.MatchedBy(func(shipment models.Shipment) bool {
+			return shipment.TrackingNumber == "AWB1" && shipment.ProviderShipmentID == "shp_1" && shipment.ShippedAt != nil
+		})).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+		_, err := service.ShipOrder(context.Background(), "order1", services.ShipOrderRequest{})
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertExpectations(t)
+	})
+}
+
+func TestShippingController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(service services.ShippingServiceInterface) *gin.Engine {
+		router := gin.New()
+		controller := &controllers.ShippingController{Service: service}
+		router.POST("/api/admin/orders/:order_id/shipment", controller.BookShipment)
+		router.GET("/api/admin/orders/:order_id/shipment/label", controller.GetLabel)
+		router.POST("/api/shipping/webhook", controller.HandleWebhook)
+		return router
+	}
+
+	t.Run("BookShipment - Empty Body", func(t *testing.T) {
+		mockService := new(MockShippingService)
+		mockService.On("BookShipment", "order1", services.BookShipmentRequest{}).
+			Return(&models.Order{ID: "order1", Shipment: &models.Shipment{TrackingNumber: "AWB1"}}, nil)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/orders/order1/shipment", nil)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Contains(t, rr.Body.String(), "AWB1")
+	})
+
+	t.Run("BookShipment - Courier Failure", func(t *testing.T) {
+		mockService := new(MockShippingService)
+		mockService.On("BookShipment", "order1", services.BookShipmentRequest{WeightGrams: 500}).
+			Return(nil, services.ErrCourierFailed)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/orders/order1/shipment", strings.NewReader(`{"weight_grams": 500}`))
+		req.Header.Set("Content-Type", "application/json")
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadGateway, rr.Code)
+	})
+
+	t.Run("GetLabel - Unsupported", func(t *testing.T) {
+		mockService := new(MockShippingService)
+		mockService.On("GetLabel", "order1").Return("", services.ErrShippingUnsupported)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/orders/order1/shipment/label", nil)
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	t.Run("HandleWebhook - Invalid Signature", func(t *testing.T) {
+		mockService := new(MockShippingService)
+		mockService.On("HandleWebhook", []byte(`{}`), "bad").Return(services.ErrInvalidWebhookSignature)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/shipping/webhook", strings.NewReader(`{}`))
+		req.Header.Set("X-Shipping-Signature", "bad")
+		newRouter(mockService).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
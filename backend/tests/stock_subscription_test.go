@@ -0,0 +1,270 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/jobs"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockStockSubscriptionRepository struct {
+	mock.Mock
+}
+
+func (m *MockStockSubscriptionRepository) Subscribe(ctx context.Context, subscription models.StockSubscription) (*models.StockSubscription, error) {
+	args := m.Called(subscription)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.StockSubscription), args.Error(1)
+}
+
+func (m *MockStockSubscriptionRepository) ListSubscribers(ctx context.Context, productID string, limit int64) ([]models.StockSubscription, error) {
+	args := m.Called(productID, limit)
+	return args.Get(0).([]models.StockSubscription), args.Error(1)
+}
+
+func (m *MockStockSubscriptionRepository) ClaimSubscription(ctx context.Context, id string) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockStockSubscriptionService struct {
+	mock.Mock
+}
+
+func (m *MockStockSubscriptionService) Subscribe(ctx context.Context, productID string, request services.NotifyMeRequest) (*models.StockSubscription, error) {
+	args := m.Called(productID, request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.StockSubscription), args.Error(1)
+}
+
+type MockRestockAlerter struct {
+	mock.Mock
+}
+
+func (m *MockRestockAlerter) AlertRestocked(ctx context.Context, productID string, limit int) (int, bool, error) {
+	args := m.Called(productID, limit)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
+
+func TestStockSubscriptionService(t *testing.T) {
+	soldOut := &models.Product{ID: "prod1", Name: "Diwali Blend", Price: 350.0, InStock: false, Stock: 0}
+	restocked := &models.Product{ID: "prod1", Name: "Diwali Blend", Price: 350.0, InStock: true, Stock: 10}
+
+	t.Run("Subscribe - Normalises Email", func(t *testing.T) {
+		mockSubscriptions := new(MockStockSubscriptionRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "prod1").Return(soldOut, nil)
+		mockSubscriptions.On("Subscribe", mock.MatchedBy(func(subscription models.StockSubscription) bool {
+			return subscription.ProductID == "prod1" && subscription.Email == "asha@example.com" && subscription.Phone == ""
+		})).Return(&models.StockSubscription{ID: "bis_1", ProductID: "prod1", Email: "asha@example.com"}, nil)
+
+		service := &services.StockSubscriptionService{Repository: mockSubscriptions, Products: mockProductRepo}
+		subscription, err := service.Subscribe(context.Background(), "prod1", services.NotifyMeRequest{Email: " Asha@Example.com "})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "bis_1", subscription.ID)
+		mockSubscriptions.AssertExpectations(t)
+	})
+
+	t.Run("Subscribe - Product In Stock", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "prod1").Return(restocked, nil)
+
+		service := &services.StockSubscriptionService{Repository: new(MockStockSubscriptionRepository), Products: mockProductRepo}
+		_, err := service.Subscribe(context.Background(), "prod1", services.NotifyMeRequest{Email: "asha@example.com"})
+
+		assert.True(t, errors.Is(err, services.ErrProductInStock))
+	})
+
+	t.Run("Subscribe - Needs Contact", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "prod1").Return(soldOut, nil)
+
+		service := &services.StockSubscriptionService{Repository: new(MockStockSubscriptionRepository), Products: mockProductRepo}
+		_, err := service.Subscribe(context.Background(), "prod1", services.NotifyMeRequest{})
+		assert.True(t, errors.Is(err, services.ErrInvalidSubscription))
+
+		_, err = service.Subscribe(context.Background(), "prod1", services.NotifyMeRequest{Email: "not-an-email"})
+		assert.True(t, errors.Is(err, services.ErrInvalidSubscription))
+	})
+
+	t.Run("Subscribe - Phone Must Be Opted In", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepository)
+		mockPreferences := new(MockPreferenceRepository)
+		mockProductRepo.On("GetProduct", "prod1").Return(soldOut, nil)
+		mockPreferences.On("GetPreference", "+919876543210").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.StockSubscriptionService{Repository: new(MockStockSubscriptionRepository), Products: mockProductRepo, Preferences: mockPreferences}
+		_, err := service.Subscribe(context.Background(), "prod1", services.NotifyMeRequest{Phone: "98765 43210"})
+
+		assert.True(t, errors.Is(err, services.ErrPhoneNotSubscribable))
+	})
+
+	t.Run("AlertRestocked - Alerts Oldest First And Clears Subscriptions", func(t *testing.T) {
+		mockSubscriptions := new(MockStockSubscriptionRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockNotifier := new(MockBackInStockNotifier)
+		mockProductRepo.On("GetProduct", "prod1").Return(restocked, nil)
+		mockSubscriptions.On("ListSubscribers", "prod1", int64(3)).Return([]models.StockSubscription{
+			{ID: "bis_1", ProductID: "prod1", Email: "first@example.com"},
+			{ID: "bis_2", ProductID: "prod1", Phone: "+919876543210"},
+			{ID: "bis_3", ProductID: "prod1", Email: "third@example.com"},
+		}, nil)
+		var order []string
+		mockNotifier.On("NotifyBackInStock", mock.Anything, *restocked, "https://shop.example.com/?product=prod1").Run(func(args mock.Arguments) {
+			contact := args.Get(0).(models.CustomerInfo)
+			order = append(order, contact.Email+contact.Phone)
+		}).Return(true, nil)
+		mockSubscriptions.On("ClaimSubscription", "bis_1").Return(true, nil)
+		mockSubscriptions.On("ClaimSubscription", "bis_2").Return(true, nil)
+
+		service := &services.StockSubscriptionService{
+			Repository: mockSubscriptions,
+			Products:   mockProductRepo,
+			Notifiers:  []services.BackInStockNotifier{mockNotifier},
+			ShopURL:    "https://shop.example.com",
+		}
+		sent, more, err := service.AlertRestocked(context.Background(), "prod1", 2)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, sent)
+		assert.True(t, more)
+		assert.Equal(t, []string{"first@example.com", "+919876543210"}, order)
+		mockSubscriptions.AssertExpectations(t)
+		mockSubscriptions.AssertNotCalled(t, "ClaimSubscription", "bis_3")
+	})
+
+	t.Run("AlertRestocked - Skips Subscribers Another Job Claimed", func(t *testing.T) {
+		mockSubscriptions := new(MockStockSubscriptionRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockNotifier := new(MockBackInStockNotifier)
+		mockProductRepo.On("GetProduct", "prod1").Return(restocked, nil)
+		mockSubscriptions.On("ListSubscribers", "prod1", int64(51)).Return([]models.StockSubscription{
+			{ID: "bis_1", ProductID: "prod1", Email: "first@example.com"},
+			{ID: "bis_2", ProductID: "prod1", Email: "second@example.com"},
+		}, nil)
+		mockSubscriptions.On("ClaimSubscription", "bis_1").Return(false, nil)
+		mockSubscriptions.On("ClaimSubscription", "bis_2").Return(true, nil)
+		mockNotifier.On("NotifyBackInStock", mock.Anything, *restocked, mock.Anything).Return(true, nil)
+
+		service := &services.StockSubscriptionService{Repository: mockSubscriptions, Products: mockProductRepo, Notifiers: []services.BackInStockNotifier{mockNotifier}}
+		sent, _, err := service.AlertRestocked(context.Background(), "prod1", 50)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, sent)
+		mockNotifier.AssertNumberOfCalls(t, "NotifyBackInStock", 1)
+		mockNotifier.AssertCalled(t, "NotifyBackInStock", models.CustomerInfo{Email: "second@example.com"}, *restocked, mock.Anything)
+	})
+
+	t.Run("AlertRestocked - Keeps Subscriptions When Sold Out Again", func(t *testing.T) {
+		mockSubscriptions := new(MockStockSubscriptionRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "prod1").Return(soldOut, nil)
+
+		service := &services.StockSubscriptionService{Repository: mockSubscriptions, Products: mockProductRepo}
+		sent, more, err := service.AlertRestocked(context.Background(), "prod1", 50)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, sent)
+		assert.False(t, more)
+		mockSubscriptions.AssertNotCalled(t, "ListSubscribers", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRestockHandler(t *testing.T) {
+	job := models.Job{ID: "job_1", Type: models.JobTypeProductRestocked, Payload: map[string]string{"product_id": "prod1"}}
+
+	t.Run("Queues Next Batch While Alerts Remain", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		wishlist := new(MockRestockAlerter)
+		subscriptions := new(MockRestockAlerter)
+		wishlist.On("AlertRestocked", "prod1", 50).Return(3, false, nil)
+		subscriptions.On("AlertRestocked", "prod1", 50).Return(50, true, nil)
+		mockJobs.On("Enqueue", mock.MatchedBy(func(request models.JobRequest) bool {
+			return request.Type == models.JobTypeProductRestocked && request.Payload["product_id"] == "prod1" &&
+				request.RunAt.After(time.Now().Add(50*time.Second))
+		})).Return(nil)
+
+		err := jobs.RestockHandler(mockJobs, wishlist, subscriptions)(context.Background(), job)
+
+		assert.Nil(t, err)
+		mockJobs.AssertExpectations(t)
+	})
+
+	t.Run("Done When All Alerts Sent", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		alerter := new(MockRestockAlerter)
+		alerter.On("AlertRestocked", "prod1", 50).Return(7, false, nil)
+
+		err := jobs.RestockHandler(mockJobs, alerter)(context.Background(), job)
+
+		assert.Nil(t, err)
+		mockJobs.AssertNotCalled(t, "Enqueue", mock.Anything)
+	})
+
+	t.Run("Error Retries Job", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		alerter := new(MockRestockAlerter)
+		alerter.On("AlertRestocked", "prod1", 50).Return(0, false, errors.New("db down"))
+
+		err := jobs.RestockHandler(mockJobs, alerter)(context.Background(), job)
+
+		assert.NotNil(t, err)
+		mockJobs.AssertNotCalled(t, "Enqueue", mock.Anything)
+	})
+}
+
+func TestStockSubscriptionController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"NotifyMe - Created", nil, http.StatusCreated},
+		{"NotifyMe - Product Not Found", services.ErrProductNotFound, http.StatusNotFound},
+		{"NotifyMe - In Stock", services.ErrProductInStock, http.StatusConflict},
+		{"NotifyMe - Phone Not Opted In", services.ErrPhoneNotSubscribable, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockStockSubscriptionService)
+			var subscription *models.StockSubscription
+			if tc.err == nil {
+				subscription = &models.StockSubscription{ID: "bis_1"}
+			}
+			mockService.On("Subscribe", "prod1", services.NotifyMeRequest{Phone: "+919876543210"}).Return(subscription, tc.err)
+
+			router := gin.New()
+			controller := &controllers.StockSubscriptionController{Service: mockService}
+			router.POST("/api/products/:product_id/notify-me", controller.NotifyMe)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/api/products/prod1/notify-me", bytes.NewBufferString(`{"phone": "+919876543210"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.code, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,829 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FakeSubscriptionGateway is an in-memory SubscriptionGateway that keeps what was asked of it.
+type FakeSubscriptionGateway struct {
+	Plans         map[string]int64  // gateway plan ID to amount
+	Subscriptions map[string]string // gateway subscription ID to status
+	Refunds       map[string]int64  // payment ID to amount refunded
+	Err           error             // returned by every call when set
+}
+
+func NewFakeSubscriptionGateway() *FakeSubscriptionGateway {
+	return &FakeSubscriptionGateway{Plans: map[string]int64{}, Subscriptions: map[string]string{}, Refunds: map[string]int64{}}
+}
+
+func (g *FakeSubscriptionGateway) CreatePlan(ctx context.Context, name string, frequency string, amount int64) (string, error) {
+	if g.Err != nil {
+		return "", g.Err
+	}
+	id := "gw_plan_" + frequency
+	g.Plans[id] = amount
+	return id, nil
+}
+
+func (g *FakeSubscriptionGateway) CreateSubscription(ctx context.Context, gatewayPlanID string, frequency string, notes map[string]string) (*services.GatewaySubscription, error) {
+	if g.Err != nil {
+		return nil, g.Err
+	}
+	id := "gw_sub_" + notes["subscription_id"]
+	g.Subscriptions[id] = "created"
+	return &services.GatewaySubscription{ID: id, Status: "created", ShortURL: "https://pay.example.com/" + id}, nil
+}
+
+func (g *FakeSubscriptionGateway) PauseSubscription(ctx context.Context, gatewaySubscriptionID string) error {
+	return g.setStatus(gatewaySubscriptionID, "paused")
+}
+
+func (g *FakeSubscriptionGateway) ResumeSubscription(ctx context.Context, gatewaySubscriptionID string) error {
+	return g.setStatus(gatewaySubscriptionID, "active")
+}
+
+func (g *FakeSubscriptionGateway) CancelSubscription(ctx context.Context, gatewaySubscriptionID string) error {
+	return g.setStatus(gatewaySubscriptionID, "cancelled")
+}
+
+func (g *FakeSubscriptionGateway) Refund(ctx context.Context, paymentID string, amount int64, notes map[string]string) (*services.GatewayRefund, error) {
+	if g.Err != nil {
+		return nil, g.Err
+	}
+	g.Refunds[paymentID] += amount
+	return &services.GatewayRefund{ID: "rfnd_" + paymentID, Status: "processed", Amount: amount}, nil
+}
+
+func (g *FakeSubscriptionGateway) setStatus(id string, status string) error {
+	if g.Err != nil {
+		return g.Err
+	}
+	g.Subscriptions[id] = status
+	return nil
+}
+
+type MockSubscriptionPlanRepository struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionPlanRepository) CreatePlan(ctx context.Context, plan models.SubscriptionPlan) error {
+	args := m.Called(plan)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionPlanRepository) GetPlan(ctx context.Context, id string) (*models.SubscriptionPlan, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.SubscriptionPlan), args.Error(1)
+}
+
+func (m *MockSubscriptionPlanRepository) ListPlans(ctx context.Context, activeOnly bool) ([]models.SubscriptionPlan, error) {
+	args := m.Called(activeOnly)
+	return args.Get(0).([]models.SubscriptionPlan), args.Error(1)
+}
+
+type MockSubscriptionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionRepository) CreateSubscription(ctx context.Context, subscription models.Subscription) error {
+	args := m.Called(subscription)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepository) GetSubscription(ctx context.Context, id string) (*models.Subscription, error) {
+	args := m.Called(id)
+	return subscriptionResult(args)
+}
+
+func (m *MockSubscriptionRepository) GetByGatewayID(ctx context.Context, gatewaySubscriptionID string) (*models.Subscription, error) {
+	args := m.Called(gatewaySubscriptionID)
+	return subscriptionResult(args)
+}
+
+func (m *MockSubscriptionRepository) ListSubscriptions(ctx context.Context, customerID string) ([]models.Subscription, error) {
+	args := m.Called(customerID)
+	return args.Get(0).([]models.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) TransitionStatus(ctx context.Context, id string, from []string, change models.StatusChange, nextOrderAt *time.Time) (*models.Subscription, error) {
+	args := m.Called(id, from, change, nextOrderAt)
+	return subscriptionResult(args)
+}
+
+func (m *MockSubscriptionRepository) ListDue(ctx context.Context, before time.Time, limit int64) ([]models.Subscription, error) {
+	args := m.Called(before, limit)
+	return args.Get(0).([]models.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) AdvanceCycle(ctx context.Context, id string, dueAt time.Time, next time.Time) error {
+	args := m.Called(id, dueAt, next)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepository) SkipCycle(ctx context.Context, id string, dueAt time.Time, next time.Time, change models.StatusChange) (*models.Subscription, error) {
+	args := m.Called(id, dueAt, next, change)
+	return subscriptionResult(args)
+}
+
+func (m *MockSubscriptionRepository) RecordSkip(ctx context.Context, id string, change models.StatusChange) error {
+	args := m.Called(id, change)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepository) ClaimCharge(ctx context.Context, id string, paymentID string) error {
+	args := m.Called(id, paymentID)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepository) ReleaseCharge(ctx context.Context, id string, paymentID string) error {
+	args := m.Called(id, paymentID)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepository) TakeSkippedCharge(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepository) ReturnSkippedCharge(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepository) AddCredit(ctx context.Context, id string, payment models.SubscriptionPayment) error {
+	args := m.Called(id, payment)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepository) TakeCredit(ctx context.Context, id string) (*models.SubscriptionPayment, error) {
+	args := m.Called(id)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.SubscriptionPayment), args.Error(1)
+}
+
+func (m *MockSubscriptionRepository) ClearCredits(ctx context.Context, id string) ([]models.SubscriptionPayment, error) {
+	args := m.Called(id)
+	return args.Get(0).([]models.SubscriptionPayment), args.Error(1)
+}
+
+func subscriptionResult(args mock.Arguments) (*models.Subscription, error) {
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.Subscription), args.Error(1)
+}
+
+type MockPaymentConfirmer struct {
+	mock.Mock
+}
+
+func (m *MockPaymentConfirmer) ConfirmPayment(ctx context.Context, order *models.Order) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+type MockSubscriptionOrderExpirer struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionOrderExpirer) ExpireSubscriptionOrders(ctx context.Context, subscriptionID string, reason string) (int, error) {
+	args := m.Called(subscriptionID, reason)
+	return args.Int(0), args.Error(1)
+}
+
+type MockSubscriptionEventHandler struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionEventHandler) HandleSubscriptionEvent(ctx context.Context, event string, gatewaySubscriptionID string, payment services.GatewayPayment) error {
+	args := m.Called(event, gatewaySubscriptionID, payment)
+	return args.Error(0)
+}
+
+type MockSubscriptionService struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionService) CreatePlan(ctx context.Context, request services.PlanRequest) (*models.SubscriptionPlan, error) {
+	args := m.Called(request)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*models.SubscriptionPlan), args.Error(1)
+}
+
+func (m *MockSubscriptionService) ListPlans(ctx context.Context) ([]models.SubscriptionPlan, error) {
+	args := m.Called()
+	return args.Get(0).([]models.SubscriptionPlan), args.Error(1)
+}
+
+func (m *MockSubscriptionService) Subscribe(ctx context.Context, customerID string, request services.SubscribeRequest) (*models.Subscription, error) {
+	args := m.Called(customerID, request)
+	return subscriptionResult(args)
+}
+
+func (m *MockSubscriptionService) ListSubscriptions(ctx context.Context, customerID string) ([]models.Subscription, error) {
+	args := m.Called(customerID)
+	return args.Get(0).([]models.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionService) PauseSubscription(ctx context.Context, customerID string, id string) (*models.Subscription, error) {
+	args := m.Called(customerID, id)
+	return subscriptionResult(args)
+}
+
+func (m *MockSubscriptionService) ResumeSubscription(ctx context.Context, customerID string, id string) (*models.Subscription, error) {
+	args := m.Called(customerID, id)
+	return subscriptionResult(args)
+}
+
+func (m *MockSubscriptionService) SkipCycle(ctx context.Context, customerID string, id string) (*models.Subscription, error) {
+	args := m.Called(customerID, id)
+	return subscriptionResult(args)
+}
+
+func (m *MockSubscriptionService) CancelSubscription(ctx context.Context, customerID string, id string, reason string) (*models.Subscription, error) {
+	args := m.Called(customerID, id, reason)
+	return subscriptionResult(args)
+}
+
+func TestSubscriptionService(t *testing.T) {
+	plan := &models.SubscriptionPlan{
+		ID:            "plan_1",
+		Name:          "Monthly Masala Chai",
+		Items:         []models.CartItem{{ProductID: "prod1", Quantity: 2, Price: 250.0}},
+		Frequency:     models.FrequencyMonthly,
+		Amount:        500.0,
+		GatewayPlanID: "gw_plan_monthly",
+		Active:        true,
+	}
+	info := models.CustomerInfo{Name: "Asha", Phone: "+919876543210", Address: "12 MG Road, Pune"}
+	active := func(nextOrderAt time.Time) *models.Subscription {
+		return &models.Subscription{
+			ID:                    "sub_1",
+			PlanName:              plan.Name,
+			CustomerID:            "cus_1",
+			CustomerInfo:          info,
+			Items:                 plan.Items,
+			Frequency:             models.FrequencyWeekly,
+			Status:                models.SubscriptionStatusActive,
+			NextOrderAt:           &nextOrderAt,
+			GatewaySubscriptionID: "gw_sub_1",
+		}
+	}
+
+	t.Run("CreatePlan - Prices Items From Catalog", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepository)
+		mockPlans := new(MockSubscriptionPlanRepository)
+		gateway := NewFakeSubscriptionGateway()
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", Price: 250.0}, nil)
+		mockProductRepo.On("GetProduct", "prod2").Return(&models.Product{ID: "prod2", Price: 99.5}, nil)
+		mockPlans.On("CreatePlan", mock.MatchedBy(func(plan models.SubscriptionPlan) bool {
+			return plan.Amount == 599.5 && plan.GatewayPlanID == "gw_plan_biweekly" && plan.Active && plan.Items[1].Price == 99.5
+		})).Return(nil)
+
+		service := &services.SubscriptionService{Plans: mockPlans, Products: mockProductRepo, Gateway: gateway}
+		created, err := service.CreatePlan(context.Background(), services.PlanRequest{
+			Name:      "Chai Lover",
+			Items:     []models.CartItem{{ProductID: "prod1", Quantity: 2}, {ProductID: "prod2", Quantity: 1}},
+			Frequency: models.FrequencyBiweekly,
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 599.5, created.Amount)
+		assert.Equal(t, int64(59950), gateway.Plans["gw_plan_biweekly"])
+		mockPlans.AssertExpectations(t)
+	})
+
+	t.Run("CreatePlan - Unknown Frequency", func(t *testing.T) {
+		service := &services.SubscriptionService{Plans: new(MockSubscriptionPlanRepository), Gateway: NewFakeSubscriptionGateway()}
+		_, err := service.CreatePlan(context.Background(), services.PlanRequest{Name: "Daily", Items: []models.CartItem{{ProductID: "prod1", Quantity: 1}}, Frequency: "daily"})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidPlan))
+	})
+
+	t.Run("Subscribe - Pending Until Payment Is Authorised", func(t *testing.T) {
+		mockPlans := new(MockSubscriptionPlanRepository)
+		mockRepo := new(MockSubscriptionRepository)
+		gateway := NewFakeSubscriptionGateway()
+		mockPlans.On("GetPlan", "plan_1").Return(plan, nil)
+		mockRepo.On("CreateSubscription", mock.MatchedBy(func(subscription models.Subscription) bool {
+			return subscription.CustomerID == "cus_1" && subscription.Status == models.SubscriptionStatusPending &&
+				subscription.NextOrderAt == nil && subscription.Amount == 500.0 && len(subscription.Items) == 1
+		})).Return(nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Plans: mockPlans, Gateway: gateway}
+		subscription, err := service.Subscribe(context.Background(), "cus_1", services.SubscribeRequest{PlanID: "plan_1", CustomerInfo: info})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "created", gateway.Subscriptions[subscription.GatewaySubscriptionID])
+		assert.Equal(t, "https://pay.example.com/"+subscription.GatewaySubscriptionID, subscription.PaymentURL)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Subscribe - Needs Delivery Address", func(t *testing.T) {
+		mockPlans := new(MockSubscriptionPlanRepository)
+		mockPlans.On("GetPlan", "plan_1").Return(plan, nil)
+
+		service := &services.SubscriptionService{Repository: new(MockSubscriptionRepository), Plans: mockPlans, Gateway: NewFakeSubscriptionGateway()}
+		_, err := service.Subscribe(context.Background(), "cus_1", services.SubscribeRequest{PlanID: "plan_1", CustomerInfo: models.CustomerInfo{Name: "Asha", Phone: "+919876543210"}})
+
+		assert.True(t, errors.Is(err, services.ErrInvalidSubscriber))
+	})
+
+	t.Run("PauseSubscription - Pauses Gateway Charges", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		gateway := NewFakeSubscriptionGateway()
+		mockRepo.On("GetSubscription", "sub_1").Return(active(time.Now().Add(48*time.Hour)), nil)
+		mockRepo.On("TransitionStatus", "sub_1", []string{models.SubscriptionStatusActive}, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.SubscriptionStatusPaused
+		}), (*time.Time)(nil), mock.Anything).Return(&models.Subscription{ID: "sub_1", Status: models.SubscriptionStatusPaused}, nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Gateway: gateway}
+		paused, err := service.PauseSubscription(context.Background(), "cus_1", "sub_1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.SubscriptionStatusPaused, paused.Status)
+		assert.Equal(t, "paused", gateway.Subscriptions["gw_sub_1"])
+	})
+
+	t.Run("PauseSubscription - Other Customer's Subscription", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		gateway := NewFakeSubscriptionGateway()
+		mockRepo.On("GetSubscription", "sub_1").Return(active(time.Now()), nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Gateway: gateway}
+		_, err := service.PauseSubscription(context.Background(), "cus_2", "sub_1")
+
+		assert.Equal(t, services.ErrSubscriptionNotFound, err)
+		assert.Empty(t, gateway.Subscriptions)
+	})
+
+	t.Run("ResumeSubscription - Keeps The Schedule", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		gateway := NewFakeSubscriptionGateway()
+		missed := time.Now().Add(-10 * 24 * time.Hour)
+		paused := active(missed)
+		paused.Status = models.SubscriptionStatusPaused
+		mockRepo.On("GetSubscription", "sub_1").Return(paused, nil)
+		mockRepo.On("TransitionStatus", "sub_1", []string{models.SubscriptionStatusPaused}, mock.Anything, mock.MatchedBy(func(next *time.Time) bool {
+			// Weekly from 10 days ago: the cycle 3 days ago has passed, so the next is in 4 days.
+			return next != nil && next.Equal(missed.AddDate(0, 0, 14))
+		}), mock.Anything).Return(&models.Subscription{ID: "sub_1", Status: models.SubscriptionStatusActive}, nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Gateway: gateway}
+		_, err := service.ResumeSubscription(context.Background(), "cus_1", "sub_1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "active", gateway.Subscriptions["gw_sub_1"])
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("SkipCycle - Moves To The Next Cycle", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		due := time.Now().Add(72 * time.Hour)
+		mockRepo.On("GetSubscription", "sub_1").Return(active(due), nil)
+		mockRepo.On("SkipCycle", "sub_1", due, due.AddDate(0, 0, 7), mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.SubscriptionCycleSkipped
+		})).Return(active(due.AddDate(0, 0, 7)), nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo}
+		skipped, err := service.SkipCycle(context.Background(), "cus_1", "sub_1")
+
+		assert.Nil(t, err)
+		assert.True(t, skipped.NextOrderAt.Equal(due.AddDate(0, 0, 7)))
+	})
+
+	t.Run("SkipCycle - Paused Subscription", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		paused := active(time.Now())
+		paused.Status = models.SubscriptionStatusPaused
+		mockRepo.On("GetSubscription", "sub_1").Return(paused, nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo}
+		_, err := service.SkipCycle(context.Background(), "cus_1", "sub_1")
+
+		assert.True(t, errors.Is(err, services.ErrSubscriptionNotActive))
+		mockRepo.AssertNotCalled(t, "SkipCycle", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("CancelSubscription - Refunds Unused Charges", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		gateway := NewFakeSubscriptionGateway()
+		mockRepo.On("GetSubscription", "sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("TransitionStatus", "sub_1", mock.Anything, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.SubscriptionStatusCancelled && change.Reason == "moving abroad"
+		}), (*time.Time)(nil), mock.Anything).Return(&models.Subscription{ID: "sub_1", Status: models.SubscriptionStatusCancelled}, nil)
+		mockRepo.On("ClearCredits", "sub_1").Return([]models.SubscriptionPayment{{PaymentID: "pay_9", Amount: 50000}}, nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Gateway: gateway}
+		_, err := service.CancelSubscription(context.Background(), "cus_1", "sub_1", "moving abroad")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "cancelled", gateway.Subscriptions["gw_sub_1"])
+		assert.Equal(t, int64(50000), gateway.Refunds["pay_9"])
+	})
+
+	t.Run("PlaceDueOrders - Places The Cycle's Order", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrders := new(MockOrderService)
+		due := time.Now().Add(-time.Hour)
+		mockRepo.On("ListDue", mock.Anything, int64(100)).Return([]models.Subscription{*active(due)}, nil)
+		mockRepo.On("AdvanceCycle", "sub_1", due, due.AddDate(0, 0, 7)).Return(nil)
+		mockOrders.On("CreateOrder", mock.MatchedBy(func(request services.CreateOrderRequest) bool {
+			return request.SubscriptionID == "sub_1" && request.CustomerID == "cus_1" && request.Items[0].Price == 250.0 &&
+				request.CustomerInfo.Address == info.Address
+		})).Return(&models.Order{ID: "ord_1", SubscriptionID: "sub_1"}, nil)
+		mockRepo.On("TakeCredit", "sub_1").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Orders: mockOrders}
+		placed, err := service.PlaceDueOrders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, placed)
+		mockOrders.AssertExpectations(t)
+	})
+
+	t.Run("PlaceDueOrders - Pays With A Charge That Came First", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrders := new(MockOrderService)
+		mockOrderRepo := new(MockOrderRepository)
+		mockPayments := new(MockPaymentConfirmer)
+		due := time.Now().Add(-time.Minute)
+		order := &models.Order{ID: "ord_1", SubscriptionID: "sub_1", Status: models.OrderStatusPending}
+		mockRepo.On("ListDue", mock.Anything, int64(100)).Return([]models.Subscription{*active(due)}, nil)
+		mockRepo.On("AdvanceCycle", "sub_1", due, due.AddDate(0, 0, 7)).Return(nil)
+		mockOrders.On("CreateOrder", mock.Anything).Return(order, nil)
+		mockRepo.On("TakeCredit", "sub_1").Return(&models.SubscriptionPayment{PaymentID: "pay_1", Method: "upi", Amount: 50000}, nil)
+		mockOrderRepo.On("RecordSubscriptionPayment", "sub_1", "pay_1", "upi").Return(order, nil)
+		mockPayments.On("ConfirmPayment", order).Return(nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Orders: mockOrders, OrderRepository: mockOrderRepo, Payments: mockPayments}
+		placed, err := service.PlaceDueOrders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, placed)
+		mockPayments.AssertExpectations(t)
+	})
+
+	t.Run("PlaceDueOrders - Out Of Stock Skips The Cycle", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrders := new(MockOrderService)
+		due := time.Now().Add(-time.Hour)
+		mockRepo.On("ListDue", mock.Anything, int64(100)).Return([]models.Subscription{*active(due)}, nil)
+		mockRepo.On("AdvanceCycle", "sub_1", due, due.AddDate(0, 0, 7)).Return(nil)
+		mockOrders.On("CreateOrder", mock.Anything).Return(nil, errors.New("product Masala Chai is out of stock"))
+		mockRepo.On("RecordSkip", "sub_1", mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.SubscriptionCycleSkipped
+		})).Return(nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Orders: mockOrders}
+		placed, err := service.PlaceDueOrders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, placed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("PlaceDueOrders - Cycle Already Claimed", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrders := new(MockOrderService)
+		due := time.Now().Add(-time.Hour)
+		mockRepo.On("ListDue", mock.Anything, int64(100)).Return([]models.Subscription{*active(due)}, nil)
+		mockRepo.On("AdvanceCycle", "sub_1", due, due.AddDate(0, 0, 7)).Return(mongo.ErrNoDocuments)
+
+		service := &services.SubscriptionService{Repository: mockRepo, Orders: mockOrders}
+		placed, err := service.PlaceDueOrders(context.Background())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, placed)
+		mockOrders.AssertNotCalled(t, "CreateOrder", mock.Anything)
+	})
+
+	t.Run("HandleSubscriptionEvent - Activation Schedules The First Order", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		pending := &models.Subscription{ID: "sub_1", Status: models.SubscriptionStatusPending, Frequency: models.FrequencyMonthly}
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(pending, nil)
+		mockRepo.On("TransitionStatus", "sub_1", []string{models.SubscriptionStatusPending, models.SubscriptionStatusHalted}, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.SubscriptionStatusActive
+		}), mock.MatchedBy(func(next *time.Time) bool {
+			return next != nil && time.Since(*next) < time.Minute
+		}), mock.Anything).Return(pending, nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.activated", "gw_sub_1", services.GatewayPayment{})
+
+		assert.Nil(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("HandleSubscriptionEvent - Charge Pays The Oldest Order", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		mockPayments := new(MockPaymentConfirmer)
+		order := &models.Order{ID: "ord_1", Status: models.OrderStatusPending}
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("ClaimCharge", "sub_1", "pay_1").Return(nil)
+		mockOrderRepo.On("RecordSubscriptionPayment", "sub_1", "pay_1", "card").Return(order, nil)
+		mockPayments.On("ConfirmPayment", order).Return(nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, OrderRepository: mockOrderRepo, Payments: mockPayments}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.charged", "gw_sub_1", services.GatewayPayment{ID: "pay_1", Method: "card", Amount: 50000})
+
+		assert.Nil(t, err)
+		mockPayments.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "AddCredit", mock.Anything, mock.Anything)
+	})
+
+	t.Run("HandleSubscriptionEvent - Charge For Skipped Cycle Is Refunded", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		gateway := NewFakeSubscriptionGateway()
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("ClaimCharge", "sub_1", "pay_2").Return(nil)
+		mockOrderRepo.On("RecordSubscriptionPayment", "sub_1", "pay_2", "card").Return(nil, mongo.ErrNoDocuments)
+		mockRepo.On("TakeSkippedCharge", "sub_1").Return(nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, OrderRepository: mockOrderRepo, Gateway: gateway}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.charged", "gw_sub_1", services.GatewayPayment{ID: "pay_2", Method: "card", Amount: 50000})
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(50000), gateway.Refunds["pay_2"])
+	})
+
+	t.Run("HandleSubscriptionEvent - Early Charge Is Kept For The Next Order", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		gateway := NewFakeSubscriptionGateway()
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("ClaimCharge", "sub_1", "pay_3").Return(nil)
+		mockOrderRepo.On("RecordSubscriptionPayment", "sub_1", "pay_3", "upi").Return(nil, mongo.ErrNoDocuments)
+		mockRepo.On("TakeSkippedCharge", "sub_1").Return(mongo.ErrNoDocuments)
+		mockRepo.On("AddCredit", "sub_1", mock.MatchedBy(func(payment models.SubscriptionPayment) bool {
+			return payment.PaymentID == "pay_3" && payment.Amount == 50000
+		})).Return(nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, OrderRepository: mockOrderRepo, Gateway: gateway}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.charged", "gw_sub_1", services.GatewayPayment{ID: "pay_3", Method: "upi", Amount: 50000})
+
+		assert.Nil(t, err)
+		assert.Empty(t, gateway.Refunds)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("HandleSubscriptionEvent - Replayed Charge Is Ignored", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("ClaimCharge", "sub_1", "pay_1").Return(mongo.ErrNoDocuments)
+
+		service := &services.SubscriptionService{Repository: mockRepo, OrderRepository: mockOrderRepo}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.charged", "gw_sub_1", services.GatewayPayment{ID: "pay_1", Amount: 50000})
+
+		assert.Nil(t, err)
+		mockOrderRepo.AssertNotCalled(t, "RecordSubscriptionPayment", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("HandleSubscriptionEvent - Charge That Could Not Be Kept Is Released For The Retry", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("ClaimCharge", "sub_1", "pay_3").Return(nil)
+		mockOrderRepo.On("RecordSubscriptionPayment", "sub_1", "pay_3", "upi").Return(nil, mongo.ErrNoDocuments)
+		mockRepo.On("TakeSkippedCharge", "sub_1").Return(mongo.ErrNoDocuments)
+		mockRepo.On("AddCredit", "sub_1", mock.Anything).Return(errors.New("connection reset"))
+		mockRepo.On("ReleaseCharge", "sub_1", "pay_3").Return(nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, OrderRepository: mockOrderRepo}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.charged", "gw_sub_1", services.GatewayPayment{ID: "pay_3", Method: "upi", Amount: 50000})
+
+		assert.NotNil(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("HandleSubscriptionEvent - Failed Refund Of Skipped Cycle Is Released For The Retry", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		gateway := NewFakeSubscriptionGateway()
+		gateway.Err = errors.New("gateway unavailable")
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("ClaimCharge", "sub_1", "pay_2").Return(nil)
+		mockOrderRepo.On("RecordSubscriptionPayment", "sub_1", "pay_2", "card").Return(nil, mongo.ErrNoDocuments)
+		mockRepo.On("TakeSkippedCharge", "sub_1").Return(nil)
+		mockRepo.On("ReturnSkippedCharge", "sub_1").Return(nil)
+		mockRepo.On("ReleaseCharge", "sub_1", "pay_2").Return(nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, OrderRepository: mockOrderRepo, Gateway: gateway}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.charged", "gw_sub_1", services.GatewayPayment{ID: "pay_2", Method: "card", Amount: 50000})
+
+		assert.NotNil(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("HandleSubscriptionEvent - Charge On A Paid Order Is Kept Claimed", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockOrderRepo := new(MockOrderRepository)
+		mockPayments := new(MockPaymentConfirmer)
+		order := &models.Order{ID: "ord_1", Status: models.OrderStatusPending}
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("ClaimCharge", "sub_1", "pay_1").Return(nil)
+		mockOrderRepo.On("RecordSubscriptionPayment", "sub_1", "pay_1", "card").Return(order, nil)
+		mockPayments.On("ConfirmPayment", order).Return(errors.New("connection reset"))
+
+		service := &services.SubscriptionService{Repository: mockRepo, OrderRepository: mockOrderRepo, Payments: mockPayments}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.charged", "gw_sub_1", services.GatewayPayment{ID: "pay_1", Method: "card", Amount: 50000})
+
+		assert.NotNil(t, err)
+		mockRepo.AssertNotCalled(t, "ReleaseCharge", mock.Anything, mock.Anything)
+	})
+
+	t.Run("HandleSubscriptionEvent - Halt Expires Unpaid Orders", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockExpirer := new(MockSubscriptionOrderExpirer)
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(active(time.Now()), nil)
+		mockRepo.On("TransitionStatus", "sub_1", mock.Anything, mock.MatchedBy(func(change models.StatusChange) bool {
+			return change.Status == models.SubscriptionStatusHalted
+		}), (*time.Time)(nil)).Return(&models.Subscription{ID: "sub_1", Status: models.SubscriptionStatusHalted}, nil)
+		mockExpirer.On("ExpireSubscriptionOrders", "sub_1", "subscription payment failed").Return(1, nil)
+
+		service := &services.SubscriptionService{Repository: mockRepo, UnpaidOrders: mockExpirer}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.halted", "gw_sub_1", services.GatewayPayment{})
+
+		assert.Nil(t, err)
+		mockExpirer.AssertExpectations(t)
+	})
+
+	t.Run("HandleSubscriptionEvent - Redelivered Halt Retries Expiring Orders", func(t *testing.T) {
+		mockRepo := new(MockSubscriptionRepository)
+		mockExpirer := new(MockSubscriptionOrderExpirer)
+		halted := active(time.Now())
+		halted.Status = models.SubscriptionStatusHalted
+		mockRepo.On("GetByGatewayID", "gw_sub_1").Return(halted, nil)
+		mockRepo.On("TransitionStatus", "sub_1", mock.Anything, mock.Anything, mock.Anything).Return(nil, mongo.ErrNoDocuments)
+		mockExpirer.On("ExpireSubscriptionOrders", "sub_1", mock.Anything).Return(0, errors.New("connection reset"))
+
+		service := &services.SubscriptionService{Repository: mockRepo, UnpaidOrders: mockExpirer}
+		err := service.HandleSubscriptionEvent(context.Background(), "subscription.halted", "gw_sub_1", services.GatewayPayment{})
+
+		assert.NotNil(t, err)
+		mockExpirer.AssertExpectations(t)
+	})
+}
+
+func TestSubscriptionOrders(t *testing.T) {
+	t.Run("CreateOrder - Subscription Orders Use Plan Prices And Keep The Cart", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockCarts := new(MockCartService)
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", Name: "Masala Chai", Price: 275.0, InStock: true, Stock: 10}, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 2).Return(nil)
+		mockOrderRepo.On("CreateOrder", mock.MatchedBy(func(order models.Order) bool {
+			return order.SubscriptionID == "sub_1" && order.TotalAmount == 500.0 && order.Items[0].Price == 250.0
+		})).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Carts: mockCarts}
+		order, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{
+			Items:          []models.CartItem{{ProductID: "prod1", Quantity: 2, Price: 250.0}},
+			CustomerID:     "cus_1",
+			SubscriptionID: "sub_1",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 500.0, order.TotalAmount)
+		mockCarts.AssertNotCalled(t, "CompleteCart", mock.Anything, mock.Anything)
+	})
+
+	t.Run("CreateOrder - Checkout Ignores Submitted Prices", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", Name: "Masala Chai", Price: 275.0, InStock: true, Stock: 10}, nil)
+		mockProductRepo.On("ReserveStock", "prod1", 1).Return(nil)
+		mockOrderRepo.On("CreateOrder", mock.Anything).Return(nil)
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo}
+		order, err := service.CreateOrder(context.Background(), services.CreateOrderRequest{Items: []models.CartItem{{ProductID: "prod1", Quantity: 1, Price: 1.0}}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 275.0, order.TotalAmount)
+	})
+
+	t.Run("HandleWebhook - Subscription Events Go To The Subscription Service", func(t *testing.T) {
+		body := []byte(`{"event": "subscription.charged", "payload": {"subscription": {"entity": {"id": "gw_sub_1"}}, "payment": {"entity": {"id": "pay_1", "method": "upi", "amount": 50000}}}}`)
+		mockGateway := new(MockPaymentGateway)
+		mockHandler := new(MockSubscriptionEventHandler)
+		mockGateway.On("VerifyWebhookSignature", body, "sig").Return(true)
+		mockHandler.On("HandleSubscriptionEvent", "subscription.charged", "gw_sub_1", services.GatewayPayment{
+			ID: "pay_1", Status: services.GatewayPaymentCaptured, Method: "upi", Amount: 50000,
+		}).Return(nil)
+
+		service := services.NewPaymentService(mockGateway, new(MockOrderRepository), nil)
+		service.Subscriptions = mockHandler
+		err := service.HandleWebhook(context.Background(), body, "sig")
+
+		assert.Nil(t, err)
+		mockHandler.AssertExpectations(t)
+	})
+}
+
+func TestSubscriptionController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := &services.CustomerTokens{Secret: []byte("test-secret")}
+
+	t.Run("Subscribe - Guest Is Unauthorized", func(t *testing.T) {
+		mockService := new(MockSubscriptionService)
+
+		router := gin.New()
+		controller := &controllers.SubscriptionController{Service: mockService}
+		router.POST("/api/subscriptions", middleware.CustomerAuth(tokens), controller.Subscribe)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/subscriptions", bytes.NewBufferString(`{"plan_id": "plan_1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockService.AssertNotCalled(t, "Subscribe", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Subscribe - Created", func(t *testing.T) {
+		mockService := new(MockSubscriptionService)
+		mockService.On("Subscribe", "cus_1", mock.MatchedBy(func(request services.SubscribeRequest) bool {
+			return request.PlanID == "plan_1" && request.CustomerInfo.Address == "12 MG Road"
+		})).Return(&models.Subscription{ID: "sub_1", Status: models.SubscriptionStatusPending}, nil)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		router := gin.New()
+		controller := &controllers.SubscriptionController{Service: mockService}
+		router.POST("/api/subscriptions", middleware.CustomerAuth(tokens), controller.Subscribe)
+
+		rr := httptest.NewRecorder()
+		body := `{"plan_id": "plan_1", "customer_info": {"name": "Asha", "phone": "+919876543210", "address": "12 MG Road"}}`
+		req, _ := http.NewRequest(http.MethodPost, "/api/subscriptions", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("SkipCycle - Not Active", func(t *testing.T) {
+		mockService := new(MockSubscriptionService)
+		mockService.On("SkipCycle", "cus_1", "sub_1").Return(nil, services.ErrSubscriptionNotActive)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		router := gin.New()
+		controller := &controllers.SubscriptionController{Service: mockService}
+		router.POST("/api/subscriptions/:subscription_id/skip", middleware.CustomerAuth(tokens), controller.SkipCycle)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/subscriptions/sub_1/skip", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("CancelSubscription - Reason Is Optional", func(t *testing.T) {
+		mockService := new(MockSubscriptionService)
+		mockService.On("CancelSubscription", "cus_1", "sub_1", "").Return(&models.Subscription{ID: "sub_1", Status: models.SubscriptionStatusCancelled}, nil)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		router := gin.New()
+		controller := &controllers.SubscriptionController{Service: mockService}
+		router.POST("/api/subscriptions/:subscription_id/cancel", middleware.CustomerAuth(tokens), controller.CancelSubscription)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/subscriptions/sub_1/cancel", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+}
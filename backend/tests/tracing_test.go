@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mangal-chai-backend/database"
+	"mangal-chai-backend/logging"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/services"
+	"mangal-chai-backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider that keeps ended spans in memory for the rest of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func spanNamed(recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Middleware - Continues The Storefront's Trace", func(t *testing.T) {
+		recorder := recordSpans(t)
+		var buf bytes.Buffer
+		mockOrderRepo := new(MockOrderRepository)
+		mockOrderRepo.On("GetOrder", "ord_1").Return(&models.Order{ID: "ord_1"}, nil)
+		service := &services.OrderService{OrderRepository: mockOrderRepo}
+
+		router := gin.New()
+		router.Use(otelgin.Middleware(tracing.ServiceName), middleware.RequestLogger(logging.New(&buf, slog.LevelInfo)))
+		router.GET("/api/orders/:order_id", func(ctx *gin.Context) {
+			service.GetOrder(ctx.Request.Context(), ctx.Param("order_id"), services.OrderAccess{Admin: true})
+			ctx.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/orders/ord_1", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		server := spanNamed(recorder, "/api/orders/:order_id")
+		serviceSpan := spanNamed(recorder, "OrderService.GetOrder")
+		assert.NotNil(t, server)
+		assert.NotNil(t, serviceSpan)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+		assert.Equal(t, server.SpanContext().SpanID(), serviceSpan.Parent().SpanID())
+
+		lines := logLines(t, &buf)
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", lines[0]["trace_id"])
+	})
+
+	t.Run("End - Records Errors", func(t *testing.T) {
+		recorder := recordSpans(t)
+
+		_, span := tracing.Start(context.Background(), "razorpay.CreateOrder")
+		tracing.End(span, errors.New("gateway timeout"))
+
+		ended := spanNamed(recorder, "razorpay.CreateOrder")
+		assert.Equal(t, codes.Error, ended.Status().Code)
+		assert.Equal(t, "gateway timeout", ended.Status().Description)
+		assert.Len(t, ended.Events(), 1)
+	})
+
+	t.Run("NewExporterFromEnv - Picks Exporter", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		console, err := tracing.NewExporterFromEnv(context.Background())
+		assert.Nil(t, err)
+		assert.Contains(t, typeName(console), "stdouttrace")
+
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+		otlp, err := tracing.NewExporterFromEnv(context.Background())
+		assert.Nil(t, err)
+		assert.Contains(t, typeName(otlp), "otlptrace")
+
+		t.Setenv("OTEL_TRACES_EXPORTER", "none")
+		none, err := tracing.NewExporterFromEnv(context.Background())
+		assert.Nil(t, err)
+		assert.Nil(t, none)
+
+		t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+		_, err = tracing.NewExporterFromEnv(context.Background())
+		assert.NotNil(t, err)
+	})
+
+	t.Run("CombineMonitors - Passes Events To Each Monitor", func(t *testing.T) {
+		var started, failed int
+		counting := &event.CommandMonitor{
+			Started: func(context.Context, *event.CommandStartedEvent) { started++ },
+			Failed:  func(context.Context, *event.CommandFailedEvent) { failed++ },
+		}
+		monitor := database.CombineMonitors(counting, counting)
+
+		monitor.Started(context.Background(), &event.CommandStartedEvent{})
+		monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{})
+		monitor.Failed(context.Background(), &event.CommandFailedEvent{})
+
+		assert.Equal(t, 2, started)
+		assert.Equal(t, 2, failed)
+	})
+}
+
+func typeName(value any) string {
+	return fmt.Sprintf("%T", value)
+}
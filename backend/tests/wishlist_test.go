@@ -0,0 +1,373 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mangal-chai-backend/controllers"
+	"mangal-chai-backend/jobs"
+	"mangal-chai-backend/messaging"
+	"mangal-chai-backend/middleware"
+	"mangal-chai-backend/models"
+	"mangal-chai-backend/notifications"
+	"mangal-chai-backend/repositories"
+	"mangal-chai-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MockWishlistRepository struct {
+	mock.Mock
+}
+
+func (m *MockWishlistRepository) SaveItem(ctx context.Context, item models.WishlistItem) error {
+	args := m.Called(item)
+	return args.Error(0)
+}
+
+func (m *MockWishlistRepository) RemoveItem(ctx context.Context, customerID string, productID string) error {
+	args := m.Called(customerID, productID)
+	return args.Error(0)
+}
+
+func (m *MockWishlistRepository) ListItems(ctx context.Context, customerID string) ([]models.WishlistItem, error) {
+	args := m.Called(customerID)
+	return args.Get(0).([]models.WishlistItem), args.Error(1)
+}
+
+func (m *MockWishlistRepository) ListRestockWatchers(ctx context.Context, productID string, limit int64) ([]models.WishlistItem, error) {
+	args := m.Called(productID, limit)
+	return args.Get(0).([]models.WishlistItem), args.Error(1)
+}
+
+func (m *MockWishlistRepository) ClaimRestockAlert(ctx context.Context, customerID string, productID string, at time.Time) (bool, error) {
+	args := m.Called(customerID, productID, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWishlistRepository) MostWishlisted(ctx context.Context, limit int64) ([]repositories.WishlistCount, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]repositories.WishlistCount), args.Error(1)
+}
+
+type MockBackInStockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockBackInStockNotifier) NotifyBackInStock(ctx context.Context, contact models.CustomerInfo, product models.Product, link string) (bool, error) {
+	args := m.Called(contact, product, link)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockRestockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockRestockNotifier) ProductRestocked(ctx context.Context, productID string) {
+	m.Called(productID)
+}
+
+type MockWishlistService struct {
+	mock.Mock
+}
+
+func (m *MockWishlistService) GetWishlist(ctx context.Context, customerID string) ([]services.WishlistEntry, error) {
+	args := m.Called(customerID)
+	return args.Get(0).([]services.WishlistEntry), args.Error(1)
+}
+
+func (m *MockWishlistService) SaveToWishlist(ctx context.Context, customerID string, productID string, notifyRestock bool) (*services.WishlistEntry, error) {
+	args := m.Called(customerID, productID, notifyRestock)
+	val := args.Get(0)
+	if val == nil {
+		return nil, args.Error(1)
+	}
+	return val.(*services.WishlistEntry), args.Error(1)
+}
+
+func (m *MockWishlistService) RemoveFromWishlist(ctx context.Context, customerID string, productID string) error {
+	args := m.Called(customerID, productID)
+	return args.Error(0)
+}
+
+func (m *MockWishlistService) MostWishlisted(ctx context.Context, limit int) ([]services.WishlistReportRow, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]services.WishlistReportRow), args.Error(1)
+}
+
+func TestWishlistService(t *testing.T) {
+	darjeeling := &models.Product{ID: "prod1", Name: "First Flush Darjeeling", Price: 650.0, InStock: true, Stock: 4}
+
+	t.Run("GetWishlist - Skips Removed Products", func(t *testing.T) {
+		mockWishlist := new(MockWishlistRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockWishlist.On("ListItems", "cus_1").Return([]models.WishlistItem{
+			{CustomerID: "cus_1", ProductID: "prod1", NotifyRestock: true},
+			{CustomerID: "cus_1", ProductID: "gone"},
+		}, nil)
+		mockProductRepo.On("GetProduct", "prod1").Return(darjeeling, nil)
+		mockProductRepo.On("GetProduct", "gone").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.WishlistService{Repository: mockWishlist, Products: mockProductRepo}
+		entries, err := service.GetWishlist(context.Background(), "cus_1")
+
+		assert.Nil(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "First Flush Darjeeling", entries[0].Product.Name)
+		assert.True(t, entries[0].NotifyRestock)
+	})
+
+	t.Run("SaveToWishlist - Unknown Product", func(t *testing.T) {
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "nope").Return(nil, mongo.ErrNoDocuments)
+
+		service := &services.WishlistService{Repository: new(MockWishlistRepository), Products: mockProductRepo}
+		_, err := service.SaveToWishlist(context.Background(), "cus_1", "nope", true)
+
+		assert.ErrorIs(t, err, services.ErrProductNotFound)
+	})
+
+	t.Run("RemoveFromWishlist - Not On Wishlist", func(t *testing.T) {
+		mockWishlist := new(MockWishlistRepository)
+		mockWishlist.On("RemoveItem", "cus_1", "prod1").Return(mongo.ErrNoDocuments)
+
+		service := &services.WishlistService{Repository: mockWishlist}
+		err := service.RemoveFromWishlist(context.Background(), "cus_1", "prod1")
+
+		assert.ErrorIs(t, err, services.ErrWishlistItemNotFound)
+	})
+
+	t.Run("AlertRestocked - Alerts Watchers And Switches Alerts Off", func(t *testing.T) {
+		mockWishlist := new(MockWishlistRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockCustomers := new(MockCustomerRepository)
+		mockNotifier := new(MockBackInStockNotifier)
+
+		mockProductRepo.On("GetProduct", "prod1").Return(darjeeling, nil)
+		mockWishlist.On("ListRestockWatchers", "prod1", int64(51)).Return([]models.WishlistItem{
+			{CustomerID: "cus_1", ProductID: "prod1", NotifyRestock: true},
+			{CustomerID: "cus_2", ProductID: "prod1", NotifyRestock: true},
+		}, nil)
+		mockCustomers.On("GetCustomer", "cus_1").Return(&models.Customer{ID: "cus_1", Name: "Asha", Email: "asha@example.com"}, nil)
+		mockCustomers.On("GetCustomer", "cus_2").Return(&models.Customer{ID: "cus_2", Phone: "+919876543210"}, nil)
+		mockNotifier.On("NotifyBackInStock", models.CustomerInfo{Name: "Asha", Email: "asha@example.com"}, *darjeeling, "https://shop.example.com/?product=prod1").Return(true, nil)
+		mockNotifier.On("NotifyBackInStock", models.CustomerInfo{Phone: "+919876543210"}, *darjeeling, mock.Anything).Return(false, nil)
+		mockWishlist.On("ClaimRestockAlert", "cus_1", "prod1", mock.Anything).Return(true, nil)
+		mockWishlist.On("ClaimRestockAlert", "cus_2", "prod1", mock.Anything).Return(true, nil)
+
+		service := &services.WishlistService{
+			Repository: mockWishlist,
+			Products:   mockProductRepo,
+			Customers:  mockCustomers,
+			Notifiers:  []services.BackInStockNotifier{mockNotifier},
+			ShopURL:    "https://shop.example.com",
+		}
+		sent, more, err := service.AlertRestocked(context.Background(), "prod1", 50)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, sent)
+		assert.False(t, more)
+		mockWishlist.AssertExpectations(t)
+	})
+
+	t.Run("AlertRestocked - Sold Out Again", func(t *testing.T) {
+		mockWishlist := new(MockWishlistRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", InStock: false, Stock: 0}, nil)
+
+		service := &services.WishlistService{Repository: mockWishlist, Products: mockProductRepo}
+		sent, more, err := service.AlertRestocked(context.Background(), "prod1", 50)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, sent)
+		assert.False(t, more)
+		mockWishlist.AssertNotCalled(t, "ListRestockWatchers", mock.Anything, mock.Anything)
+	})
+
+	t.Run("MostWishlisted - Adds Product Names", func(t *testing.T) {
+		mockWishlist := new(MockWishlistRepository)
+		mockProductRepo := new(MockProductRepository)
+		mockWishlist.On("MostWishlisted", int64(20)).Return([]repositories.WishlistCount{{ProductID: "prod1", Customers: 12, Watching: 5}}, nil)
+		mockProductRepo.On("GetProduct", "prod1").Return(darjeeling, nil)
+
+		service := &services.WishlistService{Repository: mockWishlist, Products: mockProductRepo}
+		rows, err := service.MostWishlisted(context.Background(), 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []services.WishlistReportRow{{ProductID: "prod1", Name: "First Flush Darjeeling", InStock: true, Customers: 12, WatchingRestock: 5}}, rows)
+	})
+}
+
+func TestRestockEvents(t *testing.T) {
+	t.Run("ImportCatalog - Reports Products Back In Stock", func(t *testing.T) {
+		mockRepo := new(MockProductRepository)
+		mockRestocks := new(MockRestockNotifier)
+		mockRepo.On("GetProducts").Return([]models.Product{
+			{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: false, Stock: 0},
+			{ID: "2", Name: "Darjeeling", Price: 450, Category: "Black Tea", InStock: true, Stock: 3},
+		}, nil)
+		mockRepo.On("UpsertProducts", mock.Anything).Return(nil)
+		mockRestocks.On("ProductRestocked", "1").Return()
+
+		service := &services.ProductService{Repository: mockRepo, Restocks: mockRestocks}
+		_, err := service.ImportCatalog(context.Background(), withStock(
+			models.Product{ID: "1", Name: "Assam", Price: 299, Category: "Black Tea", InStock: true, Stock: 10},
+			models.Product{ID: "2", Name: "Darjeeling", Price: 450, Category: "Black Tea", InStock: true, Stock: 8},
+		), false)
+
+		assert.Nil(t, err)
+		mockRestocks.AssertExpectations(t)
+		mockRestocks.AssertNumberOfCalls(t, "ProductRestocked", 1)
+	})
+
+	t.Run("ImportCatalog - Dry Run Reports Nothing", func(t *testing.T) {
+		mockRepo := new(MockProductRepository)
+		mockRestocks := new(MockRestockNotifier)
+		mockRepo.On("GetProducts").Return([]models.Product{{ID: "1", Name: "Assam", Stock: 0}}, nil)
+
+		service := &services.ProductService{Repository: mockRepo, Restocks: mockRestocks}
+		_, err := service.ImportCatalog(context.Background(), withStock(models.Product{ID: "1", Name: "Assam", Stock: 10, InStock: true}), true)
+
+		assert.Nil(t, err)
+		mockRestocks.AssertNotCalled(t, "ProductRestocked", mock.Anything)
+	})
+
+	t.Run("CancelOrder - Releasing Last Stock Reports Restock", func(t *testing.T) {
+		mockOrderRepo := new(MockOrderRepository)
+		mockProductRepo := new(MockProductRepositoryForOrderService)
+		mockRestocks := new(MockRestockNotifier)
+
+		order := &models.Order{
+			ID:           "order1",
+			CustomerInfo: models.CustomerInfo{Phone: "9876543210"},
+			Items:        []models.CartItem{{ProductID: "prod1", Quantity: 2, Price: 199.0}},
+			Status:       models.OrderStatusPending,
+		}
+		cancelled := *order
+		cancelled.Status = models.OrderStatusCancelled
+		mockOrderRepo.On("GetOrder", "order1").Return(order, nil)
+		mockOrderRepo.On("TransitionStatus", "order1", mock.Anything, mock.Anything, mock.Anything).Return(&cancelled, nil)
+		mockProductRepo.On("ReleaseStock", "prod1", 2).Return(nil)
+		mockProductRepo.On("GetProduct", "prod1").Return(&models.Product{ID: "prod1", InStock: true, Stock: 2}, nil)
+		mockRestocks.On("ProductRestocked", "prod1").Return()
+
+		service := &services.OrderService{OrderRepository: mockOrderRepo, ProductRepository: mockProductRepo, Restocks: mockRestocks}
+		_, err := service.CancelOrder(context.Background(), "order1", services.CancelOrderRequest{Reason: "changed my mind", Phone: "9876543210"})
+
+		assert.Nil(t, err)
+		mockRestocks.AssertExpectations(t)
+	})
+
+	t.Run("StockEvents - Queues Restock Job", func(t *testing.T) {
+		mockJobs := new(MockJobRepository)
+		mockJobs.On("Enqueue", mock.MatchedBy(func(request models.JobRequest) bool {
+			return request.Type == models.JobTypeProductRestocked && request.Payload["product_id"] == "prod1"
+		})).Return(nil)
+
+		events := &jobs.StockEvents{Jobs: mockJobs}
+		events.ProductRestocked(context.Background(), "prod1")
+
+		mockJobs.AssertExpectations(t)
+	})
+
+	t.Run("NotifyBackInStock - Email And Opted In Phone", func(t *testing.T) {
+		mockOutbox := new(MockNotificationRepository)
+		mockPreferences := new(MockPreferenceRepository)
+		mockPreferences.On("GetPreference", "+919876543210").Return(&models.MessagingPreference{OptedIn: true, Channel: "whatsapp"}, nil)
+		var queued []models.Notification
+		mockOutbox.On("Enqueue", mock.Anything).Run(func(args mock.Arguments) {
+			queued = append(queued, args.Get(0).(models.Notification))
+		}).Return(nil)
+
+		contact := models.CustomerInfo{Name: "Asha", Email: "asha@example.com", Phone: "+919876543210"}
+		product := models.Product{ID: "prod1", Name: "First Flush Darjeeling", Price: 650.0}
+		link := "https://shop.example.com/?product=prod1"
+		emailed, err := (&notifications.Notifier{Outbox: mockOutbox}).NotifyBackInStock(context.Background(), contact, product, link)
+		assert.Nil(t, err)
+		assert.True(t, emailed)
+		messaged, err := (&messaging.Notifier{Outbox: mockOutbox, Preferences: mockPreferences}).NotifyBackInStock(context.Background(), contact, product, link)
+		assert.Nil(t, err)
+		assert.True(t, messaged)
+
+		assert.Len(t, queued, 2)
+		assert.Equal(t, "First Flush Darjeeling is back in stock", queued[0].Subject)
+		assert.Contains(t, queued[0].HTML, `href="https://shop.example.com/?product=prod1"`)
+		assert.Contains(t, queued[0].Text, "₹650.00")
+		assert.Equal(t, []string{"Asha", "First Flush Darjeeling", link}, queued[1].Params)
+	})
+}
+
+func TestWishlistController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := &services.CustomerTokens{Secret: []byte("test-secret")}
+
+	t.Run("GetWishlist - Guest Is Unauthorized", func(t *testing.T) {
+		mockService := new(MockWishlistService)
+
+		router := gin.New()
+		controller := &controllers.WishlistController{Service: mockService}
+		router.GET("/api/wishlist", middleware.CustomerAuth(tokens), controller.GetWishlist)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/wishlist", nil)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		mockService.AssertNotCalled(t, "GetWishlist", mock.Anything)
+	})
+
+	t.Run("SaveToWishlist - Opts In To Restock Alert", func(t *testing.T) {
+		mockService := new(MockWishlistService)
+		mockService.On("SaveToWishlist", "cus_1", "prod1", true).Return(&services.WishlistEntry{NotifyRestock: true}, nil)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		router := gin.New()
+		controller := &controllers.WishlistController{Service: mockService}
+		router.PUT("/api/wishlist/:product_id", middleware.CustomerAuth(tokens), controller.SaveToWishlist)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/api/wishlist/prod1", bytes.NewBufferString(`{"notify_restock": true}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("RemoveFromWishlist - Not Found", func(t *testing.T) {
+		mockService := new(MockWishlistService)
+		mockService.On("RemoveFromWishlist", "cus_1", "prod1").Return(services.ErrWishlistItemNotFound)
+		token, _, _ := tokens.Issue("cus_1", time.Now())
+
+		router := gin.New()
+		controller := &controllers.WishlistController{Service: mockService}
+		router.DELETE("/api/wishlist/:product_id", middleware.CustomerAuth(tokens), controller.RemoveFromWishlist)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/api/wishlist/prod1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("MostWishlisted - Invalid Limit", func(t *testing.T) {
+		router := gin.New()
+		controller := &controllers.WishlistController{Service: new(MockWishlistService)}
+		router.GET("/api/admin/wishlists/top", controller.MostWishlisted)
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/wishlists/top?limit=lots", nil)
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
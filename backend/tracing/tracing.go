@@ -0,0 +1,89 @@
+// Package tracing sets up OpenTelemetry tracing. Requests are traced from the storefront, through the
+// services, to MongoDB and the payment gateway, so a slow checkout shows where the time went.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies the backend in traces unless OTEL_SERVICE_NAME is set.
+const ServiceName = "mangal-chai-backend"
+
+// Start starts a span named name as a child of any span in ctx. The tracer comes from the global provider,
+// so spans started before Setup installs one are not recorded.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(ServiceName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// NewExporterFromEnv builds the span exporter selected by OTEL_TRACES_EXPORTER: "otlp", "console" (JSON
+// lines on stdout) or "none". When it is not set, spans go to the OTLP collector at
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT if either is set, and to stdout
+// otherwise. A nil exporter means tracing is off.
+func NewExporterFromEnv(ctx context.Context) (sdktrace.SpanExporter, error) {
+	exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	if exporter == "" {
+		exporter = "console"
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			exporter = "otlp"
+		}
+	}
+	switch exporter {
+	case "otlp":
+		// The endpoint, headers and timeout are read from the standard OTEL_EXPORTER_OTLP_* variables.
+		return otlptracehttp.New(ctx)
+	case "console":
+		return stdouttrace.New()
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, use otlp, console or none", exporter)
+	}
+}
+
+// Setup installs a tracer provider exporting to the exporter chosen by NewExporterFromEnv, and W3C trace
+// context propagation so traces started by the storefront continue here. Sampling follows
+// OTEL_TRACES_SAMPLER, sampling everything by default. The returned function flushes buffered spans.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, err := NewExporterFromEnv(ctx)
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName())))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func serviceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return ServiceName
+}
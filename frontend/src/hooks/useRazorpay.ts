@@ -1,5 +1,6 @@
 
 import type { CartItem } from "../types";
+import { traceparent } from "../services/tracing";
 
 declare global {
   interface Window {
@@ -20,6 +21,7 @@ const useRazorpay = () => {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
+          traceparent: traceparent(),
         },
         credentials: "include",
         body: JSON.stringify({ items: itemsToOrder }),
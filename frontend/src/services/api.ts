@@ -1,4 +1,5 @@
 import axios from 'axios';
+import { traceparent } from './tracing';
 
 // Use Vite environment variable (VITE_ prefix)
 const backendUrl = import.meta.env.VITE_API_BASE_URL || 'http://localhost:8001/api';
@@ -11,6 +12,11 @@ const api = axios.create({
   withCredentials: true, // Enable credentials for CORS
 });
 
+api.interceptors.request.use((config) => {
+  config.headers.set('traceparent', traceparent());
+  return config;
+});
+
 export const fetchProducts = async () => {
   const response = await api.get('/products');
   return response.data;
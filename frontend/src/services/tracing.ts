@@ -0,0 +1,8 @@
+// W3C trace context for API requests, so the backend's trace of a request starts in the browser.
+// See https://www.w3.org/TR/trace-context/#traceparent-header.
+
+const randomHex = (bytes: number) =>
+  Array.from(crypto.getRandomValues(new Uint8Array(bytes)), (b) => b.toString(16).padStart(2, '0')).join('');
+
+// traceparent starts a new sampled trace for one request.
+export const traceparent = () => `00-${randomHex(16)}-${randomHex(8)}-01`;